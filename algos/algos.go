@@ -0,0 +1,249 @@
+// Package algos executes parent orders along a schedule: TWAP evenly over
+// time, VWAP along a historical volume curve, and POV as a share of the
+// traded volume. An Algo is a strategy.Strategy, so the same algo runs in
+// backtest, paper and live modes of the strategy package.
+//
+// Market event times drive the schedule. On every event of its symbol, an
+// algo that is behind its schedule sends an IOC child order at the best
+// opposite price, within the parent's limit, for the shortfall.
+package algos
+
+import (
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/strategy"
+)
+
+// Parent is an order to execute over a window
+type Parent struct {
+	Symbol   string
+	Side     matching.OrderSide
+	Quantity uint64
+	// Limit is the worst price of child orders (0 for none)
+	Limit uint64
+	Start time.Time
+	End   time.Time
+	// MinQuantity is the smallest child order, except for the last one
+	MinQuantity uint64
+}
+
+// Progress is the execution state of a parent order. Prices are in the units
+// of the market events; slippages are in basis points, positive when worse
+// for the side of the parent.
+type Progress struct {
+	Time      time.Time
+	Executed  uint64
+	Remaining uint64
+	// Target is the quantity to have executed at Time
+	Target       uint64
+	AveragePrice float64
+	// ArrivalPrice is the mid price at the start of the parent
+	ArrivalPrice float64
+	// MarketVolume and MarketVWAP cover the trades since the start
+	MarketVolume uint64
+	MarketVWAP   float64
+	// ArrivalSlippage compares the average price to the arrival price
+	ArrivalSlippage float64
+	// VWAPSlippage compares the average price to the market VWAP
+	VWAPSlippage float64
+}
+
+// Algo executes a parent order along a schedule
+type Algo struct {
+	// OnProgress is called after every fill of a child order (optional)
+	OnProgress func(Progress)
+
+	parent   Parent
+	schedule Schedule
+	broker   strategy.Broker
+
+	now      time.Time
+	executed uint64
+	notional float64
+	arrival  float64
+	volume   uint64
+	turnover float64
+	children map[uint64]bool
+}
+
+// New creates an algo executing parent along schedule
+func New(parent Parent, schedule Schedule) *Algo {
+	return &Algo{
+		parent:   parent,
+		schedule: schedule,
+		children: make(map[uint64]bool),
+	}
+}
+
+// NewTWAP creates an algo executing parent evenly over its window
+func NewTWAP(parent Parent) *Algo {
+	return New(parent, TWAP{})
+}
+
+// NewVWAP creates an algo executing parent along a historical volume curve
+func NewVWAP(parent Parent, curve []float64) *Algo {
+	return New(parent, VWAP{Curve: curve})
+}
+
+// NewPOV creates an algo executing parent at a participation rate
+func NewPOV(parent Parent, rate float64) *Algo {
+	return New(parent, POV{Rate: rate})
+}
+
+// Parent returns the parent order of the algo
+func (a *Algo) Parent() Parent {
+	return a.parent
+}
+
+// Done reports whether the parent order is fully executed
+func (a *Algo) Done() bool {
+	return a.executed >= a.parent.Quantity
+}
+
+// Progress returns the execution state at the time of the last event
+func (a *Algo) Progress() Progress {
+	p := Progress{
+		Time:         a.now,
+		Executed:     a.executed,
+		Remaining:    a.parent.Quantity - min(a.executed, a.parent.Quantity),
+		Target:       a.target(),
+		ArrivalPrice: a.arrival,
+		MarketVolume: a.volume,
+	}
+	if a.executed > 0 {
+		p.AveragePrice = a.notional / float64(a.executed)
+		p.ArrivalSlippage = a.slippage(p.AveragePrice, a.arrival)
+	}
+	if a.volume > 0 {
+		p.MarketVWAP = a.turnover / float64(a.volume)
+		if a.executed > 0 {
+			p.VWAPSlippage = a.slippage(p.AveragePrice, p.MarketVWAP)
+		}
+	}
+	return p
+}
+
+// Start implements strategy.Strategy
+func (a *Algo) Start(broker strategy.Broker) {
+	a.broker = broker
+}
+
+// OnBookUpdate implements strategy.Strategy
+func (a *Algo) OnBookUpdate(event strategy.MarketEvent, book *strategy.Book) {
+	if event.Symbol != a.parent.Symbol {
+		return
+	}
+	a.now = event.Time
+	if !a.started() {
+		return
+	}
+	if a.arrival == 0 {
+		bid, okBid := book.BestBid()
+		ask, okAsk := book.BestAsk()
+		if okBid && okAsk {
+			a.arrival = float64(bid.Price+ask.Price) / 2
+		}
+	}
+	a.work(book)
+}
+
+// OnTrade implements strategy.Strategy
+func (a *Algo) OnTrade(event strategy.MarketEvent) {
+	if event.Symbol != a.parent.Symbol {
+		return
+	}
+	a.now = event.Time
+	if !a.started() {
+		return
+	}
+	a.volume += event.Quantity
+	a.turnover += float64(event.Price) * float64(event.Quantity)
+}
+
+// OnFill implements strategy.Strategy
+func (a *Algo) OnFill(fill strategy.Fill) {
+	if !a.children[fill.OrderID] {
+		return
+	}
+	if fill.LeavesQuantity == 0 {
+		delete(a.children, fill.OrderID)
+	}
+	a.executed += fill.Quantity
+	a.notional += float64(fill.Price) * float64(fill.Quantity)
+	if a.OnProgress != nil {
+		a.OnProgress(a.Progress())
+	}
+}
+
+// started reports whether the window of the parent has started
+func (a *Algo) started() bool {
+	return !a.now.Before(a.parent.Start)
+}
+
+// target returns the scheduled quantity at the time of the last event
+func (a *Algo) target() uint64 {
+	if !a.started() {
+		return 0
+	}
+	return a.schedule.Target(a.parent, a.now, a.volume)
+}
+
+// work sends a child order for the shortfall against the schedule. Child
+// orders are IOC, so unfilled quantity is retried on later events.
+func (a *Algo) work(book *strategy.Book) {
+	if a.broker == nil || a.Done() {
+		return
+	}
+	target := a.target()
+	if target <= a.executed {
+		return
+	}
+	quantity := target - a.executed
+	remaining := a.parent.Quantity - a.executed
+	if quantity < a.parent.MinQuantity && quantity < remaining {
+		return
+	}
+
+	level, ok := book.BestAsk()
+	if a.parent.Side == matching.OrderSideSell {
+		level, ok = book.BestBid()
+	}
+	if !ok || !a.within(level.Price) {
+		return
+	}
+	id, err := a.broker.Submit(strategy.Order{
+		Symbol:   a.parent.Symbol,
+		Side:     a.parent.Side,
+		IOC:      true,
+		Price:    level.Price,
+		Quantity: quantity,
+	})
+	if err != nil {
+		return
+	}
+	a.children[id] = true
+}
+
+// within reports whether price respects the limit of the parent
+func (a *Algo) within(price uint64) bool {
+	if a.parent.Limit == 0 {
+		return true
+	}
+	if a.parent.Side == matching.OrderSideBuy {
+		return price <= a.parent.Limit
+	}
+	return price >= a.parent.Limit
+}
+
+// slippage returns the cost of price against benchmark in basis points
+func (a *Algo) slippage(price, benchmark float64) float64 {
+	if benchmark == 0 {
+		return 0
+	}
+	bps := (price - benchmark) / benchmark * 10000
+	if a.parent.Side == matching.OrderSideSell {
+		return -bps
+	}
+	return bps
+}
@@ -0,0 +1,119 @@
+package algos
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tienpsm/go-trader/itch"
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/sim"
+	"github.com/tienpsm/go-trader/strategy"
+)
+
+var (
+	aapl  = itch.StockField("AAPL")
+	day   = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	start = day.Add(10 * time.Hour)
+)
+
+// at returns the ITCH timestamp of a time of the day
+func at(t time.Time) uint64 {
+	return uint64(t.Sub(day))
+}
+
+// newSession starts a paper session with 10000 shares offered at 10100 and
+// 500 bid at 10000 at the start of the window
+func newSession(algo *Algo) *strategy.Paper {
+	p := strategy.NewPaper(algo, day, sim.Config{})
+	p.OnAddOrder(itch.AddOrderMessage{StockLocate: 1, Timestamp: at(start), OrderReferenceNumber: 1, BuySellIndicator: 'S', Shares: 10000, Stock: aapl, Price: 10100})
+	p.OnAddOrder(itch.AddOrderMessage{StockLocate: 1, Timestamp: at(start), OrderReferenceNumber: 2, BuySellIndicator: 'B', Shares: 500, Stock: aapl, Price: 10000})
+	return p
+}
+
+// tick publishes a book update at t
+func tick(p *strategy.Paper, t time.Time) {
+	p.OnOrderCancel(itch.OrderCancelMessage{StockLocate: 1, Timestamp: at(t), OrderReferenceNumber: 2, CanceledShares: 1})
+}
+
+func TestSchedules(t *testing.T) {
+	parent := Parent{Quantity: 1000, Start: start, End: start.Add(10 * time.Minute)}
+
+	if got := (TWAP{}).Target(parent, start.Add(-time.Minute), 0); got != 0 {
+		t.Errorf("Expected TWAP target 0 before the start, got %d", got)
+	}
+	if got := (TWAP{}).Target(parent, start.Add(3*time.Minute), 0); got != 300 {
+		t.Errorf("Expected TWAP target 300, got %d", got)
+	}
+	vwap := VWAP{Curve: []float64{1, 3}}
+	if got := vwap.Target(parent, start.Add(5*time.Minute), 0); got != 250 {
+		t.Errorf("Expected VWAP target 250 after the first bucket, got %d", got)
+	}
+	if got := vwap.Target(parent, start.Add(7*time.Minute+30*time.Second), 0); got != 625 {
+		t.Errorf("Expected VWAP target 625 halfway through the second bucket, got %d", got)
+	}
+	if got := vwap.Target(parent, start.Add(time.Hour), 0); got != 1000 {
+		t.Errorf("Expected VWAP target 1000 after the end, got %d", got)
+	}
+	if got := (POV{Rate: 0.1}).Target(parent, start.Add(time.Minute), 4321); got != 432 {
+		t.Errorf("Expected POV target 432, got %d", got)
+	}
+	if got := (POV{Rate: 0.1}).Target(parent, start.Add(10*time.Minute), 0); got != 1000 {
+		t.Errorf("Expected POV target 1000 at the end, got %d", got)
+	}
+}
+
+func TestTWAP_Paper(t *testing.T) {
+	algo := NewTWAP(Parent{Symbol: "AAPL", Side: matching.OrderSideBuy, Quantity: 1000, Start: start, End: start.Add(10 * time.Minute)})
+	var updates []Progress
+	algo.OnProgress = func(p Progress) { updates = append(updates, p) }
+	p := newSession(algo)
+
+	for minute := 1; minute <= 4; minute++ {
+		tick(p, start.Add(time.Duration(minute)*time.Minute))
+	}
+	if progress := algo.Progress(); progress.Executed != 400 || progress.Target != 400 {
+		t.Errorf("Expected 400 executed after 4 minutes, got %+v", progress)
+	}
+	tick(p, start.Add(10*time.Minute))
+	if !algo.Done() || len(updates) != 5 {
+		t.Fatalf("Expected the parent done in 5 fills, got %d fills and %+v", len(updates), algo.Progress())
+	}
+
+	progress := algo.Progress()
+	if progress.AveragePrice != 10100 || progress.ArrivalPrice != 10050 {
+		t.Errorf("Expected average 10100 against arrival 10050, got %+v", progress)
+	}
+	if want := 50.0 / 10050 * 10000; math.Abs(progress.ArrivalSlippage-want) > 1e-9 {
+		t.Errorf("Expected arrival slippage %.2f bps, got %.2f", want, progress.ArrivalSlippage)
+	}
+	if pos := p.Simulator.Position("AAPL"); pos.Shares != 1000 {
+		t.Errorf("Expected long 1000, got %+v", pos)
+	}
+}
+
+func TestPOV_Limit(t *testing.T) {
+	algo := NewPOV(Parent{Symbol: "AAPL", Side: matching.OrderSideSell, Quantity: 1000, Limit: 10000, Start: start}, 0.1)
+	p := newSession(algo)
+
+	// Trades published before the start do not count
+	p.OnTrade(itch.TradeMessage{StockLocate: 1, Timestamp: at(start.Add(-time.Minute)), BuySellIndicator: 'B', Shares: 5000, Stock: aapl, Price: 10050})
+	p.OnTrade(itch.TradeMessage{StockLocate: 1, Timestamp: at(start.Add(time.Minute)), BuySellIndicator: 'B', Shares: 2000, Stock: aapl, Price: 10050})
+	tick(p, start.Add(time.Minute))
+	if progress := algo.Progress(); progress.Executed != 200 || progress.MarketVolume != 2000 || progress.MarketVWAP != 10050 {
+		t.Errorf("Expected 10%% of 2000 executed, got %+v", progress)
+	}
+	if want := 50.0 / 10050 * 10000; math.Abs(algo.Progress().VWAPSlippage-want) > 1e-9 {
+		t.Errorf("Expected VWAP slippage %.2f bps, got %.2f", want, algo.Progress().VWAPSlippage)
+	}
+
+	// No child below the limit
+	p.OnAddOrder(itch.AddOrderMessage{StockLocate: 1, Timestamp: at(start.Add(2 * time.Minute)), OrderReferenceNumber: 3, BuySellIndicator: 'S', Shares: 100, Stock: aapl, Price: 10050})
+	p.OnOrderDelete(itch.OrderDeleteMessage{StockLocate: 1, Timestamp: at(start.Add(2 * time.Minute)), OrderReferenceNumber: 2})
+	p.OnAddOrder(itch.AddOrderMessage{StockLocate: 1, Timestamp: at(start.Add(2 * time.Minute)), OrderReferenceNumber: 4, BuySellIndicator: 'B', Shares: 500, Stock: aapl, Price: 9900})
+	p.OnTrade(itch.TradeMessage{StockLocate: 1, Timestamp: at(start.Add(3 * time.Minute)), BuySellIndicator: 'B', Shares: 1000, Stock: aapl, Price: 9900})
+	p.OnOrderCancel(itch.OrderCancelMessage{StockLocate: 1, Timestamp: at(start.Add(3 * time.Minute)), OrderReferenceNumber: 4, CanceledShares: 1})
+	if progress := algo.Progress(); progress.Executed != 200 || progress.Target != 300 {
+		t.Errorf("Expected 200 executed behind a target of 300, got %+v", progress)
+	}
+}
@@ -0,0 +1,85 @@
+package algos
+
+import (
+	"math"
+	"time"
+)
+
+// Schedule is the execution schedule of a parent order
+type Schedule interface {
+	// Target returns the quantity of parent to have executed at now, given
+	// the trade volume of the symbol published since the parent's start
+	Target(parent Parent, now time.Time, marketVolume uint64) uint64
+}
+
+// TWAP executes evenly over the window of the parent
+type TWAP struct{}
+
+// Target implements Schedule
+func (TWAP) Target(parent Parent, now time.Time, marketVolume uint64) uint64 {
+	return scale(parent.Quantity, elapsed(parent, now))
+}
+
+// VWAP executes along a historical volume curve. Curve holds the relative
+// volume of consecutive buckets of equal length covering the window of the
+// parent, so a curve of 13 half-hour volumes fits a full trading day.
+// Volume is assumed even inside a bucket.
+type VWAP struct {
+	Curve []float64
+}
+
+// Target implements Schedule
+func (s VWAP) Target(parent Parent, now time.Time, marketVolume uint64) uint64 {
+	var total float64
+	for _, v := range s.Curve {
+		total += v
+	}
+	if total <= 0 {
+		return TWAP{}.Target(parent, now, marketVolume)
+	}
+	position := elapsed(parent, now) * float64(len(s.Curve))
+	var done float64
+	for i, v := range s.Curve {
+		if position >= float64(i+1) {
+			done += v
+			continue
+		}
+		done += v * (position - float64(i))
+		break
+	}
+	return scale(parent.Quantity, done/total)
+}
+
+// POV executes a fixed share of the trade volume published since the start of
+// the parent, finishing at its end at the latest when End is set
+type POV struct {
+	// Rate is the participation rate, 0.1 for 10% of the volume
+	Rate float64
+}
+
+// Target implements Schedule
+func (s POV) Target(parent Parent, now time.Time, marketVolume uint64) uint64 {
+	if !parent.End.IsZero() && !now.Before(parent.End) {
+		return parent.Quantity
+	}
+	return min(parent.Quantity, uint64(math.Floor(s.Rate*float64(marketVolume))))
+}
+
+// elapsed returns the elapsed fraction of the window of the parent
+func elapsed(parent Parent, now time.Time) float64 {
+	if !now.After(parent.Start) {
+		return 0
+	}
+	if !now.Before(parent.End) {
+		return 1
+	}
+	return float64(now.Sub(parent.Start)) / float64(parent.End.Sub(parent.Start))
+}
+
+// scale returns the fraction of quantity, rounded down
+func scale(quantity uint64, fraction float64) uint64 {
+	if fraction >= 1 {
+		return quantity
+	}
+	return uint64(math.Floor(float64(quantity) * fraction))
+}
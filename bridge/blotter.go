@@ -0,0 +1,387 @@
+package bridge
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/tienpsm/go-trader/itch"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// ExecutionSource is where a blotter execution comes from
+type ExecutionSource string
+
+const (
+	// SourceFeed is an execution printed in the ITCH feed
+	SourceFeed ExecutionSource = "itch"
+	// SourceEngine is an execution of the matching engine
+	SourceEngine ExecutionSource = "engine"
+)
+
+// ExecutionKind is the kind of a blotter execution
+type ExecutionKind string
+
+const (
+	// KindExecution is the execution of a displayed order
+	KindExecution ExecutionKind = "execution"
+	// KindNonDisplayed is a trade of a non-displayed order, from a Trade
+	// message
+	KindNonDisplayed ExecutionKind = "non_displayed"
+	// KindCross is the volume of a cross, from a Cross Trade message
+	KindCross ExecutionKind = "cross"
+)
+
+// Execution is a trade of the blotter. Executions of orders are those of the
+// resting order: OrderID, Side and Price are the order's.
+type Execution struct {
+	Source ExecutionSource `json:"source"`
+	Kind   ExecutionKind   `json:"kind"`
+	// Timestamp is the ITCH timestamp of the message causing the execution
+	Timestamp uint64 `json:"timestamp"`
+	Stock     string `json:"stock"`
+	OrderID   uint64 `json:"order_id,omitempty"`
+	Side      string `json:"side,omitempty"`
+	Price     uint64 `json:"price"`
+	Quantity  uint64 `json:"quantity"`
+	// MatchNumber is the ITCH match number, 0 for the engine
+	MatchNumber uint64 `json:"match_number,omitempty"`
+	// Printable is false for executions not printed to the tape
+	Printable bool `json:"printable"`
+}
+
+// Mismatch pairs a feed execution with the engine execution of the same
+// order that differs from it
+type Mismatch struct {
+	Feed   Execution `json:"feed"`
+	Engine Execution `json:"engine"`
+}
+
+// Reconciliation compares the executions of the feed and the engine order by
+// order: the n-th execution of an order in the feed is paired with its n-th
+// execution in the engine.
+type Reconciliation struct {
+	// Matched is the number of feed executions the engine executed alike
+	Matched int `json:"matched"`
+	// Mismatched are the pairs differing in price or quantity
+	Mismatched []Mismatch `json:"mismatched"`
+	// Missing are the feed executions without an engine execution, such as
+	// executions of orders added before the feed was joined
+	Missing []Execution `json:"missing"`
+	// Extra are the engine executions without a feed execution, such as
+	// matches of the engine when its matching is enabled
+	Extra []Execution `json:"extra"`
+	// OffBook is the number of non-displayed trades and crosses of the feed,
+	// which have no order in the book to reconcile
+	OffBook int `json:"off_book"`
+}
+
+// Reconciled checks if every execution of the feed and the engine was
+// matched
+func (r Reconciliation) Reconciled() bool {
+	return len(r.Mismatched) == 0 && len(r.Missing) == 0 && len(r.Extra) == 0
+}
+
+// feedOrder is the state of an order of the feed needed to price its
+// executions
+type feedOrder struct {
+	locate uint16
+	side   byte
+	price  uint32
+	shares uint32
+}
+
+// Blotter replays a feed into a MarketManager through a Bridge and records
+// the trades of both: the executions printed in the feed and the executions
+// of the engine, so that they can be exported and reconciled.
+//
+// The feed orders are tracked by the blotter itself, so feed executions are
+// priced even when the engine no longer holds the order.
+type Blotter struct {
+	*Bridge
+
+	// timestamp is the ITCH timestamp of the message being replayed
+	timestamp  uint64
+	orders     map[uint64]feedOrder
+	executions []Execution
+}
+
+// NewBlotter creates a blotter replaying into mm. The handler of mm is
+// wrapped to record the engine executions and keeps receiving every event.
+func NewBlotter(mm *matching.MarketManager) *Blotter {
+	b := &Blotter{
+		Bridge: New(mm),
+		orders: make(map[uint64]feedOrder),
+	}
+	mm.SetHandler(&engineRecorder{MarketHandler: mm.Handler(), blotter: b})
+	return b
+}
+
+// Executions returns the executions of the feed and the engine in the order
+// they happened, each feed execution before the engine execution it caused
+func (b *Blotter) Executions() []Execution {
+	return b.executions
+}
+
+// engineRecorder is the MarketHandler recording the engine executions
+type engineRecorder struct {
+	matching.MarketHandler
+	blotter *Blotter
+}
+
+func (r *engineRecorder) OnExecuteOrder(order matching.Order, price, quantity uint64) {
+	b := r.blotter
+	stock := ""
+	if symbol := b.mm.GetSymbol(order.SymbolID); symbol != nil {
+		stock = symbol.Name
+	}
+	b.executions = append(b.executions, Execution{
+		Source:    SourceEngine,
+		Kind:      KindExecution,
+		Timestamp: b.timestamp,
+		Stock:     stock,
+		OrderID:   order.ID,
+		Side:      order.Side.String(),
+		Price:     price,
+		Quantity:  quantity,
+		Printable: true,
+	})
+	r.MarketHandler.OnExecuteOrder(order, price, quantity)
+}
+
+// sideName returns the name of the order side of an ITCH buy/sell indicator
+func sideName(indicator byte) string {
+	return side(indicator).String()
+}
+
+// execute records a feed execution of an order and reduces it
+func (b *Blotter) execute(ref uint64, shares uint32, price uint32, match uint64, printable bool) {
+	order, ok := b.orders[ref]
+	if !ok {
+		// An order added before the feed was joined: only the engine may
+		// know it
+		if node := b.mm.GetOrder(ref); node != nil {
+			order.locate = uint16(node.SymbolID)
+			order.price = uint32(node.Price)
+			order.side = 'B'
+			if node.IsSell() {
+				order.side = 'S'
+			}
+		}
+	}
+	if price == 0 {
+		price = order.price
+	}
+	e := Execution{
+		Source:      SourceFeed,
+		Kind:        KindExecution,
+		Timestamp:   b.timestamp,
+		Stock:       b.directory.Stock(order.locate),
+		OrderID:     ref,
+		Price:       uint64(price),
+		Quantity:    uint64(shares),
+		MatchNumber: match,
+		Printable:   printable,
+	}
+	if order.side != 0 {
+		e.Side = sideName(order.side)
+	}
+	b.executions = append(b.executions, e)
+	b.reduce(ref, shares)
+}
+
+// reduce removes shares from a feed order
+func (b *Blotter) reduce(ref uint64, shares uint32) {
+	order, ok := b.orders[ref]
+	if !ok {
+		return
+	}
+	if shares >= order.shares {
+		delete(b.orders, ref)
+		return
+	}
+	order.shares -= shares
+	b.orders[ref] = order
+}
+
+// diverged ignores the executions the engine rejects because its own
+// matching already executed the order; Reconcile reports them as missing
+func diverged(err error) error {
+	if errors.Is(err, matching.ErrOrderQuantityInvalid) {
+		return nil
+	}
+	return err
+}
+
+// OnAddOrder tracks and adds an anonymous order
+func (b *Blotter) OnAddOrder(msg itch.AddOrderMessage) error {
+	b.timestamp = msg.Timestamp
+	b.directory.Register(msg.StockLocate, msg.Stock)
+	b.orders[msg.OrderReferenceNumber] = feedOrder{locate: msg.StockLocate, side: msg.BuySellIndicator, price: msg.Price, shares: msg.Shares}
+	return b.Bridge.OnAddOrder(msg)
+}
+
+// OnAddOrderMPID tracks and adds an attributed order
+func (b *Blotter) OnAddOrderMPID(msg itch.AddOrderMPIDMessage) error {
+	b.timestamp = msg.Timestamp
+	b.directory.Register(msg.StockLocate, msg.Stock)
+	b.orders[msg.OrderReferenceNumber] = feedOrder{locate: msg.StockLocate, side: msg.BuySellIndicator, price: msg.Price, shares: msg.Shares}
+	return b.Bridge.OnAddOrderMPID(msg)
+}
+
+// OnOrderExecuted records the execution and executes the order
+func (b *Blotter) OnOrderExecuted(msg itch.OrderExecutedMessage) error {
+	b.timestamp = msg.Timestamp
+	b.execute(msg.OrderReferenceNumber, msg.ExecutedShares, 0, msg.MatchNumber, true)
+	return diverged(b.Bridge.OnOrderExecuted(msg))
+}
+
+// OnOrderExecutedWithPrice records the execution and executes the order
+func (b *Blotter) OnOrderExecutedWithPrice(msg itch.OrderExecutedWithPriceMessage) error {
+	b.timestamp = msg.Timestamp
+	b.execute(msg.OrderReferenceNumber, msg.ExecutedShares, msg.ExecutionPrice, msg.MatchNumber, msg.Printable == 'Y')
+	return diverged(b.Bridge.OnOrderExecutedWithPrice(msg))
+}
+
+// OnOrderCancel reduces the order
+func (b *Blotter) OnOrderCancel(msg itch.OrderCancelMessage) error {
+	b.timestamp = msg.Timestamp
+	b.reduce(msg.OrderReferenceNumber, msg.CanceledShares)
+	return b.Bridge.OnOrderCancel(msg)
+}
+
+// OnOrderDelete stops tracking and deletes the order
+func (b *Blotter) OnOrderDelete(msg itch.OrderDeleteMessage) error {
+	b.timestamp = msg.Timestamp
+	delete(b.orders, msg.OrderReferenceNumber)
+	return b.Bridge.OnOrderDelete(msg)
+}
+
+// OnOrderReplace tracks and replaces the order
+func (b *Blotter) OnOrderReplace(msg itch.OrderReplaceMessage) error {
+	b.timestamp = msg.Timestamp
+	if order, ok := b.orders[msg.OriginalOrderReferenceNumber]; ok {
+		delete(b.orders, msg.OriginalOrderReferenceNumber)
+		order.price, order.shares = msg.Price, msg.Shares
+		b.orders[msg.NewOrderReferenceNumber] = order
+	}
+	return b.Bridge.OnOrderReplace(msg)
+}
+
+// OnTrade records a trade of a non-displayed order
+func (b *Blotter) OnTrade(msg itch.TradeMessage) error {
+	b.timestamp = msg.Timestamp
+	b.directory.Register(msg.StockLocate, msg.Stock)
+	b.executions = append(b.executions, Execution{
+		Source:      SourceFeed,
+		Kind:        KindNonDisplayed,
+		Timestamp:   msg.Timestamp,
+		Stock:       b.directory.Stock(msg.StockLocate),
+		Side:        sideName(msg.BuySellIndicator),
+		Price:       uint64(msg.Price),
+		Quantity:    uint64(msg.Shares),
+		MatchNumber: msg.MatchNumber,
+		Printable:   true,
+	})
+	return b.Bridge.OnTrade(msg)
+}
+
+// OnCrossTrade records the volume of a cross
+func (b *Blotter) OnCrossTrade(msg itch.CrossTradeMessage) error {
+	b.timestamp = msg.Timestamp
+	b.directory.Register(msg.StockLocate, msg.Stock)
+	if msg.Shares > 0 {
+		b.executions = append(b.executions, Execution{
+			Source:      SourceFeed,
+			Kind:        KindCross,
+			Timestamp:   msg.Timestamp,
+			Stock:       b.directory.Stock(msg.StockLocate),
+			Price:       uint64(msg.CrossPrice),
+			Quantity:    msg.Shares,
+			MatchNumber: msg.MatchNumber,
+			Printable:   true,
+		})
+	}
+	return b.Bridge.OnCrossTrade(msg)
+}
+
+// Reconcile compares the executions of the feed with those of the engine
+func (b *Blotter) Reconcile() Reconciliation {
+	r := Reconciliation{}
+	feed := make(map[uint64][]Execution)
+	engine := make(map[uint64][]Execution)
+	var ids []uint64
+	for _, e := range b.executions {
+		switch {
+		case e.Kind != KindExecution:
+			r.OffBook++
+			continue
+		case e.Source == SourceFeed:
+			feed[e.OrderID] = append(feed[e.OrderID], e)
+		default:
+			engine[e.OrderID] = append(engine[e.OrderID], e)
+		}
+		if len(feed[e.OrderID])+len(engine[e.OrderID]) == 1 {
+			ids = append(ids, e.OrderID)
+		}
+	}
+
+	for _, id := range ids {
+		f, g := feed[id], engine[id]
+		n := min(len(f), len(g))
+		for i := 0; i < n; i++ {
+			if f[i].Price == g[i].Price && f[i].Quantity == g[i].Quantity {
+				r.Matched++
+			} else {
+				r.Mismatched = append(r.Mismatched, Mismatch{Feed: f[i], Engine: g[i]})
+			}
+		}
+		r.Missing = append(r.Missing, f[n:]...)
+		r.Extra = append(r.Extra, g[n:]...)
+	}
+	return r
+}
+
+// blotterHeader is the header row of the blotter CSV
+var blotterHeader = []string{
+	"source", "kind", "timestamp", "stock", "order_id", "side", "price", "quantity", "match_number", "printable",
+}
+
+// WriteCSV writes every execution as CSV with a header row
+func (b *Blotter) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(blotterHeader); err != nil {
+		return err
+	}
+	for _, e := range b.executions {
+		record := []string{
+			string(e.Source),
+			string(e.Kind),
+			strconv.FormatUint(e.Timestamp, 10),
+			e.Stock,
+			strconv.FormatUint(e.OrderID, 10),
+			e.Side,
+			strconv.FormatUint(e.Price, 10),
+			strconv.FormatUint(e.Quantity, 10),
+			strconv.FormatUint(e.MatchNumber, 10),
+			strconv.FormatBool(e.Printable),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes every execution and the reconciliation as indented JSON
+func (b *Blotter) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Executions     []Execution    `json:"executions"`
+		Reconciliation Reconciliation `json:"reconciliation"`
+	}{b.executions, b.Reconcile()})
+}
@@ -0,0 +1,123 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/tienpsm/go-trader/itch"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// blotterFeed is a feed with executions of displayed orders, one of an order
+// added before the feed was joined, a non-displayed trade and a cross
+func blotterFeed() []byte {
+	stock := itch.StockField("AAPL")
+	var data []byte
+	data = itch.AppendStockDirectory(data, itch.StockDirectoryMessage{StockLocate: 7, Stock: stock})
+	data = itch.AppendAddOrder(data, itch.AddOrderMessage{StockLocate: 7, Timestamp: 10, OrderReferenceNumber: 1, BuySellIndicator: 'B', Shares: 100, Stock: stock, Price: 1000})
+	data = itch.AppendAddOrder(data, itch.AddOrderMessage{StockLocate: 7, Timestamp: 11, OrderReferenceNumber: 2, BuySellIndicator: 'S', Shares: 200, Stock: stock, Price: 1010})
+	data = itch.AppendOrderExecuted(data, itch.OrderExecutedMessage{StockLocate: 7, Timestamp: 12, OrderReferenceNumber: 1, ExecutedShares: 40, MatchNumber: 1})
+	data = itch.AppendOrderReplace(data, itch.OrderReplaceMessage{StockLocate: 7, Timestamp: 13, OriginalOrderReferenceNumber: 2, NewOrderReferenceNumber: 3, Shares: 150, Price: 1005})
+	data = itch.AppendOrderExecutedWithPrice(data, itch.OrderExecutedWithPriceMessage{StockLocate: 7, Timestamp: 14, OrderReferenceNumber: 3, ExecutedShares: 50, MatchNumber: 2, Printable: 'N', ExecutionPrice: 1004})
+	data = itch.AppendOrderExecuted(data, itch.OrderExecutedMessage{StockLocate: 7, Timestamp: 15, OrderReferenceNumber: 99, ExecutedShares: 10, MatchNumber: 3})
+	data = itch.AppendTrade(data, itch.TradeMessage{StockLocate: 7, Timestamp: 16, BuySellIndicator: 'B', Shares: 25, Stock: stock, Price: 1002, MatchNumber: 4})
+	data = itch.AppendCrossTrade(data, itch.CrossTradeMessage{StockLocate: 7, Timestamp: 17, Shares: 500, Stock: stock, CrossPrice: 1003, MatchNumber: 5, CrossType: 'C'})
+	return data
+}
+
+func TestBlotter_Reconcile(t *testing.T) {
+	b := NewBlotter(matching.NewMarketManager())
+	if _, _, err := itch.NewParser(b).ParseAll(blotterFeed()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var feed, engine int
+	for _, e := range b.Executions() {
+		if e.Source == SourceFeed {
+			feed++
+		} else {
+			engine++
+		}
+	}
+	if feed != 5 || engine != 2 {
+		t.Errorf("Expected 5 feed and 2 engine executions, got %d and %d", feed, engine)
+	}
+
+	first := b.Executions()[0]
+	if first.Stock != "AAPL" || first.Side != "BUY" || first.Price != 1000 || first.Quantity != 40 || first.Timestamp != 12 {
+		t.Errorf("Expected the feed execution of 40 @ 1000, got %+v", first)
+	}
+
+	r := b.Reconcile()
+	if r.Matched != 2 || len(r.Mismatched) != 0 || r.OffBook != 2 {
+		t.Errorf("Expected 2 matched and 2 off-book executions, got %+v", r)
+	}
+	if len(r.Missing) != 1 || r.Missing[0].OrderID != 99 {
+		t.Errorf("Expected the execution of unknown order 99 missing, got %+v", r.Missing)
+	}
+	if len(r.Extra) != 0 || r.Reconciled() {
+		t.Errorf("Expected no extra executions and an unreconciled blotter, got %+v", r.Extra)
+	}
+}
+
+func TestBlotter_EngineMatching(t *testing.T) {
+	mm := matching.NewMarketManager()
+	mm.EnableMatching()
+	b := NewBlotter(mm)
+
+	// The engine matches the crossing bid the feed leaves resting, then
+	// executes the rest of the ask the feed executes in full
+	stock := itch.StockField("AAPL")
+	var data []byte
+	data = itch.AppendAddOrder(data, itch.AddOrderMessage{StockLocate: 1, OrderReferenceNumber: 1, BuySellIndicator: 'S', Shares: 10, Stock: stock, Price: 100})
+	data = itch.AppendAddOrder(data, itch.AddOrderMessage{StockLocate: 1, OrderReferenceNumber: 2, BuySellIndicator: 'B', Shares: 4, Stock: stock, Price: 101})
+	data = itch.AppendOrderExecuted(data, itch.OrderExecutedMessage{StockLocate: 1, OrderReferenceNumber: 1, ExecutedShares: 6, MatchNumber: 1})
+	data = itch.AppendOrderExecuted(data, itch.OrderExecutedMessage{StockLocate: 1, OrderReferenceNumber: 1, ExecutedShares: 4, MatchNumber: 2})
+	if _, _, err := itch.NewParser(b).ParseAll(data); err != nil {
+		t.Fatalf("Expected the executions the engine rejects to be ignored, got %v", err)
+	}
+
+	r := b.Reconcile()
+	if r.Matched != 0 || len(r.Mismatched) != 2 {
+		t.Fatalf("Expected 2 mismatched executions, got %+v", r)
+	}
+	if m := r.Mismatched[0]; m.Feed.Quantity != 6 || m.Engine.Quantity != 4 {
+		t.Errorf("Expected the feed execution of 6 paired with the engine match of 4, got %+v", m)
+	}
+	if len(r.Extra) != 1 || r.Extra[0].OrderID != 2 || len(r.Missing) != 0 {
+		t.Errorf("Expected the engine execution of the bid as extra, got %+v", r)
+	}
+}
+
+func TestBlotter_Export(t *testing.T) {
+	b := NewBlotter(matching.NewMarketManager())
+	if _, _, err := itch.NewParser(b).ParseAll(blotterFeed()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := b.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 8 || lines[1] != "itch,execution,12,AAPL,1,BUY,1000,40,1,true" || lines[2] != "engine,execution,12,AAPL,1,BUY,1000,40,0,true" {
+		t.Errorf("Expected a header and 7 executions, got %q", lines)
+	}
+
+	buf.Reset()
+	if err := b.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	var decoded struct {
+		Executions     []Execution
+		Reconciliation Reconciliation
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(decoded.Executions) != 7 || decoded.Reconciliation.Matched != 2 || decoded.Executions[6].Kind != KindCross {
+		t.Errorf("Expected the executions and reconciliation back from JSON, got %+v", decoded)
+	}
+}
@@ -0,0 +1,156 @@
+// Package bridge feeds ITCH 5.0 order messages into a matching engine, so that
+// an exchange's book can be rebuilt with the matching MarketManager and its
+// handlers (market data, persistence, statistics) reused on recorded feeds.
+package bridge
+
+import (
+	"strings"
+
+	"github.com/tienpsm/go-trader/itch"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// Bridge is an ITCH handler that replays order messages into a MarketManager.
+//
+// The stock locate code is used as the symbol ID and the order reference
+// number as the order ID. Symbols and order books are created from Stock
+// Directory messages, or from the first order of a stock not in the directory.
+// Executions are taken from the feed, so the MarketManager should have
+// automatic matching disabled.
+//
+// Orders added with MPID attribution carry it as their ParticipantID.
+type Bridge struct {
+	itch.DefaultHandler
+
+	mm        *matching.MarketManager
+	directory *itch.SymbolDirectory
+}
+
+// New creates a bridge replaying into mm
+func New(mm *matching.MarketManager) *Bridge {
+	return &Bridge{
+		mm:        mm,
+		directory: itch.NewSymbolDirectory(),
+	}
+}
+
+// MarketManager returns the market manager the bridge replays into
+func (b *Bridge) MarketManager() *matching.MarketManager {
+	return b.mm
+}
+
+// Directory returns the symbol directory of the bridge
+func (b *Bridge) Directory() *itch.SymbolDirectory {
+	return b.directory
+}
+
+// SetDirectory shares a symbol directory with other handlers, such as an
+// itch.BookBuilder mirroring the same feed. It must be set before the first
+// message.
+func (b *Bridge) SetDirectory(d *itch.SymbolDirectory) {
+	b.directory = d
+}
+
+// ParticipantID converts an ITCH attribution field into a participant ID.
+// The four MPID characters are packed big-endian, so IDs sort like MPIDs.
+func ParticipantID(mpid [4]byte) uint32 {
+	return uint32(mpid[0])<<24 | uint32(mpid[1])<<16 | uint32(mpid[2])<<8 | uint32(mpid[3])
+}
+
+// MPID converts a participant ID back into a trimmed MPID, or "" for 0
+func MPID(id uint32) string {
+	if id == 0 {
+		return ""
+	}
+	mpid := []byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}
+	return strings.TrimRight(string(mpid), " \x00")
+}
+
+// symbol creates the symbol and order book of a locate code if needed. The
+// name is taken from the directory, or from stock for locate codes not in it.
+func (b *Bridge) symbol(locate uint16, stock itch.StockSymbol) error {
+	if b.mm.GetOrderBook(uint32(locate)) != nil {
+		return nil
+	}
+	name := b.directory.Stock(locate)
+	if name == "" {
+		name = b.directory.Name(stock)
+	}
+	symbol := matching.NewSymbol(uint32(locate), name)
+	if code := b.mm.AddSymbol(symbol); code != matching.ErrorOK && code != matching.ErrorSymbolDuplicate {
+		return code.Error()
+	}
+	if code := b.mm.AddOrderBook(symbol); code != matching.ErrorOK && code != matching.ErrorOrderBookDuplicate {
+		return code.Error()
+	}
+	return nil
+}
+
+// check converts a result code into an error. Orders unknown to the engine,
+// such as orders added before the feed was joined, are ignored.
+func check(code matching.ErrorCode) error {
+	if code == matching.ErrorOK || code == matching.ErrorOrderNotFound {
+		return nil
+	}
+	return code.Error()
+}
+
+// side converts an ITCH buy/sell indicator into an order side
+func side(indicator byte) matching.OrderSide {
+	if indicator == 'S' {
+		return matching.OrderSideSell
+	}
+	return matching.OrderSideBuy
+}
+
+// add adds a resting limit order
+func (b *Bridge) add(ref uint64, locate uint16, stock itch.StockSymbol, indicator byte, shares, price uint32, participant uint32) error {
+	if err := b.symbol(locate, stock); err != nil {
+		return err
+	}
+	order := matching.NewLimitOrder(ref, uint32(locate), side(indicator), uint64(price), uint64(shares))
+	order.ParticipantID = participant
+	return check(b.mm.AddOrder(*order))
+}
+
+// OnStockDirectory registers the stock in the directory and creates its
+// symbol and order book
+func (b *Bridge) OnStockDirectory(msg itch.StockDirectoryMessage) error {
+	b.directory.Add(msg)
+	return b.symbol(msg.StockLocate, msg.Stock)
+}
+
+// OnAddOrder adds an anonymous order
+func (b *Bridge) OnAddOrder(msg itch.AddOrderMessage) error {
+	return b.add(msg.OrderReferenceNumber, msg.StockLocate, msg.Stock, msg.BuySellIndicator, msg.Shares, msg.Price, 0)
+}
+
+// OnAddOrderMPID adds an order attributed to its MPID
+func (b *Bridge) OnAddOrderMPID(msg itch.AddOrderMPIDMessage) error {
+	return b.add(msg.OrderReferenceNumber, msg.StockLocate, msg.Stock, msg.BuySellIndicator, msg.Shares, msg.Price, ParticipantID(msg.Attribution))
+}
+
+// OnOrderExecuted executes the order at its price
+func (b *Bridge) OnOrderExecuted(msg itch.OrderExecutedMessage) error {
+	return check(b.mm.ExecuteOrder(msg.OrderReferenceNumber, uint64(msg.ExecutedShares)))
+}
+
+// OnOrderExecutedWithPrice executes the order at the execution price
+func (b *Bridge) OnOrderExecutedWithPrice(msg itch.OrderExecutedWithPriceMessage) error {
+	return check(b.mm.ExecuteOrderWithPrice(msg.OrderReferenceNumber, uint64(msg.ExecutionPrice), uint64(msg.ExecutedShares)))
+}
+
+// OnOrderCancel reduces the order by the canceled shares
+func (b *Bridge) OnOrderCancel(msg itch.OrderCancelMessage) error {
+	return check(b.mm.ReduceOrder(msg.OrderReferenceNumber, uint64(msg.CanceledShares)))
+}
+
+// OnOrderDelete deletes the order
+func (b *Bridge) OnOrderDelete(msg itch.OrderDeleteMessage) error {
+	return check(b.mm.DeleteOrder(msg.OrderReferenceNumber))
+}
+
+// OnOrderReplace replaces the order, keeping its side and participant
+func (b *Bridge) OnOrderReplace(msg itch.OrderReplaceMessage) error {
+	return check(b.mm.ReplaceOrder(msg.OriginalOrderReferenceNumber, msg.NewOrderReferenceNumber, uint64(msg.Price), uint64(msg.Shares)))
+}
@@ -0,0 +1,98 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/tienpsm/go-trader/itch"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+func TestBridge_ReplaysFeed(t *testing.T) {
+	mm := matching.NewMarketManager()
+	b := New(mm)
+
+	stock := itch.StockField("AAPL")
+	var data []byte
+	data = itch.AppendStockDirectory(data, itch.StockDirectoryMessage{StockLocate: 7, Stock: stock})
+	data = itch.AppendAddOrder(data, itch.AddOrderMessage{StockLocate: 7, OrderReferenceNumber: 1, BuySellIndicator: 'B', Shares: 100, Stock: stock, Price: 1000000})
+	data = itch.AppendAddOrderMPID(data, itch.AddOrderMPIDMessage{StockLocate: 7, OrderReferenceNumber: 2, BuySellIndicator: 'S', Shares: 300, Stock: stock, Price: 1010000, Attribution: itch.MPIDField("GSCO")})
+	data = itch.AppendOrderExecuted(data, itch.OrderExecutedMessage{StockLocate: 7, OrderReferenceNumber: 1, ExecutedShares: 40, MatchNumber: 1})
+	data = itch.AppendOrderCancel(data, itch.OrderCancelMessage{StockLocate: 7, OrderReferenceNumber: 2, CanceledShares: 100})
+	data = itch.AppendOrderReplace(data, itch.OrderReplaceMessage{StockLocate: 7, OriginalOrderReferenceNumber: 2, NewOrderReferenceNumber: 3, Shares: 150, Price: 1005000})
+	data = itch.AppendOrderDelete(data, itch.OrderDeleteMessage{StockLocate: 7, OrderReferenceNumber: 99})
+
+	if _, n, err := itch.NewParser(b).ParseAll(data); err != nil || n != 7 {
+		t.Fatalf("Expected 7 messages without error, got %d, %v", n, err)
+	}
+
+	if mm.GetOrderBook(7) == nil {
+		t.Fatal("Expected order book for locate 7")
+	}
+
+	bid := mm.GetOrder(1)
+	if bid == nil || bid.LeavesQuantity != 60 || bid.ParticipantID != 0 {
+		t.Errorf("Expected anonymous bid with 60 leaves, got %+v", bid)
+	}
+
+	if mm.GetOrder(2) != nil {
+		t.Error("Expected replaced order 2 to be removed")
+	}
+	ask := mm.GetOrder(3)
+	if ask == nil {
+		t.Fatal("Expected replacement order 3")
+	}
+	if ask.Price != 1005000 || ask.LeavesQuantity != 150 || !ask.IsSell() {
+		t.Errorf("Expected sell 150 @ 1005000, got %+v", ask.Order)
+	}
+	if MPID(ask.ParticipantID) != "GSCO" {
+		t.Errorf("Expected participant GSCO, got %q", MPID(ask.ParticipantID))
+	}
+}
+
+func TestBridge_CreatesSymbolOnFirstOrder(t *testing.T) {
+	mm := matching.NewMarketManager()
+	b := New(mm)
+
+	err := b.OnAddOrder(itch.AddOrderMessage{StockLocate: 3, OrderReferenceNumber: 1, BuySellIndicator: 'S', Shares: 10, Stock: itch.StockField("MSFT"), Price: 500})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if symbol := mm.GetSymbol(3); symbol == nil || symbol.Name != "MSFT" {
+		t.Errorf("Expected MSFT for locate 3, got %v", symbol)
+	}
+	if err := b.OnAddOrder(itch.AddOrderMessage{StockLocate: 3, OrderReferenceNumber: 1, BuySellIndicator: 'S', Shares: 10, Price: 500}); err == nil {
+		t.Error("Expected duplicate order error")
+	}
+}
+
+func TestBridge_SharedDirectory(t *testing.T) {
+	mm := matching.NewMarketManager()
+	b := New(mm)
+	book := itch.NewBookBuilder()
+	book.SetDirectory(b.Directory())
+
+	// The book builder sees the directory message, the bridge only the order
+	book.OnStockDirectory(itch.StockDirectoryMessage{StockLocate: 5, Stock: itch.StockField("SPY"), RoundLotSize: 100, ETPFlag: 'Y'})
+	if err := b.OnAddOrder(itch.AddOrderMessage{StockLocate: 5, OrderReferenceNumber: 1, BuySellIndicator: 'B', Shares: 10, Price: 500}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if symbol := mm.GetSymbol(5); symbol == nil || symbol.Name != "SPY" {
+		t.Errorf("Expected SPY for locate 5, got %v", symbol)
+	}
+	if info, ok := b.Directory().BySymbol("SPY"); !ok || !info.IsETP() || info.RoundLotSize != 100 {
+		t.Errorf("Expected SPY as an ETP, got %+v", info)
+	}
+}
+
+func TestParticipantID(t *testing.T) {
+	id := ParticipantID(itch.MPIDField("MS"))
+	if MPID(id) != "MS" {
+		t.Errorf("Expected MS, got %q", MPID(id))
+	}
+	if ParticipantID(itch.MPIDField("ABCD")) <= ParticipantID(itch.MPIDField("ABCC")) {
+		t.Error("Expected participant IDs to sort like MPIDs")
+	}
+	if MPID(0) != "" {
+		t.Errorf("Expected empty MPID for 0, got %q", MPID(0))
+	}
+}
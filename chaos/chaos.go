@@ -0,0 +1,207 @@
+// Package chaos injects latency and failures into the storage and network
+// layers, so that recovery and gap-fill behaviour can be tested under
+// controlled failure modes.
+//
+// Writer wraps the output of a Journal or any io.Writer with latency, failed
+// and partial writes; Storage does the same for a snapshot storage tier.
+// PacketReader wraps the datagram source of a feed receiver with latency,
+// dropped, duplicated and reordered packets. Faults are drawn from a
+// generator seeded by Faults.Seed, so a failing run can be reproduced.
+package chaos
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/tienpsm/go-trader/persistence"
+)
+
+// ErrInjected is the error of injected failures
+var ErrInjected = errors.New("chaos: injected failure")
+
+// Faults configures the faults of a wrapper. Rates are probabilities from 0
+// to 1 of an operation being affected; rates that do not apply to a wrapper
+// are ignored.
+type Faults struct {
+	// Latency is added to every operation, plus a random Jitter up to Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+	// FailRate fails writes and storage operations with ErrInjected
+	FailRate float64
+	// PartialRate writes a random prefix of the data and fails the write
+	PartialRate float64
+	// DropRate drops packets
+	DropRate float64
+	// DuplicateRate delivers packets twice
+	DuplicateRate float64
+	// ReorderRate holds packets back and delivers them after the next one
+	ReorderRate float64
+	// Seed seeds the fault generator
+	Seed uint64
+}
+
+// Stats counts the operations of a wrapper and the faults injected
+type Stats struct {
+	Operations uint64
+	Failed     uint64
+	Partial    uint64
+	Dropped    uint64
+	Duplicated uint64
+	Reordered  uint64
+}
+
+// injector draws the faults of a wrapper
+type injector struct {
+	mu     sync.Mutex
+	faults Faults
+	rng    *rand.Rand
+	stats  Stats
+}
+
+func newInjector(f Faults) *injector {
+	return &injector{faults: f, rng: rand.New(rand.NewPCG(f.Seed, f.Seed))}
+}
+
+// chance returns true with probability rate. It must be called with i.mu
+// held.
+func (i *injector) chance(rate float64) bool {
+	return rate > 0 && i.rng.Float64() < rate
+}
+
+// delay returns the latency of an operation and counts it
+func (i *injector) delay() time.Duration {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.stats.Operations++
+	d := i.faults.Latency
+	if i.faults.Jitter > 0 {
+		d += time.Duration(i.rng.Int64N(int64(i.faults.Jitter)))
+	}
+	return d
+}
+
+// sleep waits for the latency of an operation
+func (i *injector) sleep() {
+	if d := i.delay(); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// fail returns true if the operation fails
+func (i *injector) fail() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.chance(i.faults.FailRate) {
+		i.stats.Failed++
+		return true
+	}
+	return false
+}
+
+// partial returns the length of the prefix of n bytes to write, n if the
+// write is complete
+func (i *injector) partial(n int) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if n == 0 || !i.chance(i.faults.PartialRate) {
+		return n
+	}
+	i.stats.Partial++
+	return i.rng.IntN(n)
+}
+
+// Stats returns the operations and the faults injected so far
+func (i *injector) Stats() Stats {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.stats
+}
+
+// Writer is an io.Writer injecting latency, failed and partial writes
+type Writer struct {
+	*injector
+	w io.Writer
+}
+
+// NewWriter wraps w with faults
+func NewWriter(w io.Writer, f Faults) *Writer {
+	return &Writer{injector: newInjector(f), w: w}
+}
+
+// Write writes p to the underlying writer. A failed write writes nothing
+// and a partial one a prefix of p, both returning ErrInjected.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.sleep()
+	if w.fail() {
+		return 0, ErrInjected
+	}
+	if n := w.partial(len(p)); n < len(p) {
+		n, err := w.w.Write(p[:n])
+		if err == nil {
+			err = ErrInjected
+		}
+		return n, err
+	}
+	return w.w.Write(p)
+}
+
+// Journal injects faults into the writes of j, simulating a slow or failing
+// disk, and returns the writer for its statistics. Torn records left by
+// partial writes are what a crash during a write leaves behind.
+func Journal(j *persistence.Journal, f Faults) (*Writer, error) {
+	var w *Writer
+	err := j.WrapWriter(func(file io.Writer) io.Writer {
+		if w == nil {
+			w = NewWriter(file, f)
+		} else {
+			w.w = file
+		}
+		return w
+	})
+	return w, err
+}
+
+// Storage is a snapshot storage tier injecting latency and failures. A
+// partial Put stores a prefix of the object and fails, as an interrupted
+// upload would.
+type Storage struct {
+	*injector
+	s persistence.Storage
+}
+
+// NewStorage wraps s with faults
+func NewStorage(s persistence.Storage, f Faults) *Storage {
+	return &Storage{injector: newInjector(f), s: s}
+}
+
+// Put implements persistence.Storage
+func (s *Storage) Put(name string, r io.Reader) error {
+	s.sleep()
+	if s.fail() {
+		return ErrInjected
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if n := s.partial(len(data)); n < len(data) {
+		if err := s.s.Put(name, bytes.NewReader(data[:n])); err != nil {
+			return err
+		}
+		return ErrInjected
+	}
+	return s.s.Put(name, bytes.NewReader(data))
+}
+
+// Get implements persistence.Storage
+func (s *Storage) Get(name string) (io.ReadCloser, error) {
+	s.sleep()
+	if s.fail() {
+		return nil, ErrInjected
+	}
+	return s.s.Get(name)
+}
@@ -0,0 +1,185 @@
+package chaos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/tienpsm/go-trader/marketdata"
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/persistence"
+	"github.com/tienpsm/go-trader/pipeline"
+	"github.com/tienpsm/go-trader/strategy"
+)
+
+// packetSource returns one packet per Read, like a UDP connection
+type packetSource struct {
+	packets [][]byte
+}
+
+func (r *packetSource) Read(p []byte) (int, error) {
+	if len(r.packets) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.packets[0])
+	r.packets = r.packets[1:]
+	return n, nil
+}
+
+// packetCollector keeps every written packet
+type packetCollector struct {
+	packets [][]byte
+}
+
+func (w *packetCollector) Write(p []byte) (int, error) {
+	w.packets = append(w.packets, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+// readPackets reads every packet of r
+func readPackets(t *testing.T, r io.Reader) []string {
+	t.Helper()
+	var packets []string
+	buf := make([]byte, 16)
+	for {
+		n, err := r.Read(buf)
+		if err == io.EOF {
+			return packets
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		packets = append(packets, string(buf[:n]))
+	}
+}
+
+func TestPacketReader(t *testing.T) {
+	source := func() io.Reader {
+		return &packetSource{packets: [][]byte{[]byte("1"), []byte("2"), []byte("3"), []byte("4"), []byte("5")}}
+	}
+	tests := []struct {
+		faults Faults
+		want   string
+	}{
+		{Faults{}, "12345"},
+		{Faults{ReorderRate: 1}, "21435"},
+		{Faults{DuplicateRate: 1}, "1122334455"},
+		{Faults{DropRate: 1}, ""},
+	}
+	for _, tt := range tests {
+		r := NewPacketReader(source(), tt.faults)
+		var got string
+		for _, p := range readPackets(t, r) {
+			got += p
+		}
+		if got != tt.want {
+			t.Errorf("%+v: Expected packets %q, got %q", tt.faults, tt.want, got)
+		}
+	}
+
+	r := NewPacketReader(source(), Faults{DropRate: 0.5, Seed: 3})
+	delivered := len(readPackets(t, r))
+	if stats := r.Stats(); stats.Dropped == 0 || int(stats.Dropped)+delivered != 5 || stats.Operations != uint64(delivered) {
+		t.Errorf("Expected dropped and delivered packets to add up to 5, got %+v and %d delivered", stats, delivered)
+	}
+}
+
+func TestPacketReader_Gaps(t *testing.T) {
+	w := &packetCollector{}
+	pub := marketdata.NewPublisher(w, "TEST")
+	pub.SetMaxPacketSize(100)
+	manager := matching.NewMarketManagerWithHandler(pub)
+	symbol := matching.NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+	for i := uint64(0); i < 50; i++ {
+		manager.AddOrder(*matching.NewLimitOrder(i+1, 1, matching.OrderSideBuy, 10000-i, 100))
+	}
+	if err := pub.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	var gaps uint64
+	p := pipeline.New(&strategy.DefaultStrategy{}, pipeline.Config{OnGap: func(first, last uint64) { gaps += last - first + 1 }})
+	r := NewPacketReader(&packetSource{packets: w.packets}, Faults{DropRate: 0.2, ReorderRate: 0.2, Seed: 1})
+	if err := p.Run(context.Background(), r); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	stats := p.Stats()
+	if faults := r.Stats(); faults.Dropped == 0 || faults.Reordered == 0 {
+		t.Fatalf("Expected dropped and reordered packets, got %+v", faults)
+	}
+	if stats.Gaps == 0 || stats.Gaps != gaps {
+		t.Errorf("Expected the gaps to be reported, got %d and %d reported", stats.Gaps, gaps)
+	}
+	if stats.Duplicates == 0 {
+		t.Error("Expected late packets to be skipped as duplicates")
+	}
+}
+
+func TestJournal_PartialWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "engine.journal")
+	j, err := persistence.OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	order := *matching.NewLimitOrder(1, 1, matching.OrderSideBuy, 10000, 100)
+	for i := 0; i < 3; i++ {
+		order.ID = uint64(i + 1)
+		if err := j.Append(persistence.MatchingEvent{Type: persistence.EventNewOrder, Order: order}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	w, err := Journal(j, Faults{PartialRate: 1, Seed: 1})
+	if err != nil {
+		t.Fatalf("Journal: %v", err)
+	}
+	order.ID = 4
+	j.Append(persistence.MatchingEvent{Type: persistence.EventNewOrder, Order: order})
+	if err := j.Flush(); !errors.Is(err, ErrInjected) || w.Stats().Partial != 1 {
+		t.Fatalf("Expected a partial write, got %v and %+v", err, w.Stats())
+	}
+	j.Close()
+
+	// The torn record is dropped on recovery
+	events, err := persistence.ReadAll(path)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if len(events) != 3 || events[2].Order.ID != 3 {
+		t.Errorf("Expected the 3 complete events, got %d", len(events))
+	}
+}
+
+func TestStorage(t *testing.T) {
+	dir, err := persistence.NewDirStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("snapshot"), 100)
+
+	failing := NewStorage(dir, Faults{FailRate: 1})
+	if err := failing.Put("a.snap", bytes.NewReader(data)); err != ErrInjected {
+		t.Errorf("Expected ErrInjected, got %v", err)
+	}
+	if _, err := failing.Get("a.snap"); err != ErrInjected {
+		t.Errorf("Expected ErrInjected, got %v", err)
+	}
+
+	partial := NewStorage(dir, Faults{PartialRate: 1, Seed: 2})
+	if err := partial.Put("b.snap", bytes.NewReader(data)); err != ErrInjected {
+		t.Errorf("Expected ErrInjected, got %v", err)
+	}
+	r, err := partial.Get("b.snap")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer r.Close()
+	if stored, _ := io.ReadAll(r); len(stored) >= len(data) || !bytes.HasPrefix(data, stored) {
+		t.Errorf("Expected a prefix of the object to be stored, got %d bytes", len(stored))
+	}
+}
@@ -0,0 +1,88 @@
+package chaos
+
+import (
+	"io"
+	"time"
+)
+
+// PacketReader is a datagram source, such as a UDP connection, injecting
+// latency, dropped, duplicated and reordered packets. Each Read returns one
+// packet, as the pipeline receiver expects. Faults apply to every packet,
+// so DropRate should be 0 when the end-of-session packet must arrive.
+type PacketReader struct {
+	*injector
+	r io.Reader
+	// pending are packets to deliver before reading new ones
+	pending [][]byte
+	// held is a packet held back until the next one is delivered
+	held []byte
+}
+
+// NewPacketReader wraps r with faults
+func NewPacketReader(r io.Reader, f Faults) *PacketReader {
+	return &PacketReader{injector: newInjector(f), r: r}
+}
+
+// Read returns the next packet
+func (p *PacketReader) Read(b []byte) (int, error) {
+	for len(p.pending) == 0 {
+		if err := p.receive(len(b)); err != nil {
+			return 0, err
+		}
+	}
+	p.sleep()
+	packet := p.pending[0]
+	p.pending = p.pending[1:]
+	return copy(b, packet), nil
+}
+
+// receive reads a packet and queues it for delivery with its faults. At the
+// end of r, a held packet is delivered before the error.
+func (p *PacketReader) receive(size int) error {
+	buf := make([]byte, size)
+	n, err := p.r.Read(buf)
+	if err != nil {
+		if p.held != nil {
+			p.pending, p.held = append(p.pending, p.held), nil
+			return nil
+		}
+		return err
+	}
+	packet := buf[:n]
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.chance(p.faults.DropRate) {
+		p.stats.Dropped++
+		return nil
+	}
+	copies := 1
+	if p.chance(p.faults.DuplicateRate) {
+		p.stats.Duplicated++
+		copies = 2
+	}
+	if p.held == nil && p.chance(p.faults.ReorderRate) {
+		p.stats.Reordered++
+		p.held = packet
+		if copies == 2 {
+			p.pending = append(p.pending, packet)
+		}
+		return nil
+	}
+	for range copies {
+		p.pending = append(p.pending, packet)
+	}
+	if p.held != nil {
+		p.pending, p.held = append(p.pending, p.held), nil
+	}
+	return nil
+}
+
+// SetReadDeadline sets the read deadline of the underlying reader, if it has
+// one, so that a pipeline can unblock it
+func (p *PacketReader) SetReadDeadline(t time.Time) error {
+	if d, ok := p.r.(interface{ SetReadDeadline(time.Time) error }); ok {
+		return d.SetReadDeadline(t)
+	}
+	return nil
+}
@@ -0,0 +1,469 @@
+// Package client is a Go client of the gateway order entry server.
+//
+// A Client keeps a WebSocket session to the server for a participant. It
+// reconnects with exponential backoff, resyncs the execution reports it missed
+// using the per-participant report sequence, and resends the requests that
+// were not answered before the connection dropped. Retries are idempotent:
+// the server answers a request repeating an order it has already accepted
+// with the original report, and a request answered while the client was away
+// is resolved by the replayed report instead of being sent again.
+//
+// SubscribeTrades and SubscribeDepth stream public market data, resuming from
+// the last market data sequence after a reconnection.
+package client
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/tienpsm/go-trader/gateway"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// Errors returned by the client
+var (
+	// ErrClosed is returned for requests on a closed client
+	ErrClosed = errors.New("client: closed")
+	// ErrInFlight is returned for a request that is already waiting for an
+	// answer, such as a second cancel of the same order
+	ErrInFlight = errors.New("client: request already in flight")
+)
+
+// RejectError is returned for a request rejected by the server
+type RejectError struct {
+	Report gateway.Report
+}
+
+// Error returns the rejection reason
+func (e *RejectError) Error() string {
+	return "client: rejected: " + e.Report.Reason
+}
+
+// Default reconnection backoff
+const (
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 10 * time.Second
+)
+
+// writeTimeout bounds the time to write a request
+const writeTimeout = 10 * time.Second
+
+// Config configures a Client
+type Config struct {
+	// URL is the base URL of the server, for example http://localhost:8080
+	URL string
+	// Participant is the participant ID of the session
+	Participant uint32
+	// LastSequence is the last report sequence processed in a previous
+	// session; reports after it are replayed on connection
+	LastSequence uint64
+	// OnReport is called with every execution report, once and in sequence
+	// order, from the client's connection goroutine
+	OnReport func(gateway.Report)
+	// MarketDataRate conflates market data subscriptions for a slow
+	// consumer: level changes are coalesced by the server and sent at most
+	// MarketDataRate times per second. Zero delivers every event.
+	MarketDataRate int
+	// HeartbeatInterval is the time between heartbeats sent on the order
+	// entry connection, to keep it open under a server session timeout. Zero
+	// sends none.
+	HeartbeatInterval time.Duration
+
+	// MinBackoff and MaxBackoff bound the delay between reconnection
+	// attempts; zero selects the defaults
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Dialer is the WebSocket dialer, websocket.DefaultDialer if nil
+	Dialer *websocket.Dialer
+}
+
+// Order is a new order
+type Order struct {
+	// ClientOrderID identifies the order for the participant; it must not
+	// have been used before
+	ClientOrderID string
+	Symbol        string
+	Side          matching.OrderSide
+	// Market selects a market order instead of a limit order
+	Market      bool
+	TimeInForce matching.OrderTimeInForce
+	Price       uint64
+	Quantity    uint64
+}
+
+// pending is a request waiting for its answer
+type pending struct {
+	req  gateway.Request
+	done chan gateway.Report
+}
+
+// Client is an order entry session of a participant
+type Client struct {
+	cfg  Config
+	base *url.URL
+
+	mu sync.Mutex
+	// ws is the connection once it has resynced, nil while disconnected
+	ws *websocket.Conn
+	// last is the sequence of the last report passed to OnReport
+	last uint64
+	// pending maps request keys to requests waiting for an answer
+	pending map[string]*pending
+	closed  bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a client and starts connecting to the server in the background.
+// Requests made before the connection is established are sent once it is.
+func New(cfg Config) (*Client, error) {
+	base, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, errors.New("client: URL scheme must be http or https")
+	}
+	if cfg.Participant == 0 {
+		return nil, errors.New("client: missing participant")
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = max(DefaultMaxBackoff, cfg.MinBackoff)
+	}
+	if cfg.Dialer == nil {
+		cfg.Dialer = websocket.DefaultDialer
+	}
+
+	c := &Client{
+		cfg:     cfg,
+		base:    base,
+		last:    cfg.LastSequence,
+		pending: make(map[string]*pending),
+		done:    make(chan struct{}),
+	}
+	c.wg.Add(1)
+	go c.run()
+	return c, nil
+}
+
+// LastSequence returns the sequence of the last report received, to be passed
+// as Config.LastSequence by the next session
+func (c *Client) LastSequence() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// Connected returns true while the session is connected and resynced
+func (c *Client) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ws != nil
+}
+
+// SubmitOrder enters a new order and returns its accepted report. A rejected
+// order returns a *RejectError.
+func (c *Client) SubmitOrder(ctx context.Context, order Order) (gateway.Report, error) {
+	if order.ClientOrderID == "" {
+		return gateway.Report{}, errors.New("client: missing client order ID")
+	}
+	req := gateway.Request{
+		Type:          gateway.RequestSubmit,
+		ClientOrderID: order.ClientOrderID,
+		Symbol:        order.Symbol,
+		Side:          strings.ToLower(order.Side.String()),
+		OrderType:     "limit",
+		TimeInForce:   strings.ToLower(order.TimeInForce.String()),
+		Price:         order.Price,
+		Quantity:      order.Quantity,
+	}
+	if order.Market {
+		req.OrderType = "market"
+	}
+	return c.do(ctx, orderKey(order.ClientOrderID), req)
+}
+
+// CancelOrder cancels an open order and returns its canceled report. An order
+// that is unknown or no longer open returns a *RejectError.
+func (c *Client) CancelOrder(ctx context.Context, clientOrderID string) (gateway.Report, error) {
+	if clientOrderID == "" {
+		return gateway.Report{}, errors.New("client: missing client order ID")
+	}
+	req := gateway.Request{Type: gateway.RequestCancel, OrigClientOrderID: clientOrderID}
+	return c.do(ctx, cancelKey(clientOrderID), req)
+}
+
+// ModifyOrder replaces an open order by a new order with another client order
+// ID, price and quantity, and returns the replaced report
+func (c *Client) ModifyOrder(ctx context.Context, origClientOrderID, clientOrderID string, price, quantity uint64) (gateway.Report, error) {
+	if clientOrderID == "" || origClientOrderID == "" {
+		return gateway.Report{}, errors.New("client: missing client order ID")
+	}
+	req := gateway.Request{
+		Type:              gateway.RequestModify,
+		ClientOrderID:     clientOrderID,
+		OrigClientOrderID: origClientOrderID,
+		Price:             price,
+		Quantity:          quantity,
+	}
+	return c.do(ctx, orderKey(clientOrderID), req)
+}
+
+// orderKey is the pending key of a submit or modify request
+func orderKey(clientOrderID string) string { return "order:" + clientOrderID }
+
+// cancelKey is the pending key of a cancel request
+func cancelKey(clientOrderID string) string { return "cancel:" + clientOrderID }
+
+// answerKey returns the pending key a report answers, if any
+func answerKey(r gateway.Report) (string, bool) {
+	switch r.Type {
+	case gateway.ReportAccepted, gateway.ReportReplaced:
+		return orderKey(r.ClientOrderID), true
+	case gateway.ReportCanceled:
+		return cancelKey(r.ClientOrderID), true
+	case gateway.ReportRejected:
+		// Only rejected cancels have no client order ID
+		if r.ClientOrderID != "" {
+			return orderKey(r.ClientOrderID), true
+		}
+		return cancelKey(r.OrigClientOrderID), true
+	default:
+		return "", false
+	}
+}
+
+// do sends a request, or queues it until the session is connected, and waits
+// for its answer. If ctx ends first, the request may still take effect.
+func (c *Client) do(ctx context.Context, key string, req gateway.Request) (gateway.Report, error) {
+	p := &pending{req: req, done: make(chan gateway.Report, 1)}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return gateway.Report{}, ErrClosed
+	}
+	if _, exists := c.pending[key]; exists {
+		c.mu.Unlock()
+		return gateway.Report{}, ErrInFlight
+	}
+	c.pending[key] = p
+	if c.ws != nil {
+		c.write(req)
+	}
+	c.mu.Unlock()
+
+	select {
+	case r := <-p.done:
+		if r.Type == gateway.ReportRejected {
+			return r, &RejectError{Report: r}
+		}
+		return r, nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		if c.pending[key] == p {
+			delete(c.pending, key)
+		}
+		c.mu.Unlock()
+		return gateway.Report{}, ctx.Err()
+	case <-c.done:
+		return gateway.Report{}, ErrClosed
+	}
+}
+
+// write sends a request on the connection. It is called with the client lock
+// held. A failed write closes the connection, and the request is resent after
+// the reconnection.
+func (c *Client) write(req gateway.Request) {
+	_ = c.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := c.ws.WriteJSON(req); err != nil {
+		_ = c.ws.Close()
+	}
+}
+
+// run keeps the session connected until the client is closed
+func (c *Client) run() {
+	defer c.wg.Done()
+	b := newBackoff(c.cfg.MinBackoff, c.cfg.MaxBackoff)
+	for {
+		if c.session() {
+			b.reset()
+		}
+		if !b.wait(c.done) {
+			return
+		}
+	}
+}
+
+// session connects, resyncs and handles reports until the connection drops.
+// It returns true if the connection was established.
+func (c *Client) session() bool {
+	u := wsURL(c.base, "/orders")
+	q := url.Values{}
+	q.Set("participant", strconv.FormatUint(uint64(c.cfg.Participant), 10))
+	q.Set("last_sequence", strconv.FormatUint(c.LastSequence(), 10))
+	u.RawQuery = q.Encode()
+
+	ws, _, err := c.cfg.Dialer.Dial(u.String(), nil)
+	if err != nil {
+		return false
+	}
+	stop := closeOnDone(ws, c.done)
+	defer stop()
+	if c.cfg.HeartbeatInterval > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go c.heartbeat(ws, done)
+	}
+	defer func() {
+		c.mu.Lock()
+		if c.ws == ws {
+			c.ws = nil
+		}
+		c.mu.Unlock()
+		_ = ws.Close()
+	}()
+
+	for {
+		var r gateway.Report
+		if err := ws.ReadJSON(&r); err != nil {
+			return true
+		}
+		if r.Type == gateway.ReportResynced {
+			c.resynced(ws)
+			continue
+		}
+		c.receive(r)
+	}
+}
+
+// heartbeat sends heartbeats on ws once it is live, until done is closed
+func (c *Client) heartbeat(ws *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(c.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.mu.Lock()
+			if c.ws == ws {
+				c.write(gateway.Request{Type: gateway.RequestHeartbeat})
+			}
+			c.mu.Unlock()
+		case <-done:
+			return
+		}
+	}
+}
+
+// resynced makes ws the live connection and resends the unanswered requests
+func (c *Client) resynced(ws *websocket.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ws == ws {
+		return
+	}
+	c.ws = ws
+	for _, p := range c.pending {
+		c.write(p.req)
+	}
+}
+
+// receive handles a report: replayed duplicates are dropped, answers resolve
+// their request and logged reports are passed to OnReport
+func (c *Client) receive(r gateway.Report) {
+	c.mu.Lock()
+	if r.Sequence != 0 && r.Sequence <= c.last {
+		c.mu.Unlock()
+		return
+	}
+	if r.Sequence != 0 {
+		c.last = r.Sequence
+	}
+	if key, ok := answerKey(r); ok {
+		if p := c.pending[key]; p != nil {
+			delete(c.pending, key)
+			p.done <- r
+		}
+	}
+	c.mu.Unlock()
+
+	if r.Sequence != 0 && c.cfg.OnReport != nil {
+		c.cfg.OnReport(r)
+	}
+}
+
+// Close ends the session. Requests waiting for an answer return ErrClosed.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	close(c.done)
+	c.mu.Unlock()
+	c.wg.Wait()
+	return nil
+}
+
+// wsURL returns the WebSocket URL of a server path
+func wsURL(base *url.URL, path string) *url.URL {
+	u := *base
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	return &u
+}
+
+// closeOnDone closes ws when done is closed, unblocking its reader. The
+// returned function stops watching.
+func closeOnDone(ws *websocket.Conn, done <-chan struct{}) func() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+			_ = ws.Close()
+		case <-stop:
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// backoff is an exponential reconnection delay
+type backoff struct {
+	min, max, cur time.Duration
+}
+
+func newBackoff(min, max time.Duration) *backoff {
+	return &backoff{min: min, max: max, cur: min}
+}
+
+// reset restarts from the minimum delay
+func (b *backoff) reset() { b.cur = b.min }
+
+// wait sleeps for the current delay and doubles it. It returns false if done
+// is closed first.
+func (b *backoff) wait(done <-chan struct{}) bool {
+	t := time.NewTimer(b.cur)
+	defer t.Stop()
+	b.cur = min(2*b.cur, b.max)
+	select {
+	case <-t.C:
+		return true
+	case <-done:
+		return false
+	}
+}
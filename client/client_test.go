@@ -0,0 +1,378 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tienpsm/go-trader/gateway"
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/persistence"
+)
+
+// testServer is a gateway server over a recovered persistence manager in dir
+type testServer struct {
+	server  *gateway.Server
+	manager *persistence.Manager
+	reports *gateway.ReportLog
+	http    *httptest.Server
+}
+
+// startServer starts a server in dir, listening on addr if it is not empty
+func startServer(t *testing.T, dir, addr string) *testServer {
+	t.Helper()
+	journal := filepath.Join(dir, "engine.journal")
+	snapshots := filepath.Join(dir, "snapshots")
+
+	mm := matching.NewMarketManager()
+	mm.EnableMatching()
+	symbol := matching.NewSymbol(1, "AAPL")
+	mm.AddSymbol(symbol)
+	mm.AddOrderBook(symbol)
+	manager, err := persistence.NewManagerWithRecovery(mm, journal, snapshots)
+	if err != nil {
+		t.Fatalf("NewManagerWithRecovery: %v", err)
+	}
+	marketData, err := persistence.OpenMarketDataLog(filepath.Join(dir, "marketdata.log"))
+	if err != nil {
+		t.Fatalf("OpenMarketDataLog: %v", err)
+	}
+	manager.AttachMarketDataLog(marketData)
+	reports, err := gateway.OpenReportLog(filepath.Join(dir, "reports.log"))
+	if err != nil {
+		t.Fatalf("OpenReportLog: %v", err)
+	}
+	server := gateway.NewServer(manager, reports)
+
+	ts := &testServer{server: server, manager: manager, reports: reports}
+	ts.http = httptest.NewUnstartedServer(server.Routes())
+	if addr != "" {
+		// The previous listener may take a moment to release the port
+		var l net.Listener
+		for i := 0; i < 50; i++ {
+			if l, err = net.Listen("tcp", addr); err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("Listen: %v", err)
+		}
+		ts.http.Listener.Close()
+		ts.http.Listener = l
+	}
+	ts.http.Start()
+	return ts
+}
+
+func (ts *testServer) stop(t *testing.T) {
+	t.Helper()
+	ts.server.Close()
+	ts.http.Close()
+	if err := ts.reports.Close(); err != nil {
+		t.Fatalf("reports.Close: %v", err)
+	}
+	if err := ts.manager.Close(); err != nil {
+		t.Fatalf("manager.Close: %v", err)
+	}
+}
+
+// collector records the reports passed to OnReport
+type collector struct {
+	mu      sync.Mutex
+	reports []gateway.Report
+}
+
+func (c *collector) add(r gateway.Report) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reports = append(c.reports, r)
+}
+
+// wait returns the reports once there are n of them
+func (c *collector) wait(t *testing.T, n int) []gateway.Report {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		reports := append([]gateway.Report(nil), c.reports...)
+		c.mu.Unlock()
+		if len(reports) >= n {
+			return reports
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d reports, got %d", n, len(c.reports))
+	return nil
+}
+
+func newClient(t *testing.T, url string, participant uint32, reports *collector) *Client {
+	t.Helper()
+	c, err := New(Config{URL: url, Participant: participant, OnReport: reports.add, MinBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestClient_OrderEntry(t *testing.T) {
+	ts := startServer(t, t.TempDir(), "")
+	defer ts.stop(t)
+	ctx := testContext(t)
+
+	sellerReports, buyerReports := &collector{}, &collector{}
+	seller := newClient(t, ts.http.URL, 7, sellerReports)
+	buyer := newClient(t, ts.http.URL, 8, buyerReports)
+
+	r, err := seller.SubmitOrder(ctx, Order{ClientOrderID: "s1", Symbol: "AAPL", Side: matching.OrderSideSell, Price: 10000, Quantity: 100})
+	if err != nil {
+		t.Fatalf("SubmitOrder: %v", err)
+	}
+	if r.Type != gateway.ReportAccepted || r.Sequence != 1 || r.LeavesQuantity != 100 {
+		t.Errorf("Expected s1 accepted, got %+v", r)
+	}
+	if _, err := buyer.SubmitOrder(ctx, Order{ClientOrderID: "b1", Symbol: "AAPL", Side: matching.OrderSideBuy, Price: 10000, Quantity: 40}); err != nil {
+		t.Fatalf("SubmitOrder: %v", err)
+	}
+
+	_, err = buyer.SubmitOrder(ctx, Order{ClientOrderID: "b1", Symbol: "AAPL", Side: matching.OrderSideBuy, Price: 9000, Quantity: 10})
+	var reject *RejectError
+	if !errors.As(err, &reject) || reject.Report.Sequence != 3 {
+		t.Errorf("Expected duplicate rejection, got %v", err)
+	}
+	if _, err := buyer.SubmitOrder(ctx, Order{ClientOrderID: "b2", Symbol: "AAPL", Quantity: 10, Side: 9}); !errors.As(err, &reject) || reject.Report.Sequence != 0 {
+		t.Errorf("Expected unlogged rejection of an invalid side, got %v", err)
+	}
+
+	r, err = seller.ModifyOrder(ctx, "s1", "s2", 10100, 50)
+	if err != nil {
+		t.Fatalf("ModifyOrder: %v", err)
+	}
+	if r.Type != gateway.ReportReplaced || r.ClientOrderID != "s2" || r.Price != 10100 {
+		t.Errorf("Expected s1 replaced by s2, got %+v", r)
+	}
+	if _, err := seller.CancelOrder(ctx, "s1"); !errors.As(err, &reject) {
+		t.Errorf("Expected rejection of a replaced order, got %v", err)
+	}
+	r, err = seller.CancelOrder(ctx, "s2")
+	if err != nil {
+		t.Fatalf("CancelOrder: %v", err)
+	}
+	if r.Type != gateway.ReportCanceled || r.LeavesQuantity != 50 {
+		t.Errorf("Expected s2 canceled, got %+v", r)
+	}
+
+	// OnReport sees every logged report once, in sequence order
+	for i, r := range sellerReports.wait(t, 5) {
+		if r.Sequence != uint64(i+1) {
+			t.Errorf("Expected seller report %d, got %+v", i+1, r)
+		}
+	}
+	if got := seller.LastSequence(); got != 5 {
+		t.Errorf("Expected last sequence 5, got %d", got)
+	}
+	if reports := buyerReports.wait(t, 3); reports[1].Type != gateway.ReportFill {
+		t.Errorf("Expected buyer fill, got %+v", reports[1])
+	}
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	dir := t.TempDir()
+	ts := startServer(t, dir, "")
+	addr := ts.http.Listener.Addr().String()
+	ctx := testContext(t)
+
+	reports := &collector{}
+	c := newClient(t, ts.http.URL, 7, reports)
+	if _, err := c.SubmitOrder(ctx, Order{ClientOrderID: "a", Symbol: "AAPL", Side: matching.OrderSideBuy, Price: 10000, Quantity: 10}); err != nil {
+		t.Fatalf("SubmitOrder: %v", err)
+	}
+	ts.stop(t)
+
+	// A request made while the server is down is sent after the reconnection
+	type result struct {
+		r   gateway.Report
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		r, err := c.SubmitOrder(ctx, Order{ClientOrderID: "b", Symbol: "AAPL", Side: matching.OrderSideBuy, Price: 9900, Quantity: 5})
+		done <- result{r, err}
+	}()
+
+	ts = startServer(t, dir, addr)
+	defer ts.stop(t)
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("SubmitOrder: %v", res.err)
+	}
+	if res.r.Type != gateway.ReportAccepted || res.r.Sequence != 2 {
+		t.Errorf("Expected b accepted as report 2, got %+v", res.r)
+	}
+
+	// Reports produced while a client is away are replayed once
+	ts.server.Close()
+	other := newClient(t, ts.http.URL, 8, &collector{})
+	if _, err := other.SubmitOrder(ctx, Order{ClientOrderID: "x", Symbol: "AAPL", Side: matching.OrderSideSell, Price: 10000, Quantity: 4}); err != nil {
+		t.Fatalf("SubmitOrder: %v", err)
+	}
+	got := reports.wait(t, 3)
+	if r := got[2]; r.Type != gateway.ReportFill || r.ClientOrderID != "a" || r.LeavesQuantity != 6 {
+		t.Errorf("Expected replayed fill of a, got %+v", r)
+	}
+	if _, err := c.CancelOrder(ctx, "a"); err != nil {
+		t.Fatalf("CancelOrder: %v", err)
+	}
+	for i, r := range reports.wait(t, 4) {
+		if r.Sequence != uint64(i+1) {
+			t.Errorf("Expected report %d, got %+v", i+1, r)
+		}
+	}
+}
+
+func TestClient_Heartbeat(t *testing.T) {
+	ts := startServer(t, t.TempDir(), "")
+	defer ts.stop(t)
+	ts.server.SetSessionConfig(gateway.SessionConfig{CancelOnDisconnect: true, Timeout: 100 * time.Millisecond})
+	ctx := testContext(t)
+
+	c, err := New(Config{URL: ts.http.URL, Participant: 7, HeartbeatInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+	r, err := c.SubmitOrder(ctx, Order{ClientOrderID: "o1", Symbol: "AAPL", Side: matching.OrderSideBuy, Price: 9000, Quantity: 10})
+	if err != nil {
+		t.Fatalf("SubmitOrder: %v", err)
+	}
+
+	// Heartbeats keep the idle session, and so the order, alive
+	time.Sleep(300 * time.Millisecond)
+	ts.manager.View(func(mm *matching.MarketManager) {
+		if mm.GetOrder(r.OrderID) == nil {
+			t.Error("Expected the order of a heartbeating client to rest")
+		}
+	})
+}
+
+func TestClient_MarketData(t *testing.T) {
+	ts := startServer(t, t.TempDir(), "")
+	defer ts.stop(t)
+	ctx := testContext(t)
+
+	seller := newClient(t, ts.http.URL, 7, &collector{})
+	buyer := newClient(t, ts.http.URL, 8, &collector{})
+	for i, price := range []uint64{10000, 10100} {
+		if _, err := seller.SubmitOrder(ctx, Order{ClientOrderID: string(rune('a' + i)), Symbol: "AAPL", Side: matching.OrderSideSell, Price: price, Quantity: 10}); err != nil {
+			t.Fatalf("SubmitOrder: %v", err)
+		}
+	}
+
+	if _, err := buyer.SubscribeTrades(ctx, "MSFT"); err == nil {
+		t.Errorf("Expected an error for an unknown symbol")
+	}
+	trades, err := buyer.SubscribeTrades(ctx, "AAPL")
+	if err != nil {
+		t.Fatalf("SubscribeTrades: %v", err)
+	}
+	depth, err := buyer.SubscribeDepth(ctx, "AAPL")
+	if err != nil {
+		t.Fatalf("SubscribeDepth: %v", err)
+	}
+	d := <-depth
+	if len(d.Asks) != 2 || d.Asks[0].Price != 10000 || len(d.Bids) != 0 {
+		t.Fatalf("Expected two ask levels in the snapshot, got %+v", d)
+	}
+
+	if _, err := buyer.SubmitOrder(ctx, Order{ClientOrderID: "b", Symbol: "AAPL", Side: matching.OrderSideBuy, Price: 10000, Quantity: 14}); err != nil {
+		t.Fatalf("SubmitOrder: %v", err)
+	}
+	select {
+	case md := <-trades:
+		if md.Price != 10000 || md.Quantity != 10 || md.Aggressor != "buy" {
+			t.Errorf("Expected a trade of 10 at 10000, got %+v", md)
+		}
+	case <-ctx.Done():
+		t.Fatalf("Expected a trade")
+	}
+
+	// The remaining 4 rest as a bid; wait for the depth to settle there
+	for {
+		select {
+		case d = <-depth:
+		case <-ctx.Done():
+			t.Fatalf("Expected the depth to settle, got %+v", d)
+		}
+		if len(d.Bids) == 1 && len(d.Asks) == 1 {
+			break
+		}
+	}
+	if d.Bids[0] != (gateway.DepthLevel{Price: 10000, Volume: 4, Orders: 1}) || d.Asks[0].Price != 10100 {
+		t.Errorf("Expected a bid of 4 at 10000 and an ask at 10100, got %+v", d)
+	}
+	snapshot, err := buyer.Depth(ctx, "AAPL")
+	if err != nil {
+		t.Fatalf("Depth: %v", err)
+	}
+	if snapshot.Sequence < d.Sequence || len(snapshot.Bids) != 1 || snapshot.Bids[0] != d.Bids[0] || len(snapshot.Asks) != 1 || snapshot.Asks[0] != d.Asks[0] {
+		t.Errorf("Expected the snapshot to match the local depth %+v, got %+v", d, snapshot)
+	}
+}
+
+func TestClient_ConflatedMarketData(t *testing.T) {
+	ts := startServer(t, t.TempDir(), "")
+	defer ts.stop(t)
+	ctx := testContext(t)
+
+	seller := newClient(t, ts.http.URL, 7, &collector{})
+	for i := 0; i < 5; i++ {
+		if _, err := seller.SubmitOrder(ctx, Order{ClientOrderID: string(rune('a' + i)), Symbol: "AAPL", Side: matching.OrderSideSell, Price: 10000, Quantity: 10}); err != nil {
+			t.Fatalf("SubmitOrder: %v", err)
+		}
+	}
+	if _, err := seller.SubmitOrder(ctx, Order{ClientOrderID: "f", Symbol: "AAPL", Side: matching.OrderSideBuy, Price: 10000, Quantity: 4}); err != nil {
+		t.Fatalf("SubmitOrder: %v", err)
+	}
+
+	// The replayed changes of the level are coalesced into its latest state
+	c, err := New(Config{URL: ts.http.URL, Participant: 8, MarketDataRate: 1})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+	events, err := c.SubscribeMarketData(ctx, "AAPL", 1)
+	if err != nil {
+		t.Fatalf("SubscribeMarketData: %v", err)
+	}
+	var level, trade gateway.MarketData
+	for i := 0; i < 2; i++ {
+		select {
+		case md := <-events:
+			if md.Type == gateway.MarketDataTrade {
+				trade = md
+			} else {
+				level = md
+			}
+		case <-ctx.Done():
+			t.Fatalf("Expected a level and a trade, got %+v and %+v", level, trade)
+		}
+	}
+	if level.Type != gateway.MarketDataLevelAdd || level.Price != 10000 || level.Volume != 46 || level.Orders != 5 {
+		t.Errorf("Expected a level of 46 in 5 orders at 10000, got %+v", level)
+	}
+	if trade.Quantity != 4 || trade.Aggressor != "buy" {
+		t.Errorf("Expected a trade of 4, got %+v", trade)
+	}
+}
@@ -0,0 +1,315 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+	"github.com/tienpsm/go-trader/gateway"
+)
+
+// subscriptionBuffer is the channel capacity of a subscription
+const subscriptionBuffer = 256
+
+// SubscribeTrades streams the trades of a symbol from the next one on. After
+// a reconnection the stream resumes after the last trade delivered. The
+// channel is closed when ctx ends or the client is closed. The first
+// connection is made before returning, so an unknown symbol returns an error.
+func (c *Client) SubscribeTrades(ctx context.Context, symbol string) (<-chan gateway.MarketData, error) {
+	return c.subscribe(ctx, symbol, 0, func(md gateway.MarketData) bool {
+		return md.Type == gateway.MarketDataTrade
+	})
+}
+
+// SubscribeMarketData streams every market data event of a symbol, trades and
+// level changes, starting at sequence from, or with the next event if from is
+// 0. After a reconnection the stream resumes after the last event delivered,
+// or with the next event if the server no longer has it. The channel is
+// closed when ctx ends or the client is closed.
+func (c *Client) SubscribeMarketData(ctx context.Context, symbol string, from uint64) (<-chan gateway.MarketData, error) {
+	return c.subscribe(ctx, symbol, from, func(gateway.MarketData) bool { return true })
+}
+
+// subscribe streams the market data events of a symbol selected by keep
+func (c *Client) subscribe(ctx context.Context, symbol string, from uint64, keep func(gateway.MarketData) bool) (<-chan gateway.MarketData, error) {
+	ws, err := c.dialMarketData(ctx, symbol, from)
+	if err != nil {
+		return nil, err
+	}
+	if !c.track() {
+		ws.Close()
+		return nil, ErrClosed
+	}
+	ch := make(chan gateway.MarketData, subscriptionBuffer)
+	go func() {
+		defer c.wg.Done()
+		defer close(ch)
+
+		done := mergeDone(ctx, c.done)
+		next := from
+		b := newBackoff(c.cfg.MinBackoff, c.cfg.MaxBackoff)
+		for {
+			if ws != nil {
+				b.reset()
+				err := readMarketData(ws, done, func(md gateway.MarketData) bool {
+					if md.Sequence < next {
+						return true
+					}
+					next = md.Sequence + 1
+					if !keep(md) {
+						return true
+					}
+					select {
+					case ch <- md:
+						return true
+					case <-done:
+						return false
+					}
+				})
+				if isSequenceUnavailable(err) {
+					// The log no longer starts early enough; resume live
+					next = 0
+				}
+			}
+			if !b.wait(done) {
+				return
+			}
+			ws, _ = c.dialMarketData(ctx, symbol, next)
+		}
+	}()
+	return ch, nil
+}
+
+// SubscribeDepth streams the displayed depth of a symbol. It fetches a depth
+// snapshot, applies the level events after it and delivers the updated depth
+// after each of them, best levels first. After a reconnection the stream
+// resumes from the last event applied, or from a new snapshot if the server
+// no longer has it. The channel is closed when ctx ends or the client is
+// closed.
+func (c *Client) SubscribeDepth(ctx context.Context, symbol string) (<-chan gateway.Depth, error) {
+	book, err := c.Depth(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := c.dialMarketData(ctx, symbol, book.Sequence+1)
+	if err != nil {
+		return nil, err
+	}
+	if !c.track() {
+		ws.Close()
+		return nil, ErrClosed
+	}
+	ch := make(chan gateway.Depth, subscriptionBuffer)
+	go func() {
+		defer c.wg.Done()
+		defer close(ch)
+
+		done := mergeDone(ctx, c.done)
+		deliver := func(d gateway.Depth) bool {
+			select {
+			case ch <- d:
+				return true
+			case <-done:
+				return false
+			}
+		}
+		local := newDepthBook(book)
+		if !deliver(local.depth()) {
+			return
+		}
+
+		b := newBackoff(c.cfg.MinBackoff, c.cfg.MaxBackoff)
+		for {
+			if ws != nil {
+				b.reset()
+				err := readMarketData(ws, done, func(md gateway.MarketData) bool {
+					if md.Sequence <= local.sequence {
+						return true
+					}
+					local.sequence = md.Sequence
+					if md.Type == gateway.MarketDataTrade {
+						return true
+					}
+					local.apply(md)
+					return deliver(local.depth())
+				})
+				if isSequenceUnavailable(err) {
+					local = nil
+				}
+			}
+			if !b.wait(done) {
+				return
+			}
+			if local == nil {
+				snapshot, err := c.Depth(ctx, symbol)
+				if err != nil {
+					ws = nil
+					continue
+				}
+				local = newDepthBook(snapshot)
+				if !deliver(local.depth()) {
+					return
+				}
+			}
+			ws, _ = c.dialMarketData(ctx, symbol, local.sequence+1)
+		}
+	}()
+	return ch, nil
+}
+
+// track registers a subscription goroutine, or returns false if the client
+// is closed
+func (c *Client) track() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	c.wg.Add(1)
+	return true
+}
+
+// Depth fetches a depth snapshot of a symbol
+func (c *Client) Depth(ctx context.Context, symbol string) (gateway.Depth, error) {
+	u := *c.base
+	u.Path = u.Path + "/depth"
+	u.RawQuery = url.Values{"symbol": {symbol}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return gateway.Depth{}, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return gateway.Depth{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return gateway.Depth{}, fmt.Errorf("client: depth of %s: %s", symbol, resp.Status)
+	}
+	var depth gateway.Depth
+	if err := json.NewDecoder(resp.Body).Decode(&depth); err != nil {
+		return gateway.Depth{}, err
+	}
+	return depth, nil
+}
+
+// dialMarketData connects to the market data stream of a symbol, from a
+// sequence or live if from is 0
+func (c *Client) dialMarketData(ctx context.Context, symbol string, from uint64) (*websocket.Conn, error) {
+	u := wsURL(c.base, "/marketdata")
+	q := url.Values{"symbol": {symbol}}
+	if from > 0 {
+		q.Set("from", strconv.FormatUint(from, 10))
+	}
+	if c.cfg.MarketDataRate > 0 {
+		q.Set("rate", strconv.Itoa(c.cfg.MarketDataRate))
+	}
+	u.RawQuery = q.Encode()
+	ws, resp, err := c.cfg.Dialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("client: market data of %s: %s", symbol, resp.Status)
+		}
+		return nil, err
+	}
+	return ws, nil
+}
+
+// readMarketData passes the messages of ws to fn until the connection drops,
+// fn returns false or done is closed
+func readMarketData(ws *websocket.Conn, done <-chan struct{}, fn func(gateway.MarketData) bool) error {
+	stop := closeOnDone(ws, done)
+	defer stop()
+	defer ws.Close()
+	for {
+		var md gateway.MarketData
+		if err := ws.ReadJSON(&md); err != nil {
+			return err
+		}
+		if !fn(md) {
+			return nil
+		}
+	}
+}
+
+// isSequenceUnavailable returns true if the server closed a stream because
+// the requested sequence is no longer logged
+func isSequenceUnavailable(err error) bool {
+	var closeErr *websocket.CloseError
+	return errors.As(err, &closeErr) && closeErr.Code == websocket.ClosePolicyViolation
+}
+
+// mergeDone returns a channel closed when ctx ends or done is closed
+func mergeDone(ctx context.Context, done <-chan struct{}) <-chan struct{} {
+	merged := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		close(merged)
+	}()
+	return merged
+}
+
+// depthBook is a local copy of the displayed levels of a symbol
+type depthBook struct {
+	symbol   string
+	sequence uint64
+	bids     map[uint64]gateway.DepthLevel
+	asks     map[uint64]gateway.DepthLevel
+}
+
+func newDepthBook(d gateway.Depth) *depthBook {
+	b := &depthBook{
+		symbol:   d.Symbol,
+		sequence: d.Sequence,
+		bids:     make(map[uint64]gateway.DepthLevel),
+		asks:     make(map[uint64]gateway.DepthLevel),
+	}
+	for _, level := range d.Bids {
+		b.bids[level.Price] = level
+	}
+	for _, level := range d.Asks {
+		b.asks[level.Price] = level
+	}
+	return b
+}
+
+// apply applies a level event
+func (b *depthBook) apply(md gateway.MarketData) {
+	levels := b.asks
+	if md.Side == "bid" {
+		levels = b.bids
+	}
+	if md.Type == gateway.MarketDataLevelDelete {
+		delete(levels, md.Price)
+		return
+	}
+	levels[md.Price] = gateway.DepthLevel{Price: md.Price, Volume: md.Volume, Orders: md.Orders}
+}
+
+// depth returns the book as a Depth, best levels first
+func (b *depthBook) depth() gateway.Depth {
+	d := gateway.Depth{
+		Symbol:   b.symbol,
+		Sequence: b.sequence,
+		Bids:     make([]gateway.DepthLevel, 0, len(b.bids)),
+		Asks:     make([]gateway.DepthLevel, 0, len(b.asks)),
+	}
+	for _, level := range b.bids {
+		d.Bids = append(d.Bids, level)
+	}
+	for _, level := range b.asks {
+		d.Asks = append(d.Asks, level)
+	}
+	sort.Slice(d.Bids, func(i, j int) bool { return d.Bids[i].Price > d.Bids[j].Price })
+	sort.Slice(d.Asks, func(i, j int) bool { return d.Asks[i].Price < d.Asks[j].Price })
+	return d
+}
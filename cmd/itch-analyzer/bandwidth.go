@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tienpsm/go-trader/itch"
+)
+
+// printBandwidth prints the bytes by message type and the multicast
+// bandwidth projected at the peak rates
+func printBandwidth(w io.Writer, b *itch.BandwidthStats, packetSize int) {
+	total := b.Total()
+	fmt.Fprintln(w, "\nBytes by message type:")
+	fmt.Fprintf(w, "  %-4s %12s %14s %8s %7s\n", "Type", "Messages", "Bytes", "Avg", "Share")
+	for _, t := range b.Types() {
+		fmt.Fprintf(w, "  %-4c %12d %14d %8.1f %6.1f%%\n",
+			t.Type, t.Messages, t.Bytes, t.AverageSize(), 100*float64(t.Bytes)/float64(total.Bytes))
+	}
+	fmt.Fprintf(w, "  %-4s %12d %14d %8.1f\n", "All", total.Messages, total.Bytes, total.AverageSize())
+
+	fmt.Fprintf(w, "\nPeak rates and MoldUDP64 bandwidth (%d byte packets):\n", packetSize)
+	fmt.Fprintf(w, "  %-8s %-15s %12s %12s %12s\n", "Interval", "At", "Msg/s", "MB/s", "Mbit/s")
+	for _, p := range b.Peaks() {
+		if p.Messages == 0 {
+			continue
+		}
+		size := float64(p.Bytes) / float64(p.Messages)
+		fmt.Fprintf(w, "  %-8s %-15s %12.0f %12.2f %12.1f\n",
+			p.Interval, timeOfDay(p.Start), p.MessagesPerSecond(), p.BytesPerSecond()/1e6,
+			itch.MulticastBandwidth(p.MessagesPerSecond(), size, packetSize)/1e6)
+	}
+}
+
+// timeOfDay formats an ITCH timestamp, in nanoseconds since midnight
+func timeOfDay(ns uint64) string {
+	return time.Unix(0, int64(ns)).UTC().Format("15:04:05.000000")
+}
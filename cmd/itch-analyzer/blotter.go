@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tienpsm/go-trader/bridge"
+	"github.com/tienpsm/go-trader/itch"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// writeBlotter replays an ITCH file through the matching engine and writes
+// the executions of the feed and the engine to out, as JSON with the
+// reconciliation if out ends in .json and as CSV otherwise. With match, the
+// engine also matches the orders it receives.
+func writeBlotter(path, out string, match bool) (bridge.Reconciliation, error) {
+	in, err := openInput(path)
+	if err != nil {
+		return bridge.Reconciliation{}, err
+	}
+	defer in.Close()
+
+	mm := matching.NewMarketManager()
+	if match {
+		mm.EnableMatching()
+	}
+	b := bridge.NewBlotter(mm)
+	if _, err := itch.NewParser(b).ParseStream(in); err != nil {
+		return b.Reconcile(), fmt.Errorf("%s: %w", path, err)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return b.Reconcile(), err
+	}
+	defer f.Close()
+	buf := bufio.NewWriter(f)
+	if strings.EqualFold(filepath.Ext(out), ".json") {
+		err = b.WriteJSON(buf)
+	} else {
+		err = b.WriteCSV(buf)
+	}
+	if err != nil {
+		return b.Reconcile(), err
+	}
+	if err := buf.Flush(); err != nil {
+		return b.Reconcile(), err
+	}
+	return b.Reconcile(), f.Close()
+}
+
+// printReconciliation prints the counts of a reconciliation
+func printReconciliation(w io.Writer, r bridge.Reconciliation) {
+	fmt.Fprintln(w, "Reconciliation")
+	fmt.Fprintf(w, "  Matched:    %d\n", r.Matched)
+	fmt.Fprintf(w, "  Mismatched: %d\n", len(r.Mismatched))
+	fmt.Fprintf(w, "  Missing:    %d\n", len(r.Missing))
+	fmt.Fprintf(w, "  Extra:      %d\n", len(r.Extra))
+	fmt.Fprintf(w, "  Off-book:   %d\n", r.OffBook)
+}
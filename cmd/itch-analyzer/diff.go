@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/tienpsm/go-trader/itch"
+)
+
+// diffFiles compares two captures of the same feed and prints up to limit of
+// their differences (-1 for all) followed by the counts
+func diffFiles(w io.Writer, first, second string, limit int) (itch.FeedDiffStats, error) {
+	a, err := openInput(first)
+	if err != nil {
+		return itch.FeedDiffStats{}, err
+	}
+	defer a.Close()
+	b, err := openInput(second)
+	if err != nil {
+		return itch.FeedDiffStats{}, err
+	}
+	defer b.Close()
+
+	fmt.Fprintf(w, "Comparing %s (first) with %s (second)\n", first, second)
+	printed := 0
+	stats, err := itch.DiffFeeds(a, b, func(d itch.FeedDiff) error {
+		if limit < 0 || printed < limit {
+			fmt.Fprintf(w, "  %s  %v\n", timeOfDay(d.Timestamp), d)
+			printed++
+		}
+		return nil
+	})
+
+	total := stats.Missing[0] + stats.Missing[1] + stats.Duplicates[0] + stats.Duplicates[1] + stats.Mismatches
+	if total > uint64(printed) {
+		fmt.Fprintf(w, "  ... %d more differences\n", total-uint64(printed))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%-28s %14s %14s\n", "", "first", "second")
+	fmt.Fprintf(w, "%-28s %14d %14d\n", "Messages", stats.Messages[0], stats.Messages[1])
+	fmt.Fprintf(w, "%-28s %14d %14d\n", "Missing", stats.Missing[0], stats.Missing[1])
+	fmt.Fprintf(w, "%-28s %14d %14d\n", "Duplicated", stats.Duplicates[0], stats.Duplicates[1])
+	fmt.Fprintf(w, "%-28s %14d\n", "Mismatched", stats.Mismatches)
+	if err == nil && stats.Identical() {
+		fmt.Fprintln(w, "OK: the captures are identical")
+	}
+	return stats, err
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// defaultPollInterval is how often a followed file is checked for new data
+const defaultPollInterval = 200 * time.Millisecond
+
+// followReader reads a file that is still being written. At the end of the
+// file it waits for more data instead of returning io.EOF, until done is closed.
+type followReader struct {
+	r    io.Reader
+	poll time.Duration
+	done <-chan struct{}
+}
+
+// Read reads from the underlying file, polling for new data at EOF
+func (f *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := f.r.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+		select {
+		case <-f.done:
+			return 0, io.EOF
+		case <-time.After(f.poll):
+		}
+	}
+}
+
+// openFollow opens path for following. "-" reads standard input, which ends
+// when the writing process closes the pipe.
+func openFollow(path string, done <-chan struct{}) (io.Reader, io.Closer, error) {
+	if path == "-" {
+		return os.Stdin, io.NopCloser(os.Stdin), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var r io.Reader = &followReader{r: f, poll: defaultPollInterval, done: done}
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		r = gz
+	}
+	return r, f, nil
+}
+
+// follow parses a growing file or pipe and prints incremental statistics
+// every interval until the stream ends or the process is interrupted.
+// It returns the final report.
+func follow(path string, interval time.Duration) *Report {
+	start := time.Now()
+	done := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	in, closer, err := openFollow(path, done)
+	if err != nil {
+		return newReport([]*FileReport{{Path: path, MessageTypes: make(map[byte]uint64), Err: err}}, time.Since(start))
+	}
+	defer closer.Close()
+
+	// mu guards the analysis. Once stopped, the reader goroutine, which may
+	// still be blocked reading standard input, no longer consumes messages.
+	var mu sync.Mutex
+	var stopped bool
+	a := newAnalysis(path, in)
+	finished := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := a.next()
+			mu.Lock()
+			if stopped {
+				mu.Unlock()
+				return
+			}
+			if err == nil {
+				err = a.consume(msg)
+			}
+			mu.Unlock()
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				finished <- err
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastMessages, lastVolume uint64
+	lastTick := start
+	var result error
+loop:
+	for {
+		select {
+		case now := <-ticker.C:
+			mu.Lock()
+			messages := a.report.Messages
+			bytes := a.report.Bytes
+			var volume uint64
+			for _, s := range a.stats.All() {
+				volume += s.Volume
+			}
+			mu.Unlock()
+
+			fmt.Printf("[%s] +%d msgs (%.0f msg/s), +%d shares traded | total %d msgs, %d bytes, %d shares\n",
+				now.Format("15:04:05"), messages-lastMessages, rate(float64(messages-lastMessages), now.Sub(lastTick)),
+				volume-lastVolume, messages, bytes, volume)
+			lastMessages, lastVolume, lastTick = messages, volume, now
+		case result = <-finished:
+			break loop
+		case <-signals:
+			close(done)
+			break loop
+		}
+	}
+
+	mu.Lock()
+	stopped = true
+	report := a.finish(result)
+	mu.Unlock()
+	report.Elapsed = time.Since(start)
+	return newReport([]*FileReport{report}, report.Elapsed)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/tienpsm/go-trader/itch"
+)
+
+// writeHeatmap samples the depth of the books of an ITCH file and writes the
+// frames as CSV to out. It returns the number of frames written.
+func writeHeatmap(path, out string, config itch.HeatmapConfig) (uint64, error) {
+	in, err := openInput(path)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	f, err := os.Create(out)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	buf := bufio.NewWriter(f)
+	w := itch.NewHeatmapWriter(buf)
+
+	h := itch.NewHeatmap(config, w.Write)
+	if _, err := itch.NewParser(h).ParseStream(in); err != nil {
+		return w.Frames(), fmt.Errorf("%s: %w", path, err)
+	}
+	if err := h.Flush(); err != nil {
+		return w.Frames(), err
+	}
+	if err := w.Flush(); err != nil {
+		return w.Frames(), err
+	}
+	if err := buf.Flush(); err != nil {
+		return w.Frames(), err
+	}
+	return w.Frames(), f.Close()
+}
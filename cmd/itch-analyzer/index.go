@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tienpsm/go-trader/itch"
+)
+
+// writeIndexes writes the sidecar index of every file and prints its entry
+// count. Compressed files cannot be seeked and fail.
+func writeIndexes(w io.Writer, paths []string, opts itch.IndexOptions) error {
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".gz") {
+			return fmt.Errorf("%s: compressed files cannot be indexed", path)
+		}
+		x, err := itch.BuildIndexFile(path, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Fprintf(w, "Indexed %d messages of %s in %d entries to %s\n",
+			x.Messages, path, len(x.Entries), itch.IndexPath(path))
+	}
+	return nil
+}
@@ -0,0 +1,426 @@
+// Command itch-analyzer parses NASDAQ ITCH 5.0 files and prints message and
+// per-symbol trading statistics.
+//
+// Usage:
+//
+//	itch-analyzer [flags] <file|directory|glob>...
+//
+// Files are expected in the NASDAQ BinaryFILE format (2-byte length prefixed
+// messages) and may be gzip-compressed (.gz). Directories are expanded to the
+// regular files they contain. When several inputs are given, the report shows
+// a per-file breakdown followed by the combined statistics. Directories skip
+// the .idx sidecar indexes written by -index.
+//
+// With -follow, a single file that is still being written (or standard input,
+// given as -) is read continuously and incremental statistics are printed
+// every -interval until the stream ends or the process is interrupted.
+//
+// With -heatmap, the depth of every book of a single file is sampled every
+// -heatmap-interval of message time and written as CSV rows of timestamp,
+// stock, side, price, shares and orders, for heatmap visualization. Only
+// books that changed since their previous sample are written.
+//
+// With -blotter, a single file is replayed through the matching engine and
+// the executions printed in the feed are written alongside the executions of
+// the engine, as CSV or, for a .json path, as JSON with the reconciliation.
+// The executions of each order are paired and counted as matched, mismatched
+// (differing in price or quantity), missing from the engine or extra in the
+// engine. The engine only executes what the feed executes unless
+// -blotter-match enables its own matching.
+//
+// With -quality, every trade is measured against the best bid and ask of the
+// book rebuilt from the feed just before it, approximating the NBBO, and the
+// effective spread, price improvement and the percentages of trades at,
+// inside and outside the quote are printed per symbol.
+//
+// With -summary, the combined trades, volume and price range of every symbol
+// are verified against an official daily summary CSV, such as the NASDAQ
+// daily market summary, catching parser or file corruption issues that parse
+// without unknown messages. Discrepancies are printed after the report and
+// make the command fail.
+//
+// With -bandwidth, the bytes and average size of every message type are
+// printed with the peak message rates over a second and a millisecond of
+// message time, and the multicast bandwidth they would take as a MoldUDP64
+// feed in packets of up to -packet-size bytes, for network capacity planning
+// when moving from file replay to live feeds.
+//
+// With -diff, exactly two captures of the same feed, such as the primary and
+// backup captures of a session, are compared message by message, and the
+// messages missing from either, duplicated in either or differing between
+// them are printed with their timestamp and message numbers, followed by the
+// counts. Messages are matched by content among those with the same
+// timestamp. Differences make the command fail.
+//
+// With -index, a sidecar index (<file>.idx) holding the byte offset of a
+// message every -index-every messages and every -index-interval of message
+// time is written for each uncompressed file instead, so that
+// itch.Parser.ParseFrom can start parsing a large file at a given time
+// without reading it from the start.
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tienpsm/go-trader/itch"
+)
+
+func main() {
+	parallel := flag.Int("parallel", 1, "number of files to parse concurrently")
+	top := flag.Int("top", 20, "number of symbols to show in the volume table (-1 for all)")
+	followMode := flag.Bool("follow", false, "keep reading a growing file (or - for stdin) and print incremental statistics")
+	interval := flag.Duration("interval", 5*time.Second, "statistics interval in follow mode")
+	heatmap := flag.String("heatmap", "", "write sampled book depth of a single file as CSV to this `path`")
+	heatmapInterval := flag.Duration("heatmap-interval", time.Second, "sampling interval of the heatmap in message time")
+	heatmapDepth := flag.Int("heatmap-depth", 20, "price levels per side in the heatmap (0 for all)")
+	heatmapSymbols := flag.String("heatmap-symbols", "", "comma-separated symbols to sample (default all)")
+	blotter := flag.String("blotter", "", "replay a single file through the engine and write the feed and engine executions to this `path` (.csv or .json)")
+	blotterMatch := flag.Bool("blotter-match", false, "enable matching in the engine of -blotter")
+	quality := flag.Bool("quality", false, "report execution quality against the quote of the rebuilt books")
+	summaryPath := flag.String("summary", "", "verify the per-symbol totals against a daily summary CSV at this `path`")
+	bandwidth := flag.Bool("bandwidth", false, "report bytes by message type and the projected multicast bandwidth at the peak rates")
+	packetSize := flag.Int("packet-size", 1400, "maximum UDP payload of a multicast packet for -bandwidth")
+	diff := flag.Bool("diff", false, "compare two captures of the same feed and report missing, duplicated and mismatched messages")
+	diffMax := flag.Int("diff-max", 100, "number of differences to print with -diff (-1 for all)")
+	index := flag.Bool("index", false, "write a sidecar index of each uncompressed file instead of analyzing it")
+	indexEvery := flag.Int64("index-every", itch.DefaultIndexEvery, "maximum messages between index entries (-1 to index by time only)")
+	indexInterval := flag.Duration("index-interval", itch.DefaultIndexInterval, "message time between index entries (-1ns to index by count only)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <file|directory|glob>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *heatmap != "" {
+		if flag.NArg() != 1 || *followMode {
+			fmt.Fprintln(os.Stderr, "itch-analyzer: -heatmap takes exactly one file and no -follow")
+			os.Exit(2)
+		}
+		config := itch.HeatmapConfig{Interval: *heatmapInterval, Depth: *heatmapDepth}
+		if *heatmapSymbols != "" {
+			config.Stocks = strings.Split(*heatmapSymbols, ",")
+		}
+		frames, err := writeHeatmap(flag.Arg(0), *heatmap, config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "itch-analyzer: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %d frames to %s\n", frames, *heatmap)
+		return
+	}
+
+	if *diff {
+		if flag.NArg() != 2 || *followMode {
+			fmt.Fprintln(os.Stderr, "itch-analyzer: -diff takes exactly two files and no -follow")
+			os.Exit(2)
+		}
+		stats, err := diffFiles(os.Stdout, flag.Arg(0), flag.Arg(1), *diffMax)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "itch-analyzer: %v\n", err)
+			os.Exit(1)
+		}
+		if !stats.Identical() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *blotter != "" {
+		if flag.NArg() != 1 || *followMode {
+			fmt.Fprintln(os.Stderr, "itch-analyzer: -blotter takes exactly one file and no -follow")
+			os.Exit(2)
+		}
+		r, err := writeBlotter(flag.Arg(0), *blotter, *blotterMatch)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "itch-analyzer: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote executions to %s\n", *blotter)
+		printReconciliation(os.Stdout, r)
+		return
+	}
+
+	if *followMode {
+		if *index {
+			fmt.Fprintln(os.Stderr, "itch-analyzer: -index does not support -follow")
+			os.Exit(2)
+		}
+		if *summaryPath != "" {
+			fmt.Fprintln(os.Stderr, "itch-analyzer: -summary does not support -follow")
+			os.Exit(2)
+		}
+		if *quality {
+			fmt.Fprintln(os.Stderr, "itch-analyzer: -quality does not support -follow")
+			os.Exit(2)
+		}
+		if flag.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "itch-analyzer: -follow takes exactly one file or -")
+			os.Exit(2)
+		}
+		report := follow(flag.Arg(0), *interval)
+		report.Print(os.Stdout, *top)
+		if *bandwidth {
+			printBandwidth(os.Stdout, report.Bandwidth, *packetSize)
+		}
+		if report.Files[0].Err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	paths, err := expandInputs(flag.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "itch-analyzer: %v\n", err)
+		os.Exit(1)
+	}
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "itch-analyzer: no input files")
+		os.Exit(1)
+	}
+
+	if *index {
+		if err := writeIndexes(os.Stdout, paths, itch.IndexOptions{Every: *indexEvery, Interval: *indexInterval}); err != nil {
+			fmt.Fprintf(os.Stderr, "itch-analyzer: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *quality {
+		if *summaryPath != "" {
+			fmt.Fprintln(os.Stderr, "itch-analyzer: -summary does not support -quality")
+			os.Exit(2)
+		}
+		stats, err := analyzeQuality(paths)
+		printQuality(os.Stdout, stats, *top)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "itch-analyzer: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var summary *itch.DailySummary
+	if *summaryPath != "" {
+		if summary, err = readSummary(*summaryPath); err != nil {
+			fmt.Fprintf(os.Stderr, "itch-analyzer: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	start := time.Now()
+	files := analyzeAll(paths, *parallel)
+	report := newReport(files, time.Since(start))
+	report.Print(os.Stdout, *top)
+	if *bandwidth {
+		printBandwidth(os.Stdout, report.Bandwidth, *packetSize)
+	}
+
+	failed := false
+	if summary != nil && printSummaryCheck(os.Stdout, summary, report) > 0 {
+		failed = true
+	}
+	for _, f := range files {
+		if f.Err != nil {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// expandInputs resolves files, directories and glob patterns into a sorted,
+// de-duplicated list of files, preserving the order of the arguments
+func expandInputs(args []string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+
+	for _, arg := range args {
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("bad pattern %q: %w", arg, err)
+		}
+		if matches == nil {
+			return nil, fmt.Errorf("%s: no such file or directory", arg)
+		}
+		for _, m := range matches {
+			info, err := os.Stat(m)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				add(m)
+				continue
+			}
+			entries, err := os.ReadDir(m)
+			if err != nil {
+				return nil, err
+			}
+			var dirFiles []string
+			for _, e := range entries {
+				// Sidecar indexes are not captures
+				if e.Type().IsRegular() && !strings.HasPrefix(e.Name(), ".") && !strings.HasSuffix(e.Name(), ".idx") {
+					dirFiles = append(dirFiles, filepath.Join(m, e.Name()))
+				}
+			}
+			sort.Strings(dirFiles)
+			for _, p := range dirFiles {
+				add(p)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// analyzeAll parses every file using up to parallel workers and returns the
+// file reports in input order
+func analyzeAll(paths []string, parallel int) []*FileReport {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	reports := make([]*FileReport, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				reports[i] = analyzeFile(paths[i])
+			}
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return reports
+}
+
+// openInput opens a file, transparently decompressing gzip files
+func openInput(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, f}, nil
+}
+
+// analysis accumulates the statistics of one input stream
+type analysis struct {
+	report    *FileReport
+	stats     *itch.SymbolStats
+	bandwidth *itch.BandwidthStats
+	parser    *itch.Parser
+	frames    *itch.FrameReader
+}
+
+// newAnalysis creates an analysis reading framed messages from r
+func newAnalysis(path string, r io.Reader) *analysis {
+	stats := itch.NewSymbolStats()
+	bandwidth := itch.NewBandwidthStats()
+	return &analysis{
+		report: &FileReport{
+			Path:         path,
+			MessageTypes: make(map[byte]uint64),
+			Bandwidth:    bandwidth,
+		},
+		stats:     stats,
+		bandwidth: bandwidth,
+		parser:    itch.NewParser(stats),
+		frames:    itch.NewFrameReader(r),
+	}
+}
+
+// next reads the next frame from the stream. It returns io.EOF at a clean end
+// of stream.
+func (a *analysis) next() ([]byte, error) {
+	msg, err := a.frames.Next()
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("offset %d: %w", a.frames.Offset(), err)
+	}
+	return msg, err
+}
+
+// consume parses one message and updates the statistics
+func (a *analysis) consume(msg []byte) error {
+	a.report.Bytes = a.frames.Offset()
+	if len(msg) == 0 {
+		return nil
+	}
+	if _, err := a.parser.Parse(msg); err != nil {
+		return fmt.Errorf("offset %d: %w", a.frames.Offset()-int64(len(msg))-2, err)
+	}
+	a.report.MessageTypes[msg[0]]++
+	a.bandwidth.Add(msg)
+	a.report.Messages++
+	return nil
+}
+
+// finish completes the file report. It does not read the input, so that a
+// follow can finish while its reader is still blocked.
+func (a *analysis) finish(err error) *FileReport {
+	a.report.Err = err
+	a.report.Stocks = a.stats.All()
+	return a.report
+}
+
+// analyzeFile parses one file and collects its statistics
+func analyzeFile(path string) *FileReport {
+	start := time.Now()
+	in, err := openInput(path)
+	if err != nil {
+		return &FileReport{Path: path, MessageTypes: make(map[byte]uint64), Err: err}
+	}
+	defer in.Close()
+
+	a := newAnalysis(path, in)
+	for {
+		msg, err := a.next()
+		if err == nil {
+			err = a.consume(msg)
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			report := a.finish(err)
+			report.Elapsed = time.Since(start)
+			return report
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/tienpsm/go-trader/itch"
+)
+
+// analyzeQuality measures the execution quality of every file against the
+// quote of its rebuilt books and merges the statistics per symbol. Books are
+// rebuilt from scratch for each file, as each file is a separate session.
+func analyzeQuality(paths []string) (map[string]*itch.QualityStats, error) {
+	merged := make(map[string]*itch.QualityStats)
+	for _, path := range paths {
+		in, err := openInput(path)
+		if err != nil {
+			return merged, err
+		}
+		h := itch.NewExecutionQuality()
+		_, err = itch.NewParser(h).ParseStream(in)
+		in.Close()
+		if err != nil {
+			return merged, fmt.Errorf("%s: %w", path, err)
+		}
+
+		for _, s := range h.All() {
+			if m, ok := merged[s.Stock]; ok {
+				m.Merge(s)
+			} else {
+				copied := s
+				merged[s.Stock] = &copied
+			}
+		}
+	}
+	return merged, nil
+}
+
+// printQuality writes the execution quality of up to top symbols by traded
+// shares (-1 for all)
+func printQuality(w io.Writer, stats map[string]*itch.QualityStats, top int) {
+	stocks := make([]*itch.QualityStats, 0, len(stats))
+	for _, s := range stats {
+		stocks = append(stocks, s)
+	}
+	sort.Slice(stocks, func(i, j int) bool {
+		if stocks[i].Shares != stocks[j].Shares {
+			return stocks[i].Shares > stocks[j].Shares
+		}
+		return stocks[i].Stock < stocks[j].Stock
+	})
+	if top >= 0 && len(stocks) > top {
+		stocks = stocks[:top]
+	}
+
+	fmt.Fprintln(w, "===========================================")
+	fmt.Fprintln(w, "        ITCH Execution Quality Report")
+	fmt.Fprintln(w, "===========================================")
+	fmt.Fprintln(w, "\nEffective spread and price improvement per share, against the best bid")
+	fmt.Fprintln(w, "and ask of the rebuilt book before each trade:")
+	fmt.Fprintf(w, "\n  %-8s %10s %14s %8s %10s %9s %10s %8s %8s %8s\n",
+		"Symbol", "Trades", "Shares", "Quoted%", "EffSpread", "Eff bps", "PriceImpr", "At%", "Inside%", "Outside%")
+	for _, s := range stocks {
+		quoted := 0.0
+		if s.Trades > 0 {
+			quoted = 100 * float64(s.Quoted) / float64(s.Trades)
+		}
+		fmt.Fprintf(w, "  %-8s %10d %14d %8.1f %10.4f %9.2f %10.4f %8.1f %8.1f %8.1f\n",
+			s.Stock, s.Trades, s.Shares, quoted, s.EffectiveSpread()/1e4, s.EffectiveSpreadBps(),
+			s.PriceImprovement()/1e4, s.AtQuotePercent(), s.InsideQuotePercent(), s.OutsideQuotePercent())
+	}
+	fmt.Fprintln(w, "===========================================")
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/tienpsm/go-trader/itch"
+)
+
+// FileReport holds the statistics collected from one ITCH file
+type FileReport struct {
+	// Path is the file that was analyzed
+	Path string
+	// Bytes is the number of bytes read from the file (after decompression)
+	Bytes int64
+	// Messages is the number of messages parsed
+	Messages uint64
+	// MessageTypes counts messages by type
+	MessageTypes map[byte]uint64
+	// Stocks holds the per-symbol statistics
+	Stocks []itch.StockStats
+	// Bandwidth holds the bytes by message type and the peak rates, nil if
+	// the file could not be opened
+	Bandwidth *itch.BandwidthStats
+	// Elapsed is the time spent parsing the file
+	Elapsed time.Duration
+	// Err is the error that stopped parsing, if any
+	Err error
+}
+
+// Report is the combined report over several files
+type Report struct {
+	// Files holds the per-file breakdown in input order
+	Files []*FileReport
+	// Bytes is the total number of bytes read
+	Bytes int64
+	// Messages is the total number of messages parsed
+	Messages uint64
+	// MessageTypes counts messages by type over all files
+	MessageTypes map[byte]uint64
+	// Stocks holds per-symbol statistics merged over all files
+	Stocks map[string]*itch.StockStats
+	// Bandwidth holds the bytes by message type over all files and the
+	// peak rates of the busiest file
+	Bandwidth *itch.BandwidthStats
+	// Elapsed is the wall-clock time of the whole run
+	Elapsed time.Duration
+}
+
+// newReport aggregates file reports into one combined report.
+// Files are merged in input order so that last prices come from the last file.
+func newReport(files []*FileReport, elapsed time.Duration) *Report {
+	r := &Report{
+		Files:        files,
+		MessageTypes: make(map[byte]uint64),
+		Stocks:       make(map[string]*itch.StockStats),
+		Bandwidth:    itch.NewBandwidthStats(),
+		Elapsed:      elapsed,
+	}
+	for _, f := range files {
+		r.add(f)
+	}
+	return r
+}
+
+// add merges a file report into the combined report
+func (r *Report) add(f *FileReport) {
+	r.Bytes += f.Bytes
+	r.Messages += f.Messages
+	for t, n := range f.MessageTypes {
+		r.MessageTypes[t] += n
+	}
+	if f.Bandwidth != nil {
+		r.Bandwidth.Merge(f.Bandwidth)
+	}
+	for _, s := range f.Stocks {
+		merged, ok := r.Stocks[s.Stock]
+		if !ok {
+			copied := s
+			copied.StockLocate = 0
+			r.Stocks[s.Stock] = &copied
+			continue
+		}
+		mergeStats(merged, s)
+	}
+}
+
+// mergeStats adds s into merged
+func mergeStats(merged *itch.StockStats, s itch.StockStats) {
+	merged.AddOrders += s.AddOrders
+	merged.Executions += s.Executions
+	merged.Cancels += s.Cancels
+	merged.Deletes += s.Deletes
+	merged.Replaces += s.Replaces
+	merged.Trades += s.Trades
+	merged.Volume += s.Volume
+	merged.Notional += s.Notional
+	merged.BrokenTrades += s.BrokenTrades
+	merged.BrokenVolume += s.BrokenVolume
+	merged.Lifetime = merged.Lifetime.Merge(s.Lifetime)
+	merged.ModifiedOrders += s.ModifiedOrders
+	merged.Modifications += s.Modifications
+	if s.High > merged.High {
+		merged.High = s.High
+	}
+	if s.Low != 0 && (merged.Low == 0 || s.Low < merged.Low) {
+		merged.Low = s.Low
+	}
+	if s.Last != 0 {
+		merged.Last = s.Last
+	}
+}
+
+// sortedTypes returns message types ordered by descending count
+func sortedTypes(counts map[byte]uint64) []byte {
+	types := make([]byte, 0, len(counts))
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if counts[types[i]] != counts[types[j]] {
+			return counts[types[i]] > counts[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	return types
+}
+
+// topStocks returns up to n stocks ordered by descending volume
+func (r *Report) topStocks(n int) []*itch.StockStats {
+	stocks := make([]*itch.StockStats, 0, len(r.Stocks))
+	for _, s := range r.Stocks {
+		if s.Volume > 0 {
+			stocks = append(stocks, s)
+		}
+	}
+	sort.Slice(stocks, func(i, j int) bool {
+		if stocks[i].Volume != stocks[j].Volume {
+			return stocks[i].Volume > stocks[j].Volume
+		}
+		return stocks[i].Stock < stocks[j].Stock
+	})
+	if n >= 0 && len(stocks) > n {
+		stocks = stocks[:n]
+	}
+	return stocks
+}
+
+// rate returns a per-second rate, guarding against zero durations
+func rate(n float64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return n / d.Seconds()
+}
+
+// lifetime formats an order lifetime with a precision suited to its magnitude
+func lifetime(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond).String()
+	default:
+		return d.String()
+	}
+}
+
+// Print writes the report as text
+func (r *Report) Print(w io.Writer, top int) {
+	fmt.Fprintln(w, "===========================================")
+	fmt.Fprintln(w, "            ITCH Analyzer Report")
+	fmt.Fprintln(w, "===========================================")
+
+	if len(r.Files) > 1 {
+		fmt.Fprintln(w, "\nFiles:")
+		for _, f := range r.Files {
+			status := ""
+			if f.Err != nil {
+				status = "  ERROR: " + f.Err.Error()
+			}
+			fmt.Fprintf(w, "  %-40s %12d msgs %14d bytes %10.0f msg/s%s\n",
+				f.Path, f.Messages, f.Bytes, rate(float64(f.Messages), f.Elapsed), status)
+		}
+	} else if len(r.Files) == 1 && r.Files[0].Err != nil {
+		fmt.Fprintf(w, "\nERROR: %v\n", r.Files[0].Err)
+	}
+
+	fmt.Fprintln(w, "\nTotals:")
+	fmt.Fprintf(w, "  Files:      %d\n", len(r.Files))
+	fmt.Fprintf(w, "  Messages:   %d\n", r.Messages)
+	fmt.Fprintf(w, "  Bytes:      %d\n", r.Bytes)
+	fmt.Fprintf(w, "  Elapsed:    %s\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "  Throughput: %.0f msg/s\n", rate(float64(r.Messages), r.Elapsed))
+
+	fmt.Fprintln(w, "\nMessage types:")
+	for _, t := range sortedTypes(r.MessageTypes) {
+		fmt.Fprintf(w, "  %c  %12d\n", t, r.MessageTypes[t])
+	}
+
+	if top != 0 {
+		fmt.Fprintln(w, "\nTop symbols by volume:")
+		fmt.Fprintf(w, "  %-8s %10s %14s %12s %12s %12s\n", "Symbol", "Trades", "Volume", "VWAP", "Low", "High")
+		for _, s := range r.topStocks(top) {
+			fmt.Fprintf(w, "  %-8s %10d %14d %12.4f %12.4f %12.4f\n",
+				s.Stock, s.Trades, s.Volume, s.VWAP()/1e4, float64(s.Low)/1e4, float64(s.High)/1e4)
+		}
+
+		fmt.Fprintln(w, "\nOrder lifecycle (top symbols by volume):")
+		fmt.Fprintf(w, "  %-8s %10s %12s %12s %12s %12s %8s\n", "Symbol", "Completed", "Life p50", "Life p90", "Life p99", "Cancel/Trd", "Mods/Ord")
+		for _, s := range r.topStocks(top) {
+			fmt.Fprintf(w, "  %-8s %10d %12s %12s %12s %12.2f %8.2f\n",
+				s.Stock, s.Lifetime.Count, lifetime(s.Lifetime.Percentile(0.5)), lifetime(s.Lifetime.Percentile(0.9)),
+				lifetime(s.Lifetime.Percentile(0.99)), s.CancelToTradeRatio(), s.ModificationsPerOrder())
+		}
+	}
+	fmt.Fprintln(w, "===========================================")
+}
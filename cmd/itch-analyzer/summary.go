@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tienpsm/go-trader/itch"
+)
+
+// readSummary reads a daily summary CSV file
+func readSummary(path string) (*itch.DailySummary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	summary, err := itch.ReadDailySummary(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return summary, nil
+}
+
+// printSummaryCheck compares the combined statistics with a daily summary and
+// prints the discrepancies, returning their number
+func printSummaryCheck(w io.Writer, summary *itch.DailySummary, r *Report) int {
+	stats := make([]itch.StockStats, 0, len(r.Stocks))
+	for _, s := range r.Stocks {
+		stats = append(stats, *s)
+	}
+	discrepancies := summary.Check(stats)
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Daily summary check (%d symbols)\n", len(summary.Stocks))
+	if len(discrepancies) == 0 {
+		fmt.Fprintln(w, "  OK: all totals match")
+		return 0
+	}
+	for _, d := range discrepancies {
+		fmt.Fprintf(w, "  MISMATCH %s\n", d)
+	}
+	fmt.Fprintf(w, "  %d discrepancies\n", len(discrepancies))
+	return len(discrepancies)
+}
@@ -0,0 +1,129 @@
+// Command itch-conformance writes a golden ITCH 5.0 conformance corpus: a
+// length-prefixed (BinaryFILE) message file that exercises every message
+// type and edge case, and a JSON file with the expected parsed output.
+//
+// Usage:
+//
+//	itch-conformance [-o dir] [-name conformance]
+//	itch-conformance -verify <file.itch> <file.json>
+//
+// The JSON document lists one record per message with its index, frame
+// offset, type and fields. Byte fields are strings with padding kept and
+// integers are numbers, including full-range 64-bit values, so consumers
+// outside Go should decode them without loss of precision.
+//
+// With -verify, the message file is parsed with the itch package and
+// compared against the expected output.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tienpsm/go-trader/itch/conformance"
+)
+
+func main() {
+	dir := flag.String("o", ".", "output directory")
+	name := flag.String("name", "conformance", "base name of the generated files")
+	verify := flag.Bool("verify", false, "parse a message file and compare it with its expected output")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-o dir] [-name conformance]\n       %s -verify <file.itch> <file.json>\n", os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *verify {
+		if flag.NArg() != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		if err := verifyFiles(flag.Arg(0), flag.Arg(1)); err != nil {
+			fmt.Fprintf(os.Stderr, "itch-conformance: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	data, expected, err := conformance.Build(conformance.Corpus())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "itch-conformance: %v\n", err)
+		os.Exit(1)
+	}
+	if err := write(*dir, *name, data, expected); err != nil {
+		fmt.Fprintf(os.Stderr, "itch-conformance: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %d messages (%d bytes) to %s\n", expected.Messages, expected.Bytes, filepath.Join(*dir, *name+".itch"))
+}
+
+// write stores the message file and its expected output in dir
+func write(dir, name string, data []byte, expected conformance.Expected) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".itch"), data, 0o644); err != nil {
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(dir, name+".json"))
+	if err != nil {
+		return err
+	}
+	if err := conformance.WriteExpected(f, expected); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// verifyFiles parses a message file and compares it with the expected output
+func verifyFiles(itchPath, jsonPath string) error {
+	in, err := os.Open(itchPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	parsed, err := conformance.Parse(in)
+	if err != nil {
+		return fmt.Errorf("%s: %w", itchPath, err)
+	}
+
+	f, err := os.Open(jsonPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	expected, err := conformance.ReadExpected(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", jsonPath, err)
+	}
+
+	failures := 0
+	for i, want := range expected {
+		if i >= len(parsed) {
+			fmt.Printf("FAIL #%d %s: missing\n", i, want.Case)
+			failures++
+			continue
+		}
+		if !want.Equal(parsed[i]) {
+			fmt.Printf("FAIL #%d %s:\n  want %+v\n  got  %+v\n", i, want.Case, want, parsed[i])
+			failures++
+		}
+	}
+	if len(parsed) > len(expected) {
+		fmt.Printf("FAIL %d unexpected trailing messages\n", len(parsed)-len(expected))
+		failures++
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d of %d messages do not conform", failures, len(expected))
+	}
+	fmt.Printf("OK %d messages\n", len(expected))
+	return nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/tienpsm/go-trader/itch"
+)
+
+// Row is one normalized ITCH message in the output table
+type Row struct {
+	Type        string `parquet:"type,dict"`
+	Timestamp   int64  `parquet:"timestamp,timestamp(nanosecond)"`
+	Locate      int32  `parquet:"locate"`
+	Symbol      string `parquet:"symbol,dict"`
+	Side        string `parquet:"side,dict"`
+	Price       int64  `parquet:"price,decimal(4:18)"`
+	Size        int64  `parquet:"size"`
+	OrderRef    int64  `parquet:"order_ref"`
+	NewOrderRef int64  `parquet:"new_order_ref"`
+	MatchNumber int64  `parquet:"match_number"`
+}
+
+// order is the resting order state used to enrich executions and cancels
+type order struct {
+	side   byte
+	shares uint32
+	price  uint32
+}
+
+// converter is an itch.Handler that turns messages into rows
+type converter struct {
+	itch.DefaultHandler
+
+	w      *parquet.GenericWriter[Row]
+	types  [256]bool
+	base   int64
+	stocks map[uint16]string
+	orders map[uint64]order
+	batch  []Row
+	rows   int64
+}
+
+// newConverter creates a converter writing the given message types to w
+func newConverter(w *parquet.GenericWriter[Row], types string, base time.Time) *converter {
+	c := &converter{
+		w:      w,
+		stocks: make(map[uint16]string),
+		orders: make(map[uint64]order),
+		batch:  make([]Row, 0, rowBatchSize),
+	}
+	if !base.IsZero() {
+		c.base = base.UnixNano()
+	}
+	for i := 0; i < len(types); i++ {
+		c.types[types[i]] = true
+	}
+	return c
+}
+
+// emit buffers a row if its message type is selected
+func (c *converter) emit(msgType byte, timestamp uint64, locate uint16, r Row) error {
+	if !c.types[msgType] {
+		return nil
+	}
+	r.Type = string(msgType)
+	r.Timestamp = c.base + int64(timestamp)
+	r.Locate = int32(locate)
+	r.Symbol = c.stocks[locate]
+	c.batch = append(c.batch, r)
+	if len(c.batch) == cap(c.batch) {
+		return c.flush()
+	}
+	return nil
+}
+
+// flush writes the buffered rows
+func (c *converter) flush() error {
+	if len(c.batch) == 0 {
+		return nil
+	}
+	n, err := c.w.Write(c.batch)
+	c.rows += int64(n)
+	c.batch = c.batch[:0]
+	return err
+}
+
+// register remembers the stock symbol for a locate code
+func (c *converter) register(locate uint16, stock itch.StockSymbol) {
+	if _, ok := c.stocks[locate]; !ok {
+		c.stocks[locate] = stock.Trimmed()
+	}
+}
+
+// reduce removes shares from a resting order, forgetting it once empty
+func (c *converter) reduce(ref uint64, shares uint32) order {
+	o := c.orders[ref]
+	if shares >= o.shares {
+		delete(c.orders, ref)
+	} else {
+		o.shares -= shares
+		c.orders[ref] = o
+	}
+	return o
+}
+
+// side converts a buy/sell indicator into a column value
+func side(indicator byte) string {
+	switch indicator {
+	case 'B':
+		return "B"
+	case 'S':
+		return "S"
+	default:
+		return ""
+	}
+}
+
+func (c *converter) OnStockDirectory(msg itch.StockDirectoryMessage) error {
+	c.register(msg.StockLocate, msg.Stock)
+	return nil
+}
+
+func (c *converter) OnAddOrder(msg itch.AddOrderMessage) error {
+	c.register(msg.StockLocate, msg.Stock)
+	c.orders[msg.OrderReferenceNumber] = order{side: msg.BuySellIndicator, shares: msg.Shares, price: msg.Price}
+	return c.emit(msg.Type, msg.Timestamp, msg.StockLocate, Row{
+		Side:     side(msg.BuySellIndicator),
+		Price:    int64(msg.Price),
+		Size:     int64(msg.Shares),
+		OrderRef: int64(msg.OrderReferenceNumber),
+	})
+}
+
+func (c *converter) OnAddOrderMPID(msg itch.AddOrderMPIDMessage) error {
+	c.register(msg.StockLocate, msg.Stock)
+	c.orders[msg.OrderReferenceNumber] = order{side: msg.BuySellIndicator, shares: msg.Shares, price: msg.Price}
+	return c.emit(msg.Type, msg.Timestamp, msg.StockLocate, Row{
+		Side:     side(msg.BuySellIndicator),
+		Price:    int64(msg.Price),
+		Size:     int64(msg.Shares),
+		OrderRef: int64(msg.OrderReferenceNumber),
+	})
+}
+
+func (c *converter) OnOrderExecuted(msg itch.OrderExecutedMessage) error {
+	o := c.reduce(msg.OrderReferenceNumber, msg.ExecutedShares)
+	return c.emit(msg.Type, msg.Timestamp, msg.StockLocate, Row{
+		Side:        side(o.side),
+		Price:       int64(o.price),
+		Size:        int64(msg.ExecutedShares),
+		OrderRef:    int64(msg.OrderReferenceNumber),
+		MatchNumber: int64(msg.MatchNumber),
+	})
+}
+
+func (c *converter) OnOrderExecutedWithPrice(msg itch.OrderExecutedWithPriceMessage) error {
+	o := c.reduce(msg.OrderReferenceNumber, msg.ExecutedShares)
+	return c.emit(msg.Type, msg.Timestamp, msg.StockLocate, Row{
+		Side:        side(o.side),
+		Price:       int64(msg.ExecutionPrice),
+		Size:        int64(msg.ExecutedShares),
+		OrderRef:    int64(msg.OrderReferenceNumber),
+		MatchNumber: int64(msg.MatchNumber),
+	})
+}
+
+func (c *converter) OnOrderCancel(msg itch.OrderCancelMessage) error {
+	o := c.reduce(msg.OrderReferenceNumber, msg.CanceledShares)
+	return c.emit(msg.Type, msg.Timestamp, msg.StockLocate, Row{
+		Side:     side(o.side),
+		Price:    int64(o.price),
+		Size:     int64(msg.CanceledShares),
+		OrderRef: int64(msg.OrderReferenceNumber),
+	})
+}
+
+func (c *converter) OnOrderDelete(msg itch.OrderDeleteMessage) error {
+	o := c.orders[msg.OrderReferenceNumber]
+	delete(c.orders, msg.OrderReferenceNumber)
+	return c.emit(msg.Type, msg.Timestamp, msg.StockLocate, Row{
+		Side:     side(o.side),
+		Price:    int64(o.price),
+		OrderRef: int64(msg.OrderReferenceNumber),
+	})
+}
+
+func (c *converter) OnOrderReplace(msg itch.OrderReplaceMessage) error {
+	o := c.orders[msg.OriginalOrderReferenceNumber]
+	delete(c.orders, msg.OriginalOrderReferenceNumber)
+	c.orders[msg.NewOrderReferenceNumber] = order{side: o.side, shares: msg.Shares, price: msg.Price}
+	return c.emit(msg.Type, msg.Timestamp, msg.StockLocate, Row{
+		Side:        side(o.side),
+		Price:       int64(msg.Price),
+		Size:        int64(msg.Shares),
+		OrderRef:    int64(msg.OriginalOrderReferenceNumber),
+		NewOrderRef: int64(msg.NewOrderReferenceNumber),
+	})
+}
+
+func (c *converter) OnTrade(msg itch.TradeMessage) error {
+	c.register(msg.StockLocate, msg.Stock)
+	return c.emit(msg.Type, msg.Timestamp, msg.StockLocate, Row{
+		Side:        side(msg.BuySellIndicator),
+		Price:       int64(msg.Price),
+		Size:        int64(msg.Shares),
+		OrderRef:    int64(msg.OrderReferenceNumber),
+		MatchNumber: int64(msg.MatchNumber),
+	})
+}
+
+func (c *converter) OnCrossTrade(msg itch.CrossTradeMessage) error {
+	c.register(msg.StockLocate, msg.Stock)
+	return c.emit(msg.Type, msg.Timestamp, msg.StockLocate, Row{
+		Price:       int64(msg.CrossPrice),
+		Size:        int64(msg.Shares),
+		MatchNumber: int64(msg.MatchNumber),
+	})
+}
+
+func (c *converter) OnBrokenTrade(msg itch.BrokenTradeMessage) error {
+	return c.emit(msg.Type, msg.Timestamp, msg.StockLocate, Row{
+		MatchNumber: int64(msg.MatchNumber),
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/persistence"
+)
+
+// runDiff runs the diff subcommand and returns the exit status: 0 when the
+// snapshots match, 1 when they differ and 2 on errors
+func runDiff(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s diff <snapshot> <snapshot>\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "Each argument is a snapshot file or a snapshot directory (its newest snapshot).")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	a, err := loadSnapshot(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "journal-replay: %v\n", err)
+		return 2
+	}
+	b, err := loadSnapshot(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "journal-replay: %v\n", err)
+		return 2
+	}
+
+	d := persistence.DiffSnapshots(a, b)
+	printDiff(out, a, b, d)
+	if d.Empty() {
+		return 0
+	}
+	return 1
+}
+
+// loadSnapshot reads a snapshot file, or the newest snapshot of a directory
+func loadSnapshot(path string) (*persistence.Snapshot, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return persistence.LoadSnapshotFile(path)
+	}
+	sp, err := persistence.NewSnapshotter(path)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := sp.LoadLatest()
+	if err != nil {
+		return nil, err
+	}
+	if snap == nil {
+		return nil, fmt.Errorf("no snapshot in %s", path)
+	}
+	return snap, nil
+}
+
+// printDiff prints a summary line followed by one line per difference
+func printDiff(out io.Writer, a, b *persistence.Snapshot, d *persistence.SnapshotDiff) {
+	fmt.Fprintf(out, "--- %s (%d symbols, %d orders)\n", formatTime(a.Timestamp), len(a.Symbols), len(a.Orders))
+	fmt.Fprintf(out, "+++ %s (%d symbols, %d orders)\n", formatTime(b.Timestamp), len(b.Symbols), len(b.Orders))
+	if d.Empty() {
+		fmt.Fprintln(out, "No differences")
+		return
+	}
+	fmt.Fprintf(out, "Symbols: %d added, %d removed, %d changed\n",
+		len(d.SymbolsAdded), len(d.SymbolsRemoved), len(d.SymbolsChanged))
+	fmt.Fprintf(out, "Orders: %d added, %d removed, %d changed\n",
+		len(d.OrdersAdded), len(d.OrdersRemoved), len(d.OrdersChanged))
+
+	for _, s := range d.SymbolsAdded {
+		fmt.Fprintf(out, "+ symbol %d %s\n", s.ID, s.Name)
+	}
+	for _, s := range d.SymbolsRemoved {
+		fmt.Fprintf(out, "- symbol %d %s\n", s.ID, s.Name)
+	}
+	for _, c := range d.SymbolsChanged {
+		fmt.Fprintf(out, "~ symbol %d: %s\n", c.New.ID, formatFields(c.Fields))
+	}
+	for _, o := range d.OrdersAdded {
+		fmt.Fprintf(out, "+ order %s\n", formatOrder(o))
+	}
+	for _, o := range d.OrdersRemoved {
+		fmt.Fprintf(out, "- order %s\n", formatOrder(o))
+	}
+	for _, c := range d.OrdersChanged {
+		fmt.Fprintf(out, "~ order %d: %s\n", c.New.ID, formatFields(c.Fields))
+	}
+}
+
+// formatOrder formats the main fields of an order on one line
+func formatOrder(o matching.Order) string {
+	return fmt.Sprintf("%d symbol=%d %s %s %d @ %d executed=%d leaves=%d participant=%d",
+		o.ID, o.SymbolID, o.Side, o.Type, o.Quantity, o.Price, o.ExecutedQuantity, o.LeavesQuantity, o.ParticipantID)
+}
+
+// formatFields formats field changes as "Field old -> new" pairs
+func formatFields(fields []persistence.FieldChange) string {
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = fmt.Sprintf("%s %s -> %s", f.Field, f.Old, f.New)
+	}
+	return strings.Join(parts, ", ")
+}
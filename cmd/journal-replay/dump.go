@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/persistence"
+)
+
+// event prints a journal event
+func (s *session) event(seq int, e persistence.MatchingEvent) {
+	prefix := fmt.Sprintf("#%d %s", seq, formatTime(e.Timestamp))
+	switch e.Type {
+	case persistence.EventNewOrder:
+		o := e.Order
+		fmt.Fprintf(s.out, "%s NEW    id=%d symbol=%d %s %s %d @ %d tif=%s\n",
+			prefix, o.ID, o.SymbolID, o.Side, o.Type, o.LeavesQuantity, o.Price, o.TimeInForce)
+	case persistence.EventCancelOrder:
+		fmt.Fprintf(s.out, "%s CANCEL id=%d\n", prefix, e.OrderID)
+	case persistence.EventResetSession:
+		fmt.Fprintf(s.out, "%s RESET  session\n", prefix)
+	case persistence.EventRejectOrder:
+		fmt.Fprintf(s.out, "%s REJECT id=%d %s\n", prefix, e.Order.ID, e.Reject)
+	default:
+		fmt.Fprintf(s.out, "%s UNKNOWN type=%d\n", prefix, e.Type)
+	}
+}
+
+// book prints the price levels and orders of an order book
+func (s *session) book(symbolID uint32, depth int) {
+	ob := s.replayer.MarketManager().GetOrderBook(symbolID)
+	if ob == nil {
+		fmt.Fprintf(s.out, "Order book %d not found\n", symbolID)
+		return
+	}
+
+	fmt.Fprintf(s.out, "%s at #%d", ob, s.replayer.Position())
+	if ob.IsCrossed() {
+		fmt.Fprint(s.out, " CROSSED")
+	}
+	fmt.Fprintln(s.out)
+
+	stats := ob.SessionStats()
+	if stats.Trades > 0 {
+		fmt.Fprintf(s.out, "Session: %d trades, volume %d, O %d H %d L %d C %d\n",
+			stats.Trades, stats.Volume, stats.Open, stats.High, stats.Low, stats.Last)
+	}
+
+	// Asks are printed from the highest shown level down to the best ask
+	asks := levels(ob.Asks(), depth)
+	for i := len(asks) - 1; i >= 0; i-- {
+		s.level("ASK", asks[i])
+	}
+	fmt.Fprintln(s.out, "  ---")
+	for _, level := range levels(ob.Bids(), depth) {
+		s.level("BID", level)
+	}
+}
+
+// level prints a price level followed by its orders in queue order
+func (s *session) level(side string, level *matching.LevelNode) {
+	fmt.Fprintf(s.out, "  %s %10d  total %d visible %d orders %d\n",
+		side, level.Price, level.TotalVolume, level.VisibleVolume, level.Orders)
+	for order := level.OrderList.Front(); order != nil; order = order.Next {
+		fmt.Fprintf(s.out, "        id=%d leaves=%d executed=%d\n",
+			order.ID, order.LeavesQuantity, order.ExecutedQuantity)
+		s.amendments(order)
+	}
+}
+
+// amendments prints the amendment history of an order
+func (s *session) amendments(order *matching.OrderNode) {
+	for _, a := range order.Amendments() {
+		fmt.Fprintf(s.out, "          %s %s id=%d price %d->%d qty %d->%d leaves %d->%d\n",
+			formatTime(a.Timestamp), a.Type, a.OrderID, a.Price, a.NewPrice,
+			a.Quantity, a.NewQuantity, a.LeavesQuantity, a.NewLeavesQuantity)
+	}
+}
+
+// levels returns up to depth levels of a tree, best first
+func levels(tree *matching.AVLTree, depth int) []*matching.LevelNode {
+	var result []*matching.LevelNode
+	tree.ForEach(func(level *matching.LevelNode) bool {
+		result = append(result, level)
+		return depth < 0 || len(result) < depth
+	})
+	return result
+}
+
+// info prints the current position and a summary of every order book
+func (s *session) info() {
+	fmt.Fprintf(s.out, "Position %d of %d events\n", s.replayer.Position(), s.replayer.Len())
+
+	mm := s.replayer.MarketManager()
+	ids := make([]uint32, 0, len(mm.OrderBooks()))
+	for id := range mm.OrderBooks() {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		ob := mm.GetOrderBook(id)
+		bid, ask := "-", "-"
+		if best := ob.BestBid(); best != nil {
+			bid = fmt.Sprintf("%d x %d", best.Price, best.TotalVolume)
+		}
+		if best := ob.BestAsk(); best != nil {
+			ask = fmt.Sprintf("%d x %d", best.Price, best.TotalVolume)
+		}
+		fmt.Fprintf(s.out, "  %-8s (%d) bid %s  ask %s  trades %d\n",
+			ob.Symbol().Name, id, bid, ask, ob.SessionStats().Trades)
+	}
+}
+
+// audit checks the invariants of the engine state and prints the violations
+func (s *session) audit() {
+	r := persistence.Audit(s.replayer.MarketManager())
+	fmt.Fprintf(s.out, "Audited %d books, %d levels and %d orders: %d violations\n",
+		r.Books, r.Levels, r.Orders, len(r.Violations))
+	for _, v := range r.Violations {
+		fmt.Fprintf(s.out, "  %s\n", v)
+	}
+}
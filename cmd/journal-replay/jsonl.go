@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tienpsm/go-trader/persistence"
+)
+
+// runExport implements the export subcommand
+func runExport(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s export <journal> > events.jsonl\n", os.Args[0])
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if _, err := persistence.ExportJSONL(fs.Arg(0), out); err != nil {
+		fmt.Fprintf(os.Stderr, "journal-replay: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runImport implements the import subcommand
+func runImport(args []string, in io.Reader) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	noLock := fs.Bool("no-lock", false, "replace the journal even if another process has it open")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s import [-no-lock] <journal> < events.jsonl\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "The journal is replaced once every event has been imported.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	n, err := persistence.ImportJSONLWithOptions(in, fs.Arg(0), persistence.JournalOptions{NoLock: *noLock})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "journal-replay: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "imported %d events\n", n)
+	return 0
+}
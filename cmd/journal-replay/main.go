@@ -0,0 +1,362 @@
+// Command journal-replay replays a matching engine journal step by step to
+// inspect the order books at any point of a session.
+//
+// Usage:
+//
+//	journal-replay [flags] <journal>
+//
+// The replay starts from the newest snapshot in -snapshots taken before the
+// first journal event. Without snapshots, an order book is created for every
+// symbol referenced by the journal.
+//
+// In interactive mode the following commands are read from standard input:
+//
+//	next [n]              apply the next n events (default 1), printing trades
+//	prev [n]              go back n events (default 1)
+//	seek <seq>            go to sequence number seq (0 is the base snapshot)
+//	time <ts>             go to the last event at or before ts (RFC 3339 or Unix ns)
+//	events [from] [n]     list n events (default 10) starting at from
+//	book <symbol> [depth] dump the order book of a symbol
+//	order <id>            show an order
+//	info                  show the current position and book summary
+//	audit                 check the invariants of the engine state
+//	quit                  exit
+//
+// With -seek and -dump, the tool seeks to a sequence number, dumps the order
+// book of a symbol and exits without reading commands.
+//
+// The diff subcommand compares two snapshots instead, for instance those of a
+// primary and a standby, or the snapshots before and after a rollover:
+//
+//	journal-replay diff <snapshot> <snapshot>
+//
+// Each argument is a snapshot file or a snapshot directory, whose newest
+// snapshot is used. Added, removed and changed symbols and orders are printed
+// with the fields that differ, and the exit status is 1 when the snapshots
+// differ.
+//
+// The export and import subcommands convert a journal to JSON Lines, one
+// event per line, and back, to inspect or edit it or to carry it across
+// binary format versions:
+//
+//	journal-replay export <journal> > events.jsonl
+//	journal-replay import [-no-lock] <journal> < events.jsonl
+//
+// Import fails while a trader-server has the journal open, unless -no-lock is
+// given to repair the journal of a hung process.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/persistence"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "diff":
+			os.Exit(runDiff(os.Args[2:], os.Stdout))
+		case "export":
+			os.Exit(runExport(os.Args[2:], os.Stdout))
+		case "import":
+			os.Exit(runImport(os.Args[2:], os.Stdin))
+		}
+	}
+
+	snapshots := flag.String("snapshots", "", "snapshot directory containing the base snapshot")
+	seek := flag.Int("seek", -1, "sequence number to seek to before dumping")
+	dump := flag.Int("dump", -1, "symbol ID to dump after -seek, then exit")
+	depth := flag.Int("depth", 10, "number of price levels to dump per side")
+	rule := flag.String("price-rule", "resting", "trade price rule used by the engine (resting, midpoint, aggressor)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <journal>\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s diff <snapshot> <snapshot>\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s export|import <journal>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	priceRule, err := parsePriceRule(*rule)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "journal-replay: %v\n", err)
+		os.Exit(2)
+	}
+
+	s, err := newSession(flag.Arg(0), *snapshots, priceRule, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "journal-replay: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *dump >= 0 {
+		if *seek >= 0 {
+			if err := s.seek(*seek); err != nil {
+				fmt.Fprintf(os.Stderr, "journal-replay: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		s.book(uint32(*dump), *depth)
+		return
+	}
+
+	if *seek >= 0 {
+		if err := s.seek(*seek); err != nil {
+			fmt.Fprintf(os.Stderr, "journal-replay: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	s.info()
+	s.run(os.Stdin, *depth)
+}
+
+// parsePriceRule converts a -price-rule value into a matching.PriceRule
+func parsePriceRule(name string) (matching.PriceRule, error) {
+	for _, rule := range []matching.PriceRule{matching.PriceRuleResting, matching.PriceRuleMidpoint, matching.PriceRuleAggressor} {
+		if strings.EqualFold(rule.String(), name) {
+			return rule, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown price rule %q", name)
+}
+
+// amendmentDepth is the number of amendments kept per order for dumps
+const amendmentDepth = 16
+
+// session is an interactive replay session
+type session struct {
+	replayer *persistence.Replayer
+	handler  *tradePrinter
+	out      io.Writer
+}
+
+// newSession opens the journal and prepares the replay
+func newSession(journal, snapshots string, rule matching.PriceRule, out io.Writer) (*session, error) {
+	handler := &tradePrinter{out: out}
+	create := func() *matching.MarketManager {
+		mm := matching.NewMarketManagerWithHandler(handler)
+		mm.EnableMatching()
+		mm.SetPriceRule(rule)
+		mm.EnableAmendmentHistory(amendmentDepth)
+		return mm
+	}
+
+	r, err := persistence.OpenReplayer(journal, snapshots, create)
+	if err != nil {
+		return nil, err
+	}
+	if base := r.Base(); base != nil {
+		fmt.Fprintf(out, "Base snapshot: %s (%d symbols, %d orders)\n",
+			formatTime(base.Timestamp), len(base.Symbols), len(base.Orders))
+	}
+	return &session{replayer: r, handler: handler, out: out}, nil
+}
+
+// run reads and executes commands until quit or end of input
+func (s *session) run(in io.Reader, depth int) {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprintf(s.out, "[%d/%d]> ", s.replayer.Position(), s.replayer.Len())
+		if !scanner.Scan() {
+			fmt.Fprintln(s.out)
+			return
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if err := s.execute(fields[0], fields[1:], depth); err != nil {
+			if err == io.EOF {
+				return
+			}
+			fmt.Fprintf(s.out, "error: %v\n", err)
+		}
+	}
+}
+
+// execute runs a single command; io.EOF requests exit
+func (s *session) execute(cmd string, args []string, depth int) error {
+	arg := func(i int, def int) (int, error) {
+		if i >= len(args) {
+			return def, nil
+		}
+		return strconv.Atoi(args[i])
+	}
+
+	switch cmd {
+	case "n", "next":
+		n, err := arg(0, 1)
+		if err != nil {
+			return err
+		}
+		return s.next(n)
+	case "p", "prev":
+		n, err := arg(0, 1)
+		if err != nil {
+			return err
+		}
+		return s.seek(max(s.replayer.Position()-n, 0))
+	case "s", "seek":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: seek <seq>")
+		}
+		seq, err := arg(0, 0)
+		if err != nil {
+			return err
+		}
+		return s.seek(seq)
+	case "t", "time":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: time <RFC 3339 time or Unix nanoseconds>")
+		}
+		ts, err := parseTime(args[0])
+		if err != nil {
+			return err
+		}
+		s.handler.quiet = true
+		defer func() { s.handler.quiet = false }()
+		if err := s.replayer.SeekTime(ts); err != nil {
+			return err
+		}
+		s.current()
+	case "e", "events":
+		from, err := arg(0, s.replayer.Position()+1)
+		if err != nil {
+			return err
+		}
+		n, err := arg(1, 10)
+		if err != nil {
+			return err
+		}
+		for seq := from; seq < from+n; seq++ {
+			e, ok := s.replayer.Event(seq)
+			if !ok {
+				break
+			}
+			s.event(seq, e)
+		}
+	case "b", "book":
+		if len(args) < 1 {
+			return fmt.Errorf("usage: book <symbol> [depth]")
+		}
+		id, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil {
+			return err
+		}
+		d, err := arg(1, depth)
+		if err != nil {
+			return err
+		}
+		s.book(uint32(id), d)
+	case "o", "order":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: order <id>")
+		}
+		id, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return err
+		}
+		node := s.replayer.MarketManager().GetOrder(id)
+		if node == nil {
+			return fmt.Errorf("order %d not found", id)
+		}
+		fmt.Fprintln(s.out, node.Order.String())
+		s.amendments(node)
+	case "i", "info":
+		s.info()
+	case "a", "audit":
+		s.audit()
+	case "q", "quit", "exit":
+		return io.EOF
+	case "h", "help", "?":
+		fmt.Fprintln(s.out, "Commands: next [n], prev [n], seek <seq>, time <ts>, events [from] [n], book <symbol> [depth], order <id>, info, audit, quit")
+	default:
+		return fmt.Errorf("unknown command %q (try help)", cmd)
+	}
+	return nil
+}
+
+// next applies up to n events, printing each event and its trades
+func (s *session) next(n int) error {
+	for i := 0; i < n; i++ {
+		// Print the event first so its trades follow it
+		seq := s.replayer.Position() + 1
+		e, ok := s.replayer.Event(seq)
+		if !ok {
+			fmt.Fprintln(s.out, "End of journal")
+			return nil
+		}
+		s.event(seq, e)
+		if _, _, err := s.replayer.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seek moves to a sequence number without printing intermediate trades
+func (s *session) seek(seq int) error {
+	s.handler.quiet = true
+	defer func() { s.handler.quiet = false }()
+	if err := s.replayer.Seek(seq); err != nil {
+		return err
+	}
+	s.current()
+	return nil
+}
+
+// current prints the last applied event
+func (s *session) current() {
+	seq := s.replayer.Position()
+	if e, ok := s.replayer.Event(seq); ok {
+		s.event(seq, e)
+	} else {
+		fmt.Fprintln(s.out, "At base snapshot")
+	}
+}
+
+// tradePrinter prints trades while stepping through events
+type tradePrinter struct {
+	matching.DefaultMarketHandler
+	out   io.Writer
+	quiet bool
+}
+
+// OnTrade prints a trade unless the replay is seeking
+func (h *tradePrinter) OnTrade(trade matching.Trade) {
+	if h.quiet {
+		return
+	}
+	fmt.Fprintf(h.out, "    TRADE symbol=%d buy=%d sell=%d %d @ %d aggressor=%s\n",
+		trade.SymbolID, trade.BuyOrderID, trade.SellOrderID, trade.Quantity, trade.Price, trade.Aggressor)
+}
+
+// parseTime parses an RFC 3339 time or Unix nanoseconds
+func parseTime(value string) (int64, error) {
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ts, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return t.UnixNano(), nil
+}
+
+// formatTime formats Unix nanoseconds for display
+func formatTime(ts int64) string {
+	return time.Unix(0, ts).UTC().Format("2006-01-02T15:04:05.000000000Z")
+}
@@ -0,0 +1,203 @@
+// Command trader-repl is an interactive shell to enter orders and inspect
+// order books, against a local engine or a running trader-server.
+//
+// Usage:
+//
+//	trader-repl [flags]
+//
+// Without -url, the shell runs a local persisted engine trading the id:name
+// symbols of -symbols, keeping its journal, snapshots and logs in -data (a
+// temporary directory removed on exit if empty):
+//
+//	trader-repl -symbols 1:AAPL,2:MSFT
+//
+// With -url, it enters orders as a participant of a trader-server:
+//
+//	trader-repl -url http://localhost:8080 -participant 7
+//
+// Type help at the prompt for the list of commands.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tienpsm/go-trader/client"
+	"github.com/tienpsm/go-trader/gateway"
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/persistence"
+)
+
+func main() {
+	serverURL := flag.String("url", "", "base URL of a trader-server, for example http://localhost:8080 (default a local engine)")
+	participant := flag.Uint("participant", 1, "participant ID of the session")
+	data := flag.String("data", "", "directory of the local engine state (default a temporary directory)")
+	symbols := flag.String("symbols", "1:AAPL", "comma-separated id:name symbols of the local engine")
+	flag.Parse()
+
+	if err := run(*serverURL, uint32(*participant), *data, *symbols); err != nil {
+		fmt.Fprintf(os.Stderr, "trader-repl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run starts the local engine if needed and reads commands from standard
+// input until it ends or the quit command
+func run(serverURL string, participant uint32, data, symbols string) error {
+	var snapshot func() error
+	if serverURL == "" {
+		local, err := startLocal(data, symbols)
+		if err != nil {
+			return err
+		}
+		defer local.close()
+		serverURL = local.url
+		snapshot = local.snapshot
+		fmt.Printf("Local engine in %s\n", local.dir)
+	}
+
+	sh := newShell(os.Stdout, snapshot)
+	c, err := client.New(client.Config{URL: serverURL, Participant: participant, OnReport: sh.report})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	sh.client = c
+
+	fmt.Println(`Type "help" for the list of commands.`)
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("trader> ")
+		if !in.Scan() {
+			fmt.Println()
+			return in.Err()
+		}
+		if !sh.exec(in.Text()) {
+			return nil
+		}
+	}
+}
+
+// local is an engine with a gateway served on the loopback interface
+type local struct {
+	dir     string
+	temp    bool
+	url     string
+	manager *persistence.Manager
+	reports *gateway.ReportLog
+	server  *gateway.Server
+	http    *http.Server
+}
+
+// startLocal starts a persisted engine with the given symbols, recovering the
+// state kept in dir
+func startLocal(dir, symbolList string) (*local, error) {
+	symbols, err := parseSymbols(symbolList)
+	if err != nil {
+		return nil, err
+	}
+	if len(symbols) == 0 {
+		return nil, errors.New("no symbols")
+	}
+	l := &local{dir: dir}
+	if dir == "" {
+		if l.dir, err = os.MkdirTemp("", "trader-repl-"); err != nil {
+			return nil, err
+		}
+		l.temp = true
+	} else if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	mm := matching.NewMarketManager()
+	mm.EnableMatching()
+	for _, symbol := range symbols {
+		mm.AddSymbol(symbol)
+		mm.AddOrderBook(symbol)
+	}
+	l.manager, err = persistence.NewManagerWithRecovery(mm, filepath.Join(l.dir, "engine.journal"), filepath.Join(l.dir, "snapshots"))
+	if err != nil {
+		l.close()
+		return nil, err
+	}
+	marketData, err := persistence.OpenMarketDataLog(filepath.Join(l.dir, "marketdata.log"))
+	if err != nil {
+		l.close()
+		return nil, err
+	}
+	l.manager.AttachMarketDataLog(marketData)
+	if l.reports, err = gateway.OpenReportLog(filepath.Join(l.dir, "reports.log")); err != nil {
+		l.close()
+		return nil, err
+	}
+	l.server = gateway.NewServer(l.manager, l.reports)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		l.close()
+		return nil, err
+	}
+	l.url = "http://" + listener.Addr().String()
+	l.http = &http.Server{Handler: l.server.Routes()}
+	go l.http.Serve(listener)
+	return l, nil
+}
+
+// snapshot writes a snapshot of the engine
+func (l *local) snapshot() error {
+	errCh := make(chan error, 1)
+	l.manager.TakeSnapshot(errCh)
+	return <-errCh
+}
+
+// close stops the engine, removing its directory if it is temporary
+func (l *local) close() {
+	if l.http != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_ = l.http.Shutdown(ctx)
+		cancel()
+	}
+	if l.server != nil {
+		l.server.Close()
+	}
+	if l.reports != nil {
+		l.reports.Close()
+	}
+	if l.manager != nil {
+		l.manager.Close()
+	}
+	if l.temp {
+		os.RemoveAll(l.dir)
+	}
+}
+
+// parseSymbols parses a comma-separated list of id:name pairs
+func parseSymbols(list string) ([]matching.Symbol, error) {
+	var symbols []matching.Symbol
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, name, ok := strings.Cut(field, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid symbol %q, want id:name", field)
+		}
+		n, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid symbol ID %q", id)
+		}
+		symbols = append(symbols, matching.NewSymbol(uint32(n), name))
+	}
+	return symbols, nil
+}
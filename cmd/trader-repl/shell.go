@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tienpsm/go-trader/client"
+	"github.com/tienpsm/go-trader/gateway"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// requestTimeout bounds the wait for the answer to a command
+const requestTimeout = 5 * time.Second
+
+// maxTrades is the number of recent trades kept per symbol
+const maxTrades = 1000
+
+// replayWait is how long the first trades command of a symbol waits for the
+// logged trades to arrive
+const replayWait = 200 * time.Millisecond
+
+const help = `Commands:
+  buy SYMBOL QTY [PRICE [TIF]]    enter a buy order, a market order without PRICE
+  sell SYMBOL QTY [PRICE [TIF]]   enter a sell order (TIF is gtc, ioc, fok, aon or day)
+  cancel ID                       cancel an order
+  modify ID PRICE QTY             replace an order with a new price and quantity
+  orders                          list the open orders of the session
+  book SYMBOL [DEPTH]             show the order book (default 10 levels)
+  trades SYMBOL [N]               show the last trades (default 10)
+  snapshot                        write a snapshot of the local engine
+  help                            show this help
+  quit                            leave the shell`
+
+// shell runs the commands of an order entry session. Orders are numbered by
+// the shell; their client order IDs carry a prefix unique to the session.
+type shell struct {
+	client   *client.Client
+	out      io.Writer
+	snapshot func() error
+	prefix   string
+
+	mu     sync.Mutex
+	nextID int
+	// replacements numbers the client order IDs of replacement orders
+	replacements int
+	// clientIDs maps the shell ID of an order to its current client order ID
+	clientIDs map[int]string
+	// ids maps every client order ID of the session to its shell ID
+	ids map[string]int
+	// open is the last report of each open order by shell ID
+	open map[int]gateway.Report
+	// trades are the recent trades of each subscribed symbol
+	trades map[string][]gateway.MarketData
+}
+
+// newShell creates a shell writing to out. snapshot is nil without a local
+// engine.
+func newShell(out io.Writer, snapshot func() error) *shell {
+	return &shell{
+		out:       out,
+		snapshot:  snapshot,
+		prefix:    strconv.FormatInt(time.Now().UnixNano(), 36) + "-",
+		nextID:    1,
+		clientIDs: make(map[int]string),
+		ids:       make(map[string]int),
+		open:      make(map[int]gateway.Report),
+		trades:    make(map[string][]gateway.MarketData),
+	}
+}
+
+// exec runs a command line and returns false if the shell should exit
+func (sh *shell) exec(line string) bool {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return true
+	}
+	var err error
+	switch strings.ToLower(args[0]) {
+	case "buy":
+		err = sh.submit(matching.OrderSideBuy, args[1:])
+	case "sell":
+		err = sh.submit(matching.OrderSideSell, args[1:])
+	case "cancel":
+		err = sh.cancel(args[1:])
+	case "modify":
+		err = sh.modify(args[1:])
+	case "orders":
+		sh.orders()
+	case "book":
+		err = sh.book(args[1:])
+	case "trades":
+		err = sh.showTrades(args[1:])
+	case "snapshot":
+		err = sh.takeSnapshot()
+	case "help", "?":
+		fmt.Fprintln(sh.out, help)
+	case "quit", "exit":
+		return false
+	default:
+		err = fmt.Errorf("unknown command %q, try help", args[0])
+	}
+	var reject *client.RejectError
+	if err != nil && !errors.As(err, &reject) {
+		// Rejections were printed with the other reports
+		fmt.Fprintf(sh.out, "error: %v\n", err)
+	}
+	return true
+}
+
+// submit enters a new order
+func (sh *shell) submit(side matching.OrderSide, args []string) error {
+	if len(args) < 2 || len(args) > 4 {
+		return errors.New("usage: buy|sell SYMBOL QTY [PRICE [TIF]]")
+	}
+	order := client.Order{Symbol: args[0], Side: side, Market: len(args) == 2}
+	var err error
+	if order.Quantity, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+		return fmt.Errorf("invalid quantity %q", args[1])
+	}
+	if len(args) > 2 {
+		if order.Price, err = strconv.ParseUint(args[2], 10, 64); err != nil {
+			return fmt.Errorf("invalid price %q", args[2])
+		}
+	}
+	if len(args) > 3 {
+		if order.TimeInForce, err = parseTimeInForce(args[3]); err != nil {
+			return err
+		}
+	}
+	sh.subscribe(order.Symbol)
+
+	sh.mu.Lock()
+	id := sh.nextID
+	sh.nextID++
+	order.ClientOrderID = sh.prefix + strconv.Itoa(id)
+	sh.clientIDs[id] = order.ClientOrderID
+	sh.ids[order.ClientOrderID] = id
+	sh.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	_, err = sh.client.SubmitOrder(ctx, order)
+	return err
+}
+
+// cancel cancels an order
+func (sh *shell) cancel(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: cancel ID")
+	}
+	clientID, err := sh.lookup(args[0])
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	_, err = sh.client.CancelOrder(ctx, clientID)
+	return err
+}
+
+// modify replaces an order, which keeps its shell ID
+func (sh *shell) modify(args []string) error {
+	if len(args) != 3 {
+		return errors.New("usage: modify ID PRICE QTY")
+	}
+	origID, err := sh.lookup(args[0])
+	if err != nil {
+		return err
+	}
+	price, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid price %q", args[1])
+	}
+	quantity, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid quantity %q", args[2])
+	}
+
+	sh.mu.Lock()
+	id := sh.ids[origID]
+	sh.replacements++
+	clientID := sh.prefix + strconv.Itoa(id) + "." + strconv.Itoa(sh.replacements)
+	sh.ids[clientID] = id
+	sh.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	_, err = sh.client.ModifyOrder(ctx, origID, clientID, price, quantity)
+	return err
+}
+
+// lookup returns the current client order ID of a shell ID
+func (sh *shell) lookup(arg string) (string, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+	if err != nil {
+		return "", fmt.Errorf("invalid order ID %q", arg)
+	}
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	clientID, ok := sh.clientIDs[id]
+	if !ok {
+		return "", fmt.Errorf("unknown order #%d", id)
+	}
+	return clientID, nil
+}
+
+// report prints an execution report of the session and tracks open orders.
+// Reports of orders entered by earlier sessions are ignored.
+func (sh *shell) report(r gateway.Report) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	id, ok := sh.ids[r.ClientOrderID]
+	if !ok {
+		return
+	}
+	switch r.Type {
+	case gateway.ReportAccepted:
+		sh.open[id] = r
+		fmt.Fprintf(sh.out, "#%d accepted: %s\n", id, formatOrder(r))
+	case gateway.ReportReplaced:
+		sh.clientIDs[id] = r.ClientOrderID
+		sh.open[id] = r
+		fmt.Fprintf(sh.out, "#%d replaced: %s\n", id, formatOrder(r))
+	case gateway.ReportFill:
+		if r.LeavesQuantity > 0 {
+			sh.open[id] = r
+		} else {
+			delete(sh.open, id)
+		}
+		fmt.Fprintf(sh.out, "#%d fill: %d @ %d, leaves %d\n", id, r.LastQuantity, r.LastPrice, r.LeavesQuantity)
+	case gateway.ReportCanceled:
+		delete(sh.open, id)
+		fmt.Fprintf(sh.out, "#%d canceled, leaves %d\n", id, r.LeavesQuantity)
+	case gateway.ReportRejected:
+		fmt.Fprintf(sh.out, "#%d rejected: %s\n", id, r.Reason)
+	}
+}
+
+// orders lists the open orders of the session
+func (sh *shell) orders() {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if len(sh.open) == 0 {
+		fmt.Fprintln(sh.out, "No open orders")
+		return
+	}
+	ids := make([]int, 0, len(sh.open))
+	for id := range sh.open {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		r := sh.open[id]
+		fmt.Fprintf(sh.out, "#%-4d %s, executed %d, leaves %d\n", id, formatOrder(r), r.ExecutedQuantity, r.LeavesQuantity)
+	}
+}
+
+// book prints the depth of a symbol, bids and asks side by side
+func (sh *shell) book(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("usage: book SYMBOL [DEPTH]")
+	}
+	depth := 10
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid depth %q", args[1])
+		}
+		depth = n
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	d, err := sh.client.Depth(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(sh.out, "%s (sequence %d)\n", d.Symbol, d.Sequence)
+	fmt.Fprint(sh.out, matching.RenderLadder(ladderLevels(d.Bids), ladderLevels(d.Asks), depth))
+	return nil
+}
+
+// ladderLevels converts depth levels for rendering
+func ladderLevels(levels []gateway.DepthLevel) []matching.Level {
+	result := make([]matching.Level, len(levels))
+	for i, l := range levels {
+		result[i] = matching.Level{Price: l.Price, TotalVolume: l.Volume, VisibleVolume: l.Volume, Orders: l.Orders}
+	}
+	return result
+}
+
+// showTrades prints the last trades of a symbol, most recent last
+func (sh *shell) showTrades(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("usage: trades SYMBOL [N]")
+	}
+	n := 10
+	if len(args) == 2 {
+		v, err := strconv.Atoi(args[1])
+		if err != nil || v <= 0 {
+			return fmt.Errorf("invalid count %q", args[1])
+		}
+		n = v
+	}
+	if first, err := sh.subscribe(args[0]); err != nil {
+		return err
+	} else if first {
+		time.Sleep(replayWait)
+	}
+
+	sh.mu.Lock()
+	trades := sh.trades[args[0]]
+	trades = trades[max(len(trades)-n, 0):]
+	sh.mu.Unlock()
+	if len(trades) == 0 {
+		fmt.Fprintln(sh.out, "No trades")
+		return nil
+	}
+	for _, t := range trades {
+		at := time.Unix(0, t.Timestamp).Format("15:04:05.000")
+		fmt.Fprintf(sh.out, "%s %8d @ %-10d %s\n", at, t.Quantity, t.Price, t.Aggressor)
+	}
+	return nil
+}
+
+// subscribe starts collecting the trades of a symbol from the start of the
+// market data log, and returns true for a new subscription
+func (sh *shell) subscribe(symbol string) (bool, error) {
+	sh.mu.Lock()
+	_, ok := sh.trades[symbol]
+	sh.mu.Unlock()
+	if ok {
+		return false, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	// The subscription outlives the dial context, so it ends with the client
+	ch, err := sh.client.SubscribeMarketData(context.WithoutCancel(ctx), symbol, 1)
+	if err != nil {
+		return false, err
+	}
+	sh.mu.Lock()
+	sh.trades[symbol] = nil
+	sh.mu.Unlock()
+	go func() {
+		for md := range ch {
+			if md.Type != gateway.MarketDataTrade {
+				continue
+			}
+			sh.mu.Lock()
+			trades := append(sh.trades[symbol], md)
+			if len(trades) > maxTrades {
+				trades = trades[len(trades)-maxTrades:]
+			}
+			sh.trades[symbol] = trades
+			sh.mu.Unlock()
+		}
+	}()
+	return true, nil
+}
+
+// takeSnapshot writes a snapshot of the local engine
+func (sh *shell) takeSnapshot() error {
+	if sh.snapshot == nil {
+		return errors.New("snapshots are only available with a local engine")
+	}
+	if err := sh.snapshot(); err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, "Snapshot written")
+	return nil
+}
+
+// formatOrder describes the order of a report
+func formatOrder(r gateway.Report) string {
+	if r.Price == 0 {
+		return fmt.Sprintf("%s %d %s at market", r.Side, r.Quantity, r.Symbol)
+	}
+	return fmt.Sprintf("%s %d %s @ %d", r.Side, r.Quantity, r.Symbol, r.Price)
+}
+
+// parseTimeInForce converts a time in force argument
+func parseTimeInForce(s string) (matching.OrderTimeInForce, error) {
+	switch strings.ToLower(s) {
+	case "gtc":
+		return matching.OrderTimeInForceGTC, nil
+	case "ioc":
+		return matching.OrderTimeInForceIOC, nil
+	case "fok":
+		return matching.OrderTimeInForceFOK, nil
+	case "aon":
+		return matching.OrderTimeInForceAON, nil
+	case "day":
+		return matching.OrderTimeInForceDay, nil
+	default:
+		return 0, fmt.Errorf("invalid time in force %q", s)
+	}
+}
@@ -0,0 +1,264 @@
+// Command trader-server runs a persisted matching engine with WebSocket order
+// entry.
+//
+// Usage:
+//
+//	trader-server [flags]
+//
+// The server keeps its journal, snapshots, market data and execution reports
+// in -data and recovers from them on start. Symbols are given as id:name pairs:
+//
+//	trader-server -data data -symbols 1:AAPL,2:MSFT
+//
+// With -config, the settings are read from a TOML or JSON file instead of the
+// flags, see the config package. The symbols of the file are reloaded when it
+// changes; the other sections take effect on restart:
+//
+//	trader-server -config trader.toml
+//
+// Participants connect to ws://<addr>/orders?participant=<id> and exchange the
+// JSON messages of the gateway package. Reconnecting clients pass
+// last_sequence=<n> to receive the execution reports they missed. Market data
+// is streamed from ws://<addr>/marketdata?symbol=<name> and depth snapshots
+// are served at http://<addr>/depth?symbol=<name>. Liveness and readiness are
+// served at http://<addr>/healthz and http://<addr>/readyz; readiness fails
+// when the last snapshot is older than -max-snapshot-age or a book has had no
+// market data for -max-book-staleness. With -cancel-on-disconnect, the open
+// orders of a participant are canceled when its connection drops, or when it
+// sends no request, not even a heartbeat, for -session-timeout. A retried
+// submit or modify is answered with the report of the original order, for
+// client order IDs remembered for -dedup-window. Engine order
+// IDs are allocated by -order-ids, sequential, partitioned, snowflake or
+// scrambled, with the partition, node or seed -order-id-node; servers sharing
+// an ID space use distinct partitions or nodes.
+//
+// With -admin-token, or $TRADER_ADMIN_TOKEN, operators manage the server at
+// http://<addr>/admin/ with the token as a bearer token: symbols are listed,
+// added and deleted at /admin/symbols, trading is halted and resumed per
+// symbol or market-wide at /admin/halt and /admin/resume, and /admin/snapshot
+// and /admin/rotate write a snapshot and rotate the journal. Symbol changes
+// are snapshotted, but the symbols of -symbols or the configuration file are
+// added back on restart; halts last until restart.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tienpsm/go-trader/config"
+	"github.com/tienpsm/go-trader/gateway"
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/persistence"
+)
+
+// reloadInterval is the time between checks of the configuration file
+const reloadInterval = 2 * time.Second
+
+func main() {
+	configPath := flag.String("config", "", "TOML or JSON configuration file, replacing the other flags")
+	addr := flag.String("addr", config.DefaultAddr, "HTTP listen address")
+	data := flag.String("data", config.DefaultDataDir, "directory of the journal, snapshots, market data and report logs")
+	symbols := flag.String("symbols", "", "comma-separated id:name symbols to trade")
+	interval := flag.Duration("snapshot-interval", config.DefaultSnapshotInterval, "time between snapshots, 0 to disable")
+	maxSnapshotAge := flag.Duration("max-snapshot-age", 0, "age of the last snapshot failing readiness, 0 to disable")
+	maxBookStaleness := flag.Duration("max-book-staleness", 0, "time without market data marking a book stale and failing readiness, 0 to disable")
+	cancelOnDisconnect := flag.Bool("cancel-on-disconnect", false, "cancel the open orders of a participant whose connection drops")
+	sessionTimeout := flag.Duration("session-timeout", 0, "time without requests closing a connection, 0 to disable")
+	dedupWindow := flag.Duration("dedup-window", 0, "time client order IDs are remembered to answer retries, 0 for the life of the report log")
+	orderIDs := flag.String("order-ids", "sequential", "order ID allocator: sequential, partitioned, snowflake or scrambled")
+	orderIDNode := flag.Uint64("order-id-node", 0, "partition, node or seed of the order ID allocator")
+	adminToken := flag.String("admin-token", os.Getenv("TRADER_ADMIN_TOKEN"), "bearer token of the admin API, disabled if empty (default $TRADER_ADMIN_TOKEN)")
+	flag.Parse()
+
+	var cfg *config.Config
+	var err error
+	if *configPath != "" {
+		cfg, err = config.Load(*configPath)
+	} else {
+		cfg, err = flagConfig(*data, *symbols, *interval, config.API{
+			Addr:               *addr,
+			MaxSnapshotAge:     config.Duration(*maxSnapshotAge),
+			MaxBookStaleness:   config.Duration(*maxBookStaleness),
+			CancelOnDisconnect: *cancelOnDisconnect,
+			SessionTimeout:     config.Duration(*sessionTimeout),
+			DedupWindow:        config.Duration(*dedupWindow),
+			OrderIDs:           *orderIDs,
+			OrderIDNode:        *orderIDNode,
+			AdminToken:         *adminToken,
+		})
+	}
+	if err == nil {
+		err = run(cfg, *configPath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "trader-server: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// flagConfig builds the configuration of the command line flags
+func flagConfig(data, symbolList string, interval time.Duration, api config.API) (*config.Config, error) {
+	symbols, err := parseSymbols(symbolList)
+	if err != nil {
+		return nil, err
+	}
+	c := &config.Config{
+		Symbols: symbols,
+		Persistence: config.Persistence{
+			Dir:              data,
+			Journal:          filepath.Join(data, "engine.journal"),
+			Snapshots:        filepath.Join(data, "snapshots"),
+			SnapshotInterval: config.Duration(interval),
+		},
+		API: api,
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// run serves order entry until SIGINT or SIGTERM, reloading the symbols of
+// the configuration file at configPath if set
+func run(cfg *config.Config, configPath string) error {
+	if err := os.MkdirAll(cfg.Persistence.Dir, 0o755); err != nil {
+		return err
+	}
+
+	mm := matching.NewMarketManager()
+	mm.EnableMatching()
+	if err := config.ApplySymbols(mm, cfg.Symbols); err != nil {
+		return err
+	}
+	mm.SetOrderIDAllocator(cfg.API.OrderIDAllocator())
+	manager, err := persistence.NewManagerWithOptions(mm, cfg.Persistence.Journal, cfg.Persistence.Snapshots, cfg.Persistence.ManagerOptions())
+	if err != nil {
+		return err
+	}
+	defer manager.Close()
+	marketData, err := persistence.OpenMarketDataLog(filepath.Join(cfg.Persistence.Dir, "marketdata.log"))
+	if err != nil {
+		return err
+	}
+	manager.AttachMarketDataLog(marketData)
+	reports, err := gateway.OpenReportLog(filepath.Join(cfg.Persistence.Dir, "reports.log"))
+	if err != nil {
+		return err
+	}
+	defer reports.Close()
+
+	server := gateway.NewServer(manager, reports)
+	defer server.Close()
+	server.SetHealthConfig(cfg.API.HealthConfig())
+	server.SetRiskLimits(cfg.Risk.Limits())
+	server.SetSessionConfig(cfg.API.SessionConfig())
+	server.SetDedupConfig(cfg.API.DedupConfig())
+	server.SetAdminConfig(cfg.API.AdminConfig())
+	httpServer := &http.Server{Addr: cfg.API.Addr, Handler: server.Routes()}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if interval := time.Duration(cfg.Persistence.SnapshotInterval); interval > 0 {
+		go snapshotLoop(ctx, manager, interval)
+	}
+	go expiryLoop(ctx, manager, expiryInterval)
+	if configPath != "" {
+		go config.Watch(ctx, configPath, reloadInterval, func(c *config.Config, err error) {
+			if err == nil {
+				manager.View(func(mm *matching.MarketManager) {
+					err = config.ApplySymbols(mm, c.Symbols)
+				})
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "trader-server: reload: %v\n", err)
+				return
+			}
+			fmt.Fprintf(os.Stderr, "trader-server: reloaded %d symbols\n", len(c.Symbols))
+		})
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- httpServer.ListenAndServe() }()
+	fmt.Fprintf(os.Stderr, "trader-server: listening on %s\n", cfg.API.Addr)
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return httpServer.Shutdown(shutdown)
+}
+
+// snapshotLoop takes a snapshot every interval until ctx is done
+func snapshotLoop(ctx context.Context, manager *persistence.Manager, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			errCh := make(chan error, 1)
+			manager.TakeSnapshot(errCh)
+			if err := <-errCh; err != nil {
+				fmt.Fprintf(os.Stderr, "trader-server: snapshot: %v\n", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// expiryInterval is the time between purges of the orders that outlived the
+// order_ttl or quote_ttl of their symbol
+const expiryInterval = time.Second
+
+// expiryLoop expires stale orders every interval until ctx is done, through
+// the manager so that the expiries are journalled
+func expiryLoop(ctx context.Context, manager *persistence.Manager, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := manager.ExpireOrders(); err != nil {
+				fmt.Fprintf(os.Stderr, "trader-server: expiry: %v\n", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// parseSymbols parses a comma-separated list of id:name pairs
+func parseSymbols(list string) ([]config.Symbol, error) {
+	var symbols []config.Symbol
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, name, ok := strings.Cut(field, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid symbol %q, want id:name", field)
+		}
+		n, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid symbol ID %q", id)
+		}
+		symbols = append(symbols, config.Symbol{ID: uint32(n), Name: name})
+	}
+	return symbols, nil
+}
@@ -0,0 +1,498 @@
+// Package config loads the configuration of the server and market data
+// pipelines from TOML or JSON files.
+//
+// A configuration file describes the traded symbols, the persistence paths
+// and durability, the market data feeds, the API listener and the pre-trade
+// risk limits:
+//
+//	[persistence]
+//	dir = "data"
+//	durability = "sync"
+//	snapshot_interval = "5m"
+//	snapshot_level = "fastest"
+//	snapshot_rate = 50000000
+//	journal_rejects = true
+//	audit = true
+//
+//	[api]
+//	addr = ":8080"
+//	cancel_on_disconnect = true
+//	session_timeout = "30s"
+//	dedup_window = "24h"
+//	order_ids = "snowflake"
+//	order_id_node = 1
+//
+//	[risk]
+//	max_order_quantity = 100000
+//
+//	[[symbols]]
+//	id = 1
+//	name = "AAPL"
+//	tick_size = 100
+//	min_price = 10000
+//	max_price = 5000000
+//
+//	[[feeds]]
+//	name = "itch-a"
+//	address = "239.1.1.1:30001"
+//	depth_policy = "conflate"
+//
+// Unknown keys are rejected so typos are not silently ignored. The symbol
+// section can be reloaded while the server runs, see Watch and ApplySymbols.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tienpsm/go-trader/gateway"
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/persistence"
+	"github.com/tienpsm/go-trader/pipeline"
+)
+
+// Default settings
+const (
+	DefaultDataDir          = "data"
+	DefaultSnapshotInterval = 5 * time.Minute
+	DefaultAddr             = ":8080"
+)
+
+// Config is the configuration of a server
+type Config struct {
+	Symbols     []Symbol    `json:"symbols"`
+	Persistence Persistence `json:"persistence"`
+	Feeds       []Feed      `json:"feeds"`
+	API         API         `json:"api"`
+	Risk        Risk        `json:"risk"`
+}
+
+// Symbol is a traded symbol and the trading rules of its order book
+type Symbol struct {
+	ID   uint32 `json:"id"`
+	Name string `json:"name"`
+	// TickSize and LotSize are the price and quantity increments, 0 for any
+	TickSize uint64 `json:"tick_size"`
+	LotSize  uint64 `json:"lot_size"`
+	// MinPrice and MaxPrice are the price band, 0 for no limit
+	MinPrice uint64 `json:"min_price"`
+	MaxPrice uint64 `json:"max_price"`
+	// IcebergVariance randomizes refreshed iceberg displays by up to this
+	// percentage either way
+	IcebergVariance uint64 `json:"iceberg_variance"`
+	// Allocation is "fifo" or "pro_rata", with the pro rata top order
+	// carve-out, FIFO share percentage and minimum allocation
+	Allocation       string `json:"allocation"`
+	TopOrderQuantity uint64 `json:"top_order_quantity"`
+	FIFOPercent      uint64 `json:"fifo_percent"`
+	MinAllocation    uint64 `json:"min_allocation"`
+	// CancelInvalid cancels resting orders that violate reloaded rules
+	CancelInvalid bool `json:"cancel_invalid"`
+	// OrderTTL and QuoteTTL expire resting orders and quotes, 0 for none
+	OrderTTL Duration `json:"order_ttl"`
+	QuoteTTL Duration `json:"quote_ttl"`
+	// StopProtection limits triggered stop orders to their stop price plus
+	// or minus this many price points, 0 for none
+	StopProtection uint64 `json:"stop_protection"`
+}
+
+// Persistence configures the journal and snapshots
+type Persistence struct {
+	// Dir holds the journal, snapshots, market data and report logs
+	Dir string `json:"dir"`
+	// Journal and Snapshots override the paths under Dir
+	Journal   string `json:"journal"`
+	Snapshots string `json:"snapshots"`
+	// Durability is "group" for group commit or "sync" for an fsync per event
+	Durability string `json:"durability"`
+	// SnapshotInterval is the time between snapshots, negative to disable
+	SnapshotInterval Duration `json:"snapshot_interval"`
+	// SnapshotLevel is the zstd level of snapshots, "fastest", "default",
+	// "better" or "best"
+	SnapshotLevel string `json:"snapshot_level"`
+	// SnapshotConcurrency is the number of cores compressing a snapshot, 1
+	// by default
+	SnapshotConcurrency int `json:"snapshot_concurrency"`
+	// SnapshotRate limits the snapshot data compressed per second, in bytes,
+	// 0 for no limit
+	SnapshotRate int64 `json:"snapshot_rate"`
+	// JournalRejects also journals the rejected orders, for audit
+	JournalRejects bool `json:"journal_rejects"`
+	// Audit checks the invariants of the recovered state before the server
+	// accepts traffic
+	Audit bool `json:"audit"`
+}
+
+// Feed is a market data feed received by a pipeline
+type Feed struct {
+	Name string `json:"name"`
+	// Address is the multicast group and port, such as 239.1.1.1:30001
+	Address string `json:"address"`
+	// Interface is the network interface joining the group, empty for the
+	// default one
+	Interface string `json:"interface"`
+	// PacketQueue and EventQueue are the queue sizes, 0 for the defaults
+	PacketQueue int `json:"packet_queue"`
+	EventQueue  int `json:"event_queue"`
+	// DepthPolicy is "block", "drop" or "conflate"
+	DepthPolicy string `json:"depth_policy"`
+}
+
+// API configures the HTTP listener of the gateway
+type API struct {
+	Addr string `json:"addr"`
+	// MaxSnapshotAge and MaxBookStaleness are the readiness thresholds, 0 to
+	// disable
+	MaxSnapshotAge   Duration `json:"max_snapshot_age"`
+	MaxBookStaleness Duration `json:"max_book_staleness"`
+	// CancelOnDisconnect cancels the open orders of a participant whose
+	// connection drops, and SessionTimeout drops idle connections, 0 for none
+	CancelOnDisconnect bool     `json:"cancel_on_disconnect"`
+	SessionTimeout     Duration `json:"session_timeout"`
+	// DedupWindow is how long client order IDs are remembered to answer
+	// retried requests, 0 for the life of the report log
+	DedupWindow Duration `json:"dedup_window"`
+	// OrderIDs allocates the engine order IDs: "sequential" by default,
+	// "partitioned", "snowflake" or "scrambled". OrderIDNode is the
+	// partition, node or seed of the allocator
+	OrderIDs    string `json:"order_ids"`
+	OrderIDNode uint64 `json:"order_id_node"`
+	// AdminToken is the bearer token of the admin API, empty to disable it
+	AdminToken string `json:"admin_token"`
+}
+
+// Risk are the pre-trade limits of every participant, 0 for no limit
+type Risk struct {
+	MaxOrderQuantity uint64 `json:"max_order_quantity"`
+	MaxOrderNotional uint64 `json:"max_order_notional"`
+	MaxOpenOrders    int    `json:"max_open_orders"`
+}
+
+// Duration is a time.Duration written as a string such as "5m" or "250ms"
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string, or a number of nanoseconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("invalid duration %s", data)
+		}
+		*d = Duration(n)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Load reads and validates the configuration file at path. Files ending in
+// .json are read as JSON, others as TOML. Missing settings take their
+// defaults.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c *Config
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		c, err = ParseJSON(data)
+	} else {
+		c, err = ParseTOML(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// ParseTOML parses and validates a TOML configuration
+func ParseTOML(data []byte) (*Config, error) {
+	tree, err := parseTOML(string(data))
+	if err != nil {
+		return nil, err
+	}
+	// The tree maps onto the JSON schema, which decodes and checks the types
+	encoded, err := json.Marshal(tree)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJSON(encoded)
+}
+
+// ParseJSON parses and validates a JSON configuration
+func ParseJSON(data []byte) (*Config, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	var c Config
+	if err := d.Decode(&c); err != nil {
+		return nil, err
+	}
+	c.setDefaults()
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// setDefaults fills in missing settings
+func (c *Config) setDefaults() {
+	if c.Persistence.Dir == "" {
+		c.Persistence.Dir = DefaultDataDir
+	}
+	if c.Persistence.Journal == "" {
+		c.Persistence.Journal = filepath.Join(c.Persistence.Dir, "engine.journal")
+	}
+	if c.Persistence.Snapshots == "" {
+		c.Persistence.Snapshots = filepath.Join(c.Persistence.Dir, "snapshots")
+	}
+	if c.Persistence.SnapshotInterval == 0 {
+		c.Persistence.SnapshotInterval = Duration(DefaultSnapshotInterval)
+	}
+	if c.API.Addr == "" {
+		c.API.Addr = DefaultAddr
+	}
+}
+
+// Validate checks the configuration, returning every problem found
+func (c *Config) Validate() error {
+	var errs []error
+	errs = append(errs, ValidateSymbols(c.Symbols))
+	if _, err := persistence.ParseDurability(c.Persistence.Durability); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := persistence.ParseSnapshotLevel(c.Persistence.SnapshotLevel); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Persistence.SnapshotConcurrency < 0 || c.Persistence.SnapshotRate < 0 {
+		errs = append(errs, errors.New("persistence: negative snapshot concurrency or rate"))
+	}
+	names := make(map[string]bool)
+	for i, f := range c.Feeds {
+		if f.Name == "" {
+			errs = append(errs, fmt.Errorf("feed %d: missing name", i))
+		} else if names[f.Name] {
+			errs = append(errs, fmt.Errorf("feed %s: duplicate name", f.Name))
+		}
+		names[f.Name] = true
+		if f.Address == "" {
+			errs = append(errs, fmt.Errorf("feed %s: missing address", f.Name))
+		}
+		if f.PacketQueue < 0 || f.EventQueue < 0 {
+			errs = append(errs, fmt.Errorf("feed %s: negative queue size", f.Name))
+		}
+		if _, err := parseDepthPolicy(f.DepthPolicy); err != nil {
+			errs = append(errs, fmt.Errorf("feed %s: %w", f.Name, err))
+		}
+	}
+	if c.API.MaxSnapshotAge < 0 || c.API.MaxBookStaleness < 0 {
+		errs = append(errs, errors.New("api: negative readiness threshold"))
+	}
+	if c.API.SessionTimeout < 0 {
+		errs = append(errs, errors.New("api: negative session timeout"))
+	}
+	if c.API.DedupWindow < 0 {
+		errs = append(errs, errors.New("api: negative dedup window"))
+	}
+	if _, err := parseOrderIDs(c.API.OrderIDs, c.API.OrderIDNode); err != nil {
+		errs = append(errs, fmt.Errorf("api: %w", err))
+	}
+	if c.Risk.MaxOpenOrders < 0 {
+		errs = append(errs, errors.New("risk: negative max open orders"))
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateSymbols checks that symbol IDs and names are unique and their
+// trading rules consistent
+func ValidateSymbols(symbols []Symbol) error {
+	var errs []error
+	ids := make(map[uint32]bool)
+	names := make(map[string]bool)
+	for _, s := range symbols {
+		if s.Name == "" {
+			errs = append(errs, fmt.Errorf("symbol %d: missing name", s.ID))
+			continue
+		}
+		if ids[s.ID] {
+			errs = append(errs, fmt.Errorf("symbol %s: duplicate ID %d", s.Name, s.ID))
+		}
+		if names[s.Name] {
+			errs = append(errs, fmt.Errorf("symbol %s: duplicate name", s.Name))
+		}
+		ids[s.ID], names[s.Name] = true, true
+		if _, err := parseAllocation(s.Allocation); err != nil {
+			errs = append(errs, fmt.Errorf("symbol %s: %w", s.Name, err))
+		}
+		if err := s.SymbolConfig().Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("symbol %s: %w", s.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Symbol returns the matching symbol
+func (s Symbol) Symbol() matching.Symbol {
+	return matching.NewSymbol(s.ID, s.Name)
+}
+
+// SymbolConfig returns the trading rules of the order book
+func (s Symbol) SymbolConfig() matching.SymbolConfig {
+	allocation, _ := parseAllocation(s.Allocation)
+	return matching.SymbolConfig{
+		TickSize:         s.TickSize,
+		LotSize:          s.LotSize,
+		MinPrice:         s.MinPrice,
+		MaxPrice:         s.MaxPrice,
+		IcebergVariance:  s.IcebergVariance,
+		Allocation:       allocation,
+		TopOrderQuantity: s.TopOrderQuantity,
+		FIFOPercent:      s.FIFOPercent,
+		MinAllocation:    s.MinAllocation,
+		CancelInvalid:    s.CancelInvalid,
+		OrderTTL:         time.Duration(s.OrderTTL),
+		QuoteTTL:         time.Duration(s.QuoteTTL),
+		StopProtection:   s.StopProtection,
+	}
+}
+
+// parseAllocation parses the name of an allocation, FIFO by default
+func parseAllocation(s string) (matching.Allocation, error) {
+	for _, a := range []matching.Allocation{matching.AllocationFIFO, matching.AllocationProRata} {
+		if strings.EqualFold(s, a.String()) {
+			return a, nil
+		}
+	}
+	if s == "" {
+		return matching.AllocationFIFO, nil
+	}
+	return 0, fmt.Errorf("unknown allocation %q", s)
+}
+
+// ManagerOptions returns the options of the persistence manager
+func (p Persistence) ManagerOptions() persistence.ManagerOptions {
+	durability, _ := persistence.ParseDurability(p.Durability)
+	level, _ := persistence.ParseSnapshotLevel(p.SnapshotLevel)
+	return persistence.ManagerOptions{
+		Recover:        true,
+		Durability:     durability,
+		JournalRejects: p.JournalRejects,
+		Audit:          p.Audit,
+		Snapshots: persistence.SnapshotterOptions{
+			Level:       level,
+			Concurrency: p.SnapshotConcurrency,
+			Limiter:     persistence.NewRateLimiter(p.SnapshotRate),
+		},
+	}
+}
+
+// PipelineConfig returns the pipeline configuration of the feed. The
+// session date, strategy broker and callbacks are left to the caller.
+func (f Feed) PipelineConfig() pipeline.Config {
+	policy, _ := parseDepthPolicy(f.DepthPolicy)
+	return pipeline.Config{
+		PacketQueue: f.PacketQueue,
+		EventQueue:  f.EventQueue,
+		DepthPolicy: policy,
+	}
+}
+
+// parseDepthPolicy parses the name of a depth policy, block by default
+func parseDepthPolicy(s string) (pipeline.DepthPolicy, error) {
+	for _, p := range []pipeline.DepthPolicy{pipeline.DepthBlock, pipeline.DepthDrop, pipeline.DepthConflate} {
+		if strings.EqualFold(s, p.String()) {
+			return p, nil
+		}
+	}
+	if s == "" {
+		return pipeline.DepthBlock, nil
+	}
+	return 0, fmt.Errorf("unknown depth policy %q", s)
+}
+
+// HealthConfig returns the readiness thresholds of the gateway
+func (a API) HealthConfig() gateway.HealthConfig {
+	return gateway.HealthConfig{
+		MaxSnapshotAge:   time.Duration(a.MaxSnapshotAge),
+		MaxBookStaleness: time.Duration(a.MaxBookStaleness),
+	}
+}
+
+// SessionConfig returns the gateway session settings
+func (a API) SessionConfig() gateway.SessionConfig {
+	return gateway.SessionConfig{
+		CancelOnDisconnect: a.CancelOnDisconnect,
+		Timeout:            time.Duration(a.SessionTimeout),
+	}
+}
+
+// DedupConfig returns the gateway deduplication settings
+func (a API) DedupConfig() gateway.DedupConfig {
+	return gateway.DedupConfig{Window: time.Duration(a.DedupWindow)}
+}
+
+// AdminConfig returns the gateway admin API settings
+func (a API) AdminConfig() gateway.AdminConfig {
+	return gateway.AdminConfig{Token: a.AdminToken}
+}
+
+// OrderIDAllocator returns the allocator of engine order IDs
+func (a API) OrderIDAllocator() matching.OrderIDAllocator {
+	allocator, _ := parseOrderIDs(a.OrderIDs, a.OrderIDNode)
+	return allocator
+}
+
+// Settings of the order ID allocators
+const (
+	// orderIDPartitionBits is the number of high bits holding the partition
+	orderIDPartitionBits = 16
+)
+
+// orderIDEpoch is the epoch of snowflake order IDs
+var orderIDEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// parseOrderIDs creates the order ID allocator of a name, sequential by
+// default
+func parseOrderIDs(s string, node uint64) (matching.OrderIDAllocator, error) {
+	switch strings.ToLower(s) {
+	case "", "sequential":
+		return matching.NewSequentialIDAllocator(1), nil
+	case "partitioned":
+		if node >= 1<<orderIDPartitionBits {
+			return nil, fmt.Errorf("order ID partition %d above %d", node, 1<<orderIDPartitionBits-1)
+		}
+		return matching.NewPartitionedIDAllocator(node, orderIDPartitionBits), nil
+	case "snowflake":
+		if node > matching.SnowflakeMaxNode {
+			return nil, fmt.Errorf("order ID node %d above %d", node, matching.SnowflakeMaxNode)
+		}
+		return matching.NewSnowflakeIDAllocator(uint16(node), orderIDEpoch), nil
+	case "scrambled":
+		return matching.NewScrambledIDAllocator(node), nil
+	}
+	return nil, fmt.Errorf("unknown order ID allocator %q", s)
+}
+
+// Limits returns the gateway risk limits
+func (r Risk) Limits() gateway.RiskLimits {
+	return gateway.RiskLimits{
+		MaxOrderQuantity: r.MaxOrderQuantity,
+		MaxOrderNotional: r.MaxOrderNotional,
+		MaxOpenOrders:    r.MaxOpenOrders,
+	}
+}
@@ -0,0 +1,263 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/persistence"
+	"github.com/tienpsm/go-trader/pipeline"
+)
+
+const testTOML = `
+# Engine
+[persistence]
+dir = "/var/lib/trader"
+durability = "sync"   # fsync every event
+snapshot_level = "fastest"
+snapshot_rate = 10_000_000
+journal_rejects = true
+audit = true
+
+[api]
+addr = "127.0.0.1:9000"
+max_book_staleness = "30s"
+cancel_on_disconnect = true
+session_timeout = "15s"
+dedup_window = "1h"
+order_ids = "partitioned"
+order_id_node = 3
+admin_token = "secret"
+
+[risk]
+max_order_quantity = 1_000
+max_open_orders = 50
+
+[[symbols]]
+id = 1
+name = "AAPL"
+tick_size = 100
+min_price = 10000
+max_price = 500000
+stop_protection = 500
+
+[[symbols]]
+id = 2
+name = 'MSFT'
+lot_size = 10
+allocation = "pro_rata"
+top_order_quantity = 50
+
+[[feeds]]
+name = "itch \"a\""
+address = "239.1.1.1:30001"
+depth_policy = "conflate"
+event_queue = 8192
+`
+
+func TestParseTOML(t *testing.T) {
+	c, err := ParseTOML([]byte(testTOML))
+	if err != nil {
+		t.Fatalf("ParseTOML: %v", err)
+	}
+	if len(c.Symbols) != 2 || c.Symbols[1].Name != "MSFT" || c.Symbols[1].LotSize != 10 {
+		t.Errorf("Expected AAPL and MSFT, got %+v", c.Symbols)
+	}
+	if cfg := c.Symbols[0].SymbolConfig(); cfg.TickSize != 100 || cfg.MinPrice != 10000 || cfg.MaxPrice != 500000 || cfg.StopProtection != 500 {
+		t.Errorf("Expected the AAPL price band and stop protection, got %+v", cfg)
+	}
+	if cfg := c.Symbols[1].SymbolConfig(); cfg.Allocation != matching.AllocationProRata || cfg.TopOrderQuantity != 50 {
+		t.Errorf("Expected MSFT pro rata allocation, got %+v", cfg)
+	}
+	if c.Persistence.Journal != "/var/lib/trader/engine.journal" || c.Persistence.Snapshots != "/var/lib/trader/snapshots" {
+		t.Errorf("Expected default paths under the data directory, got %+v", c.Persistence)
+	}
+	if opts := c.Persistence.ManagerOptions(); opts.Durability != persistence.DurabilitySync || !opts.Recover || !opts.JournalRejects || !opts.Audit {
+		t.Errorf("Expected sync durability with audited recovery and journaled rejects, got %+v", opts)
+	}
+	if opts := c.Persistence.ManagerOptions().Snapshots; opts.Level.String() != "fastest" || opts.Limiter == nil || opts.Concurrency != 0 {
+		t.Errorf("Expected fastest rate limited snapshots, got %+v", opts)
+	}
+	if time.Duration(c.Persistence.SnapshotInterval) != DefaultSnapshotInterval {
+		t.Errorf("Expected the default snapshot interval, got %v", c.Persistence.SnapshotInterval)
+	}
+	if c.API.Addr != "127.0.0.1:9000" || c.API.HealthConfig().MaxBookStaleness != 30*time.Second {
+		t.Errorf("Expected the API settings, got %+v", c.API)
+	}
+	if session := c.API.SessionConfig(); !session.CancelOnDisconnect || session.Timeout != 15*time.Second {
+		t.Errorf("Expected cancel on disconnect with a 15s timeout, got %+v", session)
+	}
+	if dedup := c.API.DedupConfig(); dedup.Window != time.Hour {
+		t.Errorf("Expected a 1h dedup window, got %+v", dedup)
+	}
+	if ids, ok := c.API.OrderIDAllocator().(*matching.PartitionedIDAllocator); !ok || ids.Partition() != 3 {
+		t.Errorf("Expected order IDs of partition 3, got %+v", c.API.OrderIDAllocator())
+	}
+	if admin := c.API.AdminConfig(); admin.Token != "secret" {
+		t.Errorf("Expected the admin token, got %+v", admin)
+	}
+	if limits := c.Risk.Limits(); limits.MaxOrderQuantity != 1000 || limits.MaxOpenOrders != 50 {
+		t.Errorf("Expected the risk limits, got %+v", limits)
+	}
+	if len(c.Feeds) != 1 || c.Feeds[0].Name != `itch "a"` {
+		t.Fatalf("Expected one feed, got %+v", c.Feeds)
+	}
+	if p := c.Feeds[0].PipelineConfig(); p.DepthPolicy != pipeline.DepthConflate || p.EventQueue != 8192 {
+		t.Errorf("Expected the feed pipeline settings, got %+v", p)
+	}
+}
+
+func TestParseTOML_Syntax(t *testing.T) {
+	tree, err := parseTOML(`
+a = [1, 2.5, "x", true,
+     [false], { b = -3 }, ] # trailing comma
+[t.u]
+"quoted key" = 'C:\path'
+[[t.v]]
+w = 1
+[[t.v]]
+w = 2
+`)
+	if err != nil {
+		t.Fatalf("parseTOML: %v", err)
+	}
+	a := tree["a"].([]any)
+	if len(a) != 6 || a[0] != int64(1) || a[1] != 2.5 || a[2] != "x" || a[3] != true {
+		t.Errorf("Expected the array values, got %v", a)
+	}
+	if b := a[5].(map[string]any)["b"]; b != int64(-3) {
+		t.Errorf("Expected the inline table, got %v", a[5])
+	}
+	table := tree["t"].(map[string]any)
+	if table["u"].(map[string]any)["quoted key"] != `C:\path` {
+		t.Errorf("Expected a literal string, got %v", table["u"])
+	}
+	if v := table["v"].([]any); len(v) != 2 || v[1].(map[string]any)["w"] != int64(2) {
+		t.Errorf("Expected an array of 2 tables, got %v", v)
+	}
+
+	for _, bad := range []string{
+		"a = 1\na = 2",
+		"a = ",
+		"a = \"open",
+		"a = [1, 2",
+		"a = 1 2",
+		"[t\nb = 1",
+		"a = 1\n[a]",
+		"a = nope",
+	} {
+		if _, err := parseTOML(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, bad := range []string{
+		"unknown = 1",
+		"[api]\nport = 80",
+		"[persistence]\ndurability = \"always\"",
+		"[persistence]\nsnapshot_level = \"max\"",
+		"[persistence]\nsnapshot_rate = -1",
+		"[[symbols]]\nid = 1\nname = \"A\"\n[[symbols]]\nid = 1\nname = \"B\"",
+		"[[symbols]]\nid = 1\nname = \"A\"\ntick_size = 3\nmax_price = 10",
+		"[[feeds]]\nname = \"a\"\ndepth_policy = \"skip\"\naddress = \"239.1.1.1:1\"",
+		"[[feeds]]\nname = \"a\"",
+		"[api]\nmax_snapshot_age = \"soon\"",
+		"[[symbols]]\nid = 1\nname = \"A\"\nallocation = \"lifo\"",
+		"[[symbols]]\nid = 1\nname = \"A\"\ntick_size = 100\nstop_protection = 50",
+		"[api]\norder_ids = \"random\"",
+		"[api]\norder_ids = \"snowflake\"\norder_id_node = 1024",
+	} {
+		if _, err := ParseTOML([]byte(bad)); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "trader.json")
+	if err := os.WriteFile(path, []byte(`{"symbols": [{"id": 1, "name": "AAPL"}], "persistence": {"snapshot_interval": "1m"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(c.Symbols) != 1 || time.Duration(c.Persistence.SnapshotInterval) != time.Minute || c.API.Addr != DefaultAddr {
+		t.Errorf("Expected the JSON configuration with defaults, got %+v", c)
+	}
+
+	bad := filepath.Join(dir, "bad.toml")
+	if err := os.WriteFile(bad, []byte("[api\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(bad); err == nil || !strings.Contains(err.Error(), "bad.toml") {
+		t.Errorf("Expected an error naming the file, got %v", err)
+	}
+}
+
+func TestApplySymbols(t *testing.T) {
+	mm := matching.NewMarketManager()
+	if err := ApplySymbols(mm, []Symbol{{ID: 1, Name: "AAPL"}}); err != nil {
+		t.Fatalf("ApplySymbols: %v", err)
+	}
+	mm.AddOrder(*matching.NewLimitOrder(1, 1, matching.OrderSideBuy, 10050, 10))
+
+	err := ApplySymbols(mm, []Symbol{
+		{ID: 1, Name: "AAPL2", TickSize: 100, CancelInvalid: true},
+		{ID: 2, Name: "MSFT", LotSize: 10},
+	})
+	if err != nil {
+		t.Fatalf("ApplySymbols: %v", err)
+	}
+	if ob := mm.GetOrderBookByName("AAPL2"); ob == nil || ob.Config().TickSize != 100 {
+		t.Error("Expected AAPL to be renamed with the new tick size")
+	}
+	if mm.GetOrder(1) != nil {
+		t.Error("Expected the order off the new tick size to be cancelled")
+	}
+	if ob := mm.GetOrderBook(2); ob == nil || ob.Config().LotSize != 10 {
+		t.Error("Expected MSFT to be added with its lot size")
+	}
+
+	if err := ApplySymbols(mm, []Symbol{{ID: 3, Name: "MSFT"}}); err == nil {
+		t.Error("Expected an error for a name used by another symbol")
+	}
+	if err := ApplySymbols(mm, []Symbol{{ID: 3, Name: "X", MinPrice: 10, MaxPrice: 5}}); err == nil {
+		t.Error("Expected an error for an invalid price band")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trader.toml")
+	if err := os.WriteFile(path, []byte("[[symbols]]\nid = 1\nname = \"AAPL\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan *Config, 1)
+	go Watch(ctx, path, 5*time.Millisecond, func(c *Config, err error) {
+		if err == nil {
+			changes <- c
+		}
+	})
+
+	time.Sleep(20 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("[[symbols]]\nid = 1\nname = \"AAPL\"\n[[symbols]]\nid = 2\nname = \"MSFT\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case c := <-changes:
+		if len(c.Symbols) != 2 {
+			t.Errorf("Expected 2 symbols, got %d", len(c.Symbols))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the change to be reported")
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// Watch polls the configuration file at path every interval until ctx is
+// done. When the file changes, onChange is called with the loaded
+// configuration, or with the error if it does not load; the previous
+// configuration then stays in effect.
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func(c *Config, err error)) {
+	last, _ := os.Stat(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil || last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last = info
+		onChange(Load(path))
+	}
+}
+
+// ApplySymbols brings the symbols of mm in line with the configuration: new
+// symbols are added with their order books, renamed symbols are renamed and
+// changed trading rules are applied to the live books. Symbols missing from
+// the configuration keep trading until they are removed explicitly.
+func ApplySymbols(mm *matching.MarketManager, symbols []Symbol) error {
+	if err := ValidateSymbols(symbols); err != nil {
+		return err
+	}
+	var errs []error
+	for _, s := range symbols {
+		current := mm.GetSymbol(s.ID)
+		switch {
+		case current == nil:
+			if other := mm.GetSymbolByName(s.Name); other != nil {
+				errs = append(errs, fmt.Errorf("symbol %s: name used by symbol %d", s.Name, other.ID))
+				continue
+			}
+			mm.AddSymbol(s.Symbol())
+		case current.Name != s.Symbol().Name:
+			if code := mm.RenameSymbol(s.ID, s.Name); code != matching.ErrorOK {
+				errs = append(errs, fmt.Errorf("symbol %s: %s", s.Name, code))
+				continue
+			}
+		}
+		ob := mm.GetOrderBook(s.ID)
+		if ob == nil {
+			mm.AddOrderBook(s.Symbol())
+			ob = mm.GetOrderBook(s.ID)
+		}
+		if ob.Config() == s.SymbolConfig() {
+			continue
+		}
+		if code := mm.UpdateSymbolConfig(s.ID, s.SymbolConfig()); code != matching.ErrorOK {
+			errs = append(errs, fmt.Errorf("symbol %s: %s", s.Name, code))
+		}
+	}
+	return errors.Join(errs...)
+}
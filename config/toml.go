@@ -0,0 +1,369 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseTOML parses the subset of TOML used by configuration files into
+// nested maps: tables, arrays of tables, bare and quoted keys, basic and
+// literal strings, integers, floats, booleans, arrays and inline tables.
+// Dates, multi-line strings and dotted keys are not supported.
+func parseTOML(data string) (map[string]any, error) {
+	p := &tomlParser{root: make(map[string]any)}
+	p.table = p.root
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		start := i
+		// Arrays and inline tables may span lines
+		for open(line) && i+1 < len(lines) {
+			i++
+			line += "\n" + strings.TrimSpace(lines[i])
+		}
+		if err := p.line(line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", start+1, err)
+		}
+	}
+	return p.root, nil
+}
+
+// tomlParser is the state of parseTOML
+type tomlParser struct {
+	root  map[string]any
+	table map[string]any
+}
+
+// line parses a table header or a key/value pair
+func (p *tomlParser) line(line string) error {
+	if strings.HasPrefix(line, "[[") {
+		path, err := p.header(line, "[[", "]]")
+		if err != nil {
+			return err
+		}
+		parent, err := p.walk(path[:len(path)-1])
+		if err != nil {
+			return err
+		}
+		last := path[len(path)-1]
+		table := make(map[string]any)
+		switch v := parent[last].(type) {
+		case nil:
+			parent[last] = []any{table}
+		case []any:
+			parent[last] = append(v, table)
+		default:
+			return fmt.Errorf("%s is not an array of tables", strings.Join(path, "."))
+		}
+		p.table = table
+		return nil
+	}
+	if strings.HasPrefix(line, "[") {
+		path, err := p.header(line, "[", "]")
+		if err != nil {
+			return err
+		}
+		table, err := p.walk(path)
+		if err != nil {
+			return err
+		}
+		p.table = table
+		return nil
+	}
+
+	s := &scanner{s: line}
+	key, err := s.key()
+	if err != nil {
+		return err
+	}
+	if !s.consume('=') {
+		return fmt.Errorf("expected = after %s", key)
+	}
+	value, err := s.value()
+	if err != nil {
+		return err
+	}
+	if !s.end() {
+		return fmt.Errorf("unexpected %q after value of %s", s.rest(), key)
+	}
+	if _, exists := p.table[key]; exists {
+		return fmt.Errorf("duplicate key %s", key)
+	}
+	p.table[key] = value
+	return nil
+}
+
+// header parses the dotted key path of a table header
+func (p *tomlParser) header(line, open, close string) ([]string, error) {
+	s := &scanner{s: line[len(open):]}
+	var path []string
+	for {
+		key, err := s.key()
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, key)
+		if !s.consume('.') {
+			break
+		}
+	}
+	s.space()
+	if !strings.HasPrefix(s.rest(), close) {
+		return nil, fmt.Errorf("unterminated table header")
+	}
+	s.pos += len(close)
+	if !s.end() {
+		return nil, fmt.Errorf("unexpected %q after table header", s.rest())
+	}
+	return path, nil
+}
+
+// walk returns the table at path, creating missing tables. The path goes
+// through the last table of arrays of tables.
+func (p *tomlParser) walk(path []string) (map[string]any, error) {
+	table := p.root
+	for i, key := range path {
+		switch v := table[key].(type) {
+		case nil:
+			next := make(map[string]any)
+			table[key] = next
+			table = next
+		case map[string]any:
+			table = v
+		case []any:
+			last, ok := v[len(v)-1].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s is not a table", strings.Join(path[:i+1], "."))
+			}
+			table = last
+		default:
+			return nil, fmt.Errorf("%s is not a table", strings.Join(path[:i+1], "."))
+		}
+	}
+	return table, nil
+}
+
+// open returns true if line has more opening than closing brackets or
+// braces outside of strings and comments
+func open(line string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return depth > 0
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// scanner reads the tokens of a key/value pair
+type scanner struct {
+	s   string
+	pos int
+}
+
+// rest returns the unread input
+func (s *scanner) rest() string {
+	return s.s[s.pos:]
+}
+
+// space skips whitespace, newlines and comments
+func (s *scanner) space() {
+	for s.pos < len(s.s) {
+		switch c := s.s[s.pos]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			s.pos++
+		case c == '#':
+			for s.pos < len(s.s) && s.s[s.pos] != '\n' {
+				s.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// consume skips whitespace and c, returning false if c is not next
+func (s *scanner) consume(c byte) bool {
+	s.space()
+	if s.pos < len(s.s) && s.s[s.pos] == c {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+// end returns true if only whitespace and comments are left
+func (s *scanner) end() bool {
+	s.space()
+	return s.pos == len(s.s)
+}
+
+// key reads a bare or quoted key
+func (s *scanner) key() (string, error) {
+	s.space()
+	if s.pos < len(s.s) && (s.s[s.pos] == '"' || s.s[s.pos] == '\'') {
+		return s.string()
+	}
+	start := s.pos
+	for s.pos < len(s.s) && isBare(s.s[s.pos]) {
+		s.pos++
+	}
+	if start == s.pos {
+		return "", fmt.Errorf("expected a key at %q", s.rest())
+	}
+	return s.s[start:s.pos], nil
+}
+
+// isBare returns true for the characters of bare keys
+func isBare(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// value reads a value
+func (s *scanner) value() (any, error) {
+	s.space()
+	if s.pos == len(s.s) {
+		return nil, fmt.Errorf("missing value")
+	}
+	switch c := s.s[s.pos]; c {
+	case '"', '\'':
+		return s.string()
+	case '[':
+		s.pos++
+		values := []any{}
+		for !s.consume(']') {
+			v, err := s.value()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			if !s.consume(',') {
+				if !s.consume(']') {
+					return nil, fmt.Errorf("expected , or ] in array")
+				}
+				break
+			}
+		}
+		return values, nil
+	case '{':
+		s.pos++
+		table := make(map[string]any)
+		if s.consume('}') {
+			return table, nil
+		}
+		for {
+			key, err := s.key()
+			if err != nil {
+				return nil, err
+			}
+			if !s.consume('=') {
+				return nil, fmt.Errorf("expected = after %s", key)
+			}
+			v, err := s.value()
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := table[key]; exists {
+				return nil, fmt.Errorf("duplicate key %s", key)
+			}
+			table[key] = v
+			if s.consume('}') {
+				return table, nil
+			}
+			if !s.consume(',') {
+				return nil, fmt.Errorf("expected , or } in inline table")
+			}
+		}
+	}
+
+	start := s.pos
+	for s.pos < len(s.s) && !strings.ContainsRune(" \t\r\n,]}#", rune(s.s[s.pos])) {
+		s.pos++
+	}
+	token := s.s[start:s.pos]
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	digits := strings.ReplaceAll(token, "_", "")
+	if n, err := strconv.ParseInt(digits, 10, 64); err == nil {
+		return n, nil
+	}
+	if n, err := strconv.ParseUint(digits, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(digits, 64); err == nil && token != "" {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid value %q", token)
+}
+
+// string reads a basic or literal string
+func (s *scanner) string() (string, error) {
+	quote := s.s[s.pos]
+	s.pos++
+	var b strings.Builder
+	for s.pos < len(s.s) {
+		c := s.s[s.pos]
+		s.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\n':
+			return "", fmt.Errorf("newline in string")
+		case c == '\\' && quote == '"':
+			if s.pos == len(s.s) {
+				return "", fmt.Errorf("unterminated string")
+			}
+			e := s.s[s.pos]
+			s.pos++
+			switch e {
+			case '"', '\\':
+				b.WriteByte(e)
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'u':
+				if s.pos+4 > len(s.s) {
+					return "", fmt.Errorf("invalid escape")
+				}
+				r, err := strconv.ParseUint(s.s[s.pos:s.pos+4], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(r)) {
+					return "", fmt.Errorf("invalid escape \\u%s", s.s[s.pos:s.pos+4])
+				}
+				b.WriteRune(rune(r))
+				s.pos += 4
+			default:
+				return "", fmt.Errorf("invalid escape \\%c", e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
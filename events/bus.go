@@ -0,0 +1,207 @@
+// Package events is a lightweight in-memory publish/subscribe bus with typed
+// topics and bounded subscriber queues.
+//
+// A Subscriber delivers the events of every topic it subscribes to in
+// publish order, either synchronously in the publishing goroutine or from its
+// own goroutine through a bounded queue. MarketHandler publishes matching
+// engine events on the bus, so that integrations such as the market data
+// publisher and trade persistence subscribe to the topics they need instead
+// of wrapping each other's handlers.
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tienpsm/go-trader/metrics"
+)
+
+// Topic is a named stream of events of type T
+type Topic[T any] struct {
+	name string
+}
+
+// NewTopic creates a topic. Topics with the same name are the same topic, so
+// names must be unique per event type.
+func NewTopic[T any](name string) Topic[T] {
+	return Topic[T]{name: name}
+}
+
+// Name returns the topic name
+func (t Topic[T]) Name() string {
+	return t.name
+}
+
+// OverflowPolicy decides what happens when a subscriber queue is full
+type OverflowPolicy uint8
+
+const (
+	// Block makes the publisher wait for room in the queue, so no event is
+	// lost but a slow subscriber slows down the publisher
+	Block OverflowPolicy = iota
+	// Drop discards the event for the subscriber and counts it
+	Drop
+)
+
+// route is a subscription of a subscriber to a topic
+type route struct {
+	sub *Subscriber
+	// fn is the func(T) handler of the topic's event type
+	fn any
+}
+
+// Bus routes published events to subscribers
+type Bus struct {
+	mu     sync.RWMutex
+	routes map[string][]route
+	subs   []*Subscriber
+}
+
+// NewBus creates an empty bus
+func NewBus() *Bus {
+	return &Bus{routes: make(map[string][]route)}
+}
+
+// Subscriber receives events from one or more topics. Handlers of a
+// subscriber run one at a time, in publish order.
+type Subscriber struct {
+	bus     *Bus
+	queue   chan queued
+	policy  OverflowPolicy
+	dropped atomic.Uint64
+	latency *metrics.Histogram
+	done    chan struct{}
+	once    sync.Once
+}
+
+// queued is an event waiting in a subscriber queue
+type queued struct {
+	deliver func()
+	at      time.Time
+}
+
+// NewSubscriber creates a subscriber. With size 0 handlers are called
+// synchronously by Publish; otherwise events are queued, up to size, and
+// handled by a dedicated goroutine, with policy applied when the queue is
+// full.
+func (b *Bus) NewSubscriber(size int, policy OverflowPolicy) *Subscriber {
+	s := &Subscriber{bus: b, policy: policy, done: make(chan struct{})}
+	if size > 0 {
+		s.queue = make(chan queued, size)
+		s.latency = metrics.NewHistogram()
+		go s.run()
+	} else {
+		close(s.done)
+	}
+
+	b.mu.Lock()
+	b.subs = append(b.subs, s)
+	b.mu.Unlock()
+	return s
+}
+
+// run handles queued events until the queue is closed
+func (s *Subscriber) run() {
+	defer close(s.done)
+	for q := range s.queue {
+		s.latency.Since(q.at)
+		q.deliver()
+	}
+}
+
+// Subscribe registers fn for the events of topic. It must not be called from
+// a handler.
+func Subscribe[T any](s *Subscriber, topic Topic[T], fn func(T)) {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.routes[topic.name] = append(s.bus.routes[topic.name], route{sub: s, fn: fn})
+}
+
+// Publish delivers event to every subscriber of topic
+func Publish[T any](b *Bus, topic Topic[T], event T) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, r := range b.routes[topic.name] {
+		fn := r.fn.(func(T))
+		r.sub.deliver(func() { fn(event) })
+	}
+}
+
+// deliver runs or queues a handler call
+func (s *Subscriber) deliver(fn func()) {
+	if s.queue == nil {
+		fn()
+		return
+	}
+	q := queued{deliver: fn, at: time.Now()}
+	if s.policy == Drop {
+		select {
+		case s.queue <- q:
+		default:
+			s.dropped.Add(1)
+		}
+		return
+	}
+	s.queue <- q
+}
+
+// Dropped returns the number of events discarded because the queue was full
+func (s *Subscriber) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Pending returns the number of queued events not handled yet
+func (s *Subscriber) Pending() int {
+	return len(s.queue)
+}
+
+// QueueLatency returns the distribution of the time events spent in the
+// queue. It is empty for synchronous subscribers.
+func (s *Subscriber) QueueLatency() metrics.Snapshot {
+	if s.latency == nil {
+		return metrics.Snapshot{}
+	}
+	return s.latency.Snapshot()
+}
+
+// Close unsubscribes from every topic, handles the events already queued and
+// waits for the last handler to return. It must not be called from a
+// handler.
+func (s *Subscriber) Close() {
+	s.once.Do(func() {
+		b := s.bus
+		b.mu.Lock()
+		for name, routes := range b.routes {
+			kept := routes[:0]
+			for _, r := range routes {
+				if r.sub != s {
+					kept = append(kept, r)
+				}
+			}
+			b.routes[name] = kept
+		}
+		for i, sub := range b.subs {
+			if sub == s {
+				b.subs = append(b.subs[:i], b.subs[i+1:]...)
+				break
+			}
+		}
+		b.mu.Unlock()
+
+		if s.queue != nil {
+			close(s.queue)
+		}
+	})
+	<-s.done
+}
+
+// Close closes every subscriber, draining their queues
+func (b *Bus) Close() {
+	b.mu.RLock()
+	subs := append([]*Subscriber(nil), b.subs...)
+	b.mu.RUnlock()
+	for _, s := range subs {
+		s.Close()
+	}
+}
@@ -0,0 +1,126 @@
+package events
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+var (
+	testInts    = NewTopic[int]("test.ints")
+	testStrings = NewTopic[string]("test.strings")
+)
+
+func TestBus_SynchronousOrder(t *testing.T) {
+	bus := NewBus()
+	sub := bus.NewSubscriber(0, Block)
+	var got []any
+	Subscribe(sub, testInts, func(v int) { got = append(got, v) })
+	Subscribe(sub, testStrings, func(v string) { got = append(got, v) })
+
+	Publish(bus, testInts, 1)
+	Publish(bus, testStrings, "a")
+	Publish(bus, testInts, 2)
+
+	if len(got) != 3 || got[0] != 1 || got[1] != "a" || got[2] != 2 {
+		t.Errorf("Expected [1 a 2], got %v", got)
+	}
+}
+
+func TestBus_QueuedOrderAcrossTopics(t *testing.T) {
+	bus := NewBus()
+	sub := bus.NewSubscriber(4, Block)
+	var got []any
+	Subscribe(sub, testInts, func(v int) { got = append(got, v) })
+	Subscribe(sub, testStrings, func(v string) { got = append(got, v) })
+
+	for i := 0; i < 100; i++ {
+		Publish(bus, testInts, i)
+		Publish(bus, testStrings, "s")
+	}
+	sub.Close()
+
+	if len(got) != 200 {
+		t.Fatalf("Expected 200 events, got %d", len(got))
+	}
+	for i := 0; i < 100; i++ {
+		if got[2*i] != i || got[2*i+1] != "s" {
+			t.Fatalf("Expected event %d in publish order, got %v", i, got[2*i:2*i+2])
+		}
+	}
+	if sub.QueueLatency().Count != 200 {
+		t.Errorf("Expected 200 queue latency samples, got %d", sub.QueueLatency().Count)
+	}
+
+	// A closed subscriber no longer receives events
+	Publish(bus, testInts, 1000)
+	if len(got) != 200 {
+		t.Errorf("Expected no delivery after Close, got %d events", len(got))
+	}
+}
+
+func TestBus_DropPolicy(t *testing.T) {
+	bus := NewBus()
+	sub := bus.NewSubscriber(1, Drop)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var got []int
+	Subscribe(sub, testInts, func(v int) {
+		<-release
+		mu.Lock()
+		got = append(got, v)
+		mu.Unlock()
+	})
+
+	// The first event may already be handled (blocked on release), so the
+	// queue holds at most one more; the rest are dropped
+	for i := 0; i < 10; i++ {
+		Publish(bus, testInts, i)
+	}
+	close(release)
+	bus.Close()
+
+	if uint64(len(got))+sub.Dropped() != 10 {
+		t.Errorf("Expected delivered + dropped = 10, got %d + %d", len(got), sub.Dropped())
+	}
+	if len(got) > 2 || sub.Dropped() < 8 {
+		t.Errorf("Expected at most 2 delivered events, got %v (%d dropped)", got, sub.Dropped())
+	}
+}
+
+func TestMarketHandler(t *testing.T) {
+	bus := NewBus()
+	sub := bus.NewSubscriber(16, Block)
+	var trades []matching.Trade
+	var executions []Execution
+	var levels []LevelChange
+	var rejects []Reject
+	Subscribe(sub, TopicTrade, func(trade matching.Trade) { trades = append(trades, trade) })
+	Subscribe(sub, TopicExecution, func(e Execution) { executions = append(executions, e) })
+	Subscribe(sub, TopicLevel, func(c LevelChange) { levels = append(levels, c) })
+	Subscribe(sub, TopicRejectOrder, func(r Reject) { rejects = append(rejects, r) })
+
+	manager := matching.NewMarketManagerWithHandler(NewMarketHandler(bus))
+	manager.EnableMatching()
+	symbol := matching.NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+	manager.AddOrder(*matching.NewLimitOrder(1, 1, matching.OrderSideSell, 10000, 100))
+	manager.AddOrder(*matching.NewLimitOrder(2, 1, matching.OrderSideBuy, 10000, 40))
+	manager.AddOrder(*matching.NewLimitOrder(1, 1, matching.OrderSideBuy, 9900, 10))
+	bus.Close()
+
+	if len(trades) != 1 || trades[0].Quantity != 40 || trades[0].SellOrderID != 1 {
+		t.Errorf("Expected one trade of 40 against order 1, got %+v", trades)
+	}
+	if len(executions) != 2 || executions[0].Quantity != 40 || executions[0].Price != 10000 {
+		t.Errorf("Expected two executions of 40 at 10000, got %+v", executions)
+	}
+	if len(levels) == 0 || levels[0].SymbolID != 1 || levels[0].Action != LevelAdd {
+		t.Errorf("Expected level add for symbol 1 first, got %+v", levels)
+	}
+	if len(rejects) != 1 || rejects[0].Code != matching.ErrorOrderDuplicate || rejects[0].Order.Price != 9900 {
+		t.Errorf("Expected the duplicate order rejected, got %+v", rejects)
+	}
+}
@@ -0,0 +1,171 @@
+package events
+
+import "github.com/tienpsm/go-trader/matching"
+
+// Execution is an order execution
+type Execution struct {
+	// Order is the executed order after the execution
+	Order matching.Order
+	// Price is the execution price
+	Price uint64
+	// Quantity is the executed quantity
+	Quantity uint64
+}
+
+// Reject is a rejected order operation
+type Reject struct {
+	// Order is the order as requested, or the state of the existing order
+	Order matching.Order
+	// Code is the reason of the reject
+	Code matching.ErrorCode
+}
+
+// LevelAction identifies the kind of price level change
+type LevelAction uint8
+
+const (
+	// LevelAdd is a new price level
+	LevelAdd LevelAction = iota
+	// LevelUpdate is a change of an existing price level
+	LevelUpdate
+	// LevelDelete is a price level that no longer has any orders
+	LevelDelete
+)
+
+// String returns the string representation of a LevelAction
+func (a LevelAction) String() string {
+	switch a {
+	case LevelAdd:
+		return "ADD"
+	case LevelUpdate:
+		return "UPDATE"
+	case LevelDelete:
+		return "DELETE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// LevelChange is a price level change of an order book
+type LevelChange struct {
+	// SymbolID is the symbol of the order book
+	SymbolID uint32
+	// Action is the kind of change
+	Action LevelAction
+	// Level is the state of the level after the change
+	Level matching.Level
+	// Top is true if the level is the best bid or ask
+	Top bool
+}
+
+// Matching engine topics published by MarketHandler. Events are copies, so
+// they are safe to handle from subscriber goroutines.
+var (
+	// TopicAddSymbol carries added symbols
+	TopicAddSymbol = NewTopic[matching.Symbol]("matching.symbol.add")
+	// TopicDeleteSymbol carries deleted symbols
+	TopicDeleteSymbol = NewTopic[matching.Symbol]("matching.symbol.delete")
+	// TopicAddOrder carries orders added to the engine
+	TopicAddOrder = NewTopic[matching.Order]("matching.order.add")
+	// TopicUpdateOrder carries updated orders
+	TopicUpdateOrder = NewTopic[matching.Order]("matching.order.update")
+	// TopicDeleteOrder carries deleted orders
+	TopicDeleteOrder = NewTopic[matching.Order]("matching.order.delete")
+	// TopicInvalidOrder carries resting orders that violate a new symbol
+	// configuration
+	TopicInvalidOrder = NewTopic[matching.Order]("matching.order.invalid")
+	// TopicRejectOrder carries rejected order operations
+	TopicRejectOrder = NewTopic[Reject]("matching.order.reject")
+	// TopicExpireOrder carries resting orders that outlived the time to live
+	// of their order book, before their deletion
+	TopicExpireOrder = NewTopic[matching.Order]("matching.order.expire")
+	// TopicExecution carries order executions
+	TopicExecution = NewTopic[Execution]("matching.order.execute")
+	// TopicTrade carries trades, after both orders have been executed
+	TopicTrade = NewTopic[matching.Trade]("matching.trade")
+	// TopicLevel carries price level changes
+	TopicLevel = NewTopic[LevelChange]("matching.level")
+)
+
+// MarketHandler is a matching.MarketHandler that publishes engine events on a
+// bus. Order book lifecycle events, which carry the live *OrderBook, are not
+// published.
+type MarketHandler struct {
+	matching.DefaultMarketHandler
+	bus *Bus
+}
+
+// NewMarketHandler creates a handler publishing on bus
+func NewMarketHandler(bus *Bus) *MarketHandler {
+	return &MarketHandler{bus: bus}
+}
+
+// Bus returns the bus events are published on
+func (h *MarketHandler) Bus() *Bus {
+	return h.bus
+}
+
+// OnAddSymbol publishes on TopicAddSymbol
+func (h *MarketHandler) OnAddSymbol(symbol matching.Symbol) {
+	Publish(h.bus, TopicAddSymbol, symbol)
+}
+
+// OnDeleteSymbol publishes on TopicDeleteSymbol
+func (h *MarketHandler) OnDeleteSymbol(symbol matching.Symbol) {
+	Publish(h.bus, TopicDeleteSymbol, symbol)
+}
+
+// OnAddLevel publishes on TopicLevel
+func (h *MarketHandler) OnAddLevel(orderBook *matching.OrderBook, level matching.Level, top bool) {
+	Publish(h.bus, TopicLevel, LevelChange{SymbolID: orderBook.Symbol().ID, Action: LevelAdd, Level: level, Top: top})
+}
+
+// OnUpdateLevel publishes on TopicLevel
+func (h *MarketHandler) OnUpdateLevel(orderBook *matching.OrderBook, level matching.Level, top bool) {
+	Publish(h.bus, TopicLevel, LevelChange{SymbolID: orderBook.Symbol().ID, Action: LevelUpdate, Level: level, Top: top})
+}
+
+// OnDeleteLevel publishes on TopicLevel
+func (h *MarketHandler) OnDeleteLevel(orderBook *matching.OrderBook, level matching.Level, top bool) {
+	Publish(h.bus, TopicLevel, LevelChange{SymbolID: orderBook.Symbol().ID, Action: LevelDelete, Level: level, Top: top})
+}
+
+// OnAddOrder publishes on TopicAddOrder
+func (h *MarketHandler) OnAddOrder(order matching.Order) {
+	Publish(h.bus, TopicAddOrder, order)
+}
+
+// OnUpdateOrder publishes on TopicUpdateOrder
+func (h *MarketHandler) OnUpdateOrder(order matching.Order) {
+	Publish(h.bus, TopicUpdateOrder, order)
+}
+
+// OnDeleteOrder publishes on TopicDeleteOrder
+func (h *MarketHandler) OnDeleteOrder(order matching.Order) {
+	Publish(h.bus, TopicDeleteOrder, order)
+}
+
+// OnInvalidOrder publishes on TopicInvalidOrder
+func (h *MarketHandler) OnInvalidOrder(order matching.Order) {
+	Publish(h.bus, TopicInvalidOrder, order)
+}
+
+// OnRejectOrder publishes on TopicRejectOrder
+func (h *MarketHandler) OnRejectOrder(order matching.Order, code matching.ErrorCode) {
+	Publish(h.bus, TopicRejectOrder, Reject{Order: order, Code: code})
+}
+
+// OnExpireOrder publishes on TopicExpireOrder
+func (h *MarketHandler) OnExpireOrder(order matching.Order) {
+	Publish(h.bus, TopicExpireOrder, order)
+}
+
+// OnExecuteOrder publishes on TopicExecution
+func (h *MarketHandler) OnExecuteOrder(order matching.Order, price, quantity uint64) {
+	Publish(h.bus, TopicExecution, Execution{Order: order, Price: price, Quantity: quantity})
+}
+
+// OnTrade publishes on TopicTrade
+func (h *MarketHandler) OnTrade(trade matching.Trade) {
+	Publish(h.bus, TopicTrade, trade)
+}
@@ -67,6 +67,7 @@ func main() {
 	handler := &TradeLogger{}
 	manager := matching.NewMarketManagerWithHandler(handler)
 	manager.EnableMatching()
+	manager.EnableAmendmentHistory(8)
 
 	// Add symbols
 	appl := matching.NewSymbol(1, "AAPL")
@@ -178,13 +179,7 @@ func main() {
 	ob := manager.GetOrderBook(1)
 	fmt.Println("\n--- AAPL Order Book ---")
 	
-	if bestBid := ob.BestBid(); bestBid != nil {
-		fmt.Printf("Best Bid: $%.2f x %d\n", float64(bestBid.Price)/100, bestBid.TotalVolume)
-	}
-	if bestAsk := ob.BestAsk(); bestAsk != nil {
-		fmt.Printf("Best Ask: $%.2f x %d\n", float64(bestAsk.Price)/100, bestAsk.TotalVolume)
-	}
-	fmt.Printf("Spread: $%.2f\n", float64(ob.GetSpread())/100)
+	fmt.Print(ob.RenderDOM(5))
 	fmt.Printf("Mid Price: $%.2f\n", float64(ob.GetMidPrice())/100)
 
 	fmt.Println("\n--- Scenario 4: Order Modification ---")
@@ -196,6 +191,10 @@ func main() {
 	if order := manager.GetOrder(5); order != nil {
 		fmt.Printf("Order 5 after modification: %d @ $%.2f\n",
 			order.Quantity, float64(order.Price)/100)
+		for _, a := range order.Amendments() {
+			fmt.Printf("  %s: %d @ $%.2f -> %d @ $%.2f\n", a.Type,
+				a.Quantity, float64(a.Price)/100, a.NewQuantity, float64(a.NewPrice)/100)
+		}
 	}
 
 	fmt.Println("\n--- Scenario 5: Order Cancellation ---")
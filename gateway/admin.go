@@ -0,0 +1,251 @@
+package gateway
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// AdminConfig configures the admin API of a server
+type AdminConfig struct {
+	// Token is the bearer token of admin requests; the admin API is disabled
+	// while it is empty
+	Token string
+}
+
+// AdminSymbol is a symbol of the admin API
+type AdminSymbol struct {
+	ID   uint32 `json:"id"`
+	Name string `json:"name"`
+	// Halted is true while trading is halted on the book of the symbol,
+	// market-wide halts excluded
+	Halted bool `json:"halted,omitempty"`
+}
+
+// AdminStatus is the response of GET /admin/symbols
+type AdminStatus struct {
+	MarketHalted bool          `json:"market_halted"`
+	Symbols      []AdminSymbol `json:"symbols"`
+}
+
+// AdminResult is the response of the admin actions
+type AdminResult struct {
+	Status string `json:"status"`
+	// Archive is the archived journal segment of a rotation
+	Archive string `json:"archive,omitempty"`
+}
+
+// adminError is a failed admin request
+type adminError struct {
+	code int
+	msg  string
+}
+
+func (e *adminError) Error() string { return e.msg }
+
+// SetAdminConfig replaces the admin configuration
+func (s *Server) SetAdminConfig(config AdminConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.admin = config
+}
+
+// AdminHandler returns the HTTP handler of the admin API, mounted at /admin/
+// by Routes. Every request needs the configured token as a bearer token in
+// the Authorization header; the API answers 404 while no token is set.
+//
+//   - GET /admin/symbols lists the symbols with order books and their halts
+//   - POST /admin/symbols with an AdminSymbol body adds a symbol and its book
+//   - DELETE /admin/symbols?symbol= deletes a symbol, canceling its orders
+//   - POST /admin/halt and /admin/resume halt and resume trading on the book
+//     of the symbol query parameter, or market-wide without one
+//   - POST /admin/snapshot writes a snapshot
+//   - POST /admin/rotate snapshots and rotates the journal
+//
+// Symbols are not journaled, so symbol changes are followed by a snapshot.
+// Halts are not persisted and end on restart.
+func (s *Server) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/symbols", s.adminSymbols)
+	mux.HandleFunc("/admin/halt", s.adminHalt(true))
+	mux.HandleFunc("/admin/resume", s.adminHalt(false))
+	mux.HandleFunc("/admin/snapshot", s.adminAction(func() (AdminResult, error) {
+		return AdminResult{Status: HealthOK}, s.manager.SaveSnapshot()
+	}))
+	mux.HandleFunc("/admin/rotate", s.adminAction(func() (AdminResult, error) {
+		archive, err := s.manager.RotateJournal()
+		return AdminResult{Status: HealthOK, Archive: archive}, err
+	}))
+	return s.authorize(mux)
+}
+
+// authorize serves admin requests carrying the configured token
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		token := s.admin.Token
+		s.mu.Unlock()
+		if token == "" {
+			http.NotFound(w, r)
+			return
+		}
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminSymbols lists, adds and deletes symbols
+func (s *Server) adminSymbols(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeAdmin(w, s.adminStatus())
+	case http.MethodPost:
+		var sym AdminSymbol
+		if err := json.NewDecoder(r.Body).Decode(&sym); err != nil || sym.Name == "" || len(sym.Name) > 8 {
+			http.Error(w, "invalid symbol", http.StatusBadRequest)
+			return
+		}
+		s.adminUpdate(w, func(mm *matching.MarketManager) error {
+			if other := mm.GetSymbolByName(sym.Name); other != nil {
+				return &adminError{http.StatusConflict, fmt.Sprintf("name used by symbol %d", other.ID)}
+			}
+			symbol := matching.NewSymbol(sym.ID, sym.Name)
+			if code := mm.AddSymbol(symbol); code != matching.ErrorOK {
+				return codeError(code)
+			}
+			return codeError(mm.AddOrderBook(symbol))
+		})
+	case http.MethodDelete:
+		s.adminUpdate(w, func(mm *matching.MarketManager) error {
+			sym := mm.GetSymbolByName(r.URL.Query().Get("symbol"))
+			if sym == nil {
+				return codeError(matching.ErrorSymbolNotFound)
+			}
+			return codeError(mm.DeleteSymbol(sym.ID))
+		})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// adminStatus returns the symbols with order books and the halts
+func (s *Server) adminStatus() AdminStatus {
+	status := AdminStatus{Symbols: []AdminSymbol{}}
+	s.manager.View(func(mm *matching.MarketManager) {
+		status.MarketHalted = mm.IsMarketHalted()
+		for id, ob := range mm.OrderBooks() {
+			status.Symbols = append(status.Symbols, AdminSymbol{
+				ID:     id,
+				Name:   ob.Symbol().Name,
+				Halted: ob.IsHalted() && !mm.IsMarketHalted(),
+			})
+		}
+	})
+	sort.Slice(status.Symbols, func(i, j int) bool { return status.Symbols[i].ID < status.Symbols[j].ID })
+	return status
+}
+
+// adminUpdate changes the symbols and snapshots the result
+func (s *Server) adminUpdate(w http.ResponseWriter, fn func(mm *matching.MarketManager) error) {
+	if err := s.manager.Update(fn); err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	if err := s.manager.SaveSnapshot(); err != nil {
+		http.Error(w, "applied but not persisted: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeAdmin(w, AdminResult{Status: HealthOK})
+}
+
+// adminHalt halts or resumes trading on a book, or market-wide
+func (s *Server) adminHalt(halt bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		name := r.URL.Query().Get("symbol")
+		err := s.manager.Update(func(mm *matching.MarketManager) error {
+			if name == "" {
+				if halt {
+					mm.HaltMarket()
+				} else {
+					mm.ResumeMarket()
+				}
+				return nil
+			}
+			sym := mm.GetSymbolByName(name)
+			if sym == nil {
+				return codeError(matching.ErrorSymbolNotFound)
+			}
+			if halt {
+				return codeError(mm.HaltTrading(sym.ID))
+			}
+			return codeError(mm.ResumeTrading(sym.ID))
+		})
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdmin(w, AdminResult{Status: HealthOK})
+	}
+}
+
+// adminAction serves a persistence action
+func (s *Server) adminAction(action func() (AdminResult, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		result, err := action()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeAdmin(w, result)
+	}
+}
+
+// codeError converts an engine error code to an admin error, nil for
+// ErrorOK
+func codeError(code matching.ErrorCode) error {
+	switch code {
+	case matching.ErrorOK:
+		return nil
+	case matching.ErrorSymbolNotFound, matching.ErrorOrderBookNotFound:
+		return &adminError{http.StatusNotFound, code.String()}
+	case matching.ErrorSymbolDuplicate, matching.ErrorOrderBookDuplicate, matching.ErrorSymbolInUse:
+		return &adminError{http.StatusConflict, code.String()}
+	default:
+		return &adminError{http.StatusBadRequest, code.String()}
+	}
+}
+
+// writeAdminError answers a failed admin request
+func writeAdminError(w http.ResponseWriter, err error) {
+	var e *adminError
+	if errors.As(err, &e) {
+		http.Error(w, e.msg, e.code)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// writeAdmin answers an admin request
+func writeAdmin(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,155 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// adminRequest sends an admin request with token and returns the status code
+// and body
+func adminRequest(t *testing.T, method, url, token, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+func TestServer_AdminAuth(t *testing.T) {
+	ts := startServer(t, t.TempDir())
+	defer ts.stop(t)
+	routes := httptest.NewServer(ts.Routes())
+	defer routes.Close()
+	url := routes.URL + "/admin/symbols"
+
+	// Disabled without a token
+	if code, _ := adminRequest(t, http.MethodGet, url, "secret", ""); code != http.StatusNotFound {
+		t.Errorf("Expected 404 while disabled, got %d", code)
+	}
+	ts.SetAdminConfig(AdminConfig{Token: "secret"})
+	if code, _ := adminRequest(t, http.MethodGet, url, "", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", code)
+	}
+	if code, _ := adminRequest(t, http.MethodGet, url, "wrong", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with a wrong token, got %d", code)
+	}
+	if code, _ := adminRequest(t, http.MethodGet, url, "secret", ""); code != http.StatusOK {
+		t.Errorf("Expected 200 with the token, got %d", code)
+	}
+}
+
+func TestServer_AdminSymbols(t *testing.T) {
+	dir := t.TempDir()
+	ts := startServer(t, dir)
+	routes := httptest.NewServer(ts.Routes())
+	defer routes.Close()
+	ts.SetAdminConfig(AdminConfig{Token: "secret"})
+	url := routes.URL + "/admin/symbols"
+
+	if code, body := adminRequest(t, http.MethodPost, url, "secret", `{"id":2,"name":"MSFT"}`); code != http.StatusOK {
+		t.Fatalf("Expected MSFT to be added, got %d %s", code, body)
+	}
+	if code, _ := adminRequest(t, http.MethodPost, url, "secret", `{"id":3,"name":"MSFT"}`); code != http.StatusConflict {
+		t.Errorf("Expected a duplicate name to conflict, got %d", code)
+	}
+	if code, _ := adminRequest(t, http.MethodPost, url, "secret", `{"id":1,"name":"IBM"}`); code != http.StatusConflict {
+		t.Errorf("Expected a duplicate ID to conflict, got %d", code)
+	}
+	if code, _ := adminRequest(t, http.MethodDelete, url+"?symbol=IBM", "secret", ""); code != http.StatusNotFound {
+		t.Errorf("Expected an unknown symbol not to be found, got %d", code)
+	}
+	if code, _ := adminRequest(t, http.MethodDelete, url+"?symbol=AAPL", "secret", ""); code != http.StatusOK {
+		t.Errorf("Expected AAPL to be deleted, got %d", code)
+	}
+
+	_, body := adminRequest(t, http.MethodGet, url, "secret", "")
+	var status AdminStatus
+	if err := json.Unmarshal([]byte(body), &status); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(status.Symbols) != 1 || status.Symbols[0].Name != "MSFT" {
+		t.Errorf("Expected only MSFT, got %+v", status)
+	}
+	ts.stop(t)
+
+	// The symbol changes were snapshotted
+	recovered := startServer(t, dir)
+	defer recovered.stop(t)
+	var names []string
+	for _, sym := range recovered.adminStatus().Symbols {
+		names = append(names, sym.Name)
+	}
+	if len(names) != 2 || names[0] != "AAPL" || names[1] != "MSFT" {
+		t.Errorf("Expected AAPL from the setup and the recovered MSFT, got %v", names)
+	}
+}
+
+func TestServer_AdminHalt(t *testing.T) {
+	ts := startServer(t, t.TempDir())
+	defer ts.stop(t)
+	routes := httptest.NewServer(ts.Routes())
+	defer routes.Close()
+	ts.SetAdminConfig(AdminConfig{Token: "secret"})
+
+	ws := ts.dial(t, "participant=7")
+	expect(t, ws, ReportResynced, 0)
+	if code, _ := adminRequest(t, http.MethodPost, routes.URL+"/admin/halt?symbol=AAPL", "secret", ""); code != http.StatusOK {
+		t.Fatalf("Expected AAPL to be halted, got %d", code)
+	}
+	send(t, ws, Request{Type: RequestSubmit, ClientOrderID: "a", Symbol: "AAPL", Side: "buy", Price: 10000, Quantity: 10})
+	if r := expect(t, ws, ReportRejected, 1); !strings.Contains(r.Reason, "halted") {
+		t.Errorf("Expected a halted rejection, got %+v", r)
+	}
+	if status := ts.adminStatus(); status.MarketHalted || !status.Symbols[0].Halted {
+		t.Errorf("Expected AAPL halted, got %+v", status)
+	}
+
+	// A market-wide halt outlasts the resumption of the book
+	adminRequest(t, http.MethodPost, routes.URL+"/admin/halt", "secret", "")
+	adminRequest(t, http.MethodPost, routes.URL+"/admin/resume?symbol=AAPL", "secret", "")
+	send(t, ws, Request{Type: RequestSubmit, ClientOrderID: "b", Symbol: "AAPL", Side: "buy", Price: 10000, Quantity: 10})
+	expect(t, ws, ReportRejected, 2)
+	adminRequest(t, http.MethodPost, routes.URL+"/admin/resume", "secret", "")
+	send(t, ws, Request{Type: RequestSubmit, ClientOrderID: "c", Symbol: "AAPL", Side: "buy", Price: 10000, Quantity: 10})
+	expect(t, ws, ReportAccepted, 3)
+}
+
+func TestServer_AdminPersistence(t *testing.T) {
+	ts := startServer(t, t.TempDir())
+	defer ts.stop(t)
+	routes := httptest.NewServer(ts.Routes())
+	defer routes.Close()
+	ts.SetAdminConfig(AdminConfig{Token: "secret"})
+
+	if code, body := adminRequest(t, http.MethodPost, routes.URL+"/admin/snapshot", "secret", ""); code != http.StatusOK {
+		t.Errorf("Expected a snapshot, got %d %s", code, body)
+	}
+	if ts.manager.Health().LastSnapshot.IsZero() {
+		t.Error("Expected the snapshot time to be recorded")
+	}
+	code, body := adminRequest(t, http.MethodPost, routes.URL+"/admin/rotate", "secret", "")
+	var result AdminResult
+	if err := json.Unmarshal([]byte(body), &result); code != http.StatusOK || err != nil || result.Archive == "" {
+		t.Errorf("Expected an archived journal segment, got %d %s", code, body)
+	}
+	if code, _ := adminRequest(t, http.MethodGet, routes.URL+"/admin/rotate", "secret", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", code)
+	}
+}
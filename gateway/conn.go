@@ -0,0 +1,184 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// sendQueueSize is the number of report batches buffered per connection. A
+// client that falls further behind is disconnected and must resync.
+const sendQueueSize = 4096
+
+// conn is the WebSocket connection of a participant
+type conn struct {
+	ws          *websocket.Conn
+	participant uint32
+	queue       chan []Report
+	done        chan struct{}
+	once        sync.Once
+}
+
+// send queues reports, disconnecting a client that does not keep up. It is
+// called with the server lock held.
+func (c *conn) send(reports ...Report) {
+	select {
+	case c.queue <- reports:
+	default:
+		c.close()
+	}
+}
+
+// close stops the writer and closes the socket
+func (c *conn) close() {
+	c.once.Do(func() {
+		close(c.done)
+		_ = c.ws.Close()
+	})
+}
+
+// writeLoop writes queued reports until the connection is closed
+func (c *conn) writeLoop() {
+	for {
+		select {
+		case reports := <-c.queue:
+			for _, r := range reports {
+				if err := c.ws.WriteJSON(r); err != nil {
+					c.close()
+					return
+				}
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Handler returns the HTTP handler of the order entry endpoint. Clients
+// connect with the participant query parameter, and optionally last_sequence
+// to receive the reports they missed before live ones. Authenticating the
+// participant is left to the deployment, for example a proxy in front of the
+// server; the engine's Authorizer still checks every order.
+func (s *Server) Handler() http.Handler {
+	upgrader := websocket.Upgrader{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		participant, err := strconv.ParseUint(r.URL.Query().Get("participant"), 10, 32)
+		if err != nil || participant == 0 {
+			http.Error(w, "missing or invalid participant", http.StatusBadRequest)
+			return
+		}
+		var last uint64
+		if v := r.URL.Query().Get("last_sequence"); v != "" {
+			if last, err = strconv.ParseUint(v, 10, 64); err != nil {
+				http.Error(w, "invalid last_sequence", http.StatusBadRequest)
+				return
+			}
+		}
+
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		c := &conn{ws: ws, participant: uint32(participant), queue: make(chan []Report, sendQueueSize), done: make(chan struct{})}
+		go c.writeLoop()
+		s.attach(c, last)
+		s.readLoop(c)
+	})
+}
+
+// attach makes c the live connection of its participant, replacing any
+// previous one, after queueing the reports after last
+func (s *Server) attach(c *conn, last uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old := s.conns[c.participant]; old != nil {
+		old.close()
+	}
+	s.conns[c.participant] = c
+	s.resync(c, last)
+}
+
+// resync queues the reports after last followed by a resynced marker. It is
+// called with the server lock held, so no live report can interleave.
+func (s *Server) resync(c *conn, last uint64) {
+	reports := s.reports.Since(c.participant, last)
+	reports = append(reports, Report{Type: ReportResynced, Participant: c.participant, Sequence: s.reports.LastSequence(c.participant)})
+	c.send(reports...)
+}
+
+// readLoop handles the requests of a connection until it is closed
+func (s *Server) readLoop(c *conn) {
+	defer func() {
+		// Orders are collected under the lock, so a reconnecting client's
+		// new orders are never canceled
+		var orphaned []uint64
+		s.mu.Lock()
+		if s.conns[c.participant] == c {
+			delete(s.conns, c.participant)
+			if s.session.CancelOnDisconnect {
+				orphaned = s.openOrders(c.participant)
+			}
+		}
+		s.mu.Unlock()
+		c.close()
+		s.cancelOrders(orphaned)
+	}()
+
+	for {
+		if timeout := s.sessionConfig().Timeout; timeout > 0 {
+			_ = c.ws.SetReadDeadline(time.Now().Add(timeout))
+		} else {
+			_ = c.ws.SetReadDeadline(time.Time{})
+		}
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req Request
+		if err := json.Unmarshal(data, &req); err != nil {
+			s.answer(c, req, "malformed request")
+			continue
+		}
+		if req.Type == RequestHeartbeat {
+			continue
+		}
+		if req.Type == RequestResync {
+			s.mu.Lock()
+			s.resync(c, req.LastSequence)
+			s.mu.Unlock()
+			continue
+		}
+		if err := s.handle(c.participant, req); err != nil {
+			s.answer(c, req, err.Error())
+		}
+	}
+}
+
+// answer sends an unlogged rejection of a malformed request
+func (s *Server) answer(c *conn, req Request, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.send(Report{
+		Type:              ReportRejected,
+		Participant:       c.participant,
+		ClientOrderID:     req.ClientOrderID,
+		OrigClientOrderID: req.OrigClientOrderID,
+		Reason:            reason,
+	})
+}
+
+// Close disconnects every client and ends the market data streams
+func (s *Server) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for participant, c := range s.conns {
+		c.close()
+		delete(s.conns, participant)
+	}
+}
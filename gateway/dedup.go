@@ -0,0 +1,119 @@
+package gateway
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// DedupConfig controls how long client order IDs are remembered to answer
+// retried requests. A submit or modify reusing a remembered client order ID
+// with the same order is a retry: it enters no order, and the accepted or
+// replaced report of the original is sent again, unlogged and with its
+// original sequence. Reusing the ID for a different order is rejected.
+//
+// Client order IDs are restored from the report log, so retries are also
+// recognized across restarts.
+type DedupConfig struct {
+	// Window is how long a client order ID is remembered after it was
+	// accepted, zero to remember every ID. IDs of open orders are remembered
+	// until the orders end, then for another window at most. Once forgotten,
+	// an ID can be used for a new order.
+	Window time.Duration
+}
+
+// client is a client order ID remembered for deduplication
+type client struct {
+	// id is the engine ID of the order
+	id uint64
+	// accepted is the accepted or replaced report of the order, zero until
+	// it is logged
+	accepted Report
+	// seen is when the ID was accepted, or last found open, in Unix
+	// nanoseconds
+	seen int64
+}
+
+// expiring is a remembered client order ID in the expiry queue
+type expiring struct {
+	key  clientKey
+	seen int64
+}
+
+// SetDedupConfig replaces the deduplication configuration. A shorter window
+// forgets the expired client order IDs on the next request.
+func (s *Server) SetDedupConfig(config DedupConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dedup = config
+
+	s.expiry = nil
+	if config.Window <= 0 {
+		return
+	}
+	for key, cl := range s.clients {
+		s.expiry = append(s.expiry, expiring{key: key, seen: cl.seen})
+	}
+	sort.Slice(s.expiry, func(i, j int) bool { return s.expiry[i].seen < s.expiry[j].seen })
+}
+
+// remember records a client order ID. It is called with the server lock held.
+func (s *Server) remember(key clientKey, cl *client) {
+	s.clients[key] = cl
+	if s.dedup.Window > 0 {
+		s.expiry = append(s.expiry, expiring{key: key, seen: cl.seen})
+	}
+}
+
+// expire forgets the client order IDs of ended orders accepted before the
+// window. It is called with the server lock held.
+func (s *Server) expire(now int64) {
+	if s.dedup.Window <= 0 {
+		return
+	}
+	cutoff := now - int64(s.dedup.Window)
+	for len(s.expiry) > 0 && s.expiry[0].seen < cutoff {
+		x := s.expiry[0]
+		s.expiry = s.expiry[1:]
+		// The ID may have been released and used again since
+		cl := s.clients[x.key]
+		if cl == nil || cl.seen != x.seen {
+			continue
+		}
+		if s.orders[cl.id] != nil {
+			cl.seen = now
+			s.expiry = append(s.expiry, expiring{key: x.key, seen: now})
+			continue
+		}
+		delete(s.clients, x.key)
+	}
+}
+
+// retried answers a submit or modify request repeating an order already
+// accepted by sending its report again, and returns false for other requests
+func (s *Server) retried(participant uint32, req Request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(time.Now().UnixNano())
+
+	cl := s.clients[clientKey{participant, req.ClientOrderID}]
+	if cl == nil || cl.accepted.Type == "" || !sameOrder(cl.accepted, req) {
+		return false
+	}
+	if c := s.conns[participant]; c != nil {
+		c.send(cl.accepted)
+	}
+	return true
+}
+
+// sameOrder checks if req requests the order of an accepted or replaced
+// report
+func sameOrder(accepted Report, req Request) bool {
+	if req.Price != accepted.Price || req.Quantity != accepted.Quantity || req.OrigClientOrderID != accepted.OrigClientOrderID {
+		return false
+	}
+	if req.Type == RequestModify {
+		return true
+	}
+	return req.Symbol == accepted.Symbol && strings.EqualFold(req.Side, accepted.Side)
+}
@@ -0,0 +1,55 @@
+package gateway
+
+import (
+	"sort"
+	"time"
+)
+
+// SessionConfig controls what happens to the open orders of a participant
+// whose connection ends
+type SessionConfig struct {
+	// CancelOnDisconnect cancels the open orders of a participant when its
+	// connection closes without another one replacing it. The cancellations
+	// are reported and logged as usual, so the client sees them when it
+	// resyncs. Closing the server leaves orders open.
+	CancelOnDisconnect bool
+	// Timeout closes a connection that sent no request for this long, zero
+	// for none. Idle clients keep their connection with heartbeat requests.
+	Timeout time.Duration
+}
+
+// SetSessionConfig replaces the session configuration. It applies to the
+// next request read on each connection.
+func (s *Server) SetSessionConfig(config SessionConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.session = config
+}
+
+// sessionConfig returns the session configuration
+func (s *Server) sessionConfig() SessionConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.session
+}
+
+// openOrders returns the engine IDs of the open orders of a participant in
+// ID order. It is called with the server lock held.
+func (s *Server) openOrders(participant uint32) []uint64 {
+	var ids []uint64
+	for id, e := range s.orders {
+		if e.participant == participant && !e.replaced {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// cancelOrders cancels orders, skipping those that executed or were canceled
+// meanwhile
+func (s *Server) cancelOrders(ids []uint64) {
+	for _, id := range ids {
+		_ = s.manager.CancelOrder(id)
+	}
+}
@@ -0,0 +1,214 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// Health statuses
+const (
+	HealthOK   = "ok"
+	HealthFail = "fail"
+)
+
+// HealthConfig configures the readiness checks of a server
+type HealthConfig struct {
+	// MaxSnapshotAge fails readiness when the newest snapshot is older, or
+	// missing once the server has run that long; 0 disables the check
+	MaxSnapshotAge time.Duration
+	// MaxBookStaleness marks books without market data for longer as stale
+	// and fails readiness while one is; 0 only reports the book ages
+	MaxBookStaleness time.Duration
+	// Now returns the current time, time.Now if nil
+	Now func() time.Time
+}
+
+// CheckStatus is the result of a health check
+type CheckStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BookStatus is the market data activity of an order book
+type BookStatus struct {
+	Symbol string `json:"symbol"`
+	// LastUpdate is the time of the last market data event of the book in
+	// Unix nanoseconds, 0 if there was none since the server started
+	LastUpdate int64 `json:"last_update"`
+	// Age is the time since the last event, or since the server started, in
+	// nanoseconds
+	Age   time.Duration `json:"age"`
+	Stale bool          `json:"stale,omitempty"`
+}
+
+// HealthStatus is the response of the health and readiness endpoints
+type HealthStatus struct {
+	Status string        `json:"status"`
+	Checks []CheckStatus `json:"checks"`
+	Books  []BookStatus  `json:"books,omitempty"`
+}
+
+// healthCheck is a readiness check added with AddHealthCheck
+type healthCheck struct {
+	name  string
+	check func() error
+}
+
+// SetHealthConfig configures the readiness checks
+func (s *Server) SetHealthConfig(config HealthConfig) {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	s.health = config
+}
+
+// AddHealthCheck adds a readiness check, such as the sequence gap status of a
+// market data feed. check returns an error while the component is not ready.
+func (s *Server) AddHealthCheck(name string, check func() error) {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	s.checks = append(s.checks, healthCheck{name: name, check: check})
+}
+
+// touchBook records market data activity of a book
+func (s *Server) touchBook(symbolID uint32) {
+	s.healthMu.Lock()
+	s.bookUpdates[symbolID] = s.now()
+	s.healthMu.Unlock()
+}
+
+// now returns the current time of the health clock. It must be called with
+// healthMu held.
+func (s *Server) now() time.Time {
+	if s.health.Now != nil {
+		return s.health.Now()
+	}
+	return time.Now()
+}
+
+// Liveness checks that the server is open and its journal still writable.
+// A failed liveness calls for a restart.
+func (s *Server) Liveness() HealthStatus {
+	status := HealthStatus{Status: HealthOK}
+	status.add("server", s.checkOpen())
+	status.add("journal", s.manager.Health().JournalErr)
+	return status
+}
+
+// Readiness checks liveness, the age of the newest snapshot, the checks added
+// with AddHealthCheck and the staleness of every order book. A failed
+// readiness calls for routing traffic elsewhere.
+func (s *Server) Readiness() HealthStatus {
+	status := s.Liveness()
+	health := s.manager.Health()
+
+	s.healthMu.Lock()
+	config := s.health
+	checks := append([]healthCheck(nil), s.checks...)
+	now := s.now()
+	updates := make(map[uint32]time.Time, len(s.bookUpdates))
+	for id, t := range s.bookUpdates {
+		updates[id] = t
+	}
+	started := s.started
+	s.healthMu.Unlock()
+
+	if config.MaxSnapshotAge > 0 {
+		err := health.SnapshotErr
+		if err == nil {
+			last := health.LastSnapshot
+			if last.IsZero() {
+				last = started
+			}
+			if age := now.Sub(last); age > config.MaxSnapshotAge {
+				err = fmt.Errorf("last snapshot %s ago", age.Round(time.Second))
+			}
+		}
+		status.add("snapshot", err)
+	}
+	for _, c := range checks {
+		status.add(c.name, c.check())
+	}
+
+	var stale int
+	s.manager.View(func(mm *matching.MarketManager) {
+		for id, ob := range mm.OrderBooks() {
+			book := BookStatus{Symbol: ob.Symbol().Name}
+			last, ok := updates[id]
+			if ok {
+				book.LastUpdate = last.UnixNano()
+			} else {
+				last = started
+			}
+			book.Age = now.Sub(last)
+			if config.MaxBookStaleness > 0 && book.Age > config.MaxBookStaleness {
+				book.Stale = true
+				stale++
+			}
+			status.Books = append(status.Books, book)
+		}
+	})
+	sort.Slice(status.Books, func(i, j int) bool { return status.Books[i].Symbol < status.Books[j].Symbol })
+	if config.MaxBookStaleness > 0 {
+		var err error
+		if stale > 0 {
+			err = fmt.Errorf("%d stale books", stale)
+		}
+		status.add("books", err)
+	}
+	return status
+}
+
+// checkOpen returns an error once the server is closed
+func (s *Server) checkOpen() error {
+	select {
+	case <-s.done:
+		return fmt.Errorf("closed")
+	default:
+		return nil
+	}
+}
+
+// add records the result of a check, failing the status on error
+func (h *HealthStatus) add(name string, err error) {
+	c := CheckStatus{Name: name, Status: HealthOK}
+	if err != nil {
+		c.Status = HealthFail
+		c.Error = err.Error()
+		h.Status = HealthFail
+	}
+	h.Checks = append(h.Checks, c)
+}
+
+// HealthHandler returns the HTTP handler of the liveness endpoint. It answers
+// 200 with a HealthStatus while the server is live, 503 otherwise.
+func (s *Server) HealthHandler() http.Handler {
+	return healthHandler(s.Liveness)
+}
+
+// ReadyHandler returns the HTTP handler of the readiness endpoint. It answers
+// 200 with a HealthStatus while the server is ready, 503 otherwise.
+func (s *Server) ReadyHandler() http.Handler {
+	return healthHandler(s.Readiness)
+}
+
+// healthHandler serves a health status
+func healthHandler(status func() HealthStatus) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		st := status()
+		w.Header().Set("Content-Type", "application/json")
+		if st.Status != HealthOK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(st)
+	})
+}
@@ -0,0 +1,82 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func getHealth(t *testing.T, url string) (int, HealthStatus) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer resp.Body.Close()
+	var status HealthStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	return resp.StatusCode, status
+}
+
+func TestServer_Health(t *testing.T) {
+	ts := startServer(t, t.TempDir())
+	defer ts.stop(t)
+	routes := httptest.NewServer(ts.Routes())
+	defer routes.Close()
+
+	now := time.Unix(1000, 0)
+	ts.started = now
+	ts.SetHealthConfig(HealthConfig{
+		MaxSnapshotAge:   time.Hour,
+		MaxBookStaleness: time.Minute,
+		Now:              func() time.Time { return now },
+	})
+
+	code, status := getHealth(t, routes.URL+"/readyz")
+	if code != http.StatusOK || status.Status != HealthOK {
+		t.Fatalf("Expected ready, got %d %+v", code, status)
+	}
+	if len(status.Books) != 1 || status.Books[0].Symbol != "AAPL" || status.Books[0].LastUpdate != 0 {
+		t.Errorf("Expected AAPL without updates, got %+v", status.Books)
+	}
+
+	// The book goes stale without market data
+	now = now.Add(2 * time.Minute)
+	code, status = getHealth(t, routes.URL+"/readyz")
+	if code != http.StatusServiceUnavailable || !status.Books[0].Stale {
+		t.Errorf("Expected a stale book, got %d %+v", code, status)
+	}
+	ts.touchBook(1)
+	code, status = getHealth(t, routes.URL+"/readyz")
+	if code != http.StatusOK || status.Books[0].LastUpdate != now.UnixNano() || status.Books[0].Stale {
+		t.Errorf("Expected a fresh book, got %d %+v", code, status)
+	}
+
+	// No snapshot within MaxSnapshotAge of the start
+	now = now.Add(time.Hour)
+	ts.touchBook(1)
+	code, status = getHealth(t, routes.URL+"/readyz")
+	if code != http.StatusServiceUnavailable || status.Checks[2].Name != "snapshot" || status.Checks[2].Status != HealthFail {
+		t.Errorf("Expected the snapshot check to fail, got %d %+v", code, status)
+	}
+	ts.SetHealthConfig(HealthConfig{Now: func() time.Time { return now }})
+
+	ts.AddHealthCheck("feed", func() error { return errors.New("gap") })
+	code, status = getHealth(t, routes.URL+"/readyz")
+	if code != http.StatusServiceUnavailable || status.Checks[len(status.Checks)-1].Error != "gap" {
+		t.Errorf("Expected the feed check to fail, got %d %+v", code, status)
+	}
+	if code, _ := getHealth(t, routes.URL+"/healthz"); code != http.StatusOK {
+		t.Errorf("Expected live, got %d", code)
+	}
+
+	ts.Close()
+	if code, status := getHealth(t, routes.URL+"/healthz"); code != http.StatusServiceUnavailable || status.Checks[0].Status != HealthFail {
+		t.Errorf("Expected not live once closed, got %d %+v", code, status)
+	}
+}
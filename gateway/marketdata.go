@@ -0,0 +1,327 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/persistence"
+)
+
+// marketDataBatch is the number of logged events read at once by a stream
+const marketDataBatch = 1024
+
+// marketDataPoll bounds the wait for new events of a stream, in case a
+// notification was missed because the market data log was attached after the
+// server was created
+const marketDataPoll = 100 * time.Millisecond
+
+// notifyMarketData wakes up the market data streams
+func (s *Server) notifyMarketData() {
+	s.mdMu.Lock()
+	close(s.mdReady)
+	s.mdReady = make(chan struct{})
+	s.mdMu.Unlock()
+}
+
+// marketDataReady returns a channel closed by the next notification
+func (s *Server) marketDataReady() <-chan struct{} {
+	s.mdMu.Lock()
+	defer s.mdMu.Unlock()
+	return s.mdReady
+}
+
+// symbolID returns the ID of a symbol with an order book
+func (s *Server) symbolID(name string) (uint32, bool) {
+	var id uint32
+	var ok bool
+	s.manager.View(func(mm *matching.MarketManager) {
+		if sym := mm.GetSymbolByName(name); sym != nil && mm.GetOrderBook(sym.ID) != nil {
+			id, ok = sym.ID, true
+		}
+	})
+	return id, ok
+}
+
+// marketData converts a logged event of a symbol
+func marketData(symbol string, e persistence.MarketDataEvent) MarketData {
+	md := MarketData{Sequence: e.Sequence, Timestamp: e.Timestamp, Symbol: symbol}
+	switch e.Type {
+	case persistence.MarketDataTrade:
+		md.Type = MarketDataTrade
+		md.Price = e.Trade.Price
+		md.Quantity = e.Trade.Quantity
+		md.Aggressor = formatSide(e.Trade.Aggressor)
+		return md
+	case persistence.MarketDataLevelAdd:
+		md.Type = MarketDataLevelAdd
+	case persistence.MarketDataLevelUpdate:
+		md.Type = MarketDataLevelUpdate
+	case persistence.MarketDataLevelDelete:
+		md.Type = MarketDataLevelDelete
+	}
+	md.Price = e.Level.Price
+	md.Side = formatLevelSide(e.Level.Type)
+	md.Volume = e.Level.VisibleVolume
+	md.Orders = e.Level.Orders
+	md.Top = e.Top
+	return md
+}
+
+// levelKey identifies a price level of a symbol
+type levelKey struct {
+	side  string
+	price uint64
+}
+
+// conflater coalesces the level changes of a stream between two sends, so
+// that a slow client receives the latest state of each level instead of
+// every change. Trades are all kept.
+type conflater struct {
+	levels map[levelKey]MarketData
+	trades []MarketData
+}
+
+func newConflater() *conflater {
+	return &conflater{levels: make(map[levelKey]MarketData)}
+}
+
+// add merges an event with the pending change of its level
+func (c *conflater) add(md MarketData) {
+	if md.Type == MarketDataTrade {
+		c.trades = append(c.trades, md)
+		return
+	}
+	key := levelKey{md.Side, md.Price}
+	prev, ok := c.levels[key]
+	if ok {
+		switch {
+		case prev.Type == MarketDataLevelAdd && md.Type == MarketDataLevelDelete:
+			// The client never saw the level
+			delete(c.levels, key)
+			return
+		case prev.Type == MarketDataLevelAdd:
+			md.Type = MarketDataLevelAdd
+		case prev.Type == MarketDataLevelDelete && md.Type == MarketDataLevelAdd:
+			md.Type = MarketDataLevelUpdate
+		}
+	}
+	c.levels[key] = md
+}
+
+// len returns the number of pending events
+func (c *conflater) len() int {
+	return len(c.levels) + len(c.trades)
+}
+
+// flush returns the pending events in sequence order and clears them. A
+// conflated level change carries the sequence of its last event.
+func (c *conflater) flush() []MarketData {
+	events := append([]MarketData(nil), c.trades...)
+	for _, md := range c.levels {
+		events = append(events, md)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Sequence < events[j].Sequence })
+	c.trades = c.trades[:0]
+	clear(c.levels)
+	return events
+}
+
+// formatLevelSide converts a level type for market data
+func formatLevelSide(t matching.LevelType) string {
+	if t == matching.LevelTypeBid {
+		return "bid"
+	}
+	return "ask"
+}
+
+// MarketDataHandler returns the HTTP handler of the market data stream.
+// Clients connect with the symbol query parameter and receive its trades and
+// level changes as MarketData messages. With from, the stream starts at that
+// market data sequence, replaying logged events first; without it, the stream
+// starts with the next event. The manager must have a market data log
+// attached, preferably before the server is created.
+//
+// With rate, the stream is conflated for slow clients: changes of the same
+// price level are coalesced into one message with the latest state, and
+// messages are sent at most rate times per second. Trades are never
+// conflated, and messages keep increasing sequence numbers.
+func (s *Server) MarketDataHandler() http.Handler {
+	upgrader := websocket.Upgrader{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := s.manager.MarketDataLog()
+		if log == nil {
+			http.Error(w, "market data is not available", http.StatusNotFound)
+			return
+		}
+		symbol := r.URL.Query().Get("symbol")
+		id, ok := s.symbolID(symbol)
+		if !ok {
+			http.Error(w, "unknown symbol", http.StatusNotFound)
+			return
+		}
+		next := log.LastSequence() + 1
+		if v := r.URL.Query().Get("from"); v != "" {
+			from, err := strconv.ParseUint(v, 10, 64)
+			if err != nil || from == 0 {
+				http.Error(w, "invalid from", http.StatusBadRequest)
+				return
+			}
+			next = from
+		}
+		var conflate *conflater
+		var interval time.Duration
+		if v := r.URL.Query().Get("rate"); v != "" {
+			rate, err := strconv.Atoi(v)
+			if err != nil || rate <= 0 {
+				http.Error(w, "invalid rate", http.StatusBadRequest)
+				return
+			}
+			conflate = newConflater()
+			interval = time.Second / time.Duration(rate)
+		}
+
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+
+		// The reader only notices the client going away
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := ws.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		var lastSend time.Time
+		for {
+			// Take the notification channel first so no event is missed
+			// between reading the log and waiting
+			ready := s.marketDataReady()
+			events, err := log.ReadFrom(next, marketDataBatch)
+			if err != nil {
+				msg := websocket.FormatCloseMessage(websocket.CloseInternalServerErr, err.Error())
+				if errors.Is(err, persistence.ErrSequenceUnavailable) {
+					msg = websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error())
+				}
+				_ = ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+				return
+			}
+			for _, e := range events {
+				next = e.Sequence + 1
+				if e.SymbolID != id {
+					continue
+				}
+				if conflate != nil {
+					conflate.add(marketData(symbol, e))
+					continue
+				}
+				if err := ws.WriteJSON(marketData(symbol, e)); err != nil {
+					return
+				}
+			}
+			if len(events) == marketDataBatch {
+				continue
+			}
+
+			// Conflated events are sent once the stream has caught up with
+			// the log and the interval since the last send has passed
+			var due <-chan time.Time
+			if conflate != nil && conflate.len() > 0 {
+				if wait := interval - time.Since(lastSend); wait > 0 {
+					due = time.After(wait)
+				} else {
+					lastSend = time.Now()
+					for _, md := range conflate.flush() {
+						if err := ws.WriteJSON(md); err != nil {
+							return
+						}
+					}
+					continue
+				}
+			}
+			select {
+			case <-ready:
+			case <-due:
+			case <-time.After(marketDataPoll):
+			case <-closed:
+				return
+			case <-s.done:
+				return
+			}
+		}
+	})
+}
+
+// DepthHandler returns the HTTP handler of depth snapshots. GET with the
+// symbol query parameter returns a Depth, best levels first. Level events of
+// the market data stream after its Sequence apply on top of it.
+func (s *Server) DepthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		symbol := r.URL.Query().Get("symbol")
+		depth := Depth{Symbol: symbol, Bids: []DepthLevel{}, Asks: []DepthLevel{}}
+		log := s.manager.MarketDataLog()
+		var found bool
+		s.manager.View(func(mm *matching.MarketManager) {
+			sym := mm.GetSymbolByName(symbol)
+			if sym == nil {
+				return
+			}
+			ob := mm.GetOrderBook(sym.ID)
+			if ob == nil {
+				return
+			}
+			found = true
+			// Events are logged under the manager lock, so the sequence
+			// matches the book
+			if log != nil {
+				depth.Sequence = log.LastSequence()
+			}
+			ob.Bids().ForEach(func(level *matching.LevelNode) bool {
+				depth.Bids = append(depth.Bids, DepthLevel{Price: level.Price, Volume: level.VisibleVolume, Orders: level.Orders})
+				return true
+			})
+			ob.Asks().ForEach(func(level *matching.LevelNode) bool {
+				depth.Asks = append(depth.Asks, DepthLevel{Price: level.Price, Volume: level.VisibleVolume, Orders: level.Orders})
+				return true
+			})
+		})
+		if !found {
+			http.Error(w, "unknown symbol", http.StatusNotFound)
+			return
+		}
+		sort.Slice(depth.Bids, func(i, j int) bool { return depth.Bids[i].Price > depth.Bids[j].Price })
+		sort.Slice(depth.Asks, func(i, j int) bool { return depth.Asks[i].Price < depth.Asks[j].Price })
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(depth)
+	})
+}
+
+// Routes returns a handler serving order entry at /orders, the market data
+// stream at /marketdata, depth snapshots at /depth, liveness and readiness
+// at /healthz and /readyz, and the admin API under /admin/
+func (s *Server) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/orders", s.Handler())
+	mux.Handle("/marketdata", s.MarketDataHandler())
+	mux.Handle("/depth", s.DepthHandler())
+	mux.Handle("/healthz", s.HealthHandler())
+	mux.Handle("/readyz", s.ReadyHandler())
+	mux.Handle("/admin/", s.AdminHandler())
+	return mux
+}
@@ -0,0 +1,221 @@
+// Package gateway serves order entry for a persisted matching engine.
+//
+// Clients connect over WebSocket as a participant and exchange JSON messages:
+// they submit, cancel and modify orders identified by their own client order
+// IDs, and receive execution reports numbered by a per-participant sequence.
+// Reports are kept in a ReportLog next to the order journal, so a client that
+// reconnects, even to a restarted server, resyncs by asking for the reports
+// after the last sequence it processed.
+package gateway
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// Request types sent by clients
+const (
+	// RequestSubmit enters a new order
+	RequestSubmit = "submit"
+	// RequestCancel cancels an open order
+	RequestCancel = "cancel"
+	// RequestModify replaces an open order with a new price and quantity
+	RequestModify = "modify"
+	// RequestResync replays the reports after a sequence number
+	RequestResync = "resync"
+	// RequestHeartbeat keeps an idle connection open under a session timeout
+	RequestHeartbeat = "heartbeat"
+)
+
+// Request is a client order entry message
+type Request struct {
+	// Type is the request type (Request*)
+	Type string `json:"type"`
+	// ClientOrderID identifies the order for the participant. For a modify it
+	// is the ID of the replacement order.
+	ClientOrderID string `json:"client_order_id,omitempty"`
+	// OrigClientOrderID is the order to cancel or modify
+	OrigClientOrderID string `json:"orig_client_order_id,omitempty"`
+
+	// Symbol is the symbol name of a new order
+	Symbol string `json:"symbol,omitempty"`
+	// Side is "buy" or "sell"
+	Side string `json:"side,omitempty"`
+	// OrderType is "limit" (default) or "market"
+	OrderType string `json:"order_type,omitempty"`
+	// TimeInForce is "gtc" (default), "ioc", "fok", "aon" or "day"
+	TimeInForce string `json:"time_in_force,omitempty"`
+	// Price is the limit price of a new or modified order
+	Price uint64 `json:"price,omitempty"`
+	// Quantity is the quantity of a new or modified order
+	Quantity uint64 `json:"quantity,omitempty"`
+	// MinQuantity is the minimum execution quantity of a new order
+	MinQuantity uint64 `json:"min_quantity,omitempty"`
+	// Account is the clearing account a new order settles to, 0 for the
+	// default account
+	Account uint32 `json:"account,omitempty"`
+	// Quote marks a new order as a market maker quote, which expires after
+	// the quote_ttl of its symbol
+	Quote bool `json:"quote,omitempty"`
+
+	// LastSequence is the last report sequence the client processed (resync)
+	LastSequence uint64 `json:"last_sequence,omitempty"`
+}
+
+// Execution report types sent by the server
+const (
+	// ReportAccepted acknowledges a new or replacement order
+	ReportAccepted = "accepted"
+	// ReportRejected rejects a request; Reason explains why
+	ReportRejected = "rejected"
+	// ReportFill is a partial or full execution of an order
+	ReportFill = "fill"
+	// ReportCanceled is an order removed before it was fully executed
+	ReportCanceled = "canceled"
+	// ReportReplaced is an order replaced by a modify request
+	ReportReplaced = "replaced"
+	// ReportResynced ends the replay of a resync request; Sequence is the
+	// last sequence of the participant
+	ReportResynced = "resynced"
+)
+
+// Report is an execution report
+type Report struct {
+	// Sequence numbers the reports of a participant from 1, without gaps.
+	// Rejections of malformed requests and resync markers are not logged and
+	// have sequence 0.
+	Sequence uint64 `json:"sequence"`
+	// Type is the report type (Report*)
+	Type string `json:"type"`
+	// Timestamp is the time of the report in Unix nanoseconds
+	Timestamp int64 `json:"timestamp"`
+	// Participant is the participant owning the order
+	Participant uint32 `json:"participant"`
+
+	ClientOrderID     string `json:"client_order_id,omitempty"`
+	OrigClientOrderID string `json:"orig_client_order_id,omitempty"`
+	// OrderID is the engine order ID
+	OrderID uint64 `json:"order_id,omitempty"`
+	Symbol  string `json:"symbol,omitempty"`
+	Side    string `json:"side,omitempty"`
+	Price   uint64 `json:"price,omitempty"`
+
+	Quantity         uint64 `json:"quantity,omitempty"`
+	ExecutedQuantity uint64 `json:"executed_quantity,omitempty"`
+	LeavesQuantity   uint64 `json:"leaves_quantity,omitempty"`
+	// LastPrice and LastQuantity describe the execution of a fill
+	LastPrice    uint64 `json:"last_price,omitempty"`
+	LastQuantity uint64 `json:"last_quantity,omitempty"`
+
+	// Reason is the rejection reason
+	Reason string `json:"reason,omitempty"`
+}
+
+// parseSide converts a request side
+func parseSide(s string) (matching.OrderSide, error) {
+	switch strings.ToLower(s) {
+	case "buy":
+		return matching.OrderSideBuy, nil
+	case "sell":
+		return matching.OrderSideSell, nil
+	default:
+		return 0, fmt.Errorf("invalid side %q", s)
+	}
+}
+
+// formatSide converts an order side for reports
+func formatSide(side matching.OrderSide) string {
+	if side == matching.OrderSideBuy {
+		return "buy"
+	}
+	return "sell"
+}
+
+// parseOrderType converts a request order type
+func parseOrderType(s string) (matching.OrderType, error) {
+	switch strings.ToLower(s) {
+	case "", "limit":
+		return matching.OrderTypeLimit, nil
+	case "market":
+		return matching.OrderTypeMarket, nil
+	default:
+		return 0, fmt.Errorf("invalid order type %q", s)
+	}
+}
+
+// parseTimeInForce converts a request time in force
+func parseTimeInForce(s string) (matching.OrderTimeInForce, error) {
+	switch strings.ToLower(s) {
+	case "", "gtc":
+		return matching.OrderTimeInForceGTC, nil
+	case "ioc":
+		return matching.OrderTimeInForceIOC, nil
+	case "fok":
+		return matching.OrderTimeInForceFOK, nil
+	case "aon":
+		return matching.OrderTimeInForceAON, nil
+	case "day":
+		return matching.OrderTimeInForceDay, nil
+	default:
+		return 0, fmt.Errorf("invalid time in force %q", s)
+	}
+}
+
+// Market data event types
+const (
+	// MarketDataTrade is a trade
+	MarketDataTrade = "trade"
+	// MarketDataLevelAdd is a new price level
+	MarketDataLevelAdd = "level_add"
+	// MarketDataLevelUpdate is a change of a price level
+	MarketDataLevelUpdate = "level_update"
+	// MarketDataLevelDelete is a price level without orders left
+	MarketDataLevelDelete = "level_delete"
+)
+
+// MarketData is a public market data event
+type MarketData struct {
+	// Sequence is the position of the event in the market data log, shared
+	// by all symbols
+	Sequence uint64 `json:"sequence"`
+	// Type is the event type (MarketData*)
+	Type string `json:"type"`
+	// Timestamp is the time of the event in Unix nanoseconds
+	Timestamp int64  `json:"timestamp"`
+	Symbol    string `json:"symbol"`
+	// Price is the trade or level price
+	Price uint64 `json:"price"`
+
+	// Quantity is the traded quantity
+	Quantity uint64 `json:"quantity,omitempty"`
+	// Aggressor is the side that took liquidity in a trade
+	Aggressor string `json:"aggressor,omitempty"`
+
+	// Side is "bid" or "ask" for level events
+	Side string `json:"side,omitempty"`
+	// Volume is the displayed volume of the level
+	Volume uint64 `json:"volume,omitempty"`
+	// Orders is the number of orders at the level
+	Orders uint64 `json:"orders,omitempty"`
+	// Top is true if the level is the best bid or ask
+	Top bool `json:"top,omitempty"`
+}
+
+// DepthLevel is a price level of a depth snapshot
+type DepthLevel struct {
+	Price  uint64 `json:"price"`
+	Volume uint64 `json:"volume"`
+	Orders uint64 `json:"orders"`
+}
+
+// Depth is a snapshot of the displayed levels of an order book
+type Depth struct {
+	Symbol string `json:"symbol"`
+	// Sequence is the last market data sequence reflected in the snapshot;
+	// level events after it apply on top of the snapshot
+	Sequence uint64       `json:"sequence"`
+	Bids     []DepthLevel `json:"bids"`
+	Asks     []DepthLevel `json:"asks"`
+}
@@ -0,0 +1,143 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ReportLog is an append-only file of execution reports, one JSON document
+// per line, with the reports of every participant held in memory for resync.
+// A torn last line left by a crash is truncated away on open.
+type ReportLog struct {
+	mu      sync.Mutex
+	file    *os.File
+	writer  *bufio.Writer
+	reports map[uint32][]Report
+}
+
+// OpenReportLog opens (or creates) the report log at path and loads the
+// reports it already contains
+func OpenReportLog(path string) (*ReportLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l := &ReportLog{file: f, reports: make(map[uint32][]Report)}
+
+	valid, err := l.load()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if err := f.Truncate(valid); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	l.writer = bufio.NewWriter(f)
+	return l, nil
+}
+
+// load reads every complete line and returns the size of the valid prefix
+func (l *ReportLog) load() (int64, error) {
+	r := bufio.NewReader(l.file)
+	var valid int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return valid, nil
+		}
+		if err != nil {
+			return valid, err
+		}
+		var report Report
+		if err := json.Unmarshal(bytes.TrimSpace(line), &report); err != nil {
+			return valid, fmt.Errorf("gateway: report log at offset %d: %w", valid, err)
+		}
+		l.reports[report.Participant] = append(l.reports[report.Participant], report)
+		valid += int64(len(line))
+	}
+}
+
+// Append assigns the next sequence number of the participant to report and
+// writes it to the log. The report is written through to the file.
+func (l *ReportLog) Append(report Report) (Report, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	report.Sequence = uint64(len(l.reports[report.Participant])) + 1
+	line, err := json.Marshal(report)
+	if err != nil {
+		return Report{}, err
+	}
+	line = append(line, '\n')
+	if _, err := l.writer.Write(line); err != nil {
+		return Report{}, err
+	}
+	if err := l.writer.Flush(); err != nil {
+		return Report{}, err
+	}
+	l.reports[report.Participant] = append(l.reports[report.Participant], report)
+	return report, nil
+}
+
+// Since returns the reports of a participant after sequence seq
+func (l *ReportLog) Since(participant uint32, seq uint64) []Report {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	reports := l.reports[participant]
+	if seq >= uint64(len(reports)) {
+		return nil
+	}
+	return append([]Report(nil), reports[seq:]...)
+}
+
+// LastSequence returns the sequence number of the last report of a
+// participant, 0 if there is none
+func (l *ReportLog) LastSequence(participant uint32) uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return uint64(len(l.reports[participant]))
+}
+
+// Participants returns the participants with reports, in ascending order
+func (l *ReportLog) Participants() []uint32 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	participants := make([]uint32, 0, len(l.reports))
+	for p := range l.reports {
+		participants = append(participants, p)
+	}
+	sort.Slice(participants, func(i, j int) bool { return participants[i] < participants[j] })
+	return participants
+}
+
+// Flush forces written reports to disk (fsync)
+func (l *ReportLog) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.writer.Flush(); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// Close flushes the log and closes the underlying file
+func (l *ReportLog) Close() error {
+	err := l.Flush()
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+package gateway
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// RiskLimits are the pre-trade limits applied to every participant. A zero
+// limit is not checked.
+type RiskLimits struct {
+	// MaxOrderQuantity is the largest quantity of an order
+	MaxOrderQuantity uint64
+	// MaxOrderNotional is the largest price × quantity of a priced order
+	MaxOrderNotional uint64
+	// MaxOpenOrders is the largest number of open orders of a participant
+	MaxOpenOrders int
+}
+
+// SetRiskLimits replaces the pre-trade limits. Open orders are not affected.
+func (s *Server) SetRiskLimits(limits RiskLimits) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.risk = limits
+}
+
+// checkRisk returns the reason an order of price and quantity breaks the risk
+// limits, or an empty string. New orders count against the open order limit,
+// replacements do not.
+func (s *Server) checkRisk(participant uint32, price, quantity uint64, replacement bool) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limits := s.risk
+	if limits.MaxOrderQuantity != 0 && quantity > limits.MaxOrderQuantity {
+		return fmt.Sprintf("quantity above limit %d", limits.MaxOrderQuantity)
+	}
+	if limits.MaxOrderNotional != 0 && price != 0 {
+		hi, notional := bits.Mul64(price, quantity)
+		if hi != 0 || notional > limits.MaxOrderNotional {
+			return fmt.Sprintf("notional above limit %d", limits.MaxOrderNotional)
+		}
+	}
+	if limits.MaxOpenOrders != 0 && !replacement {
+		var open int
+		for _, e := range s.orders {
+			if e.participant == participant {
+				open++
+			}
+		}
+		if open >= limits.MaxOpenOrders {
+			return fmt.Sprintf("open orders at limit %d", limits.MaxOpenOrders)
+		}
+	}
+	return ""
+}
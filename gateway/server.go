@@ -0,0 +1,465 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/persistence"
+)
+
+// clientKey identifies an order by its client order ID
+type clientKey struct {
+	participant   uint32
+	clientOrderID string
+}
+
+// entry is the gateway state of an open order
+type entry struct {
+	participant       uint32
+	clientOrderID     string
+	origClientOrderID string
+	symbol            string
+	// replaced is set while the order is canceled by a modify request
+	replaced bool
+}
+
+// Server accepts orders from participants, submits them through a
+// persistence.Manager and reports their executions.
+//
+// The server installs a handler on the manager's MarketManager that turns
+// engine events into execution reports, so orders must only be entered
+// through the server while it runs.
+type Server struct {
+	manager *persistence.Manager
+	reports *ReportLog
+
+	mu sync.Mutex
+	// orders maps the engine IDs of open orders to their gateway state
+	orders map[uint64]*entry
+	// clients maps the client order IDs remembered for deduplication, every
+	// one accepted unless a window is set, to their orders
+	clients map[clientKey]*client
+	// expiry is the remembered client order IDs in the order they expire,
+	// kept while a deduplication window is set
+	expiry []expiring
+	// conns is the live connection of each participant
+	conns map[uint32]*conn
+
+	// mdReady is closed and replaced when market data is published
+	mdMu    sync.Mutex
+	mdReady chan struct{}
+	// done is closed by Close to end the market data streams
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// healthMu guards the health configuration and book activity
+	healthMu sync.Mutex
+	health   HealthConfig
+	checks   []healthCheck
+	started  time.Time
+	// bookUpdates is the time of the last market data event of each book
+	bookUpdates map[uint32]time.Time
+
+	// risk is guarded by mu
+	risk RiskLimits
+	// session is guarded by mu
+	session SessionConfig
+	// dedup is guarded by mu
+	dedup DedupConfig
+	// admin is guarded by mu
+	admin AdminConfig
+}
+
+// NewServer creates a server entering orders through manager and logging
+// reports to reports. Client order IDs and open orders are restored from the
+// report log, so the manager must have been recovered first.
+func NewServer(manager *persistence.Manager, reports *ReportLog) *Server {
+	s := &Server{
+		manager: manager,
+		reports: reports,
+		orders:  make(map[uint64]*entry),
+		clients: make(map[clientKey]*client),
+		conns:   make(map[uint32]*conn),
+		mdReady: make(chan struct{}),
+		done:    make(chan struct{}),
+
+		started:     time.Now(),
+		bookUpdates: make(map[uint32]time.Time),
+	}
+
+	manager.View(func(mm *matching.MarketManager) {
+		ids := mm.OrderIDAllocator()
+		for _, participant := range reports.Participants() {
+			for _, r := range reports.Since(participant, 0) {
+				if r.Type != ReportAccepted && r.Type != ReportReplaced {
+					continue
+				}
+				ids.Observe(r.OrderID)
+				s.clients[clientKey{participant, r.ClientOrderID}] = &client{id: r.OrderID, accepted: r, seen: r.Timestamp}
+				if mm.GetOrder(r.OrderID) != nil {
+					s.orders[r.OrderID] = &entry{participant: participant, clientOrderID: r.ClientOrderID, symbol: r.Symbol}
+				}
+			}
+		}
+		mm.SetHandler(&reportHandler{MarketHandler: mm.Handler(), s: s})
+	})
+	return s
+}
+
+// emit logs a report and delivers it to the participant's connection
+func (s *Server) emit(r Report) {
+	r.Timestamp = time.Now().UnixNano()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	logged, err := s.reports.Append(r)
+	if err != nil {
+		// The report is still delivered; a resync will not find it
+		logged = r
+	}
+	if r.Type == ReportAccepted || r.Type == ReportReplaced {
+		if cl := s.clients[clientKey{r.Participant, r.ClientOrderID}]; cl != nil {
+			cl.accepted = logged
+		}
+	}
+	if c := s.conns[r.Participant]; c != nil {
+		c.send(logged)
+	}
+}
+
+// reject logs a rejection of a well-formed request
+func (s *Server) reject(participant uint32, req Request, reason string) {
+	s.emit(Report{
+		Type:              ReportRejected,
+		Participant:       participant,
+		ClientOrderID:     req.ClientOrderID,
+		OrigClientOrderID: req.OrigClientOrderID,
+		Symbol:            req.Symbol,
+		Reason:            reason,
+	})
+}
+
+// errRequest is returned for malformed requests, which are answered without
+// being logged
+var errRequest = errors.New("gateway: invalid request")
+
+// handle processes an order entry request of a participant. Malformed
+// requests return an error wrapping errRequest; every other outcome is
+// reported.
+func (s *Server) handle(participant uint32, req Request) error {
+	switch req.Type {
+	case RequestSubmit:
+		return s.submit(participant, req)
+	case RequestCancel:
+		return s.cancel(participant, req)
+	case RequestModify:
+		return s.modify(participant, req)
+	default:
+		return fmt.Errorf("%w: unknown request type %q", errRequest, req.Type)
+	}
+}
+
+// submit enters a new order
+func (s *Server) submit(participant uint32, req Request) error {
+	if req.ClientOrderID == "" {
+		return fmt.Errorf("%w: missing client order ID", errRequest)
+	}
+	if s.retried(participant, req) {
+		return nil
+	}
+	side, err := parseSide(req.Side)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errRequest, err)
+	}
+	orderType, err := parseOrderType(req.OrderType)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errRequest, err)
+	}
+	tif, err := parseTimeInForce(req.TimeInForce)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errRequest, err)
+	}
+
+	var symbol *matching.Symbol
+	s.manager.View(func(mm *matching.MarketManager) {
+		if sym := mm.GetSymbolByName(req.Symbol); sym != nil && mm.GetOrderBook(sym.ID) != nil {
+			copied := *sym
+			symbol = &copied
+		}
+	})
+	if symbol == nil {
+		s.reject(participant, req, "unknown symbol")
+		return nil
+	}
+	if reason := s.checkRisk(participant, req.Price, req.Quantity, false); reason != "" {
+		s.reject(participant, req, reason)
+		return nil
+	}
+
+	id, ok := s.register(participant, req.ClientOrderID, "", req.Symbol)
+	if !ok {
+		s.reject(participant, req, "duplicate client order ID")
+		return nil
+	}
+	order := matching.NewOrder(id, symbol.ID, orderType, side, req.Price, 0, req.Quantity)
+	order.TimeInForce = tif
+	order.MinQuantity = req.MinQuantity
+	order.ParticipantID = participant
+	order.AccountID = req.Account
+	order.Quote = req.Quote
+	if err := s.manager.AddOrder(*order); err != nil {
+		s.unregister(participant, req.ClientOrderID, id)
+		s.reject(participant, req, err.Error())
+	}
+	return nil
+}
+
+// cancel cancels an open order
+func (s *Server) cancel(participant uint32, req Request) error {
+	if req.OrigClientOrderID == "" {
+		return fmt.Errorf("%w: missing original client order ID", errRequest)
+	}
+	id, ok := s.lookup(participant, req.OrigClientOrderID)
+	if !ok {
+		s.reject(participant, req, "unknown order")
+		return nil
+	}
+	if err := s.manager.CancelOrder(id); err != nil {
+		s.reject(participant, req, err.Error())
+	}
+	return nil
+}
+
+// modify replaces an open order with a new order of the same symbol, side and
+// time in force. The replacement loses the queue position of the original.
+func (s *Server) modify(participant uint32, req Request) error {
+	if req.ClientOrderID == "" || req.OrigClientOrderID == "" {
+		return fmt.Errorf("%w: missing client order ID", errRequest)
+	}
+	if s.retried(participant, req) {
+		return nil
+	}
+	origID, ok := s.lookup(participant, req.OrigClientOrderID)
+	if !ok {
+		s.reject(participant, req, "unknown order")
+		return nil
+	}
+	var orig matching.Order
+	var found bool
+	s.manager.View(func(mm *matching.MarketManager) {
+		if node := mm.GetOrder(origID); node != nil {
+			orig, found = node.Order, true
+		}
+	})
+	if !found {
+		s.reject(participant, req, "unknown order")
+		return nil
+	}
+	if reason := s.checkRisk(participant, req.Price, req.Quantity, true); reason != "" {
+		s.reject(participant, req, reason)
+		return nil
+	}
+
+	s.mu.Lock()
+	symbol := s.orders[origID].symbol
+	s.mu.Unlock()
+	id, ok := s.register(participant, req.ClientOrderID, req.OrigClientOrderID, symbol)
+	if !ok {
+		s.reject(participant, req, "duplicate client order ID")
+		return nil
+	}
+
+	s.setReplaced(origID, true)
+	if err := s.manager.CancelOrder(origID); err != nil {
+		s.setReplaced(origID, false)
+		s.unregister(participant, req.ClientOrderID, id)
+		s.reject(participant, req, err.Error())
+		return nil
+	}
+
+	order := matching.NewOrder(id, orig.SymbolID, orig.Type, orig.Side, req.Price, orig.StopPrice, req.Quantity)
+	order.TimeInForce = orig.TimeInForce
+	order.MaxVisibleQuantity = orig.MaxVisibleQuantity
+	order.DisplayLowQuantity = orig.DisplayLowQuantity
+	order.DisplayHighQuantity = orig.DisplayHighQuantity
+	order.MinQuantity = min(orig.MinQuantity, req.Quantity)
+	order.ParticipantID = participant
+	order.AccountID = orig.AccountID
+	order.Quote = orig.Quote
+	if err := s.manager.AddOrder(*order); err != nil {
+		s.unregister(participant, req.ClientOrderID, id)
+		s.emit(Report{
+			Type:           ReportCanceled,
+			Participant:    participant,
+			ClientOrderID:  req.OrigClientOrderID,
+			OrderID:        origID,
+			Symbol:         symbol,
+			Side:           formatSide(orig.Side),
+			Price:          orig.Price,
+			Quantity:       orig.Quantity,
+			LeavesQuantity: orig.LeavesQuantity,
+		})
+		s.reject(participant, req, err.Error())
+	}
+	return nil
+}
+
+// register allocates an engine ID for a new client order ID, or returns
+// false if the participant already used it
+func (s *Server) register(participant uint32, clientOrderID, origClientOrderID, symbol string) (uint64, bool) {
+	key := clientKey{participant, clientOrderID}
+	if s.registered(key) {
+		return 0, false
+	}
+	// The ID is allocated without the server lock, which engine events take
+	// under the manager lock
+	id := s.manager.NextOrderID()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.clients[key]; exists {
+		return 0, false
+	}
+	s.remember(key, &client{id: id, seen: time.Now().UnixNano()})
+	s.orders[id] = &entry{participant: participant, clientOrderID: clientOrderID, origClientOrderID: origClientOrderID, symbol: symbol}
+	return id, true
+}
+
+// registered checks if a client order ID was already used
+func (s *Server) registered(key clientKey) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, exists := s.clients[key]
+	return exists
+}
+
+// unregister releases the client order ID of an order the engine rejected
+func (s *Server) unregister(participant uint32, clientOrderID string, id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, clientKey{participant, clientOrderID})
+	delete(s.orders, id)
+}
+
+// lookup returns the engine ID of an open order
+func (s *Server) lookup(participant uint32, clientOrderID string) (uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cl, exists := s.clients[clientKey{participant, clientOrderID}]
+	if !exists || s.orders[cl.id] == nil {
+		return 0, false
+	}
+	return cl.id, true
+}
+
+// setReplaced marks an order as being canceled by a modify request
+func (s *Server) setReplaced(id uint64, replaced bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e := s.orders[id]; e != nil {
+		e.replaced = replaced
+	}
+}
+
+// order returns the gateway state of an open order, nil for orders not
+// entered through the server
+func (s *Server) order(id uint64) *entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.orders[id]
+}
+
+// report builds a report about an order
+func (e *entry) report(reportType string, order matching.Order) Report {
+	return Report{
+		Type:             reportType,
+		Participant:      e.participant,
+		ClientOrderID:    e.clientOrderID,
+		OrderID:          order.ID,
+		Symbol:           e.symbol,
+		Side:             formatSide(order.Side),
+		Price:            order.Price,
+		Quantity:         order.Quantity,
+		ExecutedQuantity: order.ExecutedQuantity,
+		LeavesQuantity:   order.LeavesQuantity,
+	}
+}
+
+// reportHandler turns engine events about gateway orders into reports. Its
+// callbacks run under the persistence manager lock.
+type reportHandler struct {
+	matching.MarketHandler
+	s *Server
+}
+
+// OnAddOrder reports an accepted or replacement order
+func (h *reportHandler) OnAddOrder(order matching.Order) {
+	if e := h.s.order(order.ID); e != nil {
+		if e.origClientOrderID == "" {
+			h.s.emit(e.report(ReportAccepted, order))
+		} else {
+			r := e.report(ReportReplaced, order)
+			r.OrigClientOrderID = e.origClientOrderID
+			h.s.emit(r)
+		}
+	}
+	h.MarketHandler.OnAddOrder(order)
+}
+
+// OnExecuteOrder reports a fill
+func (h *reportHandler) OnExecuteOrder(order matching.Order, price, quantity uint64) {
+	if e := h.s.order(order.ID); e != nil {
+		r := e.report(ReportFill, order)
+		r.LastPrice = price
+		r.LastQuantity = quantity
+		h.s.emit(r)
+	}
+	h.MarketHandler.OnExecuteOrder(order, price, quantity)
+}
+
+// OnDeleteOrder reports a cancellation and forgets the order
+func (h *reportHandler) OnDeleteOrder(order matching.Order) {
+	if e := h.s.order(order.ID); e != nil {
+		h.s.mu.Lock()
+		delete(h.s.orders, order.ID)
+		h.s.mu.Unlock()
+		// Fully executed orders were reported by their last fill, and
+		// replaced orders by their replacement
+		if order.LeavesQuantity > 0 && !e.replaced {
+			h.s.emit(e.report(ReportCanceled, order))
+		}
+	}
+	h.MarketHandler.OnDeleteOrder(order)
+}
+
+// OnTrade records the book activity and wakes up the market data streams
+func (h *reportHandler) OnTrade(trade matching.Trade) {
+	h.MarketHandler.OnTrade(trade)
+	h.s.touchBook(trade.SymbolID)
+	h.s.notifyMarketData()
+}
+
+// OnAddLevel records the book activity and wakes up the market data streams
+func (h *reportHandler) OnAddLevel(orderBook *matching.OrderBook, level matching.Level, top bool) {
+	h.MarketHandler.OnAddLevel(orderBook, level, top)
+	h.s.touchBook(orderBook.Symbol().ID)
+	h.s.notifyMarketData()
+}
+
+// OnUpdateLevel records the book activity and wakes up the market data streams
+func (h *reportHandler) OnUpdateLevel(orderBook *matching.OrderBook, level matching.Level, top bool) {
+	h.MarketHandler.OnUpdateLevel(orderBook, level, top)
+	h.s.touchBook(orderBook.Symbol().ID)
+	h.s.notifyMarketData()
+}
+
+// OnDeleteLevel records the book activity and wakes up the market data streams
+func (h *reportHandler) OnDeleteLevel(orderBook *matching.OrderBook, level matching.Level, top bool) {
+	h.MarketHandler.OnDeleteLevel(orderBook, level, top)
+	h.s.touchBook(orderBook.Symbol().ID)
+	h.s.notifyMarketData()
+}
@@ -0,0 +1,354 @@
+package gateway
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/persistence"
+)
+
+// testServer is a gateway over a recovered persistence manager in dir
+type testServer struct {
+	*Server
+	manager *persistence.Manager
+	reports *ReportLog
+	http    *httptest.Server
+}
+
+func startServer(t *testing.T, dir string) *testServer {
+	t.Helper()
+	journal := filepath.Join(dir, "engine.journal")
+	snapshots := filepath.Join(dir, "snapshots")
+
+	mm := matching.NewMarketManager()
+	mm.EnableMatching()
+	symbol := matching.NewSymbol(1, "AAPL")
+	mm.AddSymbol(symbol)
+	mm.AddOrderBook(symbol)
+	manager, err := persistence.NewManagerWithRecovery(mm, journal, snapshots)
+	if err != nil {
+		t.Fatalf("NewManagerWithRecovery: %v", err)
+	}
+	reports, err := OpenReportLog(filepath.Join(dir, "reports.log"))
+	if err != nil {
+		t.Fatalf("OpenReportLog: %v", err)
+	}
+	s := NewServer(manager, reports)
+	return &testServer{Server: s, manager: manager, reports: reports, http: httptest.NewServer(s.Handler())}
+}
+
+func (ts *testServer) stop(t *testing.T) {
+	t.Helper()
+	ts.http.Close()
+	ts.Close()
+	if err := ts.reports.Close(); err != nil {
+		t.Fatalf("reports.Close: %v", err)
+	}
+	if err := ts.manager.Close(); err != nil {
+		t.Fatalf("manager.Close: %v", err)
+	}
+}
+
+func (ts *testServer) dial(t *testing.T, query string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(ts.http.URL, "http") + "/orders?" + query
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+func read(t *testing.T, ws *websocket.Conn) Report {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var r Report
+	if err := ws.ReadJSON(&r); err != nil {
+		t.Fatalf("ReadJSON: %v", err)
+	}
+	return r
+}
+
+func expect(t *testing.T, ws *websocket.Conn, reportType string, seq uint64) Report {
+	t.Helper()
+	r := read(t, ws)
+	if r.Type != reportType || r.Sequence != seq {
+		t.Fatalf("Expected %s report %d, got %+v", reportType, seq, r)
+	}
+	return r
+}
+
+func send(t *testing.T, ws *websocket.Conn, req Request) {
+	t.Helper()
+	if err := ws.WriteJSON(req); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+}
+
+func TestServer_OrderEntry(t *testing.T) {
+	ts := startServer(t, t.TempDir())
+	defer ts.stop(t)
+
+	seller := ts.dial(t, "participant=7")
+	expect(t, seller, ReportResynced, 0)
+	buyer := ts.dial(t, "participant=8")
+	expect(t, buyer, ReportResynced, 0)
+
+	send(t, seller, Request{Type: RequestSubmit, ClientOrderID: "s1", Symbol: "AAPL", Side: "sell", Price: 10000, Quantity: 100})
+	r := expect(t, seller, ReportAccepted, 1)
+	if r.ClientOrderID != "s1" || r.OrderID == 0 || r.LeavesQuantity != 100 || r.Symbol != "AAPL" {
+		t.Errorf("Expected s1 accepted with 100 leaves, got %+v", r)
+	}
+
+	send(t, buyer, Request{Type: RequestSubmit, ClientOrderID: "b1", Symbol: "AAPL", Side: "buy", Price: 10000, Quantity: 40})
+	expect(t, buyer, ReportAccepted, 1)
+	r = expect(t, buyer, ReportFill, 2)
+	if r.LastPrice != 10000 || r.LastQuantity != 40 || r.LeavesQuantity != 0 {
+		t.Errorf("Expected b1 fully filled, got %+v", r)
+	}
+	r = expect(t, seller, ReportFill, 2)
+	if r.ClientOrderID != "s1" || r.LeavesQuantity != 60 {
+		t.Errorf("Expected s1 partially filled, got %+v", r)
+	}
+
+	// Duplicate client order IDs and unknown symbols are rejected and logged
+	send(t, buyer, Request{Type: RequestSubmit, ClientOrderID: "b1", Symbol: "AAPL", Side: "buy", Price: 9000, Quantity: 10})
+	if r := expect(t, buyer, ReportRejected, 3); !strings.Contains(r.Reason, "duplicate") {
+		t.Errorf("Expected duplicate rejection, got %+v", r)
+	}
+	send(t, buyer, Request{Type: RequestSubmit, ClientOrderID: "b2", Symbol: "MSFT", Side: "buy", Price: 9000, Quantity: 10})
+	expect(t, buyer, ReportRejected, 4)
+	// Malformed requests are answered without a sequence number
+	send(t, buyer, Request{Type: RequestSubmit, ClientOrderID: "b3", Symbol: "AAPL", Side: "hold", Quantity: 10})
+	expect(t, buyer, ReportRejected, 0)
+	if err := buyer.WriteMessage(websocket.TextMessage, []byte("{")); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	expect(t, buyer, ReportRejected, 0)
+
+	send(t, seller, Request{Type: RequestModify, ClientOrderID: "s2", OrigClientOrderID: "s1", Price: 10100, Quantity: 50})
+	r = expect(t, seller, ReportReplaced, 3)
+	if r.ClientOrderID != "s2" || r.OrigClientOrderID != "s1" || r.Price != 10100 || r.LeavesQuantity != 50 {
+		t.Errorf("Expected s1 replaced by s2, got %+v", r)
+	}
+	send(t, seller, Request{Type: RequestCancel, OrigClientOrderID: "s1"})
+	expect(t, seller, ReportRejected, 4)
+	send(t, seller, Request{Type: RequestCancel, OrigClientOrderID: "s2"})
+	if r := expect(t, seller, ReportCanceled, 5); r.ClientOrderID != "s2" || r.LeavesQuantity != 50 {
+		t.Errorf("Expected s2 canceled, got %+v", r)
+	}
+
+	// An in-band resync replays the reports after a sequence number
+	send(t, seller, Request{Type: RequestResync, LastSequence: 3})
+	expect(t, seller, ReportRejected, 4)
+	expect(t, seller, ReportCanceled, 5)
+	expect(t, seller, ReportResynced, 5)
+}
+
+func TestServer_RiskLimits(t *testing.T) {
+	ts := startServer(t, t.TempDir())
+	defer ts.stop(t)
+	ts.SetRiskLimits(RiskLimits{MaxOrderQuantity: 100, MaxOrderNotional: 500000, MaxOpenOrders: 1})
+
+	ws := ts.dial(t, "participant=7")
+	expect(t, ws, ReportResynced, 0)
+
+	send(t, ws, Request{Type: RequestSubmit, ClientOrderID: "o1", Symbol: "AAPL", Side: "buy", Price: 1000, Quantity: 101})
+	if r := expect(t, ws, ReportRejected, 1); !strings.Contains(r.Reason, "quantity") {
+		t.Errorf("Expected quantity rejection, got %+v", r)
+	}
+	send(t, ws, Request{Type: RequestSubmit, ClientOrderID: "o2", Symbol: "AAPL", Side: "buy", Price: 10000, Quantity: 100})
+	if r := expect(t, ws, ReportRejected, 2); !strings.Contains(r.Reason, "notional") {
+		t.Errorf("Expected notional rejection, got %+v", r)
+	}
+	send(t, ws, Request{Type: RequestSubmit, ClientOrderID: "o3", Symbol: "AAPL", Side: "buy", Price: 5000, Quantity: 100})
+	expect(t, ws, ReportAccepted, 3)
+	send(t, ws, Request{Type: RequestSubmit, ClientOrderID: "o4", Symbol: "AAPL", Side: "buy", Price: 5000, Quantity: 10})
+	if r := expect(t, ws, ReportRejected, 4); !strings.Contains(r.Reason, "open orders") {
+		t.Errorf("Expected open orders rejection, got %+v", r)
+	}
+	// A replacement does not count as a new order
+	send(t, ws, Request{Type: RequestModify, ClientOrderID: "o5", OrigClientOrderID: "o3", Price: 4000, Quantity: 50})
+	expect(t, ws, ReportReplaced, 5)
+}
+
+func TestServer_Resync(t *testing.T) {
+	dir := t.TempDir()
+	ts := startServer(t, dir)
+
+	ws := ts.dial(t, "participant=7")
+	expect(t, ws, ReportResynced, 0)
+	send(t, ws, Request{Type: RequestSubmit, ClientOrderID: "a", Symbol: "AAPL", Side: "buy", Price: 10000, Quantity: 10})
+	expect(t, ws, ReportAccepted, 1)
+	ws.Close()
+
+	// Reports produced while the client is away are kept
+	other := ts.dial(t, "participant=8")
+	expect(t, other, ReportResynced, 0)
+	send(t, other, Request{Type: RequestSubmit, ClientOrderID: "x", Symbol: "AAPL", Side: "sell", Price: 10000, Quantity: 4})
+	expect(t, other, ReportAccepted, 1)
+	expect(t, other, ReportFill, 2)
+	ts.stop(t)
+
+	// After a restart, the client resyncs and still owns its open order
+	ts = startServer(t, dir)
+	defer ts.stop(t)
+	ws = ts.dial(t, "participant=7&last_sequence=1")
+	r := expect(t, ws, ReportFill, 2)
+	if r.ClientOrderID != "a" || r.LeavesQuantity != 6 {
+		t.Errorf("Expected missed fill of a, got %+v", r)
+	}
+	expect(t, ws, ReportResynced, 2)
+
+	// A retry of the order is answered with its acceptance
+	send(t, ws, Request{Type: RequestSubmit, ClientOrderID: "a", Symbol: "AAPL", Side: "buy", Price: 10000, Quantity: 10})
+	if r := expect(t, ws, ReportAccepted, 1); r.OrderID != 1 {
+		t.Errorf("Expected the acceptance of order 1 again, got %+v", r)
+	}
+	send(t, ws, Request{Type: RequestCancel, OrigClientOrderID: "a"})
+	if r := expect(t, ws, ReportCanceled, 3); r.OrderID != 1 || r.LeavesQuantity != 6 {
+		t.Errorf("Expected recovered order 1 canceled, got %+v", r)
+	}
+	send(t, ws, Request{Type: RequestSubmit, ClientOrderID: "b", Symbol: "AAPL", Side: "buy", Price: 9000, Quantity: 10})
+	if r := expect(t, ws, ReportAccepted, 4); r.OrderID != 3 {
+		t.Errorf("Expected engine order ID 3 after restart, got %+v", r)
+	}
+}
+
+func TestServer_Dedup(t *testing.T) {
+	ts := startServer(t, t.TempDir())
+	defer ts.stop(t)
+
+	ws := ts.dial(t, "participant=7")
+	expect(t, ws, ReportResynced, 0)
+	submit := Request{Type: RequestSubmit, ClientOrderID: "a", Symbol: "AAPL", Side: "buy", Price: 10000, Quantity: 10}
+	send(t, ws, submit)
+	expect(t, ws, ReportAccepted, 1)
+
+	// Retries enter no order and are not logged
+	send(t, ws, submit)
+	if r := expect(t, ws, ReportAccepted, 1); r.OrderID != 1 {
+		t.Errorf("Expected the acceptance of order 1 again, got %+v", r)
+	}
+	modify := Request{Type: RequestModify, ClientOrderID: "b", OrigClientOrderID: "a", Price: 9900, Quantity: 5}
+	send(t, ws, modify)
+	expect(t, ws, ReportReplaced, 2)
+	send(t, ws, modify)
+	if r := expect(t, ws, ReportReplaced, 2); r.OrigClientOrderID != "a" {
+		t.Errorf("Expected the replacement of a again, got %+v", r)
+	}
+	var orders int
+	ts.manager.View(func(mm *matching.MarketManager) { orders = len(mm.Orders()) })
+	if orders != 1 {
+		t.Errorf("Expected 1 open order, got %d", orders)
+	}
+
+	// IDs of ended orders are forgotten after the window
+	ts.SetDedupConfig(DedupConfig{Window: time.Millisecond})
+	send(t, ws, Request{Type: RequestCancel, OrigClientOrderID: "b"})
+	expect(t, ws, ReportCanceled, 3)
+	time.Sleep(5 * time.Millisecond)
+	send(t, ws, Request{Type: RequestSubmit, ClientOrderID: "b", Symbol: "AAPL", Side: "sell", Price: 11000, Quantity: 1})
+	if r := expect(t, ws, ReportAccepted, 4); r.OrderID == 2 {
+		t.Errorf("Expected a new order for the reused ID, got %+v", r)
+	}
+}
+
+func TestConflater(t *testing.T) {
+	c := newConflater()
+	c.add(MarketData{Sequence: 1, Type: MarketDataLevelAdd, Side: "bid", Price: 100, Volume: 10})
+	c.add(MarketData{Sequence: 2, Type: MarketDataLevelDelete, Side: "ask", Price: 101})
+	c.add(MarketData{Sequence: 3, Type: MarketDataTrade, Price: 100, Quantity: 5})
+	c.add(MarketData{Sequence: 4, Type: MarketDataLevelUpdate, Side: "bid", Price: 100, Volume: 5})
+	c.add(MarketData{Sequence: 5, Type: MarketDataLevelAdd, Side: "ask", Price: 101, Volume: 7})
+	c.add(MarketData{Sequence: 6, Type: MarketDataLevelAdd, Side: "ask", Price: 102, Volume: 1})
+	c.add(MarketData{Sequence: 7, Type: MarketDataLevelDelete, Side: "ask", Price: 102})
+
+	events := c.flush()
+	want := []MarketData{
+		{Sequence: 3, Type: MarketDataTrade, Price: 100, Quantity: 5},
+		{Sequence: 4, Type: MarketDataLevelAdd, Side: "bid", Price: 100, Volume: 5},
+		{Sequence: 5, Type: MarketDataLevelUpdate, Side: "ask", Price: 101, Volume: 7},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Expected %+v, got %+v", want, events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("Event %d: expected %+v, got %+v", i, want[i], events[i])
+		}
+	}
+	if c.len() != 0 {
+		t.Errorf("Expected no pending events after flush, got %d", c.len())
+	}
+}
+
+func TestServer_CancelOnDisconnect(t *testing.T) {
+	ts := startServer(t, t.TempDir())
+	defer ts.stop(t)
+	ts.SetSessionConfig(SessionConfig{CancelOnDisconnect: true, Timeout: 200 * time.Millisecond})
+
+	open := func(participant uint32) int {
+		var n int
+		ts.manager.View(func(mm *matching.MarketManager) {
+			for _, order := range mm.Orders() {
+				if order.ParticipantID == participant {
+					n++
+				}
+			}
+		})
+		return n
+	}
+	waitClosed := func(participant uint32) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for open(participant) != 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected orders of participant %d canceled", participant)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	ws := ts.dial(t, "participant=7")
+	expect(t, ws, ReportResynced, 0)
+	send(t, ws, Request{Type: RequestSubmit, ClientOrderID: "a", Symbol: "AAPL", Side: "buy", Price: 9000, Quantity: 10})
+	expect(t, ws, ReportAccepted, 1)
+	send(t, ws, Request{Type: RequestSubmit, ClientOrderID: "b", Symbol: "AAPL", Side: "buy", Price: 9100, Quantity: 10})
+	expect(t, ws, ReportAccepted, 2)
+
+	// Replacing a connection keeps the orders
+	again := ts.dial(t, "participant=7&last_sequence=2")
+	expect(t, again, ReportResynced, 2)
+	if n := open(7); n != 2 {
+		t.Fatalf("Expected 2 open orders after reconnecting, got %d", n)
+	}
+
+	// Heartbeats keep an idle connection past the timeout
+	for range 4 {
+		time.Sleep(100 * time.Millisecond)
+		send(t, again, Request{Type: RequestHeartbeat})
+	}
+	if n := open(7); n != 2 {
+		t.Fatalf("Expected 2 open orders while heartbeating, got %d", n)
+	}
+
+	// A dropped connection cancels them, and the client sees it on resync
+	again.Close()
+	waitClosed(7)
+	ws = ts.dial(t, "participant=7&last_sequence=2")
+	expect(t, ws, ReportCanceled, 3)
+	expect(t, ws, ReportCanceled, 4)
+	expect(t, ws, ReportResynced, 4)
+
+	// So does an idle one
+	send(t, ws, Request{Type: RequestSubmit, ClientOrderID: "c", Symbol: "AAPL", Side: "buy", Price: 9000, Quantity: 10})
+	expect(t, ws, ReportAccepted, 5)
+	waitClosed(7)
+}
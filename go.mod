@@ -2,4 +2,15 @@ module github.com/tienpsm/go-trader
 
 go 1.24.11
 
-require github.com/klauspost/compress v1.18.4
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/klauspost/compress v1.18.4
+	github.com/parquet-go/parquet-go v0.25.1
+)
+
+require (
+	github.com/andybalholm/brotli v1.1.0 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/pierrec/lz4/v4 v4.1.21 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+)
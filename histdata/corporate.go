@@ -0,0 +1,179 @@
+package histdata
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// ErrInvalidSplit is returned for a split with a zero ratio term
+var ErrInvalidSplit = errors.New("histdata: invalid split")
+
+// ApplySplit adjusts the history of a symbol before a stock split, so that
+// prices and volumes are comparable across it: every from shares become to
+// shares. Prices of ticks and bars before the split time are multiplied by
+// from/to and share counts by to/from, rounded to the nearest unit. Bar
+// notionals are unchanged. Adjustments compound, so each split must be
+// applied once.
+func (s *Store) ApplySplit(symbol string, to, from uint64, before time.Time) error {
+	if err := checkSymbol(symbol); err != nil {
+		return err
+	}
+	if to == 0 || from == 0 {
+		return ErrInvalidSplit
+	}
+	price := func(p uint32) uint32 { return uint32(min(ratio(uint64(p), from, to), math.MaxUint32)) }
+	shares := func(n uint64) uint64 { return ratio(n, to, from) }
+
+	err := adjustSeries(filepath.Join(s.dir, symbol, "ticks"), before, func(t Tick) int64 { return t.Time }, func(t Tick) Tick {
+		t.Price = price(t.Price)
+		t.Shares = shares(t.Shares)
+		return t
+	})
+	if err != nil {
+		return err
+	}
+	intervals, err := s.Intervals(symbol)
+	if err != nil {
+		return err
+	}
+	for _, interval := range intervals {
+		err := adjustSeries(s.barsDir(symbol, interval), before, func(b Bar) int64 { return b.Start }, func(b Bar) Bar {
+			b.Open = price(b.Open)
+			b.High = price(b.High)
+			b.Low = price(b.Low)
+			b.Close = price(b.Close)
+			b.Volume = shares(b.Volume)
+			return b
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenameSymbol moves the history of a symbol to a new name after a ticker
+// change, so that queries of the new name cover both. Days stored under both
+// names are merged.
+func (s *Store) RenameSymbol(old, name string) error {
+	if err := checkSymbol(old); err != nil {
+		return err
+	}
+	if err := checkSymbol(name); err != nil {
+		return err
+	}
+	if old == name {
+		return nil
+	}
+	src, dst := filepath.Join(s.dir, old), filepath.Join(s.dir, name)
+	if _, err := os.Stat(src); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if _, err := os.Stat(dst); os.IsNotExist(err) {
+		return os.Rename(src, dst)
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		var err error
+		if e.Name() == "ticks" {
+			err = mergeSeries(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name()), func(t Tick) int64 { return t.Time })
+		} else if strings.HasPrefix(e.Name(), "bars-") {
+			err = mergeSeries(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name()), func(b Bar) int64 { return b.Start })
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(src)
+}
+
+// adjustSeries rewrites the rows of a series before a time with adjust
+func adjustSeries[T any](dir string, before time.Time, at func(T) int64, adjust func(T) T) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	limit := before.UnixNano()
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".parquet")
+		if !ok {
+			continue
+		}
+		if d, err := time.Parse(dayFormat, name); err != nil || !d.Before(before) {
+			continue
+		}
+		rows, err := parquet.ReadFile[T](filepath.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		for i, row := range rows {
+			if at(row) < limit {
+				rows[i] = adjust(row)
+			}
+		}
+		if err := writeSeries(dir, rows, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeSeries moves the partitions of a series to another directory, merging
+// the rows of days present in both
+func mergeSeries[T any](src, dst string, at func(T) int64) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".parquet") {
+			continue
+		}
+		from, to := filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())
+		if _, err := os.Stat(to); os.IsNotExist(err) {
+			if err := os.Rename(from, to); err != nil {
+				return err
+			}
+			continue
+		}
+		rows, err := parquet.ReadFile[T](from)
+		if err != nil {
+			return err
+		}
+		existing, err := parquet.ReadFile[T](to)
+		if err != nil {
+			return err
+		}
+		if err := writeSeries(dst, append(existing, rows...), at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ratio returns v*mul/div rounded to the nearest integer
+func ratio(v, mul, div uint64) uint64 {
+	return (v*mul + div/2) / div
+}
@@ -0,0 +1,109 @@
+// Package histdata stores the trades of ITCH sessions as ticks and OHLCV bars
+// in a columnar on-disk layout, so backtests can query them by symbol,
+// interval and time range without re-parsing the raw feed.
+//
+// A Store keeps one Parquet file per symbol, series and UTC day:
+//
+//	<dir>/<symbol>/ticks/20240301.parquet
+//	<dir>/<symbol>/bars-1m0s/20240301.parquet
+//
+// A Recorder installed as the ITCH handler of a session saves its ticks and
+// bars when the session ends.
+package histdata
+
+import "time"
+
+// Tick is a printed trade
+type Tick struct {
+	// Time is the trade time in Unix nanoseconds
+	Time int64 `parquet:"time,timestamp(nanosecond)"`
+	// Price is the trade price (4 implied decimals)
+	Price  uint32 `parquet:"price"`
+	Shares uint64 `parquet:"shares"`
+	// Side is the side of the resting order ("B" or "S"), empty for crosses
+	Side        string `parquet:"side,dict"`
+	MatchNumber uint64 `parquet:"match_number"`
+	Cross       bool   `parquet:"cross"`
+}
+
+// Bar summarizes the ticks of an interval
+type Bar struct {
+	// Start is the start of the interval in Unix nanoseconds
+	Start int64  `parquet:"start,timestamp(nanosecond)"`
+	Open  uint32 `parquet:"open"`
+	High  uint32 `parquet:"high"`
+	Low   uint32 `parquet:"low"`
+	Close uint32 `parquet:"close"`
+	// Volume is the number of shares traded
+	Volume uint64 `parquet:"volume"`
+	// Notional is the sum of price times shares (4 implied decimals)
+	Notional uint64 `parquet:"notional"`
+	// Trades is the number of ticks
+	Trades uint64 `parquet:"trades"`
+}
+
+// VWAP returns the volume weighted average price of the bar (4 implied
+// decimals), or 0 for a bar without volume
+func (b Bar) VWAP() float64 {
+	if b.Volume == 0 {
+		return 0
+	}
+	return float64(b.Notional) / float64(b.Volume)
+}
+
+// Aggregator builds bars of a fixed interval from the ticks of one symbol.
+// Bars start at multiples of the interval since the Unix epoch, and
+// intervals without ticks have no bar. Ticks must be added in time order.
+type Aggregator struct {
+	interval int64
+	bar      Bar
+	open     bool
+
+	// OnBar is called with every completed bar
+	OnBar func(b Bar)
+}
+
+// NewAggregator creates an aggregator of bars of interval calling onBar
+func NewAggregator(interval time.Duration, onBar func(b Bar)) *Aggregator {
+	return &Aggregator{interval: int64(interval), OnBar: onBar}
+}
+
+// Add adds a tick, completing the current bar if the tick is past it
+func (a *Aggregator) Add(t Tick) {
+	start := t.Time - t.Time%a.interval
+	if a.open && start != a.bar.Start {
+		a.Flush()
+	}
+	if !a.open {
+		a.bar = Bar{Start: start, Open: t.Price, High: t.Price, Low: t.Price}
+		a.open = true
+	}
+	a.bar.High = max(a.bar.High, t.Price)
+	a.bar.Low = min(a.bar.Low, t.Price)
+	a.bar.Close = t.Price
+	a.bar.Volume += t.Shares
+	a.bar.Notional += uint64(t.Price) * t.Shares
+	a.bar.Trades++
+}
+
+// Flush completes the current bar, if any
+func (a *Aggregator) Flush() {
+	if !a.open {
+		return
+	}
+	a.open = false
+	if a.OnBar != nil {
+		a.OnBar(a.bar)
+	}
+}
+
+// Bars aggregates ticks in time order into bars of interval
+func Bars(ticks []Tick, interval time.Duration) []Bar {
+	var bars []Bar
+	a := NewAggregator(interval, func(b Bar) { bars = append(bars, b) })
+	for _, t := range ticks {
+		a.Add(t)
+	}
+	a.Flush()
+	return bars
+}
@@ -0,0 +1,204 @@
+package histdata
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/tienpsm/go-trader/itch"
+)
+
+var session = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func TestBars(t *testing.T) {
+	at := func(d time.Duration) int64 { return session.Add(d).UnixNano() }
+	ticks := []Tick{
+		{Time: at(10 * time.Second), Price: 100, Shares: 10},
+		{Time: at(20 * time.Second), Price: 120, Shares: 10},
+		{Time: at(50 * time.Second), Price: 90, Shares: 20},
+		// No tick in the second minute
+		{Time: at(2*time.Minute + time.Second), Price: 110, Shares: 5},
+	}
+	bars := Bars(ticks, time.Minute)
+	want := []Bar{
+		{Start: at(0), Open: 100, High: 120, Low: 90, Close: 90, Volume: 40, Notional: 100*10 + 120*10 + 90*20, Trades: 3},
+		{Start: at(2 * time.Minute), Open: 110, High: 110, Low: 110, Close: 110, Volume: 5, Notional: 550, Trades: 1},
+	}
+	if len(bars) != len(want) {
+		t.Fatalf("Expected %+v, got %+v", want, bars)
+	}
+	for i := range want {
+		if bars[i] != want[i] {
+			t.Errorf("Bar %d: expected %+v, got %+v", i, want[i], bars[i])
+		}
+	}
+	if vwap := bars[0].VWAP(); vwap != 100 {
+		t.Errorf("Expected VWAP 100, got %f", vwap)
+	}
+}
+
+func TestStore(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	day1 := session.Add(23 * time.Hour).UnixNano()
+	day2 := session.Add(25 * time.Hour).UnixNano()
+	ticks := []Tick{
+		{Time: day2, Price: 102, Shares: 1, Side: "S", MatchNumber: 3},
+		{Time: day1, Price: 101, Shares: 1, Side: "B", MatchNumber: 1},
+		{Time: day1 + 1, Price: 100, Shares: 2, MatchNumber: 2, Cross: true},
+	}
+	if err := store.WriteTicks("AAPL", ticks); err != nil {
+		t.Fatalf("WriteTicks: %v", err)
+	}
+
+	got, err := store.Ticks("AAPL", session, session.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("Ticks: %v", err)
+	}
+	if len(got) != 3 || got[0] != ticks[1] || got[1] != ticks[2] || got[2] != ticks[0] {
+		t.Errorf("Expected the ticks in time order, got %+v", got)
+	}
+	// The range is half-open and spans both partitions
+	got, err = store.Ticks("AAPL", time.Unix(0, day1+1), time.Unix(0, day2))
+	if err != nil {
+		t.Fatalf("Ticks: %v", err)
+	}
+	if len(got) != 1 || got[0] != ticks[2] {
+		t.Errorf("Expected the cross only, got %+v", got)
+	}
+
+	// Writing a day again replaces it and leaves the other day alone
+	if err := store.WriteTicks("AAPL", []Tick{{Time: day1, Price: 99, Shares: 7}}); err != nil {
+		t.Fatalf("WriteTicks: %v", err)
+	}
+	got, err = store.Ticks("AAPL", session, session.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("Ticks: %v", err)
+	}
+	if len(got) != 2 || got[0].Price != 99 || got[1] != ticks[0] {
+		t.Errorf("Expected the new first day and the second day, got %+v", got)
+	}
+
+	if _, err := store.Ticks("../etc", session, session); err != ErrInvalidSymbol {
+		t.Errorf("Expected ErrInvalidSymbol, got %v", err)
+	}
+	if got, err := store.Ticks("MSFT", session, session.AddDate(0, 0, 2)); err != nil || len(got) != 0 {
+		t.Errorf("Expected no ticks for an unknown symbol, got %+v, %v", got, err)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	aapl := itch.StockField("AAPL")
+	second := uint64(time.Second)
+	var feed []byte
+	feed = itch.AppendFrame(feed, itch.AppendStockDirectory(nil, itch.StockDirectoryMessage{StockLocate: 1, Stock: aapl}))
+	feed = itch.AppendFrame(feed, itch.AppendAddOrder(nil, itch.AddOrderMessage{StockLocate: 1, Timestamp: 1 * second, OrderReferenceNumber: 1, BuySellIndicator: 'B', Shares: 100, Stock: aapl, Price: 9900}))
+	feed = itch.AppendFrame(feed, itch.AppendOrderExecuted(nil, itch.OrderExecutedMessage{StockLocate: 1, Timestamp: 2 * second, OrderReferenceNumber: 1, ExecutedShares: 40, MatchNumber: 1}))
+	feed = itch.AppendFrame(feed, itch.AppendTrade(nil, itch.TradeMessage{StockLocate: 1, Timestamp: 3 * second, BuySellIndicator: 'S', Shares: 10, Stock: aapl, Price: 9950, MatchNumber: 2}))
+	feed = itch.AppendFrame(feed, itch.AppendOrderExecuted(nil, itch.OrderExecutedMessage{StockLocate: 1, Timestamp: 61 * second, OrderReferenceNumber: 1, ExecutedShares: 60, MatchNumber: 3}))
+	feed = itch.AppendFrame(feed, itch.AppendBrokenTrade(nil, itch.BrokenTradeMessage{StockLocate: 1, Timestamp: 62 * second, MatchNumber: 2}))
+
+	r := NewRecorder(store, session, time.Minute)
+	if _, err := itch.NewParser(r).ParseStream(bytes.NewReader(feed)); err != nil {
+		t.Fatalf("ParseStream: %v", err)
+	}
+	if err := r.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	ticks, err := store.Ticks("AAPL", session, session.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("Ticks: %v", err)
+	}
+	if len(ticks) != 2 || ticks[0].Time != session.Add(2*time.Second).UnixNano() || ticks[0].Shares != 40 || ticks[1].Shares != 60 {
+		t.Errorf("Expected the two unbroken executions, got %+v", ticks)
+	}
+	bars, err := store.Bars("AAPL", time.Minute, session, session.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("Bars: %v", err)
+	}
+	if len(bars) != 2 || bars[0].Volume != 40 || bars[1].Start != session.Add(time.Minute).UnixNano() || bars[1].Volume != 60 {
+		t.Errorf("Expected a bar per minute, got %+v", bars)
+	}
+	intervals, err := store.Intervals("AAPL")
+	if err != nil || len(intervals) != 1 || intervals[0] != time.Minute {
+		t.Errorf("Expected a one minute interval, got %v, %v", intervals, err)
+	}
+	symbols, err := store.Symbols()
+	if err != nil || len(symbols) != 1 || symbols[0] != "AAPL" {
+		t.Errorf("Expected AAPL, got %v, %v", symbols, err)
+	}
+}
+
+func TestStore_ApplySplit(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	exDate := session.AddDate(0, 0, 1)
+	before := exDate.Add(-time.Hour).UnixNano()
+	after := exDate.Add(time.Hour).UnixNano()
+	ticks := []Tick{
+		{Time: before, Price: 10001, Shares: 3},
+		{Time: after, Price: 5000, Shares: 6},
+	}
+	if err := store.WriteTicks("AAPL", ticks); err != nil {
+		t.Fatalf("WriteTicks: %v", err)
+	}
+	if err := store.WriteBars("AAPL", time.Hour, Bars(ticks, time.Hour)); err != nil {
+		t.Fatalf("WriteBars: %v", err)
+	}
+
+	if err := store.ApplySplit("AAPL", 2, 1, exDate); err != nil {
+		t.Fatalf("ApplySplit: %v", err)
+	}
+	got, err := store.Ticks("AAPL", session, session.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("Ticks: %v", err)
+	}
+	if len(got) != 2 || got[0].Price != 5001 || got[0].Shares != 6 || got[1] != ticks[1] {
+		t.Errorf("Expected the tick before the split to be adjusted, got %+v", got)
+	}
+	bars, err := store.Bars("AAPL", time.Hour, session, session.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("Bars: %v", err)
+	}
+	if len(bars) != 2 || bars[0].Close != 5001 || bars[0].Volume != 6 || bars[0].Notional != 30003 || bars[1].Close != 5000 {
+		t.Errorf("Expected the bar before the split to be adjusted, got %+v", bars)
+	}
+	if err := store.ApplySplit("AAPL", 0, 1, exDate); err != ErrInvalidSplit {
+		t.Errorf("Expected ErrInvalidSplit, got %v", err)
+	}
+}
+
+func TestStore_RenameSymbol(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	day1 := session.Add(time.Hour).UnixNano()
+	day2 := session.Add(25 * time.Hour).UnixNano()
+	store.WriteTicks("FB", []Tick{{Time: day1, Price: 100, Shares: 1}, {Time: day2, Price: 101, Shares: 1}})
+	store.WriteTicks("META", []Tick{{Time: day2 + 1, Price: 102, Shares: 1}})
+
+	if err := store.RenameSymbol("FB", "META"); err != nil {
+		t.Fatalf("RenameSymbol: %v", err)
+	}
+	got, err := store.Ticks("META", session, session.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("Ticks: %v", err)
+	}
+	if len(got) != 3 || got[0].Price != 100 || got[1].Price != 101 || got[2].Price != 102 {
+		t.Errorf("Expected the merged history, got %+v", got)
+	}
+	symbols, err := store.Symbols()
+	if err != nil || len(symbols) != 1 || symbols[0] != "META" {
+		t.Errorf("Expected META only, got %v, %v", symbols, err)
+	}
+}
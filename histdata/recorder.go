@@ -0,0 +1,70 @@
+package histdata
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tienpsm/go-trader/itch"
+)
+
+// Recorder is an ITCH handler recording the prints of a session. Parse the
+// session with the Recorder as handler, then call Save to store the ticks
+// and bars of every traded symbol. Broken trades are not stored.
+type Recorder struct {
+	*itch.TapeHandler
+
+	store     *Store
+	date      time.Time
+	intervals []time.Duration
+}
+
+// NewRecorder creates a recorder of a session of date saving to store, with
+// bars of each interval. Message timestamps are converted to times of date,
+// taken at midnight in its location, so date should be in the exchange's
+// time zone.
+func NewRecorder(store *Store, date time.Time, intervals ...time.Duration) *Recorder {
+	return &Recorder{
+		TapeHandler: itch.NewTapeHandler(),
+		store:       store,
+		date:        time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location()),
+		intervals:   intervals,
+	}
+}
+
+// Ticks returns the ticks of the session by symbol, in time order
+func (r *Recorder) Ticks() map[string][]Tick {
+	ticks := make(map[string][]Tick)
+	for _, p := range r.ActivePrints() {
+		tick := Tick{
+			Time:        r.date.Add(time.Duration(p.Timestamp)).UnixNano(),
+			Price:       p.Price,
+			Shares:      p.Shares,
+			MatchNumber: p.MatchNumber,
+			Cross:       p.Cross,
+		}
+		if p.Side != 0 {
+			tick.Side = string(p.Side)
+		}
+		ticks[p.Stock] = append(ticks[p.Stock], tick)
+	}
+	for _, t := range ticks {
+		sort.SliceStable(t, func(i, j int) bool { return t[i].Time < t[j].Time })
+	}
+	return ticks
+}
+
+// Save stores the ticks and bars of the session, replacing the stored data
+// of its day
+func (r *Recorder) Save() error {
+	for symbol, ticks := range r.Ticks() {
+		if err := r.store.WriteTicks(symbol, ticks); err != nil {
+			return err
+		}
+		for _, interval := range r.intervals {
+			if err := r.store.WriteBars(symbol, interval, Bars(ticks, interval)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package histdata
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Errors returned by the store
+var (
+	ErrInvalidSymbol   = errors.New("histdata: invalid symbol")
+	ErrInvalidInterval = errors.New("histdata: invalid interval")
+)
+
+// dayFormat is the file name format of a daily partition
+const dayFormat = "20060102"
+
+// Store is a directory of tick and bar series. Each write replaces the days
+// it covers, so recording a session again overwrites it. Writes to the same
+// series must not run concurrently.
+type Store struct {
+	dir string
+}
+
+// Open opens the store in dir, creating the directory if needed
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Symbols returns the symbols with stored data, sorted
+func (s *Store) Symbols() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var symbols []string
+	for _, e := range entries {
+		if e.IsDir() {
+			symbols = append(symbols, e.Name())
+		}
+	}
+	return symbols, nil
+}
+
+// Intervals returns the bar intervals stored for a symbol, shortest first
+func (s *Store) Intervals(symbol string) ([]time.Duration, error) {
+	if err := checkSymbol(symbol); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(s.dir, symbol))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var intervals []time.Duration
+	for _, e := range entries {
+		name, ok := strings.CutPrefix(e.Name(), "bars-")
+		if !ok {
+			continue
+		}
+		if d, err := time.ParseDuration(name); err == nil {
+			intervals = append(intervals, d)
+		}
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+	return intervals, nil
+}
+
+// WriteTicks stores the ticks of a symbol, replacing the stored ticks of
+// every UTC day they cover
+func (s *Store) WriteTicks(symbol string, ticks []Tick) error {
+	if err := checkSymbol(symbol); err != nil {
+		return err
+	}
+	return writeSeries(filepath.Join(s.dir, symbol, "ticks"), ticks, func(t Tick) int64 { return t.Time })
+}
+
+// WriteBars stores the bars of interval of a symbol, replacing the stored
+// bars of every UTC day they cover
+func (s *Store) WriteBars(symbol string, interval time.Duration, bars []Bar) error {
+	if err := checkSymbol(symbol); err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return ErrInvalidInterval
+	}
+	return writeSeries(s.barsDir(symbol, interval), bars, func(b Bar) int64 { return b.Start })
+}
+
+// Ticks returns the ticks of a symbol in [from, to), in time order
+func (s *Store) Ticks(symbol string, from, to time.Time) ([]Tick, error) {
+	if err := checkSymbol(symbol); err != nil {
+		return nil, err
+	}
+	return readSeries(filepath.Join(s.dir, symbol, "ticks"), from, to, func(t Tick) int64 { return t.Time })
+}
+
+// Bars returns the bars of interval of a symbol starting in [from, to), in
+// time order
+func (s *Store) Bars(symbol string, interval time.Duration, from, to time.Time) ([]Bar, error) {
+	if err := checkSymbol(symbol); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+	return readSeries(s.barsDir(symbol, interval), from, to, func(b Bar) int64 { return b.Start })
+}
+
+// barsDir returns the directory of a bar series
+func (s *Store) barsDir(symbol string, interval time.Duration) string {
+	return filepath.Join(s.dir, symbol, "bars-"+interval.String())
+}
+
+// checkSymbol rejects symbols that are not a single path element
+func checkSymbol(symbol string) error {
+	if symbol == "" || symbol == "." || symbol == ".." || strings.ContainsAny(symbol, `/\`) {
+		return ErrInvalidSymbol
+	}
+	return nil
+}
+
+// day returns the UTC day of a Unix nanosecond time
+func day(ns int64) time.Time {
+	t := time.Unix(0, ns).UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// writeSeries groups rows by UTC day and writes one partition per day, rows
+// sorted by time. Partitions are written to a temporary file and renamed,
+// so readers never see a partial file.
+func writeSeries[T any](dir string, rows []T, at func(T) int64) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	sorted := append([]T(nil), rows...)
+	sort.SliceStable(sorted, func(i, j int) bool { return at(sorted[i]) < at(sorted[j]) })
+
+	for start := 0; start < len(sorted); {
+		d := day(at(sorted[start]))
+		end := start + 1
+		for end < len(sorted) && day(at(sorted[end])).Equal(d) {
+			end++
+		}
+		path := filepath.Join(dir, d.Format(dayFormat)+".parquet")
+		tmp := path + ".tmp"
+		if err := parquet.WriteFile(tmp, sorted[start:end], parquet.Compression(&parquet.Zstd)); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// readSeries reads the partitions of the days overlapping [from, to) and
+// returns their rows in the range
+func readSeries[T any](dir string, from, to time.Time, at func(T) int64) ([]T, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	lo, hi := from.UnixNano(), to.UnixNano()
+	var result []T
+	// Entries are sorted by name, which is the date
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".parquet")
+		if !ok {
+			continue
+		}
+		d, err := time.Parse(dayFormat, name)
+		if err != nil || !d.Before(to) || !d.AddDate(0, 0, 1).After(from) {
+			continue
+		}
+		rows, err := parquet.ReadFile[T](filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if ns := at(row); ns >= lo && ns < hi {
+				result = append(result, row)
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,240 @@
+package itch
+
+// Imbalance directions carried by NOIIMessage
+const (
+	// ImbalanceBuy is a buy imbalance
+	ImbalanceBuy = 'B'
+	// ImbalanceSell is a sell imbalance
+	ImbalanceSell = 'S'
+	// ImbalanceNone is no imbalance
+	ImbalanceNone = 'N'
+	// ImbalanceInsufficient is insufficient orders to calculate
+	ImbalanceInsufficient = 'O'
+	// ImbalancePaused is a paused imbalance calculation
+	ImbalancePaused = 'P'
+)
+
+// Retail interest flags carried by RPIIMessage
+const (
+	// RetailInterestBuy is RPI orders on the buy side
+	RetailInterestBuy = 'B'
+	// RetailInterestSell is RPI orders on the sell side
+	RetailInterestSell = 'S'
+	// RetailInterestBoth is RPI orders on both sides
+	RetailInterestBoth = 'A'
+	// RetailInterestNone is no RPI orders
+	RetailInterestNone = 'N'
+)
+
+// ImbalanceThreshold is an imbalance subscription of a stock
+type ImbalanceThreshold struct {
+	// Shares alerts when the imbalance reaches this many shares and when it
+	// falls back below, 0 to not alert on size
+	Shares uint64
+	// Direction alerts when a buy imbalance turns into a sell imbalance or
+	// back
+	Direction bool
+	// CrossType restricts the subscription to the indicators of one cross,
+	// 0 for all
+	CrossType byte
+}
+
+// Reasons of an ImbalanceAlert
+const (
+	// ImbalanceAlertAbove is an imbalance reaching the threshold shares
+	ImbalanceAlertAbove = iota + 1
+	// ImbalanceAlertBelow is an imbalance falling back below the threshold
+	// shares
+	ImbalanceAlertBelow
+	// ImbalanceAlertDirection is a buy imbalance turning into a sell
+	// imbalance or back
+	ImbalanceAlertDirection
+)
+
+// ImbalanceAlert is an indicator crossing an imbalance threshold
+type ImbalanceAlert struct {
+	// Stock is the trimmed stock symbol
+	Stock string
+	// Reason is one of the ImbalanceAlert constants
+	Reason int
+	// Threshold is the subscription crossed
+	Threshold ImbalanceThreshold
+	// Previous is the last indicator of the stock and cross before Msg, zero
+	// for the first one
+	Previous NOIIMessage
+	Msg      NOIIMessage
+}
+
+// RetailInterestAlert is a change of the retail interest flag of a stock
+type RetailInterestAlert struct {
+	// Stock is the trimmed stock symbol
+	Stock string
+	// Previous is the last flag of the stock, 0 for the first message
+	Previous byte
+	Msg      RPIIMessage
+}
+
+// imbalanceKey identifies the indicators of a stock for one cross
+type imbalanceKey struct {
+	stock     StockSymbol
+	crossType byte
+}
+
+// AlertHandler calls back when Net Order Imbalance Indicator and Retail
+// Price Improvement Indicator messages cross the thresholds subscribed per
+// stock, so that users do not process every indicator themselves.
+//
+// Imbalance thresholds alert on the message that crosses them, not on every
+// message beyond them, and each cross of a stock is tracked separately.
+// Subscriptions with an empty stock apply to every stock without one of its
+// own.
+type AlertHandler struct {
+	DefaultHandler
+
+	imbalance map[StockSymbol]ImbalanceThreshold
+	interest  map[StockSymbol]bool
+	// lastImbalance and lastInterest are the last indicators of the
+	// subscribed stocks
+	lastImbalance map[imbalanceKey]NOIIMessage
+	lastInterest  map[StockSymbol]byte
+
+	// OnImbalanceAlert is called when an imbalance crosses its threshold
+	// (optional)
+	OnImbalanceAlert func(a ImbalanceAlert)
+	// OnRetailInterestAlert is called when a retail interest flag changes
+	// (optional)
+	OnRetailInterestAlert func(a RetailInterestAlert)
+}
+
+// NewAlertHandler creates a new alert handler without subscriptions
+func NewAlertHandler() *AlertHandler {
+	return &AlertHandler{
+		imbalance:     make(map[StockSymbol]ImbalanceThreshold),
+		interest:      make(map[StockSymbol]bool),
+		lastImbalance: make(map[imbalanceKey]NOIIMessage),
+		lastInterest:  make(map[StockSymbol]byte),
+	}
+}
+
+// alertKey returns the map key of a stock, empty for every stock
+func alertKey(stock string) StockSymbol {
+	if stock == "" {
+		return StockSymbol{}
+	}
+	return NewStockSymbol(stock)
+}
+
+// messageKey returns the map key of the stock field of a message, padded
+// with spaces like NewStockSymbol
+func messageKey(s StockSymbol) StockSymbol {
+	for i := s.Len(); i < len(s); i++ {
+		s[i] = ' '
+	}
+	return s
+}
+
+// SubscribeImbalance replaces the imbalance threshold of a stock, of every
+// stock if empty
+func (h *AlertHandler) SubscribeImbalance(stock string, threshold ImbalanceThreshold) {
+	h.imbalance[alertKey(stock)] = threshold
+}
+
+// UnsubscribeImbalance removes the imbalance threshold of a stock
+func (h *AlertHandler) UnsubscribeImbalance(stock string) {
+	key := alertKey(stock)
+	delete(h.imbalance, key)
+	for k := range h.lastImbalance {
+		if stock == "" || k.stock == key {
+			delete(h.lastImbalance, k)
+		}
+	}
+}
+
+// SubscribeRetailInterest alerts on the retail interest flag changes of a
+// stock, of every stock if empty
+func (h *AlertHandler) SubscribeRetailInterest(stock string) {
+	h.interest[alertKey(stock)] = true
+}
+
+// UnsubscribeRetailInterest stops the retail interest alerts of a stock
+func (h *AlertHandler) UnsubscribeRetailInterest(stock string) {
+	key := alertKey(stock)
+	delete(h.interest, key)
+	for k := range h.lastInterest {
+		if stock == "" || k == key {
+			delete(h.lastInterest, k)
+		}
+	}
+}
+
+// imbalanceThreshold returns the threshold applying to a stock
+func (h *AlertHandler) imbalanceThreshold(stock StockSymbol) (ImbalanceThreshold, bool) {
+	if t, ok := h.imbalance[stock]; ok {
+		return t, true
+	}
+	t, ok := h.imbalance[StockSymbol{}]
+	return t, ok
+}
+
+// OnNOII checks an imbalance indicator against the threshold of its stock
+func (h *AlertHandler) OnNOII(msg NOIIMessage) error {
+	stock := messageKey(msg.Stock)
+	threshold, ok := h.imbalanceThreshold(stock)
+	if !ok || (threshold.CrossType != 0 && threshold.CrossType != msg.CrossType) {
+		return nil
+	}
+	key := imbalanceKey{stock: stock, crossType: msg.CrossType}
+	prev, seen := h.lastImbalance[key]
+	h.lastImbalance[key] = msg
+	if h.OnImbalanceAlert == nil {
+		return nil
+	}
+
+	alert := func(reason int) {
+		h.OnImbalanceAlert(ImbalanceAlert{
+			Stock:     msg.Stock.Trimmed(),
+			Reason:    reason,
+			Threshold: threshold,
+			Previous:  prev,
+			Msg:       msg,
+		})
+	}
+	if threshold.Shares != 0 {
+		above := msg.ImbalanceShares >= threshold.Shares
+		wasAbove := seen && prev.ImbalanceShares >= threshold.Shares
+		switch {
+		case above && !wasAbove:
+			alert(ImbalanceAlertAbove)
+		case !above && wasAbove:
+			alert(ImbalanceAlertBelow)
+		}
+	}
+	if threshold.Direction && seen && isImbalanceSide(prev.ImbalanceDirection) &&
+		isImbalanceSide(msg.ImbalanceDirection) && prev.ImbalanceDirection != msg.ImbalanceDirection {
+		alert(ImbalanceAlertDirection)
+	}
+	return nil
+}
+
+// isImbalanceSide returns true for a buy or sell imbalance
+func isImbalanceSide(direction byte) bool {
+	return direction == ImbalanceBuy || direction == ImbalanceSell
+}
+
+// OnRPII alerts on a change of the retail interest flag of a subscribed
+// stock. The first message of a stock alerts unless it has no interest.
+func (h *AlertHandler) OnRPII(msg RPIIMessage) error {
+	stock := messageKey(msg.Stock)
+	if !h.interest[stock] && !h.interest[StockSymbol{}] {
+		return nil
+	}
+	prev, seen := h.lastInterest[stock]
+	h.lastInterest[stock] = msg.InterestFlag
+	if seen && prev == msg.InterestFlag || !seen && msg.InterestFlag == RetailInterestNone {
+		return nil
+	}
+	if h.OnRetailInterestAlert != nil {
+		h.OnRetailInterestAlert(RetailInterestAlert{Stock: msg.Stock.Trimmed(), Previous: prev, Msg: msg})
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package itch
+
+import "testing"
+
+func noiiMessage(stock string, shares uint64, direction, crossType byte) NOIIMessage {
+	return NOIIMessage{
+		Type:               MessageTypeNOII,
+		Stock:              stockField(stock),
+		ImbalanceShares:    shares,
+		ImbalanceDirection: direction,
+		CrossType:          crossType,
+	}
+}
+
+func rpiiMessage(stock string, flag byte) RPIIMessage {
+	return RPIIMessage{Type: MessageTypeRPII, Stock: stockField(stock), InterestFlag: flag}
+}
+
+func TestAlertHandler_Imbalance(t *testing.T) {
+	h := NewAlertHandler()
+	var alerts []ImbalanceAlert
+	h.OnImbalanceAlert = func(a ImbalanceAlert) { alerts = append(alerts, a) }
+	h.SubscribeImbalance("AAPL", ImbalanceThreshold{Shares: 10000, Direction: true})
+	h.SubscribeImbalance("MSFT", ImbalanceThreshold{Shares: 500, CrossType: CrossTypeClosing})
+
+	h.OnNOII(noiiMessage("AAPL", 5000, ImbalanceBuy, CrossTypeOpening))
+	h.OnNOII(noiiMessage("AAPL", 12000, ImbalanceBuy, CrossTypeOpening))
+	// Beyond the threshold again, no new alert
+	h.OnNOII(noiiMessage("AAPL", 15000, ImbalanceBuy, CrossTypeOpening))
+	h.OnNOII(noiiMessage("AAPL", 15000, ImbalanceSell, CrossTypeOpening))
+	h.OnNOII(noiiMessage("AAPL", 100, ImbalanceSell, CrossTypeOpening))
+	// Not subscribed or another cross
+	h.OnNOII(noiiMessage("IBM", 1000000, ImbalanceBuy, CrossTypeOpening))
+	h.OnNOII(noiiMessage("MSFT", 1000, ImbalanceBuy, CrossTypeOpening))
+	h.OnNOII(noiiMessage("MSFT", 1000, ImbalanceBuy, CrossTypeClosing))
+
+	want := []struct {
+		stock  string
+		reason int
+		shares uint64
+	}{
+		{"AAPL", ImbalanceAlertAbove, 12000},
+		{"AAPL", ImbalanceAlertDirection, 15000},
+		{"AAPL", ImbalanceAlertBelow, 100},
+		{"MSFT", ImbalanceAlertAbove, 1000},
+	}
+	if len(alerts) != len(want) {
+		t.Fatalf("Expected %d alerts, got %+v", len(want), alerts)
+	}
+	for i, w := range want {
+		if a := alerts[i]; a.Stock != w.stock || a.Reason != w.reason || a.Msg.ImbalanceShares != w.shares {
+			t.Errorf("Expected alert %d %+v, got %+v", i, w, a)
+		}
+	}
+	if alerts[1].Previous.ImbalanceDirection != ImbalanceBuy {
+		t.Errorf("Expected the previous buy imbalance, got %+v", alerts[1].Previous)
+	}
+
+	// Every stock, and the crosses of a stock tracked apart
+	alerts = nil
+	h.UnsubscribeImbalance("AAPL")
+	h.SubscribeImbalance("", ImbalanceThreshold{Shares: 100})
+	h.OnNOII(noiiMessage("AAPL", 200, ImbalanceBuy, CrossTypeOpening))
+	h.OnNOII(noiiMessage("AAPL", 200, ImbalanceBuy, CrossTypeClosing))
+	h.OnNOII(noiiMessage("MSFT", 200, ImbalanceBuy, CrossTypeOpening))
+	if len(alerts) != 2 || alerts[0].Msg.CrossType != CrossTypeOpening || alerts[1].Msg.CrossType != CrossTypeClosing {
+		t.Errorf("Expected an alert per AAPL cross, got %+v", alerts)
+	}
+}
+
+func TestAlertHandler_RetailInterest(t *testing.T) {
+	h := NewAlertHandler()
+	var alerts []RetailInterestAlert
+	h.OnRetailInterestAlert = func(a RetailInterestAlert) { alerts = append(alerts, a) }
+	h.SubscribeRetailInterest("AAPL")
+
+	h.OnRPII(rpiiMessage("AAPL", RetailInterestNone))
+	h.OnRPII(rpiiMessage("AAPL", RetailInterestBuy))
+	h.OnRPII(rpiiMessage("AAPL", RetailInterestBuy))
+	h.OnRPII(rpiiMessage("AAPL", RetailInterestBoth))
+	h.OnRPII(rpiiMessage("MSFT", RetailInterestSell))
+
+	if len(alerts) != 2 {
+		t.Fatalf("Expected 2 alerts, got %+v", alerts)
+	}
+	if a := alerts[0]; a.Stock != "AAPL" || a.Previous != RetailInterestNone || a.Msg.InterestFlag != RetailInterestBuy {
+		t.Errorf("Expected AAPL turning to buy interest, got %+v", a)
+	}
+	if a := alerts[1]; a.Previous != RetailInterestBuy || a.Msg.InterestFlag != RetailInterestBoth {
+		t.Errorf("Expected AAPL turning to both sides, got %+v", a)
+	}
+
+	// The first message of a stock alerts unless it has no interest
+	alerts = nil
+	h.SubscribeRetailInterest("")
+	h.OnRPII(rpiiMessage("MSFT", RetailInterestSell))
+	h.OnRPII(rpiiMessage("IBM", RetailInterestNone))
+	if len(alerts) != 1 || alerts[0].Stock != "MSFT" || alerts[0].Previous != 0 {
+		t.Errorf("Expected the MSFT sell interest, got %+v", alerts)
+	}
+}
@@ -0,0 +1,213 @@
+package itch
+
+import "sort"
+
+// Cross types carried by CrossTradeMessage and NOIIMessage
+const (
+	// CrossTypeOpening is the Nasdaq Opening Cross
+	CrossTypeOpening = 'O'
+	// CrossTypeClosing is the Nasdaq Closing Cross
+	CrossTypeClosing = 'C'
+	// CrossTypeHalt is the cross for IPO and halted/paused securities
+	CrossTypeHalt = 'H'
+	// CrossTypeIntraday is the Cross Network intraday and post-close cross
+	CrossTypeIntraday = 'I'
+)
+
+// AuctionVolume is the auction and continuous-session volume for one symbol
+type AuctionVolume struct {
+	// Stock is the trimmed stock symbol
+	Stock string
+	// StockLocate is the locate code the volume was reported under
+	StockLocate uint16
+
+	// OpeningShares is the volume printed by the opening cross
+	OpeningShares uint64
+	// OpeningPrice is the price of the last opening cross
+	OpeningPrice uint32
+	// ClosingShares is the volume printed by the closing cross
+	ClosingShares uint64
+	// ClosingPrice is the price of the last closing cross
+	ClosingPrice uint32
+	// HaltShares is the volume printed by IPO and halt/pause crosses
+	HaltShares uint64
+	// IntradayShares is the volume printed by intraday and post-close crosses
+	IntradayShares uint64
+	// Crosses is the number of cross trade messages seen
+	Crosses uint64
+
+	// ContinuousShares is the printable volume executed outside of crosses
+	ContinuousShares uint64
+}
+
+// AuctionShares returns the total volume printed by all crosses
+func (v AuctionVolume) AuctionShares() uint64 {
+	return v.OpeningShares + v.ClosingShares + v.HaltShares + v.IntradayShares
+}
+
+// TotalShares returns the total printed volume (auction and continuous)
+func (v AuctionVolume) TotalShares() uint64 {
+	return v.AuctionShares() + v.ContinuousShares
+}
+
+// AuctionRatio returns the fraction of total volume printed by crosses,
+// or 0 if no volume was printed
+func (v AuctionVolume) AuctionRatio() float64 {
+	total := v.TotalShares()
+	if total == 0 {
+		return 0
+	}
+	return float64(v.AuctionShares()) / float64(total)
+}
+
+// AuctionHandler aggregates Cross Trade messages into opening, closing and
+// halt auction volume per symbol and compares it against continuous-session
+// volume from printable executions and non-displayable trades.
+//
+// Executions carry only a locate code, so symbols are resolved from Stock
+// Directory, Add Order, Trade and Cross Trade messages seen in the stream.
+type AuctionHandler struct {
+	DefaultHandler
+
+	directory *SymbolDirectory
+	volumes   map[uint16]*AuctionVolume
+}
+
+// NewAuctionHandler creates a new auction volume handler
+func NewAuctionHandler() *AuctionHandler {
+	return &AuctionHandler{
+		directory: NewSymbolDirectory(),
+		volumes:   make(map[uint16]*AuctionVolume),
+	}
+}
+
+// Directory returns the symbol directory of the handler
+func (h *AuctionHandler) Directory() *SymbolDirectory {
+	return h.directory
+}
+
+// SetDirectory shares a symbol directory with other handlers. It must be set
+// before the first message.
+func (h *AuctionHandler) SetDirectory(d *SymbolDirectory) {
+	h.directory = d
+}
+
+// register remembers the stock symbol for a locate code
+func (h *AuctionHandler) register(locate uint16, stock StockSymbol) {
+	h.directory.Register(locate, stock)
+}
+
+// volume returns the aggregate for a locate code, creating it if necessary
+func (h *AuctionHandler) volume(locate uint16) *AuctionVolume {
+	v, ok := h.volumes[locate]
+	if !ok {
+		v = &AuctionVolume{StockLocate: locate}
+		h.volumes[locate] = v
+	}
+	return v
+}
+
+// OnStockDirectory registers the stock in the directory
+func (h *AuctionHandler) OnStockDirectory(msg StockDirectoryMessage) error {
+	h.directory.Add(msg)
+	return nil
+}
+
+// OnAddOrder registers the symbol for the locate code
+func (h *AuctionHandler) OnAddOrder(msg AddOrderMessage) error {
+	h.register(msg.StockLocate, msg.Stock)
+	return nil
+}
+
+// OnAddOrderMPID registers the symbol for the locate code
+func (h *AuctionHandler) OnAddOrderMPID(msg AddOrderMPIDMessage) error {
+	h.register(msg.StockLocate, msg.Stock)
+	return nil
+}
+
+// OnOrderExecuted adds the executed shares to continuous volume
+func (h *AuctionHandler) OnOrderExecuted(msg OrderExecutedMessage) error {
+	h.volume(msg.StockLocate).ContinuousShares += uint64(msg.ExecutedShares)
+	return nil
+}
+
+// OnOrderExecutedWithPrice adds printable executed shares to continuous volume.
+// Non-printable executions are cross participations already reported by the
+// Cross Trade message and are ignored to avoid double counting.
+func (h *AuctionHandler) OnOrderExecutedWithPrice(msg OrderExecutedWithPriceMessage) error {
+	if msg.Printable == 'Y' {
+		h.volume(msg.StockLocate).ContinuousShares += uint64(msg.ExecutedShares)
+	}
+	return nil
+}
+
+// OnTrade adds non-displayable order executions to continuous volume
+func (h *AuctionHandler) OnTrade(msg TradeMessage) error {
+	h.register(msg.StockLocate, msg.Stock)
+	h.volume(msg.StockLocate).ContinuousShares += uint64(msg.Shares)
+	return nil
+}
+
+// OnCrossTrade adds the cross volume to the bucket for its cross type
+func (h *AuctionHandler) OnCrossTrade(msg CrossTradeMessage) error {
+	h.register(msg.StockLocate, msg.Stock)
+	v := h.volume(msg.StockLocate)
+	v.Crosses++
+
+	switch msg.CrossType {
+	case CrossTypeOpening:
+		v.OpeningShares += msg.Shares
+		v.OpeningPrice = msg.CrossPrice
+	case CrossTypeClosing:
+		v.ClosingShares += msg.Shares
+		v.ClosingPrice = msg.CrossPrice
+	case CrossTypeHalt:
+		v.HaltShares += msg.Shares
+	default:
+		v.IntradayShares += msg.Shares
+	}
+	return nil
+}
+
+// Volume returns the aggregated volume for a stock symbol
+func (h *AuctionHandler) Volume(stock string) (AuctionVolume, bool) {
+	for locate, v := range h.volumes {
+		if h.directory.Stock(locate) == stock {
+			result := *v
+			result.Stock = stock
+			return result, true
+		}
+	}
+	return AuctionVolume{}, false
+}
+
+// Report returns the aggregated volume of every symbol, sorted by stock symbol
+func (h *AuctionHandler) Report() []AuctionVolume {
+	report := make([]AuctionVolume, 0, len(h.volumes))
+	for locate, v := range h.volumes {
+		result := *v
+		result.Stock = h.directory.Stock(locate)
+		report = append(report, result)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Stock != report[j].Stock {
+			return report[i].Stock < report[j].Stock
+		}
+		return report[i].StockLocate < report[j].StockLocate
+	})
+	return report
+}
+
+// Total returns the volume summed over all symbols
+func (h *AuctionHandler) Total() AuctionVolume {
+	var total AuctionVolume
+	for _, v := range h.volumes {
+		total.OpeningShares += v.OpeningShares
+		total.ClosingShares += v.ClosingShares
+		total.HaltShares += v.HaltShares
+		total.IntradayShares += v.IntradayShares
+		total.Crosses += v.Crosses
+		total.ContinuousShares += v.ContinuousShares
+	}
+	return total
+}
@@ -0,0 +1,67 @@
+package itch
+
+import "testing"
+
+func stockField(s string) [8]byte {
+	var stock [8]byte
+	copy(stock[:], s+"        ")
+	return stock
+}
+
+func TestAuctionHandler_CrossVolume(t *testing.T) {
+	h := NewAuctionHandler()
+
+	h.OnStockDirectory(StockDirectoryMessage{StockLocate: 1, Stock: stockField("AAPL")})
+	h.OnCrossTrade(CrossTradeMessage{StockLocate: 1, Stock: stockField("AAPL"), Shares: 1000, CrossPrice: 15000, CrossType: CrossTypeOpening})
+	h.OnCrossTrade(CrossTradeMessage{StockLocate: 1, Stock: stockField("AAPL"), Shares: 3000, CrossPrice: 15100, CrossType: CrossTypeClosing})
+	h.OnCrossTrade(CrossTradeMessage{StockLocate: 1, Stock: stockField("AAPL"), Shares: 500, CrossPrice: 15050, CrossType: CrossTypeHalt})
+
+	h.OnOrderExecuted(OrderExecutedMessage{StockLocate: 1, ExecutedShares: 200})
+	h.OnOrderExecutedWithPrice(OrderExecutedWithPriceMessage{StockLocate: 1, ExecutedShares: 100, Printable: 'Y'})
+	h.OnOrderExecutedWithPrice(OrderExecutedWithPriceMessage{StockLocate: 1, ExecutedShares: 1000, Printable: 'N'})
+	h.OnTrade(TradeMessage{StockLocate: 1, Stock: stockField("AAPL"), Shares: 300})
+
+	v, ok := h.Volume("AAPL")
+	if !ok {
+		t.Fatal("Expected AAPL volume")
+	}
+	if v.OpeningShares != 1000 || v.OpeningPrice != 15000 {
+		t.Errorf("Expected opening 1000 @ 15000, got %d @ %d", v.OpeningShares, v.OpeningPrice)
+	}
+	if v.ClosingShares != 3000 || v.ClosingPrice != 15100 {
+		t.Errorf("Expected closing 3000 @ 15100, got %d @ %d", v.ClosingShares, v.ClosingPrice)
+	}
+	if v.HaltShares != 500 {
+		t.Errorf("Expected halt shares 500, got %d", v.HaltShares)
+	}
+	if v.ContinuousShares != 600 {
+		t.Errorf("Expected continuous shares 600, got %d", v.ContinuousShares)
+	}
+	if v.AuctionShares() != 4500 {
+		t.Errorf("Expected auction shares 4500, got %d", v.AuctionShares())
+	}
+	if ratio := v.AuctionRatio(); ratio < 0.88 || ratio > 0.89 {
+		t.Errorf("Expected auction ratio ~0.882, got %f", ratio)
+	}
+}
+
+func TestAuctionHandler_Report(t *testing.T) {
+	h := NewAuctionHandler()
+
+	h.OnAddOrder(AddOrderMessage{StockLocate: 2, Stock: stockField("MSFT")})
+	h.OnCrossTrade(CrossTradeMessage{StockLocate: 1, Stock: stockField("AAPL"), Shares: 100, CrossType: CrossTypeOpening})
+	h.OnOrderExecuted(OrderExecutedMessage{StockLocate: 2, ExecutedShares: 50})
+
+	report := h.Report()
+	if len(report) != 2 {
+		t.Fatalf("Expected 2 symbols, got %d", len(report))
+	}
+	if report[0].Stock != "AAPL" || report[1].Stock != "MSFT" {
+		t.Errorf("Expected report sorted by stock, got %s, %s", report[0].Stock, report[1].Stock)
+	}
+
+	total := h.Total()
+	if total.TotalShares() != 150 {
+		t.Errorf("Expected total shares 150, got %d", total.TotalShares())
+	}
+}
@@ -0,0 +1,204 @@
+package itch
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Framing overhead of ITCH over MoldUDP64 multicast, in bytes
+const (
+	// MoldHeaderSize is the MoldUDP64 packet header: session, sequence
+	// number and message count
+	MoldHeaderSize = 20
+	// MoldLengthSize is the length prefix of each message in a packet
+	MoldLengthSize = 2
+	// PacketOverhead is the wire overhead of a UDP packet: the UDP, IPv4
+	// and Ethernet headers, the frame check sequence, the preamble and the
+	// inter-frame gap
+	PacketOverhead = 8 + 20 + 14 + 4 + 20
+)
+
+// DefaultPeakIntervals are the intervals of the peak rates measured by
+// NewBandwidthStats by default: sustained over a second and bursts over a
+// millisecond
+var DefaultPeakIntervals = []time.Duration{time.Second, time.Millisecond}
+
+// Peak is the busiest interval of a feed in message time
+type Peak struct {
+	// Interval is the length of the measured intervals
+	Interval time.Duration
+	// Start is the timestamp of the busiest interval, in nanoseconds since
+	// midnight
+	Start uint64
+	// Messages and Bytes are the messages and message bytes of the interval
+	Messages uint64
+	Bytes    uint64
+}
+
+// MessagesPerSecond returns the message rate of the peak
+func (p Peak) MessagesPerSecond() float64 {
+	return float64(p.Messages) / p.Interval.Seconds()
+}
+
+// BytesPerSecond returns the message byte rate of the peak
+func (p Peak) BytesPerSecond() float64 {
+	return float64(p.Bytes) / p.Interval.Seconds()
+}
+
+// peakCounter measures the peak of one interval length
+type peakCounter struct {
+	peak Peak
+	// index is the current interval, counting from midnight, and messages
+	// and bytes its totals so far
+	index    uint64
+	messages uint64
+	bytes    uint64
+}
+
+// add counts a message in the interval of timestamp
+func (c *peakCounter) add(timestamp uint64, size int) {
+	index := timestamp / uint64(c.peak.Interval)
+	if index != c.index {
+		c.close()
+		c.index, c.messages, c.bytes = index, 0, 0
+	}
+	c.messages++
+	c.bytes += uint64(size)
+}
+
+// close ends the current interval, keeping it if it is the busiest
+func (c *peakCounter) close() {
+	if c.messages > c.peak.Messages {
+		c.peak.Start = c.index * uint64(c.peak.Interval)
+		c.peak.Messages, c.peak.Bytes = c.messages, c.bytes
+	}
+}
+
+// TypeSize is the volume of one message type of a feed
+type TypeSize struct {
+	Type     byte
+	Messages uint64
+	Bytes    uint64
+}
+
+// AverageSize returns the average message size of the type
+func (t TypeSize) AverageSize() float64 {
+	if t.Messages == 0 {
+		return 0
+	}
+	return float64(t.Bytes) / float64(t.Messages)
+}
+
+// BandwidthStats measures the bytes of a feed by message type and its peak
+// message rates in message time, to plan the network capacity of a live
+// feed from file replays. Messages are counted without their framing.
+// Not thread-safe.
+type BandwidthStats struct {
+	messages [256]uint64
+	bytes    [256]uint64
+	peaks    []peakCounter
+}
+
+// NewBandwidthStats creates bandwidth statistics measuring the peak rates
+// over each interval, DefaultPeakIntervals if none are given
+func NewBandwidthStats(intervals ...time.Duration) *BandwidthStats {
+	if len(intervals) == 0 {
+		intervals = DefaultPeakIntervals
+	}
+	b := &BandwidthStats{peaks: make([]peakCounter, 0, len(intervals))}
+	for _, interval := range intervals {
+		if interval > 0 {
+			b.peaks = append(b.peaks, peakCounter{peak: Peak{Interval: interval}})
+		}
+	}
+	return b
+}
+
+// Add counts a message. Messages too short to hold a timestamp are counted
+// in the totals only.
+func (b *BandwidthStats) Add(msg []byte) {
+	if len(msg) == 0 {
+		return
+	}
+	b.messages[msg[0]]++
+	b.bytes[msg[0]] += uint64(len(msg))
+	if len(msg) < 11 {
+		return
+	}
+	timestamp := readUint48BE(msg[5:11])
+	for i := range b.peaks {
+		b.peaks[i].add(timestamp, len(msg))
+	}
+}
+
+// Types returns the volume of each message type seen, by descending bytes
+func (b *BandwidthStats) Types() []TypeSize {
+	var types []TypeSize
+	for t, n := range b.messages {
+		if n > 0 {
+			types = append(types, TypeSize{Type: byte(t), Messages: n, Bytes: b.bytes[t]})
+		}
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].Bytes != types[j].Bytes {
+			return types[i].Bytes > types[j].Bytes
+		}
+		return types[i].Type < types[j].Type
+	})
+	return types
+}
+
+// Total returns the volume of all message types, with a zero Type
+func (b *BandwidthStats) Total() TypeSize {
+	var total TypeSize
+	for t, n := range b.messages {
+		total.Messages += n
+		total.Bytes += b.bytes[t]
+	}
+	return total
+}
+
+// Peaks returns the busiest interval of each measured interval length, in
+// the order of NewBandwidthStats
+func (b *BandwidthStats) Peaks() []Peak {
+	peaks := make([]Peak, len(b.peaks))
+	for i := range b.peaks {
+		// The current interval is not closed yet
+		c := b.peaks[i]
+		c.close()
+		peaks[i] = c.peak
+	}
+	return peaks
+}
+
+// Merge adds the totals of other, measured on another feed or file, and
+// keeps the busier peak of each interval length both measure
+func (b *BandwidthStats) Merge(other *BandwidthStats) {
+	for t := range b.messages {
+		b.messages[t] += other.messages[t]
+		b.bytes[t] += other.bytes[t]
+	}
+	for _, peak := range other.Peaks() {
+		for i := range b.peaks {
+			c := &b.peaks[i]
+			if c.peak.Interval == peak.Interval && peak.Messages > c.peak.Messages {
+				c.peak = peak
+			}
+		}
+	}
+}
+
+// MulticastBandwidth projects the wire bandwidth in bits per second of a
+// MoldUDP64 multicast feed carrying rate messages per second of size bytes
+// on average, packed into packets of at most packetSize bytes of UDP
+// payload. Messages larger than a packet are sent one per packet.
+func MulticastBandwidth(rate, size float64, packetSize int) float64 {
+	if rate <= 0 {
+		return 0
+	}
+	perPacket := max(math.Floor(float64(packetSize-MoldHeaderSize)/(size+MoldLengthSize)), 1)
+	packets := rate / perPacket
+	bytes := rate*(size+MoldLengthSize) + packets*(MoldHeaderSize+PacketOverhead)
+	return bytes * 8
+}
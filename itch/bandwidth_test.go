@@ -0,0 +1,70 @@
+package itch
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBandwidthStats(t *testing.T) {
+	b := NewBandwidthStats()
+	add := func(ts uint64) { b.Add(AppendAddOrder(nil, AddOrderMessage{Timestamp: ts})) }
+	del := func(ts uint64) { b.Add(AppendOrderDelete(nil, OrderDeleteMessage{Timestamp: ts})) }
+
+	// Second 1 holds 3 messages, second 2 holds 4 with a burst of 3 in one ms
+	add(uint64(time.Second))
+	add(uint64(time.Second) + 500*uint64(time.Millisecond))
+	del(uint64(time.Second) + 900*uint64(time.Millisecond))
+	add(2 * uint64(time.Second))
+	del(2*uint64(time.Second) + 100)
+	del(2*uint64(time.Second) + 200)
+	add(2*uint64(time.Second) + 2*uint64(time.Millisecond))
+
+	types := b.Types()
+	if len(types) != 2 || types[0].Type != MessageTypeAddOrder || types[0].Messages != 4 || types[0].Bytes != 4*36 {
+		t.Fatalf("Expected 4 add orders of 36 bytes first, got %+v", types)
+	}
+	if types[1].AverageSize() != 19 {
+		t.Errorf("Expected deletes of 19 bytes, got %f", types[1].AverageSize())
+	}
+	if total := b.Total(); total.Messages != 7 || total.Bytes != 4*36+3*19 {
+		t.Errorf("Expected 7 messages, got %+v", total)
+	}
+
+	peaks := b.Peaks()
+	if len(peaks) != 2 {
+		t.Fatalf("Expected 2 peaks, got %d", len(peaks))
+	}
+	if p := peaks[0]; p.Messages != 4 || p.Start != 2*uint64(time.Second) || p.MessagesPerSecond() != 4 {
+		t.Errorf("Expected 4 messages in second 2, got %+v", p)
+	}
+	if p := peaks[1]; p.Messages != 3 || p.Bytes != 36+2*19 || p.MessagesPerSecond() != 3000 {
+		t.Errorf("Expected a burst of 3 messages in a ms, got %+v", p)
+	}
+
+	// Merging keeps the busier peak
+	other := NewBandwidthStats()
+	for i := uint64(0); i < 5; i++ {
+		other.Add(AppendOrderDelete(nil, OrderDeleteMessage{Timestamp: 10 * uint64(time.Second)}))
+	}
+	b.Merge(other)
+	if b.Total().Messages != 12 || b.Peaks()[0].Messages != 5 || b.Peaks()[0].Start != 10*uint64(time.Second) {
+		t.Errorf("Expected the merged peak of 5, got %+v", b.Peaks())
+	}
+}
+
+func TestMulticastBandwidth(t *testing.T) {
+	// 1000 msg/s of 30 bytes: 43 messages per 1400 byte packet
+	packets := 1000.0 / 43
+	want := 8 * (1000*32 + packets*(MoldHeaderSize+PacketOverhead))
+	if got := MulticastBandwidth(1000, 30, 1400); math.Abs(got-want) > 1e-6 {
+		t.Errorf("Expected %f bit/s, got %f", want, got)
+	}
+	// Messages larger than a packet are sent alone
+	if got, want := MulticastBandwidth(10, 2000, 1400), 8*10*(2002.0+MoldHeaderSize+PacketOverhead); got != want {
+		t.Errorf("Expected %f bit/s, got %f", want, got)
+	}
+	if got := MulticastBandwidth(0, 30, 1400); got != 0 {
+		t.Errorf("Expected 0 without messages, got %f", got)
+	}
+}
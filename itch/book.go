@@ -0,0 +1,330 @@
+package itch
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"sort"
+)
+
+// DepthAction identifies the kind of price level change
+type DepthAction byte
+
+const (
+	// DepthAdd is a new price level
+	DepthAdd DepthAction = 'A'
+	// DepthUpdate is a change of the aggregate size of a price level
+	DepthUpdate DepthAction = 'U'
+	// DepthDelete is a price level that no longer has any orders
+	DepthDelete DepthAction = 'D'
+)
+
+// String returns the string representation of a DepthAction
+func (a DepthAction) String() string {
+	switch a {
+	case DepthAdd:
+		return "ADD"
+	case DepthUpdate:
+		return "UPDATE"
+	case DepthDelete:
+		return "DELETE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// BookLevel is an aggregated price level of a book
+type BookLevel struct {
+	// Price is the level price (4 implied decimals)
+	Price uint32
+	// Shares is the total displayed shares at the price
+	Shares uint64
+	// Orders is the number of orders at the price
+	Orders int
+}
+
+// DepthChange is an incremental price level change emitted by BookBuilder
+type DepthChange struct {
+	// Timestamp is nanoseconds since midnight of the message causing the change
+	Timestamp uint64
+	// StockLocate is the locate code of the stock
+	StockLocate uint16
+	// Stock is the trimmed stock symbol
+	Stock string
+	// Action is the kind of change
+	Action DepthAction
+	// Side is 'B' for bids or 'S' for asks
+	Side byte
+	// Level is the new state of the level; Shares and Orders are 0 on delete
+	Level BookLevel
+	// Attribution is the MPID of the order causing the change, empty for
+	// anonymous orders
+	Attribution string
+}
+
+// Book is the aggregated depth of book of a single stock
+type Book struct {
+	// StockLocate is the locate code of the stock
+	StockLocate uint16
+	// Stock is the trimmed stock symbol
+	Stock string
+
+	bids map[uint32]*BookLevel
+	asks map[uint32]*BookLevel
+}
+
+// newBook creates an empty book
+func newBook(locate uint16, stock string) *Book {
+	return &Book{
+		StockLocate: locate,
+		Stock:       stock,
+		bids:        make(map[uint32]*BookLevel),
+		asks:        make(map[uint32]*BookLevel),
+	}
+}
+
+// Bids returns up to depth bid levels, best first (-1 for all)
+func (b *Book) Bids(depth int) []BookLevel {
+	return sortedLevels(b.bids, depth, func(x, y uint32) bool { return x > y })
+}
+
+// Asks returns up to depth ask levels, best first (-1 for all)
+func (b *Book) Asks(depth int) []BookLevel {
+	return sortedLevels(b.asks, depth, func(x, y uint32) bool { return x < y })
+}
+
+// BestBid returns the best bid level
+func (b *Book) BestBid() (BookLevel, bool) {
+	levels := b.Bids(1)
+	if len(levels) == 0 {
+		return BookLevel{}, false
+	}
+	return levels[0], true
+}
+
+// BestAsk returns the best ask level
+func (b *Book) BestAsk() (BookLevel, bool) {
+	levels := b.Asks(1)
+	if len(levels) == 0 {
+		return BookLevel{}, false
+	}
+	return levels[0], true
+}
+
+// ChecksumDepth is the number of price levels per side covered by Book.Checksum
+const ChecksumDepth = 10
+
+// Checksum returns a CRC32 (IEEE) of the top ChecksumDepth price levels in the
+// format of matching.OrderBook.Checksum: for each depth the bid price and
+// shares followed by the ask price and shares, as big-endian uint64 values.
+// A book mirrored from a marketdata.Publisher feed has the same checksum as
+// the engine's order book.
+func (b *Book) Checksum() uint32 {
+	bids := b.Bids(ChecksumDepth)
+	asks := b.Asks(ChecksumDepth)
+
+	var buf [ChecksumDepth * 32]byte
+	n := 0
+	for i := 0; i < ChecksumDepth; i++ {
+		if i < len(bids) {
+			binary.BigEndian.PutUint64(buf[n:], uint64(bids[i].Price))
+			binary.BigEndian.PutUint64(buf[n+8:], bids[i].Shares)
+			n += 16
+		}
+		if i < len(asks) {
+			binary.BigEndian.PutUint64(buf[n:], uint64(asks[i].Price))
+			binary.BigEndian.PutUint64(buf[n+8:], asks[i].Shares)
+			n += 16
+		}
+	}
+	return crc32.ChecksumIEEE(buf[:n])
+}
+
+// sortedLevels copies the levels of one side in priority order
+func sortedLevels(levels map[uint32]*BookLevel, depth int, better func(x, y uint32) bool) []BookLevel {
+	result := make([]BookLevel, 0, len(levels))
+	for _, level := range levels {
+		result = append(result, *level)
+	}
+	sort.Slice(result, func(i, j int) bool { return better(result[i].Price, result[j].Price) })
+	if depth >= 0 && len(result) > depth {
+		result = result[:depth]
+	}
+	return result
+}
+
+// BookBuilder maintains the aggregated depth of book of every stock from
+// order messages. With OnDepthChange set it also emits the incremental price
+// level changes, so consumers can mirror books or publish L2 data without
+// diffing snapshots.
+type BookBuilder struct {
+	DefaultHandler
+
+	tracker orderTracker
+	books   map[uint16]*Book
+
+	// OnDepthChange is called for every price level change (optional)
+	OnDepthChange func(c DepthChange)
+}
+
+// NewBookBuilder creates a new book builder
+func NewBookBuilder() *BookBuilder {
+	return &BookBuilder{
+		tracker: newOrderTracker(),
+		books:   make(map[uint16]*Book),
+	}
+}
+
+// Directory returns the symbol directory of the builder
+func (h *BookBuilder) Directory() *SymbolDirectory {
+	return h.tracker.directory
+}
+
+// SetDirectory shares a symbol directory with other handlers. It must be set
+// before the first message.
+func (h *BookBuilder) SetDirectory(d *SymbolDirectory) {
+	h.tracker.directory = d
+}
+
+// Book returns the book of a stock, or nil if no order has been seen for it
+func (h *BookBuilder) Book(stock string) *Book {
+	locate, ok := h.tracker.directory.Locate(stock)
+	if !ok {
+		return nil
+	}
+	return h.books[locate]
+}
+
+// BookByLocate returns the book of a locate code, or nil
+func (h *BookBuilder) BookByLocate(locate uint16) *Book {
+	return h.books[locate]
+}
+
+// Books returns every book ordered by locate code
+func (h *BookBuilder) Books() []*Book {
+	books := make([]*Book, 0, len(h.books))
+	for _, book := range h.books {
+		books = append(books, book)
+	}
+	sort.Slice(books, func(i, j int) bool { return books[i].StockLocate < books[j].StockLocate })
+	return books
+}
+
+// book returns the book of a locate code, creating it if needed
+func (h *BookBuilder) book(locate uint16) *Book {
+	book, ok := h.books[locate]
+	if !ok {
+		book = newBook(locate, h.tracker.stock(locate))
+		h.books[locate] = book
+	}
+	return book
+}
+
+// apply changes a price level by the given shares and orders
+func (h *BookBuilder) apply(timestamp uint64, locate uint16, side byte, price uint32, shares int64, orders int, mpid string) {
+	book := h.book(locate)
+	levels := book.bids
+	if side == 'S' {
+		levels = book.asks
+	}
+
+	action := DepthUpdate
+	level, ok := levels[price]
+	if !ok {
+		action = DepthAdd
+		level = &BookLevel{Price: price}
+		levels[price] = level
+	}
+	level.Shares = uint64(int64(level.Shares) + shares)
+	level.Orders += orders
+	if level.Orders <= 0 || level.Shares == 0 {
+		action = DepthDelete
+		delete(levels, price)
+		*level = BookLevel{Price: price}
+	}
+
+	if h.OnDepthChange != nil {
+		h.OnDepthChange(DepthChange{
+			Timestamp:   timestamp,
+			StockLocate: locate,
+			Stock:       book.Stock,
+			Action:      action,
+			Side:        side,
+			Level:       *level,
+			Attribution: mpid,
+		})
+	}
+}
+
+// reduce removes shares from a tracked order's level, given the order's
+// state before the reduction
+func (h *BookBuilder) reduce(timestamp uint64, order trackedOrder, shares uint32) {
+	orders := 0
+	if shares >= order.shares {
+		shares = order.shares
+		orders = -1
+	}
+	h.apply(timestamp, order.locate, order.side, order.price, -int64(shares), orders, order.mpid)
+}
+
+// OnStockDirectory registers the stock in the directory
+func (h *BookBuilder) OnStockDirectory(msg StockDirectoryMessage) error {
+	h.tracker.directory.Add(msg)
+	return nil
+}
+
+// OnAddOrder adds the order to its price level
+func (h *BookBuilder) OnAddOrder(msg AddOrderMessage) error {
+	h.tracker.add(msg.Timestamp, msg.OrderReferenceNumber, msg.StockLocate, msg.Stock, msg.BuySellIndicator, msg.Shares, msg.Price)
+	h.apply(msg.Timestamp, msg.StockLocate, msg.BuySellIndicator, msg.Price, int64(msg.Shares), 1, "")
+	return nil
+}
+
+// OnAddOrderMPID adds the order to its price level
+func (h *BookBuilder) OnAddOrderMPID(msg AddOrderMPIDMessage) error {
+	h.tracker.addMPID(msg)
+	h.apply(msg.Timestamp, msg.StockLocate, msg.BuySellIndicator, msg.Price, int64(msg.Shares), 1, trimMPID(msg.Attribution))
+	return nil
+}
+
+// OnOrderExecuted removes the executed shares from the order's level
+func (h *BookBuilder) OnOrderExecuted(msg OrderExecutedMessage) error {
+	if order, ok := h.tracker.execute(msg.OrderReferenceNumber, msg.ExecutedShares); ok {
+		h.reduce(msg.Timestamp, order, msg.ExecutedShares)
+	}
+	return nil
+}
+
+// OnOrderExecutedWithPrice removes the executed shares from the order's level
+func (h *BookBuilder) OnOrderExecutedWithPrice(msg OrderExecutedWithPriceMessage) error {
+	if order, ok := h.tracker.execute(msg.OrderReferenceNumber, msg.ExecutedShares); ok {
+		h.reduce(msg.Timestamp, order, msg.ExecutedShares)
+	}
+	return nil
+}
+
+// OnOrderCancel removes the canceled shares from the order's level
+func (h *BookBuilder) OnOrderCancel(msg OrderCancelMessage) error {
+	if order, ok := h.tracker.cancel(msg.OrderReferenceNumber, msg.CanceledShares); ok {
+		h.reduce(msg.Timestamp, order, msg.CanceledShares)
+	}
+	return nil
+}
+
+// OnOrderDelete removes the order from its level
+func (h *BookBuilder) OnOrderDelete(msg OrderDeleteMessage) error {
+	if order, ok := h.tracker.delete(msg.OrderReferenceNumber); ok {
+		h.reduce(msg.Timestamp, order, order.shares)
+	}
+	return nil
+}
+
+// OnOrderReplace moves the order to its new price level
+func (h *BookBuilder) OnOrderReplace(msg OrderReplaceMessage) error {
+	order, ok := h.tracker.replace(msg.OriginalOrderReferenceNumber, msg.NewOrderReferenceNumber, msg.Shares, msg.Price)
+	if !ok {
+		return nil
+	}
+	h.reduce(msg.Timestamp, order, order.shares)
+	h.apply(msg.Timestamp, order.locate, order.side, msg.Price, int64(msg.Shares), 1, order.mpid)
+	return nil
+}
@@ -0,0 +1,92 @@
+package itch
+
+import "testing"
+
+func TestBookBuilder_DepthChanges(t *testing.T) {
+	h := NewBookBuilder()
+	var changes []DepthChange
+	h.OnDepthChange = func(c DepthChange) { changes = append(changes, c) }
+
+	stock := stockField("AAPL")
+	h.OnStockDirectory(StockDirectoryMessage{StockLocate: 1, Stock: stock})
+	h.OnAddOrder(AddOrderMessage{StockLocate: 1, OrderReferenceNumber: 1, BuySellIndicator: 'B', Shares: 100, Stock: stock, Price: 1000000})
+	h.OnAddOrder(AddOrderMessage{StockLocate: 1, OrderReferenceNumber: 2, BuySellIndicator: 'B', Shares: 200, Stock: stock, Price: 1000000})
+	h.OnAddOrderMPID(AddOrderMPIDMessage{StockLocate: 1, OrderReferenceNumber: 3, BuySellIndicator: 'S', Shares: 300, Stock: stock, Price: 1010000, Attribution: MPIDField("GSCO")})
+	h.OnOrderExecuted(OrderExecutedMessage{StockLocate: 1, OrderReferenceNumber: 1, ExecutedShares: 100, MatchNumber: 1})
+	h.OnOrderCancel(OrderCancelMessage{StockLocate: 1, OrderReferenceNumber: 3, CanceledShares: 50})
+	h.OnOrderReplace(OrderReplaceMessage{StockLocate: 1, OriginalOrderReferenceNumber: 3, NewOrderReferenceNumber: 4, Shares: 100, Price: 1005000})
+	h.OnOrderDelete(OrderDeleteMessage{StockLocate: 1, OrderReferenceNumber: 2})
+
+	expected := []struct {
+		action DepthAction
+		side   byte
+		price  uint32
+		shares uint64
+		orders int
+	}{
+		{DepthAdd, 'B', 1000000, 100, 1},
+		{DepthUpdate, 'B', 1000000, 300, 2},
+		{DepthAdd, 'S', 1010000, 300, 1},
+		{DepthUpdate, 'B', 1000000, 200, 1},
+		{DepthUpdate, 'S', 1010000, 250, 1},
+		{DepthDelete, 'S', 1010000, 0, 0},
+		{DepthAdd, 'S', 1005000, 100, 1},
+		{DepthDelete, 'B', 1000000, 0, 0},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %d: %+v", len(expected), len(changes), changes)
+	}
+	for i, e := range expected {
+		c := changes[i]
+		if c.Action != e.action || c.Side != e.side || c.Level.Price != e.price || c.Level.Shares != e.shares || c.Level.Orders != e.orders {
+			t.Errorf("Change %d: expected %s %c %d x %d (%d orders), got %s %c %d x %d (%d orders)",
+				i, e.action, e.side, e.price, e.shares, e.orders,
+				c.Action, c.Side, c.Level.Price, c.Level.Shares, c.Level.Orders)
+		}
+		if c.Stock != "AAPL" || c.StockLocate != 1 {
+			t.Errorf("Change %d: expected AAPL/1, got %s/%d", i, c.Stock, c.StockLocate)
+		}
+		attribution := ""
+		if e.side == 'S' {
+			attribution = "GSCO"
+		}
+		if c.Attribution != attribution {
+			t.Errorf("Change %d: expected attribution %q, got %q", i, attribution, c.Attribution)
+		}
+	}
+
+	book := h.Book("AAPL")
+	if book == nil {
+		t.Fatal("Expected AAPL book")
+	}
+	if _, ok := book.BestBid(); ok {
+		t.Error("Expected empty bid side")
+	}
+	if ask, ok := book.BestAsk(); !ok || ask.Price != 1005000 || ask.Shares != 100 {
+		t.Errorf("Expected best ask 1005000 x 100, got %+v", ask)
+	}
+}
+
+func TestBookBuilder_Levels(t *testing.T) {
+	h := NewBookBuilder()
+	stock := stockField("MSFT")
+	for i, price := range []uint32{100, 300, 200} {
+		h.OnAddOrder(AddOrderMessage{StockLocate: 2, OrderReferenceNumber: uint64(i + 1), BuySellIndicator: 'B', Shares: 10, Stock: stock, Price: price})
+		h.OnAddOrder(AddOrderMessage{StockLocate: 2, OrderReferenceNumber: uint64(i + 11), BuySellIndicator: 'S', Shares: 10, Stock: stock, Price: price + 1000})
+	}
+	// Unknown references are ignored
+	h.OnOrderDelete(OrderDeleteMessage{StockLocate: 2, OrderReferenceNumber: 99})
+
+	book := h.BookByLocate(2)
+	bids := book.Bids(2)
+	if len(bids) != 2 || bids[0].Price != 300 || bids[1].Price != 200 {
+		t.Errorf("Expected bids [300 200], got %+v", bids)
+	}
+	asks := book.Asks(-1)
+	if len(asks) != 3 || asks[0].Price != 1100 || asks[2].Price != 1300 {
+		t.Errorf("Expected asks [1100 1200 1300], got %+v", asks)
+	}
+	if len(h.Books()) != 1 || h.Books()[0].Stock != "MSFT" {
+		t.Errorf("Expected a single MSFT book, got %d books", len(h.Books()))
+	}
+}
@@ -0,0 +1,183 @@
+package itch
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCallbackType is returned by Parser.On for a callback whose signature does
+// not match the message type
+var ErrCallbackType = errors.New("callback does not match the message type")
+
+// messageSizes is the length of each message type, 0 for unknown types
+var messageSizes = [256]int{
+	MessageTypeSystemEvent:            12,
+	MessageTypeStockDirectory:         39,
+	MessageTypeStockTradingAction:     25,
+	MessageTypeRegSHO:                 20,
+	MessageTypeMarketParticipantPos:   26,
+	MessageTypeMWCBDecline:            35,
+	MessageTypeMWCBStatus:             12,
+	MessageTypeIPOQuoting:             28,
+	MessageTypeAddOrder:               36,
+	MessageTypeAddOrderMPID:           40,
+	MessageTypeOrderExecuted:          31,
+	MessageTypeOrderExecutedWithPrice: 36,
+	MessageTypeOrderCancel:            23,
+	MessageTypeOrderDelete:            19,
+	MessageTypeOrderReplace:           35,
+	MessageTypeTrade:                  44,
+	MessageTypeCrossTrade:             40,
+	MessageTypeBrokenTrade:            19,
+	MessageTypeNOII:                   50,
+	MessageTypeRPII:                   20,
+}
+
+// callbacks is the Handler of the callbacks registered with Parser.On. Only
+// registered message types are dispatched to it.
+type callbacks struct {
+	DefaultHandler
+
+	registered [256]bool
+
+	systemEvent            func(SystemEventMessage) error
+	stockDirectory         func(StockDirectoryMessage) error
+	stockTradingAction     func(StockTradingActionMessage) error
+	regSHO                 func(RegSHOMessage) error
+	marketParticipantPos   func(MarketParticipantPositionMessage) error
+	mwcbDecline            func(MWCBDeclineMessage) error
+	mwcbStatus             func(MWCBStatusMessage) error
+	ipoQuoting             func(IPOQuotingMessage) error
+	addOrder               func(AddOrderMessage) error
+	addOrderMPID           func(AddOrderMPIDMessage) error
+	orderExecuted          func(OrderExecutedMessage) error
+	orderExecutedWithPrice func(OrderExecutedWithPriceMessage) error
+	orderCancel            func(OrderCancelMessage) error
+	orderDelete            func(OrderDeleteMessage) error
+	orderReplace           func(OrderReplaceMessage) error
+	trade                  func(TradeMessage) error
+	crossTrade             func(CrossTradeMessage) error
+	brokenTrade            func(BrokenTradeMessage) error
+	noii                   func(NOIIMessage) error
+	rpii                   func(RPIIMessage) error
+}
+
+// On registers callback for the messages of msgType, replacing any previous
+// callback of that type. callback must be a func(XMessage) error of the
+// message type, for example func(AddOrderMessage) error for 'A'; otherwise
+// ErrCallbackType is returned.
+//
+// Registered types are passed to their callback instead of the handler. The
+// other types go to the handler, or, for a parser created with a nil handler,
+// are skipped without being decoded:
+//
+//	p := itch.NewParser(nil)
+//	p.On(itch.MessageTypeAddOrder, func(msg itch.AddOrderMessage) error {
+//		adds++
+//		return nil
+//	})
+func (p *Parser) On(msgType byte, callback any) error {
+	if p.callbacks == nil {
+		p.callbacks = &callbacks{}
+	}
+	c := p.callbacks
+	ok := false
+	switch msgType {
+	case MessageTypeSystemEvent:
+		c.systemEvent, ok = callback.(func(SystemEventMessage) error)
+	case MessageTypeStockDirectory:
+		c.stockDirectory, ok = callback.(func(StockDirectoryMessage) error)
+	case MessageTypeStockTradingAction:
+		c.stockTradingAction, ok = callback.(func(StockTradingActionMessage) error)
+	case MessageTypeRegSHO:
+		c.regSHO, ok = callback.(func(RegSHOMessage) error)
+	case MessageTypeMarketParticipantPos:
+		c.marketParticipantPos, ok = callback.(func(MarketParticipantPositionMessage) error)
+	case MessageTypeMWCBDecline:
+		c.mwcbDecline, ok = callback.(func(MWCBDeclineMessage) error)
+	case MessageTypeMWCBStatus:
+		c.mwcbStatus, ok = callback.(func(MWCBStatusMessage) error)
+	case MessageTypeIPOQuoting:
+		c.ipoQuoting, ok = callback.(func(IPOQuotingMessage) error)
+	case MessageTypeAddOrder:
+		c.addOrder, ok = callback.(func(AddOrderMessage) error)
+	case MessageTypeAddOrderMPID:
+		c.addOrderMPID, ok = callback.(func(AddOrderMPIDMessage) error)
+	case MessageTypeOrderExecuted:
+		c.orderExecuted, ok = callback.(func(OrderExecutedMessage) error)
+	case MessageTypeOrderExecutedWithPrice:
+		c.orderExecutedWithPrice, ok = callback.(func(OrderExecutedWithPriceMessage) error)
+	case MessageTypeOrderCancel:
+		c.orderCancel, ok = callback.(func(OrderCancelMessage) error)
+	case MessageTypeOrderDelete:
+		c.orderDelete, ok = callback.(func(OrderDeleteMessage) error)
+	case MessageTypeOrderReplace:
+		c.orderReplace, ok = callback.(func(OrderReplaceMessage) error)
+	case MessageTypeTrade:
+		c.trade, ok = callback.(func(TradeMessage) error)
+	case MessageTypeCrossTrade:
+		c.crossTrade, ok = callback.(func(CrossTradeMessage) error)
+	case MessageTypeBrokenTrade:
+		c.brokenTrade, ok = callback.(func(BrokenTradeMessage) error)
+	case MessageTypeNOII:
+		c.noii, ok = callback.(func(NOIIMessage) error)
+	case MessageTypeRPII:
+		c.rpii, ok = callback.(func(RPIIMessage) error)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownMessageType, msgType)
+	}
+	if !ok {
+		return fmt.Errorf("%w: %q got %T", ErrCallbackType, msgType, callback)
+	}
+	c.registered[msgType] = true
+	return nil
+}
+
+// dispatch parses a message for a parser with callbacks: registered types go
+// to their callback, the others to the handler or, without one, are skipped
+func (p *Parser) dispatch(data []byte) (int, error) {
+	msgType := data[0]
+	if p.callbacks.registered[msgType] {
+		return parseMessage(p.callbacks, data)
+	}
+	if p.handler != nil {
+		return parseMessage(p.handler, data)
+	}
+	size := messageSizes[msgType]
+	if size == 0 {
+		return len(data), nil
+	}
+	if len(data) < size {
+		return 0, ErrInsufficientData
+	}
+	return size, nil
+}
+
+func (c *callbacks) OnSystemEvent(msg SystemEventMessage) error       { return c.systemEvent(msg) }
+func (c *callbacks) OnStockDirectory(msg StockDirectoryMessage) error { return c.stockDirectory(msg) }
+func (c *callbacks) OnStockTradingAction(msg StockTradingActionMessage) error {
+	return c.stockTradingAction(msg)
+}
+func (c *callbacks) OnRegSHO(msg RegSHOMessage) error { return c.regSHO(msg) }
+func (c *callbacks) OnMarketParticipantPosition(msg MarketParticipantPositionMessage) error {
+	return c.marketParticipantPos(msg)
+}
+func (c *callbacks) OnMWCBDecline(msg MWCBDeclineMessage) error { return c.mwcbDecline(msg) }
+func (c *callbacks) OnMWCBStatus(msg MWCBStatusMessage) error   { return c.mwcbStatus(msg) }
+func (c *callbacks) OnIPOQuoting(msg IPOQuotingMessage) error   { return c.ipoQuoting(msg) }
+func (c *callbacks) OnAddOrder(msg AddOrderMessage) error       { return c.addOrder(msg) }
+func (c *callbacks) OnAddOrderMPID(msg AddOrderMPIDMessage) error {
+	return c.addOrderMPID(msg)
+}
+func (c *callbacks) OnOrderExecuted(msg OrderExecutedMessage) error { return c.orderExecuted(msg) }
+func (c *callbacks) OnOrderExecutedWithPrice(msg OrderExecutedWithPriceMessage) error {
+	return c.orderExecutedWithPrice(msg)
+}
+func (c *callbacks) OnOrderCancel(msg OrderCancelMessage) error   { return c.orderCancel(msg) }
+func (c *callbacks) OnOrderDelete(msg OrderDeleteMessage) error   { return c.orderDelete(msg) }
+func (c *callbacks) OnOrderReplace(msg OrderReplaceMessage) error { return c.orderReplace(msg) }
+func (c *callbacks) OnTrade(msg TradeMessage) error               { return c.trade(msg) }
+func (c *callbacks) OnCrossTrade(msg CrossTradeMessage) error     { return c.crossTrade(msg) }
+func (c *callbacks) OnBrokenTrade(msg BrokenTradeMessage) error   { return c.brokenTrade(msg) }
+func (c *callbacks) OnNOII(msg NOIIMessage) error                 { return c.noii(msg) }
+func (c *callbacks) OnRPII(msg RPIIMessage) error                 { return c.rpii(msg) }
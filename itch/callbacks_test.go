@@ -0,0 +1,126 @@
+package itch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParser_On(t *testing.T) {
+	var data []byte
+	for _, msg := range testMessages() {
+		data = appendMessage(data, msg)
+	}
+
+	// Only the registered types are decoded, the others are skipped
+	var adds []AddOrderMessage
+	p := NewParser(nil)
+	if err := p.On(MessageTypeAddOrder, func(msg AddOrderMessage) error {
+		adds = append(adds, msg)
+		return nil
+	}); err != nil {
+		t.Fatalf("On: %v", err)
+	}
+	consumed, count, err := p.ParseAll(data)
+	if err != nil {
+		t.Fatalf("ParseAll: %v", err)
+	}
+	if consumed != len(data) || count != len(testMessages()) {
+		t.Errorf("Expected %d messages in %d bytes, got %d in %d", len(testMessages()), len(data), count, consumed)
+	}
+	if len(adds) != 1 || adds[0].OrderReferenceNumber != 1 {
+		t.Errorf("Expected the add order, got %+v", adds)
+	}
+	if _, err := p.Parse(data[:5]); err != ErrInsufficientData {
+		t.Errorf("Expected ErrInsufficientData, got %v", err)
+	}
+
+	// Callback errors are reported as handler errors
+	stop := errors.New("stop")
+	p = NewParser(nil)
+	p.On(MessageTypeAddOrder, func(AddOrderMessage) error { return stop })
+	var herr *HandlerError
+	if _, _, err := p.ParseAll(data); !errors.As(err, &herr) || !errors.Is(err, stop) || herr.MessageType != 'A' {
+		t.Errorf("Expected a handler error wrapping the callback error, got %v", err)
+	}
+
+	if err := p.On(MessageTypeTrade, func(AddOrderMessage) error { return nil }); !errors.Is(err, ErrCallbackType) {
+		t.Errorf("Expected ErrCallbackType, got %v", err)
+	}
+	if err := p.On('z', func(AddOrderMessage) error { return nil }); !errors.Is(err, ErrUnknownMessageType) {
+		t.Errorf("Expected ErrUnknownMessageType, got %v", err)
+	}
+}
+
+func TestParser_OnEveryType(t *testing.T) {
+	var data []byte
+	for _, msg := range testMessages() {
+		data = appendMessage(data, msg)
+	}
+	want := &recordHandler{}
+	if _, _, err := NewParser(want).ParseAll(data); err != nil {
+		t.Fatalf("ParseAll: %v", err)
+	}
+
+	var got []any
+	p := NewParser(nil)
+	register := func(msgType byte, callback any) {
+		t.Helper()
+		if err := p.On(msgType, callback); err != nil {
+			t.Fatalf("On(%q): %v", msgType, err)
+		}
+	}
+	register('S', func(m SystemEventMessage) error { got = append(got, m); return nil })
+	register('R', func(m StockDirectoryMessage) error { got = append(got, m); return nil })
+	register('H', func(m StockTradingActionMessage) error { got = append(got, m); return nil })
+	register('Y', func(m RegSHOMessage) error { got = append(got, m); return nil })
+	register('L', func(m MarketParticipantPositionMessage) error { got = append(got, m); return nil })
+	register('V', func(m MWCBDeclineMessage) error { got = append(got, m); return nil })
+	register('W', func(m MWCBStatusMessage) error { got = append(got, m); return nil })
+	register('K', func(m IPOQuotingMessage) error { got = append(got, m); return nil })
+	register('A', func(m AddOrderMessage) error { got = append(got, m); return nil })
+	register('F', func(m AddOrderMPIDMessage) error { got = append(got, m); return nil })
+	register('E', func(m OrderExecutedMessage) error { got = append(got, m); return nil })
+	register('C', func(m OrderExecutedWithPriceMessage) error { got = append(got, m); return nil })
+	register('X', func(m OrderCancelMessage) error { got = append(got, m); return nil })
+	register('D', func(m OrderDeleteMessage) error { got = append(got, m); return nil })
+	register('U', func(m OrderReplaceMessage) error { got = append(got, m); return nil })
+	register('P', func(m TradeMessage) error { got = append(got, m); return nil })
+	register('Q', func(m CrossTradeMessage) error { got = append(got, m); return nil })
+	register('B', func(m BrokenTradeMessage) error { got = append(got, m); return nil })
+	register('I', func(m NOIIMessage) error { got = append(got, m); return nil })
+	register('N', func(m RPIIMessage) error { got = append(got, m); return nil })
+	if _, _, err := p.ParseAll(data); err != nil {
+		t.Fatalf("ParseAll: %v", err)
+	}
+	if !reflect.DeepEqual(got, want.msgs) {
+		t.Errorf("Expected messages %+v, got %+v", want.msgs, got)
+	}
+
+	// Unregistered types still reach the handler
+	rest := &recordHandler{}
+	adds := 0
+	p = NewParser(rest)
+	p.On(MessageTypeAddOrder, func(AddOrderMessage) error { adds++; return nil })
+	if _, _, err := p.ParseAll(data); err != nil {
+		t.Fatalf("ParseAll: %v", err)
+	}
+	if adds != 1 || len(rest.msgs) != len(want.msgs)-1 {
+		t.Errorf("Expected 1 callback and %d handler messages, got %d and %d", len(want.msgs)-1, adds, len(rest.msgs))
+	}
+}
+
+func BenchmarkParser_OnSession(b *testing.B) {
+	data := sampleSession(10000)
+	var added uint64
+	parser := NewParser(nil)
+	parser.On(MessageTypeAddOrder, func(msg AddOrderMessage) error {
+		added += uint64(msg.Shares)
+		return nil
+	})
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parser.ParseAll(data)
+	}
+}
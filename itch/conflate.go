@@ -0,0 +1,274 @@
+package itch
+
+import (
+	"sort"
+	"time"
+)
+
+// ConflationConfig configures a ConflationHandler
+type ConflationConfig struct {
+	// Interval is the conflation interval in message time
+	Interval time.Duration
+	// Depth is the number of price levels per side of the updates, 0 for all
+	Depth int
+	// Stocks restricts conflation to these stocks, empty for all
+	Stocks []string
+}
+
+// ConflatedUpdate is the state of a stock at the end of a conflation
+// interval in which its book changed or it traded
+type ConflatedUpdate struct {
+	// Timestamp is the end of the interval in nanoseconds since midnight
+	Timestamp uint64
+	// StockLocate is the locate code of the stock
+	StockLocate uint16
+	// Stock is the trimmed stock symbol
+	Stock string
+
+	// BookChanged is true if the book changed in the interval
+	BookChanged bool
+	// Bids and Asks are the levels of the book at the end of the interval,
+	// best first
+	Bids []BookLevel
+	Asks []BookLevel
+
+	// Trades is the number of prints in the interval
+	Trades uint64
+	// Volume is the number of shares printed in the interval
+	Volume uint64
+	// Open, High, Low and Last are the print prices of the interval (4
+	// implied decimals), 0 without prints
+	Open uint32
+	High uint32
+	Low  uint32
+	Last uint32
+}
+
+// ConflationStats counts the events conflated by a ConflationHandler
+type ConflationStats struct {
+	// Events is the number of price level changes and prints
+	Events uint64
+	// Updates is the number of updates emitted
+	Updates uint64
+}
+
+// ConflationHandler is an ITCH handler emitting at most one update per stock
+// per interval of message time, for plotting tools that cannot keep up with
+// every book change and print. Updates are emitted at multiples of the
+// interval since midnight and hold the book at the end of the interval and
+// the prints within it, so the last update of each interval is exact. Stocks
+// that did not change are not repeated, so consumers carry the last update
+// of a stock forward. Broken trades are not taken back. Call Flush at the end
+// of the stream to emit the final interval.
+type ConflationHandler struct {
+	*BookBuilder
+
+	interval uint64
+	depth    int
+	stocks   map[string]bool
+	next     uint64
+	pending  map[uint16]*ConflatedUpdate
+	stats    ConflationStats
+
+	// OnUpdate is called with every conflated update; an error stops parsing
+	OnUpdate func(u ConflatedUpdate) error
+}
+
+// NewConflationHandler creates a conflation handler calling onUpdate with
+// every update
+func NewConflationHandler(config ConflationConfig, onUpdate func(u ConflatedUpdate) error) *ConflationHandler {
+	h := &ConflationHandler{
+		BookBuilder: NewBookBuilder(),
+		interval:    uint64(max(config.Interval, 1)),
+		depth:       config.Depth,
+		pending:     make(map[uint16]*ConflatedUpdate),
+		OnUpdate:    onUpdate,
+	}
+	if h.depth <= 0 {
+		h.depth = -1
+	}
+	if len(config.Stocks) > 0 {
+		h.stocks = make(map[string]bool, len(config.Stocks))
+		for _, s := range config.Stocks {
+			h.stocks[s] = true
+		}
+	}
+	h.BookBuilder.OnDepthChange = func(c DepthChange) {
+		if u := h.update(c.StockLocate); u != nil {
+			u.BookChanged = true
+			h.stats.Events++
+		}
+	}
+	return h
+}
+
+// Stats returns the number of events conflated and updates emitted
+func (h *ConflationHandler) Stats() ConflationStats {
+	return h.stats
+}
+
+// update returns the pending update of a locate code, creating it if needed,
+// or nil if the stock is not conflated
+func (h *ConflationHandler) update(locate uint16) *ConflatedUpdate {
+	if u, ok := h.pending[locate]; ok {
+		return u
+	}
+	stock := h.tracker.stock(locate)
+	if h.stocks != nil && !h.stocks[stock] {
+		return nil
+	}
+	u := &ConflatedUpdate{StockLocate: locate, Stock: stock}
+	h.pending[locate] = u
+	return u
+}
+
+// print adds a print to the pending update of its stock
+func (h *ConflationHandler) print(locate uint16, shares uint64, price uint32) {
+	u := h.update(locate)
+	if u == nil {
+		return
+	}
+	h.stats.Events++
+	if u.Trades == 0 {
+		u.Open, u.High, u.Low = price, price, price
+	}
+	u.Trades++
+	u.Volume += shares
+	u.High = max(u.High, price)
+	u.Low = min(u.Low, price)
+	u.Last = price
+}
+
+// advance emits the pending updates if timestamp is past the end of the
+// interval. The changes happened before it, so the updates are stamped with
+// that time.
+func (h *ConflationHandler) advance(timestamp uint64) error {
+	if timestamp < h.next {
+		return nil
+	}
+	err := h.emit(h.next)
+	h.next = timestamp - timestamp%h.interval + h.interval
+	return err
+}
+
+// Flush emits the updates pending since the last interval at the end of the
+// current one
+func (h *ConflationHandler) Flush() error {
+	return h.emit(h.next)
+}
+
+// emit calls OnUpdate with every pending update, in locate order
+func (h *ConflationHandler) emit(at uint64) error {
+	if len(h.pending) == 0 {
+		return nil
+	}
+	locates := make([]uint16, 0, len(h.pending))
+	for locate := range h.pending {
+		locates = append(locates, locate)
+	}
+	sort.Slice(locates, func(i, j int) bool { return locates[i] < locates[j] })
+
+	var err error
+	for _, locate := range locates {
+		u := h.pending[locate]
+		u.Timestamp = at
+		if book := h.BookByLocate(locate); book != nil {
+			u.Bids = book.Bids(h.depth)
+			u.Asks = book.Asks(h.depth)
+		}
+		h.stats.Updates++
+		if h.OnUpdate != nil && err == nil {
+			err = h.OnUpdate(*u)
+		}
+	}
+	clear(h.pending)
+	return err
+}
+
+// OnAddOrder emits the finished interval and adds the order
+func (h *ConflationHandler) OnAddOrder(msg AddOrderMessage) error {
+	if err := h.advance(msg.Timestamp); err != nil {
+		return err
+	}
+	return h.BookBuilder.OnAddOrder(msg)
+}
+
+// OnAddOrderMPID emits the finished interval and adds the order
+func (h *ConflationHandler) OnAddOrderMPID(msg AddOrderMPIDMessage) error {
+	if err := h.advance(msg.Timestamp); err != nil {
+		return err
+	}
+	return h.BookBuilder.OnAddOrderMPID(msg)
+}
+
+// OnOrderExecuted emits the finished interval and prints the execution at
+// the resting order's price
+func (h *ConflationHandler) OnOrderExecuted(msg OrderExecutedMessage) error {
+	if err := h.advance(msg.Timestamp); err != nil {
+		return err
+	}
+	if order, ok := h.tracker.orders[msg.OrderReferenceNumber]; ok {
+		h.print(order.locate, uint64(msg.ExecutedShares), order.price)
+	}
+	return h.BookBuilder.OnOrderExecuted(msg)
+}
+
+// OnOrderExecutedWithPrice emits the finished interval and prints printable
+// executions at the execution price
+func (h *ConflationHandler) OnOrderExecutedWithPrice(msg OrderExecutedWithPriceMessage) error {
+	if err := h.advance(msg.Timestamp); err != nil {
+		return err
+	}
+	if order, ok := h.tracker.orders[msg.OrderReferenceNumber]; ok && msg.Printable == 'Y' {
+		h.print(order.locate, uint64(msg.ExecutedShares), msg.ExecutionPrice)
+	}
+	return h.BookBuilder.OnOrderExecutedWithPrice(msg)
+}
+
+// OnOrderCancel emits the finished interval and cancels shares of the order
+func (h *ConflationHandler) OnOrderCancel(msg OrderCancelMessage) error {
+	if err := h.advance(msg.Timestamp); err != nil {
+		return err
+	}
+	return h.BookBuilder.OnOrderCancel(msg)
+}
+
+// OnOrderDelete emits the finished interval and deletes the order
+func (h *ConflationHandler) OnOrderDelete(msg OrderDeleteMessage) error {
+	if err := h.advance(msg.Timestamp); err != nil {
+		return err
+	}
+	return h.BookBuilder.OnOrderDelete(msg)
+}
+
+// OnOrderReplace emits the finished interval and replaces the order
+func (h *ConflationHandler) OnOrderReplace(msg OrderReplaceMessage) error {
+	if err := h.advance(msg.Timestamp); err != nil {
+		return err
+	}
+	return h.BookBuilder.OnOrderReplace(msg)
+}
+
+// OnTrade emits the finished interval and prints the non-displayable
+// execution
+func (h *ConflationHandler) OnTrade(msg TradeMessage) error {
+	if err := h.advance(msg.Timestamp); err != nil {
+		return err
+	}
+	h.tracker.register(msg.StockLocate, msg.Stock)
+	h.print(msg.StockLocate, uint64(msg.Shares), msg.Price)
+	return nil
+}
+
+// OnCrossTrade emits the finished interval and prints the cross volume, if
+// any shares were matched
+func (h *ConflationHandler) OnCrossTrade(msg CrossTradeMessage) error {
+	if err := h.advance(msg.Timestamp); err != nil {
+		return err
+	}
+	h.tracker.register(msg.StockLocate, msg.Stock)
+	if msg.Shares != 0 {
+		h.print(msg.StockLocate, msg.Shares, msg.CrossPrice)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package itch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConflationHandler(t *testing.T) {
+	second := uint64(time.Second)
+	var updates []ConflatedUpdate
+	h := NewConflationHandler(ConflationConfig{Interval: time.Second, Depth: 1, Stocks: []string{"AAPL"}}, func(u ConflatedUpdate) error {
+		updates = append(updates, u)
+		return nil
+	})
+
+	aapl, msft := stockField("AAPL"), stockField("MSFT")
+	h.OnAddOrder(AddOrderMessage{Timestamp: second / 4, StockLocate: 1, OrderReferenceNumber: 1, BuySellIndicator: 'B', Shares: 100, Stock: aapl, Price: 1000})
+	h.OnAddOrder(AddOrderMessage{Timestamp: second / 4, StockLocate: 1, OrderReferenceNumber: 2, BuySellIndicator: 'S', Shares: 100, Stock: aapl, Price: 1100})
+	h.OnAddOrder(AddOrderMessage{Timestamp: second / 4, StockLocate: 2, OrderReferenceNumber: 3, BuySellIndicator: 'S', Shares: 10, Stock: msft, Price: 2000})
+	// Prints within the first second, only the latest book is kept
+	h.OnOrderExecuted(OrderExecutedMessage{Timestamp: second / 2, StockLocate: 1, OrderReferenceNumber: 2, ExecutedShares: 30})
+	h.OnTrade(TradeMessage{Timestamp: second / 2, StockLocate: 1, BuySellIndicator: 'B', Shares: 20, Stock: aapl, Price: 1050})
+	h.OnOrderExecutedWithPrice(OrderExecutedWithPriceMessage{Timestamp: second / 2, StockLocate: 1, OrderReferenceNumber: 1, ExecutedShares: 10, Printable: 'Y', ExecutionPrice: 990})
+	h.OnOrderCancel(OrderCancelMessage{Timestamp: second / 2, StockLocate: 1, OrderReferenceNumber: 1, CanceledShares: 40})
+	// A print without a book change two seconds later
+	h.OnCrossTrade(CrossTradeMessage{Timestamp: 2*second + 1, StockLocate: 1, Shares: 500, Stock: aapl, CrossPrice: 1020})
+	if err := h.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	if len(updates) != 2 {
+		t.Fatalf("Expected 2 updates, got %+v", updates)
+	}
+	u := updates[0]
+	if u.Timestamp != second || u.Stock != "AAPL" || !u.BookChanged {
+		t.Errorf("Expected the AAPL book at 1s, got %+v", u)
+	}
+	if len(u.Bids) != 1 || u.Bids[0].Shares != 50 || len(u.Asks) != 1 || u.Asks[0].Shares != 70 {
+		t.Errorf("Expected the book at the end of the interval, got bids %+v asks %+v", u.Bids, u.Asks)
+	}
+	if u.Trades != 3 || u.Volume != 60 || u.Open != 1100 || u.High != 1100 || u.Low != 990 || u.Last != 990 {
+		t.Errorf("Expected the three prints of the interval, got %+v", u)
+	}
+	u = updates[1]
+	if u.Timestamp != 3*second || u.BookChanged || u.Trades != 1 || u.Last != 1020 || len(u.Bids) != 1 {
+		t.Errorf("Expected the cross at 3s with the unchanged book, got %+v", u)
+	}
+
+	// 5 level changes and 4 prints of AAPL
+	if stats := h.Stats(); stats.Events != 9 || stats.Updates != 2 {
+		t.Errorf("Expected 9 events and 2 updates, got %+v", stats)
+	}
+}
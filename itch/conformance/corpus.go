@@ -0,0 +1,225 @@
+// Package conformance generates a golden ITCH 5.0 corpus for validating feed
+// handlers: a length-prefixed message file exercising every message type and
+// edge case, together with the expected parsed output as JSON.
+//
+// Downstream consumers parse the file with their own decoder and compare each
+// message with the expected record at the same index. Go consumers can use
+// Parse, ReadExpected and Record.Equal.
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/tienpsm/go-trader/itch"
+)
+
+// Limits of the ITCH wire fields
+const (
+	// MaxTimestamp is the largest 48-bit timestamp
+	MaxTimestamp = 1<<48 - 1
+	// MaxUint16 is the largest locate code or tracking number
+	MaxUint16 = 1<<16 - 1
+	// MaxUint32 is the largest share count or price
+	MaxUint32 = 1<<32 - 1
+	// MaxUint64 is the largest order reference or match number
+	MaxUint64 = 1<<64 - 1
+)
+
+// Case is one message of the corpus
+type Case struct {
+	// Name describes what the message exercises
+	Name string
+	// Message is an itch message struct, such as itch.AddOrderMessage
+	Message any
+}
+
+// Expected is the JSON document describing the parsed corpus
+type Expected struct {
+	// Messages is the number of messages in the file
+	Messages int `json:"messages"`
+	// Bytes is the size of the file
+	Bytes int64 `json:"bytes"`
+	// Records holds the expected parsed form of every message, in order
+	Records []Record `json:"records"`
+}
+
+// Encode appends the wire representation of an itch message struct to b
+func Encode(b []byte, msg any) ([]byte, error) {
+	switch m := msg.(type) {
+	case itch.SystemEventMessage:
+		return itch.AppendSystemEvent(b, m), nil
+	case itch.StockDirectoryMessage:
+		return itch.AppendStockDirectory(b, m), nil
+	case itch.StockTradingActionMessage:
+		return itch.AppendStockTradingAction(b, m), nil
+	case itch.RegSHOMessage:
+		return itch.AppendRegSHO(b, m), nil
+	case itch.MarketParticipantPositionMessage:
+		return itch.AppendMarketParticipantPosition(b, m), nil
+	case itch.MWCBDeclineMessage:
+		return itch.AppendMWCBDecline(b, m), nil
+	case itch.MWCBStatusMessage:
+		return itch.AppendMWCBStatus(b, m), nil
+	case itch.IPOQuotingMessage:
+		return itch.AppendIPOQuoting(b, m), nil
+	case itch.AddOrderMessage:
+		return itch.AppendAddOrder(b, m), nil
+	case itch.AddOrderMPIDMessage:
+		return itch.AppendAddOrderMPID(b, m), nil
+	case itch.OrderExecutedMessage:
+		return itch.AppendOrderExecuted(b, m), nil
+	case itch.OrderExecutedWithPriceMessage:
+		return itch.AppendOrderExecutedWithPrice(b, m), nil
+	case itch.OrderCancelMessage:
+		return itch.AppendOrderCancel(b, m), nil
+	case itch.OrderDeleteMessage:
+		return itch.AppendOrderDelete(b, m), nil
+	case itch.OrderReplaceMessage:
+		return itch.AppendOrderReplace(b, m), nil
+	case itch.TradeMessage:
+		return itch.AppendTrade(b, m), nil
+	case itch.CrossTradeMessage:
+		return itch.AppendCrossTrade(b, m), nil
+	case itch.BrokenTradeMessage:
+		return itch.AppendBrokenTrade(b, m), nil
+	case itch.NOIIMessage:
+		return itch.AppendNOII(b, m), nil
+	case itch.RPIIMessage:
+		return itch.AppendRPII(b, m), nil
+	default:
+		return b, fmt.Errorf("conformance: unsupported message %T", msg)
+	}
+}
+
+// Build encodes the cases into a length-prefixed ITCH file and returns it
+// with the expected parsed output
+func Build(cases []Case) ([]byte, Expected, error) {
+	var data []byte
+	expected := Expected{Records: make([]Record, 0, len(cases))}
+	for i, c := range cases {
+		msg, err := Encode(nil, c.Message)
+		if err != nil {
+			return nil, Expected{}, fmt.Errorf("case %d (%s): %w", i, c.Name, err)
+		}
+		r := NewRecord(c.Message)
+		r.Index = uint64(i)
+		r.Offset = int64(len(data))
+		r.Case = c.Name
+		expected.Records = append(expected.Records, r)
+		data = itch.AppendFrame(data, msg)
+	}
+	expected.Messages = len(cases)
+	expected.Bytes = int64(len(data))
+	return data, expected, nil
+}
+
+// WriteExpected writes the expected parsed output as indented JSON
+func WriteExpected(w io.Writer, expected Expected) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(expected)
+}
+
+// Corpus returns the standard pathological corpus: every message type, field
+// boundary values, zero and maximum timestamps, maximum-length and minimal
+// symbols and MPIDs, a chain of replaces, partial and full executions,
+// cancels and deletes, and broken trades.
+func Corpus() []Case {
+	maxStock := itch.StockField("ZZZZZZZZ")
+	shortStock := itch.StockField("A")
+	dotStock := itch.StockField("BRK.A")
+
+	return []Case{
+		// System and reference data
+		{"start of messages at timestamp 0", itch.SystemEventMessage{Type: 'S', EventCode: 'O'}},
+		{"start of system hours", itch.SystemEventMessage{Type: 'S', TrackingNumber: 1, Timestamp: 14400000000000, EventCode: 'S'}},
+		{"directory with 8-character symbol and maximum locate",
+			itch.StockDirectoryMessage{Type: 'R', StockLocate: MaxUint16, TrackingNumber: MaxUint16, Stock: maxStock, MarketCategory: 'Q',
+				FinancialStatusIndicator: 'N', RoundLotSize: 100, RoundLotsOnly: 'N', IssueClassification: 'C', IssueSubType: [2]byte{'Z', ' '},
+				Authenticity: 'P', ShortSaleThresholdIndicator: 'N', IPOFlag: 'N', LULDReferencePriceTier: '1', ETPFlag: 'N',
+				ETPLeverageFactor: 0, InverseIndicator: 'N'}},
+		{"directory with 1-character symbol and locate 1",
+			itch.StockDirectoryMessage{Type: 'R', StockLocate: 1, Stock: shortStock, MarketCategory: 'N', FinancialStatusIndicator: ' ',
+				RoundLotSize: 1, RoundLotsOnly: 'Y', IssueClassification: 'A', IssueSubType: [2]byte{'A', 'I'}, Authenticity: 'T',
+				ShortSaleThresholdIndicator: ' ', IPOFlag: ' ', LULDReferencePriceTier: ' ', ETPFlag: ' ', ETPLeverageFactor: MaxUint32, InverseIndicator: 'Y'}},
+		{"directory with punctuation in symbol",
+			itch.StockDirectoryMessage{Type: 'R', StockLocate: 2, Stock: dotStock, MarketCategory: 'N', FinancialStatusIndicator: 'D',
+				RoundLotSize: MaxUint32, RoundLotsOnly: 'N', IssueClassification: 'C', IssueSubType: [2]byte{' ', ' '}, Authenticity: 'P',
+				ShortSaleThresholdIndicator: 'Y', IPOFlag: 'Y', LULDReferencePriceTier: '2', ETPFlag: 'Y', ETPLeverageFactor: 3, InverseIndicator: 'N'}},
+		{"trading action halt", itch.StockTradingActionMessage{Type: 'H', StockLocate: 1, Timestamp: 1, Stock: shortStock, TradingState: 'H', Reserved: ' ', Reason: 'T'}},
+		{"trading action resume", itch.StockTradingActionMessage{Type: 'H', StockLocate: 1, Timestamp: 2, Stock: shortStock, TradingState: 'T', Reserved: ' ', Reason: ' '}},
+		{"Reg SHO restriction", itch.RegSHOMessage{Type: 'Y', StockLocate: MaxUint16, Timestamp: 3, Stock: maxStock, RegSHOAction: '1'}},
+		{"primary market maker with 4-character MPID",
+			itch.MarketParticipantPositionMessage{Type: 'L', StockLocate: 1, Timestamp: 4, MPID: itch.MPIDField("GSCO"), Stock: shortStock,
+				PrimaryMarketMaker: 'Y', MarketMakerMode: 'N', MarketParticipantState: 'A'}},
+		{"market maker with 1-character MPID",
+			itch.MarketParticipantPositionMessage{Type: 'L', StockLocate: 2, Timestamp: 4, MPID: itch.MPIDField("X"), Stock: dotStock,
+				PrimaryMarketMaker: 'N', MarketMakerMode: 'P', MarketParticipantState: 'S'}},
+		{"MWCB decline levels at maximum", itch.MWCBDeclineMessage{Type: 'V', Timestamp: 5, Level1: MaxUint64, Level2: 1, Level3: 0}},
+		{"MWCB level 1 breached", itch.MWCBStatusMessage{Type: 'W', Timestamp: 6, BreachedLevel: '1'}},
+		{"IPO quoting with maximum price",
+			itch.IPOQuotingMessage{Type: 'K', StockLocate: 2, Timestamp: 7, Stock: dotStock, IPOReleaseTime: 86399, IPOReleaseQualifier: 'A', IPOPrice: MaxUint32}},
+		{"IPO quoting canceled", itch.IPOQuotingMessage{Type: 'K', StockLocate: 2, Timestamp: 8, Stock: dotStock, IPOReleaseQualifier: 'C'}},
+
+		// Order lifecycle with boundary values
+		{"add order with zero timestamp and reference",
+			itch.AddOrderMessage{Type: 'A', StockLocate: 1, OrderReferenceNumber: 0, BuySellIndicator: 'B', Shares: 100, Stock: shortStock, Price: 1}},
+		{"add order with maximum reference, shares and price",
+			itch.AddOrderMessage{Type: 'A', StockLocate: MaxUint16, TrackingNumber: MaxUint16, Timestamp: MaxTimestamp, OrderReferenceNumber: MaxUint64,
+				BuySellIndicator: 'S', Shares: MaxUint32, Stock: maxStock, Price: MaxUint32}},
+		{"add order with MPID",
+			itch.AddOrderMPIDMessage{Type: 'F', StockLocate: 1, Timestamp: 34200000000000, OrderReferenceNumber: 10, BuySellIndicator: 'S', Shares: 500,
+				Stock: shortStock, Price: 1000000, Attribution: itch.MPIDField("GSCO")}},
+		{"add order with 1-character MPID",
+			itch.AddOrderMPIDMessage{Type: 'F', StockLocate: 2, Timestamp: 34200000000001, OrderReferenceNumber: 11, BuySellIndicator: 'B', Shares: 1,
+				Stock: dotStock, Price: 9999, Attribution: itch.MPIDField("X")}},
+		{"partial execution", itch.OrderExecutedMessage{Type: 'E', StockLocate: 1, Timestamp: 34200000000002, OrderReferenceNumber: 10, ExecutedShares: 100, MatchNumber: 1}},
+		{"execution with price, non-printable",
+			itch.OrderExecutedWithPriceMessage{Type: 'C', StockLocate: 1, Timestamp: 34200000000003, OrderReferenceNumber: 10, ExecutedShares: 100,
+				MatchNumber: 2, Printable: 'N', ExecutionPrice: 999900}},
+		{"partial cancel", itch.OrderCancelMessage{Type: 'X', StockLocate: 1, Timestamp: 34200000000004, OrderReferenceNumber: 10, CanceledShares: 50}},
+
+		// Replace chain: 10 -> 12 -> 13 -> MaxUint64-1, then executed and deleted
+		{"replace chain step 1", itch.OrderReplaceMessage{Type: 'U', StockLocate: 1, Timestamp: 34200000000005,
+			OriginalOrderReferenceNumber: 10, NewOrderReferenceNumber: 12, Shares: 250, Price: 1000100}},
+		{"replace chain step 2", itch.OrderReplaceMessage{Type: 'U', StockLocate: 1, Timestamp: 34200000000006,
+			OriginalOrderReferenceNumber: 12, NewOrderReferenceNumber: 13, Shares: 250, Price: 1000200}},
+		{"replace chain step 3 to maximum-1 reference with zero shares", itch.OrderReplaceMessage{Type: 'U', StockLocate: 1, Timestamp: 34200000000007,
+			OriginalOrderReferenceNumber: 13, NewOrderReferenceNumber: MaxUint64 - 1, Shares: 0, Price: MaxUint32}},
+		{"execution of replaced order with maximum match number",
+			itch.OrderExecutedMessage{Type: 'E', StockLocate: 1, Timestamp: 34200000000008, OrderReferenceNumber: MaxUint64 - 1, ExecutedShares: 0, MatchNumber: MaxUint64}},
+		{"delete of replaced order", itch.OrderDeleteMessage{Type: 'D', StockLocate: 1, Timestamp: 34200000000009, OrderReferenceNumber: MaxUint64 - 1}},
+		{"full cancel of MPID order", itch.OrderCancelMessage{Type: 'X', StockLocate: 2, Timestamp: 34200000000010, OrderReferenceNumber: 11, CanceledShares: MaxUint32}},
+		{"delete of order with reference 0", itch.OrderDeleteMessage{Type: 'D', StockLocate: 1, Timestamp: 34200000000011, OrderReferenceNumber: 0}},
+
+		// Trades, crosses and breaks
+		{"non-displayable trade with zero order reference",
+			itch.TradeMessage{Type: 'P', StockLocate: 1, Timestamp: 34200000000012, BuySellIndicator: 'B', Shares: 300, Stock: shortStock, Price: 1000000, MatchNumber: 3}},
+		{"non-displayable trade with maximum values",
+			itch.TradeMessage{Type: 'P', StockLocate: MaxUint16, Timestamp: MaxTimestamp, OrderReferenceNumber: MaxUint64, BuySellIndicator: 'S', Shares: MaxUint32,
+				Stock: maxStock, Price: MaxUint32, MatchNumber: 4}},
+		{"opening cross", itch.CrossTradeMessage{Type: 'Q', StockLocate: 1, Timestamp: 34200000000013, Shares: 1000000, Stock: shortStock, CrossPrice: 1000000, MatchNumber: 5, CrossType: 'O'}},
+		{"cross with zero shares", itch.CrossTradeMessage{Type: 'Q', StockLocate: 2, Timestamp: 34200000000014, Shares: 0, Stock: dotStock, CrossPrice: 0, MatchNumber: 6, CrossType: 'H'}},
+		{"closing cross with maximum shares", itch.CrossTradeMessage{Type: 'Q', StockLocate: MaxUint16, Timestamp: 57600000000000, Shares: MaxUint64, Stock: maxStock,
+			CrossPrice: MaxUint32, MatchNumber: 7, CrossType: 'C'}},
+		{"broken trade", itch.BrokenTradeMessage{Type: 'B', StockLocate: 1, Timestamp: 34200000000015, MatchNumber: 3}},
+		{"broken execution with match number 1", itch.BrokenTradeMessage{Type: 'B', StockLocate: 1, Timestamp: 34200000000016, MatchNumber: 1}},
+		{"break of unknown match number", itch.BrokenTradeMessage{Type: 'B', StockLocate: 1, Timestamp: 34200000000017, MatchNumber: MaxUint64}},
+
+		// Imbalance and retail interest
+		{"NOII with buy imbalance",
+			itch.NOIIMessage{Type: 'I', StockLocate: 1, Timestamp: 34190000000000, PairedShares: 5000, ImbalanceShares: 1000, ImbalanceDirection: 'B',
+				Stock: shortStock, FarPrice: 1000000, NearPrice: 1000100, CurrentRefPrice: 1000050, CrossType: 'O', PriceVariationIndicator: 'L'}},
+		{"NOII with no imbalance and zero prices",
+			itch.NOIIMessage{Type: 'I', StockLocate: 2, Timestamp: 57590000000000, ImbalanceDirection: 'N', Stock: dotStock, CrossType: 'C', PriceVariationIndicator: ' '}},
+		{"NOII with maximum values",
+			itch.NOIIMessage{Type: 'I', StockLocate: MaxUint16, Timestamp: MaxTimestamp, PairedShares: MaxUint64, ImbalanceShares: MaxUint64, ImbalanceDirection: 'S',
+				Stock: maxStock, FarPrice: MaxUint32, NearPrice: MaxUint32, CurrentRefPrice: MaxUint32, CrossType: 'H', PriceVariationIndicator: 'C'}},
+		{"RPII on both sides", itch.RPIIMessage{Type: 'N', StockLocate: 1, Timestamp: 34200000000018, Stock: shortStock, InterestFlag: 'A'}},
+		{"RPII cleared", itch.RPIIMessage{Type: 'N', StockLocate: 1, Timestamp: 34200000000019, Stock: shortStock, InterestFlag: 'N'}},
+
+		{"end of messages at maximum timestamp", itch.SystemEventMessage{Type: 'S', Timestamp: MaxTimestamp, EventCode: 'C'}},
+	}
+}
@@ -0,0 +1,63 @@
+package conformance
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCorpus_RoundTrip(t *testing.T) {
+	data, expected, err := Build(Corpus())
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	// Every ITCH 5.0 message type is covered
+	types := make(map[string]bool)
+	for _, r := range expected.Records {
+		types[r.Type] = true
+	}
+	for _, msgType := range "SRHYLVWKAFECXDUPQBIN" {
+		if !types[string(msgType)] {
+			t.Errorf("Expected message type %c in corpus", msgType)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteExpected(&buf, expected); err != nil {
+		t.Fatalf("WriteExpected: %v", err)
+	}
+	golden, err := ReadExpected(&buf)
+	if err != nil {
+		t.Fatalf("ReadExpected: %v", err)
+	}
+
+	parsed, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(parsed) != len(golden) || len(parsed) != expected.Messages {
+		t.Fatalf("Expected %d records, got %d parsed and %d decoded", expected.Messages, len(parsed), len(golden))
+	}
+	for i := range golden {
+		if !golden[i].Equal(parsed[i]) {
+			t.Errorf("Record %d (%s): expected %+v, got %+v", i, golden[i].Case, golden[i], parsed[i])
+		}
+	}
+}
+
+func TestRecord_Equal(t *testing.T) {
+	_, expected, err := Build(Corpus())
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	a := expected.Records[15]
+	b := NewRecord(Corpus()[15].Message)
+	b.Index, b.Offset = a.Index, a.Offset
+	if !a.Equal(b) {
+		t.Errorf("Expected %+v to equal %+v", a, b)
+	}
+	b.Fields["Shares"] = uint64(1)
+	if a.Equal(b) {
+		t.Errorf("Expected records with different shares to differ")
+	}
+}
@@ -0,0 +1,209 @@
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/tienpsm/go-trader/itch"
+)
+
+// Record is the expected (or actual) parsed form of one message
+type Record struct {
+	// Index is the position of the message in the stream
+	Index uint64 `json:"index"`
+	// Offset is the byte offset of the message frame in the file
+	Offset int64 `json:"offset"`
+	// Case describes what the message exercises (expected records only)
+	Case string `json:"case,omitempty"`
+	// Type is the message type character
+	Type string `json:"type"`
+	// Fields holds every message field other than Type, keyed by the Go
+	// field name. Single bytes and byte arrays are strings with padding
+	// kept, integers are numbers.
+	Fields map[string]any `json:"fields"`
+}
+
+// NewRecord converts a parsed message struct into a record
+func NewRecord(msg any) Record {
+	v := reflect.ValueOf(msg)
+	t := v.Type()
+	r := Record{Fields: make(map[string]any, t.NumField())}
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Name
+		f := v.Field(i)
+		if name == "Type" {
+			r.Type = string(rune(f.Uint()))
+			continue
+		}
+		switch {
+		case f.Kind() == reflect.Uint8:
+			r.Fields[name] = string(rune(f.Uint()))
+		case f.Kind() == reflect.Array:
+			b := make([]byte, f.Len())
+			reflect.Copy(reflect.ValueOf(b), f)
+			r.Fields[name] = string(b)
+		default:
+			r.Fields[name] = f.Uint()
+		}
+	}
+	return r
+}
+
+// Equal returns true if r and other describe the same message at the same
+// position. Case is ignored, and numbers compare by their decimal form so
+// that decoded JSON records match parsed ones.
+func (r Record) Equal(other Record) bool {
+	if r.Index != other.Index || r.Offset != other.Offset || r.Type != other.Type || len(r.Fields) != len(other.Fields) {
+		return false
+	}
+	for name, value := range r.Fields {
+		if fmt.Sprint(value) != fmt.Sprint(other.Fields[name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Recorder is an itch.Handler that records every parsed message, so that a
+// parser can be checked against the expected records of a corpus
+type Recorder struct {
+	// offset is the byte offset of the frame being parsed
+	offset int64
+
+	// Records holds the parsed messages in stream order
+	Records []Record
+}
+
+// add records a parsed message at the current stream position
+func (h *Recorder) add(msg any) error {
+	r := NewRecord(msg)
+	r.Index = uint64(len(h.Records))
+	r.Offset = h.offset
+	h.Records = append(h.Records, r)
+	return nil
+}
+
+// OnSystemEvent and the other message handlers record the message
+func (h *Recorder) OnSystemEvent(msg itch.SystemEventMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnStockDirectory(msg itch.StockDirectoryMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnStockTradingAction(msg itch.StockTradingActionMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnRegSHO(msg itch.RegSHOMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnMarketParticipantPosition(msg itch.MarketParticipantPositionMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnMWCBDecline(msg itch.MWCBDeclineMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnMWCBStatus(msg itch.MWCBStatusMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnIPOQuoting(msg itch.IPOQuotingMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnAddOrder(msg itch.AddOrderMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnAddOrderMPID(msg itch.AddOrderMPIDMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnOrderExecuted(msg itch.OrderExecutedMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnOrderExecutedWithPrice(msg itch.OrderExecutedWithPriceMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnOrderCancel(msg itch.OrderCancelMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnOrderDelete(msg itch.OrderDeleteMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnOrderReplace(msg itch.OrderReplaceMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnTrade(msg itch.TradeMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnCrossTrade(msg itch.CrossTradeMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnBrokenTrade(msg itch.BrokenTradeMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnNOII(msg itch.NOIIMessage) error {
+	return h.add(msg)
+}
+
+func (h *Recorder) OnRPII(msg itch.RPIIMessage) error {
+	return h.add(msg)
+}
+
+// OnUnknownMessage fails, as every message of a corpus has a known type
+func (h *Recorder) OnUnknownMessage(msgType byte, data []byte) error {
+	return fmt.Errorf("conformance: unknown message type %q", msgType)
+}
+
+// Parse parses a length-prefixed ITCH stream with the package parser and
+// returns its records. Frames must contain exactly one message.
+func Parse(r io.Reader) ([]Record, error) {
+	h := &Recorder{}
+	parser := itch.NewParser(h)
+	frames := itch.NewFrameReader(r)
+	for {
+		h.offset = frames.Offset()
+		msg, err := frames.Next()
+		if err == io.EOF {
+			return h.Records, nil
+		}
+		if err != nil {
+			return h.Records, err
+		}
+		n, err := parser.Parse(msg)
+		if err != nil {
+			return h.Records, err
+		}
+		if n != len(msg) {
+			return h.Records, fmt.Errorf("conformance: frame at offset %d has %d bytes, message has %d", h.offset, len(msg), n)
+		}
+	}
+}
+
+// ReadExpected decodes the JSON document written by WriteExpected. Numbers
+// are kept as json.Number, so 64-bit values compare exactly with Equal.
+func ReadExpected(r io.Reader) ([]Record, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var doc Expected
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc.Records, nil
+}
@@ -0,0 +1,314 @@
+package itch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// FeedDiffKind identifies the kind of a FeedDiff
+type FeedDiffKind uint8
+
+const (
+	// FeedDiffMissing is a message of one capture missing from the other
+	FeedDiffMissing FeedDiffKind = iota + 1
+	// FeedDiffDuplicate is a message repeated more often in one capture than
+	// in the other
+	FeedDiffDuplicate
+	// FeedDiffMismatch is a message whose content differs between the
+	// captures
+	FeedDiffMismatch
+)
+
+// String returns the string representation of a FeedDiffKind
+func (k FeedDiffKind) String() string {
+	switch k {
+	case FeedDiffMissing:
+		return "MISSING"
+	case FeedDiffDuplicate:
+		return "DUPLICATE"
+	case FeedDiffMismatch:
+		return "MISMATCH"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// FeedDiff is a difference between two captures of the same feed, such as
+// the primary and backup captures of a session
+type FeedDiff struct {
+	Kind FeedDiffKind
+	// Timestamp is the timestamp of the message in nanoseconds since midnight
+	Timestamp uint64
+	// Type, StockLocate and TrackingNumber identify the message
+	Type           byte
+	StockLocate    uint16
+	TrackingNumber uint16
+	// Sequences are the 1-based message numbers in the first and second
+	// capture, 0 in the capture missing the message or not holding the
+	// duplicate
+	Sequences [2]uint64
+	// Offset is the first differing byte of a mismatch
+	Offset int
+}
+
+// String returns the string representation of a FeedDiff
+func (d FeedDiff) String() string {
+	s := fmt.Sprintf("%s %c locate %d tracking %d at %d", d.Kind, d.Type, d.StockLocate, d.TrackingNumber, d.Timestamp)
+	switch {
+	case d.Kind == FeedDiffMismatch:
+		return s + fmt.Sprintf(": #%d and #%d differ at byte %d", d.Sequences[0], d.Sequences[1], d.Offset)
+	case d.Sequences[0] != 0 && d.Kind == FeedDiffMissing:
+		return s + fmt.Sprintf(": #%d only in the first capture", d.Sequences[0])
+	case d.Kind == FeedDiffMissing:
+		return s + fmt.Sprintf(": #%d only in the second capture", d.Sequences[1])
+	case d.Sequences[0] != 0:
+		return s + fmt.Sprintf(": #%d repeated in the first capture", d.Sequences[0])
+	default:
+		return s + fmt.Sprintf(": #%d repeated in the second capture", d.Sequences[1])
+	}
+}
+
+// FeedDiffStats counts the messages and differences of two captures
+type FeedDiffStats struct {
+	// Messages is the number of messages of each capture
+	Messages [2]uint64
+	// Missing is the number of messages missing from each capture
+	Missing [2]uint64
+	// Duplicates is the number of extra copies of messages in each capture
+	Duplicates [2]uint64
+	// Mismatches is the number of messages differing between the captures
+	Mismatches uint64
+}
+
+// Identical returns true if the captures hold the same messages
+func (s FeedDiffStats) Identical() bool {
+	return s.Missing == [2]uint64{} && s.Duplicates == [2]uint64{} && s.Mismatches == 0
+}
+
+// diffMessage is a message of a capture being compared
+type diffMessage struct {
+	sequence uint64
+	data     []byte
+}
+
+// diffStream reads the messages of a capture one timestamp at a time
+type diffStream struct {
+	frames   *FrameReader
+	sequence uint64
+	// next is the first message of the next timestamp, nil at the end
+	next []byte
+	done bool
+}
+
+// peek returns the timestamp of the next message, false at the end
+func (s *diffStream) peek() (uint64, bool, error) {
+	if s.next == nil && !s.done {
+		msg, err := s.frames.Next()
+		if err == io.EOF {
+			s.done = true
+		} else if err != nil {
+			return 0, false, fmt.Errorf("message %d: %w", s.sequence+1, err)
+		} else {
+			s.next = append([]byte(nil), msg...)
+		}
+	}
+	if s.next == nil {
+		return 0, false, nil
+	}
+	return messageTimestamp(s.next), true, nil
+}
+
+// group reads the messages of the next timestamp
+func (s *diffStream) group(timestamp uint64) ([]diffMessage, error) {
+	var group []diffMessage
+	for {
+		ts, ok, err := s.peek()
+		if err != nil || !ok || ts != timestamp {
+			return group, err
+		}
+		s.sequence++
+		group = append(group, diffMessage{sequence: s.sequence, data: s.next})
+		s.next = nil
+	}
+}
+
+// DiffFeeds compares two length-prefixed captures of the same feed message by
+// message, calling onDiff with every difference, and returns the counts.
+// Messages are matched by content among those with the same timestamp, so
+// captures with timestamps in non-decreasing order, as exchange captures
+// are, can be compared in a single pass whatever their length. The first
+// copy of a message found in one capture only is missing from the other, and
+// further copies are duplicates. A message missing from both captures with
+// the same type, stock locate and tracking number at the same time is
+// reported as one mismatch. An error returned by onDiff stops the
+// comparison.
+func DiffFeeds(first, second io.Reader, onDiff func(d FeedDiff) error) (FeedDiffStats, error) {
+	streams := [2]*diffStream{{frames: NewFrameReader(first)}, {frames: NewFrameReader(second)}}
+	var stats FeedDiffStats
+	for {
+		var timestamps [2]uint64
+		var ok [2]bool
+		for i, s := range streams {
+			var err error
+			if timestamps[i], ok[i], err = s.peek(); err != nil {
+				return stats, fmt.Errorf("capture %d: %w", i+1, err)
+			}
+		}
+		if !ok[0] && !ok[1] {
+			return stats, nil
+		}
+		timestamp := timestamps[0]
+		if !ok[0] || ok[1] && timestamps[1] < timestamp {
+			timestamp = timestamps[1]
+		}
+
+		var groups [2][]diffMessage
+		for i, s := range streams {
+			if !ok[i] || timestamps[i] != timestamp {
+				continue
+			}
+			var err error
+			if groups[i], err = s.group(timestamp); err != nil {
+				return stats, fmt.Errorf("capture %d: %w", i+1, err)
+			}
+			stats.Messages[i] += uint64(len(groups[i]))
+		}
+		for _, d := range diffGroups(groups) {
+			switch d.Kind {
+			case FeedDiffMissing:
+				stats.Missing[missingFrom(d)]++
+			case FeedDiffDuplicate:
+				stats.Duplicates[missingFrom(d)^1]++
+			case FeedDiffMismatch:
+				stats.Mismatches++
+			}
+			if onDiff != nil {
+				if err := onDiff(d); err != nil {
+					return stats, err
+				}
+			}
+		}
+	}
+}
+
+// missingFrom returns the capture without a sequence in d
+func missingFrom(d FeedDiff) int {
+	if d.Sequences[0] == 0 {
+		return 0
+	}
+	return 1
+}
+
+// diffGroups compares the messages of the captures with the same timestamp
+// and returns the differences in sequence order
+func diffGroups(groups [2][]diffMessage) []FeedDiff {
+	var copies [2]map[string][]diffMessage
+	for i, group := range groups {
+		copies[i] = make(map[string][]diffMessage, len(group))
+		for _, m := range group {
+			copies[i][string(m.data)] = append(copies[i][string(m.data)], m)
+		}
+	}
+
+	var diffs []FeedDiff
+	var unmatched [2][]diffMessage
+	for i, group := range groups {
+		other := copies[i^1]
+		for _, m := range group {
+			same := copies[i][string(m.data)]
+			if same[0].sequence != m.sequence {
+				continue
+			}
+			// Each message is handled with its copies, from its first one
+			common := min(len(same), len(other[string(m.data)]))
+			extra := same[common:]
+			if common == 0 {
+				unmatched[i] = append(unmatched[i], extra[0])
+				extra = extra[1:]
+			}
+			for _, dup := range extra {
+				d := newFeedDiff(FeedDiffDuplicate, dup.data)
+				d.Sequences[i] = dup.sequence
+				diffs = append(diffs, d)
+			}
+		}
+	}
+
+	paired := make([]bool, len(unmatched[1]))
+	for _, a := range unmatched[0] {
+		j := -1
+		for k, b := range unmatched[1] {
+			if !paired[k] && sameMessage(a.data, b.data) {
+				j = k
+				break
+			}
+		}
+		if j < 0 {
+			d := newFeedDiff(FeedDiffMissing, a.data)
+			d.Sequences[0] = a.sequence
+			diffs = append(diffs, d)
+			continue
+		}
+		paired[j] = true
+		b := unmatched[1][j]
+		d := newFeedDiff(FeedDiffMismatch, a.data)
+		d.Sequences = [2]uint64{a.sequence, b.sequence}
+		d.Offset = firstDifference(a.data, b.data)
+		diffs = append(diffs, d)
+	}
+	for k, b := range unmatched[1] {
+		if !paired[k] {
+			d := newFeedDiff(FeedDiffMissing, b.data)
+			d.Sequences[1] = b.sequence
+			diffs = append(diffs, d)
+		}
+	}
+
+	sort.SliceStable(diffs, func(i, j int) bool {
+		return diffSequence(diffs[i]) < diffSequence(diffs[j])
+	})
+	return diffs
+}
+
+// diffSequence returns the sequence a difference is ordered by, of the first
+// capture if it has one
+func diffSequence(d FeedDiff) uint64 {
+	if d.Sequences[0] != 0 {
+		return d.Sequences[0]
+	}
+	return d.Sequences[1]
+}
+
+// newFeedDiff creates a difference identified by the header of msg
+func newFeedDiff(kind FeedDiffKind, msg []byte) FeedDiff {
+	d := FeedDiff{Kind: kind, Timestamp: messageTimestamp(msg)}
+	if len(msg) > 0 {
+		d.Type = msg[0]
+	}
+	if len(msg) >= 5 {
+		d.StockLocate = binary.BigEndian.Uint16(msg[1:3])
+		d.TrackingNumber = binary.BigEndian.Uint16(msg[3:5])
+	}
+	return d
+}
+
+// sameMessage returns true if a and b have the same length, type, stock
+// locate and tracking number
+func sameMessage(a, b []byte) bool {
+	n := min(len(a), 5)
+	return len(a) == len(b) && string(a[:n]) == string(b[:n])
+}
+
+// firstDifference returns the offset of the first byte differing between a
+// and b
+func firstDifference(a, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
@@ -0,0 +1,77 @@
+package itch
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// capture frames messages into a BinaryFILE capture
+func capture(msgs ...[]byte) *bytes.Reader {
+	var b []byte
+	for _, msg := range msgs {
+		b = append(b, frame(msg)...)
+	}
+	return bytes.NewReader(b)
+}
+
+func TestDiffFeeds(t *testing.T) {
+	add := func(ref uint64, shares uint32, timestamp uint64) []byte {
+		return AppendAddOrder(nil, AddOrderMessage{StockLocate: 1, TrackingNumber: uint16(ref), Timestamp: timestamp,
+			OrderReferenceNumber: ref, BuySellIndicator: 'B', Shares: shares, Stock: StockField("AAPL"), Price: 1000})
+	}
+	del := func(ref uint64, timestamp uint64) []byte {
+		return AppendOrderDelete(nil, OrderDeleteMessage{StockLocate: 1, TrackingNumber: uint16(ref), Timestamp: timestamp, OrderReferenceNumber: ref})
+	}
+
+	first := capture(add(1, 100, 1), add(2, 100, 1), del(1, 2), del(2, 3))
+	// Order 2 with other shares, the delete of order 1 repeated, the delete
+	// of order 2 lost and a message the first capture lacks
+	second := capture(add(1, 100, 1), add(2, 200, 1), del(1, 2), del(1, 2), del(3, 4))
+
+	var diffs []FeedDiff
+	stats, err := DiffFeeds(first, second, func(d FeedDiff) error {
+		diffs = append(diffs, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DiffFeeds: %v", err)
+	}
+
+	want := []FeedDiff{
+		{Kind: FeedDiffMismatch, Timestamp: 1, Type: MessageTypeAddOrder, StockLocate: 1, TrackingNumber: 2, Sequences: [2]uint64{2, 2}, Offset: 23},
+		{Kind: FeedDiffDuplicate, Timestamp: 2, Type: MessageTypeOrderDelete, StockLocate: 1, TrackingNumber: 1, Sequences: [2]uint64{0, 4}},
+		{Kind: FeedDiffMissing, Timestamp: 3, Type: MessageTypeOrderDelete, StockLocate: 1, TrackingNumber: 2, Sequences: [2]uint64{4, 0}},
+		{Kind: FeedDiffMissing, Timestamp: 4, Type: MessageTypeOrderDelete, StockLocate: 1, TrackingNumber: 3, Sequences: [2]uint64{0, 5}},
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("Expected %d differences, got %+v", len(want), diffs)
+	}
+	for i := range want {
+		if diffs[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want[i], diffs[i])
+		}
+	}
+	wantStats := FeedDiffStats{Messages: [2]uint64{4, 5}, Missing: [2]uint64{1, 1}, Duplicates: [2]uint64{0, 1}, Mismatches: 1}
+	if stats != wantStats || stats.Identical() {
+		t.Errorf("Expected %+v, got %+v", wantStats, stats)
+	}
+}
+
+func TestDiffFeeds_Identical(t *testing.T) {
+	msg := AppendOrderDelete(nil, OrderDeleteMessage{StockLocate: 1, Timestamp: 5, OrderReferenceNumber: 1})
+	stats, err := DiffFeeds(capture(msg, msg), capture(msg, msg), nil)
+	if err != nil || !stats.Identical() || stats.Messages != [2]uint64{2, 2} {
+		t.Errorf("Expected identical captures, got %+v, %v", stats, err)
+	}
+
+	// A truncated capture fails, and so does onDiff
+	truncated := bytes.NewReader(frame(msg)[:5])
+	if _, err := DiffFeeds(capture(msg), truncated, nil); err == nil {
+		t.Error("Expected an error for a truncated capture")
+	}
+	stop := errors.New("stop")
+	if _, err := DiffFeeds(capture(msg), capture(), func(d FeedDiff) error { return stop }); err != stop {
+		t.Errorf("Expected the onDiff error, got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package itch
+
+import (
+	"sort"
+	"strings"
+)
+
+// StockInfo is the reference data of a stock from its Stock Directory message
+type StockInfo struct {
+	// StockLocate is the locate code of the stock
+	StockLocate uint16
+	// Stock is the trimmed stock symbol
+	Stock string
+	// Listed is set once the Stock Directory message of the stock is seen.
+	// Stocks only known from their orders and trades have no reference data.
+	Listed bool
+
+	// MarketCategory is the listing market, such as 'Q' for the Global Select
+	// Market or 'N' for the NYSE
+	MarketCategory           byte
+	FinancialStatusIndicator byte
+	// RoundLotSize is the number of shares of a round lot
+	RoundLotSize uint32
+	// RoundLotsOnly is set when only round lots are accepted
+	RoundLotsOnly               bool
+	IssueClassification         byte
+	IssueSubType                string
+	Authenticity                byte
+	ShortSaleThresholdIndicator byte
+	IPOFlag                     byte
+	LULDReferencePriceTier      byte
+
+	// ETPFlag is 'Y' for exchange traded products, ETPLeverageFactor their
+	// leverage and Inverse set for inverse products
+	ETPFlag           byte
+	ETPLeverageFactor uint32
+	Inverse           bool
+}
+
+// IsETP checks if the stock is an exchange traded product
+func (s StockInfo) IsETP() bool {
+	return s.ETPFlag == 'Y'
+}
+
+// IsTest checks if the stock is a test issue
+func (s StockInfo) IsTest() bool {
+	return s.Authenticity == 'T'
+}
+
+// SymbolDirectory is the registry of the stocks of a feed, built from Stock
+// Directory messages. Handlers resolving locate codes share one directory
+// through SetDirectory instead of each keeping its own map; stocks whose
+// directory message was missed are registered from their first order or trade.
+//
+// SymbolDirectory is a Handler, so it can also be chained on its own.
+type SymbolDirectory struct {
+	DefaultHandler
+
+	stocks  map[uint16]*StockInfo
+	locates map[string]uint16
+	// names interns the stock fields of the messages
+	names *StockTable
+}
+
+// NewSymbolDirectory creates an empty symbol directory
+func NewSymbolDirectory() *SymbolDirectory {
+	return &SymbolDirectory{
+		stocks:  make(map[uint16]*StockInfo),
+		locates: make(map[string]uint16),
+		names:   NewStockTable(),
+	}
+}
+
+// OnStockDirectory registers the stock
+func (d *SymbolDirectory) OnStockDirectory(msg StockDirectoryMessage) error {
+	d.Add(msg)
+	return nil
+}
+
+// Add registers the stock of a Stock Directory message, replacing the
+// reference data of its locate code, and returns it
+func (d *SymbolDirectory) Add(msg StockDirectoryMessage) StockInfo {
+	info := &StockInfo{
+		StockLocate:                 msg.StockLocate,
+		Stock:                       d.names.Name(msg.Stock),
+		Listed:                      true,
+		MarketCategory:              msg.MarketCategory,
+		FinancialStatusIndicator:    msg.FinancialStatusIndicator,
+		RoundLotSize:                msg.RoundLotSize,
+		RoundLotsOnly:               msg.RoundLotsOnly == 'Y',
+		IssueClassification:         msg.IssueClassification,
+		IssueSubType:                trimSubType(msg.IssueSubType),
+		Authenticity:                msg.Authenticity,
+		ShortSaleThresholdIndicator: msg.ShortSaleThresholdIndicator,
+		IPOFlag:                     msg.IPOFlag,
+		LULDReferencePriceTier:      msg.LULDReferencePriceTier,
+		ETPFlag:                     msg.ETPFlag,
+		ETPLeverageFactor:           msg.ETPLeverageFactor,
+		Inverse:                     msg.InverseIndicator == 'Y',
+	}
+	d.store(info)
+	return *info
+}
+
+// Register remembers the symbol of a locate code not in the directory, such
+// as a stock whose directory message was missed
+func (d *SymbolDirectory) Register(locate uint16, stock StockSymbol) {
+	if _, ok := d.stocks[locate]; !ok {
+		d.store(&StockInfo{StockLocate: locate, Stock: d.names.Name(stock)})
+	}
+}
+
+// Name returns the trimmed symbol of a stock field, interned so that
+// resolving the field of every message does not allocate
+func (d *SymbolDirectory) Name(stock StockSymbol) string {
+	return d.names.Name(stock)
+}
+
+// Names returns the table interning the stock fields seen by the directory
+func (d *SymbolDirectory) Names() *StockTable {
+	return d.names
+}
+
+// store indexes a stock by locate code and symbol
+func (d *SymbolDirectory) store(info *StockInfo) {
+	if previous, ok := d.stocks[info.StockLocate]; ok && d.locates[previous.Stock] == info.StockLocate {
+		delete(d.locates, previous.Stock)
+	}
+	d.stocks[info.StockLocate] = info
+	d.locates[info.Stock] = info.StockLocate
+}
+
+// ByLocate returns the stock of a locate code
+func (d *SymbolDirectory) ByLocate(locate uint16) (StockInfo, bool) {
+	info, ok := d.stocks[locate]
+	if !ok {
+		return StockInfo{}, false
+	}
+	return *info, true
+}
+
+// BySymbol returns the stock of a trimmed stock symbol
+func (d *SymbolDirectory) BySymbol(stock string) (StockInfo, bool) {
+	locate, ok := d.locates[stock]
+	if !ok {
+		return StockInfo{}, false
+	}
+	return *d.stocks[locate], true
+}
+
+// Stock returns the stock symbol of a locate code, or "" if unknown
+func (d *SymbolDirectory) Stock(locate uint16) string {
+	if info, ok := d.stocks[locate]; ok {
+		return info.Stock
+	}
+	return ""
+}
+
+// Locate returns the locate code of a stock symbol
+func (d *SymbolDirectory) Locate(stock string) (uint16, bool) {
+	locate, ok := d.locates[stock]
+	return locate, ok
+}
+
+// Len returns the number of stocks
+func (d *SymbolDirectory) Len() int {
+	return len(d.stocks)
+}
+
+// Stocks returns every stock ordered by locate code
+func (d *SymbolDirectory) Stocks() []StockInfo {
+	stocks := make([]StockInfo, 0, len(d.stocks))
+	for _, info := range d.stocks {
+		stocks = append(stocks, *info)
+	}
+	sort.Slice(stocks, func(i, j int) bool { return stocks[i].StockLocate < stocks[j].StockLocate })
+	return stocks
+}
+
+// trimSubType converts a space-padded ITCH issue sub-type into a string
+func trimSubType(subType [2]byte) string {
+	return strings.TrimRight(string(subType[:]), " ")
+}
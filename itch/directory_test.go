@@ -0,0 +1,92 @@
+package itch
+
+import "testing"
+
+func TestSymbolDirectory_Lookups(t *testing.T) {
+	d := NewSymbolDirectory()
+	d.OnStockDirectory(StockDirectoryMessage{StockLocate: 1, Stock: stockField("AAPL"), MarketCategory: 'Q',
+		RoundLotSize: 100, RoundLotsOnly: 'N', IssueSubType: [2]byte{'Z', ' '}, Authenticity: 'P', ETPFlag: 'N'})
+	d.OnStockDirectory(StockDirectoryMessage{StockLocate: 2, Stock: stockField("SQQQ"), MarketCategory: 'G',
+		RoundLotSize: 100, RoundLotsOnly: 'Y', Authenticity: 'P', ETPFlag: 'Y', ETPLeverageFactor: 3, InverseIndicator: 'Y'})
+	d.Register(3, stockField("ZVZZT"))
+
+	info, ok := d.ByLocate(1)
+	if !ok || info.Stock != "AAPL" || !info.Listed || info.MarketCategory != 'Q' || info.RoundLotSize != 100 || info.RoundLotsOnly || info.IssueSubType != "Z" {
+		t.Errorf("Expected AAPL on Q with lots of 100, got %+v", info)
+	}
+	if info.IsETP() || info.IsTest() {
+		t.Errorf("Expected AAPL to be a live stock, got %+v", info)
+	}
+
+	info, ok = d.BySymbol("SQQQ")
+	if !ok || info.StockLocate != 2 || !info.IsETP() || info.ETPLeverageFactor != 3 || !info.Inverse || !info.RoundLotsOnly {
+		t.Errorf("Expected SQQQ as a 3x inverse ETP, got %+v", info)
+	}
+
+	// Stocks missing from the directory have no reference data
+	info, ok = d.BySymbol("ZVZZT")
+	if !ok || info.StockLocate != 3 || info.Listed || info.RoundLotSize != 0 {
+		t.Errorf("Expected ZVZZT registered without reference data, got %+v", info)
+	}
+	if _, ok := d.BySymbol("MSFT"); ok {
+		t.Error("Expected MSFT to be unknown")
+	}
+	if d.Stock(9) != "" {
+		t.Errorf("Expected no stock for locate 9, got %q", d.Stock(9))
+	}
+	if d.Len() != 3 {
+		t.Errorf("Expected 3 stocks, got %d", d.Len())
+	}
+}
+
+func TestSymbolDirectory_Replace(t *testing.T) {
+	d := NewSymbolDirectory()
+	d.Register(1, stockField("AAPL"))
+	d.Register(1, stockField("MSFT"))
+	if d.Stock(1) != "AAPL" {
+		t.Errorf("Expected the first registration to be kept, got %q", d.Stock(1))
+	}
+
+	// A directory message replaces the stock of its locate code
+	d.Add(StockDirectoryMessage{StockLocate: 1, Stock: stockField("MSFT"), RoundLotSize: 100})
+	if _, ok := d.Locate("AAPL"); ok {
+		t.Error("Expected AAPL to be replaced")
+	}
+	if locate, ok := d.Locate("MSFT"); !ok || locate != 1 {
+		t.Errorf("Expected MSFT on locate 1, got %d", locate)
+	}
+	d.Register(1, stockField("AAPL"))
+	if info, _ := d.ByLocate(1); info.Stock != "MSFT" || !info.Listed {
+		t.Errorf("Expected the directory entry to be kept, got %+v", info)
+	}
+
+	d.Register(0, stockField("ZVZZT"))
+	stocks := d.Stocks()
+	if len(stocks) != 2 || stocks[0].Stock != "ZVZZT" || stocks[1].Stock != "MSFT" {
+		t.Errorf("Expected stocks by locate code, got %+v", stocks)
+	}
+}
+
+func TestSymbolDirectory_Shared(t *testing.T) {
+	d := NewSymbolDirectory()
+	book := NewBookBuilder()
+	book.SetDirectory(d)
+	auction := NewAuctionHandler()
+	auction.SetDirectory(d)
+
+	// Only the book builder sees the directory message
+	stock := stockField("AAPL")
+	book.OnStockDirectory(StockDirectoryMessage{StockLocate: 1, Stock: stock, RoundLotSize: 100})
+	book.OnAddOrder(AddOrderMessage{StockLocate: 1, OrderReferenceNumber: 1, BuySellIndicator: 'B', Shares: 100, Stock: stock, Price: 1000000})
+	auction.OnCrossTrade(CrossTradeMessage{StockLocate: 1, Shares: 100, CrossPrice: 1000000, MatchNumber: 1, CrossType: 'O'})
+
+	if book.Directory() != d || book.Book("AAPL") == nil {
+		t.Error("Expected the AAPL book through the shared directory")
+	}
+	if v, ok := auction.Volume("AAPL"); !ok || v.OpeningShares != 100 {
+		t.Errorf("Expected an AAPL opening cross of 100, got %+v", v)
+	}
+	if info, ok := d.BySymbol("AAPL"); !ok || info.RoundLotSize != 100 {
+		t.Errorf("Expected AAPL with lots of 100, got %+v", info)
+	}
+}
@@ -0,0 +1,217 @@
+package itch
+
+import "encoding/binary"
+
+// Encoders append the ITCH 5.0 wire representation of a message to b and
+// return the extended slice. The message type byte is always written from the
+// encoder, so the Type field of msg is ignored.
+
+// appendHeader appends the common message header
+func appendHeader(b []byte, msgType byte, locate, tracking uint16, timestamp uint64) []byte {
+	b = append(b, msgType)
+	b = binary.BigEndian.AppendUint16(b, locate)
+	b = binary.BigEndian.AppendUint16(b, tracking)
+	return appendUint48BE(b, timestamp)
+}
+
+// appendUint48BE appends the low 48 bits of v in big-endian order
+func appendUint48BE(b []byte, v uint64) []byte {
+	return append(b, byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// StockField converts a symbol into a space-padded ITCH stock field.
+// Symbols longer than 8 characters are truncated.
+func StockField(stock string) StockSymbol {
+	return NewStockSymbol(stock)
+}
+
+// MPIDField converts a market participant ID into a space-padded ITCH
+// attribution field. IDs longer than 4 characters are truncated.
+func MPIDField(mpid string) [4]byte {
+	var field [4]byte
+	n := copy(field[:], mpid)
+	for i := n; i < len(field); i++ {
+		field[i] = ' '
+	}
+	return field
+}
+
+// AppendSystemEvent appends a System Event message
+func AppendSystemEvent(b []byte, msg SystemEventMessage) []byte {
+	b = appendHeader(b, MessageTypeSystemEvent, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	return append(b, msg.EventCode)
+}
+
+// AppendStockDirectory appends a Stock Directory message
+func AppendStockDirectory(b []byte, msg StockDirectoryMessage) []byte {
+	b = appendHeader(b, MessageTypeStockDirectory, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	b = append(b, msg.Stock[:]...)
+	b = append(b, msg.MarketCategory, msg.FinancialStatusIndicator)
+	b = binary.BigEndian.AppendUint32(b, msg.RoundLotSize)
+	b = append(b, msg.RoundLotsOnly, msg.IssueClassification)
+	b = append(b, msg.IssueSubType[:]...)
+	b = append(b, msg.Authenticity, msg.ShortSaleThresholdIndicator, msg.IPOFlag, msg.LULDReferencePriceTier, msg.ETPFlag)
+	b = binary.BigEndian.AppendUint32(b, msg.ETPLeverageFactor)
+	return append(b, msg.InverseIndicator)
+}
+
+// AppendStockTradingAction appends a Stock Trading Action message. The reason
+// field is 4 bytes on the wire; Reason is written padded with spaces.
+func AppendStockTradingAction(b []byte, msg StockTradingActionMessage) []byte {
+	b = appendHeader(b, MessageTypeStockTradingAction, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	b = append(b, msg.Stock[:]...)
+	return append(b, msg.TradingState, msg.Reserved, msg.Reason, ' ', ' ', ' ')
+}
+
+// AppendRegSHO appends a Reg SHO Short Sale Price Test Restricted Indicator message
+func AppendRegSHO(b []byte, msg RegSHOMessage) []byte {
+	b = appendHeader(b, MessageTypeRegSHO, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	b = append(b, msg.Stock[:]...)
+	return append(b, msg.RegSHOAction)
+}
+
+// AppendMarketParticipantPosition appends a Market Participant Position message
+func AppendMarketParticipantPosition(b []byte, msg MarketParticipantPositionMessage) []byte {
+	b = appendHeader(b, MessageTypeMarketParticipantPos, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	b = append(b, msg.MPID[:]...)
+	b = append(b, msg.Stock[:]...)
+	return append(b, msg.PrimaryMarketMaker, msg.MarketMakerMode, msg.MarketParticipantState)
+}
+
+// AppendMWCBDecline appends a MWCB Decline Level message
+func AppendMWCBDecline(b []byte, msg MWCBDeclineMessage) []byte {
+	b = appendHeader(b, MessageTypeMWCBDecline, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	b = binary.BigEndian.AppendUint64(b, msg.Level1)
+	b = binary.BigEndian.AppendUint64(b, msg.Level2)
+	return binary.BigEndian.AppendUint64(b, msg.Level3)
+}
+
+// AppendMWCBStatus appends a MWCB Status message
+func AppendMWCBStatus(b []byte, msg MWCBStatusMessage) []byte {
+	b = appendHeader(b, MessageTypeMWCBStatus, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	return append(b, msg.BreachedLevel)
+}
+
+// AppendIPOQuoting appends an IPO Quoting Period Update message
+func AppendIPOQuoting(b []byte, msg IPOQuotingMessage) []byte {
+	b = appendHeader(b, MessageTypeIPOQuoting, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	b = append(b, msg.Stock[:]...)
+	b = binary.BigEndian.AppendUint32(b, msg.IPOReleaseTime)
+	b = append(b, msg.IPOReleaseQualifier)
+	return binary.BigEndian.AppendUint32(b, msg.IPOPrice)
+}
+
+// AppendAddOrder appends an Add Order message
+func AppendAddOrder(b []byte, msg AddOrderMessage) []byte {
+	b = appendHeader(b, MessageTypeAddOrder, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	b = binary.BigEndian.AppendUint64(b, msg.OrderReferenceNumber)
+	b = append(b, msg.BuySellIndicator)
+	b = binary.BigEndian.AppendUint32(b, msg.Shares)
+	b = append(b, msg.Stock[:]...)
+	return binary.BigEndian.AppendUint32(b, msg.Price)
+}
+
+// AppendAddOrderMPID appends an Add Order with MPID attribution message
+func AppendAddOrderMPID(b []byte, msg AddOrderMPIDMessage) []byte {
+	b = appendHeader(b, MessageTypeAddOrderMPID, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	b = binary.BigEndian.AppendUint64(b, msg.OrderReferenceNumber)
+	b = append(b, msg.BuySellIndicator)
+	b = binary.BigEndian.AppendUint32(b, msg.Shares)
+	b = append(b, msg.Stock[:]...)
+	b = binary.BigEndian.AppendUint32(b, msg.Price)
+	return append(b, msg.Attribution[:]...)
+}
+
+// AppendOrderExecuted appends an Order Executed message
+func AppendOrderExecuted(b []byte, msg OrderExecutedMessage) []byte {
+	b = appendHeader(b, MessageTypeOrderExecuted, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	b = binary.BigEndian.AppendUint64(b, msg.OrderReferenceNumber)
+	b = binary.BigEndian.AppendUint32(b, msg.ExecutedShares)
+	return binary.BigEndian.AppendUint64(b, msg.MatchNumber)
+}
+
+// AppendOrderExecutedWithPrice appends an Order Executed With Price message
+func AppendOrderExecutedWithPrice(b []byte, msg OrderExecutedWithPriceMessage) []byte {
+	b = appendHeader(b, MessageTypeOrderExecutedWithPrice, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	b = binary.BigEndian.AppendUint64(b, msg.OrderReferenceNumber)
+	b = binary.BigEndian.AppendUint32(b, msg.ExecutedShares)
+	b = binary.BigEndian.AppendUint64(b, msg.MatchNumber)
+	b = append(b, msg.Printable)
+	return binary.BigEndian.AppendUint32(b, msg.ExecutionPrice)
+}
+
+// AppendOrderCancel appends an Order Cancel message
+func AppendOrderCancel(b []byte, msg OrderCancelMessage) []byte {
+	b = appendHeader(b, MessageTypeOrderCancel, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	b = binary.BigEndian.AppendUint64(b, msg.OrderReferenceNumber)
+	return binary.BigEndian.AppendUint32(b, msg.CanceledShares)
+}
+
+// AppendOrderDelete appends an Order Delete message
+func AppendOrderDelete(b []byte, msg OrderDeleteMessage) []byte {
+	b = appendHeader(b, MessageTypeOrderDelete, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	return binary.BigEndian.AppendUint64(b, msg.OrderReferenceNumber)
+}
+
+// AppendOrderReplace appends an Order Replace message
+func AppendOrderReplace(b []byte, msg OrderReplaceMessage) []byte {
+	b = appendHeader(b, MessageTypeOrderReplace, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	b = binary.BigEndian.AppendUint64(b, msg.OriginalOrderReferenceNumber)
+	b = binary.BigEndian.AppendUint64(b, msg.NewOrderReferenceNumber)
+	b = binary.BigEndian.AppendUint32(b, msg.Shares)
+	return binary.BigEndian.AppendUint32(b, msg.Price)
+}
+
+// AppendTrade appends a non-cross Trade message
+func AppendTrade(b []byte, msg TradeMessage) []byte {
+	b = appendHeader(b, MessageTypeTrade, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	b = binary.BigEndian.AppendUint64(b, msg.OrderReferenceNumber)
+	b = append(b, msg.BuySellIndicator)
+	b = binary.BigEndian.AppendUint32(b, msg.Shares)
+	b = append(b, msg.Stock[:]...)
+	b = binary.BigEndian.AppendUint32(b, msg.Price)
+	return binary.BigEndian.AppendUint64(b, msg.MatchNumber)
+}
+
+// AppendCrossTrade appends a Cross Trade message
+func AppendCrossTrade(b []byte, msg CrossTradeMessage) []byte {
+	b = appendHeader(b, MessageTypeCrossTrade, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	b = binary.BigEndian.AppendUint64(b, msg.Shares)
+	b = append(b, msg.Stock[:]...)
+	b = binary.BigEndian.AppendUint32(b, msg.CrossPrice)
+	b = binary.BigEndian.AppendUint64(b, msg.MatchNumber)
+	return append(b, msg.CrossType)
+}
+
+// AppendBrokenTrade appends a Broken Trade message
+func AppendBrokenTrade(b []byte, msg BrokenTradeMessage) []byte {
+	b = appendHeader(b, MessageTypeBrokenTrade, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	return binary.BigEndian.AppendUint64(b, msg.MatchNumber)
+}
+
+// AppendNOII appends a Net Order Imbalance Indicator message
+func AppendNOII(b []byte, msg NOIIMessage) []byte {
+	b = appendHeader(b, MessageTypeNOII, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	b = binary.BigEndian.AppendUint64(b, msg.PairedShares)
+	b = binary.BigEndian.AppendUint64(b, msg.ImbalanceShares)
+	b = append(b, msg.ImbalanceDirection)
+	b = append(b, msg.Stock[:]...)
+	b = binary.BigEndian.AppendUint32(b, msg.FarPrice)
+	b = binary.BigEndian.AppendUint32(b, msg.NearPrice)
+	b = binary.BigEndian.AppendUint32(b, msg.CurrentRefPrice)
+	return append(b, msg.CrossType, msg.PriceVariationIndicator)
+}
+
+// AppendRPII appends a Retail Price Improvement Indicator message
+func AppendRPII(b []byte, msg RPIIMessage) []byte {
+	b = appendHeader(b, MessageTypeRPII, msg.StockLocate, msg.TrackingNumber, msg.Timestamp)
+	b = append(b, msg.Stock[:]...)
+	return append(b, msg.InterestFlag)
+}
+
+// AppendFrame appends msg with its 2-byte big-endian length prefix, as in
+// NASDAQ BinaryFILE captures read by FrameReader
+func AppendFrame(b []byte, msg []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(msg)))
+	return append(b, msg...)
+}
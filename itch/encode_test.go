@@ -0,0 +1,233 @@
+package itch
+
+import "testing"
+
+// recordHandler keeps every parsed message in arrival order
+type recordHandler struct {
+	DefaultHandler
+	msgs []any
+}
+
+func (h *recordHandler) OnSystemEvent(msg SystemEventMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnStockDirectory(msg StockDirectoryMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnAddOrder(msg AddOrderMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnAddOrderMPID(msg AddOrderMPIDMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnOrderExecuted(msg OrderExecutedMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnOrderExecutedWithPrice(msg OrderExecutedWithPriceMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnOrderCancel(msg OrderCancelMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnOrderDelete(msg OrderDeleteMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnOrderReplace(msg OrderReplaceMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnTrade(msg TradeMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnBrokenTrade(msg BrokenTradeMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnStockTradingAction(msg StockTradingActionMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnRegSHO(msg RegSHOMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnMarketParticipantPosition(msg MarketParticipantPositionMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnMWCBDecline(msg MWCBDeclineMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnMWCBStatus(msg MWCBStatusMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnIPOQuoting(msg IPOQuotingMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnCrossTrade(msg CrossTradeMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnNOII(msg NOIIMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+func (h *recordHandler) OnRPII(msg RPIIMessage) error {
+	h.msgs = append(h.msgs, msg)
+	return nil
+}
+
+// unknownMessage is an unknown message kept by recordHandler, data
+// including the type byte
+type unknownMessage struct {
+	msgType byte
+	data    string
+}
+
+func (h *recordHandler) OnUnknownMessage(msgType byte, data []byte) error {
+	h.msgs = append(h.msgs, unknownMessage{msgType, string(data)})
+	return nil
+}
+
+// appendMessage appends the wire representation of a message struct
+func appendMessage(data []byte, msg any) []byte {
+	switch m := msg.(type) {
+	case SystemEventMessage:
+		data = AppendSystemEvent(data, m)
+	case StockDirectoryMessage:
+		data = AppendStockDirectory(data, m)
+	case AddOrderMessage:
+		data = AppendAddOrder(data, m)
+	case AddOrderMPIDMessage:
+		data = AppendAddOrderMPID(data, m)
+	case OrderExecutedMessage:
+		data = AppendOrderExecuted(data, m)
+	case OrderExecutedWithPriceMessage:
+		data = AppendOrderExecutedWithPrice(data, m)
+	case OrderCancelMessage:
+		data = AppendOrderCancel(data, m)
+	case OrderReplaceMessage:
+		data = AppendOrderReplace(data, m)
+	case OrderDeleteMessage:
+		data = AppendOrderDelete(data, m)
+	case TradeMessage:
+		data = AppendTrade(data, m)
+	case BrokenTradeMessage:
+		data = AppendBrokenTrade(data, m)
+	case StockTradingActionMessage:
+		data = AppendStockTradingAction(data, m)
+	case RegSHOMessage:
+		data = AppendRegSHO(data, m)
+	case MarketParticipantPositionMessage:
+		data = AppendMarketParticipantPosition(data, m)
+	case MWCBDeclineMessage:
+		data = AppendMWCBDecline(data, m)
+	case MWCBStatusMessage:
+		data = AppendMWCBStatus(data, m)
+	case IPOQuotingMessage:
+		data = AppendIPOQuoting(data, m)
+	case CrossTradeMessage:
+		data = AppendCrossTrade(data, m)
+	case NOIIMessage:
+		data = AppendNOII(data, m)
+	case RPIIMessage:
+		data = AppendRPII(data, m)
+	case unknownMessage:
+		data = append(data, m.data...)
+	}
+	return data
+}
+
+// testMessages returns one message of every type
+func testMessages() []any {
+	const ts = 34200000000123
+	stock := StockField("AAPL")
+	return []any{
+		SystemEventMessage{Type: 'S', TrackingNumber: 1, Timestamp: ts, EventCode: 'O'},
+		StockDirectoryMessage{Type: 'R', StockLocate: 7, Timestamp: ts, Stock: stock, MarketCategory: 'Q', FinancialStatusIndicator: 'N',
+			RoundLotSize: 100, RoundLotsOnly: 'N', IssueClassification: 'C', IssueSubType: [2]byte{'Z', ' '}, Authenticity: 'P',
+			ShortSaleThresholdIndicator: 'N', IPOFlag: 'N', LULDReferencePriceTier: '1', ETPFlag: 'N', ETPLeverageFactor: 3, InverseIndicator: 'N'},
+		AddOrderMessage{Type: 'A', StockLocate: 7, Timestamp: ts, OrderReferenceNumber: 1, BuySellIndicator: 'B', Shares: 100, Stock: stock, Price: 1500000},
+		AddOrderMPIDMessage{Type: 'F', StockLocate: 7, Timestamp: ts, OrderReferenceNumber: 2, BuySellIndicator: 'S', Shares: 200, Stock: stock, Price: 1510000, Attribution: MPIDField("GSCO")},
+		OrderExecutedMessage{Type: 'E', StockLocate: 7, Timestamp: ts, OrderReferenceNumber: 1, ExecutedShares: 10, MatchNumber: 5},
+		OrderExecutedWithPriceMessage{Type: 'C', StockLocate: 7, Timestamp: ts, OrderReferenceNumber: 1, ExecutedShares: 10, MatchNumber: 6, Printable: 'Y', ExecutionPrice: 1490000},
+		OrderCancelMessage{Type: 'X', StockLocate: 7, Timestamp: ts, OrderReferenceNumber: 1, CanceledShares: 30},
+		OrderReplaceMessage{Type: 'U', StockLocate: 7, Timestamp: ts, OriginalOrderReferenceNumber: 2, NewOrderReferenceNumber: 3, Shares: 50, Price: 1520000},
+		OrderDeleteMessage{Type: 'D', StockLocate: 7, Timestamp: ts, OrderReferenceNumber: 3},
+		TradeMessage{Type: 'P', StockLocate: 7, Timestamp: ts, BuySellIndicator: 'B', Shares: 25, Stock: stock, Price: 1500100, MatchNumber: 7},
+		BrokenTradeMessage{Type: 'B', StockLocate: 7, Timestamp: ts, MatchNumber: 7},
+		StockTradingActionMessage{Type: 'H', StockLocate: 7, Timestamp: ts, Stock: stock, TradingState: 'T', Reserved: ' ', Reason: ' '},
+		RegSHOMessage{Type: 'Y', StockLocate: 7, Timestamp: ts, Stock: stock, RegSHOAction: '1'},
+		MarketParticipantPositionMessage{Type: 'L', StockLocate: 7, Timestamp: ts, MPID: MPIDField("GSCO"), Stock: stock, PrimaryMarketMaker: 'Y', MarketMakerMode: 'N', MarketParticipantState: 'A'},
+		MWCBDeclineMessage{Type: 'V', Timestamp: ts, Level1: 1, Level2: 2, Level3: 3},
+		MWCBStatusMessage{Type: 'W', Timestamp: ts, BreachedLevel: '1'},
+		IPOQuotingMessage{Type: 'K', StockLocate: 7, Timestamp: ts, Stock: stock, IPOReleaseTime: 36000, IPOReleaseQualifier: 'A', IPOPrice: 200000},
+		CrossTradeMessage{Type: 'Q', StockLocate: 7, Timestamp: ts, Shares: 1000, Stock: stock, CrossPrice: 1500000, MatchNumber: 8, CrossType: 'O'},
+		NOIIMessage{Type: 'I', StockLocate: 7, Timestamp: ts, PairedShares: 500, ImbalanceShares: 100, ImbalanceDirection: 'B', Stock: stock,
+			FarPrice: 1500000, NearPrice: 1500100, CurrentRefPrice: 1500050, CrossType: 'C', PriceVariationIndicator: 'L'},
+		RPIIMessage{Type: 'N', StockLocate: 7, Timestamp: ts, Stock: stock, InterestFlag: 'B'},
+	}
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	msgs := testMessages()
+
+	var data []byte
+	for _, msg := range msgs {
+		data = appendMessage(data, msg)
+	}
+
+	handler := &recordHandler{}
+	consumed, count, err := NewParser(handler).ParseAll(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if consumed != len(data) || count != len(msgs) {
+		t.Fatalf("Expected %d messages in %d bytes, got %d in %d", len(msgs), len(data), count, consumed)
+	}
+	for i := range msgs {
+		if handler.msgs[i] != msgs[i] {
+			t.Errorf("Message %d: expected %+v, got %+v", i, msgs[i], handler.msgs[i])
+		}
+	}
+}
+
+func TestStockField(t *testing.T) {
+	if f := StockField("MSFT"); string(f[:]) != "MSFT    " {
+		t.Errorf("Expected padded MSFT, got %q", f[:])
+	}
+	if f := StockField("VERYLONGNAME"); string(f[:]) != "VERYLONG" {
+		t.Errorf("Expected truncated symbol, got %q", f[:])
+	}
+}
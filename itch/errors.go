@@ -0,0 +1,95 @@
+package itch
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// maxErrorDumpSize is the maximum number of offending bytes kept in a ParseError
+const maxErrorDumpSize = 64
+
+// ParseError describes a failure while decoding a message from an ITCH
+// stream. It records where in the stream the failure happened so that
+// malformed files and feed glitches can be located, and wraps the underlying
+// error so that errors.Is and errors.As keep working.
+type ParseError struct {
+	// Offset is the byte offset of the message within the stream
+	Offset int64
+	// Index is the zero-based index of the message within the stream
+	Index uint64
+	// MessageType is the type byte of the offending message
+	MessageType byte
+	// Data holds the first bytes of the offending message
+	Data []byte
+	// Err is the underlying error
+	Err error
+}
+
+// newParseError creates a ParseError keeping a copy of the offending bytes
+func newParseError(offset int64, index uint64, data []byte, err error) *ParseError {
+	n := len(data)
+	if n > maxErrorDumpSize {
+		n = maxErrorDumpSize
+	}
+	pe := &ParseError{
+		Offset: offset,
+		Index:  index,
+		Data:   append([]byte(nil), data[:n]...),
+		Err:    err,
+	}
+	if len(data) > 0 {
+		pe.MessageType = data[0]
+	}
+	return pe
+}
+
+// Error returns a description of the failure with the stream position
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("itch: message %d (type %q) at offset %d: %v",
+		e.Index, e.MessageType, e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Dump returns a hexdump of the offending bytes
+func (e *ParseError) Dump() string {
+	return hex.Dump(e.Data)
+}
+
+// HandlerError describes an error returned by the handler or a callback for a
+// message that was decoded, as opposed to a ParseError for a malformed
+// stream. The parser position is past the message, so parsing can resume with
+// the next one.
+type HandlerError struct {
+	// Offset is the byte offset of the message within the stream
+	Offset int64
+	// Index is the zero-based index of the message within the stream
+	Index uint64
+	// MessageType is the type byte of the message
+	MessageType byte
+	// Err is the error returned by the handler
+	Err error
+}
+
+// Error returns the handler error with the stream position of the message
+func (e *HandlerError) Error() string {
+	return fmt.Sprintf("itch: handler failed on message %d (type %q) at offset %d: %v",
+		e.Index, e.MessageType, e.Offset, e.Err)
+}
+
+// Unwrap returns the error returned by the handler
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// wrapError wraps the failure of the message at the start of data: a
+// HandlerError once the message was decoded, consumed > 0, or a ParseError
+func wrapError(offset int64, index uint64, data []byte, consumed int, err error) error {
+	if consumed > 0 {
+		return &HandlerError{Offset: offset, Index: index, MessageType: data[0], Err: err}
+	}
+	return newParseError(offset, index, data[:1], err)
+}
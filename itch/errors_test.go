@@ -0,0 +1,106 @@
+package itch
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var errRejected = errors.New("rejected")
+
+// rejectingHandler fails on the second system event
+type rejectingHandler struct {
+	DefaultHandler
+	events int
+}
+
+func (h *rejectingHandler) OnSystemEvent(msg SystemEventMessage) error {
+	h.events++
+	if h.events == 2 {
+		return errRejected
+	}
+	return nil
+}
+
+func TestParser_ParseError(t *testing.T) {
+	event := make([]byte, 12)
+	event[0] = 'S'
+	event[11] = 'O'
+	data := append(frame(event), frame(event[:5])...)
+
+	parser := NewParser(&DefaultHandler{})
+	count, err := parser.ParseStream(bytes.NewReader(data))
+	if count != 1 {
+		t.Errorf("Expected 1 message before the error, got %d", count)
+	}
+
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("Expected *ParseError, got %v", err)
+	}
+	if pe.Offset != 14 || pe.Index != 1 || pe.MessageType != 'S' {
+		t.Errorf("Expected message 1 of type S at offset 14, got %d %c %d", pe.Index, pe.MessageType, pe.Offset)
+	}
+	if len(pe.Data) != 5 {
+		t.Errorf("Expected 5 offending bytes, got %d", len(pe.Data))
+	}
+	if !strings.Contains(pe.Dump(), "53 00 00") {
+		t.Errorf("Expected hexdump of the message, got %q", pe.Dump())
+	}
+
+	parser.Reset()
+	if parser.Offset() != 0 || parser.MessageIndex() != 0 {
+		t.Errorf("Expected reset parser position, got %d / %d", parser.Offset(), parser.MessageIndex())
+	}
+}
+
+func TestParser_HandlerError(t *testing.T) {
+	parser := NewParser(&rejectingHandler{})
+
+	data := make([]byte, 36)
+	for i := 0; i < 3; i++ {
+		data[i*12] = 'S'
+		data[i*12+11] = 'O'
+	}
+
+	consumed, count, err := parser.ParseAll(data)
+	if consumed != 24 || count != 2 {
+		t.Errorf("Expected 24 bytes / 2 messages up to the failed one, got %d / %d", consumed, count)
+	}
+
+	// Handler errors are told apart from malformed input
+	var he *HandlerError
+	var pe *ParseError
+	if !errors.As(err, &he) || errors.As(err, &pe) {
+		t.Fatalf("Expected *HandlerError, got %v", err)
+	}
+	if !errors.Is(err, errRejected) {
+		t.Errorf("Expected error to wrap errRejected")
+	}
+	if he.Offset != 12 || he.Index != 1 || he.MessageType != 'S' {
+		t.Errorf("Expected message 1 of type S at offset 12, got %d %c %d", he.Index, he.MessageType, he.Offset)
+	}
+
+	// The position is past the failed message, so parsing resumes after it
+	if parser.Offset() != 24 || parser.MessageIndex() != 2 {
+		t.Errorf("Expected parser position 24 / 2, got %d / %d", parser.Offset(), parser.MessageIndex())
+	}
+	if _, _, err := parser.ParseAll(data[consumed:]); err != nil {
+		t.Fatalf("ParseAll: %v", err)
+	}
+	if parser.Offset() != 36 || parser.MessageIndex() != 3 {
+		t.Errorf("Expected parser position 36 / 3, got %d / %d", parser.Offset(), parser.MessageIndex())
+	}
+
+	// Streams report the frame offset and resume at the next frame
+	var stream []byte
+	for i := 0; i < 3; i++ {
+		stream = append(stream, frame(data[i*12:i*12+12])...)
+	}
+	parser = NewParser(&rejectingHandler{})
+	n, err := parser.ParseStream(bytes.NewReader(stream))
+	if !errors.As(err, &he) || he.Offset != 14 || n != 2 || parser.Offset() != 28 {
+		t.Errorf("Expected message 1 at offset 14 failed and position 28, got %v, %d, %d", err, n, parser.Offset())
+	}
+}
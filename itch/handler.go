@@ -54,7 +54,7 @@ type StockDirectoryMessage struct {
 	StockLocate                uint16
 	TrackingNumber             uint16
 	Timestamp                  uint64
-	Stock                      [8]byte
+	Stock                      StockSymbol
 	MarketCategory             byte
 	FinancialStatusIndicator   byte
 	RoundLotSize               uint32
@@ -76,7 +76,7 @@ type StockTradingActionMessage struct {
 	StockLocate    uint16
 	TrackingNumber uint16
 	Timestamp      uint64
-	Stock          [8]byte
+	Stock          StockSymbol
 	TradingState   byte
 	Reserved       byte
 	Reason         byte
@@ -88,7 +88,7 @@ type RegSHOMessage struct {
 	StockLocate    uint16
 	TrackingNumber uint16
 	Timestamp      uint64
-	Stock          [8]byte
+	Stock          StockSymbol
 	RegSHOAction   byte
 }
 
@@ -99,7 +99,7 @@ type MarketParticipantPositionMessage struct {
 	TrackingNumber         uint16
 	Timestamp              uint64
 	MPID                   [4]byte
-	Stock                  [8]byte
+	Stock                  StockSymbol
 	PrimaryMarketMaker     byte
 	MarketMakerMode        byte
 	MarketParticipantState byte
@@ -131,7 +131,7 @@ type IPOQuotingMessage struct {
 	StockLocate        uint16
 	TrackingNumber     uint16
 	Timestamp          uint64
-	Stock              [8]byte
+	Stock              StockSymbol
 	IPOReleaseTime     uint32
 	IPOReleaseQualifier byte
 	IPOPrice           uint32
@@ -146,7 +146,7 @@ type AddOrderMessage struct {
 	OrderReferenceNumber uint64
 	BuySellIndicator     byte
 	Shares               uint32
-	Stock                [8]byte
+	Stock                StockSymbol
 	Price                uint32
 }
 
@@ -159,9 +159,9 @@ type AddOrderMPIDMessage struct {
 	OrderReferenceNumber uint64
 	BuySellIndicator     byte
 	Shares               uint32
-	Stock                [8]byte
+	Stock                StockSymbol
 	Price                uint32
-	Attribution          byte
+	Attribution          [4]byte
 }
 
 // OrderExecutedMessage represents an order executed message
@@ -228,7 +228,7 @@ type TradeMessage struct {
 	OrderReferenceNumber uint64
 	BuySellIndicator     byte
 	Shares               uint32
-	Stock                [8]byte
+	Stock                StockSymbol
 	Price                uint32
 	MatchNumber          uint64
 }
@@ -240,7 +240,7 @@ type CrossTradeMessage struct {
 	TrackingNumber uint16
 	Timestamp      uint64
 	Shares         uint64
-	Stock          [8]byte
+	Stock          StockSymbol
 	CrossPrice     uint32
 	MatchNumber    uint64
 	CrossType      byte
@@ -264,7 +264,7 @@ type NOIIMessage struct {
 	PairedShares       uint64
 	ImbalanceShares    uint64
 	ImbalanceDirection byte
-	Stock              [8]byte
+	Stock              StockSymbol
 	FarPrice           uint32
 	NearPrice          uint32
 	CurrentRefPrice    uint32
@@ -278,7 +278,7 @@ type RPIIMessage struct {
 	StockLocate    uint16
 	TrackingNumber uint16
 	Timestamp      uint64
-	Stock          [8]byte
+	Stock          StockSymbol
 	InterestFlag   byte
 }
 
@@ -335,73 +335,121 @@ func (h *DefaultHandler) OnUnknownMessage(msgType byte, data []byte) error
 // Parser parses ITCH protocol messages
 type Parser struct {
 	handler Handler
+	// callbacks are the callbacks registered with On, nil if there are none
+	callbacks *callbacks
+
+	// offset is the stream byte offset of the next message
+	offset int64
+	// index is the stream index of the next message
+	index uint64
 }
 
-// NewParser creates a new ITCH parser
+// NewParser creates a new ITCH parser. handler may be nil if callbacks are
+// registered with On instead.
 func NewParser(handler Handler) *Parser {
 	return &Parser{handler: handler}
 }
 
-// Parse parses a single ITCH message
+// Offset returns the stream byte offset of the next message to be parsed
+func (p *Parser) Offset() int64 {
+	return p.offset
+}
+
+// MessageIndex returns the number of messages parsed so far
+func (p *Parser) MessageIndex() uint64 {
+	return p.index
+}
+
+// Reset resets the stream position, e.g. before parsing a new file
+func (p *Parser) Reset() {
+	p.offset = 0
+	p.index = 0
+}
+
+// Parse parses a single ITCH message.
+// ErrInsufficientData is returned as is, so that stream readers can wait for
+// more data. An error of the handler is wrapped in a *HandlerError, with the
+// message consumed and the position advanced past it; every other failure is
+// wrapped in a *ParseError carrying the stream position of the message.
 func (p *Parser) Parse(data []byte) (int, error) {
 	if len(data) < 1 {
 		return 0, ErrInsufficientData
 	}
 
-	msgType := data[0]
 	var consumed int
 	var err error
+	if p.callbacks != nil {
+		consumed, err = p.dispatch(data)
+	} else {
+		consumed, err = parseMessage(p.handler, data)
+	}
+	if err != nil {
+		if err == ErrInsufficientData {
+			return consumed, err
+		}
+		err = wrapError(p.offset, p.index, data, consumed, err)
+		if consumed == 0 {
+			return 0, err
+		}
+	}
+
+	p.offset += int64(consumed)
+	p.index++
+	return consumed, err
+}
 
-	switch msgType {
+// parseMessage decodes the message at the start of data, which must not be
+// empty, and passes it to h. It is generic over the handler so that parsers
+// of a concrete handler type can call it without interface dispatch.
+func parseMessage[H Handler](h H, data []byte) (int, error) {
+	switch data[0] {
 	case MessageTypeSystemEvent:
-		consumed, err = p.parseSystemEvent(data)
+		return parseSystemEvent(h, data)
 	case MessageTypeStockDirectory:
-		consumed, err = p.parseStockDirectory(data)
+		return parseStockDirectory(h, data)
 	case MessageTypeStockTradingAction:
-		consumed, err = p.parseStockTradingAction(data)
+		return parseStockTradingAction(h, data)
 	case MessageTypeRegSHO:
-		consumed, err = p.parseRegSHO(data)
+		return parseRegSHO(h, data)
 	case MessageTypeMarketParticipantPos:
-		consumed, err = p.parseMarketParticipantPosition(data)
+		return parseMarketParticipantPosition(h, data)
 	case MessageTypeMWCBDecline:
-		consumed, err = p.parseMWCBDecline(data)
+		return parseMWCBDecline(h, data)
 	case MessageTypeMWCBStatus:
-		consumed, err = p.parseMWCBStatus(data)
+		return parseMWCBStatus(h, data)
 	case MessageTypeIPOQuoting:
-		consumed, err = p.parseIPOQuoting(data)
+		return parseIPOQuoting(h, data)
 	case MessageTypeAddOrder:
-		consumed, err = p.parseAddOrder(data)
+		return parseAddOrder(h, data)
 	case MessageTypeAddOrderMPID:
-		consumed, err = p.parseAddOrderMPID(data)
+		return parseAddOrderMPID(h, data)
 	case MessageTypeOrderExecuted:
-		consumed, err = p.parseOrderExecuted(data)
+		return parseOrderExecuted(h, data)
 	case MessageTypeOrderExecutedWithPrice:
-		consumed, err = p.parseOrderExecutedWithPrice(data)
+		return parseOrderExecutedWithPrice(h, data)
 	case MessageTypeOrderCancel:
-		consumed, err = p.parseOrderCancel(data)
+		return parseOrderCancel(h, data)
 	case MessageTypeOrderDelete:
-		consumed, err = p.parseOrderDelete(data)
+		return parseOrderDelete(h, data)
 	case MessageTypeOrderReplace:
-		consumed, err = p.parseOrderReplace(data)
+		return parseOrderReplace(h, data)
 	case MessageTypeTrade:
-		consumed, err = p.parseTrade(data)
+		return parseTrade(h, data)
 	case MessageTypeCrossTrade:
-		consumed, err = p.parseCrossTrade(data)
+		return parseCrossTrade(h, data)
 	case MessageTypeBrokenTrade:
-		consumed, err = p.parseBrokenTrade(data)
+		return parseBrokenTrade(h, data)
 	case MessageTypeNOII:
-		consumed, err = p.parseNOII(data)
+		return parseNOII(h, data)
 	case MessageTypeRPII:
-		consumed, err = p.parseRPII(data)
+		return parseRPII(h, data)
 	default:
-		err = p.handler.OnUnknownMessage(msgType, data)
-		consumed = len(data)
+		return len(data), h.OnUnknownMessage(data[0], data)
 	}
-
-	return consumed, err
 }
 
-// ParseAll parses all ITCH messages in the data
+// ParseAll parses all ITCH messages in the data and returns the bytes and
+// messages consumed, including a message whose handler failed
 func (p *Parser) ParseAll(data []byte) (int, int, error) {
 	totalConsumed := 0
 	messageCount := 0
@@ -412,6 +460,10 @@ func (p *Parser) ParseAll(data []byte) (int, int, error) {
 			if err == ErrInsufficientData {
 				break
 			}
+			if consumed > 0 {
+				totalConsumed += consumed
+				messageCount++
+			}
 			return totalConsumed, messageCount, err
 		}
 		if consumed == 0 {
@@ -444,7 +496,7 @@ func readUint64BE(data []byte) uint64 {
 	return binary.BigEndian.Uint64(data)
 }
 
-func (p *Parser) parseSystemEvent(data []byte) (int, error) {
+func parseSystemEvent[H Handler](h H, data []byte) (int, error) {
 	const size = 12
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -458,10 +510,10 @@ func (p *Parser) parseSystemEvent(data []byte) (int, error) {
 		EventCode:      data[11],
 	}
 
-	return size, p.handler.OnSystemEvent(msg)
+	return size, h.OnSystemEvent(msg)
 }
 
-func (p *Parser) parseStockDirectory(data []byte) (int, error) {
+func parseStockDirectory[H Handler](h H, data []byte) (int, error) {
 	const size = 39
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -488,10 +540,10 @@ func (p *Parser) parseStockDirectory(data []byte) (int, error) {
 	copy(msg.Stock[:], data[11:19])
 	copy(msg.IssueSubType[:], data[27:29])
 
-	return size, p.handler.OnStockDirectory(msg)
+	return size, h.OnStockDirectory(msg)
 }
 
-func (p *Parser) parseStockTradingAction(data []byte) (int, error) {
+func parseStockTradingAction[H Handler](h H, data []byte) (int, error) {
 	const size = 25
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -508,10 +560,10 @@ func (p *Parser) parseStockTradingAction(data []byte) (int, error) {
 	}
 	copy(msg.Stock[:], data[11:19])
 
-	return size, p.handler.OnStockTradingAction(msg)
+	return size, h.OnStockTradingAction(msg)
 }
 
-func (p *Parser) parseRegSHO(data []byte) (int, error) {
+func parseRegSHO[H Handler](h H, data []byte) (int, error) {
 	const size = 20
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -526,10 +578,10 @@ func (p *Parser) parseRegSHO(data []byte) (int, error) {
 	}
 	copy(msg.Stock[:], data[11:19])
 
-	return size, p.handler.OnRegSHO(msg)
+	return size, h.OnRegSHO(msg)
 }
 
-func (p *Parser) parseMarketParticipantPosition(data []byte) (int, error) {
+func parseMarketParticipantPosition[H Handler](h H, data []byte) (int, error) {
 	const size = 26
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -547,10 +599,10 @@ func (p *Parser) parseMarketParticipantPosition(data []byte) (int, error) {
 	copy(msg.MPID[:], data[11:15])
 	copy(msg.Stock[:], data[15:23])
 
-	return size, p.handler.OnMarketParticipantPosition(msg)
+	return size, h.OnMarketParticipantPosition(msg)
 }
 
-func (p *Parser) parseMWCBDecline(data []byte) (int, error) {
+func parseMWCBDecline[H Handler](h H, data []byte) (int, error) {
 	const size = 35
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -566,10 +618,10 @@ func (p *Parser) parseMWCBDecline(data []byte) (int, error) {
 		Level3:         readUint64BE(data[27:35]),
 	}
 
-	return size, p.handler.OnMWCBDecline(msg)
+	return size, h.OnMWCBDecline(msg)
 }
 
-func (p *Parser) parseMWCBStatus(data []byte) (int, error) {
+func parseMWCBStatus[H Handler](h H, data []byte) (int, error) {
 	const size = 12
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -583,10 +635,10 @@ func (p *Parser) parseMWCBStatus(data []byte) (int, error) {
 		BreachedLevel:  data[11],
 	}
 
-	return size, p.handler.OnMWCBStatus(msg)
+	return size, h.OnMWCBStatus(msg)
 }
 
-func (p *Parser) parseIPOQuoting(data []byte) (int, error) {
+func parseIPOQuoting[H Handler](h H, data []byte) (int, error) {
 	const size = 28
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -603,10 +655,10 @@ func (p *Parser) parseIPOQuoting(data []byte) (int, error) {
 	}
 	copy(msg.Stock[:], data[11:19])
 
-	return size, p.handler.OnIPOQuoting(msg)
+	return size, h.OnIPOQuoting(msg)
 }
 
-func (p *Parser) parseAddOrder(data []byte) (int, error) {
+func parseAddOrder[H Handler](h H, data []byte) (int, error) {
 	const size = 36
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -624,10 +676,10 @@ func (p *Parser) parseAddOrder(data []byte) (int, error) {
 	}
 	copy(msg.Stock[:], data[24:32])
 
-	return size, p.handler.OnAddOrder(msg)
+	return size, h.OnAddOrder(msg)
 }
 
-func (p *Parser) parseAddOrderMPID(data []byte) (int, error) {
+func parseAddOrderMPID[H Handler](h H, data []byte) (int, error) {
 	const size = 40
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -642,14 +694,14 @@ func (p *Parser) parseAddOrderMPID(data []byte) (int, error) {
 		BuySellIndicator:     data[19],
 		Shares:               readUint32BE(data[20:24]),
 		Price:                readUint32BE(data[32:36]),
-		Attribution:          data[36],
 	}
 	copy(msg.Stock[:], data[24:32])
+	copy(msg.Attribution[:], data[36:40])
 
-	return size, p.handler.OnAddOrderMPID(msg)
+	return size, h.OnAddOrderMPID(msg)
 }
 
-func (p *Parser) parseOrderExecuted(data []byte) (int, error) {
+func parseOrderExecuted[H Handler](h H, data []byte) (int, error) {
 	const size = 31
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -665,10 +717,10 @@ func (p *Parser) parseOrderExecuted(data []byte) (int, error) {
 		MatchNumber:          readUint64BE(data[23:31]),
 	}
 
-	return size, p.handler.OnOrderExecuted(msg)
+	return size, h.OnOrderExecuted(msg)
 }
 
-func (p *Parser) parseOrderExecutedWithPrice(data []byte) (int, error) {
+func parseOrderExecutedWithPrice[H Handler](h H, data []byte) (int, error) {
 	const size = 36
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -686,10 +738,10 @@ func (p *Parser) parseOrderExecutedWithPrice(data []byte) (int, error) {
 		ExecutionPrice:       readUint32BE(data[32:36]),
 	}
 
-	return size, p.handler.OnOrderExecutedWithPrice(msg)
+	return size, h.OnOrderExecutedWithPrice(msg)
 }
 
-func (p *Parser) parseOrderCancel(data []byte) (int, error) {
+func parseOrderCancel[H Handler](h H, data []byte) (int, error) {
 	const size = 23
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -704,10 +756,10 @@ func (p *Parser) parseOrderCancel(data []byte) (int, error) {
 		CanceledShares:       readUint32BE(data[19:23]),
 	}
 
-	return size, p.handler.OnOrderCancel(msg)
+	return size, h.OnOrderCancel(msg)
 }
 
-func (p *Parser) parseOrderDelete(data []byte) (int, error) {
+func parseOrderDelete[H Handler](h H, data []byte) (int, error) {
 	const size = 19
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -721,10 +773,10 @@ func (p *Parser) parseOrderDelete(data []byte) (int, error) {
 		OrderReferenceNumber: readUint64BE(data[11:19]),
 	}
 
-	return size, p.handler.OnOrderDelete(msg)
+	return size, h.OnOrderDelete(msg)
 }
 
-func (p *Parser) parseOrderReplace(data []byte) (int, error) {
+func parseOrderReplace[H Handler](h H, data []byte) (int, error) {
 	const size = 35
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -741,10 +793,10 @@ func (p *Parser) parseOrderReplace(data []byte) (int, error) {
 		Price:                        readUint32BE(data[31:35]),
 	}
 
-	return size, p.handler.OnOrderReplace(msg)
+	return size, h.OnOrderReplace(msg)
 }
 
-func (p *Parser) parseTrade(data []byte) (int, error) {
+func parseTrade[H Handler](h H, data []byte) (int, error) {
 	const size = 44
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -763,10 +815,10 @@ func (p *Parser) parseTrade(data []byte) (int, error) {
 	}
 	copy(msg.Stock[:], data[24:32])
 
-	return size, p.handler.OnTrade(msg)
+	return size, h.OnTrade(msg)
 }
 
-func (p *Parser) parseCrossTrade(data []byte) (int, error) {
+func parseCrossTrade[H Handler](h H, data []byte) (int, error) {
 	const size = 40
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -784,10 +836,10 @@ func (p *Parser) parseCrossTrade(data []byte) (int, error) {
 	}
 	copy(msg.Stock[:], data[19:27])
 
-	return size, p.handler.OnCrossTrade(msg)
+	return size, h.OnCrossTrade(msg)
 }
 
-func (p *Parser) parseBrokenTrade(data []byte) (int, error) {
+func parseBrokenTrade[H Handler](h H, data []byte) (int, error) {
 	const size = 19
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -801,10 +853,10 @@ func (p *Parser) parseBrokenTrade(data []byte) (int, error) {
 		MatchNumber:    readUint64BE(data[11:19]),
 	}
 
-	return size, p.handler.OnBrokenTrade(msg)
+	return size, h.OnBrokenTrade(msg)
 }
 
-func (p *Parser) parseNOII(data []byte) (int, error) {
+func parseNOII[H Handler](h H, data []byte) (int, error) {
 	const size = 50
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -826,10 +878,10 @@ func (p *Parser) parseNOII(data []byte) (int, error) {
 	}
 	copy(msg.Stock[:], data[28:36])
 
-	return size, p.handler.OnNOII(msg)
+	return size, h.OnNOII(msg)
 }
 
-func (p *Parser) parseRPII(data []byte) (int, error) {
+func parseRPII[H Handler](h H, data []byte) (int, error) {
 	const size = 20
 	if len(data) < size {
 		return 0, ErrInsufficientData
@@ -844,7 +896,7 @@ func (p *Parser) parseRPII(data []byte) (int, error) {
 	}
 	copy(msg.Stock[:], data[11:19])
 
-	return size, p.handler.OnRPII(msg)
+	return size, h.OnRPII(msg)
 }
 
 // String returns a string representation of the message
@@ -0,0 +1,240 @@
+package itch
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// HeatmapConfig configures the sampling of a Heatmap
+type HeatmapConfig struct {
+	// Interval is the sampling interval in message time
+	Interval time.Duration
+	// Depth is the number of price levels sampled per side, 0 for all
+	Depth int
+	// Stocks restricts sampling to these stocks, empty for all
+	Stocks []string
+}
+
+// HeatmapFrame is the depth of a book at a sampling time
+type HeatmapFrame struct {
+	// Timestamp is the sampling time in nanoseconds since midnight
+	Timestamp uint64
+	// StockLocate is the locate code of the stock
+	StockLocate uint16
+	// Stock is the trimmed stock symbol
+	Stock string
+	// Bids are the bid levels, best first
+	Bids []BookLevel
+	// Asks are the ask levels, best first
+	Asks []BookLevel
+}
+
+// Heatmap is an ITCH handler sampling the depth of books at a fixed interval
+// of message time, producing a price × time × size series for heatmaps.
+// Samples are taken at multiples of the interval since midnight. A book is
+// only sampled if it changed since its previous frame, so consumers carry
+// the last frame of a book forward. Call Flush at the end of the stream to
+// sample the final state.
+type Heatmap struct {
+	*BookBuilder
+
+	interval uint64
+	depth    int
+	stocks   map[string]bool
+	next     uint64
+	dirty    map[uint16]bool
+
+	// OnFrame is called with every sampled frame; an error stops parsing
+	OnFrame func(f HeatmapFrame) error
+}
+
+// NewHeatmap creates a heatmap sampler calling onFrame with every frame
+func NewHeatmap(config HeatmapConfig, onFrame func(f HeatmapFrame) error) *Heatmap {
+	h := &Heatmap{
+		BookBuilder: NewBookBuilder(),
+		interval:    uint64(max(config.Interval, 1)),
+		depth:       config.Depth,
+		dirty:       make(map[uint16]bool),
+		OnFrame:     onFrame,
+	}
+	if h.depth <= 0 {
+		h.depth = -1
+	}
+	if len(config.Stocks) > 0 {
+		h.stocks = make(map[string]bool, len(config.Stocks))
+		for _, s := range config.Stocks {
+			h.stocks[s] = true
+		}
+	}
+	h.BookBuilder.OnDepthChange = func(c DepthChange) {
+		if h.stocks == nil || h.stocks[c.Stock] {
+			h.dirty[c.StockLocate] = true
+		}
+	}
+	return h
+}
+
+// advance samples the changed books if timestamp is past the next sampling
+// time. The books changed before it, so the sample is taken at that time.
+func (h *Heatmap) advance(timestamp uint64) error {
+	if timestamp < h.next {
+		return nil
+	}
+	err := h.sample(h.next)
+	h.next = timestamp - timestamp%h.interval + h.interval
+	return err
+}
+
+// Flush samples the books changed since the last sampling time at the next
+// sampling time
+func (h *Heatmap) Flush() error {
+	return h.sample(h.next)
+}
+
+// sample emits a frame of every changed book, in locate order
+func (h *Heatmap) sample(at uint64) error {
+	if len(h.dirty) == 0 {
+		return nil
+	}
+	var err error
+	for _, book := range h.Books() {
+		if !h.dirty[book.StockLocate] {
+			continue
+		}
+		if h.OnFrame != nil && err == nil {
+			err = h.OnFrame(HeatmapFrame{
+				Timestamp:   at,
+				StockLocate: book.StockLocate,
+				Stock:       book.Stock,
+				Bids:        book.Bids(h.depth),
+				Asks:        book.Asks(h.depth),
+			})
+		}
+	}
+	clear(h.dirty)
+	return err
+}
+
+// OnAddOrder samples the books and adds the order
+func (h *Heatmap) OnAddOrder(msg AddOrderMessage) error {
+	if err := h.advance(msg.Timestamp); err != nil {
+		return err
+	}
+	return h.BookBuilder.OnAddOrder(msg)
+}
+
+// OnAddOrderMPID samples the books and adds the order
+func (h *Heatmap) OnAddOrderMPID(msg AddOrderMPIDMessage) error {
+	if err := h.advance(msg.Timestamp); err != nil {
+		return err
+	}
+	return h.BookBuilder.OnAddOrderMPID(msg)
+}
+
+// OnOrderExecuted samples the books and executes the order
+func (h *Heatmap) OnOrderExecuted(msg OrderExecutedMessage) error {
+	if err := h.advance(msg.Timestamp); err != nil {
+		return err
+	}
+	return h.BookBuilder.OnOrderExecuted(msg)
+}
+
+// OnOrderExecutedWithPrice samples the books and executes the order
+func (h *Heatmap) OnOrderExecutedWithPrice(msg OrderExecutedWithPriceMessage) error {
+	if err := h.advance(msg.Timestamp); err != nil {
+		return err
+	}
+	return h.BookBuilder.OnOrderExecutedWithPrice(msg)
+}
+
+// OnOrderCancel samples the books and cancels shares of the order
+func (h *Heatmap) OnOrderCancel(msg OrderCancelMessage) error {
+	if err := h.advance(msg.Timestamp); err != nil {
+		return err
+	}
+	return h.BookBuilder.OnOrderCancel(msg)
+}
+
+// OnOrderDelete samples the books and deletes the order
+func (h *Heatmap) OnOrderDelete(msg OrderDeleteMessage) error {
+	if err := h.advance(msg.Timestamp); err != nil {
+		return err
+	}
+	return h.BookBuilder.OnOrderDelete(msg)
+}
+
+// OnOrderReplace samples the books and replaces the order
+func (h *Heatmap) OnOrderReplace(msg OrderReplaceMessage) error {
+	if err := h.advance(msg.Timestamp); err != nil {
+		return err
+	}
+	return h.BookBuilder.OnOrderReplace(msg)
+}
+
+// HeatmapHeader is the header row written by HeatmapWriter
+var HeatmapHeader = []string{"timestamp", "stock", "side", "price", "shares", "orders"}
+
+// HeatmapWriter writes heatmap frames as CSV, one row per price level:
+//
+//	timestamp,stock,side,price,shares,orders
+//
+// where timestamp is in nanoseconds since midnight, side is B or S and price
+// has 4 implied decimals. A frame of an empty book is written as a single row
+// with an empty side, so consumers know to clear the book.
+type HeatmapWriter struct {
+	w      *csv.Writer
+	header bool
+	frames uint64
+}
+
+// NewHeatmapWriter creates a heatmap writer
+func NewHeatmapWriter(w io.Writer) *HeatmapWriter {
+	return &HeatmapWriter{w: csv.NewWriter(w)}
+}
+
+// Write writes a frame
+func (w *HeatmapWriter) Write(f HeatmapFrame) error {
+	if !w.header {
+		if err := w.w.Write(HeatmapHeader); err != nil {
+			return err
+		}
+		w.header = true
+	}
+	w.frames++
+	timestamp := strconv.FormatUint(f.Timestamp, 10)
+	if len(f.Bids) == 0 && len(f.Asks) == 0 {
+		return w.w.Write([]string{timestamp, f.Stock, "", "0", "0", "0"})
+	}
+	for _, side := range []struct {
+		name   string
+		levels []BookLevel
+	}{{"B", f.Bids}, {"S", f.Asks}} {
+		for _, level := range side.levels {
+			row := []string{
+				timestamp,
+				f.Stock,
+				side.name,
+				strconv.FormatUint(uint64(level.Price), 10),
+				strconv.FormatUint(level.Shares, 10),
+				strconv.Itoa(level.Orders),
+			}
+			if err := w.w.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Frames returns the number of frames written
+func (w *HeatmapWriter) Frames() uint64 {
+	return w.frames
+}
+
+// Flush writes any buffered rows to the underlying writer
+func (w *HeatmapWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
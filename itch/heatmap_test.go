@@ -0,0 +1,69 @@
+package itch
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeatmap(t *testing.T) {
+	second := uint64(time.Second)
+	var frames []HeatmapFrame
+	h := NewHeatmap(HeatmapConfig{Interval: time.Second, Depth: 1, Stocks: []string{"AAPL"}}, func(f HeatmapFrame) error {
+		frames = append(frames, f)
+		return nil
+	})
+
+	aapl, msft := stockField("AAPL"), stockField("MSFT")
+	h.OnStockDirectory(StockDirectoryMessage{StockLocate: 1, Stock: aapl})
+	h.OnStockDirectory(StockDirectoryMessage{StockLocate: 2, Stock: msft})
+	h.OnAddOrder(AddOrderMessage{Timestamp: second / 2, StockLocate: 1, OrderReferenceNumber: 1, BuySellIndicator: 'B', Shares: 100, Stock: aapl, Price: 1000})
+	h.OnAddOrder(AddOrderMessage{Timestamp: second / 2, StockLocate: 1, OrderReferenceNumber: 2, BuySellIndicator: 'B', Shares: 50, Stock: aapl, Price: 900})
+	h.OnAddOrder(AddOrderMessage{Timestamp: second / 2, StockLocate: 2, OrderReferenceNumber: 3, BuySellIndicator: 'S', Shares: 10, Stock: msft, Price: 2000})
+	// Samples the first second at 1s
+	h.OnAddOrder(AddOrderMessage{Timestamp: 3*second + 1, StockLocate: 1, OrderReferenceNumber: 4, BuySellIndicator: 'S', Shares: 30, Stock: aapl, Price: 1100})
+	// Samples the ask at 4s, not repeating the unchanged seconds in between
+	h.OnOrderDelete(OrderDeleteMessage{Timestamp: 5 * second, StockLocate: 1, OrderReferenceNumber: 4})
+	h.OnOrderDelete(OrderDeleteMessage{Timestamp: 5 * second, StockLocate: 1, OrderReferenceNumber: 1})
+	h.OnOrderDelete(OrderDeleteMessage{Timestamp: 5 * second, StockLocate: 1, OrderReferenceNumber: 2})
+	if err := h.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	if len(frames) != 3 {
+		t.Fatalf("Expected 3 frames, got %+v", frames)
+	}
+	if f := frames[0]; f.Timestamp != second || f.Stock != "AAPL" || len(f.Bids) != 1 || f.Bids[0].Price != 1000 || len(f.Asks) != 0 {
+		t.Errorf("Expected the best bid at 1s, got %+v", f)
+	}
+	if f := frames[1]; f.Timestamp != 4*second || len(f.Asks) != 1 || f.Asks[0].Shares != 30 {
+		t.Errorf("Expected the ask at 4s, got %+v", f)
+	}
+	if f := frames[2]; f.Timestamp != 6*second || len(f.Bids) != 0 || len(f.Asks) != 0 {
+		t.Errorf("Expected an empty book at 6s, got %+v", f)
+	}
+
+	var buf bytes.Buffer
+	w := NewHeatmapWriter(&buf)
+	for _, f := range frames {
+		w.Write(f)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	want := strings.Join([]string{
+		"timestamp,stock,side,price,shares,orders",
+		"1000000000,AAPL,B,1000,100,1",
+		"4000000000,AAPL,B,1000,100,1",
+		"4000000000,AAPL,S,1100,30,1",
+		"6000000000,AAPL,,0,0,0",
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, buf.String())
+	}
+	if w.Frames() != 3 {
+		t.Errorf("Expected 3 frames written, got %d", w.Frames())
+	}
+}
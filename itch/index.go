@@ -0,0 +1,276 @@
+package itch
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// An index is a sidecar of a BinaryFILE capture holding the byte offset of
+// a message every IndexOptions.Every messages and every IndexOptions.Interval
+// of message time, so parsing can seek into the middle of a large file. The
+// file starts with indexMagic and a header of the file size, message count,
+// options and entry count, followed by the entries, all little-endian.
+const (
+	indexMagic = "ITCHIDX1"
+	// indexHeaderSize is the size of the header after the magic
+	indexHeaderSize = 5 * 8
+	// indexEntrySize is the size of an entry
+	indexEntrySize = 3 * 8
+)
+
+// Default spacing of the index entries
+const (
+	DefaultIndexEvery    = 1_000_000
+	DefaultIndexInterval = time.Minute
+)
+
+var (
+	// ErrInvalidIndex is returned when reading data that is not an index
+	ErrInvalidIndex = errors.New("invalid ITCH index")
+	// ErrStaleIndex is returned when an index does not match the size of
+	// the file it is used with, e.g. after the file was rewritten
+	ErrStaleIndex = errors.New("ITCH index does not match the file")
+)
+
+// IndexOptions controls the spacing of the entries of an index
+type IndexOptions struct {
+	// Every is the maximum number of messages between entries,
+	// DefaultIndexEvery if zero, negative to index by time only
+	Every int64
+	// Interval is the message time between entries, DefaultIndexInterval if
+	// zero, negative to index by message count only
+	Interval time.Duration
+}
+
+// IndexEntry is the position of an indexed message
+type IndexEntry struct {
+	// Offset is the file offset of the message frame, including its length
+	// prefix
+	Offset int64
+	// Message is the index of the message in the file
+	Message uint64
+	// Timestamp is the timestamp of the message, in nanoseconds since
+	// midnight, 0 for messages too short to hold one
+	Timestamp uint64
+}
+
+// Index is the index of a BinaryFILE capture, see BuildIndex
+type Index struct {
+	// Size is the size of the indexed file and Messages its message count
+	Size     int64
+	Messages uint64
+	Options  IndexOptions
+	// Entries are the indexed messages in file order, starting with the
+	// first message
+	Entries []IndexEntry
+}
+
+// IndexPath returns the path of the sidecar index of an ITCH file
+func IndexPath(filename string) string {
+	return filename + ".idx"
+}
+
+// BuildIndex scans the length-prefixed messages of r once and indexes the
+// first message, the first message of every opts.Interval of message time
+// and the message opts.Every messages after the previous entry
+func BuildIndex(r io.Reader, opts IndexOptions) (*Index, error) {
+	if opts.Every == 0 {
+		opts.Every = DefaultIndexEvery
+	}
+	if opts.Interval == 0 {
+		opts.Interval = DefaultIndexInterval
+	}
+	x := &Index{Options: opts}
+	frames := NewFrameReader(r)
+	var last IndexEntry
+	for {
+		offset := frames.Offset()
+		msg, err := frames.Next()
+		if err == io.EOF {
+			x.Size = frames.Offset()
+			return x, nil
+		}
+		if err != nil {
+			return nil, newParseError(offset, x.Messages, nil, fmt.Errorf("reading frame: %w", err))
+		}
+		if len(msg) == 0 {
+			// Empty frames are not messages, as in ParseStream
+			continue
+		}
+		e := IndexEntry{Offset: offset, Message: x.Messages, Timestamp: messageTimestamp(msg)}
+		if len(x.Entries) == 0 ||
+			(opts.Every > 0 && e.Message-last.Message >= uint64(opts.Every)) ||
+			(opts.Interval > 0 && e.Timestamp/uint64(opts.Interval) > last.Timestamp/uint64(opts.Interval)) {
+			x.Entries = append(x.Entries, e)
+			last = e
+		}
+		x.Messages++
+	}
+}
+
+// BuildIndexFile indexes an uncompressed ITCH file and writes the index to
+// its sidecar path
+func BuildIndexFile(filename string, opts IndexOptions) (*Index, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	x, err := BuildIndex(f, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := x.WriteFile(IndexPath(filename)); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// messageTimestamp returns the timestamp of a message, 0 if it is too short
+// to hold one
+func messageTimestamp(msg []byte) uint64 {
+	if len(msg) < 11 {
+		return 0
+	}
+	return readUint48BE(msg[5:11])
+}
+
+// Seek returns the last entry before timestamp, where parsing must start to
+// reach the first message at or after timestamp, or the start of the file.
+// Message timestamps are expected to be non-decreasing, as in exchange
+// captures.
+func (x *Index) Seek(timestamp uint64) IndexEntry {
+	i := sort.Search(len(x.Entries), func(i int) bool {
+		return x.Entries[i].Timestamp >= timestamp
+	})
+	if i == 0 {
+		return IndexEntry{}
+	}
+	return x.Entries[i-1]
+}
+
+// WriteTo writes the index to w
+func (x *Index) WriteTo(w io.Writer) (int64, error) {
+	buf := make([]byte, 0, len(indexMagic)+indexHeaderSize+len(x.Entries)*indexEntrySize)
+	buf = append(buf, indexMagic...)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(x.Size))
+	buf = binary.LittleEndian.AppendUint64(buf, x.Messages)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(x.Options.Every))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(x.Options.Interval))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(x.Entries)))
+	for _, e := range x.Entries {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(e.Offset))
+		buf = binary.LittleEndian.AppendUint64(buf, e.Message)
+		buf = binary.LittleEndian.AppendUint64(buf, e.Timestamp)
+	}
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// WriteFile writes the index to a file
+func (x *Index) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := x.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadIndex reads an index written by WriteTo
+func ReadIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	head := make([]byte, len(indexMagic)+indexHeaderSize)
+	if _, err := io.ReadFull(br, head); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrInvalidIndex
+		}
+		return nil, err
+	}
+	if string(head[:len(indexMagic)]) != indexMagic {
+		return nil, ErrInvalidIndex
+	}
+	head = head[len(indexMagic):]
+	x := &Index{
+		Size:     int64(binary.LittleEndian.Uint64(head[0:])),
+		Messages: binary.LittleEndian.Uint64(head[8:]),
+		Options: IndexOptions{
+			Every:    int64(binary.LittleEndian.Uint64(head[16:])),
+			Interval: time.Duration(binary.LittleEndian.Uint64(head[24:])),
+		},
+	}
+	count := binary.LittleEndian.Uint64(head[32:])
+	if x.Size < 0 || count > x.Messages {
+		return nil, ErrInvalidIndex
+	}
+	x.Entries = make([]IndexEntry, 0, min(count, 1<<16))
+	var entry [indexEntrySize]byte
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(br, entry[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, ErrInvalidIndex
+			}
+			return nil, err
+		}
+		x.Entries = append(x.Entries, IndexEntry{
+			Offset:    int64(binary.LittleEndian.Uint64(entry[0:])),
+			Message:   binary.LittleEndian.Uint64(entry[8:]),
+			Timestamp: binary.LittleEndian.Uint64(entry[16:]),
+		})
+	}
+	return x, nil
+}
+
+// ReadIndexFile reads an index from a file
+func ReadIndexFile(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadIndex(f)
+}
+
+// ParseFrom parses an uncompressed ITCH file from the first message at or
+// after startTime, in nanoseconds since midnight, to the end. With an index
+// the file is read from the last indexed message before startTime, without
+// one from the start; messages before startTime are skipped either way.
+// Positions and message indexes stay those of the whole file.
+//
+// Handlers tracking orders only see the orders added from startTime on, so
+// executions and cancels of earlier orders refer to unknown orders.
+func (p *Parser) ParseFrom(filename string, index *Index, startTime uint64) (int, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var start IndexEntry
+	if index != nil {
+		info, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		if info.Size() != index.Size {
+			return 0, ErrStaleIndex
+		}
+		start = index.Seek(startTime)
+		if _, err := f.Seek(start.Offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+	}
+	frames := NewFrameReader(f)
+	frames.offset = start.Offset
+	p.offset, p.index = start.Offset, start.Message
+	return p.parseFrames(frames, startTime)
+}
@@ -0,0 +1,117 @@
+package itch
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeIndexedFile writes a capture of n system events, one per second of
+// message time from 9:30, and returns its path
+func writeIndexedFile(t *testing.T, n int) string {
+	t.Helper()
+	start := uint64(9*time.Hour + 30*time.Minute)
+	var data []byte
+	for i := 0; i < n; i++ {
+		msg := AppendSystemEvent(nil, SystemEventMessage{Timestamp: start + uint64(i)*uint64(time.Second), EventCode: 'O'})
+		data = AppendFrame(data, msg)
+	}
+	path := filepath.Join(t.TempDir(), "capture.itch")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuildIndex(t *testing.T) {
+	path := writeIndexedFile(t, 300)
+	x, err := BuildIndexFile(path, IndexOptions{Every: 40})
+	if err != nil {
+		t.Fatalf("BuildIndexFile: %v", err)
+	}
+	if x.Messages != 300 || x.Size != 300*14 {
+		t.Errorf("Expected 300 messages in 4200 bytes, got %d in %d", x.Messages, x.Size)
+	}
+	// Entries at the start, at each minute and 40 messages after each entry
+	want := []uint64{0, 40, 60, 100, 120, 160, 180, 220, 240, 280}
+	if len(x.Entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %+v", len(want), x.Entries)
+	}
+	for i, e := range x.Entries {
+		if e.Message != want[i] || e.Offset != int64(want[i])*14 {
+			t.Errorf("Expected entry %d at message %d, got %+v", i, want[i], e)
+		}
+	}
+
+	read, err := ReadIndexFile(IndexPath(path))
+	if err != nil {
+		t.Fatalf("ReadIndexFile: %v", err)
+	}
+	if read.Size != x.Size || read.Messages != x.Messages || read.Options != x.Options || len(read.Entries) != len(x.Entries) {
+		t.Errorf("Expected the written index, got %+v", read)
+	}
+	if _, err := ReadIndex(bytes.NewReader([]byte("ITCHREC1"))); !errors.Is(err, ErrInvalidIndex) {
+		t.Errorf("Expected ErrInvalidIndex, got %v", err)
+	}
+}
+
+func TestIndex_Seek(t *testing.T) {
+	x := &Index{Entries: []IndexEntry{
+		{Offset: 0, Message: 0, Timestamp: 10},
+		{Offset: 140, Message: 10, Timestamp: 20},
+		{Offset: 280, Message: 20, Timestamp: 30},
+	}}
+	tests := []struct {
+		timestamp uint64
+		message   uint64
+	}{
+		{5, 0},
+		{10, 0},
+		{20, 0},
+		{21, 10},
+		{30, 10},
+		{100, 20},
+	}
+	for _, tt := range tests {
+		if e := x.Seek(tt.timestamp); e.Message != tt.message {
+			t.Errorf("Seek(%d): expected message %d, got %+v", tt.timestamp, tt.message, e)
+		}
+	}
+}
+
+func TestParser_ParseFrom(t *testing.T) {
+	path := writeIndexedFile(t, 300)
+	x, err := BuildIndexFile(path, IndexOptions{Every: 50, Interval: -1})
+	if err != nil {
+		t.Fatalf("BuildIndexFile: %v", err)
+	}
+	startTime := uint64(9*time.Hour+30*time.Minute) + 123*uint64(time.Second)
+
+	for _, index := range []*Index{x, nil} {
+		handler := &TestHandler{}
+		parser := NewParser(handler)
+		count, err := parser.ParseFrom(path, index, startTime)
+		if err != nil {
+			t.Fatalf("ParseFrom: %v", err)
+		}
+		if count != 177 || len(handler.systemEvents) != 177 {
+			t.Fatalf("Expected 177 messages, got %d", count)
+		}
+		if handler.systemEvents[0].Timestamp != startTime {
+			t.Errorf("Expected the first message at %d, got %d", startTime, handler.systemEvents[0].Timestamp)
+		}
+		if parser.MessageIndex() != 300 || parser.Offset() != 300*14 {
+			t.Errorf("Expected file positions, got message %d at %d", parser.MessageIndex(), parser.Offset())
+		}
+	}
+
+	// An index of another file is refused
+	stale := *x
+	stale.Size++
+	if _, err := NewParser(&TestHandler{}).ParseFrom(path, &stale, startTime); !errors.Is(err, ErrStaleIndex) {
+		t.Errorf("Expected ErrStaleIndex, got %v", err)
+	}
+}
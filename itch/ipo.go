@@ -0,0 +1,231 @@
+package itch
+
+import (
+	"sort"
+	"time"
+)
+
+// IPO release qualifiers carried by IPOQuotingMessage
+const (
+	// IPOReleaseAnticipated is an anticipated quotation release time
+	IPOReleaseAnticipated = 'A'
+	// IPOReleaseCanceled is a canceled or postponed IPO release
+	IPOReleaseCanceled = 'C'
+)
+
+// Trading states carried by StockTradingActionMessage
+const (
+	// TradingStateHalted is a halt across all U.S. equity markets
+	TradingStateHalted = 'H'
+	// TradingStatePaused is a Nasdaq-only pause
+	TradingStatePaused = 'P'
+	// TradingStateQuotation is a quotation only period
+	TradingStateQuotation = 'Q'
+	// TradingStateTrading is trading on Nasdaq
+	TradingStateTrading = 'T'
+)
+
+// IPOSchedule is the quoting period of an IPO stock
+type IPOSchedule struct {
+	// Stock is the trimmed stock symbol
+	Stock string
+	// StockLocate is the locate code of the stock
+	StockLocate uint16
+	// Updated is nanoseconds since midnight of the last IPO quoting message
+	Updated uint64
+	// ReleaseTime is the quotation release time in seconds since midnight
+	ReleaseTime uint32
+	// Price is the IPO price (4 implied decimals)
+	Price uint32
+	// Canceled is true if the release was canceled or postponed
+	Canceled bool
+	// Released is true once quoting has begun
+	Released bool
+	// ReleasedAt is nanoseconds since midnight of the message that released
+	// the stock
+	ReleasedAt uint64
+}
+
+// ReleaseTimestamp returns the release time in nanoseconds since midnight
+func (s IPOSchedule) ReleaseTimestamp() uint64 {
+	return uint64(s.ReleaseTime) * uint64(time.Second)
+}
+
+// IsPending returns true if the stock is waiting for its release
+func (s IPOSchedule) IsPending() bool {
+	return !s.Canceled && !s.Released
+}
+
+// IPOTracker maintains the IPO release schedules announced by IPO Quoting
+// Period Update messages and reports when quoting begins. A stock is released
+// when the feed reaches its release time, or earlier if it enters a quotation
+// or trading state.
+//
+// The feed time is taken from the messages the tracker handles; Advance can
+// be called with the timestamps of other messages to release stocks without
+// waiting for the next one.
+type IPOTracker struct {
+	DefaultHandler
+
+	// date is midnight of the trading day in the exchange time zone
+	date      time.Time
+	schedules map[string]*IPOSchedule
+	// next is the earliest pending release timestamp, valid if hasNext
+	next    uint64
+	hasNext bool
+
+	// OnUpdate is called when a schedule is announced, changed or canceled
+	// (optional)
+	OnUpdate func(s IPOSchedule)
+	// OnRelease is called once when quoting of a stock begins (optional)
+	OnRelease func(s IPOSchedule)
+}
+
+// NewIPOTracker creates a new IPO tracker for the trading day of date. The
+// location of date must be the exchange time zone, as ITCH times are
+// relative to its midnight.
+func NewIPOTracker(date time.Time) *IPOTracker {
+	return &IPOTracker{
+		date:      time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location()),
+		schedules: make(map[string]*IPOSchedule),
+	}
+}
+
+// WallTime converts nanoseconds since midnight into a time of the trading day
+func (h *IPOTracker) WallTime(timestamp uint64) time.Time {
+	return h.date.Add(time.Duration(timestamp))
+}
+
+// ReleaseWallTime returns the release time of a schedule as a time of the
+// trading day
+func (h *IPOTracker) ReleaseWallTime(s IPOSchedule) time.Time {
+	return h.WallTime(s.ReleaseTimestamp())
+}
+
+// OnIPOQuoting records a new, changed or canceled release schedule
+func (h *IPOTracker) OnIPOQuoting(msg IPOQuotingMessage) error {
+	stock := msg.Stock.Trimmed()
+	s, ok := h.schedules[stock]
+	if !ok {
+		s = &IPOSchedule{Stock: stock}
+		h.schedules[stock] = s
+	}
+	canceled := msg.IPOReleaseQualifier == IPOReleaseCanceled
+	// A cancellation or a new release time reschedules a released stock
+	if canceled || msg.IPOReleaseTime != s.ReleaseTime {
+		s.Released = false
+		s.ReleasedAt = 0
+	}
+	s.StockLocate = msg.StockLocate
+	s.Updated = msg.Timestamp
+	s.ReleaseTime = msg.IPOReleaseTime
+	s.Price = msg.IPOPrice
+	s.Canceled = canceled
+
+	h.reschedule()
+	if h.OnUpdate != nil {
+		h.OnUpdate(*s)
+	}
+	h.Advance(msg.Timestamp)
+	return nil
+}
+
+// OnStockTradingAction releases a pending stock entering a quotation or
+// trading state
+func (h *IPOTracker) OnStockTradingAction(msg StockTradingActionMessage) error {
+	if msg.TradingState == TradingStateQuotation || msg.TradingState == TradingStateTrading {
+		if s, ok := h.schedules[msg.Stock.Trimmed()]; ok && s.IsPending() {
+			h.release(s, msg.Timestamp)
+			h.reschedule()
+		}
+	}
+	h.Advance(msg.Timestamp)
+	return nil
+}
+
+// OnSystemEvent advances the feed time
+func (h *IPOTracker) OnSystemEvent(msg SystemEventMessage) error {
+	h.Advance(msg.Timestamp)
+	return nil
+}
+
+// OnAddOrder advances the feed time
+func (h *IPOTracker) OnAddOrder(msg AddOrderMessage) error {
+	h.Advance(msg.Timestamp)
+	return nil
+}
+
+// OnAddOrderMPID advances the feed time
+func (h *IPOTracker) OnAddOrderMPID(msg AddOrderMPIDMessage) error {
+	h.Advance(msg.Timestamp)
+	return nil
+}
+
+// Advance releases every pending stock whose release time is at or before
+// timestamp, in release time order
+func (h *IPOTracker) Advance(timestamp uint64) {
+	if !h.hasNext || timestamp < h.next {
+		return
+	}
+	for _, s := range h.sorted(func(s *IPOSchedule) bool {
+		return s.IsPending() && s.ReleaseTimestamp() <= timestamp
+	}) {
+		h.release(h.schedules[s.Stock], timestamp)
+	}
+	h.reschedule()
+}
+
+// release marks a stock as released and reports it
+func (h *IPOTracker) release(s *IPOSchedule, timestamp uint64) {
+	s.Released = true
+	s.ReleasedAt = timestamp
+	if h.OnRelease != nil {
+		h.OnRelease(*s)
+	}
+}
+
+// reschedule recomputes the earliest pending release timestamp
+func (h *IPOTracker) reschedule() {
+	h.hasNext = false
+	for _, s := range h.schedules {
+		if t := s.ReleaseTimestamp(); s.IsPending() && (!h.hasNext || t < h.next) {
+			h.next, h.hasNext = t, true
+		}
+	}
+}
+
+// Schedule returns the release schedule of a stock
+func (h *IPOTracker) Schedule(stock string) (IPOSchedule, bool) {
+	s, ok := h.schedules[stock]
+	if !ok {
+		return IPOSchedule{}, false
+	}
+	return *s, true
+}
+
+// Pending returns the schedules waiting for release, sorted by release time
+func (h *IPOTracker) Pending() []IPOSchedule {
+	return h.sorted(func(s *IPOSchedule) bool { return s.IsPending() })
+}
+
+// All returns every schedule, sorted by release time
+func (h *IPOTracker) All() []IPOSchedule {
+	return h.sorted(func(s *IPOSchedule) bool { return true })
+}
+
+// sorted returns the matching schedules sorted by release time and stock
+func (h *IPOTracker) sorted(match func(s *IPOSchedule) bool) []IPOSchedule {
+	result := make([]IPOSchedule, 0)
+	for _, s := range h.schedules {
+		if match(s) {
+			result = append(result, *s)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ReleaseTime != result[j].ReleaseTime {
+			return result[i].ReleaseTime < result[j].ReleaseTime
+		}
+		return result[i].Stock < result[j].Stock
+	})
+	return result
+}
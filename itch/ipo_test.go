@@ -0,0 +1,75 @@
+package itch
+
+import (
+	"testing"
+	"time"
+)
+
+func ipoMessage(stock string, timestamp uint64, release uint32, qualifier byte, price uint32) IPOQuotingMessage {
+	return IPOQuotingMessage{
+		Type:                MessageTypeIPOQuoting,
+		StockLocate:         1,
+		Timestamp:           timestamp,
+		Stock:               stockField(stock),
+		IPOReleaseTime:      release,
+		IPOReleaseQualifier: qualifier,
+		IPOPrice:            price,
+	}
+}
+
+func TestIPOTracker(t *testing.T) {
+	ny := time.FixedZone("EST", -5*3600)
+	h := NewIPOTracker(time.Date(2024, 3, 21, 15, 0, 0, 0, ny))
+	var updates, releases []IPOSchedule
+	h.OnUpdate = func(s IPOSchedule) { updates = append(updates, s) }
+	h.OnRelease = func(s IPOSchedule) { releases = append(releases, s) }
+
+	sec := uint64(time.Second)
+	// RDDT releases at 11:00, ALAB at 10:30 then postponed, ZZZZ on a trading action
+	h.OnIPOQuoting(ipoMessage("RDDT", 7*3600*sec, 11*3600, IPOReleaseAnticipated, 340000))
+	h.OnIPOQuoting(ipoMessage("ALAB", 7*3600*sec, 10*3600+1800, IPOReleaseAnticipated, 360000))
+	h.OnIPOQuoting(ipoMessage("ZZZZ", 7*3600*sec, 12*3600, IPOReleaseAnticipated, 100000))
+
+	if pending := h.Pending(); len(pending) != 3 || pending[0].Stock != "ALAB" || pending[2].Stock != "ZZZZ" {
+		t.Fatalf("Expected ALAB, RDDT and ZZZZ pending, got %+v", pending)
+	}
+	s, _ := h.Schedule("RDDT")
+	if want := time.Date(2024, 3, 21, 11, 0, 0, 0, ny); !h.ReleaseWallTime(s).Equal(want) {
+		t.Errorf("Expected release at %v, got %v", want, h.ReleaseWallTime(s))
+	}
+
+	h.OnIPOQuoting(ipoMessage("ALAB", 9*3600*sec, 10*3600+1800, IPOReleaseCanceled, 360000))
+	h.OnAddOrder(AddOrderMessage{Timestamp: 10*3600*sec + 1800*sec})
+	if len(releases) != 0 {
+		t.Fatalf("Expected no release before 11:00, got %+v", releases)
+	}
+
+	h.OnStockTradingAction(StockTradingActionMessage{Timestamp: 10*3600*sec + 1900*sec, Stock: stockField("ZZZZ"), TradingState: TradingStateQuotation})
+	h.OnAddOrderMPID(AddOrderMPIDMessage{Timestamp: 11*3600*sec + 5})
+	// Released stocks fire once
+	h.Advance(12 * 3600 * sec)
+	h.OnIPOQuoting(ipoMessage("RDDT", 12*3600*sec, 11*3600, IPOReleaseAnticipated, 340000))
+
+	if len(releases) != 2 || releases[0].Stock != "ZZZZ" || releases[1].Stock != "RDDT" {
+		t.Fatalf("Expected ZZZZ then RDDT released, got %+v", releases)
+	}
+	if releases[1].ReleasedAt != 11*3600*sec+5 || releases[1].Price != 340000 {
+		t.Errorf("Expected RDDT released at 11:00 plus 5ns, got %+v", releases[1])
+	}
+	if len(updates) != 5 || !updates[3].Canceled {
+		t.Errorf("Expected 5 updates with ALAB cancellation, got %+v", updates)
+	}
+	if s, _ := h.Schedule("ALAB"); s.IsPending() || s.Released {
+		t.Errorf("Expected ALAB canceled, got %+v", s)
+	}
+	if len(h.Pending()) != 0 || len(h.All()) != 3 {
+		t.Errorf("Expected no pending of 3 schedules, got %d of %d", len(h.Pending()), len(h.All()))
+	}
+
+	// A new release time reschedules a canceled stock
+	h.OnIPOQuoting(ipoMessage("ALAB", 12*3600*sec, 13*3600, IPOReleaseAnticipated, 360000))
+	h.Advance(13 * 3600 * sec)
+	if len(releases) != 3 || releases[2].Stock != "ALAB" {
+		t.Errorf("Expected ALAB released, got %+v", releases)
+	}
+}
@@ -0,0 +1,133 @@
+package itch
+
+import "sort"
+
+// MPIDStats is the order flow summary of a market participant
+type MPIDStats struct {
+	// MPID is the trimmed market participant ID
+	MPID string
+
+	// AddOrders is the number of attributed orders added
+	AddOrders uint64
+	// AddedShares is the number of shares added
+	AddedShares uint64
+	// Cancels is the number of partial cancels
+	Cancels uint64
+	// Deletes is the number of order deletes
+	Deletes uint64
+	// Replaces is the number of order replaces
+	Replaces uint64
+	// Executions is the number of executions against the participant's orders
+	Executions uint64
+	// ExecutedVolume is the number of shares executed
+	ExecutedVolume uint64
+}
+
+// CancelRatio returns the number of cancels and deletes per added order,
+// or 0 if no order was added
+func (s MPIDStats) CancelRatio() float64 {
+	if s.AddOrders == 0 {
+		return 0
+	}
+	return float64(s.Cancels+s.Deletes) / float64(s.AddOrders)
+}
+
+// ParticipantStats is a handler that aggregates order flow per market
+// participant from orders added with MPID attribution. Anonymous orders are
+// ignored. Replaced orders keep the attribution of the original order.
+type ParticipantStats struct {
+	DefaultHandler
+
+	tracker orderTracker
+	stats   map[string]*MPIDStats
+}
+
+// NewParticipantStats creates a new per-participant statistics handler
+func NewParticipantStats() *ParticipantStats {
+	return &ParticipantStats{
+		tracker: newOrderTracker(),
+		stats:   make(map[string]*MPIDStats),
+	}
+}
+
+// get returns the statistics for an MPID, creating them if necessary
+func (h *ParticipantStats) get(mpid string) *MPIDStats {
+	s, ok := h.stats[mpid]
+	if !ok {
+		s = &MPIDStats{MPID: mpid}
+		h.stats[mpid] = s
+	}
+	return s
+}
+
+// Stats returns the statistics for a market participant
+func (h *ParticipantStats) Stats(mpid string) (MPIDStats, bool) {
+	s, ok := h.stats[mpid]
+	if !ok {
+		return MPIDStats{}, false
+	}
+	return *s, true
+}
+
+// All returns the statistics of every participant, sorted by MPID
+func (h *ParticipantStats) All() []MPIDStats {
+	all := make([]MPIDStats, 0, len(h.stats))
+	for _, s := range h.stats {
+		all = append(all, *s)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].MPID < all[j].MPID })
+	return all
+}
+
+// OnAddOrderMPID counts the order and starts tracking it
+func (h *ParticipantStats) OnAddOrderMPID(msg AddOrderMPIDMessage) error {
+	h.tracker.addMPID(msg)
+	s := h.get(trimMPID(msg.Attribution))
+	s.AddOrders++
+	s.AddedShares += uint64(msg.Shares)
+	return nil
+}
+
+// OnOrderExecuted counts the execution against an attributed order
+func (h *ParticipantStats) OnOrderExecuted(msg OrderExecutedMessage) error {
+	if order, ok := h.tracker.execute(msg.OrderReferenceNumber, msg.ExecutedShares); ok {
+		s := h.get(order.mpid)
+		s.Executions++
+		s.ExecutedVolume += uint64(msg.ExecutedShares)
+	}
+	return nil
+}
+
+// OnOrderExecutedWithPrice counts the execution against an attributed order
+func (h *ParticipantStats) OnOrderExecutedWithPrice(msg OrderExecutedWithPriceMessage) error {
+	if order, ok := h.tracker.execute(msg.OrderReferenceNumber, msg.ExecutedShares); ok {
+		s := h.get(order.mpid)
+		s.Executions++
+		s.ExecutedVolume += uint64(msg.ExecutedShares)
+	}
+	return nil
+}
+
+// OnOrderCancel counts the partial cancel of an attributed order
+func (h *ParticipantStats) OnOrderCancel(msg OrderCancelMessage) error {
+	if order, ok := h.tracker.cancel(msg.OrderReferenceNumber, msg.CanceledShares); ok {
+		h.get(order.mpid).Cancels++
+	}
+	return nil
+}
+
+// OnOrderDelete counts the delete of an attributed order
+func (h *ParticipantStats) OnOrderDelete(msg OrderDeleteMessage) error {
+	if order, ok := h.tracker.delete(msg.OrderReferenceNumber); ok {
+		h.get(order.mpid).Deletes++
+	}
+	return nil
+}
+
+// OnOrderReplace counts the replace of an attributed order
+func (h *ParticipantStats) OnOrderReplace(msg OrderReplaceMessage) error {
+	if order, ok := h.tracker.replace(msg.OriginalOrderReferenceNumber, msg.NewOrderReferenceNumber, msg.Shares, msg.Price); ok {
+		h.get(order.mpid).Replaces++
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package itch
+
+import "testing"
+
+func TestParticipantStats(t *testing.T) {
+	h := NewParticipantStats()
+	stock := stockField("AAPL")
+
+	h.OnAddOrderMPID(AddOrderMPIDMessage{StockLocate: 1, OrderReferenceNumber: 1, BuySellIndicator: 'B', Shares: 100, Stock: stock, Price: 1000000, Attribution: MPIDField("GSCO")})
+	h.OnAddOrderMPID(AddOrderMPIDMessage{StockLocate: 1, OrderReferenceNumber: 2, BuySellIndicator: 'S', Shares: 200, Stock: stock, Price: 1010000, Attribution: MPIDField("GSCO")})
+	h.OnAddOrderMPID(AddOrderMPIDMessage{StockLocate: 1, OrderReferenceNumber: 3, BuySellIndicator: 'S', Shares: 50, Stock: stock, Price: 1020000, Attribution: MPIDField("MS")})
+	h.OnAddOrder(AddOrderMessage{StockLocate: 1, OrderReferenceNumber: 4, BuySellIndicator: 'B', Shares: 500, Stock: stock, Price: 990000})
+
+	h.OnOrderExecuted(OrderExecutedMessage{StockLocate: 1, OrderReferenceNumber: 1, ExecutedShares: 40, MatchNumber: 1})
+	h.OnOrderExecutedWithPrice(OrderExecutedWithPriceMessage{StockLocate: 1, OrderReferenceNumber: 1, ExecutedShares: 60, MatchNumber: 2, ExecutionPrice: 1000100})
+	h.OnOrderCancel(OrderCancelMessage{StockLocate: 1, OrderReferenceNumber: 2, CanceledShares: 100})
+	h.OnOrderReplace(OrderReplaceMessage{StockLocate: 1, OriginalOrderReferenceNumber: 2, NewOrderReferenceNumber: 5, Shares: 100, Price: 1005000})
+	h.OnOrderExecuted(OrderExecutedMessage{StockLocate: 1, OrderReferenceNumber: 5, ExecutedShares: 25, MatchNumber: 3})
+	h.OnOrderDelete(OrderDeleteMessage{StockLocate: 1, OrderReferenceNumber: 3})
+	h.OnOrderDelete(OrderDeleteMessage{StockLocate: 1, OrderReferenceNumber: 4})
+
+	all := h.All()
+	if len(all) != 2 || all[0].MPID != "GSCO" || all[1].MPID != "MS" {
+		t.Fatalf("Expected GSCO and MS, got %+v", all)
+	}
+
+	gsco, _ := h.Stats("GSCO")
+	expected := MPIDStats{MPID: "GSCO", AddOrders: 2, AddedShares: 300, Cancels: 1, Replaces: 1, Executions: 3, ExecutedVolume: 125}
+	if gsco != expected {
+		t.Errorf("Expected %+v, got %+v", expected, gsco)
+	}
+	if ratio := gsco.CancelRatio(); ratio != 0.5 {
+		t.Errorf("Expected GSCO cancel ratio 0.5, got %f", ratio)
+	}
+
+	ms, _ := h.Stats("MS")
+	if ms.AddOrders != 1 || ms.Deletes != 1 || ms.CancelRatio() != 1 {
+		t.Errorf("Expected MS with 1 add and 1 delete, got %+v", ms)
+	}
+
+	if _, ok := h.Stats(""); ok {
+		t.Error("Expected anonymous orders to be ignored")
+	}
+}
@@ -0,0 +1,179 @@
+package itch
+
+import "sort"
+
+// Market maker modes carried by MarketParticipantPositionMessage
+const (
+	// MarketMakerModeNormal is a market maker in normal mode
+	MarketMakerModeNormal = 'N'
+	// MarketMakerModePassive is a passive market maker
+	MarketMakerModePassive = 'P'
+	// MarketMakerModeSyndicate is a syndicate market maker
+	MarketMakerModeSyndicate = 'S'
+	// MarketMakerModePresyndicate is a pre-syndicate market maker
+	MarketMakerModePresyndicate = 'R'
+	// MarketMakerModePenalty is a market maker in penalty mode
+	MarketMakerModePenalty = 'L'
+)
+
+// Market participant states carried by MarketParticipantPositionMessage
+const (
+	// ParticipantStateActive is an active market participant
+	ParticipantStateActive = 'A'
+	// ParticipantStateExcused is an excused or withdrawn market participant
+	ParticipantStateExcused = 'E'
+	// ParticipantStateWithdrawn is a withdrawn market participant
+	ParticipantStateWithdrawn = 'W'
+	// ParticipantStateSuspended is a suspended market participant
+	ParticipantStateSuspended = 'S'
+	// ParticipantStateDeleted is a deleted market participant
+	ParticipantStateDeleted = 'D'
+)
+
+// MarketMakerPosition is the current registration of a market participant
+// in a stock
+type MarketMakerPosition struct {
+	// MPID is the trimmed market participant ID
+	MPID string
+	// Stock is the trimmed stock symbol
+	Stock string
+	// StockLocate is the locate code of the stock
+	StockLocate uint16
+	// Timestamp is nanoseconds since midnight of the last position message
+	Timestamp uint64
+	// Primary is true if the participant is the primary market maker
+	Primary bool
+	// Mode is the market maker mode (MarketMakerMode*)
+	Mode byte
+	// State is the market participant state (ParticipantState*)
+	State byte
+}
+
+// IsActive returns true if the participant is actively quoting the stock
+func (p MarketMakerPosition) IsActive() bool {
+	return p.State == ParticipantStateActive
+}
+
+// PositionChange is a change of a market participant position
+type PositionChange struct {
+	// Previous is the position before the change, the zero value if New
+	Previous MarketMakerPosition
+	// Current is the position after the change
+	Current MarketMakerPosition
+	// New is true for the first position message of the (MPID, stock) pair
+	New bool
+}
+
+// positionKey identifies the position of a participant in a stock
+type positionKey struct {
+	mpid  string
+	stock string
+}
+
+// PositionTracker maintains the current market maker state per (MPID, stock)
+// from Market Participant Position messages. Nasdaq sends a message for every
+// registration at the start of the day and whenever a registration changes.
+type PositionTracker struct {
+	DefaultHandler
+
+	positions map[positionKey]*MarketMakerPosition
+
+	// OnChange is called when a position is first seen or its primary flag,
+	// mode or state changes (optional)
+	OnChange func(c PositionChange)
+}
+
+// NewPositionTracker creates a new market participant position tracker
+func NewPositionTracker() *PositionTracker {
+	return &PositionTracker{
+		positions: make(map[positionKey]*MarketMakerPosition),
+	}
+}
+
+// OnMarketParticipantPosition updates the position of the participant
+func (h *PositionTracker) OnMarketParticipantPosition(msg MarketParticipantPositionMessage) error {
+	current := MarketMakerPosition{
+		MPID:        trimMPID(msg.MPID),
+		Stock:       msg.Stock.Trimmed(),
+		StockLocate: msg.StockLocate,
+		Timestamp:   msg.Timestamp,
+		Primary:     msg.PrimaryMarketMaker == 'Y',
+		Mode:        msg.MarketMakerMode,
+		State:       msg.MarketParticipantState,
+	}
+	key := positionKey{mpid: current.MPID, stock: current.Stock}
+
+	position, ok := h.positions[key]
+	if !ok {
+		position = &MarketMakerPosition{}
+		h.positions[key] = position
+	}
+	previous := *position
+	*position = current
+
+	changed := !ok || previous.Primary != current.Primary || previous.Mode != current.Mode || previous.State != current.State
+	if changed && h.OnChange != nil {
+		h.OnChange(PositionChange{Previous: previous, Current: current, New: !ok})
+	}
+	return nil
+}
+
+// Position returns the current position of a participant in a stock
+func (h *PositionTracker) Position(mpid, stock string) (MarketMakerPosition, bool) {
+	position, ok := h.positions[positionKey{mpid: mpid, stock: stock}]
+	if !ok {
+		return MarketMakerPosition{}, false
+	}
+	return *position, true
+}
+
+// MarketMakers returns the positions of every participant registered in a
+// stock, sorted by MPID
+func (h *PositionTracker) MarketMakers(stock string) []MarketMakerPosition {
+	return h.filter(func(p *MarketMakerPosition) bool { return p.Stock == stock })
+}
+
+// ActiveMarketMakers returns the positions of the participants actively
+// quoting a stock, sorted by MPID
+func (h *PositionTracker) ActiveMarketMakers(stock string) []MarketMakerPosition {
+	return h.filter(func(p *MarketMakerPosition) bool { return p.Stock == stock && p.IsActive() })
+}
+
+// PrimaryMarketMaker returns the position of the primary market maker of a
+// stock, if any
+func (h *PositionTracker) PrimaryMarketMaker(stock string) (MarketMakerPosition, bool) {
+	for _, p := range h.positions {
+		if p.Stock == stock && p.Primary {
+			return *p, true
+		}
+	}
+	return MarketMakerPosition{}, false
+}
+
+// Registrations returns the positions of a participant in every stock,
+// sorted by stock symbol
+func (h *PositionTracker) Registrations(mpid string) []MarketMakerPosition {
+	return h.filter(func(p *MarketMakerPosition) bool { return p.MPID == mpid })
+}
+
+// All returns every position, sorted by stock symbol and MPID
+func (h *PositionTracker) All() []MarketMakerPosition {
+	return h.filter(func(p *MarketMakerPosition) bool { return true })
+}
+
+// filter returns the matching positions sorted by stock symbol and MPID
+func (h *PositionTracker) filter(match func(p *MarketMakerPosition) bool) []MarketMakerPosition {
+	result := make([]MarketMakerPosition, 0)
+	for _, p := range h.positions {
+		if match(p) {
+			result = append(result, *p)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Stock != result[j].Stock {
+			return result[i].Stock < result[j].Stock
+		}
+		return result[i].MPID < result[j].MPID
+	})
+	return result
+}
@@ -0,0 +1,66 @@
+package itch
+
+import "testing"
+
+func positionMessage(mpid, stock string, primary, mode, state byte) MarketParticipantPositionMessage {
+	return MarketParticipantPositionMessage{
+		Type:                   'L',
+		StockLocate:            1,
+		MPID:                   MPIDField(mpid),
+		Stock:                  stockField(stock),
+		PrimaryMarketMaker:     primary,
+		MarketMakerMode:        mode,
+		MarketParticipantState: state,
+	}
+}
+
+func TestPositionTracker(t *testing.T) {
+	h := NewPositionTracker()
+	var changes []PositionChange
+	h.OnChange = func(c PositionChange) { changes = append(changes, c) }
+
+	h.OnMarketParticipantPosition(positionMessage("GSCO", "AAPL", 'Y', MarketMakerModeNormal, ParticipantStateActive))
+	h.OnMarketParticipantPosition(positionMessage("MS", "AAPL", 'N', MarketMakerModeNormal, ParticipantStateActive))
+	h.OnMarketParticipantPosition(positionMessage("MS", "MSFT", 'N', MarketMakerModePassive, ParticipantStateActive))
+	// A repeated message is not a change
+	h.OnMarketParticipantPosition(positionMessage("MS", "AAPL", 'N', MarketMakerModeNormal, ParticipantStateActive))
+	h.OnMarketParticipantPosition(positionMessage("MS", "AAPL", 'N', MarketMakerModeNormal, ParticipantStateSuspended))
+
+	if len(changes) != 4 {
+		t.Fatalf("Expected 4 changes, got %d: %+v", len(changes), changes)
+	}
+	if !changes[0].New || changes[0].Current.MPID != "GSCO" || !changes[0].Current.Primary {
+		t.Errorf("Expected new primary GSCO position, got %+v", changes[0])
+	}
+	last := changes[3]
+	if last.New || last.Previous.State != ParticipantStateActive || last.Current.State != ParticipantStateSuspended {
+		t.Errorf("Expected MS suspension, got %+v", last)
+	}
+
+	position, ok := h.Position("MS", "AAPL")
+	if !ok || position.IsActive() || position.Mode != MarketMakerModeNormal {
+		t.Errorf("Expected suspended MS position in AAPL, got %+v", position)
+	}
+	if _, ok := h.Position("MS", "TSLA"); ok {
+		t.Errorf("Expected no MS position in TSLA")
+	}
+
+	if makers := h.MarketMakers("AAPL"); len(makers) != 2 || makers[0].MPID != "GSCO" || makers[1].MPID != "MS" {
+		t.Errorf("Expected GSCO and MS in AAPL, got %+v", makers)
+	}
+	if active := h.ActiveMarketMakers("AAPL"); len(active) != 1 || active[0].MPID != "GSCO" {
+		t.Errorf("Expected only GSCO active in AAPL, got %+v", active)
+	}
+	if primary, ok := h.PrimaryMarketMaker("AAPL"); !ok || primary.MPID != "GSCO" {
+		t.Errorf("Expected GSCO as AAPL primary market maker, got %+v", primary)
+	}
+	if _, ok := h.PrimaryMarketMaker("MSFT"); ok {
+		t.Errorf("Expected no MSFT primary market maker")
+	}
+	if regs := h.Registrations("MS"); len(regs) != 2 || regs[0].Stock != "AAPL" || regs[1].Stock != "MSFT" {
+		t.Errorf("Expected MS registered in AAPL and MSFT, got %+v", regs)
+	}
+	if all := h.All(); len(all) != 3 {
+		t.Errorf("Expected 3 positions, got %d", len(all))
+	}
+}
@@ -0,0 +1,218 @@
+package itch
+
+import (
+	"math"
+	"sort"
+)
+
+// QualityStats is the execution quality of the trades of a stock, measured
+// against the best bid and ask of the book rebuilt from the feed just before
+// each trade. A single feed stands in for the NBBO, so the quote is an
+// approximation; the executions of an order sweeping several levels are each
+// measured against the book left by the previous one.
+type QualityStats struct {
+	// Stock is the trimmed stock symbol
+	Stock string
+
+	// Trades and Shares count the printed trades and their shares
+	Trades uint64
+	Shares uint64
+	// Quoted counts the trades that met a two-sided, uncrossed book. Trades
+	// without such a quote are not measured.
+	Quoted       uint64
+	QuotedShares uint64
+	// AtQuote, InsideQuote and OutsideQuote count the quoted trades at the
+	// best bid or ask, strictly between them, and beyond them
+	AtQuote      uint64
+	InsideQuote  uint64
+	OutsideQuote uint64
+
+	// Share-weighted sums over the quoted trades, in price units (4 implied
+	// decimals): twice the distance to the midpoint, the same relative to
+	// the midpoint in basis points, and the improvement over the quote the
+	// trade took from (negative when outside)
+	EffectiveSpreadSum    float64
+	EffectiveSpreadBpsSum float64
+	ImprovementSum        float64
+}
+
+// EffectiveSpread returns the share-weighted average effective spread in
+// price units (4 implied decimals)
+func (s *QualityStats) EffectiveSpread() float64 {
+	return s.perQuotedShare(s.EffectiveSpreadSum)
+}
+
+// EffectiveSpreadBps returns the share-weighted average effective spread in
+// basis points of the midpoint
+func (s *QualityStats) EffectiveSpreadBps() float64 {
+	return s.perQuotedShare(s.EffectiveSpreadBpsSum)
+}
+
+// PriceImprovement returns the share-weighted average price improvement per
+// share in price units (4 implied decimals)
+func (s *QualityStats) PriceImprovement() float64 {
+	return s.perQuotedShare(s.ImprovementSum)
+}
+
+// AtQuotePercent returns the percentage of quoted trades at the quote
+func (s *QualityStats) AtQuotePercent() float64 {
+	return s.percentOfQuoted(s.AtQuote)
+}
+
+// InsideQuotePercent returns the percentage of quoted trades inside the quote
+func (s *QualityStats) InsideQuotePercent() float64 {
+	return s.percentOfQuoted(s.InsideQuote)
+}
+
+// OutsideQuotePercent returns the percentage of quoted trades outside the
+// quote
+func (s *QualityStats) OutsideQuotePercent() float64 {
+	return s.percentOfQuoted(s.OutsideQuote)
+}
+
+// Merge adds the statistics of o, e.g. from another day of the same stock
+func (s *QualityStats) Merge(o QualityStats) {
+	s.Trades += o.Trades
+	s.Shares += o.Shares
+	s.Quoted += o.Quoted
+	s.QuotedShares += o.QuotedShares
+	s.AtQuote += o.AtQuote
+	s.InsideQuote += o.InsideQuote
+	s.OutsideQuote += o.OutsideQuote
+	s.EffectiveSpreadSum += o.EffectiveSpreadSum
+	s.EffectiveSpreadBpsSum += o.EffectiveSpreadBpsSum
+	s.ImprovementSum += o.ImprovementSum
+}
+
+func (s *QualityStats) perQuotedShare(sum float64) float64 {
+	if s.QuotedShares == 0 {
+		return 0
+	}
+	return sum / float64(s.QuotedShares)
+}
+
+func (s *QualityStats) percentOfQuoted(n uint64) float64 {
+	if s.Quoted == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(s.Quoted)
+}
+
+// ExecutionQuality is an ITCH handler measuring the execution quality of
+// printed executions and non-cross trades against the prevailing quote of
+// the book it rebuilds. Cross trades are auctions and are not measured.
+type ExecutionQuality struct {
+	*BookBuilder
+
+	stats map[uint16]*QualityStats
+}
+
+// NewExecutionQuality creates an execution quality handler
+func NewExecutionQuality() *ExecutionQuality {
+	return &ExecutionQuality{
+		BookBuilder: NewBookBuilder(),
+		stats:       make(map[uint16]*QualityStats),
+	}
+}
+
+// Stats returns the execution quality of a stock
+func (h *ExecutionQuality) Stats(stock string) (QualityStats, bool) {
+	for _, s := range h.stats {
+		if s.Stock == stock {
+			return *s, true
+		}
+	}
+	return QualityStats{}, false
+}
+
+// All returns the execution quality of every traded stock ordered by symbol
+func (h *ExecutionQuality) All() []QualityStats {
+	all := make([]QualityStats, 0, len(h.stats))
+	for _, s := range h.stats {
+		all = append(all, *s)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Stock < all[j].Stock })
+	return all
+}
+
+// measure records a trade of shares at price in the book of locate. side is
+// the side of the resting order, so a resting bid marks a trade initiated by
+// a seller.
+func (h *ExecutionQuality) measure(locate uint16, side byte, shares, price uint32) {
+	s, ok := h.stats[locate]
+	if !ok {
+		s = &QualityStats{Stock: h.tracker.stock(locate)}
+		h.stats[locate] = s
+	}
+	s.Trades++
+	s.Shares += uint64(shares)
+
+	book := h.books[locate]
+	if book == nil {
+		return
+	}
+	bid, hasBid := bestLevel(book.bids, func(x, y uint32) bool { return x > y })
+	ask, hasAsk := bestLevel(book.asks, func(x, y uint32) bool { return x < y })
+	if !hasBid || !hasAsk || bid >= ask {
+		return
+	}
+
+	s.Quoted++
+	s.QuotedShares += uint64(shares)
+	switch {
+	case price == bid || price == ask:
+		s.AtQuote++
+	case price > bid && price < ask:
+		s.InsideQuote++
+	default:
+		s.OutsideQuote++
+	}
+
+	p := float64(price)
+	mid := (float64(bid) + float64(ask)) / 2
+	effective := 2 * math.Abs(p-mid)
+	s.EffectiveSpreadSum += effective * float64(shares)
+	s.EffectiveSpreadBpsSum += effective / mid * 1e4 * float64(shares)
+	improvement := p - float64(bid)
+	if side != 'B' {
+		// A buyer took the ask
+		improvement = float64(ask) - p
+	}
+	s.ImprovementSum += improvement * float64(shares)
+}
+
+// bestLevel returns the best price of one side of a book
+func bestLevel(levels map[uint32]*BookLevel, better func(x, y uint32) bool) (uint32, bool) {
+	var best uint32
+	found := false
+	for price := range levels {
+		if !found || better(price, best) {
+			best, found = price, true
+		}
+	}
+	return best, found
+}
+
+// OnOrderExecuted measures the execution and removes the shares from the book
+func (h *ExecutionQuality) OnOrderExecuted(msg OrderExecutedMessage) error {
+	if order, ok := h.tracker.orders[msg.OrderReferenceNumber]; ok {
+		h.measure(order.locate, order.side, msg.ExecutedShares, order.price)
+	}
+	return h.BookBuilder.OnOrderExecuted(msg)
+}
+
+// OnOrderExecutedWithPrice measures printable executions and removes the
+// shares from the book
+func (h *ExecutionQuality) OnOrderExecutedWithPrice(msg OrderExecutedWithPriceMessage) error {
+	if order, ok := h.tracker.orders[msg.OrderReferenceNumber]; ok && msg.Printable == 'Y' {
+		h.measure(order.locate, order.side, msg.ExecutedShares, msg.ExecutionPrice)
+	}
+	return h.BookBuilder.OnOrderExecutedWithPrice(msg)
+}
+
+// OnTrade measures a trade against a non-displayed order
+func (h *ExecutionQuality) OnTrade(msg TradeMessage) error {
+	h.tracker.register(msg.StockLocate, msg.Stock)
+	h.measure(msg.StockLocate, msg.BuySellIndicator, msg.Shares, msg.Price)
+	return nil
+}
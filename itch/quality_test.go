@@ -0,0 +1,63 @@
+package itch
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExecutionQuality(t *testing.T) {
+	h := NewExecutionQuality()
+	aapl, msft := stockField("AAPL"), stockField("MSFT")
+	h.OnStockDirectory(StockDirectoryMessage{StockLocate: 1, Stock: aapl})
+	h.OnAddOrder(AddOrderMessage{StockLocate: 1, OrderReferenceNumber: 1, BuySellIndicator: 'B', Shares: 100, Stock: aapl, Price: 1000})
+	h.OnAddOrder(AddOrderMessage{StockLocate: 1, OrderReferenceNumber: 2, BuySellIndicator: 'S', Shares: 100, Stock: aapl, Price: 1010})
+
+	// A seller hits the bid, at the quote
+	h.OnOrderExecuted(OrderExecutedMessage{StockLocate: 1, OrderReferenceNumber: 1, ExecutedShares: 30})
+	// A buyer lifts a hidden offer inside the quote
+	h.OnTrade(TradeMessage{StockLocate: 1, BuySellIndicator: 'S', Shares: 100, Stock: aapl, Price: 1006})
+	// A buyer pays through the ask, outside the quote
+	h.OnOrderExecutedWithPrice(OrderExecutedWithPriceMessage{StockLocate: 1, OrderReferenceNumber: 2, ExecutedShares: 20, Printable: 'Y', ExecutionPrice: 1012})
+	// Non-printable executions are counted elsewhere
+	h.OnOrderExecutedWithPrice(OrderExecutedWithPriceMessage{StockLocate: 1, OrderReferenceNumber: 2, ExecutedShares: 10, Printable: 'N', ExecutionPrice: 1012})
+	// No quote to measure against
+	h.OnTrade(TradeMessage{StockLocate: 2, BuySellIndicator: 'B', Shares: 50, Stock: msft, Price: 3000})
+
+	s, ok := h.Stats("AAPL")
+	if !ok {
+		t.Fatal("Expected AAPL statistics")
+	}
+	if s.Trades != 3 || s.Shares != 150 || s.Quoted != 3 {
+		t.Errorf("Expected 3 quoted trades of 150 shares, got %+v", s)
+	}
+	if s.AtQuote != 1 || s.InsideQuote != 1 || s.OutsideQuote != 1 {
+		t.Errorf("Expected one trade at, inside and outside the quote, got %+v", s)
+	}
+	approx := func(name string, got, want float64) {
+		t.Helper()
+		if math.Abs(got-want) > 1e-9 {
+			t.Errorf("Expected %s %v, got %v", name, want, got)
+		}
+	}
+	// (10*30 + 2*100 + 14*20) / 150
+	approx("effective spread", s.EffectiveSpread(), 5.2)
+	approx("effective spread bps", s.EffectiveSpreadBps(), 5.2/1005*1e4)
+	// (0*30 + 4*100 - 2*20) / 150
+	approx("price improvement", s.PriceImprovement(), 2.4)
+	approx("at quote percent", s.AtQuotePercent(), 100.0/3)
+
+	s, ok = h.Stats("MSFT")
+	if !ok || s.Trades != 1 || s.Quoted != 0 || s.EffectiveSpread() != 0 {
+		t.Errorf("Expected one unquoted MSFT trade, got %+v", s)
+	}
+
+	all := h.All()
+	if len(all) != 2 || all[0].Stock != "AAPL" || all[1].Stock != "MSFT" {
+		t.Fatalf("Expected AAPL and MSFT, got %+v", all)
+	}
+	merged := all[0]
+	merged.Merge(all[0])
+	if merged.Trades != 6 || merged.EffectiveSpread() != all[0].EffectiveSpread() {
+		t.Errorf("Expected merged averages unchanged, got %+v", merged)
+	}
+}
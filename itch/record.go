@@ -0,0 +1,323 @@
+package itch
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Recordings store handler invocations rather than raw ITCH, so downstream
+// consumers can be regression tested against the exact call sequence their
+// upstream produced. A recording starts with recordMagic; each call is the
+// message type byte followed by the message struct in little-endian fixed
+// size form. Unknown messages are written as recordUnknown, the message type,
+// a uvarint length and the raw data.
+const (
+	recordMagic   = "ITCHREC1"
+	recordUnknown = 0
+)
+
+// ErrInvalidRecording is returned when replaying data that is not a handler
+// recording
+var ErrInvalidRecording = errors.New("invalid handler recording")
+
+// RecordingHandler is handler middleware that writes every invocation to a
+// recording before passing it on to the next handler
+type RecordingHandler struct {
+	next  Handler
+	w     *bufio.Writer
+	buf   []byte
+	calls uint64
+	err   error
+}
+
+// NewRecordingHandler creates a handler recording to w and forwarding to
+// next, which may be nil. Flush must be called when recording is done.
+func NewRecordingHandler(w io.Writer, next Handler) *RecordingHandler {
+	if next == nil {
+		next = &DefaultHandler{}
+	}
+	h := &RecordingHandler{next: next, w: bufio.NewWriterSize(w, defaultStreamBufSize)}
+	_, h.err = h.w.WriteString(recordMagic)
+	return h
+}
+
+// Calls returns the number of invocations recorded
+func (h *RecordingHandler) Calls() uint64 {
+	return h.calls
+}
+
+// Flush writes buffered calls to the underlying writer
+func (h *RecordingHandler) Flush() error {
+	if h.err != nil {
+		return h.err
+	}
+	h.err = h.w.Flush()
+	return h.err
+}
+
+// write appends an encoded call to the recording. A write error is sticky
+// and returned from every later call, stopping the parser feeding h.
+func (h *RecordingHandler) write(call []byte) error {
+	if h.err != nil {
+		return h.err
+	}
+	if _, h.err = h.w.Write(call); h.err != nil {
+		return h.err
+	}
+	h.calls++
+	return nil
+}
+
+// recordCall records a message and forwards it with fn
+func recordCall[T any](h *RecordingHandler, msgType byte, msg T, fn func(T) error) error {
+	b, err := binary.Append(append(h.buf[:0], msgType), binary.LittleEndian, msg)
+	if err != nil {
+		return err
+	}
+	h.buf = b
+	if err := h.write(b); err != nil {
+		return err
+	}
+	return fn(msg)
+}
+
+func (h *RecordingHandler) OnSystemEvent(msg SystemEventMessage) error {
+	return recordCall(h, MessageTypeSystemEvent, msg, h.next.OnSystemEvent)
+}
+
+func (h *RecordingHandler) OnStockDirectory(msg StockDirectoryMessage) error {
+	return recordCall(h, MessageTypeStockDirectory, msg, h.next.OnStockDirectory)
+}
+
+func (h *RecordingHandler) OnStockTradingAction(msg StockTradingActionMessage) error {
+	return recordCall(h, MessageTypeStockTradingAction, msg, h.next.OnStockTradingAction)
+}
+
+func (h *RecordingHandler) OnRegSHO(msg RegSHOMessage) error {
+	return recordCall(h, MessageTypeRegSHO, msg, h.next.OnRegSHO)
+}
+
+func (h *RecordingHandler) OnMarketParticipantPosition(msg MarketParticipantPositionMessage) error {
+	return recordCall(h, MessageTypeMarketParticipantPos, msg, h.next.OnMarketParticipantPosition)
+}
+
+func (h *RecordingHandler) OnMWCBDecline(msg MWCBDeclineMessage) error {
+	return recordCall(h, MessageTypeMWCBDecline, msg, h.next.OnMWCBDecline)
+}
+
+func (h *RecordingHandler) OnMWCBStatus(msg MWCBStatusMessage) error {
+	return recordCall(h, MessageTypeMWCBStatus, msg, h.next.OnMWCBStatus)
+}
+
+func (h *RecordingHandler) OnIPOQuoting(msg IPOQuotingMessage) error {
+	return recordCall(h, MessageTypeIPOQuoting, msg, h.next.OnIPOQuoting)
+}
+
+func (h *RecordingHandler) OnAddOrder(msg AddOrderMessage) error {
+	return recordCall(h, MessageTypeAddOrder, msg, h.next.OnAddOrder)
+}
+
+func (h *RecordingHandler) OnAddOrderMPID(msg AddOrderMPIDMessage) error {
+	return recordCall(h, MessageTypeAddOrderMPID, msg, h.next.OnAddOrderMPID)
+}
+
+func (h *RecordingHandler) OnOrderExecuted(msg OrderExecutedMessage) error {
+	return recordCall(h, MessageTypeOrderExecuted, msg, h.next.OnOrderExecuted)
+}
+
+func (h *RecordingHandler) OnOrderExecutedWithPrice(msg OrderExecutedWithPriceMessage) error {
+	return recordCall(h, MessageTypeOrderExecutedWithPrice, msg, h.next.OnOrderExecutedWithPrice)
+}
+
+func (h *RecordingHandler) OnOrderCancel(msg OrderCancelMessage) error {
+	return recordCall(h, MessageTypeOrderCancel, msg, h.next.OnOrderCancel)
+}
+
+func (h *RecordingHandler) OnOrderDelete(msg OrderDeleteMessage) error {
+	return recordCall(h, MessageTypeOrderDelete, msg, h.next.OnOrderDelete)
+}
+
+func (h *RecordingHandler) OnOrderReplace(msg OrderReplaceMessage) error {
+	return recordCall(h, MessageTypeOrderReplace, msg, h.next.OnOrderReplace)
+}
+
+func (h *RecordingHandler) OnTrade(msg TradeMessage) error {
+	return recordCall(h, MessageTypeTrade, msg, h.next.OnTrade)
+}
+
+func (h *RecordingHandler) OnCrossTrade(msg CrossTradeMessage) error {
+	return recordCall(h, MessageTypeCrossTrade, msg, h.next.OnCrossTrade)
+}
+
+func (h *RecordingHandler) OnBrokenTrade(msg BrokenTradeMessage) error {
+	return recordCall(h, MessageTypeBrokenTrade, msg, h.next.OnBrokenTrade)
+}
+
+func (h *RecordingHandler) OnNOII(msg NOIIMessage) error {
+	return recordCall(h, MessageTypeNOII, msg, h.next.OnNOII)
+}
+
+func (h *RecordingHandler) OnRPII(msg RPIIMessage) error {
+	return recordCall(h, MessageTypeRPII, msg, h.next.OnRPII)
+}
+
+func (h *RecordingHandler) OnUnknownMessage(msgType byte, data []byte) error {
+	b := append(h.buf[:0], recordUnknown, msgType)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	h.buf = append(b, data...)
+	if err := h.write(h.buf); err != nil {
+		return err
+	}
+	return h.next.OnUnknownMessage(msgType, data)
+}
+
+// ReplayHandler re-invokes the calls of a recording against a handler, in
+// their recorded order
+type ReplayHandler struct {
+	r       *bufio.Reader
+	handler Handler
+	buf     []byte
+	calls   uint64
+	started bool
+}
+
+// NewReplayHandler creates a replay of the recording in r against handler
+func NewReplayHandler(r io.Reader, handler Handler) *ReplayHandler {
+	return &ReplayHandler{r: bufio.NewReaderSize(r, defaultStreamBufSize), handler: handler}
+}
+
+// Calls returns the number of calls replayed so far
+func (p *ReplayHandler) Calls() uint64 {
+	return p.calls
+}
+
+// Next replays the next call. io.EOF is returned at the end of the
+// recording, ErrInvalidRecording for data that is not a recording and
+// io.ErrUnexpectedEOF if it ends inside a call. Handler errors are returned
+// as is.
+func (p *ReplayHandler) Next() error {
+	if !p.started {
+		magic := make([]byte, len(recordMagic))
+		if _, err := io.ReadFull(p.r, magic); err != nil || string(magic) != recordMagic {
+			return ErrInvalidRecording
+		}
+		p.started = true
+	}
+	kind, err := p.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if err := p.replay(kind); err != nil {
+		return err
+	}
+	p.calls++
+	return nil
+}
+
+// Replay replays every remaining call and returns the number replayed
+func (p *ReplayHandler) Replay() (uint64, error) {
+	start := p.calls
+	for {
+		if err := p.Next(); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return p.calls - start, err
+		}
+	}
+}
+
+// replayCall decodes a message and invokes fn with it
+func replayCall[T any](p *ReplayHandler, fn func(T) error) error {
+	var msg T
+	if err := p.read(binary.Size(msg)); err != nil {
+		return err
+	}
+	if _, err := binary.Decode(p.buf, binary.LittleEndian, &msg); err != nil {
+		return err
+	}
+	return fn(msg)
+}
+
+// read reads the next n bytes of a call into p.buf
+func (p *ReplayHandler) read(n int) error {
+	if cap(p.buf) < n {
+		p.buf = make([]byte, n)
+	}
+	p.buf = p.buf[:n]
+	if _, err := io.ReadFull(p.r, p.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+// replay invokes the handler for a call of the given kind
+func (p *ReplayHandler) replay(kind byte) error {
+	h := p.handler
+	switch kind {
+	case MessageTypeSystemEvent:
+		return replayCall(p, h.OnSystemEvent)
+	case MessageTypeStockDirectory:
+		return replayCall(p, h.OnStockDirectory)
+	case MessageTypeStockTradingAction:
+		return replayCall(p, h.OnStockTradingAction)
+	case MessageTypeRegSHO:
+		return replayCall(p, h.OnRegSHO)
+	case MessageTypeMarketParticipantPos:
+		return replayCall(p, h.OnMarketParticipantPosition)
+	case MessageTypeMWCBDecline:
+		return replayCall(p, h.OnMWCBDecline)
+	case MessageTypeMWCBStatus:
+		return replayCall(p, h.OnMWCBStatus)
+	case MessageTypeIPOQuoting:
+		return replayCall(p, h.OnIPOQuoting)
+	case MessageTypeAddOrder:
+		return replayCall(p, h.OnAddOrder)
+	case MessageTypeAddOrderMPID:
+		return replayCall(p, h.OnAddOrderMPID)
+	case MessageTypeOrderExecuted:
+		return replayCall(p, h.OnOrderExecuted)
+	case MessageTypeOrderExecutedWithPrice:
+		return replayCall(p, h.OnOrderExecutedWithPrice)
+	case MessageTypeOrderCancel:
+		return replayCall(p, h.OnOrderCancel)
+	case MessageTypeOrderDelete:
+		return replayCall(p, h.OnOrderDelete)
+	case MessageTypeOrderReplace:
+		return replayCall(p, h.OnOrderReplace)
+	case MessageTypeTrade:
+		return replayCall(p, h.OnTrade)
+	case MessageTypeCrossTrade:
+		return replayCall(p, h.OnCrossTrade)
+	case MessageTypeBrokenTrade:
+		return replayCall(p, h.OnBrokenTrade)
+	case MessageTypeNOII:
+		return replayCall(p, h.OnNOII)
+	case MessageTypeRPII:
+		return replayCall(p, h.OnRPII)
+	case recordUnknown:
+		msgType, err := p.r.ReadByte()
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		size, err := binary.ReadUvarint(p.r)
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		if size > 1<<20 {
+			return fmt.Errorf("%w: unknown message of %d bytes", ErrInvalidRecording, size)
+		}
+		if err := p.read(int(size)); err != nil {
+			return err
+		}
+		return h.OnUnknownMessage(msgType, p.buf)
+	default:
+		return fmt.Errorf("%w: call kind %#x", ErrInvalidRecording, kind)
+	}
+}
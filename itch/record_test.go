@@ -0,0 +1,66 @@
+package itch
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// failingDirectoryHandler rejects stock directory messages
+type failingDirectoryHandler struct {
+	DefaultHandler
+}
+
+func (h *failingDirectoryHandler) OnStockDirectory(msg StockDirectoryMessage) error {
+	return errRejected
+}
+
+func TestRecordingHandler_Replay(t *testing.T) {
+	msgs := append(testMessages(), unknownMessage{'z', "z\x01\x02\x03"})
+	var data []byte
+	for _, msg := range msgs {
+		data = appendMessage(data, msg)
+	}
+
+	var recording bytes.Buffer
+	live := &recordHandler{}
+	recorder := NewRecordingHandler(&recording, live)
+	if _, _, err := NewParser(recorder).ParseAll(data); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := recorder.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if recorder.Calls() != uint64(len(msgs)) || len(live.msgs) != len(msgs) {
+		t.Fatalf("Expected %d calls recorded and forwarded, got %d and %d", len(msgs), recorder.Calls(), len(live.msgs))
+	}
+
+	replayed := &recordHandler{}
+	n, err := NewReplayHandler(bytes.NewReader(recording.Bytes()), replayed).Replay()
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if n != uint64(len(msgs)) {
+		t.Fatalf("Expected %d calls replayed, got %d", len(msgs), n)
+	}
+	for i := range msgs {
+		if replayed.msgs[i] != msgs[i] {
+			t.Errorf("Call %d: expected %+v, got %+v", i, msgs[i], replayed.msgs[i])
+		}
+	}
+
+	// Handler errors stop the replay
+	replay := NewReplayHandler(bytes.NewReader(recording.Bytes()), &failingDirectoryHandler{})
+	if _, err := replay.Replay(); err != errRejected || replay.Calls() != 1 {
+		t.Errorf("Expected the handler error on the second call, got %v after %d calls", err, replay.Calls())
+	}
+
+	truncated := recording.Bytes()[:recording.Len()-2]
+	if _, err := NewReplayHandler(bytes.NewReader(truncated), &DefaultHandler{}).Replay(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if _, err := NewReplayHandler(bytes.NewReader(data), &DefaultHandler{}).Replay(); !errors.Is(err, ErrInvalidRecording) {
+		t.Errorf("Expected ErrInvalidRecording, got %v", err)
+	}
+}
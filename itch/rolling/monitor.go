@@ -0,0 +1,304 @@
+package rolling
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tienpsm/go-trader/itch"
+)
+
+const (
+	// DefaultRateWindow is the window used for message rates
+	DefaultRateWindow = time.Second
+	// DefaultVolumeWindow is the window used for traded volume per symbol
+	DefaultVolumeWindow = 5 * time.Minute
+	// defaultBuckets is the number of buckets per window
+	defaultBuckets = 60
+)
+
+// Monitor is an itch.Handler that keeps a rolling message rate and rolling
+// traded volume per symbol, updated on every message. It forwards every
+// message to the next handler, so it can be inserted in front of an existing
+// handler chain.
+//
+// Monitor is safe for concurrent use: the parser goroutine updates it while
+// other goroutines query it.
+type Monitor struct {
+	mu   sync.Mutex
+	next itch.Handler
+
+	messages      *Window
+	volumeSpan    time.Duration
+	volumes       map[uint16]*Window
+	stocks        map[uint16]string
+	totalVolume   *Window
+	lastTimestamp uint64
+}
+
+// NewMonitor creates a monitor with the default 1-second message rate window
+// and 5-minute volume window. next may be nil.
+func NewMonitor(next itch.Handler) *Monitor {
+	return NewMonitorWithWindows(next, DefaultRateWindow, DefaultVolumeWindow)
+}
+
+// NewMonitorWithWindows creates a monitor with custom window spans. next may be nil.
+func NewMonitorWithWindows(next itch.Handler, rateSpan, volumeSpan time.Duration) *Monitor {
+	if next == nil {
+		next = &itch.DefaultHandler{}
+	}
+	return &Monitor{
+		next:        next,
+		messages:    NewWindow(rateSpan, defaultBuckets),
+		volumeSpan:  volumeSpan,
+		volumes:     make(map[uint16]*Window),
+		stocks:      make(map[uint16]string),
+		totalVolume: NewWindow(volumeSpan, defaultBuckets),
+	}
+}
+
+// MessageRate returns the number of messages per second over the rate window
+func (m *Monitor) MessageRate() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.messages.Rate()
+}
+
+// Messages returns the number of messages inside the rate window
+func (m *Monitor) Messages() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.messages.Count()
+}
+
+// Volume returns the traded volume of a stock inside the volume window
+func (m *Monitor) Volume(stock string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for locate, name := range m.stocks {
+		if name == stock {
+			if w, ok := m.volumes[locate]; ok {
+				w.Advance(m.lastTimestamp)
+				return w.Sum()
+			}
+		}
+	}
+	return 0
+}
+
+// Volumes returns the traded volume of every stock inside the volume window
+func (m *Monitor) Volumes() map[string]uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make(map[string]uint64, len(m.volumes))
+	for locate, w := range m.volumes {
+		w.Advance(m.lastTimestamp)
+		if sum := w.Sum(); sum > 0 {
+			result[m.stocks[locate]] += sum
+		}
+	}
+	return result
+}
+
+// TotalVolume returns the traded volume of all stocks inside the volume window
+func (m *Monitor) TotalVolume() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.totalVolume.Advance(m.lastTimestamp)
+	return m.totalVolume.Sum()
+}
+
+// LastTimestamp returns the timestamp of the most recent message
+func (m *Monitor) LastTimestamp() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastTimestamp
+}
+
+// observe records a message at timestamp. Volume windows are advanced lazily
+// when queried, so the per-message cost does not grow with the symbol count.
+// Must be called with m.mu held.
+func (m *Monitor) observe(timestamp uint64) {
+	if timestamp > m.lastTimestamp {
+		m.lastTimestamp = timestamp
+	}
+	m.messages.Add(timestamp, 1)
+}
+
+// register remembers the stock symbol for a locate code.
+// Must be called with m.mu held.
+func (m *Monitor) register(locate uint16, stock itch.StockSymbol) {
+	if _, ok := m.stocks[locate]; !ok {
+		m.stocks[locate] = stock.Trimmed()
+	}
+}
+
+// trade records traded volume for a locate code.
+// Must be called with m.mu held.
+func (m *Monitor) trade(timestamp uint64, locate uint16, shares uint64) {
+	w, ok := m.volumes[locate]
+	if !ok {
+		w = NewWindow(m.volumeSpan, defaultBuckets)
+		m.volumes[locate] = w
+	}
+	w.Add(timestamp, shares)
+	m.totalVolume.Add(timestamp, shares)
+}
+
+func (m *Monitor) OnSystemEvent(msg itch.SystemEventMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.mu.Unlock()
+	return m.next.OnSystemEvent(msg)
+}
+
+func (m *Monitor) OnStockDirectory(msg itch.StockDirectoryMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.register(msg.StockLocate, msg.Stock)
+	m.mu.Unlock()
+	return m.next.OnStockDirectory(msg)
+}
+
+func (m *Monitor) OnStockTradingAction(msg itch.StockTradingActionMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.mu.Unlock()
+	return m.next.OnStockTradingAction(msg)
+}
+
+func (m *Monitor) OnRegSHO(msg itch.RegSHOMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.mu.Unlock()
+	return m.next.OnRegSHO(msg)
+}
+
+func (m *Monitor) OnMarketParticipantPosition(msg itch.MarketParticipantPositionMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.mu.Unlock()
+	return m.next.OnMarketParticipantPosition(msg)
+}
+
+func (m *Monitor) OnMWCBDecline(msg itch.MWCBDeclineMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.mu.Unlock()
+	return m.next.OnMWCBDecline(msg)
+}
+
+func (m *Monitor) OnMWCBStatus(msg itch.MWCBStatusMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.mu.Unlock()
+	return m.next.OnMWCBStatus(msg)
+}
+
+func (m *Monitor) OnIPOQuoting(msg itch.IPOQuotingMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.mu.Unlock()
+	return m.next.OnIPOQuoting(msg)
+}
+
+func (m *Monitor) OnAddOrder(msg itch.AddOrderMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.register(msg.StockLocate, msg.Stock)
+	m.mu.Unlock()
+	return m.next.OnAddOrder(msg)
+}
+
+func (m *Monitor) OnAddOrderMPID(msg itch.AddOrderMPIDMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.register(msg.StockLocate, msg.Stock)
+	m.mu.Unlock()
+	return m.next.OnAddOrderMPID(msg)
+}
+
+func (m *Monitor) OnOrderExecuted(msg itch.OrderExecutedMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.trade(msg.Timestamp, msg.StockLocate, uint64(msg.ExecutedShares))
+	m.mu.Unlock()
+	return m.next.OnOrderExecuted(msg)
+}
+
+func (m *Monitor) OnOrderExecutedWithPrice(msg itch.OrderExecutedWithPriceMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	if msg.Printable == 'Y' {
+		m.trade(msg.Timestamp, msg.StockLocate, uint64(msg.ExecutedShares))
+	}
+	m.mu.Unlock()
+	return m.next.OnOrderExecutedWithPrice(msg)
+}
+
+func (m *Monitor) OnOrderCancel(msg itch.OrderCancelMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.mu.Unlock()
+	return m.next.OnOrderCancel(msg)
+}
+
+func (m *Monitor) OnOrderDelete(msg itch.OrderDeleteMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.mu.Unlock()
+	return m.next.OnOrderDelete(msg)
+}
+
+func (m *Monitor) OnOrderReplace(msg itch.OrderReplaceMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.mu.Unlock()
+	return m.next.OnOrderReplace(msg)
+}
+
+func (m *Monitor) OnTrade(msg itch.TradeMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.register(msg.StockLocate, msg.Stock)
+	m.trade(msg.Timestamp, msg.StockLocate, uint64(msg.Shares))
+	m.mu.Unlock()
+	return m.next.OnTrade(msg)
+}
+
+func (m *Monitor) OnCrossTrade(msg itch.CrossTradeMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.register(msg.StockLocate, msg.Stock)
+	if msg.Shares > 0 {
+		m.trade(msg.Timestamp, msg.StockLocate, msg.Shares)
+	}
+	m.mu.Unlock()
+	return m.next.OnCrossTrade(msg)
+}
+
+func (m *Monitor) OnBrokenTrade(msg itch.BrokenTradeMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.mu.Unlock()
+	return m.next.OnBrokenTrade(msg)
+}
+
+func (m *Monitor) OnNOII(msg itch.NOIIMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.mu.Unlock()
+	return m.next.OnNOII(msg)
+}
+
+func (m *Monitor) OnRPII(msg itch.RPIIMessage) error {
+	m.mu.Lock()
+	m.observe(msg.Timestamp)
+	m.mu.Unlock()
+	return m.next.OnRPII(msg)
+}
+
+// OnUnknownMessage forwards unknown messages without counting them, since
+// they carry no reliable timestamp
+func (m *Monitor) OnUnknownMessage(msgType byte, data []byte) error {
+	return m.next.OnUnknownMessage(msgType, data)
+}
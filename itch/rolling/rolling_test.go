@@ -0,0 +1,66 @@
+package rolling
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tienpsm/go-trader/itch"
+)
+
+func TestWindow_Expiry(t *testing.T) {
+	w := NewWindow(time.Second, 10)
+
+	w.Add(uint64(0), 5)
+	w.Add(uint64(500*time.Millisecond), 7)
+	if w.Sum() != 12 || w.Count() != 2 {
+		t.Errorf("Expected sum 12 / count 2, got %d / %d", w.Sum(), w.Count())
+	}
+
+	// The first value expires once the window has moved a full second past it
+	w.Add(uint64(1200*time.Millisecond), 1)
+	if w.Sum() != 8 {
+		t.Errorf("Expected sum 8 after expiry, got %d", w.Sum())
+	}
+
+	// Late values outside the window are dropped
+	w.Add(uint64(100*time.Millisecond), 100)
+	if w.Sum() != 8 {
+		t.Errorf("Expected late value to be dropped, got %d", w.Sum())
+	}
+
+	w.Advance(uint64(10 * time.Second))
+	if w.Sum() != 0 || w.Rate() != 0 {
+		t.Errorf("Expected empty window after advance, got sum %d", w.Sum())
+	}
+}
+
+func TestMonitor_RateAndVolume(t *testing.T) {
+	m := NewMonitorWithWindows(nil, time.Second, time.Minute)
+	stock := [8]byte{'A', 'A', 'P', 'L', ' ', ' ', ' ', ' '}
+
+	m.OnStockDirectory(itch.StockDirectoryMessage{StockLocate: 1, Stock: stock, Timestamp: 0})
+	m.OnOrderExecuted(itch.OrderExecutedMessage{StockLocate: 1, ExecutedShares: 100, Timestamp: uint64(100 * time.Millisecond)})
+	m.OnTrade(itch.TradeMessage{StockLocate: 1, Stock: stock, Shares: 50, Timestamp: uint64(200 * time.Millisecond)})
+	m.OnOrderExecutedWithPrice(itch.OrderExecutedWithPriceMessage{StockLocate: 1, ExecutedShares: 999, Printable: 'N', Timestamp: uint64(300 * time.Millisecond)})
+
+	if m.Messages() != 4 {
+		t.Errorf("Expected 4 messages in window, got %d", m.Messages())
+	}
+	if m.Volume("AAPL") != 150 {
+		t.Errorf("Expected AAPL volume 150, got %d", m.Volume("AAPL"))
+	}
+
+	// Two seconds later the message rate window has rolled over but volume has not
+	m.OnSystemEvent(itch.SystemEventMessage{Timestamp: uint64(2300 * time.Millisecond)})
+	if m.Messages() != 1 {
+		t.Errorf("Expected 1 message in window, got %d", m.Messages())
+	}
+	if m.TotalVolume() != 150 {
+		t.Errorf("Expected total volume 150, got %d", m.TotalVolume())
+	}
+
+	m.OnSystemEvent(itch.SystemEventMessage{Timestamp: uint64(2 * time.Minute)})
+	if v := m.Volumes()["AAPL"]; v != 0 {
+		t.Errorf("Expected AAPL volume to expire, got %d", v)
+	}
+}
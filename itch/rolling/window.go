@@ -0,0 +1,122 @@
+// Package rolling provides rolling-window aggregation over ITCH events.
+// Windows are driven by message timestamps rather than the wall clock, so the
+// same code works for live feeds and for file replays.
+package rolling
+
+import "time"
+
+// bucket holds the sum and count of values added within one bucket interval
+type bucket struct {
+	index int64
+	sum   uint64
+	count uint64
+}
+
+// Window maintains a rolling sum and count over a fixed time span.
+// The span is split into equal buckets; values expire one bucket at a time.
+// Not thread-safe.
+type Window struct {
+	span    time.Duration
+	width   int64
+	buckets []bucket
+	latest  int64
+}
+
+// NewWindow creates a rolling window covering span, split into the given
+// number of buckets. More buckets give smoother expiry at a higher memory cost.
+func NewWindow(span time.Duration, buckets int) *Window {
+	if buckets < 1 {
+		buckets = 1
+	}
+	width := int64(span) / int64(buckets)
+	if width < 1 {
+		width = 1
+	}
+	w := &Window{
+		span:    span,
+		width:   width,
+		buckets: make([]bucket, buckets),
+		latest:  -1,
+	}
+	for i := range w.buckets {
+		w.buckets[i].index = -1
+	}
+	return w
+}
+
+// Span returns the time span covered by the window
+func (w *Window) Span() time.Duration {
+	return w.span
+}
+
+// Add records a value at the given timestamp (nanoseconds).
+// Values older than the window relative to the latest timestamp are dropped.
+func (w *Window) Add(timestamp uint64, value uint64) {
+	index := int64(timestamp) / w.width
+	if index > w.latest {
+		w.latest = index
+	}
+	if index <= w.latest-int64(len(w.buckets)) {
+		return
+	}
+
+	b := &w.buckets[index%int64(len(w.buckets))]
+	if b.index != index {
+		*b = bucket{index: index}
+	}
+	b.sum += value
+	b.count++
+}
+
+// Advance moves the window forward to timestamp without adding a value,
+// so that quiet periods expire old values
+func (w *Window) Advance(timestamp uint64) {
+	if index := int64(timestamp) / w.width; index > w.latest {
+		w.latest = index
+	}
+}
+
+// live returns true if a bucket is inside the window
+func (w *Window) live(b *bucket) bool {
+	return b.index >= 0 && b.index > w.latest-int64(len(w.buckets))
+}
+
+// Sum returns the sum of values inside the window
+func (w *Window) Sum() uint64 {
+	var sum uint64
+	for i := range w.buckets {
+		if w.live(&w.buckets[i]) {
+			sum += w.buckets[i].sum
+		}
+	}
+	return sum
+}
+
+// Count returns the number of values inside the window
+func (w *Window) Count() uint64 {
+	var count uint64
+	for i := range w.buckets {
+		if w.live(&w.buckets[i]) {
+			count += w.buckets[i].count
+		}
+	}
+	return count
+}
+
+// Rate returns the number of values per second inside the window
+func (w *Window) Rate() float64 {
+	return float64(w.Count()) / w.span.Seconds()
+}
+
+// SumRate returns the sum of values per second inside the window
+func (w *Window) SumRate() float64 {
+	return float64(w.Sum()) / w.span.Seconds()
+}
+
+// Reset clears all values from the window
+func (w *Window) Reset() {
+	for i := range w.buckets {
+		w.buckets[i] = bucket{index: -1}
+	}
+	w.latest = -1
+}
@@ -0,0 +1,356 @@
+package itch
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tienpsm/go-trader/metrics"
+)
+
+// StockStats is the per-symbol order flow and trade summary
+type StockStats struct {
+	// Stock is the trimmed stock symbol
+	Stock string
+	// StockLocate is the locate code the statistics were reported under
+	StockLocate uint16
+
+	// AddOrders is the number of orders added (with and without MPID)
+	AddOrders uint64
+	// Executions is the number of order executions
+	Executions uint64
+	// Cancels is the number of partial cancels
+	Cancels uint64
+	// Deletes is the number of order deletes
+	Deletes uint64
+	// Replaces is the number of order replaces
+	Replaces uint64
+
+	// Trades is the number of printed trades, excluding broken ones
+	Trades uint64
+	// Volume is the printed volume, excluding broken trades
+	Volume uint64
+	// Notional is the sum of price * shares, excluding broken trades
+	Notional uint64
+	// High is the highest trade price (4 implied decimals)
+	High uint32
+	// Low is the lowest trade price (4 implied decimals)
+	Low uint32
+	// Last is the most recent trade price (4 implied decimals)
+	Last uint32
+
+	// BrokenTrades is the number of trades removed by Broken Trade messages
+	BrokenTrades uint64
+	// BrokenVolume is the volume removed by Broken Trade messages
+	BrokenVolume uint64
+
+	// Lifetime is the distribution of the time from add to full execution,
+	// delete or full cancel of orders. Replaced orders keep the add time of
+	// the original order.
+	Lifetime metrics.Snapshot
+	// ModifiedOrders is the number of completed orders that were partially
+	// canceled or replaced at least once
+	ModifiedOrders uint64
+	// Modifications is the number of partial cancels and replaces of
+	// completed orders
+	Modifications uint64
+}
+
+// VWAP returns the volume-weighted average price (4 implied decimals),
+// or 0 if nothing traded
+func (s StockStats) VWAP() float64 {
+	if s.Volume == 0 {
+		return 0
+	}
+	return float64(s.Notional) / float64(s.Volume)
+}
+
+// CancelToTradeRatio returns the number of cancels and deletes per
+// execution, or 0 if nothing executed
+func (s StockStats) CancelToTradeRatio() float64 {
+	if s.Executions == 0 {
+		return 0
+	}
+	return float64(s.Cancels+s.Deletes) / float64(s.Executions)
+}
+
+// ModificationsPerOrder returns the average number of partial cancels and
+// replaces of completed orders, or 0 if no order completed
+func (s StockStats) ModificationsPerOrder() float64 {
+	if s.Lifetime.Count == 0 {
+		return 0
+	}
+	return float64(s.Modifications) / float64(s.Lifetime.Count)
+}
+
+// statsTrade is the compact record of a counted trade, kept by match number
+// so that it can be reversed if broken
+type statsTrade struct {
+	shares uint64
+	price  uint32
+	locate uint16
+}
+
+// SymbolStats is a handler that aggregates order flow and trade statistics
+// per symbol. Broken Trade messages remove the busted trades from the
+// aggregates so that VWAP and volume reflect only standing trades.
+//
+// Orders are followed from add to completion to measure their lifetime and
+// modifications; orders still resting at the end of the feed are not counted.
+type SymbolStats struct {
+	DefaultHandler
+
+	tracker orderTracker
+	stats   map[uint16]*StockStats
+	trades  map[uint64]statsTrade
+	// shared holds the further trades of match numbers counted more than once
+	shared map[uint64][]statsTrade
+	// prices counts the standing trades of each symbol by price, to rebuild
+	// the high and low after a break
+	prices    map[uint16]map[uint32]uint64
+	lifetimes map[uint16]*metrics.Histogram
+}
+
+// NewSymbolStats creates a new per-symbol statistics handler
+func NewSymbolStats() *SymbolStats {
+	return &SymbolStats{
+		tracker:   newOrderTracker(),
+		stats:     make(map[uint16]*StockStats),
+		trades:    make(map[uint64]statsTrade),
+		shared:    make(map[uint64][]statsTrade),
+		prices:    make(map[uint16]map[uint32]uint64),
+		lifetimes: make(map[uint16]*metrics.Histogram),
+	}
+}
+
+// Directory returns the symbol directory of the handler
+func (h *SymbolStats) Directory() *SymbolDirectory {
+	return h.tracker.directory
+}
+
+// SetDirectory shares a symbol directory with other handlers. It must be set
+// before the first message.
+func (h *SymbolStats) SetDirectory(d *SymbolDirectory) {
+	h.tracker.directory = d
+}
+
+// get returns the statistics for a locate code, creating them if necessary
+func (h *SymbolStats) get(locate uint16) *StockStats {
+	s, ok := h.stats[locate]
+	if !ok {
+		s = &StockStats{StockLocate: locate}
+		h.stats[locate] = s
+	}
+	return s
+}
+
+// complete records the lifetime and modifications of an order leaving the
+// book at timestamp
+func (h *SymbolStats) complete(timestamp uint64, order trackedOrder) {
+	lifetime, ok := h.lifetimes[order.locate]
+	if !ok {
+		lifetime = metrics.NewHistogram()
+		h.lifetimes[order.locate] = lifetime
+	}
+	lifetime.Record(time.Duration(timestamp - order.added))
+
+	if order.modifications > 0 {
+		s := h.get(order.locate)
+		s.ModifiedOrders++
+		s.Modifications += uint64(order.modifications)
+	}
+}
+
+// result returns a copy of the statistics of a locate code
+func (h *SymbolStats) result(locate uint16, s *StockStats) StockStats {
+	result := *s
+	result.Stock = h.tracker.stock(locate)
+	if lifetime, ok := h.lifetimes[locate]; ok {
+		result.Lifetime = lifetime.Snapshot()
+	}
+	return result
+}
+
+// trade adds a printed trade to the aggregates
+func (h *SymbolStats) trade(locate uint16, match uint64, shares uint64, price uint32) {
+	s := h.get(locate)
+	s.Trades++
+	s.Volume += shares
+	s.Notional += shares * uint64(price)
+	if s.High == 0 || price > s.High {
+		s.High = price
+	}
+	if s.Low == 0 || price < s.Low {
+		s.Low = price
+	}
+	s.Last = price
+
+	t := statsTrade{shares: shares, price: price, locate: locate}
+	if _, ok := h.trades[match]; ok {
+		h.shared[match] = append(h.shared[match], t)
+	} else {
+		h.trades[match] = t
+	}
+	prices, ok := h.prices[locate]
+	if !ok {
+		prices = make(map[uint32]uint64)
+		h.prices[locate] = prices
+	}
+	prices[price]++
+}
+
+// Stats returns the statistics for a stock symbol
+func (h *SymbolStats) Stats(stock string) (StockStats, bool) {
+	for locate, s := range h.stats {
+		if h.tracker.stock(locate) == stock {
+			return h.result(locate, s), true
+		}
+	}
+	return StockStats{}, false
+}
+
+// All returns the statistics of every symbol, sorted by stock symbol
+func (h *SymbolStats) All() []StockStats {
+	all := make([]StockStats, 0, len(h.stats))
+	for locate, s := range h.stats {
+		all = append(all, h.result(locate, s))
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Stock != all[j].Stock {
+			return all[i].Stock < all[j].Stock
+		}
+		return all[i].StockLocate < all[j].StockLocate
+	})
+	return all
+}
+
+// OnStockDirectory registers the stock in the directory
+func (h *SymbolStats) OnStockDirectory(msg StockDirectoryMessage) error {
+	h.tracker.directory.Add(msg)
+	h.get(msg.StockLocate)
+	return nil
+}
+
+// OnAddOrder counts the order and starts tracking it
+func (h *SymbolStats) OnAddOrder(msg AddOrderMessage) error {
+	h.tracker.add(msg.Timestamp, msg.OrderReferenceNumber, msg.StockLocate, msg.Stock, msg.BuySellIndicator, msg.Shares, msg.Price)
+	h.get(msg.StockLocate).AddOrders++
+	return nil
+}
+
+// OnAddOrderMPID counts the order and starts tracking it
+func (h *SymbolStats) OnAddOrderMPID(msg AddOrderMPIDMessage) error {
+	h.tracker.addMPID(msg)
+	h.get(msg.StockLocate).AddOrders++
+	return nil
+}
+
+// OnOrderExecuted counts the execution as a trade at the resting order's price
+func (h *SymbolStats) OnOrderExecuted(msg OrderExecutedMessage) error {
+	h.get(msg.StockLocate).Executions++
+	if order, ok := h.tracker.execute(msg.OrderReferenceNumber, msg.ExecutedShares); ok {
+		h.trade(msg.StockLocate, msg.MatchNumber, uint64(msg.ExecutedShares), order.price)
+		if msg.ExecutedShares >= order.shares {
+			h.complete(msg.Timestamp, order)
+		}
+	}
+	return nil
+}
+
+// OnOrderExecutedWithPrice counts the execution, as a trade only if printable
+func (h *SymbolStats) OnOrderExecutedWithPrice(msg OrderExecutedWithPriceMessage) error {
+	h.get(msg.StockLocate).Executions++
+	if order, ok := h.tracker.execute(msg.OrderReferenceNumber, msg.ExecutedShares); ok && msg.ExecutedShares >= order.shares {
+		h.complete(msg.Timestamp, order)
+	}
+	if msg.Printable == 'Y' {
+		h.trade(msg.StockLocate, msg.MatchNumber, uint64(msg.ExecutedShares), msg.ExecutionPrice)
+	}
+	return nil
+}
+
+// OnOrderCancel counts the partial cancel
+func (h *SymbolStats) OnOrderCancel(msg OrderCancelMessage) error {
+	if order, ok := h.tracker.cancel(msg.OrderReferenceNumber, msg.CanceledShares); ok && msg.CanceledShares >= order.shares {
+		h.complete(msg.Timestamp, order)
+	}
+	h.get(msg.StockLocate).Cancels++
+	return nil
+}
+
+// OnOrderDelete counts the delete
+func (h *SymbolStats) OnOrderDelete(msg OrderDeleteMessage) error {
+	if order, ok := h.tracker.delete(msg.OrderReferenceNumber); ok {
+		h.complete(msg.Timestamp, order)
+	}
+	h.get(msg.StockLocate).Deletes++
+	return nil
+}
+
+// OnOrderReplace counts the replace
+func (h *SymbolStats) OnOrderReplace(msg OrderReplaceMessage) error {
+	h.tracker.replace(msg.OriginalOrderReferenceNumber, msg.NewOrderReferenceNumber, msg.Shares, msg.Price)
+	h.get(msg.StockLocate).Replaces++
+	return nil
+}
+
+// OnTrade counts a non-displayable order execution as a trade
+func (h *SymbolStats) OnTrade(msg TradeMessage) error {
+	h.tracker.register(msg.StockLocate, msg.Stock)
+	h.trade(msg.StockLocate, msg.MatchNumber, uint64(msg.Shares), msg.Price)
+	return nil
+}
+
+// OnCrossTrade counts the cross as a trade, if any shares were matched
+func (h *SymbolStats) OnCrossTrade(msg CrossTradeMessage) error {
+	h.tracker.register(msg.StockLocate, msg.Stock)
+	if msg.Shares > 0 {
+		h.trade(msg.StockLocate, msg.MatchNumber, msg.Shares, msg.CrossPrice)
+	}
+	return nil
+}
+
+// OnBrokenTrade removes the trades with the broken match number from the
+// aggregates. High and low are recomputed from the remaining trades.
+func (h *SymbolStats) OnBrokenTrade(msg BrokenTradeMessage) error {
+	t, ok := h.trades[msg.MatchNumber]
+	if !ok {
+		return nil
+	}
+	broken := append([]statsTrade{t}, h.shared[msg.MatchNumber]...)
+	delete(h.trades, msg.MatchNumber)
+	delete(h.shared, msg.MatchNumber)
+
+	for _, t := range broken {
+		s := h.get(t.locate)
+		s.Trades--
+		s.Volume -= t.shares
+		s.Notional -= t.shares * uint64(t.price)
+		s.BrokenTrades++
+		s.BrokenVolume += t.shares
+		h.removePrice(s, t)
+	}
+	return nil
+}
+
+// removePrice removes a broken trade from the price counts of its symbol and
+// rebuilds the high and low from the remaining prices if it set either
+func (h *SymbolStats) removePrice(s *StockStats, t statsTrade) {
+	prices := h.prices[t.locate]
+	if prices[t.price] > 1 {
+		prices[t.price]--
+		return
+	}
+	delete(prices, t.price)
+	if t.price != s.High && t.price != s.Low {
+		return
+	}
+	s.High, s.Low = 0, 0
+	for price := range prices {
+		if s.High == 0 || price > s.High {
+			s.High = price
+		}
+		if s.Low == 0 || price < s.Low {
+			s.Low = price
+		}
+	}
+}
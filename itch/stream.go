@@ -0,0 +1,105 @@
+package itch
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// defaultStreamBufSize is the read-buffer size used for framed streams
+const defaultStreamBufSize = 64 * 1024
+
+// FrameReader reads length-prefixed ITCH messages, as found in NASDAQ
+// BinaryFILE captures: a 2-byte big-endian length followed by the message.
+type FrameReader struct {
+	r      *bufio.Reader
+	buf    []byte
+	offset int64
+}
+
+// NewFrameReader creates a frame reader on top of r
+func NewFrameReader(r io.Reader) *FrameReader {
+	return &FrameReader{
+		r:   bufio.NewReaderSize(r, defaultStreamBufSize),
+		buf: make([]byte, 0, 1<<16),
+	}
+}
+
+// Offset returns the stream byte offset of the next frame
+func (f *FrameReader) Offset() int64 {
+	return f.offset
+}
+
+// Next returns the next message without its length prefix. The returned slice
+// is only valid until the next call. io.EOF is returned at a clean end of
+// stream and io.ErrUnexpectedEOF if the stream ends inside a frame.
+func (f *FrameReader) Next() ([]byte, error) {
+	var lenBuf [2]byte
+	if _, err := io.ReadFull(f.r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint16(lenBuf[:]))
+	f.buf = f.buf[:size]
+	if _, err := io.ReadFull(f.r, f.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	f.offset += int64(2 + size)
+	return f.buf, nil
+}
+
+// ParseStream parses length-prefixed messages from r until the end of the
+// stream and returns the number of messages parsed. A frame too short for its
+// message type, or a stream truncated inside a frame, is reported as a
+// *ParseError, and an error of the handler as a *HandlerError.
+func (p *Parser) ParseStream(r io.Reader) (int, error) {
+	return p.parseFrames(NewFrameReader(r), 0)
+}
+
+// parseFrames parses the messages of frames from the first message at or
+// after startTime, skipping the earlier ones, and returns the number of
+// messages parsed
+func (p *Parser) parseFrames(frames *FrameReader, startTime uint64) (int, error) {
+	count := 0
+	started := startTime == 0
+	for {
+		offset := frames.Offset()
+		msg, err := frames.Next()
+		if err != nil {
+			if err == io.EOF {
+				return count, nil
+			}
+			return count, newParseError(offset, p.index, nil, fmt.Errorf("reading frame: %w", err))
+		}
+		if len(msg) == 0 {
+			continue
+		}
+		if !started {
+			if messageTimestamp(msg) < startTime {
+				p.offset = frames.Offset()
+				p.index++
+				continue
+			}
+			started = true
+		}
+		// Report positions as file offsets of the frame, including its prefix
+		p.offset = offset
+		if _, err := p.Parse(msg); err != nil {
+			var handlerErr *HandlerError
+			if errors.As(err, &handlerErr) {
+				p.offset = frames.Offset()
+				return count + 1, err
+			}
+			if errors.Is(err, ErrInsufficientData) {
+				return count, newParseError(offset, p.index, msg, err)
+			}
+			return count, err
+		}
+		p.offset = frames.Offset()
+		count++
+	}
+}
@@ -0,0 +1,70 @@
+package itch
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// frame prefixes a message with its 2-byte big-endian length
+func frame(msg []byte) []byte {
+	return append([]byte{byte(len(msg) >> 8), byte(len(msg))}, msg...)
+}
+
+func TestFrameReader(t *testing.T) {
+	event := make([]byte, 12)
+	event[0] = 'S'
+	data := append(frame(event), frame(event)...)
+
+	frames := NewFrameReader(bytes.NewReader(data))
+	for i := 0; i < 2; i++ {
+		msg, err := frames.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if len(msg) != 12 || msg[0] != 'S' {
+			t.Errorf("Expected 12-byte system event, got %d bytes", len(msg))
+		}
+	}
+	if frames.Offset() != 28 {
+		t.Errorf("Expected offset 28, got %d", frames.Offset())
+	}
+	if _, err := frames.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestParser_ParseStream(t *testing.T) {
+	handler := &TestHandler{}
+	parser := NewParser(handler)
+
+	event := make([]byte, 12)
+	event[0] = 'S'
+	data := append(frame(event), frame(event)...)
+
+	count, err := parser.ParseStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseStream: %v", err)
+	}
+	if count != 2 || len(handler.systemEvents) != 2 {
+		t.Errorf("Expected 2 system events, got %d", count)
+	}
+
+	// A frame too short for its message type is a parse error at the frame offset
+	data = append(frame(event), frame(event[:5])...)
+	_, err = NewParser(&TestHandler{}).ParseStream(bytes.NewReader(data))
+	var pe *ParseError
+	if !errors.As(err, &pe) || !errors.Is(err, ErrInsufficientData) {
+		t.Fatalf("Expected ParseError wrapping ErrInsufficientData, got %v", err)
+	}
+	if pe.Offset != 14 || pe.Index != 1 {
+		t.Errorf("Expected message 1 at offset 14, got %d at %d", pe.Index, pe.Offset)
+	}
+
+	// A stream truncated inside a frame is reported as well
+	_, err = NewParser(&TestHandler{}).ParseStream(bytes.NewReader(data[:20]))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
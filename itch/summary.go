@@ -0,0 +1,236 @@
+package itch
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SummaryTotals are the official totals of a stock for a trading day
+type SummaryTotals struct {
+	// Trades is the number of trades
+	Trades uint64
+	// Volume is the traded volume in shares
+	Volume uint64
+	// High and Low are the trade price range (4 implied decimals)
+	High uint32
+	Low  uint32
+}
+
+// DailySummary is an official daily summary of the exchange, such as the
+// NASDAQ daily market summary, used to verify the totals parsed from a feed
+// beyond the absence of unknown messages
+type DailySummary struct {
+	// Stocks are the totals by trimmed stock symbol
+	Stocks map[string]SummaryTotals
+	// HasTrades, HasVolume and HasRange report the columns of the summary;
+	// only those are verified
+	HasTrades bool
+	HasVolume bool
+	HasRange  bool
+}
+
+// Discrepancy is a total of a stock differing from the daily summary
+type Discrepancy struct {
+	Stock string
+	// Field is "trades", "volume", "high" or "low"
+	Field string
+	// Expected is the total of the summary, Parsed the total of the feed;
+	// prices have 4 implied decimals
+	Expected uint64
+	Parsed   uint64
+}
+
+// String returns the string representation of a Discrepancy
+func (d Discrepancy) String() string {
+	return fmt.Sprintf("%s %s: expected %d, parsed %d", d.Stock, d.Field, d.Expected, d.Parsed)
+}
+
+// summaryColumns are the accepted header names of each summary column,
+// compared in lower case
+var summaryColumns = map[string][]string{
+	"symbol": {"symbol", "stock", "ticker", "issue symbol"},
+	"trades": {"trades", "trade count", "number of trades", "total trades"},
+	"volume": {"volume", "shares", "share volume", "total volume", "matched volume"},
+	"high":   {"high", "high price"},
+	"low":    {"low", "low price"},
+}
+
+// ReadDailySummary reads a daily summary CSV file. The header row names the
+// columns, in any order and case: the symbol ("Symbol", "Stock" or
+// "Ticker"), and at least one of the trade count ("Trades"), the volume
+// ("Volume" or "Shares") and the "High" and "Low" prices. Other columns are
+// ignored. Counts may use thousands separators and prices are decimal
+// dollars.
+func ReadDailySummary(r io.Reader) (*DailySummary, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("daily summary: empty file")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("daily summary: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for column, names := range summaryColumns {
+			for _, n := range names {
+				if name == n {
+					if _, dup := columns[column]; !dup {
+						columns[column] = i
+					}
+				}
+			}
+		}
+	}
+	if _, ok := columns["symbol"]; !ok {
+		return nil, errors.New("daily summary: no symbol column")
+	}
+	_, hasHigh := columns["high"]
+	_, hasLow := columns["low"]
+	s := &DailySummary{Stocks: make(map[string]SummaryTotals)}
+	_, s.HasTrades = columns["trades"]
+	_, s.HasVolume = columns["volume"]
+	s.HasRange = hasHigh && hasLow
+	if !s.HasTrades && !s.HasVolume && !s.HasRange {
+		return nil, errors.New("daily summary: no trades, volume or high and low columns")
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return s, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("daily summary: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		field := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		stock := field("symbol")
+		if stock == "" {
+			continue
+		}
+
+		totals := s.Stocks[stock]
+		if s.HasTrades {
+			n, err := parseCount(field("trades"))
+			if err != nil {
+				return nil, fmt.Errorf("daily summary: line %d: trades: %w", line, err)
+			}
+			totals.Trades += n
+		}
+		if s.HasVolume {
+			n, err := parseCount(field("volume"))
+			if err != nil {
+				return nil, fmt.Errorf("daily summary: line %d: volume: %w", line, err)
+			}
+			totals.Volume += n
+		}
+		if s.HasRange {
+			high, err := parseSummaryPrice(field("high"))
+			if err != nil {
+				return nil, fmt.Errorf("daily summary: line %d: high: %w", line, err)
+			}
+			low, err := parseSummaryPrice(field("low"))
+			if err != nil {
+				return nil, fmt.Errorf("daily summary: line %d: low: %w", line, err)
+			}
+			totals.High = max(totals.High, high)
+			if totals.Low == 0 || (low != 0 && low < totals.Low) {
+				totals.Low = low
+			}
+		}
+		s.Stocks[stock] = totals
+	}
+}
+
+// parseCount parses a count with optional thousands separators, empty for 0
+func parseCount(s string) (uint64, error) {
+	s = strings.ReplaceAll(s, ",", "")
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// parseSummaryPrice parses a decimal dollar price into 4 implied decimals,
+// empty for 0
+func parseSummaryPrice(s string) (uint32, error) {
+	s = strings.TrimPrefix(strings.ReplaceAll(s, ",", ""), "$")
+	if s == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	v = math.Round(v * 10000)
+	if v < 0 || v > math.MaxUint32 {
+		return 0, fmt.Errorf("price %s out of range", s)
+	}
+	return uint32(v), nil
+}
+
+// Check compares the statistics parsed from a feed with the summary and
+// returns the discrepancies, sorted by stock. Stocks of the summary missing
+// from the statistics count as not traded, and traded stocks missing from
+// the summary are reported against zero totals.
+func (s *DailySummary) Check(stats []StockStats) []Discrepancy {
+	parsed := make(map[string]SummaryTotals, len(stats))
+	for _, st := range stats {
+		totals := parsed[st.Stock]
+		totals.Trades += st.Trades
+		totals.Volume += st.Volume
+		totals.High = max(totals.High, st.High)
+		if totals.Low == 0 || (st.Low != 0 && st.Low < totals.Low) {
+			totals.Low = st.Low
+		}
+		parsed[st.Stock] = totals
+	}
+
+	stocks := make([]string, 0, len(s.Stocks)+len(parsed))
+	for stock := range s.Stocks {
+		stocks = append(stocks, stock)
+	}
+	for stock, totals := range parsed {
+		if _, ok := s.Stocks[stock]; !ok && totals.Trades > 0 {
+			stocks = append(stocks, stock)
+		}
+	}
+	sort.Strings(stocks)
+
+	var discrepancies []Discrepancy
+	add := func(stock, field string, expected, got uint64) {
+		if expected != got {
+			discrepancies = append(discrepancies, Discrepancy{Stock: stock, Field: field, Expected: expected, Parsed: got})
+		}
+	}
+	for _, stock := range stocks {
+		want, got := s.Stocks[stock], parsed[stock]
+		if s.HasTrades {
+			add(stock, "trades", want.Trades, got.Trades)
+		}
+		if s.HasVolume {
+			add(stock, "volume", want.Volume, got.Volume)
+		}
+		if s.HasRange {
+			add(stock, "high", uint64(want.High), uint64(got.High))
+			add(stock, "low", uint64(want.Low), uint64(got.Low))
+		}
+	}
+	return discrepancies
+}
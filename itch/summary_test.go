@@ -0,0 +1,72 @@
+package itch
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSummary = "\ufeffSymbol,Company,Trades,Volume,High,Low\n" +
+	"AAPL,Apple Inc.,\"1,200\",\"350,000\",190.25,187.50\n" +
+	"MSFT,Microsoft,10,500,410.00,409.0000\n" +
+	"TSLA,Tesla,1,100,250.00,250.00\n"
+
+func TestReadDailySummary(t *testing.T) {
+	s, err := ReadDailySummary(strings.NewReader(testSummary))
+	if err != nil {
+		t.Fatalf("ReadDailySummary: %v", err)
+	}
+	if !s.HasTrades || !s.HasVolume || !s.HasRange || len(s.Stocks) != 3 {
+		t.Fatalf("Expected 3 stocks with every column, got %+v", s)
+	}
+	want := SummaryTotals{Trades: 1200, Volume: 350000, High: 1902500, Low: 1875000}
+	if s.Stocks["AAPL"] != want {
+		t.Errorf("Expected %+v, got %+v", want, s.Stocks["AAPL"])
+	}
+
+	s, err = ReadDailySummary(strings.NewReader("TICKER,SHARES\nAAPL,10\n"))
+	if err != nil || s.HasTrades || !s.HasVolume || s.Stocks["AAPL"].Volume != 10 {
+		t.Errorf("Expected a volume-only summary, got %+v, %v", s, err)
+	}
+	for _, bad := range []string{"", "Name,Volume\nAAPL,1\n", "Symbol,Company\nAAPL,Apple\n", "Symbol,Trades\nAAPL,many\n"} {
+		if _, err := ReadDailySummary(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestDailySummary_Check(t *testing.T) {
+	s, err := ReadDailySummary(strings.NewReader(testSummary))
+	if err != nil {
+		t.Fatalf("ReadDailySummary: %v", err)
+	}
+	stats := []StockStats{
+		{Stock: "AAPL", Trades: 1200, Volume: 350000, High: 1902500, Low: 1875000},
+		{Stock: "MSFT", Trades: 9, Volume: 400, High: 4100000, Low: 4090000},
+		{Stock: "NVDA", Trades: 2, Volume: 20, High: 1000000, Low: 1000000},
+		{Stock: "QQQ"},
+	}
+	got := s.Check(stats)
+	want := []Discrepancy{
+		{Stock: "MSFT", Field: "trades", Expected: 10, Parsed: 9},
+		{Stock: "MSFT", Field: "volume", Expected: 500, Parsed: 400},
+		{Stock: "NVDA", Field: "trades", Expected: 0, Parsed: 2},
+		{Stock: "NVDA", Field: "volume", Expected: 0, Parsed: 20},
+		{Stock: "NVDA", Field: "high", Expected: 0, Parsed: 1000000},
+		{Stock: "NVDA", Field: "low", Expected: 0, Parsed: 1000000},
+		{Stock: "TSLA", Field: "trades", Expected: 1, Parsed: 0},
+		{Stock: "TSLA", Field: "volume", Expected: 100, Parsed: 0},
+		{Stock: "TSLA", Field: "high", Expected: 2500000, Parsed: 0},
+		{Stock: "TSLA", Field: "low", Expected: 2500000, Parsed: 0},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Discrepancy %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+	if got[0].String() != "MSFT trades: expected 10, parsed 9" {
+		t.Errorf("Unexpected string %q", got[0].String())
+	}
+}
@@ -0,0 +1,119 @@
+package itch
+
+// StockSymbol is the 8-byte, space-padded stock field of ITCH messages. It is
+// comparable and can key maps, so handlers can match and look up stocks
+// without converting every message's field into a string.
+type StockSymbol [8]byte
+
+// NewStockSymbol converts a symbol into a space-padded stock field. Symbols
+// longer than 8 characters are truncated.
+func NewStockSymbol(stock string) StockSymbol {
+	var s StockSymbol
+	n := copy(s[:], stock)
+	for i := n; i < len(s); i++ {
+		s[i] = ' '
+	}
+	return s
+}
+
+// Len returns the length of the symbol without its padding of spaces, or of
+// NULs for zero fields
+func (s StockSymbol) Len() int {
+	n := len(s)
+	for n > 0 && (s[n-1] == ' ' || s[n-1] == 0) {
+		n--
+	}
+	return n
+}
+
+// Trimmed returns the symbol without its padding. It allocates the string;
+// handlers resolving every message use a StockTable instead.
+func (s StockSymbol) Trimmed() string {
+	return string(s[:s.Len()])
+}
+
+// String returns the symbol without its padding
+func (s StockSymbol) String() string {
+	return s.Trimmed()
+}
+
+// Equal checks if two symbols are the same, ignoring their padding
+func (s StockSymbol) Equal(other StockSymbol) bool {
+	if s == other {
+		return true
+	}
+	n := s.Len()
+	return n == other.Len() && string(s[:n]) == string(other[:n])
+}
+
+// EqualString checks if the symbol is stock, without allocating
+func (s StockSymbol) EqualString(stock string) bool {
+	n := s.Len()
+	return n == len(stock) && string(s[:n]) == stock
+}
+
+// StockTable interns stock symbols: each distinct symbol gets a dense uint32
+// ID, starting at 1, and its trimmed string is allocated once, when it is
+// first seen. Looking a symbol up again does not allocate.
+//
+// StockTable is not safe for concurrent use.
+type StockTable struct {
+	ids     map[StockSymbol]uint32
+	symbols []StockSymbol
+	names   []string
+}
+
+// NewStockTable creates an empty stock table
+func NewStockTable() *StockTable {
+	return &StockTable{ids: make(map[StockSymbol]uint32)}
+}
+
+// Intern returns the ID of a symbol, assigning the next one to new symbols
+func (t *StockTable) Intern(s StockSymbol) uint32 {
+	if id, ok := t.ids[s]; ok {
+		return id
+	}
+	// Differently padded fields of the same symbol share its ID
+	padded := NewStockSymbol(s.Trimmed())
+	id, ok := t.ids[padded]
+	if !ok {
+		t.symbols = append(t.symbols, padded)
+		t.names = append(t.names, padded.Trimmed())
+		id = uint32(len(t.symbols))
+		t.ids[padded] = id
+	}
+	t.ids[s] = id
+	return id
+}
+
+// Lookup returns the ID of a symbol already interned
+func (t *StockTable) Lookup(s StockSymbol) (uint32, bool) {
+	id, ok := t.ids[s]
+	return id, ok
+}
+
+// Name interns a symbol and returns its trimmed string
+func (t *StockTable) Name(s StockSymbol) string {
+	return t.names[t.Intern(s)-1]
+}
+
+// Symbol returns the symbol of an ID, or the zero symbol if unknown
+func (t *StockTable) Symbol(id uint32) StockSymbol {
+	if id == 0 || int(id) > len(t.symbols) {
+		return StockSymbol{}
+	}
+	return t.symbols[id-1]
+}
+
+// NameOf returns the trimmed symbol of an ID, or "" if unknown
+func (t *StockTable) NameOf(id uint32) string {
+	if id == 0 || int(id) > len(t.names) {
+		return ""
+	}
+	return t.names[id-1]
+}
+
+// Len returns the number of interned symbols
+func (t *StockTable) Len() int {
+	return len(t.symbols)
+}
@@ -0,0 +1,58 @@
+package itch
+
+import "testing"
+
+func TestStockSymbol(t *testing.T) {
+	s := NewStockSymbol("AAPL")
+	if s != (StockSymbol{'A', 'A', 'P', 'L', ' ', ' ', ' ', ' '}) {
+		t.Errorf("Expected a space-padded field, got %q", s[:])
+	}
+	if s.Len() != 4 || s.Trimmed() != "AAPL" || s.String() != "AAPL" {
+		t.Errorf("Expected AAPL, got %q of length %d", s.Trimmed(), s.Len())
+	}
+	if NewStockSymbol("BRK.B.WI.X").Trimmed() != "BRK.B.WI" {
+		t.Error("Expected long symbols to be truncated")
+	}
+
+	zeroPadded := StockSymbol{'A', 'A', 'P', 'L'}
+	if !s.Equal(zeroPadded) || !zeroPadded.Equal(s) || s.Equal(NewStockSymbol("AAP")) {
+		t.Error("Expected symbols to compare without their padding")
+	}
+	if !s.EqualString("AAPL") || s.EqualString("AAP") || s.EqualString("AAPL ") {
+		t.Error("Expected symbols to compare with trimmed strings")
+	}
+	if (StockSymbol{}).Trimmed() != "" {
+		t.Error("Expected the zero symbol to be empty")
+	}
+}
+
+func TestStockTable(t *testing.T) {
+	table := NewStockTable()
+	aapl := table.Intern(NewStockSymbol("AAPL"))
+	msft := table.Intern(NewStockSymbol("MSFT"))
+	if aapl != 1 || msft != 2 || table.Len() != 2 {
+		t.Errorf("Expected dense IDs from 1, got %d and %d", aapl, msft)
+	}
+	if id := table.Intern(StockSymbol{'A', 'A', 'P', 'L'}); id != aapl || table.Len() != 2 {
+		t.Errorf("Expected differently padded AAPL to share ID %d, got %d", aapl, id)
+	}
+	if id, ok := table.Lookup(NewStockSymbol("MSFT")); !ok || id != msft {
+		t.Errorf("Expected MSFT as %d, got %d", msft, id)
+	}
+	if _, ok := table.Lookup(NewStockSymbol("TSLA")); ok {
+		t.Error("Expected TSLA not interned by Lookup")
+	}
+	if table.NameOf(msft) != "MSFT" || table.Symbol(aapl) != NewStockSymbol("AAPL") || table.NameOf(0) != "" || table.NameOf(3) != "" {
+		t.Error("Expected the symbols of the IDs")
+	}
+
+	stock := NewStockSymbol("AAPL")
+	allocs := testing.AllocsPerRun(100, func() {
+		if table.Name(stock) != "AAPL" {
+			t.Fatal("Expected AAPL")
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected interned names without allocation, got %v allocations", allocs)
+	}
+}
@@ -0,0 +1,202 @@
+package itch
+
+// Print is a single trade printed on the consolidated tape
+type Print struct {
+	// Timestamp is nanoseconds since midnight
+	Timestamp uint64
+	// StockLocate is the locate code of the traded stock
+	StockLocate uint16
+	// Stock is the trimmed stock symbol
+	Stock string
+	// MatchNumber is the Nasdaq generated day-unique match number
+	MatchNumber uint64
+	// Side is the side of the resting order ('B' or 'S'), or 0 for crosses
+	Side byte
+	// Shares is the number of shares traded
+	Shares uint64
+	// Price is the trade price (4 implied decimals)
+	Price uint32
+	// Cross is true if the print came from a Cross Trade message
+	Cross bool
+	// Broken is true if the trade was later broken by a Broken Trade message
+	Broken bool
+}
+
+// TapeHandler builds the time-ordered tape of printed trades from executions,
+// non-displayable trades and crosses. Broken Trade messages flag the affected
+// prints rather than removing them, so the tape keeps a full audit trail.
+type TapeHandler struct {
+	DefaultHandler
+
+	tracker orderTracker
+	prints  []Print
+	matches map[uint64][]int
+
+	// OnPrint is called for every new print (optional)
+	OnPrint func(p Print)
+	// OnBroken is called for every print flagged as broken (optional)
+	OnBroken func(p Print)
+}
+
+// NewTapeHandler creates a new tape handler
+func NewTapeHandler() *TapeHandler {
+	return &TapeHandler{
+		tracker: newOrderTracker(),
+		matches: make(map[uint64][]int),
+	}
+}
+
+// Directory returns the symbol directory of the handler
+func (h *TapeHandler) Directory() *SymbolDirectory {
+	return h.tracker.directory
+}
+
+// SetDirectory shares a symbol directory with other handlers. It must be set
+// before the first message.
+func (h *TapeHandler) SetDirectory(d *SymbolDirectory) {
+	h.tracker.directory = d
+}
+
+// Prints returns every print, including broken ones, in arrival order
+func (h *TapeHandler) Prints() []Print {
+	return h.prints
+}
+
+// ActivePrints returns the prints that have not been broken
+func (h *TapeHandler) ActivePrints() []Print {
+	active := make([]Print, 0, len(h.prints))
+	for _, p := range h.prints {
+		if !p.Broken {
+			active = append(active, p)
+		}
+	}
+	return active
+}
+
+// record appends a print to the tape
+func (h *TapeHandler) record(p Print) {
+	h.matches[p.MatchNumber] = append(h.matches[p.MatchNumber], len(h.prints))
+	h.prints = append(h.prints, p)
+	if h.OnPrint != nil {
+		h.OnPrint(p)
+	}
+}
+
+// OnStockDirectory registers the stock in the directory
+func (h *TapeHandler) OnStockDirectory(msg StockDirectoryMessage) error {
+	h.tracker.directory.Add(msg)
+	return nil
+}
+
+// OnAddOrder starts tracking the order
+func (h *TapeHandler) OnAddOrder(msg AddOrderMessage) error {
+	h.tracker.add(msg.Timestamp, msg.OrderReferenceNumber, msg.StockLocate, msg.Stock, msg.BuySellIndicator, msg.Shares, msg.Price)
+	return nil
+}
+
+// OnAddOrderMPID starts tracking the order
+func (h *TapeHandler) OnAddOrderMPID(msg AddOrderMPIDMessage) error {
+	h.tracker.addMPID(msg)
+	return nil
+}
+
+// OnOrderExecuted prints the execution at the resting order's price
+func (h *TapeHandler) OnOrderExecuted(msg OrderExecutedMessage) error {
+	order, ok := h.tracker.execute(msg.OrderReferenceNumber, msg.ExecutedShares)
+	if !ok {
+		return nil
+	}
+	h.record(Print{
+		Timestamp:   msg.Timestamp,
+		StockLocate: msg.StockLocate,
+		Stock:       h.tracker.stock(msg.StockLocate),
+		MatchNumber: msg.MatchNumber,
+		Side:        order.side,
+		Shares:      uint64(msg.ExecutedShares),
+		Price:       order.price,
+	})
+	return nil
+}
+
+// OnOrderExecutedWithPrice prints printable executions at the execution price
+func (h *TapeHandler) OnOrderExecutedWithPrice(msg OrderExecutedWithPriceMessage) error {
+	order, ok := h.tracker.execute(msg.OrderReferenceNumber, msg.ExecutedShares)
+	if !ok || msg.Printable != 'Y' {
+		return nil
+	}
+	h.record(Print{
+		Timestamp:   msg.Timestamp,
+		StockLocate: msg.StockLocate,
+		Stock:       h.tracker.stock(msg.StockLocate),
+		MatchNumber: msg.MatchNumber,
+		Side:        order.side,
+		Shares:      uint64(msg.ExecutedShares),
+		Price:       msg.ExecutionPrice,
+	})
+	return nil
+}
+
+// OnOrderCancel reduces the tracked order
+func (h *TapeHandler) OnOrderCancel(msg OrderCancelMessage) error {
+	h.tracker.cancel(msg.OrderReferenceNumber, msg.CanceledShares)
+	return nil
+}
+
+// OnOrderDelete stops tracking the order
+func (h *TapeHandler) OnOrderDelete(msg OrderDeleteMessage) error {
+	h.tracker.delete(msg.OrderReferenceNumber)
+	return nil
+}
+
+// OnOrderReplace moves the tracked order to its new reference number
+func (h *TapeHandler) OnOrderReplace(msg OrderReplaceMessage) error {
+	h.tracker.replace(msg.OriginalOrderReferenceNumber, msg.NewOrderReferenceNumber, msg.Shares, msg.Price)
+	return nil
+}
+
+// OnTrade prints a non-displayable order execution
+func (h *TapeHandler) OnTrade(msg TradeMessage) error {
+	h.tracker.register(msg.StockLocate, msg.Stock)
+	h.record(Print{
+		Timestamp:   msg.Timestamp,
+		StockLocate: msg.StockLocate,
+		Stock:       h.tracker.directory.Name(msg.Stock),
+		MatchNumber: msg.MatchNumber,
+		Side:        msg.BuySellIndicator,
+		Shares:      uint64(msg.Shares),
+		Price:       msg.Price,
+	})
+	return nil
+}
+
+// OnCrossTrade prints the cross volume, if any shares were matched
+func (h *TapeHandler) OnCrossTrade(msg CrossTradeMessage) error {
+	h.tracker.register(msg.StockLocate, msg.Stock)
+	if msg.Shares == 0 {
+		return nil
+	}
+	h.record(Print{
+		Timestamp:   msg.Timestamp,
+		StockLocate: msg.StockLocate,
+		Stock:       h.tracker.directory.Name(msg.Stock),
+		MatchNumber: msg.MatchNumber,
+		Shares:      msg.Shares,
+		Price:       msg.CrossPrice,
+		Cross:       true,
+	})
+	return nil
+}
+
+// OnBrokenTrade flags every print with the broken match number
+func (h *TapeHandler) OnBrokenTrade(msg BrokenTradeMessage) error {
+	for _, i := range h.matches[msg.MatchNumber] {
+		if h.prints[i].Broken {
+			continue
+		}
+		h.prints[i].Broken = true
+		if h.OnBroken != nil {
+			h.OnBroken(h.prints[i])
+		}
+	}
+	return nil
+}
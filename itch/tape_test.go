@@ -0,0 +1,131 @@
+package itch
+
+import "testing"
+
+// feedTrades sends two executions and a non-displayable trade for AAPL
+func feedTrades(h Handler) {
+	h.OnStockDirectory(StockDirectoryMessage{StockLocate: 1, Stock: stockField("AAPL")})
+	h.OnAddOrder(AddOrderMessage{StockLocate: 1, OrderReferenceNumber: 10, BuySellIndicator: 'S', Shares: 300, Stock: stockField("AAPL"), Price: 1000000})
+	h.OnOrderExecuted(OrderExecutedMessage{StockLocate: 1, OrderReferenceNumber: 10, ExecutedShares: 100, MatchNumber: 1})
+	h.OnOrderExecuted(OrderExecutedMessage{StockLocate: 1, OrderReferenceNumber: 10, ExecutedShares: 200, MatchNumber: 2})
+	h.OnTrade(TradeMessage{StockLocate: 1, BuySellIndicator: 'B', Shares: 100, Stock: stockField("AAPL"), Price: 1100000, MatchNumber: 3})
+}
+
+func TestTapeHandler_BrokenTrade(t *testing.T) {
+	h := NewTapeHandler()
+	var broken []Print
+	h.OnBroken = func(p Print) { broken = append(broken, p) }
+
+	feedTrades(h)
+	if len(h.Prints()) != 3 {
+		t.Fatalf("Expected 3 prints, got %d", len(h.Prints()))
+	}
+	if p := h.Prints()[0]; p.Price != 1000000 || p.Side != 'S' || p.Stock != "AAPL" {
+		t.Errorf("Expected execution priced from resting order, got %+v", p)
+	}
+
+	h.OnBrokenTrade(BrokenTradeMessage{StockLocate: 1, MatchNumber: 3})
+	if len(broken) != 1 || broken[0].MatchNumber != 3 {
+		t.Fatalf("Expected match 3 to be broken, got %+v", broken)
+	}
+	if !h.Prints()[2].Broken {
+		t.Error("Expected print to be flagged as broken")
+	}
+	if len(h.ActivePrints()) != 2 {
+		t.Errorf("Expected 2 active prints, got %d", len(h.ActivePrints()))
+	}
+
+	// Breaking the same match twice has no further effect
+	h.OnBrokenTrade(BrokenTradeMessage{StockLocate: 1, MatchNumber: 3})
+	if len(broken) != 1 {
+		t.Errorf("Expected broken callback once, got %d", len(broken))
+	}
+}
+
+func TestSymbolStats_BrokenTrade(t *testing.T) {
+	h := NewSymbolStats()
+	feedTrades(h)
+
+	s, ok := h.Stats("AAPL")
+	if !ok {
+		t.Fatal("Expected AAPL stats")
+	}
+	if s.Trades != 3 || s.Volume != 400 {
+		t.Errorf("Expected 3 trades / 400 shares, got %d / %d", s.Trades, s.Volume)
+	}
+	if s.High != 1100000 || s.Low != 1000000 {
+		t.Errorf("Expected range 1000000-1100000, got %d-%d", s.Low, s.High)
+	}
+
+	h.OnBrokenTrade(BrokenTradeMessage{StockLocate: 1, MatchNumber: 3})
+
+	s, _ = h.Stats("AAPL")
+	if s.Trades != 2 || s.Volume != 300 {
+		t.Errorf("Expected 2 trades / 300 shares, got %d / %d", s.Trades, s.Volume)
+	}
+	if s.VWAP() != 1000000 {
+		t.Errorf("Expected VWAP 1000000, got %f", s.VWAP())
+	}
+	if s.High != 1000000 {
+		t.Errorf("Expected high recomputed to 1000000, got %d", s.High)
+	}
+	if s.BrokenTrades != 1 || s.BrokenVolume != 100 {
+		t.Errorf("Expected 1 broken trade / 100 shares, got %d / %d", s.BrokenTrades, s.BrokenVolume)
+	}
+
+	// The range holds while a standing trade remains at the broken price, and
+	// other symbols do not affect it
+	h.OnTrade(TradeMessage{StockLocate: 2, Shares: 10, Stock: stockField("MSFT"), Price: 500000, MatchNumber: 4})
+	h.OnBrokenTrade(BrokenTradeMessage{StockLocate: 1, MatchNumber: 1})
+	s, _ = h.Stats("AAPL")
+	if s.Trades != 1 || s.High != 1000000 || s.Low != 1000000 {
+		t.Errorf("Expected 1 trade in range 1000000-1000000, got %d in %d-%d", s.Trades, s.Low, s.High)
+	}
+	h.OnBrokenTrade(BrokenTradeMessage{StockLocate: 1, MatchNumber: 2})
+	s, _ = h.Stats("AAPL")
+	if s.Trades != 0 || s.High != 0 || s.Low != 0 {
+		t.Errorf("Expected no trade and no range, got %d in %d-%d", s.Trades, s.Low, s.High)
+	}
+}
+
+func TestSymbolStats_OrderLifecycle(t *testing.T) {
+	h := NewSymbolStats()
+	stock := stockField("AAPL")
+
+	h.OnAddOrder(AddOrderMessage{Timestamp: 1000, StockLocate: 1, OrderReferenceNumber: 1, BuySellIndicator: 'B', Shares: 100, Stock: stock, Price: 1000000})
+	h.OnAddOrder(AddOrderMessage{Timestamp: 1000, StockLocate: 1, OrderReferenceNumber: 2, BuySellIndicator: 'S', Shares: 100, Stock: stock, Price: 1010000})
+	h.OnAddOrder(AddOrderMessage{Timestamp: 2000, StockLocate: 1, OrderReferenceNumber: 3, BuySellIndicator: 'S', Shares: 100, Stock: stock, Price: 1020000})
+	h.OnAddOrder(AddOrderMessage{Timestamp: 2000, StockLocate: 1, OrderReferenceNumber: 4, BuySellIndicator: 'B', Shares: 100, Stock: stock, Price: 990000})
+
+	// Order 1 is executed in two steps, order 2 is canceled, replaced twice
+	// and deleted, order 3 is fully canceled and order 4 keeps resting
+	h.OnOrderExecuted(OrderExecutedMessage{Timestamp: 3000, StockLocate: 1, OrderReferenceNumber: 1, ExecutedShares: 40, MatchNumber: 1})
+	h.OnOrderExecuted(OrderExecutedMessage{Timestamp: 5000, StockLocate: 1, OrderReferenceNumber: 1, ExecutedShares: 60, MatchNumber: 2})
+	h.OnOrderCancel(OrderCancelMessage{Timestamp: 3000, StockLocate: 1, OrderReferenceNumber: 2, CanceledShares: 50})
+	h.OnOrderReplace(OrderReplaceMessage{Timestamp: 4000, StockLocate: 1, OriginalOrderReferenceNumber: 2, NewOrderReferenceNumber: 5, Shares: 50, Price: 1005000})
+	h.OnOrderReplace(OrderReplaceMessage{Timestamp: 5000, StockLocate: 1, OriginalOrderReferenceNumber: 5, NewOrderReferenceNumber: 6, Shares: 50, Price: 1004000})
+	h.OnOrderDelete(OrderDeleteMessage{Timestamp: 9000, StockLocate: 1, OrderReferenceNumber: 6})
+	h.OnOrderCancel(OrderCancelMessage{Timestamp: 4000, StockLocate: 1, OrderReferenceNumber: 3, CanceledShares: 100})
+
+	s, _ := h.Stats("AAPL")
+	if s.Lifetime.Count != 3 {
+		t.Fatalf("Expected 3 completed orders, got %d", s.Lifetime.Count)
+	}
+	// Lifetimes are 2000ns (order 3), 4000ns (order 1) and 8000ns (order 2)
+	if s.Lifetime.Min != 2000 || s.Lifetime.Max != 8000 || s.Lifetime.Mean() != 14000/3 {
+		t.Errorf("Expected lifetimes 2000-8000ns with mean 4666ns, got %v", s.Lifetime)
+	}
+	if p50 := s.Lifetime.Percentile(0.5); p50 < 4000 || p50 > 4250 {
+		t.Errorf("Expected median lifetime ~4000ns, got %v", p50)
+	}
+	if s.ModifiedOrders != 1 || s.Modifications != 3 {
+		t.Errorf("Expected 1 modified order with 3 modifications, got %d / %d", s.ModifiedOrders, s.Modifications)
+	}
+	if s.ModificationsPerOrder() != 1 {
+		t.Errorf("Expected 1 modification per completed order, got %f", s.ModificationsPerOrder())
+	}
+	// 2 cancels and 1 delete over 2 executions
+	if s.CancelToTradeRatio() != 1.5 {
+		t.Errorf("Expected cancel-to-trade ratio 1.5, got %f", s.CancelToTradeRatio())
+	}
+}
@@ -0,0 +1,129 @@
+package itch
+
+import "strings"
+
+// trimMPID converts a space-padded ITCH attribution field into a string
+func trimMPID(mpid [4]byte) string {
+	return strings.TrimRight(string(mpid[:]), " ")
+}
+
+// trackedOrder is the minimal state of a resting order needed to price its executions
+type trackedOrder struct {
+	locate uint16
+	side   byte
+	shares uint32
+	price  uint32
+	// mpid is the attribution of orders added with MPID, empty otherwise
+	mpid string
+	// added is the timestamp of the add, kept across replaces
+	added uint64
+	// modifications is the number of partial cancels and replaces
+	modifications uint32
+}
+
+// orderTracker follows order lifecycles and locate codes so that handlers can
+// resolve the stock, side and price of executions, which ITCH only reports by
+// order reference number.
+type orderTracker struct {
+	directory *SymbolDirectory
+	orders    map[uint64]trackedOrder
+}
+
+// newOrderTracker creates an empty order tracker with its own directory
+func newOrderTracker() orderTracker {
+	return orderTracker{
+		directory: NewSymbolDirectory(),
+		orders:    make(map[uint64]trackedOrder),
+	}
+}
+
+// register remembers the stock symbol for a locate code
+func (t *orderTracker) register(locate uint16, stock StockSymbol) {
+	t.directory.Register(locate, stock)
+}
+
+// stock returns the stock symbol registered for a locate code
+func (t *orderTracker) stock(locate uint16) string {
+	return t.directory.Stock(locate)
+}
+
+// add starts tracking a new order added at timestamp
+func (t *orderTracker) add(timestamp uint64, ref uint64, locate uint16, stock StockSymbol, side byte, shares, price uint32) {
+	t.register(locate, stock)
+	t.orders[ref] = trackedOrder{locate: locate, side: side, shares: shares, price: price, added: timestamp}
+}
+
+// addMPID starts tracking a new order with MPID attribution
+func (t *orderTracker) addMPID(msg AddOrderMPIDMessage) {
+	t.register(msg.StockLocate, msg.Stock)
+	t.orders[msg.OrderReferenceNumber] = trackedOrder{
+		locate: msg.StockLocate,
+		side:   msg.BuySellIndicator,
+		shares: msg.Shares,
+		price:  msg.Price,
+		mpid:   trimMPID(msg.Attribution),
+		added:  msg.Timestamp,
+	}
+}
+
+// execute reduces an order by executed shares and returns its state before
+// the execution. Fully executed orders are no longer tracked.
+func (t *orderTracker) execute(ref uint64, shares uint32) (trackedOrder, bool) {
+	order, ok := t.orders[ref]
+	if !ok {
+		return trackedOrder{}, false
+	}
+	t.reduce(ref, order, shares)
+	return order, true
+}
+
+// cancel reduces an order by canceled shares and returns its state before
+// the cancel
+func (t *orderTracker) cancel(ref uint64, shares uint32) (trackedOrder, bool) {
+	order, ok := t.orders[ref]
+	if ok {
+		modified := order
+		modified.modifications++
+		t.reduce(ref, modified, shares)
+	}
+	return order, ok
+}
+
+// reduce removes shares from an order and stops tracking it when empty
+func (t *orderTracker) reduce(ref uint64, order trackedOrder, shares uint32) {
+	if shares >= order.shares {
+		delete(t.orders, ref)
+		return
+	}
+	order.shares -= shares
+	t.orders[ref] = order
+}
+
+// delete stops tracking an order and returns its last state
+func (t *orderTracker) delete(ref uint64) (trackedOrder, bool) {
+	order, ok := t.orders[ref]
+	if ok {
+		delete(t.orders, ref)
+	}
+	return order, ok
+}
+
+// replace moves an order to a new reference number with new shares and price.
+// The replacement keeps the attribution and add time of the original order.
+func (t *orderTracker) replace(oldRef, newRef uint64, shares, price uint32) (trackedOrder, bool) {
+	order, ok := t.orders[oldRef]
+	if !ok {
+		return trackedOrder{}, false
+	}
+	delete(t.orders, oldRef)
+	t.orders[newRef] = trackedOrder{
+		locate:        order.locate,
+		side:          order.side,
+		shares:        shares,
+		price:         price,
+		mpid:          order.mpid,
+		added:         order.added,
+		modifications: order.modifications + 1,
+	}
+	return order, true
+}
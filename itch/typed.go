@@ -0,0 +1,94 @@
+package itch
+
+// TypedParser parses ITCH messages into a handler of a concrete type.
+// Go compiles one instantiation per GC shape of H and calls the methods of H
+// through the dictionary of the instantiation: all pointer handlers share an
+// instantiation, and handler calls stay indirect and are not inlined, as
+// through the Handler interface of Parser. TypedParser only saves the
+// dispatch overhead of Parser, such as its callback lookup. Parser remains
+// for handlers that are only known at run time, such as middleware chains.
+type TypedParser[H Handler] struct {
+	handler H
+
+	// offset is the stream byte offset of the next message
+	offset int64
+	// index is the stream index of the next message
+	index uint64
+}
+
+// NewTypedParser creates a new ITCH parser for handler
+func NewTypedParser[H Handler](handler H) *TypedParser[H] {
+	return &TypedParser[H]{handler: handler}
+}
+
+// Handler returns the handler messages are parsed into
+func (p *TypedParser[H]) Handler() H {
+	return p.handler
+}
+
+// Offset returns the stream byte offset of the next message to be parsed
+func (p *TypedParser[H]) Offset() int64 {
+	return p.offset
+}
+
+// MessageIndex returns the number of messages parsed so far
+func (p *TypedParser[H]) MessageIndex() uint64 {
+	return p.index
+}
+
+// Reset resets the stream position, e.g. before parsing a new file
+func (p *TypedParser[H]) Reset() {
+	p.offset = 0
+	p.index = 0
+}
+
+// Parse parses a single ITCH message, reporting errors as Parser.Parse does
+func (p *TypedParser[H]) Parse(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, ErrInsufficientData
+	}
+
+	consumed, err := parseMessage(p.handler, data)
+	if err != nil {
+		if err == ErrInsufficientData {
+			return consumed, err
+		}
+		err = wrapError(p.offset, p.index, data, consumed, err)
+		if consumed == 0 {
+			return 0, err
+		}
+	}
+
+	p.offset += int64(consumed)
+	p.index++
+	return consumed, err
+}
+
+// ParseAll parses all ITCH messages in the data and returns the bytes and
+// messages consumed, including a message whose handler failed
+func (p *TypedParser[H]) ParseAll(data []byte) (int, int, error) {
+	totalConsumed := 0
+	messageCount := 0
+
+	for len(data) > 0 {
+		consumed, err := p.Parse(data)
+		if err != nil {
+			if err == ErrInsufficientData {
+				break
+			}
+			if consumed > 0 {
+				totalConsumed += consumed
+				messageCount++
+			}
+			return totalConsumed, messageCount, err
+		}
+		if consumed == 0 {
+			break
+		}
+		totalConsumed += consumed
+		messageCount++
+		data = data[consumed:]
+	}
+
+	return totalConsumed, messageCount, nil
+}
@@ -0,0 +1,215 @@
+package itch
+
+import (
+	"compress/gzip"
+	"flag"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var samplePath = flag.String("sample", "", "ITCH capture for the Sample benchmarks")
+
+// maxSampleSize bounds the messages loaded from the capture
+const maxSampleSize = 64 << 20
+
+// volumeCounter is a value handler summing added and executed shares. Its
+// state lives behind pointers so that value receivers can update it.
+type volumeCounter struct {
+	*DefaultHandler
+	added    *uint64
+	executed *uint64
+}
+
+func newVolumeCounter() volumeCounter {
+	return volumeCounter{added: new(uint64), executed: new(uint64)}
+}
+
+func (h volumeCounter) OnAddOrder(msg AddOrderMessage) error {
+	*h.added += uint64(msg.Shares)
+	return nil
+}
+
+func (h volumeCounter) OnOrderExecuted(msg OrderExecutedMessage) error {
+	*h.executed += uint64(msg.ExecutedShares)
+	return nil
+}
+
+// pointerCounter is volumeCounter with pointer receivers
+type pointerCounter struct {
+	DefaultHandler
+	added    uint64
+	executed uint64
+}
+
+func (h *pointerCounter) OnAddOrder(msg AddOrderMessage) error {
+	h.added += uint64(msg.Shares)
+	return nil
+}
+
+func (h *pointerCounter) OnOrderExecuted(msg OrderExecutedMessage) error {
+	h.executed += uint64(msg.ExecutedShares)
+	return nil
+}
+
+// sampleSession encodes a session dominated by order flow, as a day of ITCH
+// is: for each order an add, a partial execution, a cancel and a delete
+func sampleSession(orders int) []byte {
+	stock := StockField("AAPL")
+	data := AppendSystemEvent(nil, SystemEventMessage{Timestamp: 1, EventCode: 'O'})
+	data = AppendStockDirectory(data, StockDirectoryMessage{StockLocate: 1, Timestamp: 1, Stock: stock, RoundLotSize: 100})
+	for i := 1; i <= orders; i++ {
+		ref := uint64(i)
+		ts := uint64(i) * 1000
+		data = AppendAddOrder(data, AddOrderMessage{StockLocate: 1, Timestamp: ts, OrderReferenceNumber: ref,
+			BuySellIndicator: 'B', Shares: 300, Stock: stock, Price: 1500000 + uint32(i%100)})
+		data = AppendOrderExecuted(data, OrderExecutedMessage{StockLocate: 1, Timestamp: ts + 1, OrderReferenceNumber: ref,
+			ExecutedShares: 100, MatchNumber: ref})
+		data = AppendOrderCancel(data, OrderCancelMessage{StockLocate: 1, Timestamp: ts + 2, OrderReferenceNumber: ref, CanceledShares: 100})
+		data = AppendOrderDelete(data, OrderDeleteMessage{StockLocate: 1, Timestamp: ts + 3, OrderReferenceNumber: ref})
+	}
+	return data
+}
+
+func TestTypedParser_MatchesParser(t *testing.T) {
+	var data []byte
+	for _, msg := range testMessages() {
+		data = appendMessage(data, msg)
+	}
+
+	want := &recordHandler{}
+	wantConsumed, wantCount, err := NewParser(want).ParseAll(data)
+	if err != nil {
+		t.Fatalf("Parser.ParseAll: %v", err)
+	}
+
+	typed := NewTypedParser(&recordHandler{})
+	consumed, count, err := typed.ParseAll(data)
+	if err != nil {
+		t.Fatalf("TypedParser.ParseAll: %v", err)
+	}
+	if consumed != wantConsumed || count != wantCount {
+		t.Errorf("Expected %d messages in %d bytes, got %d in %d", wantCount, wantConsumed, count, consumed)
+	}
+	if !reflect.DeepEqual(typed.Handler().msgs, want.msgs) {
+		t.Errorf("Expected messages %+v, got %+v", want.msgs, typed.Handler().msgs)
+	}
+	if typed.Offset() != int64(len(data)) || typed.MessageIndex() != uint64(wantCount) {
+		t.Errorf("Expected position %d/%d, got %d/%d", len(data), wantCount, typed.Offset(), typed.MessageIndex())
+	}
+
+	if _, err := typed.Parse(data[:5]); err != ErrInsufficientData {
+		t.Errorf("Expected ErrInsufficientData, got %v", err)
+	}
+}
+
+func TestTypedParser_ValueHandler(t *testing.T) {
+	counter := newVolumeCounter()
+	_, count, err := NewTypedParser(counter).ParseAll(sampleSession(10))
+	if err != nil {
+		t.Fatalf("ParseAll: %v", err)
+	}
+	if count != 42 {
+		t.Errorf("Expected 42 messages, got %d", count)
+	}
+	if *counter.added != 3000 || *counter.executed != 1000 {
+		t.Errorf("Expected 3000 added and 1000 executed shares, got %d and %d", *counter.added, *counter.executed)
+	}
+}
+
+func BenchmarkParser_Session(b *testing.B) {
+	data := sampleSession(10000)
+	parser := NewParser(&pointerCounter{})
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parser.ParseAll(data)
+	}
+}
+
+func BenchmarkTypedParser_SessionPointer(b *testing.B) {
+	data := sampleSession(10000)
+	parser := NewTypedParser(&pointerCounter{})
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parser.ParseAll(data)
+	}
+}
+
+func BenchmarkTypedParser_SessionValue(b *testing.B) {
+	data := sampleSession(10000)
+	parser := NewTypedParser(newVolumeCounter())
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parser.ParseAll(data)
+	}
+}
+
+// loadSample returns the messages at the start of the -sample capture,
+// without their length prefixes, skipping the benchmark without one
+func loadSample(b *testing.B) []byte {
+	if *samplePath == "" {
+		b.Skip("no -sample capture")
+	}
+	f, err := os.Open(*samplePath)
+	if err != nil {
+		b.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(*samplePath, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			b.Fatalf("gzip: %v", err)
+		}
+		r = gz
+	}
+
+	var data []byte
+	frames := NewFrameReader(r)
+	for len(data) < maxSampleSize {
+		msg, err := frames.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			b.Fatalf("Next: %v", err)
+		}
+		data = append(data, msg...)
+	}
+	return data
+}
+
+func BenchmarkParser_Sample(b *testing.B) {
+	data := loadSample(b)
+	parser := NewParser(&pointerCounter{})
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parser.ParseAll(data)
+	}
+}
+
+func BenchmarkTypedParser_SamplePointer(b *testing.B) {
+	data := loadSample(b)
+	parser := NewTypedParser(&pointerCounter{})
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parser.ParseAll(data)
+	}
+}
+
+func BenchmarkTypedParser_SampleValue(b *testing.B) {
+	data := loadSample(b)
+	parser := NewTypedParser(newVolumeCounter())
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parser.ParseAll(data)
+	}
+}
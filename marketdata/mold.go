@@ -0,0 +1,109 @@
+// Package marketdata publishes matching engine events as an outbound
+// market data feed.
+//
+// The Publisher converts MarketHandler events into NASDAQ ITCH 5.0 messages
+// framed in MoldUDP64 packets, so that go-trader can act as a miniature
+// exchange feed for downstream consumers under test.
+package marketdata
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// MoldUDP64 packet layout constants
+const (
+	// MoldHeaderSize is the size of the MoldUDP64 downstream packet header:
+	// 10 byte session, 8 byte sequence number and 2 byte message count
+	MoldHeaderSize = 20
+	// MoldEndOfSession is the message count that marks the end of a session
+	MoldEndOfSession = 0xFFFF
+	// DefaultMaxPacketSize keeps packets within a standard Ethernet MTU
+	DefaultMaxPacketSize = 1400
+)
+
+// Errors returned when decoding MoldUDP64 packets
+var (
+	ErrShortPacket     = errors.New("moldudp64: short packet")
+	ErrTruncatedPacket = errors.New("moldudp64: truncated message block")
+)
+
+// MoldPacket is a decoded MoldUDP64 downstream packet
+type MoldPacket struct {
+	// Session identifies the feed session (space padded)
+	Session [10]byte
+	// Sequence is the sequence number of the first message in the packet,
+	// or the next expected sequence number for heartbeats
+	Sequence uint64
+	// Count is the number of messages, 0 for a heartbeat or
+	// MoldEndOfSession at the end of the session
+	Count uint16
+	// Messages are the message payloads, which alias the decoded buffer
+	Messages [][]byte
+}
+
+// IsHeartbeat returns true if the packet carries no messages
+func (p MoldPacket) IsHeartbeat() bool {
+	return p.Count == 0
+}
+
+// IsEndOfSession returns true if the packet marks the end of the session
+func (p MoldPacket) IsEndOfSession() bool {
+	return p.Count == MoldEndOfSession
+}
+
+// SessionField converts a session name into a space-padded MoldUDP64 session.
+// Names longer than 10 characters are truncated.
+func SessionField(session string) [10]byte {
+	var field [10]byte
+	n := copy(field[:], session)
+	for i := n; i < len(field); i++ {
+		field[i] = ' '
+	}
+	return field
+}
+
+// AppendMoldHeader appends a MoldUDP64 packet header
+func AppendMoldHeader(b []byte, session [10]byte, sequence uint64, count uint16) []byte {
+	b = append(b, session[:]...)
+	b = binary.BigEndian.AppendUint64(b, sequence)
+	return binary.BigEndian.AppendUint16(b, count)
+}
+
+// AppendMoldMessage appends a length-prefixed message block
+func AppendMoldMessage(b []byte, msg []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(msg)))
+	return append(b, msg...)
+}
+
+// ParseMoldPacket decodes a MoldUDP64 downstream packet
+func ParseMoldPacket(data []byte) (MoldPacket, error) {
+	if len(data) < MoldHeaderSize {
+		return MoldPacket{}, ErrShortPacket
+	}
+
+	var p MoldPacket
+	copy(p.Session[:], data[0:10])
+	p.Sequence = binary.BigEndian.Uint64(data[10:18])
+	p.Count = binary.BigEndian.Uint16(data[18:20])
+	if p.Count == 0 || p.Count == MoldEndOfSession {
+		return p, nil
+	}
+
+	p.Messages = make([][]byte, 0, p.Count)
+	offset := MoldHeaderSize
+	for i := 0; i < int(p.Count); i++ {
+		if offset+2 > len(data) {
+			return MoldPacket{}, fmt.Errorf("%w: message %d of %d", ErrTruncatedPacket, i+1, p.Count)
+		}
+		size := int(binary.BigEndian.Uint16(data[offset:]))
+		offset += 2
+		if offset+size > len(data) {
+			return MoldPacket{}, fmt.Errorf("%w: message %d of %d", ErrTruncatedPacket, i+1, p.Count)
+		}
+		p.Messages = append(p.Messages, data[offset:offset+size])
+		offset += size
+	}
+	return p, nil
+}
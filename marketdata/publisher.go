@@ -0,0 +1,453 @@
+package marketdata
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tienpsm/go-trader/events"
+	"github.com/tienpsm/go-trader/itch"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// publishedOrder is the displayed state of an order as last published
+type publishedOrder struct {
+	locate uint16
+	side   byte
+	shares uint32
+	price  uint32
+	// added is the sequence number of the Add Order message, which orders
+	// the book in queue priority
+	added uint64
+}
+
+// execution is an order execution waiting for its trade to be completed
+type execution struct {
+	order    publishedOrder
+	id       uint64
+	price    uint64
+	quantity uint64
+}
+
+// Publisher is a matching.MarketHandler that publishes the engine's order
+// book as an ITCH 5.0 feed framed in MoldUDP64 packets.
+//
+// Symbols are published as Stock Directory messages with the symbol ID as
+// stock locate. Displayed limit orders are published as Add Order messages
+// with the order ID as reference number, and are followed by Order Executed,
+// Order Cancel and Order Delete messages. Price or size increases are
+// published as a delete followed by a new add with the same reference number.
+// Hidden quantity, market and stop orders are not published. The engine adds
+// incoming orders to the book before matching them, so an aggressive order is
+// published as an add followed by its executions.
+//
+// Engine prices are published unchanged as ITCH prices (4 implied decimals)
+// and must fit in 32 bits. Both executions of a trade share a match number.
+//
+// Each packet is written with a single Write call, so w is typically a UDP
+// connection from DialMulticast. The Publisher is safe for concurrent use by
+// the engine and the heartbeat goroutine.
+type Publisher struct {
+	matching.DefaultMarketHandler
+
+	mu      sync.Mutex
+	w       io.Writer
+	session [10]byte
+	maxSize int
+	// sequence is the sequence number of the next message to be sent
+	sequence uint64
+	packet   []byte
+	count    uint16
+	lastSend time.Time
+	err      error
+
+	clock   func() time.Time
+	stocks  map[uint32]itch.StockSymbol
+	orders  map[uint64]publishedOrder
+	pending []execution
+	match   uint64
+	msg     []byte
+
+	ticker *time.Ticker
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// DialMulticast opens a UDP connection for sending packets to a multicast
+// group address such as "239.1.1.1:30001"
+func DialMulticast(address string) (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return net.DialUDP("udp", nil, addr)
+}
+
+// NewPublisher creates a publisher writing packets of the given MoldUDP64
+// session to w. Sequence numbers start at 1.
+func NewPublisher(w io.Writer, session string) *Publisher {
+	return &Publisher{
+		w:        w,
+		session:  SessionField(session),
+		maxSize:  DefaultMaxPacketSize,
+		sequence: 1,
+		clock:    time.Now,
+		stocks:   make(map[uint32]itch.StockSymbol),
+		orders:   make(map[uint64]publishedOrder),
+	}
+}
+
+// SetMaxPacketSize sets the maximum size of a packet including its header
+func (p *Publisher) SetMaxPacketSize(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxSize = size
+}
+
+// SetClock replaces the clock used for message timestamps
+func (p *Publisher) SetClock(clock func() time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clock = clock
+}
+
+// Sequence returns the sequence number of the next message
+func (p *Publisher) Sequence() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sequence + uint64(p.count)
+}
+
+// Err returns the first error returned by the writer. Handler callbacks cannot
+// return errors, so publishing stops silently after a write failure.
+func (p *Publisher) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// StartHeartbeat flushes buffered messages every interval and sends a
+// heartbeat packet when nothing was sent during the last interval
+func (p *Publisher) StartHeartbeat(interval time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ticker != nil {
+		return
+	}
+
+	p.ticker = time.NewTicker(interval)
+	p.done = make(chan struct{})
+	p.wg.Add(1)
+	go func(ticker *time.Ticker, done chan struct{}) {
+		defer p.wg.Done()
+		for {
+			select {
+			case <-ticker.C:
+				p.mu.Lock()
+				if p.count > 0 {
+					p.flush()
+				} else if time.Since(p.lastSend) >= interval {
+					p.send(AppendMoldHeader(nil, p.session, p.sequence, 0))
+				}
+				p.mu.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}(p.ticker, p.done)
+}
+
+// SystemEvent publishes a System Event message (e.g. 'O' start of messages)
+func (p *Publisher) SystemEvent(code byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flushExecutions()
+	p.emit(itch.AppendSystemEvent(p.msg[:0], itch.SystemEventMessage{Timestamp: p.timestamp(), EventCode: code}))
+}
+
+// Flush sends the buffered messages
+func (p *Publisher) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flushExecutions()
+	p.flush()
+	return p.err
+}
+
+// Close publishes the end of messages system event, sends an end-of-session
+// packet and stops the heartbeat
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	ticker, done := p.ticker, p.done
+	p.ticker = nil
+	p.mu.Unlock()
+
+	if ticker != nil {
+		ticker.Stop()
+		close(done)
+		p.wg.Wait()
+	}
+
+	p.SystemEvent('C')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flush()
+	p.send(AppendMoldHeader(nil, p.session, p.sequence, MoldEndOfSession))
+	return p.err
+}
+
+// timestamp returns the current time in nanoseconds since midnight
+func (p *Publisher) timestamp() uint64 {
+	now := p.clock()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return uint64(now.Sub(midnight))
+}
+
+// emit appends an encoded message to the current packet, sending the packet
+// first if the message does not fit. Must be called with p.mu held.
+func (p *Publisher) emit(msg []byte) {
+	p.msg = msg
+	if p.count > 0 && len(p.packet)+2+len(msg) > p.maxSize {
+		p.flush()
+	}
+	if p.count == 0 {
+		p.packet = AppendMoldHeader(p.packet[:0], p.session, p.sequence, 0)
+	}
+	p.packet = AppendMoldMessage(p.packet, msg)
+	p.count++
+}
+
+// flush sends the current packet. Must be called with p.mu held.
+func (p *Publisher) flush() {
+	if p.count == 0 {
+		return
+	}
+	// Patch the message count into the header
+	p.packet[18] = byte(p.count >> 8)
+	p.packet[19] = byte(p.count)
+	p.send(p.packet)
+	p.sequence += uint64(p.count)
+	p.count = 0
+}
+
+// send writes one packet. Must be called with p.mu held.
+func (p *Publisher) send(packet []byte) {
+	if p.err != nil {
+		return
+	}
+	if _, err := p.w.Write(packet); err != nil {
+		p.err = err
+	}
+	p.lastSend = time.Now()
+}
+
+// flushExecutions publishes executions that were not completed by a trade,
+// e.g. from MarketManager.ExecuteOrder. Each gets its own match number.
+// Must be called with p.mu held.
+func (p *Publisher) flushExecutions() {
+	for _, e := range p.pending {
+		p.match++
+		p.emitExecution(e, p.match)
+	}
+	p.pending = p.pending[:0]
+}
+
+// emitExecution publishes an execution with the given match number.
+// Must be called with p.mu held.
+func (p *Publisher) emitExecution(e execution, match uint64) {
+	if uint32(e.price) == e.order.price {
+		p.emit(itch.AppendOrderExecuted(p.msg[:0], itch.OrderExecutedMessage{
+			StockLocate:          e.order.locate,
+			Timestamp:            p.timestamp(),
+			OrderReferenceNumber: e.id,
+			ExecutedShares:       uint32(e.quantity),
+			MatchNumber:          match,
+		}))
+		return
+	}
+	p.emit(itch.AppendOrderExecutedWithPrice(p.msg[:0], itch.OrderExecutedWithPriceMessage{
+		StockLocate:          e.order.locate,
+		Timestamp:            p.timestamp(),
+		OrderReferenceNumber: e.id,
+		ExecutedShares:       uint32(e.quantity),
+		MatchNumber:          match,
+		Printable:            'Y',
+		ExecutionPrice:       uint32(e.price),
+	}))
+}
+
+// emitAdd publishes an order if it has displayed quantity. Must be called
+// with p.mu held.
+func (p *Publisher) emitAdd(order matching.Order) {
+	if !order.IsLimit() || order.VisibleQuantity() == 0 {
+		return
+	}
+	side := byte('B')
+	if order.IsSell() {
+		side = 'S'
+	}
+	published := publishedOrder{
+		locate: uint16(order.SymbolID),
+		side:   side,
+		shares: uint32(order.VisibleQuantity()),
+		price:  uint32(order.Price),
+		added:  p.sequence + uint64(p.count),
+	}
+	p.orders[order.ID] = published
+	p.emit(p.appendAdd(p.msg[:0], order.ID, published))
+}
+
+// appendAdd appends the Add Order message of a published order. Must be
+// called with p.mu held.
+func (p *Publisher) appendAdd(b []byte, id uint64, published publishedOrder) []byte {
+	return itch.AppendAddOrder(b, itch.AddOrderMessage{
+		StockLocate:          published.locate,
+		Timestamp:            p.timestamp(),
+		OrderReferenceNumber: id,
+		BuySellIndicator:     published.side,
+		Shares:               published.shares,
+		Stock:                p.stocks[uint32(published.locate)],
+		Price:                published.price,
+	})
+}
+
+// appendDirectory appends the Stock Directory message of a symbol. Must be
+// called with p.mu held.
+func (p *Publisher) appendDirectory(b []byte, id uint32) []byte {
+	return itch.AppendStockDirectory(b, itch.StockDirectoryMessage{
+		StockLocate:  uint16(id),
+		Timestamp:    p.timestamp(),
+		Stock:        p.stocks[id],
+		RoundLotSize: 100,
+	})
+}
+
+// emitDelete publishes the removal of a published order. Must be called with
+// p.mu held.
+func (p *Publisher) emitDelete(id uint64, published publishedOrder) {
+	delete(p.orders, id)
+	p.emit(itch.AppendOrderDelete(p.msg[:0], itch.OrderDeleteMessage{
+		StockLocate:          published.locate,
+		Timestamp:            p.timestamp(),
+		OrderReferenceNumber: id,
+	}))
+}
+
+// Subscribe makes the publisher consume engine events from an events bus
+// subscriber instead of being installed as the engine's MarketHandler. The
+// subscriber must not be shared with handlers that block for long, as they
+// delay the feed.
+func (p *Publisher) Subscribe(s *events.Subscriber) {
+	events.Subscribe(s, events.TopicAddSymbol, p.OnAddSymbol)
+	events.Subscribe(s, events.TopicAddOrder, p.OnAddOrder)
+	events.Subscribe(s, events.TopicUpdateOrder, p.OnUpdateOrder)
+	events.Subscribe(s, events.TopicDeleteOrder, p.OnDeleteOrder)
+	events.Subscribe(s, events.TopicExecution, func(e events.Execution) {
+		p.OnExecuteOrder(e.Order, e.Price, e.Quantity)
+	})
+	events.Subscribe(s, events.TopicTrade, p.OnTrade)
+}
+
+// OnAddSymbol publishes a Stock Directory message
+func (p *Publisher) OnAddSymbol(symbol matching.Symbol) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flushExecutions()
+
+	p.stocks[symbol.ID] = itch.StockField(symbol.Name)
+	p.emit(p.appendDirectory(p.msg[:0], symbol.ID))
+}
+
+// OnAddOrder publishes an Add Order message for displayed limit orders
+func (p *Publisher) OnAddOrder(order matching.Order) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flushExecutions()
+	p.emitAdd(order)
+}
+
+// OnUpdateOrder publishes changes to the displayed price or size of an order
+func (p *Publisher) OnUpdateOrder(order matching.Order) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	published, ok := p.orders[order.ID]
+	visible := uint32(order.VisibleQuantity())
+	if ok && published.price == uint32(order.Price) && published.shares == visible {
+		return
+	}
+	p.flushExecutions()
+
+	switch {
+	case !ok:
+		p.emitAdd(order)
+	case published.price == uint32(order.Price) && visible < published.shares:
+		canceled := published.shares - visible
+		published.shares = visible
+		if visible == 0 {
+			delete(p.orders, order.ID)
+		} else {
+			p.orders[order.ID] = published
+		}
+		p.emit(itch.AppendOrderCancel(p.msg[:0], itch.OrderCancelMessage{
+			StockLocate:          published.locate,
+			Timestamp:            p.timestamp(),
+			OrderReferenceNumber: order.ID,
+			CanceledShares:       canceled,
+		}))
+	default:
+		p.emitDelete(order.ID, published)
+		p.emitAdd(order)
+	}
+}
+
+// OnDeleteOrder publishes an Order Delete message for published orders
+func (p *Publisher) OnDeleteOrder(order matching.Order) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if published, ok := p.orders[order.ID]; ok {
+		p.flushExecutions()
+		p.emitDelete(order.ID, published)
+	}
+}
+
+// OnExecuteOrder records the execution of a published order. It is published
+// together with the other side of the trade in OnTrade.
+func (p *Publisher) OnExecuteOrder(order matching.Order, price, quantity uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	published, ok := p.orders[order.ID]
+	if !ok {
+		return
+	}
+	p.pending = append(p.pending, execution{order: published, id: order.ID, price: price, quantity: quantity})
+
+	// Track the displayed size so that the following update or delete caused
+	// by this execution does not publish anything
+	visible := uint32(order.VisibleQuantity())
+	if order.LeavesQuantity == 0 || visible == 0 {
+		delete(p.orders, order.ID)
+	} else if visible < published.shares {
+		published.shares = visible
+		p.orders[order.ID] = published
+	}
+}
+
+// OnTrade publishes the pending executions of the trade with one match number
+func (p *Publisher) OnTrade(trade matching.Trade) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.pending) == 0 {
+		return
+	}
+	p.match++
+	for _, e := range p.pending {
+		p.emitExecution(e, p.match)
+	}
+	p.pending = p.pending[:0]
+}
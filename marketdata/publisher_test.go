@@ -0,0 +1,255 @@
+package marketdata
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tienpsm/go-trader/events"
+	"github.com/tienpsm/go-trader/itch"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// packetWriter records every written packet
+type packetWriter struct {
+	mu      sync.Mutex
+	packets [][]byte
+}
+
+func (w *packetWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.packets = append(w.packets, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func (w *packetWriter) decode(t *testing.T) []MoldPacket {
+	t.Helper()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	packets := make([]MoldPacket, 0, len(w.packets))
+	for _, data := range w.packets {
+		p, err := ParseMoldPacket(data)
+		if err != nil {
+			t.Fatalf("ParseMoldPacket: %v", err)
+		}
+		packets = append(packets, p)
+	}
+	return packets
+}
+
+// feedHandler records the parsed feed as a compact event list
+type feedHandler struct {
+	itch.DefaultHandler
+	events []string
+	stats  *itch.SymbolStats
+}
+
+func (h *feedHandler) OnStockDirectory(msg itch.StockDirectoryMessage) error {
+	h.events = append(h.events, "R")
+	return h.stats.OnStockDirectory(msg)
+}
+
+func (h *feedHandler) OnAddOrder(msg itch.AddOrderMessage) error {
+	h.events = append(h.events, "A")
+	return h.stats.OnAddOrder(msg)
+}
+
+func (h *feedHandler) OnOrderExecuted(msg itch.OrderExecutedMessage) error {
+	h.events = append(h.events, "E")
+	return h.stats.OnOrderExecuted(msg)
+}
+
+func (h *feedHandler) OnOrderExecutedWithPrice(msg itch.OrderExecutedWithPriceMessage) error {
+	h.events = append(h.events, "C")
+	return h.stats.OnOrderExecutedWithPrice(msg)
+}
+
+func (h *feedHandler) OnOrderCancel(msg itch.OrderCancelMessage) error {
+	h.events = append(h.events, "X")
+	return h.stats.OnOrderCancel(msg)
+}
+
+func (h *feedHandler) OnOrderDelete(msg itch.OrderDeleteMessage) error {
+	h.events = append(h.events, "D")
+	return h.stats.OnOrderDelete(msg)
+}
+
+func (h *feedHandler) OnSystemEvent(msg itch.SystemEventMessage) error {
+	h.events = append(h.events, "S"+string(msg.EventCode))
+	return nil
+}
+
+func TestPublisher_Feed(t *testing.T) {
+	w := &packetWriter{}
+	pub := NewPublisher(w, "TEST")
+	pub.SetMaxPacketSize(100)
+
+	manager := matching.NewMarketManagerWithHandler(pub)
+	manager.EnableMatching()
+	symbol := matching.NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+
+	pub.SystemEvent('O')
+	manager.AddOrder(*matching.NewLimitOrder(1, 1, matching.OrderSideSell, 10000, 100))
+	manager.AddOrder(*matching.NewLimitOrder(2, 1, matching.OrderSideBuy, 10100, 40))
+	manager.ReduceOrder(1, 10)
+	manager.DeleteOrder(1)
+	if err := pub.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	packets := w.decode(t)
+	if last := packets[len(packets)-1]; !last.IsEndOfSession() {
+		t.Errorf("Expected end of session packet, got count %d", last.Count)
+	}
+
+	handler := &feedHandler{stats: itch.NewSymbolStats()}
+	parser := itch.NewParser(handler)
+	expected := uint64(1)
+	for _, packet := range packets {
+		if string(packet.Session[:]) != "TEST      " {
+			t.Fatalf("Expected session TEST, got %q", packet.Session[:])
+		}
+		if packet.Sequence != expected {
+			t.Fatalf("Expected sequence %d, got %d", expected, packet.Sequence)
+		}
+		if len(packet.Messages) > 0 && len(packet.Messages) != int(packet.Count) {
+			t.Fatalf("Expected %d messages, got %d", packet.Count, len(packet.Messages))
+		}
+		for _, msg := range packet.Messages {
+			if _, err := parser.Parse(msg); err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+		}
+		expected += uint64(len(packet.Messages))
+	}
+	if pub.Sequence() != expected {
+		t.Errorf("Expected next sequence %d, got %d", expected, pub.Sequence())
+	}
+
+	// The buy order is the aggressor: it is added, then both sides execute at
+	// the resting price with a shared match number
+	want := []string{"R", "SO", "A", "A", "C", "E", "X", "D", "SC"}
+	if len(handler.events) != len(want) {
+		t.Fatalf("Expected events %v, got %v", want, handler.events)
+	}
+	for i := range want {
+		if handler.events[i] != want[i] {
+			t.Errorf("Expected events %v, got %v", want, handler.events)
+			break
+		}
+	}
+
+	stats, ok := handler.stats.Stats("AAPL")
+	if !ok || stats.Volume != 80 {
+		t.Errorf("Expected 80 shares printed (both sides), got %+v", stats)
+	}
+}
+
+func TestPublisher_BookChecksum(t *testing.T) {
+	w := &packetWriter{}
+	pub := NewPublisher(w, "TEST")
+
+	manager := matching.NewMarketManagerWithHandler(pub)
+	manager.EnableMatching()
+	symbol := matching.NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+
+	for i := uint64(0); i < 15; i++ {
+		manager.AddOrder(*matching.NewLimitOrder(i+1, 1, matching.OrderSideBuy, 10000-i*100, 100+i))
+		manager.AddOrder(*matching.NewLimitOrder(i+101, 1, matching.OrderSideSell, 10100+i*100, 200+i))
+	}
+	manager.AddOrder(*matching.NewLimitOrder(201, 1, matching.OrderSideBuy, 10200, 250))
+	manager.ReduceOrder(2, 30)
+	manager.DeleteOrder(3)
+	manager.ModifyOrder(104, 10150, 80)
+	if err := pub.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	builder := itch.NewBookBuilder()
+	parser := itch.NewParser(builder)
+	for _, packet := range w.decode(t) {
+		for _, msg := range packet.Messages {
+			if _, err := parser.Parse(msg); err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+		}
+	}
+
+	want := manager.GetOrderBook(1).Checksum()
+	if got := builder.Book("AAPL").Checksum(); got != want {
+		t.Errorf("Expected mirrored book checksum %08x, got %08x", want, got)
+	}
+}
+
+func TestPublisher_Subscribe(t *testing.T) {
+	w := &packetWriter{}
+	pub := NewPublisher(w, "TEST")
+	bus := events.NewBus()
+	pub.Subscribe(bus.NewSubscriber(256, events.Block))
+
+	manager := matching.NewMarketManagerWithHandler(events.NewMarketHandler(bus))
+	manager.EnableMatching()
+	symbol := matching.NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+
+	for i := uint64(0); i < 5; i++ {
+		manager.AddOrder(*matching.NewLimitOrder(i+1, 1, matching.OrderSideBuy, 10000-i*100, 100))
+		manager.AddOrder(*matching.NewLimitOrder(i+101, 1, matching.OrderSideSell, 10100+i*100, 100))
+	}
+	manager.AddOrder(*matching.NewLimitOrder(201, 1, matching.OrderSideSell, 9900, 150))
+	manager.ReduceOrder(3, 50)
+	bus.Close()
+	if err := pub.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	builder := itch.NewBookBuilder()
+	parser := itch.NewParser(builder)
+	for _, packet := range w.decode(t) {
+		for _, msg := range packet.Messages {
+			if _, err := parser.Parse(msg); err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+		}
+	}
+
+	want := manager.GetOrderBook(1).Checksum()
+	if got := builder.Book("AAPL").Checksum(); got != want {
+		t.Errorf("Expected mirrored book checksum %08x, got %08x", want, got)
+	}
+}
+
+func TestPublisher_Heartbeat(t *testing.T) {
+	w := &packetWriter{}
+	pub := NewPublisher(w, "HB")
+	pub.StartHeartbeat(5 * time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	if err := pub.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	packets := w.decode(t)
+	if len(packets) < 3 {
+		t.Fatalf("Expected heartbeats, got %d packets", len(packets))
+	}
+	if !packets[0].IsHeartbeat() || packets[0].Sequence != 1 {
+		t.Errorf("Expected heartbeat with next sequence 1, got %+v", packets[0])
+	}
+}
+
+func TestParseMoldPacket_Truncated(t *testing.T) {
+	data := AppendMoldHeader(nil, SessionField("S"), 1, 2)
+	data = AppendMoldMessage(data, []byte{'S', 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 'O'})
+	if _, err := ParseMoldPacket(data); err == nil {
+		t.Error("Expected truncated packet error")
+	}
+	if _, err := ParseMoldPacket(data[:10]); err != ErrShortPacket {
+		t.Errorf("Expected ErrShortPacket, got %v", err)
+	}
+}
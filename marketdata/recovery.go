@@ -0,0 +1,125 @@
+package marketdata
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tienpsm/go-trader/itch"
+)
+
+// ErrSequenceGap is returned when a recovered feed skips messages. The book
+// is stale until Reset and a new snapshot are applied.
+var ErrSequenceGap = errors.New("marketdata: sequence gap")
+
+// sequencedMessage is a feed message buffered while waiting for a snapshot
+type sequencedMessage struct {
+	sequence uint64
+	data     []byte
+}
+
+// Recovery applies the feed of one stock to a handler for a subscriber
+// joining late: feed messages are buffered until a snapshot is applied, then
+// the buffered messages from the snapshot's sequence number are applied, and
+// later messages are applied as they arrive. Messages of other stocks are
+// skipped; system messages (stock locate 0) are applied.
+type Recovery struct {
+	parser   *itch.Parser
+	locate   uint16
+	next     uint64
+	synced   bool
+	buffered []sequencedMessage
+}
+
+// NewRecovery creates a recovery applying messages to handler
+func NewRecovery(handler itch.Handler) *Recovery {
+	return &Recovery{parser: itch.NewParser(handler)}
+}
+
+// Synced returns true once a snapshot has been applied
+func (r *Recovery) Synced() bool {
+	return r.synced
+}
+
+// Next returns the sequence number of the next message to be applied, 0
+// until a snapshot has been applied
+func (r *Recovery) Next() uint64 {
+	return r.next
+}
+
+// Buffered returns the number of messages waiting for a snapshot
+func (r *Recovery) Buffered() int {
+	return len(r.buffered)
+}
+
+// Reset discards the buffered messages and waits for a new snapshot. The
+// handler must be reset by the caller.
+func (r *Recovery) Reset() {
+	r.synced = false
+	r.next = 0
+	r.buffered = r.buffered[:0]
+	r.parser.Reset()
+}
+
+// OnPacket handles the messages of a MoldUDP64 packet
+func (r *Recovery) OnPacket(packet MoldPacket) error {
+	for i, msg := range packet.Messages {
+		if err := r.OnMessage(packet.Sequence+uint64(i), msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnMessage handles a feed message and its sequence number. Messages already
+// applied are skipped, so retransmitted or duplicated packets are harmless.
+func (r *Recovery) OnMessage(sequence uint64, msg []byte) error {
+	if !r.synced {
+		r.buffered = append(r.buffered, sequencedMessage{sequence: sequence, data: append([]byte(nil), msg...)})
+		return nil
+	}
+	return r.apply(sequence, msg)
+}
+
+// ApplySnapshot applies a snapshot to the handler, followed by the buffered
+// messages it does not reflect. ErrSequenceGap is returned if the buffered
+// messages do not reach back to the snapshot.
+func (r *Recovery) ApplySnapshot(snapshot Snapshot) error {
+	if len(snapshot.Messages) == 0 || snapshot.Messages[0][0] != itch.MessageTypeStockDirectory || len(snapshot.Messages[0]) < 3 {
+		return fmt.Errorf("%w: missing stock directory", ErrInvalidSnapshot)
+	}
+	r.locate = uint16(snapshot.Messages[0][1])<<8 | uint16(snapshot.Messages[0][2])
+	for _, msg := range snapshot.Messages {
+		if _, err := r.parser.Parse(msg); err != nil {
+			return err
+		}
+	}
+
+	r.synced = true
+	r.next = snapshot.Sequence
+	buffered := r.buffered
+	r.buffered = nil
+	for _, m := range buffered {
+		if err := r.apply(m.sequence, m.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply applies a message in sequence
+func (r *Recovery) apply(sequence uint64, msg []byte) error {
+	if sequence < r.next {
+		return nil
+	}
+	if sequence > r.next {
+		return fmt.Errorf("%w: expected %d, got %d", ErrSequenceGap, r.next, sequence)
+	}
+	r.next++
+	if len(msg) >= 3 {
+		if locate := uint16(msg[1])<<8 | uint16(msg[2]); locate != 0 && locate != r.locate {
+			return nil
+		}
+	}
+	_, err := r.parser.Parse(msg)
+	return err
+}
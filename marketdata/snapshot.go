@@ -0,0 +1,241 @@
+package marketdata
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/tienpsm/go-trader/itch"
+)
+
+// Snapshot requests are served over a TCP stream of length-prefixed frames,
+// as read by itch.FrameReader. A request is a frame holding the stock name.
+// The response is a frame holding a status byte, the 8 byte sequence number
+// and the 4 byte message count of the snapshot, followed by one frame per
+// message. Unknown stocks are answered with snapshotUnknown and no messages.
+const (
+	snapshotAccepted   = 'A'
+	snapshotUnknown    = 'U'
+	snapshotHeaderSize = 13
+)
+
+// Errors returned when requesting snapshots
+var (
+	ErrUnknownStock    = errors.New("marketdata: unknown stock")
+	ErrInvalidSnapshot = errors.New("marketdata: invalid snapshot response")
+)
+
+// Snapshot is the book of a stock at a point in the feed
+type Snapshot struct {
+	// Sequence is the sequence number of the first feed message not
+	// reflected in the snapshot
+	Sequence uint64
+	// Messages rebuild the book: the Stock Directory message followed by an
+	// Add Order message per displayed order, in queue priority
+	Messages [][]byte
+}
+
+// Snapshot returns the published book of a stock. Applying its messages and
+// then the feed from its sequence number mirrors the book, so a subscriber
+// joining late only needs to buffer the feed while it requests a snapshot.
+// Executions waiting for their trade are not in the feed yet, so the orders
+// they executed are in the snapshot as they were before.
+func (p *Publisher) Snapshot(stock string) (Snapshot, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	field := itch.StockField(stock)
+	var id uint32
+	found := false
+	for symbolID, s := range p.stocks {
+		if s == field {
+			id, found = symbolID, true
+			break
+		}
+	}
+	if !found {
+		return Snapshot{}, false
+	}
+
+	locate := uint16(id)
+	orders := make(map[uint64]publishedOrder)
+	for orderID, published := range p.orders {
+		if published.locate == locate {
+			orders[orderID] = published
+		}
+	}
+	// The earliest pending execution of an order holds its published state
+	for i := len(p.pending) - 1; i >= 0; i-- {
+		if e := p.pending[i]; e.order.locate == locate {
+			orders[e.id] = e.order
+		}
+	}
+	ids := make([]uint64, 0, len(orders))
+	for orderID := range orders {
+		ids = append(ids, orderID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return orders[ids[i]].added < orders[ids[j]].added })
+
+	snapshot := Snapshot{
+		Sequence: p.sequence + uint64(p.count),
+		Messages: make([][]byte, 0, len(ids)+1),
+	}
+	snapshot.Messages = append(snapshot.Messages, p.appendDirectory(nil, id))
+	for _, orderID := range ids {
+		snapshot.Messages = append(snapshot.Messages, p.appendAdd(nil, orderID, orders[orderID]))
+	}
+	return snapshot, true
+}
+
+// SnapshotServer serves publisher snapshots to late joining subscribers
+type SnapshotServer struct {
+	publisher *Publisher
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewSnapshotServer creates a server for the snapshots of a publisher
+func NewSnapshotServer(p *Publisher) *SnapshotServer {
+	return &SnapshotServer{publisher: p, conns: make(map[net.Conn]struct{})}
+}
+
+// Serve accepts connections on l until Close is called. Each connection may
+// request any number of snapshots.
+func (s *SnapshotServer) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return net.ErrClosed
+	}
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// serveConn answers the snapshot requests of a connection until it closes
+func (s *SnapshotServer) serveConn(conn net.Conn) {
+	frames := itch.NewFrameReader(conn)
+	var buf []byte
+	for {
+		request, err := frames.Next()
+		if err != nil {
+			return
+		}
+		snapshot, ok := s.publisher.Snapshot(string(request))
+		buf = appendSnapshot(buf[:0], snapshot, ok)
+		if _, err := conn.Write(buf); err != nil {
+			return
+		}
+	}
+}
+
+// Close stops accepting connections and closes the open ones
+func (s *SnapshotServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// appendSnapshot appends the response to a snapshot request
+func appendSnapshot(b []byte, snapshot Snapshot, ok bool) []byte {
+	var header [snapshotHeaderSize]byte
+	header[0] = snapshotUnknown
+	if ok {
+		header[0] = snapshotAccepted
+	}
+	binary.BigEndian.PutUint64(header[1:9], snapshot.Sequence)
+	binary.BigEndian.PutUint32(header[9:13], uint32(len(snapshot.Messages)))
+	b = itch.AppendFrame(b, header[:])
+	for _, msg := range snapshot.Messages {
+		b = itch.AppendFrame(b, msg)
+	}
+	return b
+}
+
+// RequestSnapshot requests the snapshot of a stock from a SnapshotServer over
+// conn. ErrUnknownStock is returned if the publisher has not published the
+// stock.
+func RequestSnapshot(conn io.ReadWriter, stock string) (Snapshot, error) {
+	if _, err := conn.Write(itch.AppendFrame(nil, []byte(stock))); err != nil {
+		return Snapshot{}, err
+	}
+
+	frames := itch.NewFrameReader(conn)
+	header, err := frames.Next()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if len(header) != snapshotHeaderSize {
+		return Snapshot{}, fmt.Errorf("%w: header of %d bytes", ErrInvalidSnapshot, len(header))
+	}
+	switch header[0] {
+	case snapshotAccepted:
+	case snapshotUnknown:
+		return Snapshot{}, fmt.Errorf("%w: %q", ErrUnknownStock, stock)
+	default:
+		return Snapshot{}, fmt.Errorf("%w: status %#x", ErrInvalidSnapshot, header[0])
+	}
+
+	snapshot := Snapshot{Sequence: binary.BigEndian.Uint64(header[1:9])}
+	count := binary.BigEndian.Uint32(header[9:13])
+	for i := uint32(0); i < count; i++ {
+		msg, err := frames.Next()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return Snapshot{}, err
+		}
+		snapshot.Messages = append(snapshot.Messages, append([]byte(nil), msg...))
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,139 @@
+package marketdata
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/tienpsm/go-trader/itch"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+func TestSnapshotServer_LateJoiner(t *testing.T) {
+	w := &packetWriter{}
+	pub := NewPublisher(w, "TEST")
+	pub.SetMaxPacketSize(120)
+
+	manager := matching.NewMarketManagerWithHandler(pub)
+	manager.EnableMatching()
+	for _, symbol := range []matching.Symbol{matching.NewSymbol(1, "AAPL"), matching.NewSymbol(2, "MSFT")} {
+		manager.AddSymbol(symbol)
+		manager.AddOrderBook(symbol)
+	}
+
+	for i := uint64(0); i < 10; i++ {
+		manager.AddOrder(*matching.NewLimitOrder(i+1, 1, matching.OrderSideBuy, 10000-i*100, 100+i))
+		manager.AddOrder(*matching.NewLimitOrder(i+101, 1, matching.OrderSideSell, 10100+i*100, 200+i))
+		manager.AddOrder(*matching.NewLimitOrder(i+301, 2, matching.OrderSideBuy, 5000-i*100, 50))
+	}
+	if err := pub.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	// The subscriber joins here and buffers the feed while the book changes
+	joined := len(w.packets)
+	manager.AddOrder(*matching.NewLimitOrder(201, 1, matching.OrderSideBuy, 10200, 250))
+	manager.ReduceOrder(2, 30)
+	manager.DeleteOrder(3)
+	manager.AddOrder(*matching.NewLimitOrder(202, 1, matching.OrderSideBuy, 10000, 70))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	server := NewSnapshotServer(pub)
+	go server.Serve(l)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	snapshot, err := RequestSnapshot(conn, "AAPL")
+	if err != nil {
+		t.Fatalf("RequestSnapshot: %v", err)
+	}
+	if snapshot.Sequence != pub.Sequence() {
+		t.Errorf("Expected snapshot sequence %d, got %d", pub.Sequence(), snapshot.Sequence)
+	}
+	if _, err := RequestSnapshot(conn, "GOOG"); !errors.Is(err, ErrUnknownStock) {
+		t.Errorf("Expected ErrUnknownStock, got %v", err)
+	}
+	requested := len(w.packets)
+
+	manager.ModifyOrder(104, 10150, 80)
+	manager.AddOrder(*matching.NewLimitOrder(203, 1, matching.OrderSideSell, 9900, 120))
+	manager.AddOrder(*matching.NewLimitOrder(311, 2, matching.OrderSideSell, 4000, 500))
+	if err := pub.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	builder := itch.NewBookBuilder()
+	recovery := NewRecovery(builder)
+	packets := w.decode(t)
+	for _, packet := range packets[joined:requested] {
+		if err := recovery.OnPacket(packet); err != nil {
+			t.Fatalf("OnPacket: %v", err)
+		}
+	}
+	if recovery.Synced() || recovery.Buffered() == 0 {
+		t.Fatalf("Expected buffered messages before the snapshot, got %d", recovery.Buffered())
+	}
+	if err := recovery.ApplySnapshot(snapshot); err != nil {
+		t.Fatalf("ApplySnapshot: %v", err)
+	}
+	for _, packet := range packets[requested:] {
+		if err := recovery.OnPacket(packet); err != nil {
+			t.Fatalf("OnPacket: %v", err)
+		}
+	}
+
+	want := manager.GetOrderBook(1).Checksum()
+	if got := builder.Book("AAPL").Checksum(); got != want {
+		t.Errorf("Expected recovered book checksum %08x, got %08x", want, got)
+	}
+	if book := builder.Book("MSFT"); book != nil {
+		t.Errorf("Expected other stocks to be skipped, got a MSFT book")
+	}
+	if recovery.Next() != pub.Sequence() {
+		t.Errorf("Expected next sequence %d, got %d", pub.Sequence(), recovery.Next())
+	}
+}
+
+func TestRecovery_Gap(t *testing.T) {
+	w := &packetWriter{}
+	pub := NewPublisher(w, "TEST")
+	pub.SetMaxPacketSize(60)
+
+	manager := matching.NewMarketManagerWithHandler(pub)
+	symbol := matching.NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+	manager.AddOrder(*matching.NewLimitOrder(1, 1, matching.OrderSideBuy, 10000, 100))
+
+	snapshot, ok := pub.Snapshot("AAPL")
+	if !ok {
+		t.Fatal("Expected a snapshot of AAPL")
+	}
+	if len(snapshot.Messages) != 2 {
+		t.Fatalf("Expected directory and one order, got %d messages", len(snapshot.Messages))
+	}
+
+	manager.AddOrder(*matching.NewLimitOrder(2, 1, matching.OrderSideBuy, 10000, 100))
+	manager.AddOrder(*matching.NewLimitOrder(3, 1, matching.OrderSideBuy, 10000, 100))
+	if err := pub.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	recovery := NewRecovery(itch.NewBookBuilder())
+	if err := recovery.ApplySnapshot(snapshot); err != nil {
+		t.Fatalf("ApplySnapshot: %v", err)
+	}
+	packets := w.decode(t)
+	last := packets[len(packets)-1]
+	if err := recovery.OnPacket(last); !errors.Is(err, ErrSequenceGap) {
+		t.Errorf("Expected ErrSequenceGap, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package matching
+
+import "math/bits"
+
+// Allocation selects how an aggressive order is shared out among the resting
+// orders of the price level it trades with
+type Allocation uint8
+
+const (
+	// AllocationFIFO fills resting orders in time priority
+	AllocationFIFO Allocation = iota
+	// AllocationProRata fills resting orders in proportion to their leaves
+	// quantity, hidden quantity included, as on CME futures:
+	//
+	//  1. the top order, which opened the best level and is still first in
+	//     the queue, is filled up to TopOrderQuantity
+	//  2. FIFOPercent of the rest is allocated in time priority
+	//  3. the rest is allocated in proportion to what every order has left,
+	//     rounded down, allocations below MinAllocation dropping to 0
+	//  4. what rounding left over is allocated in time priority
+	//
+	// Minimum quantity orders are not accepted in pro rata books.
+	AllocationProRata
+)
+
+// String returns the string representation of an Allocation
+func (a Allocation) String() string {
+	switch a {
+	case AllocationFIFO:
+		return "FIFO"
+	case AllocationProRata:
+		return "PRO_RATA"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// fill is the quantity allocated to a resting order
+type fill struct {
+	order    *OrderNode
+	quantity uint64
+}
+
+// markTop gives top order priority to an order entering a level if it
+// opened a new best level, and takes it away otherwise
+func markTop(ob *OrderBook, order *OrderNode) {
+	level := order.Level
+	order.top = level != nil && level.Orders == 1 && (level == ob.bestBid || level == ob.bestAsk)
+}
+
+// matchProRata matches the aggressor of a crossed bid and ask against the
+// best level on the other side of the book
+func (m *MarketManager) matchProRata(ob *OrderBook, bidOrder, askOrder *OrderNode) {
+	if Aggressor(bidOrder, askOrder) == OrderSideBuy {
+		for _, f := range ob.config.allocate(ob.bestAsk, bidOrder.LeavesQuantity) {
+			m.trade(ob, bidOrder, f.order, f.quantity)
+		}
+	} else {
+		for _, f := range ob.config.allocate(ob.bestBid, askOrder.LeavesQuantity) {
+			m.trade(ob, f.order, askOrder, f.quantity)
+		}
+	}
+}
+
+// allocate shares quantity out among the orders of a level following the
+// pro rata rules of the configuration. The fills are in queue order.
+func (c SymbolConfig) allocate(level *LevelNode, quantity uint64) []fill {
+	var orders []*OrderNode
+	var total uint64
+	for order := level.OrderList.Front(); order != nil; order = order.Next {
+		orders = append(orders, order)
+		total += order.LeavesQuantity
+	}
+	remaining := min(quantity, total)
+	allocated := make([]uint64, len(orders))
+	open := func(i int) uint64 { return orders[i].LeavesQuantity - allocated[i] }
+	give := func(i int, q uint64) {
+		q = min(q, open(i))
+		allocated[i] += q
+		remaining -= q
+	}
+	fifo := func(q uint64) {
+		for i := range orders {
+			if q == 0 {
+				return
+			}
+			before := remaining
+			give(i, q)
+			q -= before - remaining
+		}
+	}
+
+	if c.TopOrderQuantity > 0 && len(orders) > 0 && orders[0].top {
+		give(0, min(remaining, c.TopOrderQuantity))
+	}
+	fifo(remaining * c.FIFOPercent / 100)
+
+	if base := remaining; base > 0 {
+		var left uint64
+		for i := range orders {
+			left += open(i)
+		}
+		shares := make([]uint64, len(orders))
+		for i := range orders {
+			// base <= left, so the quotient fits and never exceeds open(i)
+			hi, lo := bits.Mul64(base, open(i))
+			shares[i], _ = bits.Div64(hi, lo, left)
+			if shares[i] < c.MinAllocation {
+				shares[i] = 0
+			}
+		}
+		for i, share := range shares {
+			give(i, share)
+		}
+	}
+	fifo(remaining)
+
+	var fills []fill
+	for i, q := range allocated {
+		if q > 0 {
+			fills = append(fills, fill{order: orders[i], quantity: q})
+		}
+	}
+	return fills
+}
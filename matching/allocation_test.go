@@ -0,0 +1,95 @@
+package matching
+
+import (
+	"fmt"
+	"testing"
+)
+
+// allocationHandler keeps the passive order and quantity of every trade
+type allocationHandler struct {
+	DefaultMarketHandler
+	fills []string
+}
+
+func (h *allocationHandler) OnTrade(trade Trade) {
+	passive := trade.SellOrderID
+	if trade.Aggressor == OrderSideSell {
+		passive = trade.BuyOrderID
+	}
+	h.fills = append(h.fills, fmt.Sprintf("%d:%d", passive, trade.Quantity))
+}
+
+// allocateLevel rests sells of 30, 60 and 10 at 10000 in a pro rata book,
+// then buys quantity and returns the fills of the resting orders
+func allocateLevel(t *testing.T, config SymbolConfig, quantity uint64) []string {
+	t.Helper()
+	handler := &allocationHandler{}
+	manager := newConfigManager(handler)
+	manager.EnableMatching()
+	config.Allocation = AllocationProRata
+	if err := manager.UpdateSymbolConfig(1, config); err != ErrorOK {
+		t.Fatalf("UpdateSymbolConfig failed: %s", err)
+	}
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideSell, 10000, 30))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideSell, 10000, 60))
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideSell, 10000, 10))
+	manager.AddOrder(*NewLimitOrder(4, 1, OrderSideBuy, 10000, quantity))
+	return handler.fills
+}
+
+func TestMarketManager_ProRataAllocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   SymbolConfig
+		quantity uint64
+		want     string
+	}{
+		{"proportional", SymbolConfig{}, 50, "[1:15 2:30 3:5]"},
+		{"rounding down, remainder FIFO", SymbolConfig{}, 7, "[1:3 2:4]"},
+		{"minimum allocation", SymbolConfig{MinAllocation: 3}, 7, "[1:3 2:4]"},
+		{"minimum allocation dropping all", SymbolConfig{MinAllocation: 5}, 7, "[1:7]"},
+		{"top order carve-out", SymbolConfig{TopOrderQuantity: 20}, 50, "[1:25 2:22 3:3]"},
+		{"top order capped by the match", SymbolConfig{TopOrderQuantity: 20}, 12, "[1:12]"},
+		{"FIFO share", SymbolConfig{FIFOPercent: 40}, 50, "[1:25 2:22 3:3]"},
+		{"all FIFO", SymbolConfig{FIFOPercent: 100}, 50, "[1:30 2:20]"},
+		{"whole level", SymbolConfig{TopOrderQuantity: 20, FIFOPercent: 50}, 150, "[1:30 2:60 3:10]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(allocateLevel(t, tt.config, tt.quantity)); got != tt.want {
+			t.Errorf("%s: Expected fills %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestMarketManager_ProRataTopOrder(t *testing.T) {
+	handler := &allocationHandler{}
+	manager := newConfigManager(handler)
+	manager.EnableMatching()
+	manager.UpdateSymbolConfig(1, SymbolConfig{Allocation: AllocationProRata, TopOrderQuantity: 100})
+
+	// Order 2 opens 10100 behind the best ask, so it has no top priority
+	// when 10000 trades away
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideSell, 10000, 10))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideSell, 10100, 10))
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideSell, 10100, 30))
+	manager.AddOrder(*NewLimitOrder(4, 1, OrderSideBuy, 10100, 30))
+	if got := fmt.Sprint(handler.fills); got != "[1:10 2:5 3:15]" {
+		t.Errorf("Expected fills [1:10 2:5 3:15], got %s", got)
+	}
+
+	// Sells trade pro rata against the bids too
+	handler.fills = nil
+	manager.AddOrder(*NewLimitOrder(5, 1, OrderSideBuy, 9900, 20))
+	manager.AddOrder(*NewLimitOrder(6, 1, OrderSideBuy, 9900, 60))
+	manager.AddOrder(*NewLimitOrder(7, 1, OrderSideSell, 9900, 40))
+	if got := fmt.Sprint(handler.fills); got != "[5:20 6:20]" {
+		t.Errorf("Expected fills [5:20 6:20], got %s", got)
+	}
+
+	if err := manager.AddOrder(minQuantityOrder(8, OrderSideBuy, 9800, 100, 10)); err != ErrorOrderParameterInvalid {
+		t.Errorf("Expected ErrorOrderParameterInvalid, got %s", err)
+	}
+	if (SymbolConfig{FIFOPercent: 101}).Validate() == nil {
+		t.Error("Expected a FIFO share above 100% to be invalid")
+	}
+}
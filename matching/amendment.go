@@ -0,0 +1,152 @@
+package matching
+
+import "fmt"
+
+// AmendmentType identifies the operation that changed a resting order
+type AmendmentType uint8
+
+const (
+	// AmendmentReduce is a partial cancel of the leaves quantity
+	AmendmentReduce AmendmentType = iota
+	// AmendmentModify is a price and quantity change that resets executions
+	AmendmentModify
+	// AmendmentMitigate is a price and quantity change that keeps executions
+	AmendmentMitigate
+	// AmendmentReplace is a cancel/replace to a new order ID
+	AmendmentReplace
+)
+
+// String returns the string representation of an AmendmentType
+func (t AmendmentType) String() string {
+	switch t {
+	case AmendmentReduce:
+		return "REDUCE"
+	case AmendmentModify:
+		return "MODIFY"
+	case AmendmentMitigate:
+		return "MITIGATE"
+	case AmendmentReplace:
+		return "REPLACE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Amendment is a single change of a resting order, kept for audit
+type Amendment struct {
+	// Type is the amending operation
+	Type AmendmentType
+	// Timestamp is the Unix time in nanoseconds of the amendment
+	Timestamp int64
+	// OrderID is the order ID before the amendment, which differs from the
+	// current ID for replaced orders
+	OrderID uint64
+
+	// Price is the price before the amendment
+	Price uint64
+	// Quantity is the total quantity before the amendment
+	Quantity uint64
+	// LeavesQuantity is the remaining quantity before the amendment
+	LeavesQuantity uint64
+
+	// NewPrice is the price after the amendment
+	NewPrice uint64
+	// NewQuantity is the total quantity after the amendment
+	NewQuantity uint64
+	// NewLeavesQuantity is the remaining quantity after the amendment
+	NewLeavesQuantity uint64
+}
+
+// String returns the string representation of an Amendment
+func (a Amendment) String() string {
+	return fmt.Sprintf("Amendment(Type=%s, Time=%d, OrderID=%d, Price=%d->%d, Quantity=%d->%d, Leaves=%d->%d)",
+		a.Type, a.Timestamp, a.OrderID, a.Price, a.NewPrice, a.Quantity, a.NewQuantity, a.LeavesQuantity, a.NewLeavesQuantity)
+}
+
+// amendmentRing keeps the most recent amendments of an order
+type amendmentRing struct {
+	entries []Amendment
+	// start is the index of the oldest entry once the ring is full
+	start int
+	size  int
+}
+
+// add appends an amendment, overwriting the oldest one when full
+func (r *amendmentRing) add(a Amendment) {
+	if len(r.entries) < r.size {
+		r.entries = append(r.entries, a)
+		return
+	}
+	r.entries[r.start] = a
+	r.start = (r.start + 1) % r.size
+}
+
+// list returns a copy of the amendments, oldest first
+func (r *amendmentRing) list() []Amendment {
+	result := make([]Amendment, 0, len(r.entries))
+	result = append(result, r.entries[r.start:]...)
+	return append(result, r.entries[:r.start]...)
+}
+
+// Amendments returns the recorded amendments of the order, oldest first.
+// It is empty unless amendment history is enabled on the market manager.
+func (on *OrderNode) Amendments() []Amendment {
+	if on.amendments == nil {
+		return nil
+	}
+	return on.amendments.list()
+}
+
+// EnableAmendmentHistory records the last depth amendments of every resting
+// order. Orders that already have a history keep its previous depth.
+func (m *MarketManager) EnableAmendmentHistory(depth int) {
+	if depth < 1 {
+		depth = 1
+	}
+	m.amendmentDepth = depth
+}
+
+// DisableAmendmentHistory stops recording amendments.
+// Amendments already recorded are kept with their orders.
+func (m *MarketManager) DisableAmendmentHistory() {
+	m.amendmentDepth = 0
+}
+
+// IsAmendmentHistoryEnabled returns true if amendments are recorded
+func (m *MarketManager) IsAmendmentHistoryEnabled() bool {
+	return m.amendmentDepth > 0
+}
+
+// AmendmentHistory returns the recorded amendments of a resting order, oldest
+// first. The history is discarded with the order, so handlers that build
+// execution reports should read it from OnExecuteOrder, which is called while
+// the order is still in the book.
+func (m *MarketManager) AmendmentHistory(id uint64) []Amendment {
+	orderNode, exists := m.orders[id]
+	if !exists {
+		return nil
+	}
+	return orderNode.Amendments()
+}
+
+// recordAmendment records a change of an order, given its new price and
+// quantities, before the order is updated
+func (m *MarketManager) recordAmendment(orderNode *OrderNode, amendment AmendmentType, newPrice, newQuantity, newLeaves uint64) {
+	if m.amendmentDepth == 0 {
+		return
+	}
+	if orderNode.amendments == nil {
+		orderNode.amendments = &amendmentRing{size: m.amendmentDepth}
+	}
+	orderNode.amendments.add(Amendment{
+		Type:              amendment,
+		Timestamp:         m.now().UnixNano(),
+		OrderID:           orderNode.ID,
+		Price:             orderNode.Price,
+		Quantity:          orderNode.Quantity,
+		LeavesQuantity:    orderNode.LeavesQuantity,
+		NewPrice:          newPrice,
+		NewQuantity:       newQuantity,
+		NewLeavesQuantity: newLeaves,
+	})
+}
@@ -0,0 +1,101 @@
+package matching
+
+import (
+	"testing"
+	"time"
+)
+
+// amendmentHandler records the amendment history seen by executions
+type amendmentHandler struct {
+	DefaultMarketHandler
+	manager *MarketManager
+	history []Amendment
+}
+
+func (h *amendmentHandler) OnExecuteOrder(order Order, price, quantity uint64) {
+	if order.Side == OrderSideBuy {
+		h.history = h.manager.AmendmentHistory(order.ID)
+	}
+}
+
+func TestMarketManager_AmendmentHistory(t *testing.T) {
+	handler := &amendmentHandler{}
+	manager := newConfigManager(handler)
+	handler.manager = manager
+
+	now := time.Unix(1700000000, 0)
+	manager.SetClock(func() time.Time { return now })
+	manager.EnableAmendmentHistory(8)
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 100))
+	now = now.Add(time.Second)
+	manager.ReduceOrder(1, 20)
+	now = now.Add(time.Second)
+	manager.ModifyOrder(1, 10100, 200)
+	now = now.Add(time.Second)
+	manager.MitigateOrder(1, 10200, 150)
+	now = now.Add(time.Second)
+	manager.ReplaceOrder(1, 2, 10300, 50)
+
+	if history := manager.AmendmentHistory(1); history != nil {
+		t.Errorf("Expected no history for replaced order, got %v", history)
+	}
+	history := manager.AmendmentHistory(2)
+	expected := []Amendment{
+		{Type: AmendmentReduce, OrderID: 1, Price: 10000, Quantity: 100, LeavesQuantity: 100, NewPrice: 10000, NewQuantity: 100, NewLeavesQuantity: 80},
+		{Type: AmendmentModify, OrderID: 1, Price: 10000, Quantity: 100, LeavesQuantity: 80, NewPrice: 10100, NewQuantity: 200, NewLeavesQuantity: 200},
+		{Type: AmendmentMitigate, OrderID: 1, Price: 10100, Quantity: 200, LeavesQuantity: 200, NewPrice: 10200, NewQuantity: 150, NewLeavesQuantity: 150},
+		{Type: AmendmentReplace, OrderID: 1, Price: 10200, Quantity: 150, LeavesQuantity: 150, NewPrice: 10300, NewQuantity: 50, NewLeavesQuantity: 50},
+	}
+	if len(history) != len(expected) {
+		t.Fatalf("Expected %d amendments, got %d: %v", len(expected), len(history), history)
+	}
+	for i, e := range expected {
+		e.Timestamp = time.Unix(1700000001+int64(i), 0).UnixNano()
+		if history[i] != e {
+			t.Errorf("Amendment %d: expected %s, got %s", i, e, history[i])
+		}
+	}
+
+	// The history is still available when the order is executed
+	manager.EnableMatching()
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideSell, 10300, 50))
+	if len(handler.history) != len(expected) {
+		t.Errorf("Expected %d amendments in execution, got %d", len(expected), len(handler.history))
+	}
+	if manager.GetOrder(2) != nil {
+		t.Error("Expected order 2 to be filled")
+	}
+}
+
+func TestMarketManager_AmendmentHistoryBounded(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.EnableAmendmentHistory(3)
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 100))
+	for i := uint64(1); i <= 5; i++ {
+		manager.ModifyOrder(1, 10000+i, 100)
+	}
+
+	history := manager.AmendmentHistory(1)
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 amendments, got %d", len(history))
+	}
+	for i, a := range history {
+		if a.NewPrice != 10003+uint64(i) {
+			t.Errorf("Amendment %d: expected new price %d, got %d", i, 10003+i, a.NewPrice)
+		}
+	}
+
+	manager.DisableAmendmentHistory()
+	manager.ModifyOrder(1, 10010, 100)
+	if len(manager.AmendmentHistory(1)) != 3 {
+		t.Error("Expected no amendment recorded while disabled")
+	}
+
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideBuy, 10000, 100))
+	manager.ModifyOrder(2, 10001, 100)
+	if history := manager.AmendmentHistory(2); history != nil {
+		t.Errorf("Expected no history when disabled, got %v", history)
+	}
+}
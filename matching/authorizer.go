@@ -0,0 +1,156 @@
+package matching
+
+// Operation identifies an order operation checked by an Authorizer
+type Operation uint8
+
+const (
+	// OperationAddOrder is the submission of a new order
+	OperationAddOrder Operation = iota
+	// OperationReduceOrder is a partial cancel
+	OperationReduceOrder
+	// OperationModifyOrder is a price and quantity change
+	OperationModifyOrder
+	// OperationMitigateOrder is a price and quantity change that keeps executions
+	OperationMitigateOrder
+	// OperationReplaceOrder is a cancel/replace to a new order ID
+	OperationReplaceOrder
+	// OperationDeleteOrder is a cancel
+	OperationDeleteOrder
+)
+
+// String returns the string representation of an Operation
+func (op Operation) String() string {
+	switch op {
+	case OperationAddOrder:
+		return "ADD_ORDER"
+	case OperationReduceOrder:
+		return "REDUCE_ORDER"
+	case OperationModifyOrder:
+		return "MODIFY_ORDER"
+	case OperationMitigateOrder:
+		return "MITIGATE_ORDER"
+	case OperationReplaceOrder:
+		return "REPLACE_ORDER"
+	case OperationDeleteOrder:
+		return "DELETE_ORDER"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Authorizer decides whether a participant may perform an operation.
+// It is consulted for every operation made through a Participant.
+type Authorizer interface {
+	// Authorize returns true if participantID may perform op on order.
+	// For OperationAddOrder order is the new order, otherwise it is the
+	// resting order the operation applies to.
+	Authorize(participantID uint32, op Operation, order *Order) bool
+}
+
+// AuthorizerFunc adapts a function to the Authorizer interface
+type AuthorizerFunc func(participantID uint32, op Operation, order *Order) bool
+
+// Authorize calls f
+func (f AuthorizerFunc) Authorize(participantID uint32, op Operation, order *Order) bool {
+	return f(participantID, op, order)
+}
+
+// OwnOrdersAuthorizer allows participants to add orders and to amend or cancel
+// only the orders they entered
+type OwnOrdersAuthorizer struct{}
+
+// Authorize allows additions and operations on the participant's own orders
+func (OwnOrdersAuthorizer) Authorize(participantID uint32, op Operation, order *Order) bool {
+	return op == OperationAddOrder || order.ParticipantID == participantID
+}
+
+// SetAuthorizer sets the authorizer consulted by Participant operations.
+// With no authorizer every participant operation is allowed.
+func (m *MarketManager) SetAuthorizer(authorizer Authorizer) {
+	m.authorizer = authorizer
+}
+
+// Participant returns a view of the market manager that performs order
+// operations on behalf of a participant, checking each one with the
+// authorizer. Operations made directly on the MarketManager are not checked,
+// so multi-tenant deployments should only hand Participants to clients.
+func (m *MarketManager) Participant(participantID uint32) Participant {
+	return Participant{manager: m, id: participantID}
+}
+
+// Participant performs order operations on behalf of a market participant
+type Participant struct {
+	manager *MarketManager
+	id      uint32
+}
+
+// ID returns the participant ID
+func (p Participant) ID() uint32 {
+	return p.id
+}
+
+// authorize checks an operation on a resting order
+func (p Participant) authorize(op Operation, id uint64) ErrorCode {
+	orderNode, exists := p.manager.orders[id]
+	if !exists {
+		return ErrorOrderNotFound
+	}
+	return p.check(op, &orderNode.Order)
+}
+
+// check consults the authorizer
+func (p Participant) check(op Operation, order *Order) ErrorCode {
+	if a := p.manager.authorizer; a != nil && !a.Authorize(p.id, op, order) {
+		return ErrorNotAuthorized
+	}
+	return ErrorOK
+}
+
+// AddOrder adds an order attributed to the participant
+func (p Participant) AddOrder(order Order) ErrorCode {
+	order.ParticipantID = p.id
+	if err := p.check(OperationAddOrder, &order); err != ErrorOK {
+		return err
+	}
+	return p.manager.AddOrder(order)
+}
+
+// ReduceOrder reduces the quantity of an order
+func (p Participant) ReduceOrder(id uint64, quantity uint64) ErrorCode {
+	if err := p.authorize(OperationReduceOrder, id); err != ErrorOK {
+		return err
+	}
+	return p.manager.ReduceOrder(id, quantity)
+}
+
+// ModifyOrder modifies an order
+func (p Participant) ModifyOrder(id uint64, newPrice, newQuantity uint64) ErrorCode {
+	if err := p.authorize(OperationModifyOrder, id); err != ErrorOK {
+		return err
+	}
+	return p.manager.ModifyOrder(id, newPrice, newQuantity)
+}
+
+// MitigateOrder mitigates an order
+func (p Participant) MitigateOrder(id uint64, newPrice, newQuantity uint64) ErrorCode {
+	if err := p.authorize(OperationMitigateOrder, id); err != ErrorOK {
+		return err
+	}
+	return p.manager.MitigateOrder(id, newPrice, newQuantity)
+}
+
+// ReplaceOrder replaces an order with a new one
+func (p Participant) ReplaceOrder(id uint64, newID uint64, newPrice, newQuantity uint64) ErrorCode {
+	if err := p.authorize(OperationReplaceOrder, id); err != ErrorOK {
+		return err
+	}
+	return p.manager.ReplaceOrder(id, newID, newPrice, newQuantity)
+}
+
+// DeleteOrder deletes an order
+func (p Participant) DeleteOrder(id uint64) ErrorCode {
+	if err := p.authorize(OperationDeleteOrder, id); err != ErrorOK {
+		return err
+	}
+	return p.manager.DeleteOrder(id)
+}
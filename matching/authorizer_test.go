@@ -0,0 +1,82 @@
+package matching
+
+import "testing"
+
+func TestParticipant_OwnOrdersAuthorizer(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.SetAuthorizer(OwnOrdersAuthorizer{})
+	alice := manager.Participant(1)
+	bob := manager.Participant(2)
+
+	if err := alice.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 100)); err != ErrorOK {
+		t.Fatalf("AddOrder failed: %s", err)
+	}
+	if order := manager.GetOrder(1); order == nil || order.ParticipantID != 1 {
+		t.Fatalf("Expected order attributed to participant 1, got %v", order)
+	}
+
+	if err := bob.DeleteOrder(1); err != ErrorNotAuthorized {
+		t.Errorf("Expected NOT_AUTHORIZED for delete, got %s", err)
+	}
+	if err := bob.ReduceOrder(1, 10); err != ErrorNotAuthorized {
+		t.Errorf("Expected NOT_AUTHORIZED for reduce, got %s", err)
+	}
+	if err := bob.ModifyOrder(1, 10100, 100); err != ErrorNotAuthorized {
+		t.Errorf("Expected NOT_AUTHORIZED for modify, got %s", err)
+	}
+	if err := bob.MitigateOrder(1, 10100, 100); err != ErrorNotAuthorized {
+		t.Errorf("Expected NOT_AUTHORIZED for mitigate, got %s", err)
+	}
+	if err := bob.ReplaceOrder(1, 2, 10100, 100); err != ErrorNotAuthorized {
+		t.Errorf("Expected NOT_AUTHORIZED for replace, got %s", err)
+	}
+	if order := manager.GetOrder(1); order == nil || order.Price != 10000 || order.LeavesQuantity != 100 {
+		t.Errorf("Expected order 1 unchanged, got %v", order)
+	}
+
+	if err := bob.DeleteOrder(99); err != ErrorOrderNotFound {
+		t.Errorf("Expected ORDER_NOT_FOUND, got %s", err)
+	}
+
+	if err := alice.ReplaceOrder(1, 2, 10100, 50); err != ErrorOK {
+		t.Fatalf("ReplaceOrder failed: %s", err)
+	}
+	if err := alice.DeleteOrder(2); err != ErrorOK {
+		t.Errorf("Expected owner to delete replaced order, got %s", err)
+	}
+}
+
+func TestParticipant_SymbolAuthorizer(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	msft := NewSymbol(2, "MSFT")
+	manager.AddSymbol(msft)
+	manager.AddOrderBook(msft)
+
+	var calls []Operation
+	manager.SetAuthorizer(AuthorizerFunc(func(participantID uint32, op Operation, order *Order) bool {
+		calls = append(calls, op)
+		return order.SymbolID == 1
+	}))
+
+	p := manager.Participant(7)
+	if err := p.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 100)); err != ErrorOK {
+		t.Errorf("Expected AAPL order allowed, got %s", err)
+	}
+	if err := p.AddOrder(*NewLimitOrder(2, 2, OrderSideBuy, 10000, 100)); err != ErrorNotAuthorized {
+		t.Errorf("Expected MSFT order rejected, got %s", err)
+	}
+	if manager.GetOrder(2) != nil {
+		t.Error("Expected rejected order not to be added")
+	}
+	if len(calls) != 2 || calls[0] != OperationAddOrder {
+		t.Errorf("Expected 2 add checks, got %v", calls)
+	}
+
+	// Operations made directly on the manager are not checked
+	if err := manager.AddOrder(*NewLimitOrder(3, 2, OrderSideBuy, 10000, 100)); err != ErrorOK {
+		t.Errorf("Expected direct AddOrder allowed, got %s", err)
+	}
+	if len(calls) != 2 {
+		t.Errorf("Expected no check for direct AddOrder, got %d checks", len(calls))
+	}
+}
@@ -361,3 +361,29 @@ func (t *AVLTree) forEach(node *LevelNode, fn func(*LevelNode) bool) bool {
 	}
 	return t.forEach(node.Right, fn)
 }
+
+// Range iterates in order over the levels priced from from to to inclusive,
+// which may be given in either order, visiting only the subtrees that
+// overlap the window
+func (t *AVLTree) Range(from, to uint64, fn func(*LevelNode) bool) {
+	if t.compare(from, to) > 0 {
+		from, to = to, from
+	}
+	t.forRange(t.root, from, to, fn)
+}
+
+func (t *AVLTree) forRange(node *LevelNode, from, to uint64, fn func(*LevelNode) bool) bool {
+	if node == nil {
+		return true
+	}
+	if t.compare(node.Price, from) > 0 && !t.forRange(node.Left, from, to, fn) {
+		return false
+	}
+	if t.compare(node.Price, from) >= 0 && t.compare(node.Price, to) <= 0 && !fn(node) {
+		return false
+	}
+	if t.compare(node.Price, to) < 0 {
+		return t.forRange(node.Right, from, to, fn)
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package matching
+
+import "testing"
+
+// levelPrices returns the prices of levels
+func levelPrices(levels []*LevelNode) []uint64 {
+	prices := make([]uint64, 0, len(levels))
+	for _, level := range levels {
+		prices = append(prices, level.Price)
+	}
+	return prices
+}
+
+func TestOrderBook_LevelsInRange(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	for i := uint64(0); i < 10; i++ {
+		manager.AddOrder(*NewLimitOrder(i+1, 1, OrderSideBuy, 9000+i*100, 10))
+		manager.AddOrder(*NewLimitOrder(i+11, 1, OrderSideSell, 11000+i*100, 10))
+	}
+	ob := manager.GetOrderBook(1)
+
+	tests := []struct {
+		side     OrderSide
+		from, to uint64
+		want     []uint64
+	}{
+		{OrderSideBuy, 9250, 9600, []uint64{9600, 9500, 9400, 9300}},
+		{OrderSideBuy, 9600, 9250, []uint64{9600, 9500, 9400, 9300}},
+		{OrderSideBuy, 9900, 20000, []uint64{9900}},
+		{OrderSideSell, 11200, 11400, []uint64{11200, 11300, 11400}},
+		{OrderSideSell, 0, 11050, []uint64{11000}},
+		{OrderSideSell, 11010, 11090, []uint64{}},
+		{OrderSideSell, 20000, 30000, []uint64{}},
+	}
+	for _, tt := range tests {
+		if got := levelPrices(ob.LevelsInRange(tt.side, tt.from, tt.to)); !equalIDs(got, tt.want) {
+			t.Errorf("LevelsInRange(%s, %d, %d): Expected %v, got %v", tt.side, tt.from, tt.to, tt.want, got)
+		}
+	}
+
+	// Iteration stops when the callback returns false
+	var visited int
+	ob.Asks().Range(0, 20000, func(level *LevelNode) bool {
+		visited++
+		return visited < 3
+	})
+	if visited != 3 {
+		t.Errorf("Expected 3 levels visited, got %d", visited)
+	}
+}
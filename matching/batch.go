@@ -0,0 +1,345 @@
+package matching
+
+import "sync"
+
+// EventType is the type of an Event, one per MarketHandler callback
+type EventType uint8
+
+const (
+	EventAddSymbol EventType = iota + 1
+	EventDeleteSymbol
+	EventAddOrderBook
+	EventUpdateOrderBook
+	EventDeleteOrderBook
+	EventBookCrossed
+	EventUpdateSymbolConfig
+	EventAddLevel
+	EventUpdateLevel
+	EventDeleteLevel
+	EventAddOrder
+	EventUpdateOrder
+	EventDeleteOrder
+	EventInvalidOrder
+	EventExecuteOrder
+	EventTrade
+	EventRejectOrder
+	EventExpireOrder
+)
+
+// String returns the string representation of an EventType
+func (t EventType) String() string {
+	switch t {
+	case EventAddSymbol:
+		return "ADD_SYMBOL"
+	case EventDeleteSymbol:
+		return "DELETE_SYMBOL"
+	case EventAddOrderBook:
+		return "ADD_ORDER_BOOK"
+	case EventUpdateOrderBook:
+		return "UPDATE_ORDER_BOOK"
+	case EventDeleteOrderBook:
+		return "DELETE_ORDER_BOOK"
+	case EventBookCrossed:
+		return "BOOK_CROSSED"
+	case EventUpdateSymbolConfig:
+		return "UPDATE_SYMBOL_CONFIG"
+	case EventAddLevel:
+		return "ADD_LEVEL"
+	case EventUpdateLevel:
+		return "UPDATE_LEVEL"
+	case EventDeleteLevel:
+		return "DELETE_LEVEL"
+	case EventAddOrder:
+		return "ADD_ORDER"
+	case EventUpdateOrder:
+		return "UPDATE_ORDER"
+	case EventDeleteOrder:
+		return "DELETE_ORDER"
+	case EventInvalidOrder:
+		return "INVALID_ORDER"
+	case EventExecuteOrder:
+		return "EXECUTE_ORDER"
+	case EventTrade:
+		return "TRADE"
+	case EventRejectOrder:
+		return "REJECT_ORDER"
+	case EventExpireOrder:
+		return "EXPIRE_ORDER"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Event is a MarketHandler callback recorded for batched delivery. Only the
+// fields of the callback's arguments are set.
+type Event struct {
+	Type EventType
+	// Sequence is the sequence of the operation, shared by all its events
+	Sequence uint64
+
+	// Symbol is the symbol of symbol events
+	Symbol Symbol
+	// OrderBook is the book of order book and level events. It is delivered
+	// after the operation, so its state is the state after the operation.
+	OrderBook *OrderBook
+	Config    SymbolConfig
+	Level     Level
+	Top       bool
+	Order     Order
+	// Price and Quantity are the execution of EventExecuteOrder
+	Price    uint64
+	Quantity uint64
+	Trade    Trade
+	// Code is the reason of EventRejectOrder
+	Code ErrorCode
+}
+
+// Dispatch calls the MarketHandler callback of the event
+func (e Event) Dispatch(handler MarketHandler) {
+	switch e.Type {
+	case EventAddSymbol:
+		handler.OnAddSymbol(e.Symbol)
+	case EventDeleteSymbol:
+		handler.OnDeleteSymbol(e.Symbol)
+	case EventAddOrderBook:
+		handler.OnAddOrderBook(e.OrderBook)
+	case EventUpdateOrderBook:
+		handler.OnUpdateOrderBook(e.OrderBook, e.Top)
+	case EventDeleteOrderBook:
+		handler.OnDeleteOrderBook(e.OrderBook)
+	case EventBookCrossed:
+		handler.OnBookCrossed(e.OrderBook)
+	case EventUpdateSymbolConfig:
+		handler.OnUpdateSymbolConfig(e.OrderBook, e.Config)
+	case EventAddLevel:
+		handler.OnAddLevel(e.OrderBook, e.Level, e.Top)
+	case EventUpdateLevel:
+		handler.OnUpdateLevel(e.OrderBook, e.Level, e.Top)
+	case EventDeleteLevel:
+		handler.OnDeleteLevel(e.OrderBook, e.Level, e.Top)
+	case EventAddOrder:
+		handler.OnAddOrder(e.Order)
+	case EventUpdateOrder:
+		handler.OnUpdateOrder(e.Order)
+	case EventDeleteOrder:
+		handler.OnDeleteOrder(e.Order)
+	case EventInvalidOrder:
+		handler.OnInvalidOrder(e.Order)
+	case EventExecuteOrder:
+		handler.OnExecuteOrder(e.Order, e.Price, e.Quantity)
+	case EventTrade:
+		handler.OnTrade(e.Trade)
+	case EventRejectOrder:
+		handler.OnRejectOrder(e.Order, e.Code)
+	case EventExpireOrder:
+		handler.OnExpireOrder(e.Order)
+	}
+}
+
+// BatchHandler receives the events of every operation of a market manager in
+// batched mode as one slice, in the order of the MarketHandler contract. The
+// slice must not be retained after OnEvents returns.
+type BatchHandler interface {
+	OnEvents(events []Event)
+}
+
+// BatchHandlerFunc adapts a function to a BatchHandler
+type BatchHandlerFunc func(events []Event)
+
+// OnEvents calls f
+func (f BatchHandlerFunc) OnEvents(events []Event) { f(events) }
+
+// batcher is the MarketHandler of a market manager in batched mode. It
+// records the events of an operation and delivers them when the outermost
+// operation ends; events raised outside an operation are delivered alone.
+type batcher struct {
+	handler BatchHandler
+	// depth is the number of operations in progress, more than one when an
+	// operation calls others
+	depth  int
+	events []Event
+
+	// mu serializes the delivery of batchers sharing sequence, nil if the
+	// sequence is not shared
+	mu       *sync.Mutex
+	sequence *uint64
+}
+
+// begin starts an operation
+func (b *batcher) begin() {
+	b.depth++
+}
+
+// end ends an operation, delivering its events if it is the outermost one
+func (b *batcher) end() {
+	b.depth--
+	if b.depth > 0 || len(b.events) == 0 {
+		return
+	}
+	// The handler may start operations of its own, which deliver their
+	// batch while this one is delivered
+	events := b.events
+	b.events = nil
+
+	if b.mu != nil {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+	}
+	*b.sequence++
+	for i := range events {
+		events[i].Sequence = *b.sequence
+	}
+	b.handler.OnEvents(events)
+
+	if b.events == nil {
+		b.events = events[:0]
+	}
+}
+
+// record adds an event to the current batch
+func (b *batcher) record(e Event) {
+	if b.depth == 0 {
+		b.begin()
+		defer b.end()
+	}
+	b.events = append(b.events, e)
+}
+
+// noop ends operations outside batched and conflated modes
+func noop() {}
+
+// operation starts an operation whose events are delivered as one batch in
+// batched mode, and whose order book updates are conflated in a conflated
+// mode, and returns the func ending it:
+//
+//	defer m.operation()()
+func (m *MarketManager) operation() func() {
+	if m.batch == nil && m.conflation == ConflateNone {
+		return noop
+	}
+	m.depth++
+	if m.batch != nil {
+		m.batch.begin()
+	}
+	return m.endOperation
+}
+
+// endOperation ends an operation, flushing the conflated order book updates
+// into its batch if it is the outermost one
+func (m *MarketManager) endOperation() {
+	m.depth--
+	if m.depth == 0 {
+		m.flushBookUpdates()
+	}
+	if m.batch != nil {
+		m.batch.end()
+	}
+}
+
+// SetBatchHandler switches to batched mode: instead of a MarketHandler
+// callback per event, handler receives all the events of an operation, such
+// as an AddOrder and the executions it caused, in one call with a shared
+// sequence. A nil handler leaves batched mode for a no-op MarketHandler.
+// In batched mode, Handler returns the MarketHandler recording the batches,
+// which a handler set with SetHandler may wrap to keep them.
+func (m *MarketManager) SetBatchHandler(handler BatchHandler) {
+	if handler == nil {
+		m.batch = nil
+		m.handler = &DefaultMarketHandler{}
+		return
+	}
+	var sequence uint64
+	m.setBatcher(&batcher{handler: handler, sequence: &sequence})
+}
+
+// setBatcher installs a batcher as the handler
+func (m *MarketManager) setBatcher(b *batcher) {
+	m.batch = b
+	m.handler = b
+}
+
+// NewEngineWithBatchHandler creates a new engine in batched mode: handler
+// receives the events of each operation of any shard in one call. Batches
+// of different shards are serialized and numbered by one sequence.
+func NewEngineWithBatchHandler(shards int, handler BatchHandler) *Engine {
+	e := NewEngine(shards)
+	var mu sync.Mutex
+	var sequence uint64
+	e.broadcast(func(m *MarketManager) ErrorCode {
+		m.setBatcher(&batcher{handler: handler, mu: &mu, sequence: &sequence})
+		return ErrorOK
+	})
+	return e
+}
+
+func (b *batcher) OnAddSymbol(symbol Symbol) {
+	b.record(Event{Type: EventAddSymbol, Symbol: symbol})
+}
+
+func (b *batcher) OnDeleteSymbol(symbol Symbol) {
+	b.record(Event{Type: EventDeleteSymbol, Symbol: symbol})
+}
+
+func (b *batcher) OnAddOrderBook(orderBook *OrderBook) {
+	b.record(Event{Type: EventAddOrderBook, OrderBook: orderBook})
+}
+
+func (b *batcher) OnUpdateOrderBook(orderBook *OrderBook, top bool) {
+	b.record(Event{Type: EventUpdateOrderBook, OrderBook: orderBook, Top: top})
+}
+
+func (b *batcher) OnDeleteOrderBook(orderBook *OrderBook) {
+	b.record(Event{Type: EventDeleteOrderBook, OrderBook: orderBook})
+}
+
+func (b *batcher) OnBookCrossed(orderBook *OrderBook) {
+	b.record(Event{Type: EventBookCrossed, OrderBook: orderBook})
+}
+
+func (b *batcher) OnUpdateSymbolConfig(orderBook *OrderBook, config SymbolConfig) {
+	b.record(Event{Type: EventUpdateSymbolConfig, OrderBook: orderBook, Config: config})
+}
+
+func (b *batcher) OnAddLevel(orderBook *OrderBook, level Level, top bool) {
+	b.record(Event{Type: EventAddLevel, OrderBook: orderBook, Level: level, Top: top})
+}
+
+func (b *batcher) OnUpdateLevel(orderBook *OrderBook, level Level, top bool) {
+	b.record(Event{Type: EventUpdateLevel, OrderBook: orderBook, Level: level, Top: top})
+}
+
+func (b *batcher) OnDeleteLevel(orderBook *OrderBook, level Level, top bool) {
+	b.record(Event{Type: EventDeleteLevel, OrderBook: orderBook, Level: level, Top: top})
+}
+
+func (b *batcher) OnAddOrder(order Order) {
+	b.record(Event{Type: EventAddOrder, Order: order})
+}
+
+func (b *batcher) OnUpdateOrder(order Order) {
+	b.record(Event{Type: EventUpdateOrder, Order: order})
+}
+
+func (b *batcher) OnDeleteOrder(order Order) {
+	b.record(Event{Type: EventDeleteOrder, Order: order})
+}
+
+func (b *batcher) OnInvalidOrder(order Order) {
+	b.record(Event{Type: EventInvalidOrder, Order: order})
+}
+
+func (b *batcher) OnRejectOrder(order Order, code ErrorCode) {
+	b.record(Event{Type: EventRejectOrder, Order: order, Code: code})
+}
+
+func (b *batcher) OnExpireOrder(order Order) {
+	b.record(Event{Type: EventExpireOrder, Order: order})
+}
+
+func (b *batcher) OnExecuteOrder(order Order, price, quantity uint64) {
+	b.record(Event{Type: EventExecuteOrder, Order: order, Price: price, Quantity: quantity})
+}
+
+func (b *batcher) OnTrade(trade Trade) {
+	b.record(Event{Type: EventTrade, Trade: trade})
+}
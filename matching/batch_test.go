@@ -0,0 +1,165 @@
+package matching
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// batchRecorder records the batches of a market manager
+type batchRecorder struct {
+	batches [][]Event
+}
+
+func (r *batchRecorder) OnEvents(events []Event) {
+	r.batches = append(r.batches, append([]Event(nil), events...))
+}
+
+func (r *batchRecorder) types(i int) []EventType {
+	var types []EventType
+	for _, e := range r.batches[i] {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestBatchHandler_Operation(t *testing.T) {
+	recorder := &batchRecorder{}
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.SetBatchHandler(recorder)
+	manager.EnableMatching()
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideSell, 10000, 100))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideBuy, 10000, 40))
+	if err := manager.DeleteOrder(99); err != ErrorOrderNotFound {
+		t.Fatalf("Expected ORDER_NOT_FOUND, got %s", err)
+	}
+
+	// Failed operations deliver their reject alone
+	if len(recorder.batches) != 3 {
+		t.Fatalf("Expected 3 batches, got %d", len(recorder.batches))
+	}
+	if reject := recorder.batches[2]; len(reject) != 1 || reject[0].Type != EventRejectOrder || reject[0].Code != ErrorOrderNotFound || reject[0].Order.ID != 99 {
+		t.Errorf("Expected the reject of order 99, got %+v", reject)
+	}
+	want := []EventType{
+		EventAddOrder, EventAddLevel, EventUpdateOrderBook,
+		EventExecuteOrder, EventDeleteLevel, EventUpdateOrderBook, EventDeleteOrder,
+		EventExecuteOrder, EventUpdateOrder, EventUpdateLevel, EventUpdateOrderBook,
+		EventTrade,
+	}
+	if got := recorder.types(1); !equalEventTypes(got, want) {
+		t.Errorf("Expected the matching add in one batch %v, got %v", want, got)
+	}
+	for i, batch := range recorder.batches {
+		for _, e := range batch {
+			if e.Sequence != uint64(i+1) {
+				t.Errorf("Expected sequence %d in batch %d, got %d", i+1, i, e.Sequence)
+			}
+		}
+	}
+	if trade := recorder.batches[1][len(want)-1].Trade; trade.BuyOrderID != 2 || trade.SellOrderID != 1 || trade.Quantity != 40 {
+		t.Errorf("Expected trade of 40 between 2 and 1, got %+v", trade)
+	}
+}
+
+func TestBatchHandler_NestedOperations(t *testing.T) {
+	recorder := &batchRecorder{}
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.SetBatchHandler(recorder)
+
+	for id := uint64(1); id <= 3; id++ {
+		order := NewLimitOrder(id, 1, OrderSideBuy, 10000-id, 10)
+		order.TimeInForce = OrderTimeInForceDay
+		manager.AddOrder(*order)
+	}
+	recorder.batches = nil
+
+	// The deletions of the session reset are one batch
+	if n := manager.ResetSession(); n != 3 {
+		t.Fatalf("Expected 3 orders canceled, got %d", n)
+	}
+	if len(recorder.batches) != 1 {
+		t.Fatalf("Expected 1 batch, got %d", len(recorder.batches))
+	}
+	deleted := 0
+	for _, e := range recorder.batches[0] {
+		if e.Type == EventDeleteOrder {
+			deleted++
+		}
+		if e.Sequence != 4 {
+			t.Errorf("Expected sequence 4, got %d", e.Sequence)
+		}
+	}
+	if deleted != 3 {
+		t.Errorf("Expected 3 deletions, got %v", recorder.types(0))
+	}
+
+	// Leaving batched mode delivers callbacks again
+	manager.SetBatchHandler(nil)
+	manager.AddOrder(*NewLimitOrder(4, 1, OrderSideBuy, 10000, 10))
+	if len(recorder.batches) != 1 {
+		t.Errorf("Expected no batch after leaving batched mode, got %d", len(recorder.batches))
+	}
+}
+
+// TestBatchHandler_Golden checks that dispatching the batches yields the
+// golden event sequence
+func TestBatchHandler_Golden(t *testing.T) {
+	want, err := os.ReadFile(filepath.Join("testdata", "events.golden"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	got := runSequenceScenarioWith(func(m *MarketManager, h *sequenceHandler) {
+		m.SetBatchHandler(BatchHandlerFunc(func(events []Event) {
+			for _, e := range events {
+				e.Dispatch(h)
+			}
+		}))
+	})
+	if got != string(want) {
+		t.Errorf("Expected the golden events from the batches, got\n%s", got)
+	}
+}
+
+func TestEngine_BatchHandler(t *testing.T) {
+	var mu sync.Mutex
+	var sequences []uint64
+	engine := NewEngineWithBatchHandler(4, BatchHandlerFunc(func(events []Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		sequences = append(sequences, events[0].Sequence)
+	}))
+	defer engine.Close()
+
+	for id := uint32(1); id <= 8; id++ {
+		symbol := NewSymbol(id, "S"+string(rune('0'+id)))
+		engine.AddSymbol(symbol)
+		engine.AddOrderBook(symbol)
+		engine.AddOrder(*NewLimitOrder(uint64(id), id, OrderSideBuy, 10000, 10))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, seq := range sequences {
+		if seq != uint64(i+1) {
+			t.Fatalf("Expected batches numbered in delivery order, got %v", sequences)
+		}
+	}
+	if len(sequences) != 3*8 {
+		t.Errorf("Expected %d batches, got %d", 3*8, len(sequences))
+	}
+}
+
+func equalEventTypes(a, b []EventType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
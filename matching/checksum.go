@@ -0,0 +1,101 @@
+package matching
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// ChecksumDepth is the number of displayed price levels per side covered by
+// OrderBook.Checksum
+const ChecksumDepth = 10
+
+// checksumState caches the checksum of an order book between changes
+type checksumState struct {
+	value uint32
+	valid bool
+	// bidFloor and askCeiling are the worst prices covered by the cached
+	// checksum when the side had ChecksumDepth displayed levels
+	bidFloor   uint64
+	askCeiling uint64
+	bidFull    bool
+	askFull    bool
+}
+
+// Checksum returns a CRC32 (IEEE) of the top ChecksumDepth displayed price
+// levels, so that book mirrors built from market data can cheaply verify they
+// are in sync.
+//
+// The checksummed bytes are, for i from 0 to ChecksumDepth-1, the price and
+// visible volume of the i-th best bid level followed by those of the i-th
+// best ask level, each as a big-endian uint64. Levels with no visible volume
+// are skipped, and a side with fewer levels contributes nothing once it is
+// exhausted.
+//
+// The checksum is cached and only recomputed after a change to a level
+// within the covered depth.
+func (ob *OrderBook) Checksum() uint32 {
+	cs := &ob.checksum
+	if cs.valid {
+		return cs.value
+	}
+
+	bids := visibleLevels(ob.bids)
+	asks := visibleLevels(ob.asks)
+
+	var buf [ChecksumDepth * 32]byte
+	n := 0
+	for i := 0; i < ChecksumDepth; i++ {
+		if i < len(bids) {
+			binary.BigEndian.PutUint64(buf[n:], bids[i].Price)
+			binary.BigEndian.PutUint64(buf[n+8:], bids[i].VisibleVolume)
+			n += 16
+		}
+		if i < len(asks) {
+			binary.BigEndian.PutUint64(buf[n:], asks[i].Price)
+			binary.BigEndian.PutUint64(buf[n+8:], asks[i].VisibleVolume)
+			n += 16
+		}
+	}
+
+	cs.value = crc32.ChecksumIEEE(buf[:n])
+	cs.valid = true
+	cs.bidFull = len(bids) == ChecksumDepth
+	cs.askFull = len(asks) == ChecksumDepth
+	if cs.bidFull {
+		cs.bidFloor = bids[ChecksumDepth-1].Price
+	}
+	if cs.askFull {
+		cs.askCeiling = asks[ChecksumDepth-1].Price
+	}
+	return cs.value
+}
+
+// visibleLevels returns up to ChecksumDepth levels with visible volume, best first
+func visibleLevels(tree *AVLTree) []*LevelNode {
+	levels := make([]*LevelNode, 0, ChecksumDepth)
+	tree.ForEach(func(level *LevelNode) bool {
+		if level.VisibleVolume > 0 {
+			levels = append(levels, level)
+		}
+		return len(levels) < ChecksumDepth
+	})
+	return levels
+}
+
+// invalidateChecksum discards the cached checksum if a change of the limit
+// order level of order can affect it
+func (ob *OrderBook) invalidateChecksum(order *OrderNode) {
+	cs := &ob.checksum
+	if !cs.valid || order.IsStop() || order.IsStopLimit() || order.IsTrailingStop() || order.IsTrailingStopLimit() {
+		return
+	}
+	if order.IsBuy() {
+		if !cs.bidFull || order.Price >= cs.bidFloor {
+			cs.valid = false
+		}
+	} else {
+		if !cs.askFull || order.Price <= cs.askCeiling {
+			cs.valid = false
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package matching
+
+import "testing"
+
+func TestOrderBook_Checksum(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	ob := manager.GetOrderBook(1)
+
+	empty := ob.Checksum()
+	for i := uint64(0); i < ChecksumDepth+2; i++ {
+		manager.AddOrder(*NewLimitOrder(i+1, 1, OrderSideBuy, 10000-i*10, 100))
+		manager.AddOrder(*NewLimitOrder(i+101, 1, OrderSideSell, 10100+i*10, 100))
+	}
+	full := ob.Checksum()
+	if full == empty {
+		t.Fatalf("Expected checksum to change after adding levels")
+	}
+
+	// Levels beyond the covered depth keep the cached checksum
+	manager.ReduceOrder(ChecksumDepth+2, 50)
+	manager.DeleteOrder(ChecksumDepth + 102)
+	if !ob.checksum.valid {
+		t.Errorf("Expected checksum to stay cached after changes beyond depth %d", ChecksumDepth)
+	}
+	if got := ob.Checksum(); got != full {
+		t.Errorf("Expected checksum %08x, got %08x", full, got)
+	}
+
+	// Changes within the depth are detected
+	manager.ReduceOrder(1, 10)
+	changed := ob.Checksum()
+	if changed == full {
+		t.Errorf("Expected checksum to change after reducing the best bid")
+	}
+	ob.checksum.valid = false
+	if got := ob.Checksum(); got != changed {
+		t.Errorf("Expected recomputed checksum %08x, got %08x", changed, got)
+	}
+
+	// Removing the changed level restores a deeper level into the covered depth
+	manager.DeleteOrder(1)
+	if got := ob.Checksum(); got == changed {
+		t.Errorf("Expected checksum to change after deleting the best bid")
+	}
+}
@@ -0,0 +1,206 @@
+package matching
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SymbolConfig contains the trading rules of an order book.
+// The zero value places no restrictions on orders.
+type SymbolConfig struct {
+	// TickSize is the minimum price increment, 0 for any price
+	TickSize uint64
+	// LotSize is the minimum quantity increment, 0 for any quantity
+	LotSize uint64
+	// MinPrice is the lower price band, 0 for no limit
+	MinPrice uint64
+	// MaxPrice is the upper price band, 0 for no limit
+	MaxPrice uint64
+	// Schedule is the daily trading window
+	Schedule TradingSchedule
+	// IcebergVariance randomizes the refreshed display of icebergs without a
+	// display range of their own by up to this percentage of their
+	// MaxVisibleQuantity either way, 0 for a fixed display
+	IcebergVariance uint64
+	// Allocation selects how aggressive orders are shared out among the
+	// resting orders of a level, FIFO by default
+	Allocation Allocation
+	// TopOrderQuantity caps the carve-out of the top order in pro rata books,
+	// the order that opened the best level, 0 for no top order priority
+	TopOrderQuantity uint64
+	// FIFOPercent is the share of every match allocated FIFO before pro rata
+	// allocation in pro rata books
+	FIFOPercent uint64
+	// MinAllocation is the smallest pro rata allocation; smaller ones are
+	// rounded down to 0 and their quantity allocated FIFO
+	MinAllocation uint64
+	// CancelInvalid cancels resting orders that violate a new configuration.
+	// Otherwise they are kept and only reported with OnInvalidOrder.
+	CancelInvalid bool
+	// OrderTTL expires resting orders that held their queue position for this
+	// long, 0 for none
+	OrderTTL time.Duration
+	// QuoteTTL expires resting quotes, orders with Quote set, that held their
+	// queue position for this long, 0 for the OrderTTL
+	QuoteTTL time.Duration
+	// ExpirySweep is the interval at which the next new order of the book
+	// purges its expired orders, 0 to purge them only with ExpireOrders
+	ExpirySweep time.Duration
+	// StopProtection is the price protection of triggered stop orders in
+	// price points, a multiple of the tick size, 0 for none (see TriggerStop)
+	StopProtection uint64
+}
+
+// TradingSchedule is a daily window in which new orders are accepted.
+// The zero value is always open.
+type TradingSchedule struct {
+	// Open is the offset from midnight at which trading starts
+	Open time.Duration
+	// Close is the offset from midnight at which trading ends
+	Close time.Duration
+	// Location is the time zone of the schedule, nil for UTC
+	Location *time.Location
+}
+
+// IsAlwaysOpen returns true if the schedule does not restrict trading
+func (s TradingSchedule) IsAlwaysOpen() bool {
+	return s.Open == 0 && s.Close == 0
+}
+
+// IsOpen returns true if trading is allowed at time t.
+// A Close earlier than Open describes a window spanning midnight.
+func (s TradingSchedule) IsOpen(t time.Time) bool {
+	if s.IsAlwaysOpen() {
+		return true
+	}
+	loc := s.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	offset := t.Sub(midnight)
+	if s.Open <= s.Close {
+		return offset >= s.Open && offset < s.Close
+	}
+	return offset >= s.Open || offset < s.Close
+}
+
+// Validate checks that the configuration is consistent
+func (c SymbolConfig) Validate() error {
+	if c.MaxPrice != 0 && c.MinPrice > c.MaxPrice {
+		return fmt.Errorf("min price %d above max price %d", c.MinPrice, c.MaxPrice)
+	}
+	if c.TickSize != 0 && (c.MinPrice%c.TickSize != 0 || c.MaxPrice%c.TickSize != 0) {
+		return fmt.Errorf("price band %d-%d not aligned to tick size %d", c.MinPrice, c.MaxPrice, c.TickSize)
+	}
+	if c.IcebergVariance > 100 {
+		return fmt.Errorf("iceberg variance %d%% above 100%%", c.IcebergVariance)
+	}
+	if c.Allocation > AllocationProRata {
+		return fmt.Errorf("unknown allocation %d", c.Allocation)
+	}
+	if c.FIFOPercent > 100 {
+		return fmt.Errorf("FIFO share %d%% above 100%%", c.FIFOPercent)
+	}
+	if c.TickSize != 0 && c.StopProtection%c.TickSize != 0 {
+		return fmt.Errorf("stop protection %d not aligned to tick size %d", c.StopProtection, c.TickSize)
+	}
+	if c.OrderTTL < 0 || c.QuoteTTL < 0 || c.ExpirySweep < 0 {
+		return fmt.Errorf("negative order expiry %v, %v or sweep %v", c.OrderTTL, c.QuoteTTL, c.ExpirySweep)
+	}
+	day := 24 * time.Hour
+	if c.Schedule.Open < 0 || c.Schedule.Open >= day || c.Schedule.Close < 0 || c.Schedule.Close >= day {
+		return fmt.Errorf("trading schedule %v-%v outside of a day", c.Schedule.Open, c.Schedule.Close)
+	}
+	return nil
+}
+
+// checkPrice checks a price against the tick size and price band
+func (c SymbolConfig) checkPrice(price uint64) bool {
+	if c.TickSize != 0 && price%c.TickSize != 0 {
+		return false
+	}
+	if c.MinPrice != 0 && price < c.MinPrice {
+		return false
+	}
+	if c.MaxPrice != 0 && price > c.MaxPrice {
+		return false
+	}
+	return true
+}
+
+// checkQuantity checks a quantity against the lot size
+func (c SymbolConfig) checkQuantity(quantity uint64) bool {
+	return c.LotSize == 0 || quantity%c.LotSize == 0
+}
+
+// checkOrder checks the prices and quantity of an order
+func (c SymbolConfig) checkOrder(order *Order) ErrorCode {
+	if !c.checkQuantity(order.Quantity) {
+		return ErrorOrderQuantityInvalid
+	}
+	if order.IsLimit() || order.IsStopLimit() || order.IsTrailingStopLimit() {
+		if !c.checkPrice(order.Price) {
+			return ErrorOrderParameterInvalid
+		}
+	}
+	if (order.IsStop() || order.IsStopLimit()) && !c.checkPrice(order.StopPrice) {
+		return ErrorOrderParameterInvalid
+	}
+	// Pro rata allocations ignore minimum quantities
+	if c.Allocation == AllocationProRata && order.MinQuantity != 0 {
+		return ErrorOrderParameterInvalid
+	}
+	return ErrorOK
+}
+
+// SetClock replaces the clock used to check trading schedules.
+// The default is time.Now.
+func (m *MarketManager) SetClock(clock func() time.Time) {
+	m.clock = clock
+}
+
+// now returns the current time of the market manager clock
+func (m *MarketManager) now() time.Time {
+	if m.clock != nil {
+		return m.clock()
+	}
+	return time.Now()
+}
+
+// UpdateSymbolConfig changes the trading rules of a live order book.
+// Resting orders that violate the new tick size, lot size or price band are
+// reported with OnInvalidOrder and cancelled if CancelInvalid is set.
+// The trading schedule only applies to new orders.
+func (m *MarketManager) UpdateSymbolConfig(symbolID uint32, config SymbolConfig) ErrorCode {
+	defer m.operation()()
+	ob, exists := m.orderBooks[symbolID]
+	if !exists {
+		return ErrorOrderBookNotFound
+	}
+	if config.Validate() != nil {
+		return ErrorSymbolConfigInvalid
+	}
+
+	ob.config = config
+	m.handler.OnUpdateSymbolConfig(ob, config)
+
+	// Collect invalid orders in ID order so the notifications are deterministic
+	var invalid []*OrderNode
+	for _, order := range m.orders {
+		if order.SymbolID == symbolID && config.checkOrder(&order.Order) != ErrorOK {
+			invalid = append(invalid, order)
+		}
+	}
+	sort.Slice(invalid, func(i, j int) bool { return invalid[i].ID < invalid[j].ID })
+
+	for _, order := range invalid {
+		m.handler.OnInvalidOrder(order.Order)
+		if config.CancelInvalid {
+			m.DeleteOrder(order.ID)
+		}
+	}
+	return ErrorOK
+}
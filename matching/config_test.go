@@ -0,0 +1,146 @@
+package matching
+
+import (
+	"testing"
+	"time"
+)
+
+// configHandler records symbol configuration events
+type configHandler struct {
+	DefaultMarketHandler
+	configs []SymbolConfig
+	invalid []uint64
+	deleted []uint64
+}
+
+func (h *configHandler) OnUpdateSymbolConfig(orderBook *OrderBook, config SymbolConfig) {
+	h.configs = append(h.configs, config)
+}
+
+func (h *configHandler) OnInvalidOrder(order Order) {
+	h.invalid = append(h.invalid, order.ID)
+}
+
+func (h *configHandler) OnDeleteOrder(order Order) {
+	h.deleted = append(h.deleted, order.ID)
+}
+
+func newConfigManager(handler MarketHandler) *MarketManager {
+	manager := NewMarketManagerWithHandler(handler)
+	symbol := NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+	return manager
+}
+
+func TestSymbolConfig_OrderValidation(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	config := SymbolConfig{TickSize: 5, LotSize: 100, MinPrice: 9000, MaxPrice: 11000}
+	if err := manager.UpdateSymbolConfig(1, config); err != ErrorOK {
+		t.Fatalf("UpdateSymbolConfig failed: %s", err)
+	}
+	if manager.GetOrderBook(1).Config() != config {
+		t.Errorf("Expected config %+v, got %+v", config, manager.GetOrderBook(1).Config())
+	}
+
+	tests := []struct {
+		name     string
+		order    *Order
+		expected ErrorCode
+	}{
+		{"valid", NewLimitOrder(1, 1, OrderSideBuy, 10000, 200), ErrorOK},
+		{"off tick", NewLimitOrder(2, 1, OrderSideBuy, 10001, 100), ErrorOrderParameterInvalid},
+		{"odd lot", NewLimitOrder(3, 1, OrderSideBuy, 10000, 150), ErrorOrderQuantityInvalid},
+		{"below band", NewLimitOrder(4, 1, OrderSideBuy, 8995, 100), ErrorOrderParameterInvalid},
+		{"above band", NewLimitOrder(5, 1, OrderSideSell, 11005, 100), ErrorOrderParameterInvalid},
+		{"stop off tick", NewStopOrder(6, 1, OrderSideBuy, 10002, 100), ErrorOrderParameterInvalid},
+		{"market", NewMarketOrder(7, 1, OrderSideSell, 100), ErrorOK},
+	}
+	for _, tt := range tests {
+		if err := manager.AddOrder(*tt.order); err != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, err)
+		}
+	}
+
+	if err := manager.ModifyOrder(1, 10003, 200); err != ErrorOrderParameterInvalid {
+		t.Errorf("Expected ErrorOrderParameterInvalid for off-tick modify, got %s", err)
+	}
+	if order := manager.GetOrder(1); order.Price != 10000 {
+		t.Errorf("Expected rejected modify to keep price 10000, got %d", order.Price)
+	}
+	if err := manager.ReplaceOrder(1, 10, 10000, 50); err != ErrorOrderQuantityInvalid {
+		t.Errorf("Expected ErrorOrderQuantityInvalid for odd-lot replace, got %s", err)
+	}
+}
+
+func TestSymbolConfig_Invalid(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+
+	if err := manager.UpdateSymbolConfig(2, SymbolConfig{}); err != ErrorOrderBookNotFound {
+		t.Errorf("Expected ErrorOrderBookNotFound, got %s", err)
+	}
+	invalid := []SymbolConfig{
+		{MinPrice: 200, MaxPrice: 100},
+		{TickSize: 10, MinPrice: 95},
+		{Schedule: TradingSchedule{Open: 25 * time.Hour}},
+	}
+	for _, config := range invalid {
+		if err := manager.UpdateSymbolConfig(1, config); err != ErrorSymbolConfigInvalid {
+			t.Errorf("Expected ErrorSymbolConfigInvalid for %+v, got %s", config, err)
+		}
+	}
+}
+
+func TestSymbolConfig_RestingOrders(t *testing.T) {
+	handler := &configHandler{}
+	manager := newConfigManager(handler)
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 100))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideBuy, 10010, 100))
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideSell, 10500, 150))
+
+	// Flag only: invalid orders stay in the book
+	manager.UpdateSymbolConfig(1, SymbolConfig{TickSize: 20, LotSize: 100})
+	if len(handler.configs) != 1 {
+		t.Errorf("Expected 1 config notification, got %d", len(handler.configs))
+	}
+	if len(handler.invalid) != 2 || handler.invalid[0] != 2 || handler.invalid[1] != 3 {
+		t.Errorf("Expected invalid orders [2 3], got %v", handler.invalid)
+	}
+	if len(manager.Orders()) != 3 || len(handler.deleted) != 0 {
+		t.Errorf("Expected invalid orders to be kept, got %d orders", len(manager.Orders()))
+	}
+
+	// Cancel: invalid orders are removed
+	handler.invalid = nil
+	manager.UpdateSymbolConfig(1, SymbolConfig{TickSize: 20, LotSize: 100, CancelInvalid: true})
+	if len(handler.deleted) != 2 || manager.GetOrder(2) != nil || manager.GetOrder(3) != nil {
+		t.Errorf("Expected orders 2 and 3 to be cancelled, got deleted %v", handler.deleted)
+	}
+	if manager.GetOrder(1) == nil {
+		t.Error("Expected valid order 1 to remain")
+	}
+}
+
+func TestSymbolConfig_Schedule(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	now := time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC)
+	manager.SetClock(func() time.Time { return now })
+	manager.UpdateSymbolConfig(1, SymbolConfig{
+		Schedule: TradingSchedule{Open: 9*time.Hour + 30*time.Minute, Close: 16 * time.Hour},
+	})
+
+	if err := manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 100)); err != ErrorMarketClosed {
+		t.Errorf("Expected ErrorMarketClosed before the open, got %s", err)
+	}
+	now = now.Add(2 * time.Hour)
+	if err := manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 100)); err != ErrorOK {
+		t.Errorf("Expected order to be accepted during the session, got %s", err)
+	}
+
+	overnight := TradingSchedule{Open: 22 * time.Hour, Close: 2 * time.Hour}
+	if !overnight.IsOpen(time.Date(2024, 1, 2, 23, 0, 0, 0, time.UTC)) ||
+		!overnight.IsOpen(time.Date(2024, 1, 2, 1, 0, 0, 0, time.UTC)) ||
+		overnight.IsOpen(time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)) {
+		t.Error("Expected overnight schedule to span midnight")
+	}
+}
@@ -0,0 +1,94 @@
+package matching
+
+// BookConflation determines how often OnUpdateOrderBook is called
+type BookConflation uint8
+
+const (
+	// ConflateNone calls OnUpdateOrderBook after every level event
+	ConflateNone BookConflation = iota
+	// ConflateOperation calls OnUpdateOrderBook once per updated book at the
+	// end of each operation, such as an AddOrder and the whole matching
+	// cascade it caused
+	ConflateOperation
+	// ConflateMatch calls OnUpdateOrderBook once per updated book after each
+	// matching cycle, and at the end of operations for updates outside one
+	ConflateMatch
+)
+
+// String returns the string representation of a BookConflation
+func (c BookConflation) String() string {
+	switch c {
+	case ConflateNone:
+		return "NONE"
+	case ConflateOperation:
+		return "OPERATION"
+	case ConflateMatch:
+		return "MATCH"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// bookUpdate is a pending OnUpdateOrderBook notification
+type bookUpdate struct {
+	orderBook *OrderBook
+	top       bool
+}
+
+// BookConflation returns how often OnUpdateOrderBook is called
+func (m *MarketManager) BookConflation() BookConflation {
+	return m.conflation
+}
+
+// SetBookConflation sets how often OnUpdateOrderBook is called. In a
+// conflated mode the level events are still reported one by one, but
+// OnUpdateOrderBook is called once per updated book, after the other events
+// of the operation or matching cycle, in the order the books were first
+// updated. top is set if any of the updates touched the top of book, and the
+// book passed is in its final state. The default is ConflateNone.
+func (m *MarketManager) SetBookConflation(conflation BookConflation) {
+	m.conflation = conflation
+	if conflation == ConflateNone {
+		m.flushBookUpdates()
+	}
+}
+
+// updateOrderBook notifies the handler of an order book update, or defers the
+// notification to the end of the operation in a conflated mode
+func (m *MarketManager) updateOrderBook(ob *OrderBook, top bool) {
+	if m.conflation == ConflateNone || m.depth == 0 {
+		m.handler.OnUpdateOrderBook(ob, top)
+		return
+	}
+	for i := range m.bookUpdates {
+		if m.bookUpdates[i].orderBook == ob {
+			m.bookUpdates[i].top = m.bookUpdates[i].top || top
+			return
+		}
+	}
+	m.bookUpdates = append(m.bookUpdates, bookUpdate{orderBook: ob, top: top})
+}
+
+// matched ends a matching cycle
+func (m *MarketManager) matched() {
+	if m.conflation == ConflateMatch {
+		m.flushBookUpdates()
+	}
+}
+
+// flushBookUpdates calls OnUpdateOrderBook for the deferred updates
+func (m *MarketManager) flushBookUpdates() {
+	if len(m.bookUpdates) == 0 {
+		return
+	}
+	// The handler may start operations of its own, which flush their
+	// updates while these are delivered
+	updates := m.bookUpdates
+	m.bookUpdates = nil
+	for _, u := range updates {
+		m.handler.OnUpdateOrderBook(u.orderBook, u.top)
+	}
+	if m.bookUpdates == nil {
+		m.bookUpdates = updates[:0]
+	}
+}
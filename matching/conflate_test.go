@@ -0,0 +1,99 @@
+package matching
+
+import (
+	"strings"
+	"testing"
+)
+
+// sweep rests three asks and sweeps them with one buy, returning the events
+// of the sweep
+func sweep(t *testing.T, conflation BookConflation) []string {
+	handler := &sequenceHandler{}
+	manager := newConfigManager(handler)
+	manager.EnableMatching()
+	manager.SetBookConflation(conflation)
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideSell, 10000, 10))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideSell, 10100, 10))
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideSell, 10200, 10))
+	handler.lines = nil
+	if err := manager.AddOrder(*NewLimitOrder(4, 1, OrderSideBuy, 10200, 25)); err != ErrorOK {
+		t.Fatalf("Expected OK, got %s", err)
+	}
+	return handler.lines
+}
+
+func updates(lines []string) int {
+	n := 0
+	for _, line := range lines {
+		if strings.HasPrefix(line, "UpdateOrderBook") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestBookConflation_None(t *testing.T) {
+	lines := sweep(t, ConflateNone)
+	if n := updates(lines); n < 4 {
+		t.Errorf("Expected an update per level event, got %d", n)
+	}
+}
+
+func TestBookConflation_Operation(t *testing.T) {
+	for _, conflation := range []BookConflation{ConflateOperation, ConflateMatch} {
+		lines := sweep(t, conflation)
+		if n := updates(lines); n != 1 {
+			t.Errorf("%s: Expected one update, got %d", conflation, n)
+		}
+		if last := lines[len(lines)-1]; last != "UpdateOrderBook 1 top=true" {
+			t.Errorf("%s: Expected the update last, got %q", conflation, last)
+		}
+	}
+}
+
+func TestBookConflation_Top(t *testing.T) {
+	handler := &sequenceHandler{}
+	manager := newConfigManager(handler)
+	manager.SetBookConflation(ConflateOperation)
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideSell, 10000, 10))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideSell, 10100, 10))
+
+	handler.lines = nil
+	manager.ReduceOrder(2, 5)
+	if got := handler.lines[len(handler.lines)-1]; got != "UpdateOrderBook 1 top=false" {
+		t.Errorf("Expected an update below the top, got %q", got)
+	}
+
+	// Disabling conflation reports updates as they happen again
+	manager.SetBookConflation(ConflateNone)
+	handler.lines = nil
+	manager.ReduceOrder(1, 5)
+	if len(handler.lines) != 3 || handler.lines[2] != "UpdateOrderBook 1 top=true" {
+		t.Errorf("Expected the update after the level event, got %q", handler.lines)
+	}
+}
+
+func TestBookConflation_Batch(t *testing.T) {
+	recorder := &batchRecorder{}
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.SetBatchHandler(recorder)
+	manager.EnableMatching()
+	manager.SetBookConflation(ConflateOperation)
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideSell, 10000, 10))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideBuy, 10000, 10))
+	if len(recorder.batches) != 2 {
+		t.Fatalf("Expected 2 batches, got %d", len(recorder.batches))
+	}
+	types := recorder.types(1)
+	n := 0
+	for _, typ := range types {
+		if typ == EventUpdateOrderBook {
+			n++
+		}
+	}
+	if n != 1 || types[len(types)-1] != EventUpdateOrderBook {
+		t.Errorf("Expected one update ending the batch, got %v", types)
+	}
+}
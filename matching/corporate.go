@@ -0,0 +1,205 @@
+package matching
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// SplitPolicy determines what happens to the resting orders of a split symbol
+type SplitPolicy uint8
+
+const (
+	// SplitAdjust adjusts the prices and quantities of resting orders by the
+	// split ratio, keeping their queue priority
+	SplitAdjust SplitPolicy = iota
+	// SplitCancel cancels all resting orders
+	SplitCancel
+)
+
+// String returns the string representation of a SplitPolicy
+func (p SplitPolicy) String() string {
+	switch p {
+	case SplitAdjust:
+		return "ADJUST"
+	case SplitCancel:
+		return "CANCEL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Split is a stock split of a symbol: every From shares become To shares.
+// A 2-for-1 split is To 2, From 1 and halves prices; a 1-for-10 reverse
+// split is To 1, From 10.
+type Split struct {
+	// SymbolID is the split symbol
+	SymbolID uint32
+	// To is the number of shares after the split
+	To uint64
+	// From is the number of shares before the split
+	From uint64
+	// Policy determines what happens to resting orders
+	Policy SplitPolicy
+}
+
+// ApplySplit applies a stock split to the order book of a symbol.
+//
+// With SplitAdjust, prices are multiplied by From/To and quantities by To/From.
+// Buy limit prices are rounded down and sell limit prices up, so the book
+// does not cross; stop prices are rounded away from the market, so stops do
+// not trigger early. Fractional shares are dropped and orders left without
+// leaves quantity are cancelled. The orders keep their relative queue
+// priority and are reported with OnUpdateOrder. The last prices and session
+// statistics of the book are adjusted too, but not its trading rules: update
+// them with UpdateSymbolConfig.
+//
+// With SplitCancel, all resting orders are cancelled in ID order.
+func (m *MarketManager) ApplySplit(split Split) ErrorCode {
+	defer m.operation()()
+	ob, exists := m.orderBooks[split.SymbolID]
+	if !exists {
+		return ErrorOrderBookNotFound
+	}
+	if split.To == 0 || split.From == 0 {
+		return ErrorOrderParameterInvalid
+	}
+
+	var orders []*OrderNode
+	for _, order := range m.orders {
+		if order.SymbolID == split.SymbolID {
+			orders = append(orders, order)
+		}
+	}
+
+	if split.Policy == SplitCancel {
+		sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+		for _, order := range orders {
+			m.DeleteOrder(order.ID)
+		}
+		return ErrorOK
+	}
+	if split.Policy != SplitAdjust {
+		return ErrorOrderParameterInvalid
+	}
+
+	// Take all orders out of the book before adding them back, so the book
+	// never mixes old and new prices
+	sort.Slice(orders, func(i, j int) bool { return orders[i].priority < orders[j].priority })
+	for _, order := range orders {
+		m.updateLevel(ob, order, UpdateDelete)
+		ob.DeleteOrder(order)
+	}
+	for _, order := range orders {
+		if !adjustOrder(&order.Order, split) {
+			delete(m.orders, order.ID)
+			m.unexpose(order)
+			m.handler.OnDeleteOrder(order.Order)
+			continue
+		}
+		m.reexpose(order)
+		m.enqueue(order)
+		ob.AddOrder(order)
+		m.handler.OnUpdateOrder(order.Order)
+		m.updateLevel(ob, order, UpdateAdd)
+	}
+
+	ob.lastBidPrice = splitPrice(ob.lastBidPrice, split, false)
+	ob.lastAskPrice = splitPrice(ob.lastAskPrice, split, true)
+	ob.matchingPrice = splitPrice(ob.matchingPrice, split, false)
+	s := &ob.session
+	s.Open = splitPrice(s.Open, split, false)
+	s.High = splitPrice(s.High, split, false)
+	s.Low = splitPrice(s.Low, split, false)
+	s.Last = splitPrice(s.Last, split, false)
+	s.Volume = splitQuantity(s.Volume, split)
+	return ErrorOK
+}
+
+// adjustOrder adjusts an order by a split and reports whether it has leaves
+// quantity left
+func adjustOrder(order *Order, split Split) bool {
+	buy := order.IsBuy()
+	order.Price = splitPrice(order.Price, split, !buy)
+	order.StopPrice = splitPrice(order.StopPrice, split, buy)
+	if order.Slippage != MaxSlippage {
+		order.Slippage = splitPrice(order.Slippage, split, false)
+	}
+	if order.TrailingDistance > 0 {
+		order.TrailingDistance = int64(splitPrice(uint64(order.TrailingDistance), split, false))
+	}
+	if order.TrailingStep > 0 {
+		order.TrailingStep = int64(splitPrice(uint64(order.TrailingStep), split, false))
+	}
+
+	order.ExecutedQuantity = splitQuantity(order.ExecutedQuantity, split)
+	order.LeavesQuantity = splitQuantity(order.LeavesQuantity, split)
+	order.Quantity = order.ExecutedQuantity + order.LeavesQuantity
+	if order.MaxVisibleQuantity != MaxVisibleQuantity && order.MaxVisibleQuantity > 0 {
+		// An iceberg stays an iceberg rather than becoming hidden
+		order.MaxVisibleQuantity = max(splitQuantity(order.MaxVisibleQuantity, split), 1)
+	}
+	if order.MinQuantity != 0 {
+		order.MinQuantity = max(min(splitQuantity(order.MinQuantity, split), order.Quantity), 1)
+	}
+	if order.DisplayHighQuantity != 0 {
+		order.DisplayLowQuantity = max(splitQuantity(order.DisplayLowQuantity, split), 1)
+		order.DisplayHighQuantity = max(splitQuantity(order.DisplayHighQuantity, split), order.DisplayLowQuantity)
+	}
+	return order.LeavesQuantity > 0
+}
+
+// splitPrice multiplies a price by From/To, rounding up if requested
+func splitPrice(price uint64, split Split, up bool) uint64 {
+	return mulDiv(price, split.From, split.To, up)
+}
+
+// splitQuantity multiplies a quantity by To/From, dropping fractional shares
+func splitQuantity(quantity uint64, split Split) uint64 {
+	return mulDiv(quantity, split.To, split.From, false)
+}
+
+// mulDiv returns v*mul/div without intermediate overflow, saturating at the
+// maximum uint64
+func mulDiv(v, mul, div uint64, up bool) uint64 {
+	hi, lo := bits.Mul64(v, mul)
+	if hi >= div {
+		return math.MaxUint64
+	}
+	q, r := bits.Div64(hi, lo, div)
+	if up && r != 0 && q < math.MaxUint64 {
+		q++
+	}
+	return q
+}
+
+// RenameSymbol changes the name of a symbol, for example after a ticker
+// change. The symbol keeps its ID, order book and orders, so trading
+// continues under the new name. Handlers are notified with OnAddSymbol
+// carrying the existing ID, the way a new Stock Directory message would
+// announce the name.
+func (m *MarketManager) RenameSymbol(id uint32, name string) ErrorCode {
+	defer m.operation()()
+	symbol, exists := m.symbols[id]
+	if !exists {
+		return ErrorSymbolNotFound
+	}
+
+	renamed := NewSymbol(id, name)
+	if renamed.Name == symbol.Name {
+		return ErrorOK
+	}
+	old := symbol.Name
+	symbol.Name = renamed.Name
+	if m.symbolIDs[old] == id {
+		m.unindexSymbolName(old)
+	}
+	if _, exists := m.symbolIDs[renamed.Name]; !exists {
+		m.symbolIDs[renamed.Name] = id
+	}
+	if ob := m.orderBooks[id]; ob != nil {
+		ob.symbol.Name = renamed.Name
+	}
+	m.handler.OnAddSymbol(*symbol)
+	return ErrorOK
+}
@@ -0,0 +1,116 @@
+package matching
+
+import "testing"
+
+// corporateHandler records the events of corporate actions
+type corporateHandler struct {
+	DefaultMarketHandler
+	symbols []Symbol
+	updated []uint64
+	deleted []uint64
+	crossed int
+}
+
+func (h *corporateHandler) OnAddSymbol(symbol Symbol) {
+	h.symbols = append(h.symbols, symbol)
+}
+
+func (h *corporateHandler) OnUpdateOrder(order Order) {
+	h.updated = append(h.updated, order.ID)
+}
+
+func (h *corporateHandler) OnDeleteOrder(order Order) {
+	h.deleted = append(h.deleted, order.ID)
+}
+
+func (h *corporateHandler) OnBookCrossed(orderBook *OrderBook) {
+	h.crossed++
+}
+
+func TestMarketManager_ApplySplit(t *testing.T) {
+	handler := &corporateHandler{}
+	manager := newConfigManager(handler)
+	manager.EnableMatching()
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10001, 10))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideSell, 10003, 10))
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideBuy, 10001, 5))
+	manager.AddOrder(*NewLimitOrder(4, 1, OrderSideSell, 10003, 1))
+	manager.AddOrder(*NewStopOrder(5, 1, OrderSideSell, 9001, 4))
+	manager.AddOrder(*NewLimitOrder(6, 1, OrderSideBuy, 10003, 4))
+
+	// 3-for-2: prices * 2/3, quantities * 3/2
+	if err := manager.ApplySplit(Split{SymbolID: 1, To: 3, From: 2}); err != ErrorOK {
+		t.Fatalf("ApplySplit failed: %s", err)
+	}
+	if o := manager.GetOrder(1); o.Price != 6667 || o.Quantity != 15 || o.LeavesQuantity != 15 {
+		t.Errorf("Expected buy 15 @ 6667, got %d @ %d", o.Quantity, o.Price)
+	}
+	if o := manager.GetOrder(2); o.Price != 6669 || o.ExecutedQuantity != 6 || o.LeavesQuantity != 9 || o.Quantity != 15 {
+		t.Errorf("Expected sell 9 of 15 @ 6669, got %d of %d @ %d", o.LeavesQuantity, o.Quantity, o.Price)
+	}
+	if o := manager.GetOrder(5); o.StopPrice != 6000 || o.Quantity != 6 {
+		t.Errorf("Expected sell stop 6 @ 6000, got %d @ %d", o.Quantity, o.StopPrice)
+	}
+	// The 1 share order rounds to 1 share
+	if o := manager.GetOrder(4); o == nil || o.Quantity != 1 {
+		t.Errorf("Expected order 4 to keep 1 share, got %+v", o)
+	}
+
+	ob := manager.GetOrderBook(1)
+	if bid := ob.BestBid(); bid.TotalVolume != 22 || bid.OrderList.Front().ID != 1 {
+		t.Errorf("Expected 22 bid shares with order 1 first, got %d", bid.TotalVolume)
+	}
+	if ob.SessionStats().Last != 6668 || ob.SessionStats().Volume != 6 {
+		t.Errorf("Expected the session stats to be adjusted, got %+v", ob.SessionStats())
+	}
+	if handler.crossed != 0 {
+		t.Error("Expected the book to stay uncrossed")
+	}
+
+	// 1-for-3 reverse split drops the orders left without shares
+	handler.deleted = nil
+	manager.ApplySplit(Split{SymbolID: 1, To: 1, From: 3})
+	if len(handler.deleted) != 1 || handler.deleted[0] != 4 {
+		t.Errorf("Expected order 4 to be cancelled, got %v", handler.deleted)
+	}
+	if o := manager.GetOrder(1); o.Price != 20001 || o.Quantity != 5 {
+		t.Errorf("Expected buy 5 @ 20001, got %d @ %d", o.Quantity, o.Price)
+	}
+
+	if err := manager.ApplySplit(Split{SymbolID: 1, To: 2, From: 1, Policy: SplitCancel}); err != ErrorOK {
+		t.Fatalf("ApplySplit failed: %s", err)
+	}
+	if len(manager.Orders()) != 0 {
+		t.Errorf("Expected all orders to be cancelled, got %d", len(manager.Orders()))
+	}
+
+	if err := manager.ApplySplit(Split{SymbolID: 1, To: 0, From: 1}); err != ErrorOrderParameterInvalid {
+		t.Errorf("Expected ErrorOrderParameterInvalid, got %s", err)
+	}
+	if err := manager.ApplySplit(Split{SymbolID: 2, To: 2, From: 1}); err != ErrorOrderBookNotFound {
+		t.Errorf("Expected ErrorOrderBookNotFound, got %s", err)
+	}
+}
+
+func TestMarketManager_RenameSymbol(t *testing.T) {
+	handler := &corporateHandler{}
+	manager := newConfigManager(handler)
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 10))
+
+	if err := manager.RenameSymbol(1, "AAPL2"); err != ErrorOK {
+		t.Fatalf("RenameSymbol failed: %s", err)
+	}
+	if manager.GetSymbolByName("AAPL") != nil {
+		t.Error("Expected the old name to be unknown")
+	}
+	if ob := manager.GetOrderBookByName("AAPL2"); ob == nil || ob.Symbol().Name != "AAPL2" || ob.Size() != 1 {
+		t.Error("Expected the order book to continue under the new name")
+	}
+	if last := handler.symbols[len(handler.symbols)-1]; last.ID != 1 || last.Name != "AAPL2" {
+		t.Errorf("Expected the new name to be announced, got %+v", last)
+	}
+	if err := manager.RenameSymbol(2, "MSFT"); err != ErrorSymbolNotFound {
+		t.Errorf("Expected ErrorSymbolNotFound, got %s", err)
+	}
+}
@@ -0,0 +1,295 @@
+package matching
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSV order schema used by LoadOrdersCSV and DumpOrdersCSV.
+// Column names follow the FIX tag names. The first row is a header and columns
+// may appear in any order; only OrderID, Symbol, Side and OrderQty are required.
+//
+//	OrderID          order ID (FIX ClOrdID, must be non-zero)
+//	Symbol           numeric symbol ID
+//	Side             BUY or SELL (FIX codes 1 and 2 are also accepted)
+//	OrdType          MARKET, LIMIT, STOP, STOP_LIMIT, TRAILING_STOP or
+//	                 TRAILING_STOP_LIMIT (FIX codes 1-4 are also accepted), default LIMIT
+//	Price            limit price
+//	StopPx           stop price
+//	OrderQty         total quantity
+//	CumQty           executed quantity, default 0
+//	LeavesQty        remaining quantity, default OrderQty - CumQty
+//	TimeInForce      GTC, IOC, FOK, AON or DAY (FIX codes 0, 1, 3 and 4 are
+//	                 also accepted), default GTC
+//	MaxFloor         maximum visible quantity, empty for no limit
+//	Slippage         market order slippage, empty for no limit
+//	TrailingDistance trailing stop distance
+//	TrailingStep     trailing stop step
+//	MinQty           minimum execution quantity, default 0 for none
+//	PartyID          participant ID, default 0 for anonymous orders
+//	Account          clearing account ID, default 0 for the default account
+//	Quote            Y for a market maker quote, default N
+//	DisplayLow       lowest randomized iceberg display, default 0 for a fixed one
+//	DisplayHigh      highest randomized iceberg display, default 0 for a fixed
+//	                 one; DisplayLow must be set and at most DisplayHigh
+//
+// Empty cells take the default value of the column.
+var csvColumns = []string{
+	"OrderID", "Symbol", "Side", "OrdType", "Price", "StopPx", "OrderQty", "CumQty", "LeavesQty",
+	"TimeInForce", "MaxFloor", "Slippage", "TrailingDistance", "TrailingStep", "MinQty",
+	"PartyID", "Account", "Quote", "DisplayLow", "DisplayHigh",
+}
+
+// csvRequired are the columns that must be present in the header
+var csvRequired = []string{"OrderID", "Symbol", "Side", "OrderQty"}
+
+var csvSides = map[string]OrderSide{
+	"BUY": OrderSideBuy, "1": OrderSideBuy,
+	"SELL": OrderSideSell, "2": OrderSideSell,
+}
+
+var csvOrderTypes = map[string]OrderType{
+	"MARKET": OrderTypeMarket, "1": OrderTypeMarket,
+	"LIMIT": OrderTypeLimit, "2": OrderTypeLimit,
+	"STOP": OrderTypeStop, "3": OrderTypeStop,
+	"STOP_LIMIT": OrderTypeStopLimit, "4": OrderTypeStopLimit,
+	"TRAILING_STOP":       OrderTypeTrailingStop,
+	"TRAILING_STOP_LIMIT": OrderTypeTrailingStopLimit,
+}
+
+var csvTimeInForces = map[string]OrderTimeInForce{
+	"DAY": OrderTimeInForceDay, "0": OrderTimeInForceDay,
+	"GTC": OrderTimeInForceGTC, "1": OrderTimeInForceGTC,
+	"IOC": OrderTimeInForceIOC, "3": OrderTimeInForceIOC,
+	"FOK": OrderTimeInForceFOK, "4": OrderTimeInForceFOK,
+	"AON": OrderTimeInForceAON,
+}
+
+// LoadOrdersCSV reads orders in the CSV order schema.
+// The orders are returned in file order and can be added to a MarketManager
+// with AddOrder (or RestoreOrder to keep CumQty) or written to a snapshot.
+func LoadOrdersCSV(r io.Reader) ([]Order, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("csv: missing header")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csv: %w", err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.TrimSpace(name)] = i
+	}
+	for _, name := range csvRequired {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("csv: missing required column %s", name)
+		}
+	}
+	for name := range index {
+		if !isCSVColumn(name) {
+			return nil, fmt.Errorf("csv: unknown column %s", name)
+		}
+	}
+
+	var orders []Order
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return orders, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		order, err := parseCSVOrder(record, index)
+		if err != nil {
+			return nil, fmt.Errorf("csv: line %d: %w", line, err)
+		}
+		orders = append(orders, order)
+	}
+}
+
+// isCSVColumn returns true if name is part of the CSV order schema
+func isCSVColumn(name string) bool {
+	for _, column := range csvColumns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCSVOrder converts a CSV record into an order
+func parseCSVOrder(record []string, index map[string]int) (Order, error) {
+	field := func(name string) string {
+		if i, ok := index[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	var err error
+	number := func(name string, def uint64) uint64 {
+		value := field(name)
+		if value == "" || err != nil {
+			return def
+		}
+		n, perr := strconv.ParseUint(value, 10, 64)
+		if perr != nil {
+			err = fmt.Errorf("invalid %s %q", name, value)
+		}
+		return n
+	}
+	signed := func(name string) int64 {
+		value := field(name)
+		if value == "" || err != nil {
+			return 0
+		}
+		n, perr := strconv.ParseInt(value, 10, 64)
+		if perr != nil {
+			err = fmt.Errorf("invalid %s %q", name, value)
+		}
+		return n
+	}
+	id32 := func(name string) uint32 {
+		n := number(name, 0)
+		if n > 0xFFFFFFFF && err == nil {
+			err = fmt.Errorf("invalid %s %d", name, n)
+		}
+		return uint32(n)
+	}
+
+	order := Order{
+		ID:                  number("OrderID", 0),
+		Price:               number("Price", 0),
+		StopPrice:           number("StopPx", 0),
+		Quantity:            number("OrderQty", 0),
+		ExecutedQuantity:    number("CumQty", 0),
+		MaxVisibleQuantity:  number("MaxFloor", MaxVisibleQuantity),
+		Slippage:            number("Slippage", MaxSlippage),
+		DisplayLowQuantity:  number("DisplayLow", 0),
+		DisplayHighQuantity: number("DisplayHigh", 0),
+		TrailingDistance:    signed("TrailingDistance"),
+		TrailingStep:        signed("TrailingStep"),
+		MinQuantity:         number("MinQty", 0),
+		ParticipantID:       id32("PartyID"),
+		AccountID:           id32("Account"),
+	}
+	symbol := number("Symbol", 0)
+	if err != nil {
+		return Order{}, err
+	}
+	if symbol > 0xFFFFFFFF {
+		return Order{}, fmt.Errorf("invalid Symbol %d", symbol)
+	}
+	order.SymbolID = uint32(symbol)
+
+	if order.ID == 0 {
+		return Order{}, fmt.Errorf("missing OrderID")
+	}
+	if order.DisplayHighQuantity != 0 && (order.DisplayLowQuantity == 0 || order.DisplayLowQuantity > order.DisplayHighQuantity) {
+		return Order{}, fmt.Errorf("invalid display range %d-%d", order.DisplayLowQuantity, order.DisplayHighQuantity)
+	}
+	if order.ExecutedQuantity > order.Quantity {
+		return Order{}, fmt.Errorf("CumQty %d exceeds OrderQty %d", order.ExecutedQuantity, order.Quantity)
+	}
+	order.LeavesQuantity = number("LeavesQty", order.Quantity-order.ExecutedQuantity)
+	if err != nil {
+		return Order{}, err
+	}
+
+	side, ok := csvSides[strings.ToUpper(field("Side"))]
+	if !ok {
+		return Order{}, fmt.Errorf("invalid Side %q", field("Side"))
+	}
+	order.Side = side
+
+	order.Type = OrderTypeLimit
+	if value := field("OrdType"); value != "" {
+		orderType, ok := csvOrderTypes[strings.ToUpper(value)]
+		if !ok {
+			return Order{}, fmt.Errorf("invalid OrdType %q", value)
+		}
+		order.Type = orderType
+	}
+
+	order.TimeInForce = OrderTimeInForceGTC
+	if value := field("TimeInForce"); value != "" {
+		tif, ok := csvTimeInForces[strings.ToUpper(value)]
+		if !ok {
+			return Order{}, fmt.Errorf("invalid TimeInForce %q", value)
+		}
+		order.TimeInForce = tif
+	}
+
+	switch strings.ToUpper(field("Quote")) {
+	case "Y":
+		order.Quote = true
+	case "", "N":
+	default:
+		return Order{}, fmt.Errorf("invalid Quote %q", field("Quote"))
+	}
+
+	return order, nil
+}
+
+// DumpOrdersCSV writes orders in the CSV order schema, including a header row.
+// Unlimited MaxFloor and Slippage values are written as empty cells.
+func DumpOrdersCSV(w io.Writer, orders []Order) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvColumns); err != nil {
+		return err
+	}
+
+	flag := func(value bool) string {
+		if value {
+			return "Y"
+		}
+		return "N"
+	}
+	unlimited := func(value, max uint64) string {
+		if value == max {
+			return ""
+		}
+		return strconv.FormatUint(value, 10)
+	}
+
+	for _, o := range orders {
+		record := []string{
+			strconv.FormatUint(o.ID, 10),
+			strconv.FormatUint(uint64(o.SymbolID), 10),
+			o.Side.String(),
+			o.Type.String(),
+			strconv.FormatUint(o.Price, 10),
+			strconv.FormatUint(o.StopPrice, 10),
+			strconv.FormatUint(o.Quantity, 10),
+			strconv.FormatUint(o.ExecutedQuantity, 10),
+			strconv.FormatUint(o.LeavesQuantity, 10),
+			o.TimeInForce.String(),
+			unlimited(o.MaxVisibleQuantity, MaxVisibleQuantity),
+			unlimited(o.Slippage, MaxSlippage),
+			strconv.FormatInt(o.TrailingDistance, 10),
+			strconv.FormatInt(o.TrailingStep, 10),
+			strconv.FormatUint(o.MinQuantity, 10),
+			strconv.FormatUint(uint64(o.ParticipantID), 10),
+			strconv.FormatUint(uint64(o.AccountID), 10),
+			flag(o.Quote),
+			strconv.FormatUint(o.DisplayLowQuantity, 10),
+			strconv.FormatUint(o.DisplayHighQuantity, 10),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,104 @@
+package matching
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLoadOrdersCSV(t *testing.T) {
+	input := `# book seed
+OrderID,Symbol,Side,OrderQty,Price,TimeInForce,OrdType,CumQty,MaxFloor
+1,1,BUY,100,10000,,,,
+2,1,2,50,10100,DAY,2,20,10
+3,1,sell,10,0,ioc,MARKET,,
+`
+	orders, err := LoadOrdersCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(orders) != 3 {
+		t.Fatalf("Expected 3 orders, got %d", len(orders))
+	}
+
+	expected := *NewLimitOrder(1, 1, OrderSideBuy, 10000, 100)
+	if orders[0] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, orders[0])
+	}
+
+	o := orders[1]
+	if o.Side != OrderSideSell || o.Type != OrderTypeLimit || o.TimeInForce != OrderTimeInForceDay {
+		t.Errorf("Expected SELL LIMIT DAY, got %s %s %s", o.Side, o.Type, o.TimeInForce)
+	}
+	if o.ExecutedQuantity != 20 || o.LeavesQuantity != 30 || o.MaxVisibleQuantity != 10 {
+		t.Errorf("Expected 20 executed / 30 leaves / 10 visible, got %d / %d / %d",
+			o.ExecutedQuantity, o.LeavesQuantity, o.MaxVisibleQuantity)
+	}
+
+	if orders[2].Type != OrderTypeMarket || orders[2].TimeInForce != OrderTimeInForceIOC {
+		t.Errorf("Expected MARKET IOC, got %s %s", orders[2].Type, orders[2].TimeInForce)
+	}
+}
+
+func TestLoadOrdersCSV_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"empty", "", "missing header"},
+		{"missing column", "OrderID,Symbol,Side\n", "missing required column OrderQty"},
+		{"unknown column", "OrderID,Symbol,Side,OrderQty,Currency\n", "unknown column Currency"},
+		{"bad side", "OrderID,Symbol,Side,OrderQty\n1,1,HOLD,10\n", "line 2: invalid Side"},
+		{"bad number", "OrderID,Symbol,Side,OrderQty\n1,1,BUY,ten\n", "line 2: invalid OrderQty"},
+		{"zero id", "OrderID,Symbol,Side,OrderQty\n0,1,BUY,10\n", "line 2: missing OrderID"},
+		{"overfilled", "OrderID,Symbol,Side,OrderQty,CumQty\n1,1,BUY,10,11\n", "line 2: CumQty 11 exceeds"},
+		{"bad quote", "OrderID,Symbol,Side,OrderQty,Quote\n1,1,BUY,10,maybe\n", "line 2: invalid Quote"},
+		{"bad display range", "OrderID,Symbol,Side,OrderQty,DisplayLow,DisplayHigh\n1,1,BUY,10,8,3\n", "line 2: invalid display range 8-3"},
+		{"display high only", "OrderID,Symbol,Side,OrderQty,DisplayHigh\n1,1,BUY,10,3\n", "line 2: invalid display range 0-3"},
+		{"bad party", "OrderID,Symbol,Side,OrderQty,PartyID\n1,1,BUY,10,4294967296\n", "line 2: invalid PartyID"},
+	}
+
+	for _, tt := range tests {
+		_, err := LoadOrdersCSV(strings.NewReader(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestDumpOrdersCSV_RoundTrip(t *testing.T) {
+	stop := NewStopLimitOrder(2, 7, OrderSideSell, 9900, 9950, 40)
+	stop.ExecutedQuantity = 10
+	stop.LeavesQuantity = 30
+	stop.MaxVisibleQuantity = 5
+	stop.TimeInForce = OrderTimeInForceDay
+	stop.MinQuantity = 20
+	stop.ParticipantID = 42
+	stop.AccountID = 7
+	stop.DisplayLowQuantity, stop.DisplayHighQuantity = 3, 8
+	trailing := NewOrder(3, 7, OrderTypeTrailingStop, OrderSideBuy, 0, 10100, 10)
+	trailing.TrailingDistance = -100
+	trailing.TrailingStep = 5
+	quote := NewLimitOrder(4, 7, OrderSideSell, 10200, 50)
+	quote.Quote = true
+	orders := []Order{*NewLimitOrder(1, 7, OrderSideBuy, 10000, 100), *stop, *trailing, *quote}
+
+	var buf bytes.Buffer
+	if err := DumpOrdersCSV(&buf, orders); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	loaded, err := LoadOrdersCSV(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(loaded) != len(orders) {
+		t.Fatalf("Expected %d orders, got %d", len(orders), len(loaded))
+	}
+	for i := range orders {
+		if loaded[i] != orders[i] {
+			t.Errorf("Expected %+v, got %+v", orders[i], loaded[i])
+		}
+	}
+}
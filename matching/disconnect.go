@@ -0,0 +1,157 @@
+package matching
+
+import (
+	"sort"
+	"time"
+)
+
+// orderSession is a connection of a participant whose orders are canceled
+// together when it closes
+type orderSession struct {
+	participantID uint32
+	// timeout is the inactivity after which the session expires, 0 for none
+	timeout time.Duration
+	// lastSeen is the time the session was opened or last heartbeat
+	lastSeen time.Time
+	// orders are the IDs of the orders tagged to the session. Orders that
+	// left the book are pruned lazily.
+	orders map[uint64]struct{}
+	// pruneAt is the number of tagged orders at which they are next pruned
+	pruneAt int
+}
+
+// OpenSession registers a cancel-on-disconnect session of a participant.
+// Orders entered with AddSessionOrder are tagged to the session, and so are
+// their replacements; CloseSession and ExpireSessions delete the tagged open
+// orders, reporting each with OnDeleteOrder. A session with a non-zero
+// timeout expires when it sees no heartbeat for that long.
+//
+// Sessions live in memory only: they are not journaled or snapshotted, and
+// orders restored after a restart belong to no session.
+func (m *MarketManager) OpenSession(sessionID uint64, participantID uint32, timeout time.Duration) ErrorCode {
+	if sessionID == 0 {
+		return ErrorSessionInvalid
+	}
+	if m.sessions == nil {
+		m.sessions = make(map[uint64]*orderSession)
+	}
+	if _, exists := m.sessions[sessionID]; exists {
+		return ErrorSessionDuplicate
+	}
+	m.sessions[sessionID] = &orderSession{
+		participantID: participantID,
+		timeout:       timeout,
+		lastSeen:      m.now(),
+		orders:        make(map[uint64]struct{}),
+	}
+	return ErrorOK
+}
+
+// AddSessionOrder adds an order on behalf of the session's participant, as
+// Participant.AddOrder does, and tags it to the session if it rests
+func (m *MarketManager) AddSessionOrder(sessionID uint64, order Order) ErrorCode {
+	defer m.operation()()
+	s, exists := m.sessions[sessionID]
+	if !exists {
+		return ErrorSessionNotFound
+	}
+	if err := m.Participant(s.participantID).AddOrder(order); err != ErrorOK {
+		return err
+	}
+	if len(s.orders) >= s.pruneAt {
+		m.sessionOrders(sessionID, s)
+		s.pruneAt = max(64, 2*len(s.orders))
+	}
+	if orderNode, exists := m.orders[order.ID]; exists {
+		orderNode.session = sessionID
+		s.orders[order.ID] = struct{}{}
+	}
+	return ErrorOK
+}
+
+// HeartbeatSession keeps a session from expiring
+func (m *MarketManager) HeartbeatSession(sessionID uint64) ErrorCode {
+	s, exists := m.sessions[sessionID]
+	if !exists {
+		return ErrorSessionNotFound
+	}
+	s.lastSeen = m.now()
+	return ErrorOK
+}
+
+// SessionOrders returns the IDs of the open orders of a session in ID order
+func (m *MarketManager) SessionOrders(sessionID uint64) []uint64 {
+	s, exists := m.sessions[sessionID]
+	if !exists {
+		return nil
+	}
+	return m.sessionOrders(sessionID, s)
+}
+
+// CloseSession deletes the open orders of a session in ID order and forgets
+// the session
+func (m *MarketManager) CloseSession(sessionID uint64) ErrorCode {
+	defer m.operation()()
+	s, exists := m.sessions[sessionID]
+	if !exists {
+		return ErrorSessionNotFound
+	}
+	delete(m.sessions, sessionID)
+	for _, id := range m.sessionOrders(sessionID, s) {
+		m.DeleteOrder(id)
+	}
+	return ErrorOK
+}
+
+// ExpireSessions closes the sessions whose timeout elapsed since their last
+// heartbeat, by the market manager clock, and returns their IDs in ID order.
+// Deployments call it periodically.
+func (m *MarketManager) ExpireSessions() []uint64 {
+	defer m.operation()()
+	expired := m.expiredSessions()
+	for _, id := range expired {
+		m.CloseSession(id)
+	}
+	return expired
+}
+
+// expiredSessions returns the IDs of the expired sessions in ID order
+func (m *MarketManager) expiredSessions() []uint64 {
+	now := m.now()
+	var expired []uint64
+	for id, s := range m.sessions {
+		if s.timeout > 0 && now.Sub(s.lastSeen) >= s.timeout {
+			expired = append(expired, id)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i] < expired[j] })
+	return expired
+}
+
+// sessionOrders prunes the orders of a session that left the book and
+// returns the others in ID order
+func (m *MarketManager) sessionOrders(sessionID uint64, s *orderSession) []uint64 {
+	ids := make([]uint64, 0, len(s.orders))
+	for id := range s.orders {
+		// The ID may have been reused by an order of another session
+		if orderNode, exists := m.orders[id]; exists && orderNode.session == sessionID {
+			ids = append(ids, id)
+		} else {
+			delete(s.orders, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// tagReplacement carries the session of a replaced order over to its
+// replacement
+func (m *MarketManager) tagReplacement(orderNode, replacement *OrderNode) {
+	if orderNode.session == 0 {
+		return
+	}
+	if s, exists := m.sessions[orderNode.session]; exists {
+		replacement.session = orderNode.session
+		s.orders[replacement.ID] = struct{}{}
+	}
+}
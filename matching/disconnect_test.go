@@ -0,0 +1,132 @@
+package matching
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSessions_CloseCancelsOrders(t *testing.T) {
+	handler := &configHandler{}
+	manager := newConfigManager(handler)
+
+	if err := manager.OpenSession(0, 7, 0); err != ErrorSessionInvalid {
+		t.Errorf("Expected SESSION_INVALID, got %s", err)
+	}
+	if err := manager.OpenSession(1, 7, 0); err != ErrorOK {
+		t.Fatalf("OpenSession failed: %s", err)
+	}
+	if err := manager.OpenSession(1, 7, 0); err != ErrorSessionDuplicate {
+		t.Errorf("Expected SESSION_DUPLICATE, got %s", err)
+	}
+
+	for id := uint64(3); id >= 1; id-- {
+		if err := manager.AddSessionOrder(1, participantOrder(id, 1, OrderSideBuy, 10000, 100, 0)); err != ErrorOK {
+			t.Fatalf("AddSessionOrder %d failed: %s", id, err)
+		}
+	}
+	if order := manager.GetOrder(1); order.ParticipantID != 7 {
+		t.Errorf("Expected session order of participant 7, got %d", order.ParticipantID)
+	}
+	// Orders entered outside the session are not canceled with it
+	if err := manager.AddOrder(participantOrder(4, 1, OrderSideBuy, 10000, 100, 7)); err != ErrorOK {
+		t.Fatalf("AddOrder failed: %s", err)
+	}
+	// Replacements stay in the session, filled orders leave it
+	if err := manager.ReplaceOrder(2, 5, 9900, 100); err != ErrorOK {
+		t.Fatalf("ReplaceOrder failed: %s", err)
+	}
+	if err := manager.ExecuteOrder(3, 100); err != ErrorOK {
+		t.Fatalf("ExecuteOrder failed: %s", err)
+	}
+	if orders := manager.SessionOrders(1); !reflect.DeepEqual(orders, []uint64{1, 5}) {
+		t.Errorf("Expected session orders [1 5], got %v", orders)
+	}
+
+	handler.deleted = nil
+	if err := manager.CloseSession(1); err != ErrorOK {
+		t.Fatalf("CloseSession failed: %s", err)
+	}
+	if !reflect.DeepEqual(handler.deleted, []uint64{1, 5}) {
+		t.Errorf("Expected orders 1 and 5 canceled, got %v", handler.deleted)
+	}
+	if manager.GetOrder(4) == nil {
+		t.Error("Expected order outside the session to rest")
+	}
+	if err := manager.CloseSession(1); err != ErrorSessionNotFound {
+		t.Errorf("Expected SESSION_NOT_FOUND, got %s", err)
+	}
+	if err := manager.AddSessionOrder(1, participantOrder(6, 1, OrderSideBuy, 10000, 100, 0)); err != ErrorSessionNotFound {
+		t.Errorf("Expected SESSION_NOT_FOUND, got %s", err)
+	}
+}
+
+func TestSessions_Expire(t *testing.T) {
+	handler := &configHandler{}
+	manager := newConfigManager(handler)
+	now := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	manager.SetClock(func() time.Time { return now })
+
+	manager.OpenSession(1, 7, 10*time.Second)
+	manager.OpenSession(2, 8, 10*time.Second)
+	manager.OpenSession(3, 9, 0)
+	manager.AddSessionOrder(1, participantOrder(1, 1, OrderSideBuy, 10000, 100, 0))
+	manager.AddSessionOrder(2, participantOrder(2, 1, OrderSideSell, 10100, 100, 0))
+	manager.AddSessionOrder(3, participantOrder(3, 1, OrderSideSell, 10200, 100, 0))
+
+	now = now.Add(6 * time.Second)
+	if err := manager.HeartbeatSession(2); err != ErrorOK {
+		t.Fatalf("HeartbeatSession failed: %s", err)
+	}
+	if expired := manager.ExpireSessions(); len(expired) != 0 {
+		t.Errorf("Expected no expired session, got %v", expired)
+	}
+
+	now = now.Add(6 * time.Second)
+	if expired := manager.ExpireSessions(); !reflect.DeepEqual(expired, []uint64{1}) {
+		t.Errorf("Expected session 1 expired, got %v", expired)
+	}
+	if !reflect.DeepEqual(handler.deleted, []uint64{1}) {
+		t.Errorf("Expected order 1 canceled, got %v", handler.deleted)
+	}
+
+	// Sessions without a timeout never expire
+	now = now.Add(time.Hour)
+	if expired := manager.ExpireSessions(); !reflect.DeepEqual(expired, []uint64{2}) {
+		t.Errorf("Expected session 2 expired, got %v", expired)
+	}
+	if manager.GetOrder(3) == nil {
+		t.Error("Expected order of session 3 to rest")
+	}
+}
+
+func TestEngine_Sessions(t *testing.T) {
+	handler := &configHandler{}
+	engine := NewEngineWithHandler(2, handler)
+	defer engine.Close()
+	for id := uint32(1); id <= 2; id++ {
+		symbol := NewSymbol(id, "S"+string(rune('0'+id)))
+		engine.AddSymbol(symbol)
+		engine.AddOrderBook(symbol)
+	}
+
+	if err := engine.OpenSession(1, 7, time.Nanosecond); err != ErrorOK {
+		t.Fatalf("OpenSession failed: %s", err)
+	}
+	engine.AddSessionOrder(1, participantOrder(1, 1, OrderSideBuy, 10000, 100, 0))
+	engine.AddSessionOrder(1, participantOrder(2, 2, OrderSideBuy, 10000, 100, 0))
+
+	time.Sleep(time.Millisecond)
+	if expired := engine.ExpireSessions(); !reflect.DeepEqual(expired, []uint64{1}) {
+		t.Errorf("Expected session 1 expired, got %v", expired)
+	}
+	if _, ok := engine.GetOrder(1, 1); ok {
+		t.Error("Expected order 1 canceled")
+	}
+	if _, ok := engine.GetOrder(2, 2); ok {
+		t.Error("Expected order 2 canceled")
+	}
+	if err := engine.CloseSession(1); err != ErrorSessionNotFound {
+		t.Errorf("Expected SESSION_NOT_FOUND, got %s", err)
+	}
+}
@@ -0,0 +1,112 @@
+package matching
+
+import (
+	"strconv"
+	"strings"
+)
+
+// domHeader is the header row of a depth-of-market ladder
+var domHeader = [5]string{"ORDERS", "BID", "PRICE", "ASK", "ORDERS"}
+
+// RenderDOM renders the top levels of each side as a depth-of-market ladder:
+// asks from the highest down, a spread row, then bids from the best down.
+// Volumes are total volumes, hidden included. levels <= 0 renders every
+// level.
+//
+//	ORDERS  BID  PRICE  ASK  ORDERS
+//	             10200  100       1
+//	             10100  300       2
+//	--------- spread 100 ----------
+//	     3  500  10000
+func (ob *OrderBook) RenderDOM(levels int) string {
+	return RenderLadder(topLevels(ob.bids, levels), topLevels(ob.asks, levels), levels)
+}
+
+// RenderLadder renders bid and ask levels, each ordered best first, as the
+// ladder of OrderBook.RenderDOM, for depth received from a remote book
+func RenderLadder(bids, asks []Level, levels int) string {
+	if levels > 0 {
+		bids = bids[:min(len(bids), levels)]
+		asks = asks[:min(len(asks), levels)]
+	}
+
+	rows := make([][5]string, 0, len(bids)+len(asks)+1)
+	rows = append(rows, domHeader)
+	for i := len(asks) - 1; i >= 0; i-- {
+		a := asks[i]
+		rows = append(rows, [5]string{"", "", formatUint(a.Price), formatUint(a.TotalVolume), formatUint(a.Orders)})
+	}
+	for _, b := range bids {
+		rows = append(rows, [5]string{formatUint(b.Orders), formatUint(b.TotalVolume), formatUint(b.Price), "", ""})
+	}
+
+	var widths [5]int
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	width := 2 * (len(widths) - 1)
+	for _, w := range widths {
+		width += w
+	}
+
+	var sb strings.Builder
+	for i, row := range rows {
+		if i == len(asks)+1 {
+			writeSpreadRow(&sb, spreadLabel(bids, asks), width)
+		}
+		var line strings.Builder
+		for j, cell := range row {
+			if j > 0 {
+				line.WriteString("  ")
+			}
+			line.WriteString(strings.Repeat(" ", widths[j]-len(cell)))
+			line.WriteString(cell)
+		}
+		sb.WriteString(strings.TrimRight(line.String(), " "))
+		sb.WriteByte('\n')
+	}
+	if len(bids) == 0 {
+		writeSpreadRow(&sb, spreadLabel(bids, asks), width)
+	}
+	return sb.String()
+}
+
+// topLevels returns up to n levels of a side, best first, all if n <= 0
+func topLevels(tree *AVLTree, n int) []Level {
+	var levels []Level
+	tree.ForEach(func(node *LevelNode) bool {
+		levels = append(levels, node.Level)
+		return n <= 0 || len(levels) < n
+	})
+	return levels
+}
+
+// spreadLabel describes the spread of the best levels
+func spreadLabel(bids, asks []Level) string {
+	switch {
+	case len(bids) == 0 && len(asks) == 0:
+		return "empty"
+	case len(bids) == 0 || len(asks) == 0:
+		return "no spread"
+	case bids[0].Price >= asks[0].Price:
+		return "crossed"
+	default:
+		return "spread " + formatUint(asks[0].Price-bids[0].Price)
+	}
+}
+
+// writeSpreadRow writes label centered in a row of dashes of width
+func writeSpreadRow(sb *strings.Builder, label string, width int) {
+	label = " " + label + " "
+	dashes := max(width-len(label), 2)
+	sb.WriteString(strings.Repeat("-", dashes/2))
+	sb.WriteString(label)
+	sb.WriteString(strings.Repeat("-", dashes-dashes/2))
+	sb.WriteByte('\n')
+}
+
+func formatUint(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
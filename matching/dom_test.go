@@ -0,0 +1,60 @@
+package matching
+
+import "testing"
+
+func TestOrderBook_RenderDOM(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	ob := manager.GetOrderBook(1)
+
+	if got, want := ob.RenderDOM(5), "ORDERS  BID  PRICE  ASK  ORDERS\n------------ empty ------------\n"; got != want {
+		t.Errorf("Expected empty ladder\n%s, got\n%s", want, got)
+	}
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideSell, 10200, 100))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideSell, 10100, 250))
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideSell, 10100, 50))
+	manager.AddOrder(*NewLimitOrder(4, 1, OrderSideBuy, 10000, 1500))
+	manager.AddOrder(*NewLimitOrder(5, 1, OrderSideBuy, 9900, 10))
+
+	want := "" +
+		"ORDERS   BID  PRICE  ASK  ORDERS\n" +
+		"              10200  100       1\n" +
+		"              10100  300       2\n" +
+		"---------- spread 100 ----------\n" +
+		"     1  1500  10000\n" +
+		"     1    10   9900\n"
+	if got := ob.RenderDOM(0); got != want {
+		t.Errorf("Expected ladder\n%s, got\n%s", want, got)
+	}
+
+	want = "" +
+		"ORDERS   BID  PRICE  ASK  ORDERS\n" +
+		"              10100  300       2\n" +
+		"---------- spread 100 ----------\n" +
+		"     1  1500  10000\n"
+	if got := ob.RenderDOM(1); got != want {
+		t.Errorf("Expected top of book ladder\n%s, got\n%s", want, got)
+	}
+}
+
+func TestRenderLadder_Spread(t *testing.T) {
+	bid := Level{Price: 10100, TotalVolume: 10, Orders: 1}
+	ask := Level{Price: 10000, TotalVolume: 20, Orders: 2}
+
+	want := "" +
+		"ORDERS  BID  PRICE  ASK  ORDERS\n" +
+		"             10000   20       2\n" +
+		"----------- crossed -----------\n" +
+		"     1   10  10100\n"
+	if got := RenderLadder([]Level{bid}, []Level{ask}, 0); got != want {
+		t.Errorf("Expected crossed ladder\n%s, got\n%s", want, got)
+	}
+
+	want = "" +
+		"ORDERS  BID  PRICE  ASK  ORDERS\n" +
+		"             10000   20       2\n" +
+		"---------- no spread ----------\n"
+	if got := RenderLadder(nil, []Level{ask}, 0); got != want {
+		t.Errorf("Expected one-sided ladder\n%s, got\n%s", want, got)
+	}
+}
@@ -0,0 +1,682 @@
+package matching
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// engineQueueSize is the number of commands buffered per shard
+const engineQueueSize = 1024
+
+// Engine shards symbols across several MarketManager instances, each owned by
+// its own goroutine. All operations on a symbol are executed in submission
+// order by the same shard, so every order book stays deterministic while
+// independent books are processed in parallel.
+//
+// Events from all shards are delivered to a single MarketHandler. Calls into
+// the handler are serialized, so it does not need to be thread-safe, but
+// events of different symbols may interleave. Order book pointers passed to
+// the handler must not be retained after the callback returns.
+//
+// Order IDs must be unique across all symbols. Operations on existing orders
+// take the symbol ID to route the request to the owning shard.
+// Thread-safe.
+type Engine struct {
+	shards []*engineShard
+
+	// mu guards closed against concurrent submission
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+
+	// ids is the order ID allocator shared by the shards
+	ids atomic.Pointer[lockedIDAllocator]
+}
+
+// engineShard is a market manager with the goroutine that owns it
+type engineShard struct {
+	manager  *MarketManager
+	commands chan engineCommand
+}
+
+// engineCommand is a unit of work executed by a shard goroutine
+type engineCommand struct {
+	fn   func(m *MarketManager) ErrorCode
+	done chan ErrorCode
+}
+
+// lockedHandler serializes calls from all shards into a single handler
+type lockedHandler struct {
+	mu      sync.Mutex
+	handler MarketHandler
+}
+
+// NewEngine creates a new engine with the given number of shards.
+// If shards is less than 1, one shard per CPU is created.
+func NewEngine(shards int) *Engine {
+	return NewEngineWithHandler(shards, &DefaultMarketHandler{})
+}
+
+// NewEngineWithHandler creates a new engine with a custom handler
+// receiving the events of all shards
+func NewEngineWithHandler(shards int, handler MarketHandler) *Engine {
+	if shards < 1 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+
+	shared := &lockedHandler{handler: handler}
+	ids := &lockedIDAllocator{allocator: NewSequentialIDAllocator(1)}
+	e := &Engine{shards: make([]*engineShard, shards)}
+	e.ids.Store(ids)
+	for i := range e.shards {
+		shard := &engineShard{
+			manager:  NewMarketManagerWithHandler(shared),
+			commands: make(chan engineCommand, engineQueueSize),
+		}
+		shard.manager.SetOrderIDAllocator(ids)
+		e.shards[i] = shard
+		e.wg.Add(1)
+		go e.run(shard)
+	}
+	return e
+}
+
+// run executes the commands of a shard until its queue is closed
+func (e *Engine) run(shard *engineShard) {
+	defer e.wg.Done()
+
+	// Keep the shard on one OS thread so its books stay in the same CPU cache
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	for cmd := range shard.commands {
+		result := cmd.fn(shard.manager)
+		if cmd.done != nil {
+			cmd.done <- result
+		}
+	}
+}
+
+// Shards returns the number of shards
+func (e *Engine) Shards() int {
+	return len(e.shards)
+}
+
+// ShardOf returns the index of the shard owning a symbol
+func (e *Engine) ShardOf(symbolID uint32) int {
+	return int(symbolID % uint32(len(e.shards)))
+}
+
+// Close stops all shards after the queued commands have been executed.
+// Requests submitted after Close fail with ErrorEngineClosed.
+func (e *Engine) Close() {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+	e.closed = true
+	for _, shard := range e.shards {
+		close(shard.commands)
+	}
+	e.mu.Unlock()
+
+	e.wg.Wait()
+}
+
+// submit queues a command on a shard
+func (e *Engine) submit(shard *engineShard, cmd engineCommand) ErrorCode {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return ErrorEngineClosed
+	}
+	shard.commands <- cmd
+	return ErrorOK
+}
+
+// call executes fn on a shard and waits for the result
+func (e *Engine) call(shard *engineShard, fn func(m *MarketManager) ErrorCode) ErrorCode {
+	done := make(chan ErrorCode, 1)
+	if result := e.submit(shard, engineCommand{fn: fn, done: done}); result != ErrorOK {
+		return result
+	}
+	return <-done
+}
+
+// broadcast executes fn on every shard and returns the first error
+func (e *Engine) broadcast(fn func(m *MarketManager) ErrorCode) ErrorCode {
+	results := make([]chan ErrorCode, len(e.shards))
+	for i, shard := range e.shards {
+		results[i] = make(chan ErrorCode, 1)
+		if result := e.submit(shard, engineCommand{fn: fn, done: results[i]}); result != ErrorOK {
+			return result
+		}
+	}
+
+	result := ErrorOK
+	for _, done := range results {
+		if r := <-done; r != ErrorOK && result == ErrorOK {
+			result = r
+		}
+	}
+	return result
+}
+
+// Do executes fn on the shard owning a symbol and waits for it to complete.
+// It can be used to query order books and orders safely; the market manager
+// must not be retained after fn returns.
+func (e *Engine) Do(symbolID uint32, fn func(m *MarketManager) ErrorCode) ErrorCode {
+	return e.call(e.shards[e.ShardOf(symbolID)], fn)
+}
+
+// Post queues fn on the shard owning a symbol without waiting for it.
+// Commands posted for the same symbol are executed in order.
+func (e *Engine) Post(symbolID uint32, fn func(m *MarketManager)) ErrorCode {
+	return e.submit(e.shards[e.ShardOf(symbolID)], engineCommand{fn: func(m *MarketManager) ErrorCode {
+		fn(m)
+		return ErrorOK
+	}})
+}
+
+// EnableMatching enables automatic order matching on all shards
+func (e *Engine) EnableMatching() ErrorCode {
+	return e.broadcast(func(m *MarketManager) ErrorCode {
+		m.EnableMatching()
+		return ErrorOK
+	})
+}
+
+// DisableMatching disables automatic order matching on all shards
+func (e *Engine) DisableMatching() ErrorCode {
+	return e.broadcast(func(m *MarketManager) ErrorCode {
+		m.DisableMatching()
+		return ErrorOK
+	})
+}
+
+// SetPriceRule sets the rule used to price matched orders on all shards
+func (e *Engine) SetPriceRule(rule PriceRule) ErrorCode {
+	return e.broadcast(func(m *MarketManager) ErrorCode {
+		m.SetPriceRule(rule)
+		return ErrorOK
+	})
+}
+
+// SetBookConflation sets how often OnUpdateOrderBook is called on all shards
+func (e *Engine) SetBookConflation(conflation BookConflation) ErrorCode {
+	return e.broadcast(func(m *MarketManager) ErrorCode {
+		m.SetBookConflation(conflation)
+		return ErrorOK
+	})
+}
+
+// AddSymbol adds a new symbol
+func (e *Engine) AddSymbol(symbol Symbol) ErrorCode {
+	return e.Do(symbol.ID, func(m *MarketManager) ErrorCode {
+		return m.AddSymbol(symbol)
+	})
+}
+
+// DeleteSymbol deletes a symbol
+func (e *Engine) DeleteSymbol(id uint32) ErrorCode {
+	return e.Do(id, func(m *MarketManager) ErrorCode {
+		return m.DeleteSymbol(id)
+	})
+}
+
+// AddSpread adds a spread symbol with its order book. The leg symbols must be
+// owned by the shard of the spread.
+func (e *Engine) AddSpread(symbol Symbol, spread Spread) ErrorCode {
+	return e.Do(symbol.ID, func(m *MarketManager) ErrorCode {
+		return m.AddSpread(symbol, spread)
+	})
+}
+
+// AddOrderBook adds a new order book for a symbol
+func (e *Engine) AddOrderBook(symbol Symbol) ErrorCode {
+	return e.Do(symbol.ID, func(m *MarketManager) ErrorCode {
+		return m.AddOrderBook(symbol)
+	})
+}
+
+// DeleteOrderBook deletes an order book
+func (e *Engine) DeleteOrderBook(id uint32) ErrorCode {
+	return e.Do(id, func(m *MarketManager) ErrorCode {
+		return m.DeleteOrderBook(id)
+	})
+}
+
+// SeedBook populates the order book of a symbol from a depth snapshot
+func (e *Engine) SeedBook(symbolID uint32, depth Depth) ErrorCode {
+	return e.Do(symbolID, func(m *MarketManager) ErrorCode {
+		return m.SeedBook(symbolID, depth)
+	})
+}
+
+// AddOrder adds a new order to the shard owning its symbol
+func (e *Engine) AddOrder(order Order) ErrorCode {
+	return e.Do(order.SymbolID, func(m *MarketManager) ErrorCode {
+		return m.AddOrder(order)
+	})
+}
+
+// ReduceOrder reduces the quantity of an order
+func (e *Engine) ReduceOrder(symbolID uint32, id uint64, quantity uint64) ErrorCode {
+	return e.Do(symbolID, func(m *MarketManager) ErrorCode {
+		return m.ReduceOrder(id, quantity)
+	})
+}
+
+// ModifyOrder modifies the price and quantity of an order
+func (e *Engine) ModifyOrder(symbolID uint32, id uint64, newPrice, newQuantity uint64) ErrorCode {
+	return e.Do(symbolID, func(m *MarketManager) ErrorCode {
+		return m.ModifyOrder(id, newPrice, newQuantity)
+	})
+}
+
+// MitigateOrder modifies an order taking its executed quantity into account
+func (e *Engine) MitigateOrder(symbolID uint32, id uint64, newPrice, newQuantity uint64) ErrorCode {
+	return e.Do(symbolID, func(m *MarketManager) ErrorCode {
+		return m.MitigateOrder(id, newPrice, newQuantity)
+	})
+}
+
+// ReplaceOrder replaces an order with a new order of the same symbol
+func (e *Engine) ReplaceOrder(symbolID uint32, id uint64, newID uint64, newPrice, newQuantity uint64) ErrorCode {
+	return e.Do(symbolID, func(m *MarketManager) ErrorCode {
+		return m.ReplaceOrder(id, newID, newPrice, newQuantity)
+	})
+}
+
+// DeleteOrder deletes an order
+func (e *Engine) DeleteOrder(symbolID uint32, id uint64) ErrorCode {
+	return e.Do(symbolID, func(m *MarketManager) ErrorCode {
+		return m.DeleteOrder(id)
+	})
+}
+
+// ExecuteOrder executes an order at its own price
+func (e *Engine) ExecuteOrder(symbolID uint32, id uint64, quantity uint64) ErrorCode {
+	return e.Do(symbolID, func(m *MarketManager) ErrorCode {
+		return m.ExecuteOrder(id, quantity)
+	})
+}
+
+// UpdateSymbolConfig changes the trading rules of a live order book
+func (e *Engine) UpdateSymbolConfig(symbolID uint32, config SymbolConfig) ErrorCode {
+	return e.Do(symbolID, func(m *MarketManager) ErrorCode {
+		return m.UpdateSymbolConfig(symbolID, config)
+	})
+}
+
+// HaltTrading halts trading on the order book of a symbol
+func (e *Engine) HaltTrading(symbolID uint32) ErrorCode {
+	return e.Do(symbolID, func(m *MarketManager) ErrorCode {
+		return m.HaltTrading(symbolID)
+	})
+}
+
+// ResumeTrading resumes trading on the order book of a symbol
+func (e *Engine) ResumeTrading(symbolID uint32) ErrorCode {
+	return e.Do(symbolID, func(m *MarketManager) ErrorCode {
+		return m.ResumeTrading(symbolID)
+	})
+}
+
+// HaltMarket halts trading on all shards
+func (e *Engine) HaltMarket() ErrorCode {
+	return e.broadcast(func(m *MarketManager) ErrorCode {
+		m.HaltMarket()
+		return ErrorOK
+	})
+}
+
+// ResumeMarket lifts a market-wide halt on all shards
+func (e *Engine) ResumeMarket() ErrorCode {
+	return e.broadcast(func(m *MarketManager) ErrorCode {
+		m.ResumeMarket()
+		return ErrorOK
+	})
+}
+
+// Match performs matching on the order book of a symbol
+func (e *Engine) Match(symbolID uint32) ErrorCode {
+	return e.Do(symbolID, func(m *MarketManager) ErrorCode {
+		return m.Match(symbolID)
+	})
+}
+
+// SetAuthorizer sets the authorizer of Participant operations on all shards.
+// The authorizer is called from every shard goroutine and must be safe for
+// concurrent use.
+func (e *Engine) SetAuthorizer(authorizer Authorizer) ErrorCode {
+	return e.broadcast(func(m *MarketManager) ErrorCode {
+		m.SetAuthorizer(authorizer)
+		return ErrorOK
+	})
+}
+
+// SetExposureLimits sets the exposure limits of every participant on all
+// shards. Each shard counts the exposure of the symbols it owns.
+func (e *Engine) SetExposureLimits(limits ExposureLimits) ErrorCode {
+	return e.broadcast(func(m *MarketManager) ErrorCode {
+		m.SetExposureLimits(limits)
+		return ErrorOK
+	})
+}
+
+// SetParticipantExposureLimits overrides the exposure limits of a
+// participant on all shards
+func (e *Engine) SetParticipantExposureLimits(participantID uint32, limits ExposureLimits) ErrorCode {
+	return e.broadcast(func(m *MarketManager) ErrorCode {
+		m.SetParticipantExposureLimits(participantID, limits)
+		return ErrorOK
+	})
+}
+
+// OpenSession registers a cancel-on-disconnect session on all shards
+func (e *Engine) OpenSession(sessionID uint64, participantID uint32, timeout time.Duration) ErrorCode {
+	return e.broadcast(func(m *MarketManager) ErrorCode {
+		return m.OpenSession(sessionID, participantID, timeout)
+	})
+}
+
+// AddSessionOrder adds an order tagged to a session to the shard owning its
+// symbol
+func (e *Engine) AddSessionOrder(sessionID uint64, order Order) ErrorCode {
+	return e.Do(order.SymbolID, func(m *MarketManager) ErrorCode {
+		return m.AddSessionOrder(sessionID, order)
+	})
+}
+
+// HeartbeatSession keeps a session from expiring on all shards
+func (e *Engine) HeartbeatSession(sessionID uint64) ErrorCode {
+	return e.broadcast(func(m *MarketManager) ErrorCode {
+		return m.HeartbeatSession(sessionID)
+	})
+}
+
+// CloseSession deletes the open orders of a session on all shards
+func (e *Engine) CloseSession(sessionID uint64) ErrorCode {
+	return e.broadcast(func(m *MarketManager) ErrorCode {
+		return m.CloseSession(sessionID)
+	})
+}
+
+// ExpireSessions closes the sessions expired on any shard on all shards and
+// returns their IDs in ID order
+func (e *Engine) ExpireSessions() []uint64 {
+	var mu sync.Mutex
+	seen := make(map[uint64]bool)
+	var expired []uint64
+	e.broadcast(func(m *MarketManager) ErrorCode {
+		ids := m.expiredSessions()
+		mu.Lock()
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				expired = append(expired, id)
+			}
+		}
+		mu.Unlock()
+		return ErrorOK
+	})
+	if len(expired) == 0 {
+		return nil
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i] < expired[j] })
+	e.broadcast(func(m *MarketManager) ErrorCode {
+		for _, id := range expired {
+			m.CloseSession(id)
+		}
+		return ErrorOK
+	})
+	return expired
+}
+
+// EnableAmendmentHistory records order amendments on all shards
+func (e *Engine) EnableAmendmentHistory(depth int) ErrorCode {
+	return e.broadcast(func(m *MarketManager) ErrorCode {
+		m.EnableAmendmentHistory(depth)
+		return ErrorOK
+	})
+}
+
+// AmendmentHistory returns the recorded amendments of an order
+func (e *Engine) AmendmentHistory(symbolID uint32, id uint64) []Amendment {
+	var history []Amendment
+	e.Do(symbolID, func(m *MarketManager) ErrorCode {
+		history = m.AmendmentHistory(id)
+		return ErrorOK
+	})
+	return history
+}
+
+// GetOrder returns a copy of an order, or false if it does not exist
+func (e *Engine) GetOrder(symbolID uint32, id uint64) (Order, bool) {
+	var order Order
+	result := e.Do(symbolID, func(m *MarketManager) ErrorCode {
+		node := m.GetOrder(id)
+		if node == nil {
+			return ErrorOrderNotFound
+		}
+		order = node.Order
+		return ErrorOK
+	})
+	return order, result == ErrorOK
+}
+
+// BookSnapshot returns a read-only view of an order book from its shard, see
+// OrderBook.Snapshot
+func (e *Engine) BookSnapshot(symbolID uint32, levels int) (BookView, ErrorCode) {
+	var view BookView
+	result := e.Do(symbolID, func(m *MarketManager) ErrorCode {
+		ob := m.GetOrderBook(symbolID)
+		if ob == nil {
+			return ErrorOrderBookNotFound
+		}
+		view = ob.Snapshot(levels)
+		return ErrorOK
+	})
+	return view, result
+}
+
+// GetSymbolByName returns a copy of a symbol looked up by name on all shards,
+// or false if it does not exist. If several shards hold a symbol with the
+// name, the one with the lowest ID is returned.
+func (e *Engine) GetSymbolByName(name string) (Symbol, bool) {
+	var mu sync.Mutex
+	var symbol Symbol
+	found := false
+	e.broadcast(func(m *MarketManager) ErrorCode {
+		s := m.GetSymbolByName(name)
+		if s == nil {
+			return ErrorOK
+		}
+		mu.Lock()
+		if !found || s.ID < symbol.ID {
+			symbol, found = *s, true
+		}
+		mu.Unlock()
+		return ErrorOK
+	})
+	return symbol, found
+}
+
+// QueryOrders returns a page of the open orders passing a filter. A query for
+// one symbol runs on its shard only. Otherwise the orders of all shards are
+// merged by queue time, then symbol and arrival order.
+func (e *Engine) QueryOrders(filter OrderFilter) OrderPage {
+	if filter.SymbolID != 0 {
+		var page OrderPage
+		e.Do(filter.SymbolID, func(m *MarketManager) ErrorCode {
+			page = m.QueryOrders(filter)
+			return ErrorOK
+		})
+		return page
+	}
+
+	type match struct {
+		order     Order
+		timestamp int64
+		priority  uint64
+	}
+	var mu sync.Mutex
+	var matches []match
+	e.broadcast(func(m *MarketManager) ErrorCode {
+		nodes := m.matchOrders(filter)
+		mu.Lock()
+		for _, node := range nodes {
+			matches = append(matches, match{order: node.Order, timestamp: node.timestamp, priority: node.priority})
+		}
+		mu.Unlock()
+		return ErrorOK
+	})
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.timestamp != b.timestamp {
+			return a.timestamp < b.timestamp
+		}
+		if a.order.SymbolID != b.order.SymbolID {
+			return a.order.SymbolID < b.order.SymbolID
+		}
+		return a.priority < b.priority
+	})
+
+	start, end := filter.page(len(matches))
+	page := OrderPage{Orders: make([]Order, 0, end-start), Total: len(matches)}
+	for _, match := range matches[start:end] {
+		page.Orders = append(page.Orders, match.order)
+	}
+	return page
+}
+
+// OnAddSymbol is called when a symbol is added
+func (h *lockedHandler) OnAddSymbol(symbol Symbol) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnAddSymbol(symbol)
+}
+
+// OnDeleteSymbol is called when a symbol is deleted
+func (h *lockedHandler) OnDeleteSymbol(symbol Symbol) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnDeleteSymbol(symbol)
+}
+
+// OnAddOrderBook is called when an order book is added
+func (h *lockedHandler) OnAddOrderBook(orderBook *OrderBook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnAddOrderBook(orderBook)
+}
+
+// OnUpdateOrderBook is called when an order book is updated
+func (h *lockedHandler) OnUpdateOrderBook(orderBook *OrderBook, top bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnUpdateOrderBook(orderBook, top)
+}
+
+// OnDeleteOrderBook is called when an order book is deleted
+func (h *lockedHandler) OnDeleteOrderBook(orderBook *OrderBook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnDeleteOrderBook(orderBook)
+}
+
+// OnBookCrossed is called when an order book becomes crossed
+func (h *lockedHandler) OnBookCrossed(orderBook *OrderBook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnBookCrossed(orderBook)
+}
+
+// OnUpdateSymbolConfig is called when the trading rules of an order book change
+func (h *lockedHandler) OnUpdateSymbolConfig(orderBook *OrderBook, config SymbolConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnUpdateSymbolConfig(orderBook, config)
+}
+
+// OnAddLevel is called when a price level is added
+func (h *lockedHandler) OnAddLevel(orderBook *OrderBook, level Level, top bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnAddLevel(orderBook, level, top)
+}
+
+// OnUpdateLevel is called when a price level is updated
+func (h *lockedHandler) OnUpdateLevel(orderBook *OrderBook, level Level, top bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnUpdateLevel(orderBook, level, top)
+}
+
+// OnDeleteLevel is called when a price level is deleted
+func (h *lockedHandler) OnDeleteLevel(orderBook *OrderBook, level Level, top bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnDeleteLevel(orderBook, level, top)
+}
+
+// OnAddOrder is called when an order is added
+func (h *lockedHandler) OnAddOrder(order Order) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnAddOrder(order)
+}
+
+// OnUpdateOrder is called when an order is updated
+func (h *lockedHandler) OnUpdateOrder(order Order) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnUpdateOrder(order)
+}
+
+// OnDeleteOrder is called when an order is deleted
+func (h *lockedHandler) OnDeleteOrder(order Order) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnDeleteOrder(order)
+}
+
+// OnInvalidOrder is called for a resting order violating a new symbol configuration
+func (h *lockedHandler) OnInvalidOrder(order Order) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnInvalidOrder(order)
+}
+
+// OnRejectOrder is called when an order operation is rejected
+func (h *lockedHandler) OnRejectOrder(order Order, code ErrorCode) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnRejectOrder(order, code)
+}
+
+// OnExpireOrder is called for a resting order that outlived its time to live
+func (h *lockedHandler) OnExpireOrder(order Order) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnExpireOrder(order)
+}
+
+// OnExecuteOrder is called when an order is executed
+func (h *lockedHandler) OnExecuteOrder(order Order, price, quantity uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnExecuteOrder(order, price, quantity)
+}
+
+// OnTrade is called for every match between a buy and a sell order
+func (h *lockedHandler) OnTrade(trade Trade) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler.OnTrade(trade)
+}
@@ -0,0 +1,151 @@
+package matching
+
+import (
+	"sync"
+	"testing"
+)
+
+// engineTradeHandler counts trades per symbol; it relies on the engine
+// serializing handler calls
+type engineTradeHandler struct {
+	DefaultMarketHandler
+	trades map[uint32][]Trade
+}
+
+func (h *engineTradeHandler) OnTrade(trade Trade) {
+	h.trades[trade.SymbolID] = append(h.trades[trade.SymbolID], trade)
+}
+
+func TestEngine_Sharding(t *testing.T) {
+	engine := NewEngine(4)
+	defer engine.Close()
+
+	if engine.Shards() != 4 {
+		t.Errorf("Expected 4 shards, got %d", engine.Shards())
+	}
+	if engine.ShardOf(1) == engine.ShardOf(2) {
+		t.Error("Expected consecutive symbols on different shards")
+	}
+	if engine.ShardOf(1) != engine.ShardOf(5) {
+		t.Error("Expected symbols 1 and 5 on the same shard")
+	}
+
+	symbol := NewSymbol(1, "AAPL")
+	if err := engine.AddSymbol(symbol); err != ErrorOK {
+		t.Fatalf("AddSymbol failed: %s", err)
+	}
+	if err := engine.AddSymbol(symbol); err != ErrorSymbolDuplicate {
+		t.Errorf("Expected ErrorSymbolDuplicate, got %s", err)
+	}
+	engine.AddOrderBook(symbol)
+
+	if err := engine.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 10)); err != ErrorOK {
+		t.Fatalf("AddOrder failed: %s", err)
+	}
+	if err := engine.AddOrder(*NewLimitOrder(2, 2, OrderSideBuy, 10000, 10)); err != ErrorOrderBookNotFound {
+		t.Errorf("Expected ErrorOrderBookNotFound, got %s", err)
+	}
+
+	order, ok := engine.GetOrder(1, 1)
+	if !ok || order.LeavesQuantity != 10 {
+		t.Errorf("Expected order 1 with 10 leaves, got %v %v", ok, order)
+	}
+	if err := engine.ReduceOrder(1, 1, 4); err != ErrorOK {
+		t.Errorf("ReduceOrder failed: %s", err)
+	}
+	if order, _ := engine.GetOrder(1, 1); order.LeavesQuantity != 6 {
+		t.Errorf("Expected 6 leaves after reduce, got %d", order.LeavesQuantity)
+	}
+	if err := engine.DeleteOrder(1, 1); err != ErrorOK {
+		t.Errorf("DeleteOrder failed: %s", err)
+	}
+	if _, ok := engine.GetOrder(1, 1); ok {
+		t.Error("Expected order 1 to be deleted")
+	}
+}
+
+func TestEngine_GetSymbolByName(t *testing.T) {
+	engine := NewEngine(4)
+	defer engine.Close()
+
+	engine.AddSymbol(NewSymbol(1, "AAPL"))
+	engine.AddSymbol(NewSymbol(2, "MSFT"))
+	engine.AddSymbol(NewSymbol(7, "MSFT"))
+
+	if symbol, ok := engine.GetSymbolByName("AAPL"); !ok || symbol.ID != 1 {
+		t.Errorf("Expected symbol 1, got %v %v", ok, symbol)
+	}
+	if symbol, ok := engine.GetSymbolByName("MSFT"); !ok || symbol.ID != 2 {
+		t.Errorf("Expected symbol 2, got %v %v", ok, symbol)
+	}
+	if _, ok := engine.GetSymbolByName("GOOG"); ok {
+		t.Error("Expected GOOG not to exist")
+	}
+}
+
+func TestEngine_ConcurrentSymbols(t *testing.T) {
+	const symbols = 16
+	const pairs = 200
+
+	handler := &engineTradeHandler{trades: make(map[uint32][]Trade)}
+	engine := NewEngineWithHandler(4, handler)
+	engine.EnableMatching()
+
+	for id := uint32(1); id <= symbols; id++ {
+		symbol := NewSymbol(id, "SYM")
+		engine.AddSymbol(symbol)
+		engine.AddOrderBook(symbol)
+	}
+
+	var wg sync.WaitGroup
+	for id := uint32(1); id <= symbols; id++ {
+		wg.Add(1)
+		go func(id uint32) {
+			defer wg.Done()
+			base := uint64(id) * 1000000
+			for i := uint64(0); i < pairs; i++ {
+				price := 10000 + i%5
+				engine.AddOrder(*NewLimitOrder(base+2*i+1, id, OrderSideSell, price, 10))
+				engine.AddOrder(*NewLimitOrder(base+2*i+2, id, OrderSideBuy, price, 10))
+			}
+		}(id)
+	}
+	wg.Wait()
+	engine.Close()
+
+	for id := uint32(1); id <= symbols; id++ {
+		trades := handler.trades[id]
+		if len(trades) != pairs {
+			t.Fatalf("Symbol %d: expected %d trades, got %d", id, pairs, len(trades))
+		}
+		// Per-book determinism: each buy crosses the sell submitted before it
+		base := uint64(id) * 1000000
+		for i, trade := range trades {
+			if trade.SellOrderID != base+2*uint64(i)+1 || trade.BuyOrderID != base+2*uint64(i)+2 {
+				t.Errorf("Symbol %d trade %d: unexpected orders %d/%d", id, i, trade.BuyOrderID, trade.SellOrderID)
+				break
+			}
+		}
+	}
+}
+
+func TestEngine_Close(t *testing.T) {
+	engine := NewEngine(2)
+
+	var executed int
+	for i := 0; i < 100; i++ {
+		engine.Post(1, func(m *MarketManager) { executed++ })
+	}
+	engine.Close()
+	engine.Close()
+
+	if executed != 100 {
+		t.Errorf("Expected queued commands to run before close, got %d", executed)
+	}
+	if err := engine.AddSymbol(NewSymbol(1, "AAPL")); err != ErrorEngineClosed {
+		t.Errorf("Expected ErrorEngineClosed, got %s", err)
+	}
+	if err := engine.EnableMatching(); err != ErrorEngineClosed {
+		t.Errorf("Expected ErrorEngineClosed, got %s", err)
+	}
+}
@@ -30,6 +30,31 @@ const (
 	ErrorOrderParameterInvalid
 	// ErrorOrderQuantityInvalid indicates the order quantity is invalid
 	ErrorOrderQuantityInvalid
+	// ErrorEngineClosed indicates the engine has been closed
+	ErrorEngineClosed
+	// ErrorSymbolConfigInvalid indicates the symbol configuration is invalid
+	ErrorSymbolConfigInvalid
+	// ErrorMarketClosed indicates the order book is outside its trading schedule
+	ErrorMarketClosed
+	// ErrorNotAuthorized indicates the participant may not perform the operation
+	ErrorNotAuthorized
+	// ErrorOpenOrderLimit indicates the participant has too many open orders
+	ErrorOpenOrderLimit
+	// ErrorNotionalLimit indicates the order exceeds the participant's open notional limit
+	ErrorNotionalLimit
+	// ErrorSessionInvalid indicates the session ID is invalid
+	ErrorSessionInvalid
+	// ErrorSessionDuplicate indicates the session is already open
+	ErrorSessionDuplicate
+	// ErrorSessionNotFound indicates the session was not found
+	ErrorSessionNotFound
+	// ErrorSpreadInvalid indicates the legs of a spread are invalid
+	ErrorSpreadInvalid
+	// ErrorSymbolInUse indicates the symbol is the leg of a spread
+	ErrorSymbolInUse
+	// ErrorTradingHalted indicates trading is halted on the order book or
+	// market-wide
+	ErrorTradingHalted
 )
 
 // Error messages for matching engine errors
@@ -44,6 +69,18 @@ var (
 	ErrOrderTypeInvalid      = errors.New("order type invalid")
 	ErrOrderParameterInvalid = errors.New("order parameter invalid")
 	ErrOrderQuantityInvalid  = errors.New("order quantity invalid")
+	ErrEngineClosed          = errors.New("engine closed")
+	ErrSymbolConfigInvalid   = errors.New("symbol config invalid")
+	ErrMarketClosed          = errors.New("market closed")
+	ErrNotAuthorized         = errors.New("not authorized")
+	ErrOpenOrderLimit        = errors.New("open order limit exceeded")
+	ErrNotionalLimit         = errors.New("open notional limit exceeded")
+	ErrSessionInvalid        = errors.New("session ID invalid")
+	ErrSessionDuplicate      = errors.New("session duplicate")
+	ErrSessionNotFound       = errors.New("session not found")
+	ErrSpreadInvalid         = errors.New("spread invalid")
+	ErrSymbolInUse           = errors.New("symbol in use")
+	ErrTradingHalted         = errors.New("trading halted")
 )
 
 // String returns the string representation of an ErrorCode
@@ -71,6 +108,30 @@ func (e ErrorCode) String() string {
 		return "ORDER_PARAMETER_INVALID"
 	case ErrorOrderQuantityInvalid:
 		return "ORDER_QUANTITY_INVALID"
+	case ErrorEngineClosed:
+		return "ENGINE_CLOSED"
+	case ErrorSymbolConfigInvalid:
+		return "SYMBOL_CONFIG_INVALID"
+	case ErrorMarketClosed:
+		return "MARKET_CLOSED"
+	case ErrorNotAuthorized:
+		return "NOT_AUTHORIZED"
+	case ErrorOpenOrderLimit:
+		return "OPEN_ORDER_LIMIT"
+	case ErrorNotionalLimit:
+		return "NOTIONAL_LIMIT"
+	case ErrorSessionInvalid:
+		return "SESSION_INVALID"
+	case ErrorSessionDuplicate:
+		return "SESSION_DUPLICATE"
+	case ErrorSessionNotFound:
+		return "SESSION_NOT_FOUND"
+	case ErrorSpreadInvalid:
+		return "SPREAD_INVALID"
+	case ErrorSymbolInUse:
+		return "SYMBOL_IN_USE"
+	case ErrorTradingHalted:
+		return "TRADING_HALTED"
 	default:
 		return "UNKNOWN"
 	}
@@ -101,6 +162,30 @@ func (e ErrorCode) Error() error {
 		return ErrOrderParameterInvalid
 	case ErrorOrderQuantityInvalid:
 		return ErrOrderQuantityInvalid
+	case ErrorEngineClosed:
+		return ErrEngineClosed
+	case ErrorSymbolConfigInvalid:
+		return ErrSymbolConfigInvalid
+	case ErrorMarketClosed:
+		return ErrMarketClosed
+	case ErrorNotAuthorized:
+		return ErrNotAuthorized
+	case ErrorOpenOrderLimit:
+		return ErrOpenOrderLimit
+	case ErrorNotionalLimit:
+		return ErrNotionalLimit
+	case ErrorSessionInvalid:
+		return ErrSessionInvalid
+	case ErrorSessionDuplicate:
+		return ErrSessionDuplicate
+	case ErrorSessionNotFound:
+		return ErrSessionNotFound
+	case ErrorSpreadInvalid:
+		return ErrSpreadInvalid
+	case ErrorSymbolInUse:
+		return ErrSymbolInUse
+	case ErrorTradingHalted:
+		return ErrTradingHalted
 	default:
 		return errors.New("unknown error")
 	}
@@ -0,0 +1,122 @@
+package matching
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ttl returns the time to live of an order in the order book, 0 for none
+func (c SymbolConfig) ttl(order *Order) time.Duration {
+	if order.Quote && c.QuoteTTL > 0 {
+		return c.QuoteTTL
+	}
+	return c.OrderTTL
+}
+
+// ExpireOrders deletes the open orders that held their queue position for
+// longer than the OrderTTL or QuoteTTL of their order book, by the market
+// manager clock, with ExpireOrder, and returns their IDs in ID order. Books
+// with an ExpirySweep also purge their expired orders when the next order is
+// added after the interval; deployments without one call ExpireOrders
+// periodically.
+//
+// An order modified or replaced into a new queue position starts a new time
+// to live, and so does an order restored after a restart. Expiries are not
+// journaled: with persistence, purge with persistence.Manager.ExpireOrders
+// rather than an ExpirySweep.
+func (m *MarketManager) ExpireOrders() []uint64 {
+	defer m.operation()()
+	return m.expireOrders(m.expiredOrders(nil))
+}
+
+// ExpiredOrders returns the IDs of the open orders that outlived the time to
+// live of their order book in ID order, without deleting them
+func (m *MarketManager) ExpiredOrders() []uint64 {
+	return m.expiredOrders(nil)
+}
+
+// ExpireOrder reports an order with OnExpireOrder and deletes it as
+// DeleteOrder does, whatever its age
+func (m *MarketManager) ExpireOrder(id uint64) ErrorCode {
+	defer m.operation()()
+	if orderNode, exists := m.orders[id]; exists {
+		m.handler.OnExpireOrder(orderNode.Order)
+	}
+	return m.DeleteOrder(id)
+}
+
+// sweepExpired purges the expired orders of an order book if its sweep
+// interval elapsed
+func (m *MarketManager) sweepExpired(ob *OrderBook) {
+	config := ob.config
+	if config.ExpirySweep == 0 || config.OrderTTL == 0 && config.QuoteTTL == 0 {
+		return
+	}
+	now := m.now()
+	if now.Before(ob.nextSweep) {
+		return
+	}
+	ob.nextSweep = now.Add(config.ExpirySweep)
+	m.expireOrders(m.expiredOrders(ob))
+}
+
+// expiredOrders returns the IDs of the expired orders of an order book, or of
+// all order books for a nil one, in ID order
+func (m *MarketManager) expiredOrders(ob *OrderBook) []uint64 {
+	if ob == nil && !m.hasTTL() {
+		return nil
+	}
+	now := m.now().UnixNano()
+	var expired []uint64
+	for id, order := range m.orders {
+		if ob != nil && order.SymbolID != ob.symbol.ID {
+			continue
+		}
+		ttl := m.orderBooks[order.SymbolID].config.ttl(&order.Order)
+		if ttl > 0 && now-order.timestamp >= int64(ttl) {
+			expired = append(expired, id)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i] < expired[j] })
+	return expired
+}
+
+// hasTTL returns true if any order book has a time to live, so that periodic
+// purges of engines without one skip the scan of the orders
+func (m *MarketManager) hasTTL() bool {
+	for _, ob := range m.orderBooks {
+		if ob.config.OrderTTL > 0 || ob.config.QuoteTTL > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// expireOrders expires orders, skipping those handlers deleted meanwhile, and
+// returns the IDs of the expired ones
+func (m *MarketManager) expireOrders(ids []uint64) []uint64 {
+	expired := ids[:0]
+	for _, id := range ids {
+		if _, exists := m.orders[id]; exists && m.ExpireOrder(id) == ErrorOK {
+			expired = append(expired, id)
+		}
+	}
+	return expired
+}
+
+// ExpireOrders deletes the expired open orders on all shards and returns
+// their IDs in ID order
+func (e *Engine) ExpireOrders() []uint64 {
+	var mu sync.Mutex
+	var expired []uint64
+	e.broadcast(func(m *MarketManager) ErrorCode {
+		ids := m.ExpireOrders()
+		mu.Lock()
+		expired = append(expired, ids...)
+		mu.Unlock()
+		return ErrorOK
+	})
+	sort.Slice(expired, func(i, j int) bool { return expired[i] < expired[j] })
+	return expired
+}
@@ -0,0 +1,119 @@
+package matching
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// expiryHandler records expiries and deletions in the order reported
+type expiryHandler struct {
+	DefaultMarketHandler
+	events []string
+	trades int
+}
+
+func (h *expiryHandler) OnExpireOrder(order Order) {
+	h.events = append(h.events, fmt.Sprintf("expire %d", order.ID))
+}
+
+func (h *expiryHandler) OnDeleteOrder(order Order) {
+	h.events = append(h.events, fmt.Sprintf("delete %d", order.ID))
+}
+
+func (h *expiryHandler) OnTrade(trade Trade) {
+	h.trades++
+}
+
+func TestMarketManager_ExpireOrders(t *testing.T) {
+	start := time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC)
+	now := start
+	handler := &expiryHandler{}
+	manager := newConfigManager(handler)
+	manager.SetClock(func() time.Time { return now })
+	if result := manager.UpdateSymbolConfig(1, SymbolConfig{OrderTTL: 10 * time.Second, QuoteTTL: time.Second}); result != ErrorOK {
+		t.Fatalf("Expected ErrorOK, got %v", result)
+	}
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 9900, 10))
+	quote := NewLimitOrder(2, 1, OrderSideBuy, 9800, 10)
+	quote.Quote = true
+	manager.AddOrder(*quote)
+
+	now = start.Add(999 * time.Millisecond)
+	if expired := manager.ExpireOrders(); len(expired) != 0 {
+		t.Errorf("Expected no expiry yet, got %v", expired)
+	}
+	now = start.Add(time.Second)
+	if expired := manager.ExpiredOrders(); len(expired) != 1 || expired[0] != 2 {
+		t.Errorf("Expected the quote stale, got %v", expired)
+	}
+	if expired := manager.ExpireOrders(); len(expired) != 1 || expired[0] != 2 {
+		t.Errorf("Expected the quote expired, got %v", expired)
+	}
+	if len(handler.events) != 2 || handler.events[0] != "expire 2" || handler.events[1] != "delete 2" {
+		t.Errorf("Expected the expiry reported before the deletion, got %v", handler.events)
+	}
+
+	now = start.Add(10 * time.Second)
+	if expired := manager.ExpireOrders(); len(expired) != 1 || expired[0] != 1 || manager.GetOrder(1) != nil {
+		t.Errorf("Expected order 1 expired, got %v", expired)
+	}
+
+	if result := manager.UpdateSymbolConfig(1, SymbolConfig{OrderTTL: -time.Second}); result != ErrorSymbolConfigInvalid {
+		t.Errorf("Expected ErrorSymbolConfigInvalid, got %v", result)
+	}
+}
+
+func TestMarketManager_ExpirySweep(t *testing.T) {
+	start := time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC)
+	now := start
+	handler := &expiryHandler{}
+	manager := newConfigManager(handler)
+	manager.EnableMatching()
+	manager.SetClock(func() time.Time { return now })
+	manager.UpdateSymbolConfig(1, SymbolConfig{QuoteTTL: time.Second, ExpirySweep: 5 * time.Second})
+
+	quote := NewLimitOrder(1, 1, OrderSideSell, 10000, 10)
+	quote.Quote = true
+	manager.AddOrder(*quote)
+
+	// The stale quote is purged before the next order can match it
+	now = start.Add(5 * time.Second)
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideBuy, 10000, 10))
+	if handler.trades != 0 || manager.GetOrder(1) != nil || manager.GetOrder(2) == nil {
+		t.Errorf("Expected the quote purged unmatched, got %d trades", handler.trades)
+	}
+
+	// Until the next sweep a stale quote still trades
+	quote = NewLimitOrder(3, 1, OrderSideSell, 10100, 10)
+	quote.Quote = true
+	manager.AddOrder(*quote)
+	now = start.Add(7 * time.Second)
+	manager.AddOrder(*NewLimitOrder(4, 1, OrderSideBuy, 10100, 10))
+	if handler.trades != 1 {
+		t.Errorf("Expected the quote matched before the next sweep, got %d trades", handler.trades)
+	}
+}
+
+func TestMarketManager_ExpirySweepSymbolZero(t *testing.T) {
+	start := time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC)
+	now := start
+	manager := newConfigManager(&expiryHandler{})
+	manager.SetClock(func() time.Time { return now })
+	symbol := NewSymbol(0, "ZERO")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+	manager.UpdateSymbolConfig(0, SymbolConfig{OrderTTL: time.Second, ExpirySweep: time.Second})
+	manager.UpdateSymbolConfig(1, SymbolConfig{OrderTTL: time.Second})
+
+	manager.AddOrder(*NewLimitOrder(1, 0, OrderSideBuy, 9900, 10))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideBuy, 9900, 10))
+
+	// The sweep of book 0 purges its own orders only
+	now = start.Add(time.Second)
+	manager.AddOrder(*NewLimitOrder(3, 0, OrderSideBuy, 9800, 10))
+	if manager.GetOrder(1) != nil || manager.GetOrder(2) == nil {
+		t.Errorf("Expected only order 1 purged, got orders 1 %v and 2 %v", manager.GetOrder(1) != nil, manager.GetOrder(2) != nil)
+	}
+}
@@ -0,0 +1,175 @@
+package matching
+
+import (
+	"math"
+	"math/bits"
+)
+
+// ExposureLimits caps the open orders of a participant in one symbol. Zero
+// leaves a cap disabled.
+type ExposureLimits struct {
+	// MaxOpenOrders is the maximum number of open orders
+	MaxOpenOrders uint64
+	// MaxOpenNotional is the maximum sum of price times leaves quantity.
+	// Stop orders count at their limit price, or their stop price if they
+	// have none; market orders do not count.
+	MaxOpenNotional uint64
+}
+
+// Exposure is the open order count and notional of a participant in a symbol
+type Exposure struct {
+	OpenOrders   uint64
+	OpenNotional uint64
+}
+
+// exposureKey identifies the exposure of a participant in a symbol
+type exposureKey struct {
+	participantID uint32
+	symbolID      uint32
+}
+
+// exposures tracks open exposure and its limits
+type exposures struct {
+	limits       ExposureLimits
+	participants map[uint32]ExposureLimits
+	open         map[exposureKey]*Exposure
+}
+
+// SetExposureLimits caps the open orders of every participant in every
+// symbol. Orders that would exceed a cap are rejected by AddOrder with
+// ErrorOpenOrderLimit or ErrorNotionalLimit, as are amendments that raise
+// the notional above it. The caps hold for orders entered directly on the
+// MarketManager as well as through a Participant.
+func (m *MarketManager) SetExposureLimits(limits ExposureLimits) {
+	m.trackExposure().limits = limits
+}
+
+// SetParticipantExposureLimits overrides the exposure limits of a participant
+func (m *MarketManager) SetParticipantExposureLimits(participantID uint32, limits ExposureLimits) {
+	m.trackExposure().participants[participantID] = limits
+}
+
+// Exposure returns the open exposure of a participant in a symbol. It is
+// only tracked once exposure limits are set.
+func (m *MarketManager) Exposure(participantID uint32, symbolID uint32) Exposure {
+	if m.exposures == nil {
+		return Exposure{}
+	}
+	if e := m.exposures.open[exposureKey{participantID, symbolID}]; e != nil {
+		return *e
+	}
+	return Exposure{}
+}
+
+// trackExposure starts tracking exposure, counting the orders already open
+func (m *MarketManager) trackExposure() *exposures {
+	if m.exposures == nil {
+		m.exposures = &exposures{
+			participants: make(map[uint32]ExposureLimits),
+			open:         make(map[exposureKey]*Exposure),
+		}
+		for _, order := range m.orders {
+			m.expose(order)
+		}
+	}
+	return m.exposures
+}
+
+// exposureLimits returns the limits of a participant
+func (x *exposures) exposureLimits(participantID uint32) ExposureLimits {
+	if limits, ok := x.participants[participantID]; ok {
+		return limits
+	}
+	return x.limits
+}
+
+// notional returns the open notional of an order, saturating on overflow
+func notional(order *Order) uint64 {
+	price := order.Price
+	if price == 0 {
+		price = order.StopPrice
+	}
+	hi, lo := bits.Mul64(price, order.LeavesQuantity)
+	if hi != 0 {
+		return math.MaxUint64
+	}
+	return lo
+}
+
+// checkExposure checks that order would not exceed the exposure limits of
+// its participant. An amended order replaces the exposure of the resting
+// order it amends, nil for new orders.
+func (m *MarketManager) checkExposure(order *Order, amended *OrderNode) ErrorCode {
+	if m.exposures == nil {
+		return ErrorOK
+	}
+	limits := m.exposures.exposureLimits(order.ParticipantID)
+	var open Exposure
+	if e := m.exposures.open[exposureKey{order.ParticipantID, order.SymbolID}]; e != nil {
+		open = *e
+	}
+	if amended != nil {
+		open.OpenOrders--
+		open.OpenNotional -= amended.exposed
+	}
+
+	if limits.MaxOpenOrders != 0 && open.OpenOrders+1 > limits.MaxOpenOrders {
+		return ErrorOpenOrderLimit
+	}
+	if limits.MaxOpenNotional != 0 {
+		total, carry := bits.Add64(open.OpenNotional, notional(order), 0)
+		if carry != 0 || total > limits.MaxOpenNotional {
+			return ErrorNotionalLimit
+		}
+	}
+	return ErrorOK
+}
+
+// expose counts an order entering the book
+func (m *MarketManager) expose(order *OrderNode) {
+	if m.exposures == nil {
+		return
+	}
+	key := exposureKey{order.ParticipantID, order.SymbolID}
+	e := m.exposures.open[key]
+	if e == nil {
+		e = &Exposure{}
+		m.exposures.open[key] = e
+	}
+	order.exposed = notional(&order.Order)
+	e.OpenOrders++
+	e.OpenNotional += order.exposed
+}
+
+// unexpose stops counting an order leaving the book
+func (m *MarketManager) unexpose(order *OrderNode) {
+	if m.exposures == nil {
+		return
+	}
+	key := exposureKey{order.ParticipantID, order.SymbolID}
+	e := m.exposures.open[key]
+	if e == nil {
+		return
+	}
+	e.OpenOrders--
+	e.OpenNotional -= order.exposed
+	order.exposed = 0
+	if e.OpenOrders == 0 {
+		delete(m.exposures.open, key)
+	}
+}
+
+// reexpose recounts the notional of an order whose price or leaves quantity
+// changed
+func (m *MarketManager) reexpose(order *OrderNode) {
+	if m.exposures == nil {
+		return
+	}
+	e := m.exposures.open[exposureKey{order.ParticipantID, order.SymbolID}]
+	if e == nil {
+		return
+	}
+	e.OpenNotional -= order.exposed
+	order.exposed = notional(&order.Order)
+	e.OpenNotional += order.exposed
+}
@@ -0,0 +1,88 @@
+package matching
+
+import "testing"
+
+func TestExposureLimits_OpenOrders(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.SetExposureLimits(ExposureLimits{MaxOpenOrders: 2})
+
+	for id := uint64(1); id <= 2; id++ {
+		if err := manager.AddOrder(participantOrder(id, 1, OrderSideBuy, 10000, 100, 7)); err != ErrorOK {
+			t.Fatalf("AddOrder %d failed: %s", id, err)
+		}
+	}
+	if err := manager.AddOrder(participantOrder(3, 1, OrderSideBuy, 10000, 100, 7)); err != ErrorOpenOrderLimit {
+		t.Errorf("Expected OPEN_ORDER_LIMIT, got %s", err)
+	}
+	if manager.GetOrder(3) != nil {
+		t.Error("Expected rejected order not to be added")
+	}
+	if err := manager.AddOrder(participantOrder(4, 1, OrderSideBuy, 10000, 100, 8)); err != ErrorOK {
+		t.Errorf("Expected other participant unaffected, got %s", err)
+	}
+
+	// Replacing an order does not open a new one
+	if err := manager.ReplaceOrder(1, 5, 9900, 100); err != ErrorOK {
+		t.Errorf("ReplaceOrder failed: %s", err)
+	}
+	if err := manager.DeleteOrder(2); err != ErrorOK {
+		t.Fatalf("DeleteOrder failed: %s", err)
+	}
+	if err := manager.AddOrder(participantOrder(6, 1, OrderSideBuy, 10000, 100, 7)); err != ErrorOK {
+		t.Errorf("Expected order accepted after a cancel, got %s", err)
+	}
+
+	// Filled orders no longer count
+	manager.EnableMatching()
+	if err := manager.AddOrder(participantOrder(7, 1, OrderSideSell, 9900, 300, 8)); err != ErrorOK {
+		t.Fatalf("AddOrder failed: %s", err)
+	}
+	if exposure := manager.Exposure(7, 1); exposure != (Exposure{}) {
+		t.Errorf("Expected no open exposure after fills, got %+v", exposure)
+	}
+}
+
+func TestExposureLimits_Notional(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	if err := manager.AddOrder(participantOrder(1, 1, OrderSideBuy, 10000, 100, 7)); err != ErrorOK {
+		t.Fatalf("AddOrder failed: %s", err)
+	}
+
+	// Orders already resting count once limits are set
+	manager.SetExposureLimits(ExposureLimits{MaxOpenNotional: 2_000_000})
+	manager.SetParticipantExposureLimits(8, ExposureLimits{})
+	if exposure := manager.Exposure(7, 1); exposure != (Exposure{OpenOrders: 1, OpenNotional: 1_000_000}) {
+		t.Errorf("Expected existing order counted, got %+v", exposure)
+	}
+
+	if err := manager.AddOrder(participantOrder(2, 1, OrderSideSell, 11000, 100, 7)); err != ErrorNotionalLimit {
+		t.Errorf("Expected NOTIONAL_LIMIT, got %s", err)
+	}
+	if err := manager.AddOrder(participantOrder(2, 1, OrderSideSell, 10000, 100, 7)); err != ErrorOK {
+		t.Errorf("Expected order up to the limit accepted, got %s", err)
+	}
+	if err := manager.ModifyOrder(1, 10000, 150); err != ErrorNotionalLimit {
+		t.Errorf("Expected NOTIONAL_LIMIT for modify, got %s", err)
+	}
+	if err := manager.MitigateOrder(1, 9000, 110); err != ErrorOK {
+		t.Errorf("Expected modify within the limit accepted, got %s", err)
+	}
+	if err := manager.ReduceOrder(2, 50); err != ErrorOK {
+		t.Fatalf("ReduceOrder failed: %s", err)
+	}
+	if exposure := manager.Exposure(7, 1); exposure != (Exposure{OpenOrders: 2, OpenNotional: 1_490_000}) {
+		t.Errorf("Expected exposure after amendments, got %+v", exposure)
+	}
+
+	// Participant overrides replace the default limits
+	if err := manager.AddOrder(participantOrder(3, 1, OrderSideBuy, 10000, 1000, 8)); err != ErrorOK {
+		t.Errorf("Expected unlimited participant accepted, got %s", err)
+	}
+
+	if err := manager.ExecuteOrder(1, 60); err != ErrorOK {
+		t.Fatalf("ExecuteOrder failed: %s", err)
+	}
+	if exposure := manager.Exposure(7, 1); exposure != (Exposure{OpenOrders: 2, OpenNotional: 950_000}) {
+		t.Errorf("Expected exposure after execution, got %+v", exposure)
+	}
+}
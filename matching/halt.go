@@ -0,0 +1,52 @@
+package matching
+
+// HaltTrading halts trading on an order book: new orders, and modifications
+// and replacements re-checked against the trading rules, are rejected with
+// ErrorTradingHalted until ResumeTrading. Resting orders stay in the book
+// and can still be reduced or canceled.
+func (m *MarketManager) HaltTrading(symbolID uint32) ErrorCode {
+	defer m.operation()()
+	ob, exists := m.orderBooks[symbolID]
+	if !exists {
+		return ErrorOrderBookNotFound
+	}
+	ob.halted = true
+	return ErrorOK
+}
+
+// ResumeTrading resumes trading on an order book halted by HaltTrading. A
+// market-wide halt still applies.
+func (m *MarketManager) ResumeTrading(symbolID uint32) ErrorCode {
+	defer m.operation()()
+	ob, exists := m.orderBooks[symbolID]
+	if !exists {
+		return ErrorOrderBookNotFound
+	}
+	ob.halted = false
+	return ErrorOK
+}
+
+// HaltMarket halts trading on every order book, as HaltTrading, until
+// ResumeMarket
+func (m *MarketManager) HaltMarket() {
+	defer m.operation()()
+	m.halted = true
+}
+
+// ResumeMarket lifts a market-wide halt. Order books halted by HaltTrading
+// stay halted.
+func (m *MarketManager) ResumeMarket() {
+	defer m.operation()()
+	m.halted = false
+}
+
+// IsMarketHalted returns true while trading is halted market-wide
+func (m *MarketManager) IsMarketHalted() bool {
+	return m.halted
+}
+
+// IsHalted returns true while trading is halted on the order book, by
+// HaltTrading or market-wide
+func (ob *OrderBook) IsHalted() bool {
+	return ob.halted || ob.manager != nil && ob.manager.halted
+}
@@ -0,0 +1,61 @@
+package matching
+
+import "testing"
+
+func TestMarketManager_HaltTrading(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.EnableMatching()
+	ob := manager.GetOrderBook(1)
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 100))
+
+	if err := manager.HaltTrading(1); err != ErrorOK {
+		t.Fatalf("HaltTrading failed: %s", err)
+	}
+	if !ob.IsHalted() {
+		t.Error("Expected the book to be halted")
+	}
+	if err := manager.AddOrder(*NewLimitOrder(2, 1, OrderSideSell, 10000, 100)); err != ErrorTradingHalted {
+		t.Errorf("Expected ErrorTradingHalted, got %s", err)
+	}
+	if err := manager.ModifyOrder(1, 10100, 100); err != ErrorTradingHalted {
+		t.Errorf("Expected a modification to be rejected, got %s", err)
+	}
+	// Resting orders can still be reduced and canceled
+	if err := manager.ReduceOrder(1, 50); err != ErrorOK {
+		t.Errorf("Expected a reduction to be accepted, got %s", err)
+	}
+
+	if err := manager.ResumeTrading(1); err != ErrorOK || ob.IsHalted() {
+		t.Fatalf("Expected trading to resume, got %s", err)
+	}
+	if err := manager.AddOrder(*NewLimitOrder(2, 1, OrderSideSell, 10000, 20)); err != ErrorOK {
+		t.Errorf("Expected the order to be accepted, got %s", err)
+	}
+	if err := manager.HaltTrading(2); err != ErrorOrderBookNotFound {
+		t.Errorf("Expected ErrorOrderBookNotFound, got %s", err)
+	}
+}
+
+func TestMarketManager_HaltMarket(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	ob := manager.GetOrderBook(1)
+
+	manager.HaltMarket()
+	manager.HaltTrading(1)
+	if !manager.IsMarketHalted() || !ob.IsHalted() {
+		t.Fatal("Expected the market to be halted")
+	}
+	if err := manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 100)); err != ErrorTradingHalted {
+		t.Errorf("Expected ErrorTradingHalted, got %s", err)
+	}
+
+	// The halt of the book outlives the market-wide halt
+	manager.ResumeMarket()
+	if manager.IsMarketHalted() || !ob.IsHalted() {
+		t.Error("Expected the book to stay halted")
+	}
+	manager.ResumeTrading(1)
+	if err := manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 100)); err != ErrorOK {
+		t.Errorf("Expected the order to be accepted, got %s", err)
+	}
+}
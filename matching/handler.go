@@ -6,6 +6,34 @@ package matching
 // - Add/Remove/Modify orders
 // - Order executions
 // - Order book updates
+//
+// Event ordering contract: callbacks are made synchronously on the goroutine
+// of the operation, and a given sequence of operations always produces the
+// same callbacks in the same order. Persistence replication and market data
+// replay depend on it, so the order is part of the API and is enforced by a
+// golden event sequence test (matching/testdata/events.golden):
+//   - a level event (OnAddLevel, OnUpdateLevel, OnDeleteLevel) is always
+//     followed by OnUpdateOrderBook, then OnBookCrossed if the book crossed
+//     (with SetBookConflation, OnUpdateOrderBook is instead called once per
+//     updated book after the other events of the operation or matching cycle)
+//   - AddOrder: OnAddOrder, the level event, then the matches
+//   - a match: the execution of the buy order, then of the sell order, then
+//     OnTrade; an execution is OnExecuteOrder followed by OnUpdateOrder and
+//     the level update, or by the level deletion and OnDeleteOrder when the
+//     order is filled
+//   - a match in a spread book: the spread match as above, then one OnTrade
+//     per leg in leg order
+//   - ReduceOrder: OnUpdateOrder, then the level event
+//   - DeleteOrder: the level deletion, then OnDeleteOrder
+//   - ExpireOrder: OnExpireOrder, then the order deleted as by DeleteOrder
+//   - ModifyOrder, MitigateOrder: the level deletion, OnUpdateOrder, the
+//     level addition, then the matches
+//   - ReplaceOrder: the old order deleted as by DeleteOrder, then the new
+//     order added as by AddOrder
+//   - UpdateSymbolConfig, ResetSession, DeleteOrderBook: orders are reported
+//     and cancelled in order ID order
+//   - a rejected AddOrder, ReduceOrder, ModifyOrder, MitigateOrder,
+//     ReplaceOrder, DeleteOrder or ExecuteOrder: OnRejectOrder only
 type MarketHandler interface {
 	// Symbol handlers
 	OnAddSymbol(symbol Symbol)
@@ -15,6 +43,8 @@ type MarketHandler interface {
 	OnAddOrderBook(orderBook *OrderBook)
 	OnUpdateOrderBook(orderBook *OrderBook, top bool)
 	OnDeleteOrderBook(orderBook *OrderBook)
+	OnBookCrossed(orderBook *OrderBook)
+	OnUpdateSymbolConfig(orderBook *OrderBook, config SymbolConfig)
 
 	// Price level handlers
 	OnAddLevel(orderBook *OrderBook, level Level, top bool)
@@ -25,9 +55,13 @@ type MarketHandler interface {
 	OnAddOrder(order Order)
 	OnUpdateOrder(order Order)
 	OnDeleteOrder(order Order)
+	OnInvalidOrder(order Order)
+	OnRejectOrder(order Order, code ErrorCode)
+	OnExpireOrder(order Order)
 
 	// Order execution handlers
 	OnExecuteOrder(order Order, price, quantity uint64)
+	OnTrade(trade Trade)
 }
 
 // DefaultMarketHandler is a no-op implementation of MarketHandler
@@ -48,6 +82,13 @@ func (h *DefaultMarketHandler) OnUpdateOrderBook(orderBook *OrderBook, top bool)
 // OnDeleteOrderBook is called when an order book is deleted
 func (h *DefaultMarketHandler) OnDeleteOrderBook(orderBook *OrderBook) {}
 
+// OnBookCrossed is called when the best bid reaches or passes the best ask
+// while automatic matching is disabled
+func (h *DefaultMarketHandler) OnBookCrossed(orderBook *OrderBook) {}
+
+// OnUpdateSymbolConfig is called when the trading rules of an order book change
+func (h *DefaultMarketHandler) OnUpdateSymbolConfig(orderBook *OrderBook, config SymbolConfig) {}
+
 // OnAddLevel is called when a price level is added
 func (h *DefaultMarketHandler) OnAddLevel(orderBook *OrderBook, level Level, top bool) {}
 
@@ -66,5 +107,22 @@ func (h *DefaultMarketHandler) OnUpdateOrder(order Order) {}
 // OnDeleteOrder is called when an order is deleted
 func (h *DefaultMarketHandler) OnDeleteOrder(order Order) {}
 
+// OnInvalidOrder is called for every resting order that violates a new
+// symbol configuration
+func (h *DefaultMarketHandler) OnInvalidOrder(order Order) {}
+
+// OnRejectOrder is called when an order operation is rejected, with the
+// order as requested, or its current state for an operation on an existing
+// order
+func (h *DefaultMarketHandler) OnRejectOrder(order Order, code ErrorCode) {}
+
+// OnExpireOrder is called for a resting order that outlived the time to live
+// of its order book, before it is deleted
+func (h *DefaultMarketHandler) OnExpireOrder(order Order) {}
+
 // OnExecuteOrder is called when an order is executed
 func (h *DefaultMarketHandler) OnExecuteOrder(order Order, price, quantity uint64) {}
+
+// OnTrade is called once for every match between a buy and a sell order,
+// after both orders have been executed
+func (h *DefaultMarketHandler) OnTrade(trade Trade) {}
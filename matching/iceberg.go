@@ -0,0 +1,32 @@
+package matching
+
+import "math/rand/v2"
+
+// SetDisplaySeed seeds the generator of randomized iceberg displays. The
+// default seed is 0, so the same orders refresh to the same displays on every
+// run, such as the replay of a journal. Venues seed it per session so the
+// displays cannot be predicted.
+func (m *MarketManager) SetDisplaySeed(seed uint64) {
+	m.displayRand = rand.New(rand.NewPCG(seed, seed))
+}
+
+// refreshDisplay draws a new display for an iceberg whose display was
+// executed in full
+func (m *MarketManager) refreshDisplay(order *OrderNode) {
+	if m.displayRand == nil {
+		m.SetDisplaySeed(0)
+	}
+	width := order.DisplayHighQuantity - order.DisplayLowQuantity
+	order.MaxVisibleQuantity = order.DisplayLowQuantity + m.displayRand.Uint64N(width+1)
+}
+
+// applyIcebergVariance gives an iceberg without a display range of its own the
+// range of the book
+func (c SymbolConfig) applyIcebergVariance(order *Order) {
+	if c.IcebergVariance == 0 || order.DisplayHighQuantity != 0 || !order.IsIceberg() {
+		return
+	}
+	spread := order.MaxVisibleQuantity * c.IcebergVariance / 100
+	order.DisplayLowQuantity = max(order.MaxVisibleQuantity-spread, 1)
+	order.DisplayHighQuantity = order.MaxVisibleQuantity + spread
+}
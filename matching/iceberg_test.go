@@ -0,0 +1,133 @@
+package matching
+
+import "testing"
+
+// refreshedDisplays executes an iceberg of 1000 shares showing 100 in full,
+// display after display, and returns the refreshed displays
+func refreshedDisplays(t *testing.T, seed uint64, order *Order, config SymbolConfig) []uint64 {
+	t.Helper()
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.SetDisplaySeed(seed)
+	if err := manager.UpdateSymbolConfig(1, config); err != ErrorOK {
+		t.Fatalf("UpdateSymbolConfig failed: %s", err)
+	}
+	if err := manager.AddOrder(*order); err != ErrorOK {
+		t.Fatalf("AddOrder failed: %s", err)
+	}
+
+	var displays []uint64
+	for {
+		node := manager.GetOrder(order.ID)
+		if node == nil || node.HiddenQuantity() == 0 {
+			return displays
+		}
+		manager.ExecuteOrder(order.ID, node.VisibleQuantity())
+		if node := manager.GetOrder(order.ID); node != nil {
+			displays = append(displays, node.MaxVisibleQuantity)
+			if level := manager.GetOrderBook(1).BestBid(); level.VisibleVolume != node.VisibleQuantity() || level.HiddenVolume != node.HiddenQuantity() {
+				t.Fatalf("Expected level volumes %d/%d, got %d/%d", node.VisibleQuantity(), node.HiddenQuantity(), level.VisibleVolume, level.HiddenVolume)
+			}
+		}
+	}
+}
+
+func TestMarketManager_IcebergDisplayRandomization(t *testing.T) {
+	iceberg := func() *Order {
+		order := NewLimitOrder(1, 1, OrderSideBuy, 10000, 1000)
+		order.MaxVisibleQuantity = 100
+		return order
+	}
+
+	// Per order range
+	order := iceberg()
+	order.DisplayLowQuantity, order.DisplayHighQuantity = 50, 150
+	displays := refreshedDisplays(t, 7, order, SymbolConfig{})
+	distinct := make(map[uint64]bool)
+	for _, d := range displays {
+		if d < 50 || d > 150 {
+			t.Errorf("Expected displays within 50-150, got %d", d)
+		}
+		distinct[d] = true
+	}
+	if len(displays) < 5 || len(distinct) < 3 {
+		t.Errorf("Expected several distinct displays, got %v", displays)
+	}
+
+	// The same seed refreshes to the same displays
+	order = iceberg()
+	order.DisplayLowQuantity, order.DisplayHighQuantity = 50, 150
+	again := refreshedDisplays(t, 7, order, SymbolConfig{})
+	if len(again) != len(displays) {
+		t.Fatalf("Expected %v, got %v", displays, again)
+	}
+	for i := range displays {
+		if again[i] != displays[i] {
+			t.Fatalf("Expected %v, got %v", displays, again)
+		}
+	}
+
+	// Book variance applies to icebergs without their own range
+	for _, d := range refreshedDisplays(t, 1, iceberg(), SymbolConfig{IcebergVariance: 20}) {
+		if d < 80 || d > 120 {
+			t.Errorf("Expected displays within 80-120, got %d", d)
+		}
+	}
+
+	// Without a range the display is fixed
+	for _, d := range refreshedDisplays(t, 1, iceberg(), SymbolConfig{}) {
+		if d != 100 {
+			t.Errorf("Expected a fixed display of 100, got %d", d)
+		}
+	}
+
+	// A partial execution of the display does not refresh it
+	manager := newConfigManager(&DefaultMarketHandler{})
+	order = iceberg()
+	order.DisplayLowQuantity, order.DisplayHighQuantity = 1, 1000
+	manager.AddOrder(*order)
+	manager.ExecuteOrder(1, 40)
+	if node := manager.GetOrder(1); node.MaxVisibleQuantity != 100 {
+		t.Errorf("Expected the display to stay 100, got %d", node.MaxVisibleQuantity)
+	}
+
+	order = iceberg()
+	order.ID = 2
+	order.DisplayLowQuantity, order.DisplayHighQuantity = 200, 100
+	if err := manager.AddOrder(*order); err != ErrorOrderParameterInvalid {
+		t.Errorf("Expected ErrorOrderParameterInvalid, got %s", err)
+	}
+	if (SymbolConfig{IcebergVariance: 101}).Validate() == nil {
+		t.Error("Expected a variance above 100% to be invalid")
+	}
+}
+
+func TestMarketManager_IcebergReplaceKeepsDisplayRange(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.SetDisplaySeed(7)
+	order := NewLimitOrder(1, 1, OrderSideBuy, 10000, 1000)
+	order.MaxVisibleQuantity = 100
+	order.DisplayLowQuantity, order.DisplayHighQuantity = 50, 150
+	manager.AddOrder(*order)
+
+	if err := manager.ReplaceOrder(1, 2, 10000, 2000); err != ErrorOK {
+		t.Fatalf("ReplaceOrder failed: %s", err)
+	}
+	node := manager.GetOrder(2)
+	if node == nil || node.DisplayLowQuantity != 50 || node.DisplayHighQuantity != 150 {
+		t.Fatalf("Expected the display range 50-150 kept, got %+v", node)
+	}
+
+	// The refreshed displays of the replacement are still randomized
+	distinct := make(map[uint64]bool)
+	for i := 0; i < 10; i++ {
+		manager.ExecuteOrder(2, manager.GetOrder(2).VisibleQuantity())
+		d := manager.GetOrder(2).MaxVisibleQuantity
+		if d < 50 || d > 150 {
+			t.Errorf("Expected displays within 50-150, got %d", d)
+		}
+		distinct[d] = true
+	}
+	if len(distinct) < 3 {
+		t.Errorf("Expected several distinct displays, got %v", distinct)
+	}
+}
@@ -0,0 +1,372 @@
+package matching
+
+import (
+	"sync"
+	"time"
+)
+
+// OrderIDAllocator allocates the IDs of orders entered without one. Its state
+// is a single value, so it can be saved in a snapshot and restored to continue
+// allocating after a restart.
+//
+// The market manager observes the ID of every order it adds or restores, so
+// IDs supplied by clients and IDs replayed from a journal are not allocated
+// again.
+type OrderIDAllocator interface {
+	// Next returns a new order ID, never 0
+	Next() uint64
+	// Observe records an ID in use so that it is not allocated
+	Observe(id uint64)
+	// State returns the state to restore the allocator with
+	State() uint64
+	// Restore continues allocating from a state returned by State
+	Restore(state uint64)
+}
+
+// SequentialIDAllocator allocates increasing IDs
+type SequentialIDAllocator struct {
+	next uint64
+}
+
+// NewSequentialIDAllocator creates an allocator whose first ID is start, or 1
+// if start is 0
+func NewSequentialIDAllocator(start uint64) *SequentialIDAllocator {
+	return &SequentialIDAllocator{next: max(start, 1)}
+}
+
+// Next returns the next ID
+func (a *SequentialIDAllocator) Next() uint64 {
+	id := a.next
+	a.next++
+	return id
+}
+
+// Observe moves the next ID past id
+func (a *SequentialIDAllocator) Observe(id uint64) {
+	if id >= a.next {
+		a.next = id + 1
+	}
+}
+
+// State returns the next ID
+func (a *SequentialIDAllocator) State() uint64 { return a.next }
+
+// Restore sets the next ID
+func (a *SequentialIDAllocator) Restore(state uint64) { a.next = max(state, 1) }
+
+// PartitionedIDAllocator allocates increasing IDs inside a partition of the ID
+// space: the high bits of every ID hold the partition and the low bits a
+// counter. Allocators of different partitions, for example one per gateway
+// session or per node, never collide.
+type PartitionedIDAllocator struct {
+	partition uint64
+	shift     uint
+	next      uint64
+}
+
+// NewPartitionedIDAllocator creates an allocator for partition, stored in the
+// high bits of the IDs. bits is clamped to 1..32 and only the low bits of
+// partition are used.
+func NewPartitionedIDAllocator(partition uint64, bits uint) *PartitionedIDAllocator {
+	bits = min(max(bits, 1), 32)
+	shift := 64 - bits
+	return &PartitionedIDAllocator{
+		partition: partition & (1<<bits - 1),
+		shift:     shift,
+		next:      1,
+	}
+}
+
+// Partition returns the partition of the allocator
+func (a *PartitionedIDAllocator) Partition() uint64 { return a.partition }
+
+// Contains checks if id belongs to the partition of the allocator
+func (a *PartitionedIDAllocator) Contains(id uint64) bool {
+	return id>>a.shift == a.partition
+}
+
+// Next returns the next ID of the partition
+func (a *PartitionedIDAllocator) Next() uint64 {
+	id := a.partition<<a.shift | a.next&(1<<a.shift-1)
+	a.next++
+	return id
+}
+
+// Observe moves the counter past id if it belongs to the partition
+func (a *PartitionedIDAllocator) Observe(id uint64) {
+	if !a.Contains(id) {
+		return
+	}
+	if counter := id & (1<<a.shift - 1); counter >= a.next {
+		a.next = counter + 1
+	}
+}
+
+// State returns the next counter
+func (a *PartitionedIDAllocator) State() uint64 { return a.next }
+
+// Restore sets the next counter
+func (a *PartitionedIDAllocator) Restore(state uint64) { a.next = max(state, 1) }
+
+// Layout of the IDs of SnowflakeIDAllocator
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+	snowflakeTimeShift    = snowflakeNodeBits + snowflakeSequenceBits
+	snowflakeSequenceMask = 1<<snowflakeSequenceBits - 1
+
+	// SnowflakeMaxNode is the highest node of SnowflakeIDAllocator
+	SnowflakeMaxNode = 1<<snowflakeNodeBits - 1
+)
+
+// SnowflakeIDAllocator allocates time ordered IDs made of the milliseconds
+// since an epoch (42 bits), a node (10 bits) and a sequence within the
+// millisecond (12 bits). Nodes allocate independently without colliding.
+//
+// IDs keep increasing when the clock goes back or more than 4096 IDs are
+// allocated in a millisecond: the allocator then runs ahead of the clock.
+type SnowflakeIDAllocator struct {
+	epoch time.Time
+	node  uint64
+	clock func() time.Time
+	// last is the last ID allocated or observed of the node
+	last uint64
+}
+
+// NewSnowflakeIDAllocator creates an allocator for node, counting time from
+// epoch. Only the low 10 bits of node are used.
+func NewSnowflakeIDAllocator(node uint16, epoch time.Time) *SnowflakeIDAllocator {
+	return &SnowflakeIDAllocator{epoch: epoch, node: uint64(node) & SnowflakeMaxNode}
+}
+
+// SetClock replaces the clock, nil for time.Now
+func (a *SnowflakeIDAllocator) SetClock(clock func() time.Time) {
+	a.clock = clock
+}
+
+// Node returns the node of the allocator
+func (a *SnowflakeIDAllocator) Node() uint16 { return uint16(a.node) }
+
+// Time returns the time an ID was allocated at
+func (a *SnowflakeIDAllocator) Time(id uint64) time.Time {
+	return a.epoch.Add(time.Duration(id>>snowflakeTimeShift) * time.Millisecond)
+}
+
+// Next returns the next ID of the node
+func (a *SnowflakeIDAllocator) Next() uint64 {
+	now := time.Now
+	if a.clock != nil {
+		now = a.clock
+	}
+	ms := uint64(max(now().Sub(a.epoch).Milliseconds(), 0))
+
+	lastMs := a.last >> snowflakeTimeShift
+	sequence := uint64(0)
+	if ms <= lastMs {
+		ms = lastMs
+		sequence = a.last&snowflakeSequenceMask + 1
+		if sequence > snowflakeSequenceMask {
+			ms++
+			sequence = 0
+		}
+	}
+	id := ms<<snowflakeTimeShift | a.node<<snowflakeSequenceBits | sequence
+	if id == 0 {
+		id = 1
+	}
+	a.last = id
+	return id
+}
+
+// Observe moves the allocator past id if it belongs to the node
+func (a *SnowflakeIDAllocator) Observe(id uint64) {
+	if id>>snowflakeSequenceBits&SnowflakeMaxNode == a.node && id > a.last {
+		a.last = id
+	}
+}
+
+// State returns the last ID
+func (a *SnowflakeIDAllocator) State() uint64 { return a.last }
+
+// Restore sets the last ID
+func (a *SnowflakeIDAllocator) Restore(state uint64) { a.last = state }
+
+// scrambledWindow bounds how far Observe moves a ScrambledIDAllocator, so that
+// foreign IDs, which unscramble to random counters, are ignored
+const scrambledWindow = 1 << 32
+
+// ScrambledIDAllocator allocates IDs that look random but are a deterministic
+// permutation of a counter: the same seed yields the same sequence, and the
+// sequence only repeats after 2^64 IDs. Clients cannot guess the IDs of
+// other orders from their own.
+type ScrambledIDAllocator struct {
+	// key offsets the counter, derived from the seed so that near seeds give
+	// unrelated sequences
+	key  uint64
+	next uint64
+}
+
+// NewScrambledIDAllocator creates an allocator whose sequence is determined by
+// seed
+func NewScrambledIDAllocator(seed uint64) *ScrambledIDAllocator {
+	return &ScrambledIDAllocator{key: scramble(seed), next: 1}
+}
+
+// Next returns the next ID
+func (a *ScrambledIDAllocator) Next() uint64 {
+	for {
+		id := scramble(a.next + a.key)
+		a.next++
+		if id != 0 {
+			return id
+		}
+	}
+}
+
+// Observe moves the counter past the one of id, if it is near
+func (a *ScrambledIDAllocator) Observe(id uint64) {
+	counter := unscramble(id) - a.key
+	if counter >= a.next && counter-a.next < scrambledWindow {
+		a.next = counter + 1
+	}
+}
+
+// State returns the next counter
+func (a *ScrambledIDAllocator) State() uint64 { return a.next }
+
+// Restore sets the next counter
+func (a *ScrambledIDAllocator) Restore(state uint64) { a.next = max(state, 1) }
+
+// Multipliers of scramble and their inverses modulo 2^64
+const (
+	scrambleMul1 = 0xbf58476d1ce4e5b9
+	scrambleMul2 = 0x94d049bb133111eb
+)
+
+var (
+	scrambleInv1 = inverse(scrambleMul1)
+	scrambleInv2 = inverse(scrambleMul2)
+)
+
+// scramble is the splitmix64 finalizer, a bijection of uint64
+func scramble(x uint64) uint64 {
+	x ^= x >> 30
+	x *= scrambleMul1
+	x ^= x >> 27
+	x *= scrambleMul2
+	x ^= x >> 31
+	return x
+}
+
+// unscramble is the inverse of scramble
+func unscramble(x uint64) uint64 {
+	x = unshift(x, 31)
+	x *= scrambleInv2
+	x = unshift(x, 27)
+	x *= scrambleInv1
+	x = unshift(x, 30)
+	return x
+}
+
+// unshift inverts x ^= x >> shift
+func unshift(x uint64, shift uint) uint64 {
+	y := x
+	for i := shift; i < 64; i += shift {
+		y = x ^ y>>shift
+	}
+	return y
+}
+
+// inverse returns the inverse of an odd number modulo 2^64
+func inverse(a uint64) uint64 {
+	x := a
+	for i := 0; i < 5; i++ {
+		x *= 2 - a*x
+	}
+	return x
+}
+
+// lockedIDAllocator shares an allocator between goroutines
+type lockedIDAllocator struct {
+	mu        sync.Mutex
+	allocator OrderIDAllocator
+}
+
+func (a *lockedIDAllocator) Next() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.allocator.Next()
+}
+
+func (a *lockedIDAllocator) Observe(id uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.allocator.Observe(id)
+}
+
+func (a *lockedIDAllocator) State() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.allocator.State()
+}
+
+func (a *lockedIDAllocator) Restore(state uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.allocator.Restore(state)
+}
+
+// SetOrderIDAllocator replaces the order ID allocator. The IDs of the orders
+// already in the market are observed by the new allocator.
+func (m *MarketManager) SetOrderIDAllocator(allocator OrderIDAllocator) {
+	m.idAllocator = allocator
+	for id := range m.orders {
+		allocator.Observe(id)
+	}
+}
+
+// OrderIDAllocator returns the order ID allocator, creating a sequential one
+// on first use
+func (m *MarketManager) OrderIDAllocator() OrderIDAllocator {
+	if m.idAllocator == nil {
+		m.SetOrderIDAllocator(NewSequentialIDAllocator(1))
+	}
+	return m.idAllocator
+}
+
+// NextOrderID allocates the ID of a new order, skipping the IDs of orders in
+// the market
+func (m *MarketManager) NextOrderID() uint64 {
+	allocator := m.OrderIDAllocator()
+	for {
+		id := allocator.Next()
+		if _, exists := m.orders[id]; !exists {
+			return id
+		}
+	}
+}
+
+// observeOrderID records the ID of an order entering the market
+func (m *MarketManager) observeOrderID(id uint64) {
+	if m.idAllocator != nil {
+		m.idAllocator.Observe(id)
+	}
+}
+
+// SetOrderIDAllocator replaces the order ID allocator shared by all shards.
+// It must be set before orders are entered.
+func (e *Engine) SetOrderIDAllocator(allocator OrderIDAllocator) ErrorCode {
+	locked := &lockedIDAllocator{allocator: allocator}
+	e.ids.Store(locked)
+	return e.broadcast(func(m *MarketManager) ErrorCode {
+		m.SetOrderIDAllocator(locked)
+		return ErrorOK
+	})
+}
+
+// NextOrderID allocates the ID of a new order. IDs are unique across shards
+// as long as all orders are entered with allocated IDs or IDs the allocator
+// observed.
+func (e *Engine) NextOrderID() uint64 {
+	return e.ids.Load().Next()
+}
@@ -0,0 +1,196 @@
+package matching
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSequentialIDAllocator(t *testing.T) {
+	a := NewSequentialIDAllocator(0)
+	if id := a.Next(); id != 1 {
+		t.Errorf("Expected first ID 1, got %d", id)
+	}
+	a.Observe(10)
+	a.Observe(5)
+	if id := a.Next(); id != 11 {
+		t.Errorf("Expected ID 11 after observing 10, got %d", id)
+	}
+
+	b := NewSequentialIDAllocator(1)
+	b.Restore(a.State())
+	if id := b.Next(); id != 12 {
+		t.Errorf("Expected restored allocator to continue at 12, got %d", id)
+	}
+}
+
+func TestPartitionedIDAllocator(t *testing.T) {
+	a := NewPartitionedIDAllocator(5, 4)
+	b := NewPartitionedIDAllocator(6, 4)
+	if id := a.Next(); id != 5<<60|1 {
+		t.Errorf("Expected ID %#x, got %#x", uint64(5<<60|1), id)
+	}
+	if id := b.Next(); id != 6<<60|1 {
+		t.Errorf("Expected ID %#x, got %#x", uint64(6<<60|1), id)
+	}
+
+	// IDs of other partitions are ignored
+	a.Observe(6<<60 | 100)
+	a.Observe(5<<60 | 7)
+	if id := a.Next(); id != 5<<60|8 {
+		t.Errorf("Expected ID %#x, got %#x", uint64(5<<60|8), id)
+	}
+	if !a.Contains(5<<60|1) || a.Contains(6<<60|1) {
+		t.Error("Expected Contains to check the partition")
+	}
+
+	c := NewPartitionedIDAllocator(5, 4)
+	c.Restore(a.State())
+	if id := c.Next(); id != 5<<60|9 {
+		t.Errorf("Expected restored allocator to continue at %#x, got %#x", uint64(5<<60|9), id)
+	}
+}
+
+func TestSnowflakeIDAllocator(t *testing.T) {
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := epoch.Add(time.Second)
+	a := NewSnowflakeIDAllocator(3, epoch)
+	a.SetClock(func() time.Time { return now })
+
+	first := a.Next()
+	if first != 1000<<22|3<<12 {
+		t.Errorf("Expected ID %#x, got %#x", uint64(1000<<22|3<<12), first)
+	}
+	if got := a.Time(first); !got.Equal(now) {
+		t.Errorf("Expected time %s, got %s", now, got)
+	}
+	if id := a.Next(); id != first+1 {
+		t.Errorf("Expected next sequence %#x, got %#x", first+1, id)
+	}
+
+	// IDs keep increasing when the clock goes back
+	now = now.Add(-time.Millisecond)
+	last := a.Next()
+	if last != first+2 {
+		t.Errorf("Expected ID %#x with the clock back, got %#x", first+2, last)
+	}
+
+	// A full millisecond runs ahead of the clock
+	for i := 0; i < 4093; i++ {
+		last = a.Next()
+	}
+	if id := a.Next(); id != 1001<<22|3<<12 || id <= last {
+		t.Errorf("Expected ID %#x after a full millisecond, got %#x", uint64(1001<<22|3<<12), id)
+	}
+
+	b := NewSnowflakeIDAllocator(3, epoch)
+	b.SetClock(func() time.Time { return now })
+	b.Restore(a.State())
+	if id := b.Next(); id != 1001<<22|3<<12|1 {
+		t.Errorf("Expected restored allocator to continue at %#x, got %#x", uint64(1001<<22|3<<12|1), id)
+	}
+	// IDs of other nodes are ignored
+	b.Observe(2000<<22 | 4<<12)
+	if id := b.Next(); id != 1001<<22|3<<12|2 {
+		t.Errorf("Expected ID %#x, got %#x", uint64(1001<<22|3<<12|2), id)
+	}
+}
+
+func TestScrambledIDAllocator(t *testing.T) {
+	for _, x := range []uint64{0, 1, 42, 1 << 63, ^uint64(0)} {
+		if got := unscramble(scramble(x)); got != x {
+			t.Errorf("Expected unscramble(scramble(%#x)) = %#x, got %#x", x, x, got)
+		}
+	}
+
+	a := NewScrambledIDAllocator(7)
+	b := NewScrambledIDAllocator(7)
+	seen := make(map[uint64]bool)
+	for i := 0; i < 1000; i++ {
+		id := a.Next()
+		if id == 0 || seen[id] {
+			t.Fatalf("Expected unique non-zero IDs, got %#x", id)
+		}
+		seen[id] = true
+		if other := b.Next(); other != id {
+			t.Fatalf("Expected the same sequence for the same seed, got %#x and %#x", id, other)
+		}
+	}
+	if id := NewScrambledIDAllocator(8).Next(); seen[id] {
+		t.Errorf("Expected another sequence for another seed, got %#x", id)
+	}
+
+	// Observing an ID ahead in the sequence skips to it, foreign IDs are
+	// ignored
+	c := NewScrambledIDAllocator(7)
+	c.Observe(123456789)
+	c.Restore(a.State())
+	ahead := NewScrambledIDAllocator(7)
+	ahead.Restore(a.State() + 10)
+	next := ahead.Next()
+	c.Observe(next)
+	if id := c.Next(); id != ahead.Next() {
+		t.Errorf("Expected allocator to skip past the observed ID, got %#x", id)
+	}
+}
+
+func TestMarketManager_NextOrderID(t *testing.T) {
+	manager := newConfigManager(&configHandler{})
+
+	if id := manager.NextOrderID(); id != 1 {
+		t.Errorf("Expected first order ID 1, got %d", id)
+	}
+	// Client supplied IDs are not allocated again
+	if err := manager.AddOrder(participantOrder(2, 1, OrderSideBuy, 10000, 100, 0)); err != ErrorOK {
+		t.Fatalf("AddOrder failed: %s", err)
+	}
+	if err := manager.ReplaceOrder(2, 5, 10000, 100); err != ErrorOK {
+		t.Fatalf("ReplaceOrder failed: %s", err)
+	}
+	if id := manager.NextOrderID(); id != 6 {
+		t.Errorf("Expected order ID 6, got %d", id)
+	}
+
+	// A new allocator observes the orders in the market
+	manager.SetOrderIDAllocator(NewSequentialIDAllocator(1))
+	if id := manager.NextOrderID(); id != 6 {
+		t.Errorf("Expected order ID 6 from the new allocator, got %d", id)
+	}
+}
+
+func TestEngine_NextOrderID(t *testing.T) {
+	engine := NewEngine(2)
+	defer engine.Close()
+	for id := uint32(1); id <= 2; id++ {
+		symbol := NewSymbol(id, "S"+string(rune('0'+id)))
+		engine.AddSymbol(symbol)
+		engine.AddOrderBook(symbol)
+	}
+
+	if err := engine.SetOrderIDAllocator(NewPartitionedIDAllocator(1, 8)); err != ErrorOK {
+		t.Fatalf("SetOrderIDAllocator failed: %s", err)
+	}
+	if err := engine.AddOrder(participantOrder(1<<56|10, 2, OrderSideBuy, 10000, 100, 0)); err != ErrorOK {
+		t.Fatalf("AddOrder failed: %s", err)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	seen := make(map[uint64]bool)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				id := engine.NextOrderID()
+				mu.Lock()
+				if seen[id] || id <= 1<<56|10 {
+					t.Errorf("Expected a new ID above the observed one, got %#x", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,173 @@
+package matching
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+	"time"
+)
+
+// LatencyModel draws the delay between sending an order and its arrival at
+// the matching engine, for simulations and backtests
+type LatencyModel interface {
+	// Latency returns the delay of an order, negative delays count as 0
+	Latency(order *Order) time.Duration
+}
+
+// FixedLatency delays every order by the same duration
+type FixedLatency time.Duration
+
+// Latency returns the fixed delay
+func (l FixedLatency) Latency(order *Order) time.Duration {
+	return time.Duration(l)
+}
+
+// UniformLatency draws delays uniformly between a minimum and a maximum
+type UniformLatency struct {
+	low, high time.Duration
+	rand      *rand.Rand
+}
+
+// NewUniformLatency creates a uniform latency model between low and high,
+// seeded so that simulations are reproducible
+func NewUniformLatency(low, high time.Duration, seed uint64) *UniformLatency {
+	return &UniformLatency{low: low, high: max(low, high), rand: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// Latency draws a delay
+func (l *UniformLatency) Latency(order *Order) time.Duration {
+	return l.low + time.Duration(l.rand.Int64N(int64(l.high-l.low)+1))
+}
+
+// LogNormalLatency draws log-normally distributed delays, the long-tailed
+// shape of measured network and gateway latencies
+type LogNormalLatency struct {
+	median time.Duration
+	sigma  float64
+	rand   *rand.Rand
+}
+
+// NewLogNormalLatency creates a log-normal latency model with the given
+// median and the standard deviation of the log of the delays, seeded so that
+// simulations are reproducible
+func NewLogNormalLatency(median time.Duration, sigma float64, seed uint64) *LogNormalLatency {
+	return &LogNormalLatency{median: median, sigma: sigma, rand: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// Latency draws a delay
+func (l *LogNormalLatency) Latency(order *Order) time.Duration {
+	d := float64(l.median) * math.Exp(l.sigma*l.rand.NormFloat64())
+	if d >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(d)
+}
+
+// EmpiricalLatency draws delays from measured latencies, such as the
+// acknowledgment times of a production gateway
+type EmpiricalLatency struct {
+	samples []time.Duration
+	rand    *rand.Rand
+}
+
+// NewEmpiricalLatency creates a latency model drawing uniformly from samples,
+// seeded so that simulations are reproducible. Without samples it delays
+// nothing.
+func NewEmpiricalLatency(samples []time.Duration, seed uint64) *EmpiricalLatency {
+	return &EmpiricalLatency{
+		samples: append([]time.Duration(nil), samples...),
+		rand:    rand.New(rand.NewPCG(seed, seed)),
+	}
+}
+
+// Latency draws a delay
+func (l *EmpiricalLatency) Latency(order *Order) time.Duration {
+	if len(l.samples) == 0 {
+		return 0
+	}
+	return l.samples[l.rand.IntN(len(l.samples))]
+}
+
+// LatencyConfig configures the simulated arrival of new orders
+type LatencyConfig struct {
+	// Model draws the delay of each new order, nil to add orders as they are
+	// sent
+	Model LatencyModel
+	// Reorder lets an order overtake orders sent before it with a longer
+	// delay, as orders sent over several connections. By default orders
+	// arrive in the order they were sent, as over a single connection: an
+	// order drawn to arrive before an earlier one arrives with it.
+	Reorder bool
+}
+
+// arrival is an order in flight to the matching engine
+type arrival struct {
+	at    time.Time
+	order Order
+}
+
+// SetLatency sets the latency model of new orders. With a model, AddOrder
+// only sends an order, at the time of the market manager clock, and returns
+// ErrorOK: the order arrives when the clock reaches its send time plus its
+// delay and ProcessArrivals or the next AddOrder runs. Only then is it
+// validated, matched and acknowledged with OnAddOrder, or rejected with
+// OnRejectOrder, so strategies see the acknowledgment and fill timing of a
+// real venue. Deletions and modifications are not delayed and fail with
+// ErrorOrderNotFound for orders still in flight.
+//
+// Latency models are meant for simulations and backtests driven by SetClock.
+// Orders in flight are not journaled or snapshotted.
+func (m *MarketManager) SetLatency(config LatencyConfig) {
+	m.latency = config
+}
+
+// Latency returns the latency configuration of new orders
+func (m *MarketManager) Latency() LatencyConfig {
+	return m.latency
+}
+
+// send puts an order in flight and adds it at once if it already arrived
+func (m *MarketManager) send(order Order) {
+	at := m.now().Add(max(m.latency.Model.Latency(&order), 0))
+	n := len(m.arrivals)
+	// Orders arrive in arrival time order, the ones sent first on ties
+	i := sort.Search(n, func(i int) bool { return m.arrivals[i].at.After(at) })
+	if !m.latency.Reorder && i < n {
+		at, i = m.arrivals[n-1].at, n
+	}
+	m.arrivals = append(m.arrivals, arrival{})
+	copy(m.arrivals[i+1:], m.arrivals[i:])
+	m.arrivals[i] = arrival{at: at, order: order}
+	m.ProcessArrivals()
+}
+
+// ProcessArrivals adds the orders in flight that arrived by the time of the
+// market manager clock, in arrival order, and returns their number
+func (m *MarketManager) ProcessArrivals() int {
+	now := m.now()
+	n := 0
+	// Handlers may send orders while an arrival is added, so each one
+	// leaves the queue first
+	for len(m.arrivals) != 0 && !m.arrivals[0].at.After(now) {
+		order := m.arrivals[0].order
+		m.arrivals[0] = arrival{}
+		m.arrivals = m.arrivals[1:]
+		m.addOrder(order)
+		n++
+	}
+	return n
+}
+
+// InFlight returns the number of orders sent and not arrived yet
+func (m *MarketManager) InFlight() int {
+	return len(m.arrivals)
+}
+
+// NextArrival returns the arrival time of the next order in flight, so that
+// a simulation can advance its clock to it
+func (m *MarketManager) NextArrival() (time.Time, bool) {
+	if len(m.arrivals) == 0 {
+		return time.Time{}, false
+	}
+	return m.arrivals[0].at, true
+}
@@ -0,0 +1,118 @@
+package matching
+
+import (
+	"testing"
+	"time"
+)
+
+// latencyHandler records acknowledgments and rejects with the clock time
+type latencyHandler struct {
+	DefaultMarketHandler
+	now     *time.Time
+	added   map[uint64]time.Time
+	rejects map[uint64]ErrorCode
+}
+
+func (h *latencyHandler) OnAddOrder(order Order) {
+	h.added[order.ID] = *h.now
+}
+
+func (h *latencyHandler) OnRejectOrder(order Order, code ErrorCode) {
+	h.rejects[order.ID] = code
+}
+
+func TestMarketManager_Latency(t *testing.T) {
+	start := time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC)
+	now := start
+	handler := &latencyHandler{now: &now, added: make(map[uint64]time.Time), rejects: make(map[uint64]ErrorCode)}
+	manager := newConfigManager(handler)
+	manager.EnableMatching()
+	manager.SetClock(func() time.Time { return now })
+	manager.SetLatency(LatencyConfig{Model: FixedLatency(time.Millisecond)})
+
+	if result := manager.AddOrder(*NewLimitOrder(1, 1, OrderSideSell, 10000, 10)); result != ErrorOK {
+		t.Fatalf("Expected ErrorOK, got %v", result)
+	}
+	if manager.GetOrder(1) != nil || manager.InFlight() != 1 {
+		t.Fatalf("Expected the order in flight, got %d in flight", manager.InFlight())
+	}
+	if at, ok := manager.NextArrival(); !ok || !at.Equal(start.Add(time.Millisecond)) {
+		t.Errorf("Expected an arrival after 1ms, got %v", at)
+	}
+	// Deletions are not delayed
+	if result := manager.DeleteOrder(1); result != ErrorOrderNotFound {
+		t.Errorf("Expected ErrorOrderNotFound, got %v", result)
+	}
+	clear(handler.rejects)
+
+	now = start.Add(500 * time.Microsecond)
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideBuy, 9900, 4))
+	// A duplicate ID is only rejected on arrival
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideBuy, 10000, 4))
+	if n := manager.ProcessArrivals(); n != 0 || len(handler.rejects) != 0 {
+		t.Errorf("Expected no arrivals yet, got %d and rejects %v", n, handler.rejects)
+	}
+
+	now = start.Add(2 * time.Millisecond)
+	if n := manager.ProcessArrivals(); n != 3 {
+		t.Fatalf("Expected 3 arrivals, got %d", n)
+	}
+	if !handler.added[1].Equal(now) || !handler.added[2].Equal(now) || manager.GetOrder(1).LeavesQuantity != 10 {
+		t.Errorf("Expected the orders acknowledged at 2ms, got %v", handler.added)
+	}
+	if handler.rejects[2] != ErrorOrderDuplicate {
+		t.Errorf("Expected the duplicate rejected on arrival, got %v", handler.rejects)
+	}
+}
+
+func TestMarketManager_LatencyOrdering(t *testing.T) {
+	now := time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC)
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.SetClock(func() time.Time { return now })
+	delays := NewEmpiricalLatency([]time.Duration{3 * time.Millisecond}, 0)
+
+	// An order sent after a slower one arrives with it
+	manager.SetLatency(LatencyConfig{Model: delays})
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 10))
+	manager.SetLatency(LatencyConfig{Model: FixedLatency(time.Millisecond)})
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideBuy, 10000, 10))
+	if manager.arrivals[0].order.ID != 1 || !manager.arrivals[1].at.Equal(manager.arrivals[0].at) {
+		t.Errorf("Expected the orders to arrive together in send order, got %+v", manager.arrivals)
+	}
+
+	// Or overtakes it when reordering
+	manager.SetLatency(LatencyConfig{Model: FixedLatency(time.Millisecond), Reorder: true})
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideBuy, 10000, 10))
+	if manager.arrivals[0].order.ID != 3 {
+		t.Errorf("Expected order 3 to overtake, got %+v", manager.arrivals)
+	}
+
+	// Without a model new orders are added at once, after those in flight
+	manager.SetLatency(LatencyConfig{})
+	now = now.Add(time.Second)
+	manager.AddOrder(*NewLimitOrder(4, 1, OrderSideBuy, 10000, 10))
+	if manager.InFlight() != 0 || len(manager.Orders()) != 4 {
+		t.Errorf("Expected every order added, got %d in flight", manager.InFlight())
+	}
+}
+
+func TestLatencyModels(t *testing.T) {
+	uniform := NewUniformLatency(time.Millisecond, 2*time.Millisecond, 1)
+	lognormal := NewLogNormalLatency(time.Millisecond, 0.5, 1)
+	for i := 0; i < 100; i++ {
+		if d := uniform.Latency(nil); d < time.Millisecond || d > 2*time.Millisecond {
+			t.Fatalf("Expected a delay between 1ms and 2ms, got %v", d)
+		}
+		if d := lognormal.Latency(nil); d <= 0 {
+			t.Fatalf("Expected a positive delay, got %v", d)
+		}
+	}
+	// The same seed draws the same delays
+	a, b := NewUniformLatency(0, time.Second, 7), NewUniformLatency(0, time.Second, 7)
+	if a.Latency(nil) != b.Latency(nil) {
+		t.Error("Expected reproducible delays")
+	}
+	if d := NewEmpiricalLatency(nil, 0).Latency(nil); d != 0 {
+		t.Errorf("Expected no delay without samples, got %v", d)
+	}
+}
@@ -96,6 +96,52 @@ func NewLevelNode(levelType LevelType, price uint64) *LevelNode {
 	}
 }
 
+// OrderView is a read-only copy of a resting order's queue state
+type OrderView struct {
+	// ID is the order ID
+	ID uint64
+	// Side is the order side
+	Side OrderSide
+	// Price is the order price
+	Price uint64
+	// LeavesQuantity is the remaining quantity
+	LeavesQuantity uint64
+	// VisibleQuantity is the displayed part of the remaining quantity
+	VisibleQuantity uint64
+	// ParticipantID identifies the participant that entered the order
+	ParticipantID uint32
+	// Priority is the arrival sequence number of the order
+	Priority uint64
+	// Timestamp is the Unix time in nanoseconds at which the order took its
+	// queue position
+	Timestamp int64
+}
+
+// View returns a read-only copy of the order's queue state
+func (on *OrderNode) View() OrderView {
+	return OrderView{
+		ID:              on.ID,
+		Side:            on.Side,
+		Price:           on.Price,
+		LeavesQuantity:  on.LeavesQuantity,
+		VisibleQuantity: on.VisibleQuantity(),
+		ParticipantID:   on.ParticipantID,
+		Priority:        on.priority,
+		Timestamp:       on.timestamp,
+	}
+}
+
+// ForEachOrder calls fn for every order at the level in queue order until fn
+// returns false. fn receives copies, so it cannot affect the book, but it must
+// not modify the order book while iterating.
+func (l *LevelNode) ForEachOrder(fn func(OrderView) bool) {
+	for order := l.OrderList.Head; order != nil; order = order.Next {
+		if !fn(order.View()) {
+			return
+		}
+	}
+}
+
 // LevelUpdate represents an update to a price level
 type LevelUpdate struct {
 	// Type is the type of update (add, update, delete)
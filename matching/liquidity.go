@@ -0,0 +1,114 @@
+package matching
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+)
+
+// LiquidityStats contains the executed volume of a participant in the
+// current session, split between the liquidity it provided as the resting
+// maker and the liquidity it took as the aggressor. Notionals are price ×
+// quantity, saturating on overflow.
+type LiquidityStats struct {
+	// MakerTrades, MakerVolume and MakerNotional count the executions of
+	// resting orders
+	MakerTrades   uint64
+	MakerVolume   uint64
+	MakerNotional uint64
+	// TakerTrades, TakerVolume and TakerNotional count the executions of
+	// aggressing orders
+	TakerTrades   uint64
+	TakerVolume   uint64
+	TakerNotional uint64
+}
+
+// Volume returns the executed quantity as maker and taker
+func (s LiquidityStats) Volume() uint64 {
+	return s.MakerVolume + s.TakerVolume
+}
+
+// add records an execution of price and quantity
+func (s *LiquidityStats) add(maker bool, price, quantity uint64) {
+	hi, notional := bits.Mul64(price, quantity)
+	if hi != 0 {
+		notional = math.MaxUint64
+	}
+	if maker {
+		s.MakerTrades++
+		s.MakerVolume += quantity
+		s.MakerNotional = saturatingAdd(s.MakerNotional, notional)
+	} else {
+		s.TakerTrades++
+		s.TakerVolume += quantity
+		s.TakerNotional = saturatingAdd(s.TakerNotional, notional)
+	}
+}
+
+// merge adds the counters of other
+func (s *LiquidityStats) merge(other LiquidityStats) {
+	s.MakerTrades += other.MakerTrades
+	s.MakerVolume += other.MakerVolume
+	s.MakerNotional = saturatingAdd(s.MakerNotional, other.MakerNotional)
+	s.TakerTrades += other.TakerTrades
+	s.TakerVolume += other.TakerVolume
+	s.TakerNotional = saturatingAdd(s.TakerNotional, other.TakerNotional)
+}
+
+// saturatingAdd returns a + b, math.MaxUint64 on overflow
+func saturatingAdd(a, b uint64) uint64 {
+	sum, carry := bits.Add64(a, b, 0)
+	if carry != 0 {
+		return math.MaxUint64
+	}
+	return sum
+}
+
+// recordLiquidity counts a trade between a bid and an ask against the
+// participants of both orders
+func (m *MarketManager) recordLiquidity(bid, ask *OrderNode, price, quantity uint64) {
+	aggressor := Aggressor(bid, ask)
+	m.countLiquidity(bid.ParticipantID, aggressor == OrderSideSell, price, quantity)
+	m.countLiquidity(ask.ParticipantID, aggressor == OrderSideBuy, price, quantity)
+}
+
+// countLiquidity counts an execution of a participant. Orders without a
+// participant are not counted.
+func (m *MarketManager) countLiquidity(participantID uint32, maker bool, price, quantity uint64) {
+	if participantID == 0 {
+		return
+	}
+	if m.liquidity == nil {
+		m.liquidity = make(map[uint32]*LiquidityStats)
+	}
+	stats := m.liquidity[participantID]
+	if stats == nil {
+		stats = &LiquidityStats{}
+		m.liquidity[participantID] = stats
+	}
+	stats.add(maker, price, quantity)
+}
+
+// ParticipantLiquidity returns the maker and taker volume of a participant
+// in the current session
+func (m *MarketManager) ParticipantLiquidity(participantID uint32) LiquidityStats {
+	if stats := m.liquidity[participantID]; stats != nil {
+		return *stats
+	}
+	return LiquidityStats{}
+}
+
+// ParticipantLiquidity returns the maker and taker volume of a participant
+// in the current session, summed over all shards
+func (e *Engine) ParticipantLiquidity(participantID uint32) LiquidityStats {
+	var mu sync.Mutex
+	var total LiquidityStats
+	e.broadcast(func(m *MarketManager) ErrorCode {
+		stats := m.ParticipantLiquidity(participantID)
+		mu.Lock()
+		total.merge(stats)
+		mu.Unlock()
+		return ErrorOK
+	})
+	return total
+}
@@ -0,0 +1,79 @@
+package matching
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMarketManager_Liquidity(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.EnableMatching()
+
+	manager.AddOrder(participantOrder(1, 1, OrderSideSell, 10000, 10, 7))
+	manager.AddOrder(participantOrder(2, 1, OrderSideSell, 10100, 10, 7))
+	// Participant 8 takes both levels, participant 0 is not counted
+	manager.AddOrder(participantOrder(3, 1, OrderSideBuy, 10100, 15, 8))
+	manager.AddOrder(participantOrder(4, 1, OrderSideBuy, 10100, 5, 0))
+
+	maker := manager.ParticipantLiquidity(7)
+	want := LiquidityStats{MakerTrades: 3, MakerVolume: 20, MakerNotional: 10*10000 + 10*10100}
+	if maker != want {
+		t.Errorf("Expected maker %+v, got %+v", want, maker)
+	}
+	taker := manager.ParticipantLiquidity(8)
+	want = LiquidityStats{TakerTrades: 2, TakerVolume: 15, TakerNotional: 10*10000 + 5*10100}
+	if taker != want {
+		t.Errorf("Expected taker %+v, got %+v", want, taker)
+	}
+	if taker.Volume() != 15 {
+		t.Errorf("Expected a volume of 15, got %d", taker.Volume())
+	}
+
+	stats := manager.Statistics()
+	if len(stats.Liquidity) != 2 || stats.Liquidity[7] != maker {
+		t.Errorf("Expected the counters of participants 7 and 8, got %+v", stats.Liquidity)
+	}
+
+	// A participant trading with itself is both maker and taker
+	manager.AddOrder(participantOrder(5, 1, OrderSideBuy, 9000, 1, 7))
+	manager.AddOrder(participantOrder(6, 1, OrderSideSell, 9000, 1, 7))
+	if self := manager.ParticipantLiquidity(7); self.MakerVolume != 21 || self.TakerVolume != 1 {
+		t.Errorf("Expected both sides of the self trade, got %+v", self)
+	}
+
+	manager.ResetSession()
+	if got := manager.ParticipantLiquidity(7); got != (LiquidityStats{}) {
+		t.Errorf("Expected the counters to reset, got %+v", got)
+	}
+	if len(manager.Statistics().Liquidity) != 0 {
+		t.Errorf("Expected no counters after the reset, got %+v", manager.Statistics().Liquidity)
+	}
+}
+
+func TestLiquidityStats_Saturation(t *testing.T) {
+	var stats LiquidityStats
+	stats.add(true, math.MaxUint64/2, 3)
+	stats.add(false, 2, 3)
+	stats.merge(LiquidityStats{MakerNotional: 1, TakerNotional: math.MaxUint64})
+	if stats.MakerNotional != math.MaxUint64 || stats.TakerNotional != math.MaxUint64 {
+		t.Errorf("Expected saturated notionals, got %+v", stats)
+	}
+}
+
+func TestEngine_ParticipantLiquidity(t *testing.T) {
+	engine := NewEngine(2)
+	defer engine.Close()
+	engine.EnableMatching()
+	for id := uint32(1); id <= 2; id++ {
+		symbol := NewSymbol(id, "SYM")
+		engine.AddSymbol(symbol)
+		engine.AddOrderBook(symbol)
+	}
+	for id := uint32(1); id <= 2; id++ {
+		engine.AddOrder(participantOrder(uint64(id)*10, id, OrderSideSell, 10000, 10, 7))
+		engine.AddOrder(participantOrder(uint64(id)*10+1, id, OrderSideBuy, 10000, 4, 8))
+	}
+	if got := engine.ParticipantLiquidity(7); got.MakerTrades != 2 || got.MakerVolume != 8 || got.MakerNotional != 80000 {
+		t.Errorf("Expected the maker volume of both shards, got %+v", got)
+	}
+}
@@ -1,5 +1,13 @@
 package matching
 
+import (
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"github.com/tienpsm/go-trader/metrics"
+)
+
 // MarketManager is used to manage the market with symbols, orders and order books.
 // Automatic order matching can be enabled with EnableMatching() or manually performed with Match().
 // Not thread-safe.
@@ -9,6 +17,8 @@ type MarketManager struct {
 
 	// symbols is the list of all symbols
 	symbols map[uint32]*Symbol
+	// symbolIDs is the index of symbol IDs by name
+	symbolIDs map[string]uint32
 	// orderBooks is the list of all order books
 	orderBooks map[uint32]*OrderBook
 	// orders is the map of all orders by ID
@@ -16,6 +26,68 @@ type MarketManager struct {
 
 	// matching indicates if automatic matching is enabled
 	matching bool
+	// priceRule determines the execution price of matched orders
+	priceRule PriceRule
+	// sequence is the last arrival sequence number assigned to an order
+	sequence uint64
+
+	// addOrderLatency records AddOrder latencies when tracking is enabled
+	addOrderLatency *metrics.Histogram
+
+	// clock returns the time used to check trading schedules, nil for time.Now
+	clock func() time.Time
+
+	// amendmentDepth is the number of amendments kept per order, 0 to disable
+	amendmentDepth int
+
+	// authorizer checks Participant operations, nil to allow all
+	authorizer Authorizer
+
+	// exposures tracks open exposure per participant, nil until limits are set
+	exposures *exposures
+
+	// sessions are the open cancel-on-disconnect sessions by ID
+	sessions map[uint64]*orderSession
+
+	// idAllocator allocates order IDs, nil until first used or set
+	idAllocator OrderIDAllocator
+
+	// batch records the events of operations in batched mode, nil otherwise
+	batch *batcher
+	// depth is the number of operations in progress in batched or conflated
+	// mode
+	depth int
+
+	// conflation determines how often OnUpdateOrderBook is called, and
+	// bookUpdates are the notifications it deferred
+	conflation  BookConflation
+	bookUpdates []bookUpdate
+
+	// spreads are the definitions of the spread symbols, nil until the first
+	// spread is added
+	spreads map[uint32]*Spread
+
+	// displayRand draws the refreshed displays of icebergs, created on first
+	// use with seed 0
+	displayRand *rand.Rand
+
+	// halted rejects the new orders of every book, see HaltMarket
+	halted bool
+
+	// liquidity is the maker and taker volume of each participant in the
+	// current session, nil until the first trade of a participant
+	liquidity map[uint32]*LiquidityStats
+
+	// fees are charged on executions, and settlement is the position of
+	// each account in each symbol in the current session, nil until the
+	// first trade
+	fees       FeeSchedule
+	settlement map[settlementKey]*SettlementPosition
+
+	// latency delays the arrival of new orders, and arrivals are the orders
+	// in flight in arrival order
+	latency  LatencyConfig
+	arrivals []arrival
 }
 
 // NewMarketManager creates a new market manager
@@ -23,6 +95,7 @@ func NewMarketManager() *MarketManager {
 	return &MarketManager{
 		handler:    &DefaultMarketHandler{},
 		symbols:    make(map[uint32]*Symbol),
+		symbolIDs:  make(map[string]uint32),
 		orderBooks: make(map[uint32]*OrderBook),
 		orders:     make(map[uint64]*OrderNode),
 		matching:   false,
@@ -34,12 +107,24 @@ func NewMarketManagerWithHandler(handler MarketHandler) *MarketManager {
 	return &MarketManager{
 		handler:    handler,
 		symbols:    make(map[uint32]*Symbol),
+		symbolIDs:  make(map[string]uint32),
 		orderBooks: make(map[uint32]*OrderBook),
 		orders:     make(map[uint64]*OrderNode),
 		matching:   false,
 	}
 }
 
+// Handler returns the market event handler
+func (m *MarketManager) Handler() MarketHandler {
+	return m.handler
+}
+
+// SetHandler replaces the market event handler.
+// This is typically used to attach recorders after state has been recovered.
+func (m *MarketManager) SetHandler(handler MarketHandler) {
+	m.handler = handler
+}
+
 // Symbols returns all symbols
 func (m *MarketManager) Symbols() map[uint32]*Symbol {
 	return m.symbols
@@ -65,6 +150,25 @@ func (m *MarketManager) GetOrderBook(id uint32) *OrderBook {
 	return m.orderBooks[id]
 }
 
+// GetSymbolByName returns a symbol by name.
+// Names are expected to be unique; if several symbols share a name, the first one added is returned.
+func (m *MarketManager) GetSymbolByName(name string) *Symbol {
+	id, exists := m.symbolIDs[name]
+	if !exists {
+		return nil
+	}
+	return m.symbols[id]
+}
+
+// GetOrderBookByName returns an order book by symbol name
+func (m *MarketManager) GetOrderBookByName(name string) *OrderBook {
+	id, exists := m.symbolIDs[name]
+	if !exists {
+		return nil
+	}
+	return m.orderBooks[id]
+}
+
 // GetOrder returns an order by ID
 func (m *MarketManager) GetOrder(id uint64) *OrderNode {
 	return m.orders[id]
@@ -85,23 +189,54 @@ func (m *MarketManager) DisableMatching() {
 	m.matching = false
 }
 
+// PriceRule returns the rule used to price matched orders
+func (m *MarketManager) PriceRule() PriceRule {
+	return m.priceRule
+}
+
+// SetPriceRule sets the rule used to price matched orders.
+// The default is PriceRuleResting.
+func (m *MarketManager) SetPriceRule(rule PriceRule) {
+	m.priceRule = rule
+}
+
+// nextPriority returns the next arrival sequence number
+func (m *MarketManager) nextPriority() uint64 {
+	m.sequence++
+	return m.sequence
+}
+
+// enqueue assigns an order its queue position and arrival time
+func (m *MarketManager) enqueue(order *OrderNode) {
+	order.priority = m.nextPriority()
+	order.timestamp = m.now().UnixNano()
+}
+
 // AddSymbol adds a new symbol
 func (m *MarketManager) AddSymbol(symbol Symbol) ErrorCode {
+	defer m.operation()()
 	if _, exists := m.symbols[symbol.ID]; exists {
 		return ErrorSymbolDuplicate
 	}
 
 	m.symbols[symbol.ID] = &symbol
+	if _, exists := m.symbolIDs[symbol.Name]; !exists {
+		m.symbolIDs[symbol.Name] = symbol.ID
+	}
 	m.handler.OnAddSymbol(symbol)
 	return ErrorOK
 }
 
 // DeleteSymbol deletes a symbol
 func (m *MarketManager) DeleteSymbol(id uint32) ErrorCode {
+	defer m.operation()()
 	symbol, exists := m.symbols[id]
 	if !exists {
 		return ErrorSymbolNotFound
 	}
+	if m.isLeg(id) {
+		return ErrorSymbolInUse
+	}
 
 	// Delete associated order book first
 	if ob := m.orderBooks[id]; ob != nil {
@@ -109,12 +244,31 @@ func (m *MarketManager) DeleteSymbol(id uint32) ErrorCode {
 	}
 
 	delete(m.symbols, id)
+	delete(m.spreads, id)
+	if m.symbolIDs[symbol.Name] == id {
+		m.unindexSymbolName(symbol.Name)
+	}
 	m.handler.OnDeleteSymbol(*symbol)
 	return ErrorOK
 }
 
+// unindexSymbolName removes a name from the symbol name index, handing it
+// over to the remaining symbol with the same name and the lowest ID
+func (m *MarketManager) unindexSymbolName(name string) {
+	delete(m.symbolIDs, name)
+	for id, symbol := range m.symbols {
+		if symbol.Name != name {
+			continue
+		}
+		if current, exists := m.symbolIDs[name]; !exists || id < current {
+			m.symbolIDs[name] = id
+		}
+	}
+}
+
 // AddOrderBook adds a new order book for a symbol
 func (m *MarketManager) AddOrderBook(symbol Symbol) ErrorCode {
+	defer m.operation()()
 	if _, exists := m.orderBooks[symbol.ID]; exists {
 		return ErrorOrderBookDuplicate
 	}
@@ -128,18 +282,24 @@ func (m *MarketManager) AddOrderBook(symbol Symbol) ErrorCode {
 
 // DeleteOrderBook deletes an order book
 func (m *MarketManager) DeleteOrderBook(id uint32) ErrorCode {
+	defer m.operation()()
 	ob, exists := m.orderBooks[id]
 	if !exists {
 		return ErrorOrderBookNotFound
 	}
+	if m.isLeg(id) {
+		return ErrorSymbolInUse
+	}
 
-	// Cancel all orders in the order book
+	// Cancel all orders in the order book, in ID order so the notifications
+	// are deterministic
 	ordersToDelete := make([]*OrderNode, 0)
 	for _, order := range m.orders {
 		if order.SymbolID == id {
 			ordersToDelete = append(ordersToDelete, order)
 		}
 	}
+	sort.Slice(ordersToDelete, func(i, j int) bool { return ordersToDelete[i].ID < ordersToDelete[j].ID })
 	for _, order := range ordersToDelete {
 		m.DeleteOrder(order.ID)
 	}
@@ -153,6 +313,7 @@ func (m *MarketManager) DeleteOrderBook(id uint32) ErrorCode {
 // It bypasses normal quantity initialisation and adds the order exactly as provided.
 // This method is intended only for use during persistence recovery.
 func (m *MarketManager) RestoreOrder(order Order) ErrorCode {
+	defer m.operation()()
 	if order.ID == 0 {
 		return ErrorOrderIDInvalid
 	}
@@ -167,7 +328,10 @@ func (m *MarketManager) RestoreOrder(order Order) ErrorCode {
 	}
 
 	orderNode := NewOrderNode(order)
+	m.enqueue(orderNode)
 	m.orders[order.ID] = orderNode
+	m.observeOrderID(order.ID)
+	m.expose(orderNode)
 
 	ob.AddOrder(orderNode)
 	m.handler.OnAddOrder(order)
@@ -176,8 +340,27 @@ func (m *MarketManager) RestoreOrder(order Order) ErrorCode {
 	return ErrorOK
 }
 
-// AddOrder adds a new order
+// AddOrder adds a new order. With a latency model the order is only sent:
+// it arrives later, see SetLatency.
 func (m *MarketManager) AddOrder(order Order) ErrorCode {
+	if len(m.arrivals) != 0 {
+		m.ProcessArrivals()
+	}
+	if m.latency.Model != nil {
+		m.send(order)
+		return ErrorOK
+	}
+	return m.addOrder(order)
+}
+
+// addOrder adds a new order arriving at the matching engine
+func (m *MarketManager) addOrder(order Order) (code ErrorCode) {
+	defer m.operation()()
+	defer m.reject(&order, &code)
+	if latency := m.addOrderLatency; latency != nil {
+		defer latency.Since(time.Now())
+	}
+
 	// Validate order
 	if err := m.validateOrder(order); err != ErrorOK {
 		return err
@@ -194,12 +377,30 @@ func (m *MarketManager) AddOrder(order Order) ErrorCode {
 		return ErrorOrderBookNotFound
 	}
 
+	// Purge the stale orders of the book before the order can match them
+	m.sweepExpired(ob)
+
+	// Check the trading rules of the order book
+	if err := m.checkTradingRules(ob, order); err != ErrorOK {
+		return err
+	}
+	ob.config.applyIcebergVariance(&order)
+
+	// Check the exposure limits of the participant
+	if err := m.checkExposure(&order, nil); err != ErrorOK {
+		return err
+	}
+
 	// Create order node
 	orderNode := NewOrderNode(order)
+	m.enqueue(orderNode)
 	m.orders[order.ID] = orderNode
+	m.observeOrderID(order.ID)
+	m.expose(orderNode)
 
 	// Add order to the order book
 	ob.AddOrder(orderNode)
+	markTop(ob, orderNode)
 	m.handler.OnAddOrder(order)
 
 	// Update order book
@@ -207,14 +408,16 @@ func (m *MarketManager) AddOrder(order Order) ErrorCode {
 
 	// Match if enabled
 	if m.matching {
-		m.match(ob)
+		m.matchEntry(ob, orderNode)
 	}
 
 	return ErrorOK
 }
 
 // ReduceOrder reduces the quantity of an order
-func (m *MarketManager) ReduceOrder(id uint64, quantity uint64) ErrorCode {
+func (m *MarketManager) ReduceOrder(id uint64, quantity uint64) (code ErrorCode) {
+	defer m.operation()()
+	defer m.rejectID(id, &code)
 	orderNode, exists := m.orders[id]
 	if !exists {
 		return ErrorOrderNotFound
@@ -231,11 +434,14 @@ func (m *MarketManager) ReduceOrder(id uint64, quantity uint64) ErrorCode {
 
 	ob := m.orderBooks[orderNode.SymbolID]
 
+	m.recordAmendment(orderNode, AmendmentReduce, orderNode.Price, orderNode.Quantity, orderNode.LeavesQuantity-quantity)
+
 	// Calculate hidden and visible reduction
 	oldHidden := orderNode.HiddenQuantity()
 	oldVisible := orderNode.VisibleQuantity()
 
 	orderNode.LeavesQuantity -= quantity
+	m.reexpose(orderNode)
 
 	newHidden := orderNode.HiddenQuantity()
 	newVisible := orderNode.VisibleQuantity()
@@ -252,8 +458,13 @@ func (m *MarketManager) ReduceOrder(id uint64, quantity uint64) ErrorCode {
 	return ErrorOK
 }
 
-// ModifyOrder modifies an existing order
-func (m *MarketManager) ModifyOrder(id uint64, newPrice, newQuantity uint64) ErrorCode {
+// ModifyOrder modifies the price and total quantity of an existing order.
+// The executed quantity is kept: the leaves quantity becomes the new quantity
+// less the executed quantity, and an order amended down to its executed
+// quantity or below is canceled.
+func (m *MarketManager) ModifyOrder(id uint64, newPrice, newQuantity uint64) (code ErrorCode) {
+	defer m.operation()()
+	defer m.rejectID(id, &code)
 	orderNode, exists := m.orders[id]
 	if !exists {
 		return ErrorOrderNotFound
@@ -263,8 +474,25 @@ func (m *MarketManager) ModifyOrder(id uint64, newPrice, newQuantity uint64) Err
 		return ErrorOrderQuantityInvalid
 	}
 
+	if newQuantity <= orderNode.ExecutedQuantity {
+		// Cancel the order
+		return m.DeleteOrder(id)
+	}
+
 	ob := m.orderBooks[orderNode.SymbolID]
 
+	modified := orderNode.Order
+	modified.Price = newPrice
+	modified.Quantity = newQuantity
+	if err := m.checkTradingRules(ob, modified); err != ErrorOK {
+		return err
+	}
+	modified.LeavesQuantity = newQuantity - orderNode.ExecutedQuantity
+	if err := m.checkExposure(&modified, orderNode); err != ErrorOK {
+		return err
+	}
+	m.recordAmendment(orderNode, AmendmentModify, newPrice, newQuantity, newQuantity-orderNode.ExecutedQuantity)
+
 	// Remove from old level
 	m.updateLevel(ob, orderNode, UpdateDelete)
 	ob.DeleteOrder(orderNode)
@@ -272,11 +500,13 @@ func (m *MarketManager) ModifyOrder(id uint64, newPrice, newQuantity uint64) Err
 	// Update order
 	orderNode.Price = newPrice
 	orderNode.Quantity = newQuantity
-	orderNode.LeavesQuantity = newQuantity
-	orderNode.ExecutedQuantity = 0
+	orderNode.LeavesQuantity = newQuantity - orderNode.ExecutedQuantity
+	m.reexpose(orderNode)
+	m.enqueue(orderNode)
 
 	// Add to new level
 	ob.AddOrder(orderNode)
+	markTop(ob, orderNode)
 	m.handler.OnUpdateOrder(orderNode.Order)
 	m.updateLevel(ob, orderNode, UpdateAdd)
 
@@ -289,7 +519,9 @@ func (m *MarketManager) ModifyOrder(id uint64, newPrice, newQuantity uint64) Err
 }
 
 // MitigateOrder mitigates an order (in-flight mitigation)
-func (m *MarketManager) MitigateOrder(id uint64, newPrice, newQuantity uint64) ErrorCode {
+func (m *MarketManager) MitigateOrder(id uint64, newPrice, newQuantity uint64) (code ErrorCode) {
+	defer m.operation()()
+	defer m.rejectID(id, &code)
 	orderNode, exists := m.orders[id]
 	if !exists {
 		return ErrorOrderNotFound
@@ -302,6 +534,18 @@ func (m *MarketManager) MitigateOrder(id uint64, newPrice, newQuantity uint64) E
 		return m.DeleteOrder(id)
 	}
 
+	mitigated := orderNode.Order
+	mitigated.Price = newPrice
+	mitigated.Quantity = newQuantity
+	if err := m.checkTradingRules(ob, mitigated); err != ErrorOK {
+		return err
+	}
+	mitigated.LeavesQuantity = newQuantity - orderNode.ExecutedQuantity
+	if err := m.checkExposure(&mitigated, orderNode); err != ErrorOK {
+		return err
+	}
+	m.recordAmendment(orderNode, AmendmentMitigate, newPrice, newQuantity, newQuantity-orderNode.ExecutedQuantity)
+
 	// Remove from old level
 	m.updateLevel(ob, orderNode, UpdateDelete)
 	ob.DeleteOrder(orderNode)
@@ -310,9 +554,12 @@ func (m *MarketManager) MitigateOrder(id uint64, newPrice, newQuantity uint64) E
 	orderNode.Price = newPrice
 	orderNode.Quantity = newQuantity
 	orderNode.LeavesQuantity = newQuantity - orderNode.ExecutedQuantity
+	m.reexpose(orderNode)
+	m.enqueue(orderNode)
 
 	// Add to new level
 	ob.AddOrder(orderNode)
+	markTop(ob, orderNode)
 	m.handler.OnUpdateOrder(orderNode.Order)
 	m.updateLevel(ob, orderNode, UpdateAdd)
 
@@ -325,7 +572,9 @@ func (m *MarketManager) MitigateOrder(id uint64, newPrice, newQuantity uint64) E
 }
 
 // ReplaceOrder replaces an existing order with a new one
-func (m *MarketManager) ReplaceOrder(id uint64, newID uint64, newPrice, newQuantity uint64) ErrorCode {
+func (m *MarketManager) ReplaceOrder(id uint64, newID uint64, newPrice, newQuantity uint64) (code ErrorCode) {
+	defer m.operation()()
+	defer m.rejectID(id, &code)
 	orderNode, exists := m.orders[id]
 	if !exists {
 		return ErrorOrderNotFound
@@ -341,35 +590,60 @@ func (m *MarketManager) ReplaceOrder(id uint64, newID uint64, newPrice, newQuant
 
 	ob := m.orderBooks[orderNode.SymbolID]
 
+	replaced := orderNode.Order
+	replaced.Price = newPrice
+	replaced.Quantity = newQuantity
+	if err := m.checkTradingRules(ob, replaced); err != ErrorOK {
+		return err
+	}
+	replaced.LeavesQuantity = newQuantity
+	if err := m.checkExposure(&replaced, orderNode); err != ErrorOK {
+		return err
+	}
+	m.recordAmendment(orderNode, AmendmentReplace, newPrice, newQuantity, newQuantity)
+
 	// Remove old order
 	m.updateLevel(ob, orderNode, UpdateDelete)
 	ob.DeleteOrder(orderNode)
 	delete(m.orders, id)
+	m.unexpose(orderNode)
 	m.handler.OnDeleteOrder(orderNode.Order)
 
 	// Create new order
 	newOrder := Order{
-		ID:                 newID,
-		SymbolID:           orderNode.SymbolID,
-		Type:               orderNode.Type,
-		Side:               orderNode.Side,
-		Price:              newPrice,
-		StopPrice:          orderNode.StopPrice,
-		Quantity:           newQuantity,
-		ExecutedQuantity:   0,
-		LeavesQuantity:     newQuantity,
-		TimeInForce:        orderNode.TimeInForce,
-		MaxVisibleQuantity: orderNode.MaxVisibleQuantity,
-		Slippage:           orderNode.Slippage,
-		TrailingDistance:   orderNode.TrailingDistance,
-		TrailingStep:       orderNode.TrailingStep,
+		ID:                  newID,
+		SymbolID:            orderNode.SymbolID,
+		Type:                orderNode.Type,
+		Side:                orderNode.Side,
+		Price:               newPrice,
+		StopPrice:           orderNode.StopPrice,
+		Quantity:            newQuantity,
+		ExecutedQuantity:    0,
+		LeavesQuantity:      newQuantity,
+		TimeInForce:         orderNode.TimeInForce,
+		MinQuantity:         min(orderNode.MinQuantity, newQuantity),
+		MaxVisibleQuantity:  orderNode.MaxVisibleQuantity,
+		DisplayLowQuantity:  orderNode.DisplayLowQuantity,
+		DisplayHighQuantity: orderNode.DisplayHighQuantity,
+		Slippage:            orderNode.Slippage,
+		TrailingDistance:    orderNode.TrailingDistance,
+		TrailingStep:        orderNode.TrailingStep,
+		ParticipantID:       orderNode.ParticipantID,
+		AccountID:           orderNode.AccountID,
+		Quote:               orderNode.Quote,
 	}
 
 	newOrderNode := NewOrderNode(newOrder)
+	newOrderNode.amendments = orderNode.amendments
+	m.enqueue(newOrderNode)
 	m.orders[newID] = newOrderNode
+	m.observeOrderID(newID)
+	m.expose(newOrderNode)
+	m.tagReplacement(orderNode, newOrderNode)
 
 	// Add new order
 	ob.AddOrder(newOrderNode)
+	markTop(ob, newOrderNode)
 	m.handler.OnAddOrder(newOrder)
 	m.updateLevel(ob, newOrderNode, UpdateAdd)
 
@@ -382,7 +656,9 @@ func (m *MarketManager) ReplaceOrder(id uint64, newID uint64, newPrice, newQuant
 }
 
 // DeleteOrder deletes an order
-func (m *MarketManager) DeleteOrder(id uint64) ErrorCode {
+func (m *MarketManager) DeleteOrder(id uint64) (code ErrorCode) {
+	defer m.operation()()
+	defer m.rejectID(id, &code)
 	orderNode, exists := m.orders[id]
 	if !exists {
 		return ErrorOrderNotFound
@@ -394,13 +670,16 @@ func (m *MarketManager) DeleteOrder(id uint64) ErrorCode {
 	m.updateLevel(ob, orderNode, UpdateDelete)
 	ob.DeleteOrder(orderNode)
 	delete(m.orders, id)
+	m.unexpose(orderNode)
 	m.handler.OnDeleteOrder(orderNode.Order)
 
 	return ErrorOK
 }
 
 // ExecuteOrder executes a trade between two orders
-func (m *MarketManager) ExecuteOrder(id uint64, quantity uint64) ErrorCode {
+func (m *MarketManager) ExecuteOrder(id uint64, quantity uint64) (code ErrorCode) {
+	defer m.operation()()
+	defer m.rejectID(id, &code)
 	orderNode, exists := m.orders[id]
 	if !exists {
 		return ErrorOrderNotFound
@@ -414,7 +693,9 @@ func (m *MarketManager) ExecuteOrder(id uint64, quantity uint64) ErrorCode {
 }
 
 // ExecuteOrderWithPrice executes a trade at a specific price
-func (m *MarketManager) ExecuteOrderWithPrice(id uint64, price, quantity uint64) ErrorCode {
+func (m *MarketManager) ExecuteOrderWithPrice(id uint64, price, quantity uint64) (code ErrorCode) {
+	defer m.operation()()
+	defer m.rejectID(id, &code)
 	orderNode, exists := m.orders[id]
 	if !exists {
 		return ErrorOrderNotFound
@@ -435,13 +716,18 @@ func (m *MarketManager) executeOrder(orderNode *OrderNode, price, quantity uint6
 	oldHidden := orderNode.HiddenQuantity()
 	oldVisible := orderNode.VisibleQuantity()
 
-	// Update order
+	// Update order, refreshing a randomized iceberg display executed in full
 	orderNode.ExecutedQuantity += quantity
 	orderNode.LeavesQuantity -= quantity
+	if quantity >= oldVisible && oldHidden > 0 && orderNode.DisplayHighQuantity != 0 {
+		m.refreshDisplay(orderNode)
+	}
 
 	newHidden := orderNode.HiddenQuantity()
 	newVisible := orderNode.VisibleQuantity()
 
+	// A refresh may grow the display; the reductions then wrap around and
+	// still add up in the level volumes
 	hiddenReduction := oldHidden - newHidden
 	visibleReduction := oldVisible - newVisible
 
@@ -456,8 +742,10 @@ func (m *MarketManager) executeOrder(orderNode *OrderNode, price, quantity uint6
 		m.updateLevel(ob, orderNode, UpdateDelete)
 		ob.DeleteOrder(orderNode)
 		delete(m.orders, orderNode.ID)
+		m.unexpose(orderNode)
 		m.handler.OnDeleteOrder(orderNode.Order)
 	} else {
+		m.reexpose(orderNode)
 		m.handler.OnUpdateOrder(orderNode.Order)
 		m.updateLevel(ob, orderNode, UpdateUpdate)
 	}
@@ -467,6 +755,7 @@ func (m *MarketManager) executeOrder(orderNode *OrderNode, price, quantity uint6
 
 // Match performs order matching for an order book
 func (m *MarketManager) Match(symbolID uint32) ErrorCode {
+	defer m.operation()()
 	ob, exists := m.orderBooks[symbolID]
 	if !exists {
 		return ErrorOrderBookNotFound
@@ -476,6 +765,22 @@ func (m *MarketManager) Match(symbolID uint32) ErrorCode {
 	return ErrorOK
 }
 
+// Uncross executes the crossed orders of an order book that was left crossed
+// while automatic matching was disabled. Orders are matched in price-time
+// priority using the configured price rule until the book is no longer crossed.
+func (m *MarketManager) Uncross(symbolID uint32) ErrorCode {
+	defer m.operation()()
+	ob, exists := m.orderBooks[symbolID]
+	if !exists {
+		return ErrorOrderBookNotFound
+	}
+
+	if ob.IsCrossed() {
+		m.match(ob)
+	}
+	return ErrorOK
+}
+
 // match performs matching for an order book
 func (m *MarketManager) match(ob *OrderBook) {
 	// Match limit orders
@@ -487,13 +792,20 @@ func (m *MarketManager) match(ob *OrderBook) {
 			break
 		}
 
-		// Get the orders at the best levels
-		bidOrder := ob.bestBid.OrderList.Front()
-		askOrder := ob.bestAsk.OrderList.Front()
+		// Get the first orders at the best levels allowed to trade
+		bidOrder, askOrder := matchPair(ob)
 
 		if bidOrder == nil || askOrder == nil {
 			break
 		}
+		if !m.canTrade(ob, bidOrder, askOrder) {
+			break
+		}
+
+		if ob.config.Allocation == AllocationProRata {
+			m.matchProRata(ob, bidOrder, askOrder)
+			continue
+		}
 
 		// Determine execution quantity
 		quantity := bidOrder.LeavesQuantity
@@ -501,25 +813,47 @@ func (m *MarketManager) match(ob *OrderBook) {
 			quantity = askOrder.LeavesQuantity
 		}
 
-		// Determine execution price (price-time priority: earlier order's price)
-		price := askOrder.Price
-
-		// Execute both sides
-		m.executeOrder(bidOrder, price, quantity)
-		m.executeOrder(askOrder, price, quantity)
+		m.trade(ob, bidOrder, askOrder, quantity)
 	}
+	m.matched()
 
 	// TODO: Stop order activation
 	// When market price moves through stop prices, stop orders should be activated:
 	// - Buy stop orders activate when ask price >= stop price
 	// - Sell stop orders activate when bid price <= stop price
 	// This is left as a future enhancement as it requires additional price tracking.
+	// Triggered stops should enter the book as SymbolConfig.TriggerStop
+	// returns them, as limit orders within the price protection of the book.
 
 	// TODO: Trailing stop order activation
 	// Trailing stops need to track the market and update stop prices accordingly.
 	// This is left as a future enhancement as it requires price monitoring.
 }
 
+// trade executes quantity between a bid and an ask at the price of the
+// price rule
+func (m *MarketManager) trade(ob *OrderBook, bidOrder, askOrder *OrderNode, quantity uint64) {
+	price := m.tradePrice(bidOrder, askOrder)
+
+	trade := Trade{
+		SymbolID:    ob.symbol.ID,
+		BuyOrderID:  bidOrder.ID,
+		SellOrderID: askOrder.ID,
+		Price:       price,
+		Quantity:    quantity,
+		Aggressor:   Aggressor(bidOrder, askOrder),
+	}
+
+	// Execute both sides
+	m.executeOrder(bidOrder, price, quantity)
+	m.executeOrder(askOrder, price, quantity)
+	ob.session.record(price, quantity)
+	m.recordLiquidity(bidOrder, askOrder, price, quantity)
+	m.recordSettlement(ob.symbol.ID, bidOrder, askOrder, price, quantity)
+	m.handler.OnTrade(trade)
+	m.tradeLegs(trade)
+}
+
 // validateOrder validates an order
 func (m *MarketManager) validateOrder(order Order) ErrorCode {
 	if order.ID == 0 {
@@ -530,6 +864,14 @@ func (m *MarketManager) validateOrder(order Order) ErrorCode {
 		return ErrorOrderQuantityInvalid
 	}
 
+	if order.DisplayHighQuantity != 0 && (order.DisplayLowQuantity == 0 || order.DisplayLowQuantity > order.DisplayHighQuantity) {
+		return ErrorOrderParameterInvalid
+	}
+
+	if order.MinQuantity > order.Quantity {
+		return ErrorOrderQuantityInvalid
+	}
+
 	// Validate order type specific requirements
 	switch order.Type {
 	case OrderTypeLimit:
@@ -545,21 +887,32 @@ func (m *MarketManager) validateOrder(order Order) ErrorCode {
 			return ErrorOrderParameterInvalid
 		}
 	case OrderTypeTrailingStop:
-		if order.TrailingDistance == 0 {
-			return ErrorOrderParameterInvalid
-		}
+		return checkTrailing(&order)
 	case OrderTypeTrailingStopLimit:
-		if order.Price == 0 || order.TrailingDistance == 0 {
+		if order.Price == 0 {
 			return ErrorOrderParameterInvalid
 		}
+		return checkTrailing(&order)
 	}
 
 	return ErrorOK
 }
 
+// checkTradingRules checks an order against the configuration of its order book
+func (m *MarketManager) checkTradingRules(ob *OrderBook, order Order) ErrorCode {
+	if m.halted || ob.halted {
+		return ErrorTradingHalted
+	}
+	if !ob.config.Schedule.IsAlwaysOpen() && !ob.config.Schedule.IsOpen(m.now()) {
+		return ErrorMarketClosed
+	}
+	return ob.config.checkOrder(&order)
+}
+
 // updateLevel notifies the handler about level updates
 func (m *MarketManager) updateLevel(ob *OrderBook, order *OrderNode, updateType UpdateType) {
 	if order.Level == nil {
+		m.updateCrossed(ob)
 		return
 	}
 
@@ -586,5 +939,20 @@ func (m *MarketManager) updateLevel(ob *OrderBook, order *OrderNode, updateType
 		m.handler.OnDeleteLevel(ob, level, top)
 	}
 
-	m.handler.OnUpdateOrderBook(ob, top)
+	m.updateOrderBook(ob, top)
+	m.updateCrossed(ob)
+}
+
+// updateCrossed tracks the crossed state of an order book and notifies the
+// handler when it becomes crossed. A crossed book is expected while an
+// incoming order is being matched, so no event is raised with matching enabled.
+func (m *MarketManager) updateCrossed(ob *OrderBook) {
+	if !ob.IsCrossed() {
+		ob.crossed = false
+		return
+	}
+	if !ob.crossed && !m.matching {
+		ob.crossed = true
+		m.handler.OnBookCrossed(ob)
+	}
 }
@@ -2,6 +2,7 @@ package matching
 
 import (
 	"testing"
+	"time"
 )
 
 func TestMarketManager_AddSymbol(t *testing.T) {
@@ -53,6 +54,52 @@ func TestMarketManager_DeleteSymbol(t *testing.T) {
 	}
 }
 
+func TestMarketManager_GetByName(t *testing.T) {
+	manager := NewMarketManager()
+	
+	symbol := NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+	
+	s := manager.GetSymbolByName("AAPL")
+	if s == nil || s.ID != 1 {
+		t.Fatalf("Expected symbol 1, got %v", s)
+	}
+	ob := manager.GetOrderBookByName("AAPL")
+	if ob == nil || ob.Symbol().ID != 1 {
+		t.Fatalf("Expected order book of symbol 1, got %v", ob)
+	}
+	if manager.GetSymbolByName("MSFT") != nil {
+		t.Error("Expected unknown symbol to be nil")
+	}
+	if manager.GetOrderBookByName("MSFT") != nil {
+		t.Error("Expected unknown order book to be nil")
+	}
+	
+	// Symbol without an order book
+	manager.AddSymbol(NewSymbol(2, "MSFT"))
+	if manager.GetSymbolByName("MSFT") == nil {
+		t.Error("Expected symbol MSFT to exist")
+	}
+	if manager.GetOrderBookByName("MSFT") != nil {
+		t.Error("Expected no order book for MSFT")
+	}
+	
+	// Duplicate names resolve to the first symbol until it is deleted
+	manager.AddSymbol(NewSymbol(3, "AAPL"))
+	if s := manager.GetSymbolByName("AAPL"); s.ID != 1 {
+		t.Errorf("Expected symbol 1, got %d", s.ID)
+	}
+	manager.DeleteSymbol(1)
+	if s := manager.GetSymbolByName("AAPL"); s == nil || s.ID != 3 {
+		t.Errorf("Expected symbol 3, got %v", s)
+	}
+	manager.DeleteSymbol(3)
+	if manager.GetSymbolByName("AAPL") != nil {
+		t.Error("Expected deleted symbol to be nil")
+	}
+}
+
 func TestMarketManager_AddOrderBook(t *testing.T) {
 	manager := NewMarketManager()
 	
@@ -321,6 +368,49 @@ func TestMarketManager_ModifyOrder(t *testing.T) {
 	}
 }
 
+func TestMarketManager_ModifyPartiallyFilledOrder(t *testing.T) {
+	manager := NewMarketManager()
+	manager.EnableMatching()
+
+	symbol := NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 100))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideSell, 10000, 40))
+
+	// Amending down keeps the executed quantity
+	if err := manager.ModifyOrder(1, 9900, 70); err != ErrorOK {
+		t.Fatalf("Expected ErrorOK, got %s", err)
+	}
+	o := manager.GetOrder(1)
+	if o.Quantity != 70 || o.ExecutedQuantity != 40 || o.LeavesQuantity != 30 {
+		t.Errorf("Expected quantity 70, executed 40, leaves 30, got %d, %d, %d", o.Quantity, o.ExecutedQuantity, o.LeavesQuantity)
+	}
+	if bid := manager.GetOrderBook(1).GetBid(9900); bid == nil || bid.TotalVolume != 30 {
+		t.Errorf("Expected 30 bid at 9900, got %+v", bid)
+	}
+
+	// Amending up adds to the leaves quantity
+	if err := manager.ModifyOrder(1, 9900, 120); err != ErrorOK {
+		t.Fatalf("Expected ErrorOK, got %s", err)
+	}
+	if o := manager.GetOrder(1); o.ExecutedQuantity != 40 || o.LeavesQuantity != 80 {
+		t.Errorf("Expected executed 40, leaves 80, got %d, %d", o.ExecutedQuantity, o.LeavesQuantity)
+	}
+
+	// Amending to the executed quantity or below cancels the order
+	if err := manager.ModifyOrder(1, 9900, 40); err != ErrorOK {
+		t.Fatalf("Expected ErrorOK, got %s", err)
+	}
+	if manager.GetOrder(1) != nil {
+		t.Error("Expected order to be canceled")
+	}
+	if !manager.GetOrderBook(1).Bids().Empty() {
+		t.Error("Expected no bids left")
+	}
+}
+
 func TestMarketManager_ReplaceOrder(t *testing.T) {
 	manager := NewMarketManager()
 	
@@ -698,3 +788,163 @@ func TestOrderBook_Spread(t *testing.T) {
 		t.Errorf("Expected mid price 10050, got %d", ob.GetMidPrice())
 	}
 }
+
+type crossedMarketHandler struct {
+	DefaultMarketHandler
+	crossed int
+}
+
+func (h *crossedMarketHandler) OnBookCrossed(orderBook *OrderBook) {
+	h.crossed++
+}
+
+func TestOrderBook_Crossed(t *testing.T) {
+	handler := &crossedMarketHandler{}
+	manager := NewMarketManagerWithHandler(handler)
+
+	symbol := NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+	ob := manager.GetOrderBook(1)
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 10))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideSell, 10100, 10))
+	if ob.IsCrossed() || handler.crossed != 0 {
+		t.Fatal("Expected book not to be crossed")
+	}
+
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideBuy, 10200, 5))
+	if !ob.IsCrossed() {
+		t.Fatal("Expected book to be crossed")
+	}
+	if handler.crossed != 1 {
+		t.Errorf("Expected 1 crossed event, got %d", handler.crossed)
+	}
+
+	// Further orders on a crossed book do not raise the event again
+	manager.AddOrder(*NewLimitOrder(4, 1, OrderSideBuy, 10300, 5))
+	if handler.crossed != 1 {
+		t.Errorf("Expected 1 crossed event, got %d", handler.crossed)
+	}
+
+	if err := manager.Uncross(1); err != ErrorOK {
+		t.Fatalf("Expected OK, got %s", err)
+	}
+	if ob.IsCrossed() {
+		t.Error("Expected book to be uncrossed")
+	}
+	if manager.GetOrder(2) != nil {
+		t.Error("Expected sell order to be filled")
+	}
+	if o := manager.GetOrder(1); o == nil || o.LeavesQuantity != 10 {
+		t.Error("Expected original bid to be untouched")
+	}
+
+	// The event is raised again the next time the book crosses
+	manager.AddOrder(*NewLimitOrder(5, 1, OrderSideSell, 9900, 5))
+	if handler.crossed != 2 {
+		t.Errorf("Expected 2 crossed events, got %d", handler.crossed)
+	}
+
+	if err := manager.Uncross(2); err != ErrorOrderBookNotFound {
+		t.Errorf("Expected ORDER_BOOK_NOT_FOUND, got %s", err)
+	}
+}
+
+func TestOrderBook_Crossed_MatchingEnabled(t *testing.T) {
+	handler := &crossedMarketHandler{}
+	manager := NewMarketManagerWithHandler(handler)
+	manager.EnableMatching()
+
+	symbol := NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideSell, 10000, 10))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideBuy, 10100, 5))
+	if handler.crossed != 0 {
+		t.Errorf("Expected no crossed events, got %d", handler.crossed)
+	}
+}
+
+func TestMarketManager_LatencyTracking(t *testing.T) {
+	manager := NewMarketManager()
+	manager.EnableMatching()
+
+	symbol := NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideSell, 10000, 10))
+	if manager.Statistics().AddOrderLatency.Count != 0 {
+		t.Error("Expected no latency samples while tracking is disabled")
+	}
+
+	manager.EnableLatencyTracking()
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideBuy, 10000, 5))
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideBuy, 9000, 5))
+	if got := manager.Statistics().AddOrderLatency.Count; got != 2 {
+		t.Errorf("Expected 2 latency samples, got %d", got)
+	}
+
+	manager.DisableLatencyTracking()
+	if manager.IsLatencyTrackingEnabled() {
+		t.Error("Expected latency tracking to be disabled")
+	}
+}
+
+func TestLevelNode_ForEachOrder(t *testing.T) {
+	manager := NewMarketManager()
+	symbol := NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+
+	now := time.Unix(1700000000, 0)
+	manager.SetClock(func() time.Time { return now })
+
+	first := *NewLimitOrder(1, 1, OrderSideBuy, 10000, 100)
+	first.ParticipantID = 7
+	manager.AddOrder(first)
+	now = now.Add(time.Second)
+	iceberg := *NewLimitOrder(2, 1, OrderSideBuy, 10000, 500)
+	iceberg.MaxVisibleQuantity = 50
+	manager.AddOrder(iceberg)
+	now = now.Add(time.Second)
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideBuy, 10000, 200))
+
+	level := manager.GetOrderBook(1).GetBid(10000)
+	var views []OrderView
+	level.ForEachOrder(func(v OrderView) bool {
+		views = append(views, v)
+		return true
+	})
+	if len(views) != 3 {
+		t.Fatalf("Expected 3 orders, got %d", len(views))
+	}
+	for i, v := range views {
+		if v.ID != uint64(i+1) {
+			t.Errorf("Expected order %d at position %d, got %d", i+1, i, v.ID)
+		}
+		if v.Timestamp != time.Unix(1700000000+int64(i), 0).UnixNano() {
+			t.Errorf("Order %d: unexpected timestamp %d", v.ID, v.Timestamp)
+		}
+	}
+	if views[0].ParticipantID != 7 || views[0].LeavesQuantity != 100 {
+		t.Errorf("Expected order 1 with participant 7 and 100 leaves, got %+v", views[0])
+	}
+	if views[1].LeavesQuantity != 500 || views[1].VisibleQuantity != 50 {
+		t.Errorf("Expected iceberg with 500 leaves and 50 visible, got %+v", views[1])
+	}
+	if views[0].Priority >= views[1].Priority {
+		t.Errorf("Expected increasing priorities, got %d and %d", views[0].Priority, views[1].Priority)
+	}
+
+	count := 0
+	level.ForEachOrder(func(v OrderView) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Errorf("Expected iteration to stop after 1 order, got %d", count)
+	}
+}
@@ -0,0 +1,126 @@
+package matching
+
+// matchEntry matches the book after order entered it. An order with a
+// minimum quantity only trades on entry if that much is available at once;
+// otherwise IOC and FOK orders are cancelled and others rest.
+func (m *MarketManager) matchEntry(ob *OrderBook, order *OrderNode) {
+	if order.MinQuantity == 0 {
+		m.match(ob)
+		return
+	}
+
+	id := order.ID
+	required := min(order.MinQuantity, order.LeavesQuantity)
+	available := availableQuantity(ob, order)
+	if available < required {
+		if order.IsIOC() || order.IsFOK() {
+			m.DeleteOrder(id)
+		}
+		m.match(ob)
+		return
+	}
+	order.minMet = true
+	m.match(ob)
+	if m.orders[id] == order {
+		order.minMet = false
+	}
+}
+
+// availableQuantity returns the contra quantity order can execute at once,
+// counting the contra orders whose own minimum allows the fill. It is 0 if
+// order does not cross the book.
+func availableQuantity(ob *OrderBook, order *OrderNode) uint64 {
+	levels := ob.asks
+	crosses := func(price uint64) bool { return order.IsMarket() || price <= order.Price }
+	if !order.IsBuy() {
+		levels = ob.bids
+		crosses = func(price uint64) bool { return order.IsMarket() || price >= order.Price }
+	}
+
+	var available uint64
+	levels.ForEach(func(level *LevelNode) bool {
+		if !crosses(level.Price) {
+			return false
+		}
+		for contra := level.OrderList.Front(); contra != nil; contra = contra.Next {
+			if contra.allowsFill(min(contra.LeavesQuantity, order.LeavesQuantity)) {
+				available += contra.LeavesQuantity
+			}
+		}
+		return available < order.LeavesQuantity
+	})
+	return available
+}
+
+// maxMatchScan is the number of crossed orders of each side matchPair
+// searches past blocked ones, so that books holding many minimum quantity
+// orders do not match in quadratic time
+const maxMatchScan = 64
+
+// matchPair returns the first bid and ask in price-time priority among the
+// crossed levels whose minimum quantities allow them to trade with each
+// other, or nil if there is none. Orders blocked by a minimum quantity are
+// skipped, across levels, within the first maxMatchScan crossed orders of
+// each side.
+func matchPair(ob *OrderBook) (*OrderNode, *OrderNode) {
+	bid, ask := ob.bestBid.OrderList.Front(), ob.bestAsk.OrderList.Front()
+	if bid == nil || ask == nil || pairAllowed(bid, ask) {
+		return bid, ask
+	}
+
+	var bidBuf, askBuf [maxMatchScan]*OrderNode
+	bids := crossedOrders(ob.bids, bidBuf[:0], func(price uint64) bool { return price >= ob.bestAsk.Price })
+	asks := crossedOrders(ob.asks, askBuf[:0], func(price uint64) bool { return price <= ob.bestBid.Price })
+	for _, bid := range bids {
+		for _, ask := range asks {
+			if ask.Price > bid.Price {
+				break
+			}
+			if pairAllowed(bid, ask) {
+				return bid, ask
+			}
+		}
+	}
+	return nil, nil
+}
+
+// crossedOrders appends to orders, up to its capacity, the orders of the
+// levels in priority order whose price crosses
+func crossedOrders(levels *AVLTree, orders []*OrderNode, crosses func(price uint64) bool) []*OrderNode {
+	levels.ForEach(func(level *LevelNode) bool {
+		if !crosses(level.Price) {
+			return false
+		}
+		for order := level.OrderList.Front(); order != nil && len(orders) < cap(orders); order = order.Next {
+			orders = append(orders, order)
+		}
+		return len(orders) < cap(orders)
+	})
+	return orders
+}
+
+// pairAllowed returns true if the minimum quantities of a bid and an ask
+// allow them to trade with each other
+func pairAllowed(bid, ask *OrderNode) bool {
+	quantity := min(bid.LeavesQuantity, ask.LeavesQuantity)
+	return bid.allowsFill(quantity) && ask.allowsFill(quantity)
+}
+
+// allowsFill returns true if the minimum quantity of the order allows a fill
+// of quantity
+func (on *OrderNode) allowsFill(quantity uint64) bool {
+	return on.MinQuantity == 0 || on.minMet || quantity >= min(on.MinQuantity, on.LeavesQuantity)
+}
+
+// CanMatch returns true if the book is crossed and its crossed levels hold a
+// bid and an ask allowed to trade with each other, as matchPair finds them.
+// Matching leaves such a book only while it is disabled; a book can stay
+// crossed with matching enabled when minimum quantities or spread leg prices
+// keep the orders apart.
+func (ob *OrderBook) CanMatch() bool {
+	if !ob.IsCrossed() {
+		return false
+	}
+	bid, ask := matchPair(ob)
+	return bid != nil && ask != nil && (ob.manager == nil || ob.manager.canTrade(ob, bid, ask))
+}
@@ -0,0 +1,170 @@
+package matching
+
+import "testing"
+
+// minQuantityOrder creates a limit order with a minimum execution quantity
+func minQuantityOrder(id uint64, side OrderSide, price, quantity, minQuantity uint64) Order {
+	order := NewLimitOrder(id, 1, side, price, quantity)
+	order.MinQuantity = minQuantity
+	return *order
+}
+
+func TestMarketManager_MinQuantity(t *testing.T) {
+	handler := &corporateHandler{}
+	manager := newConfigManager(handler)
+	manager.EnableMatching()
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideSell, 10000, 30))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideSell, 10100, 40))
+
+	// 70 shares are available up to 10100, short of the minimum of 100: the
+	// order rests without trading
+	if err := manager.AddOrder(minQuantityOrder(3, OrderSideBuy, 10100, 200, 100)); err != ErrorOK {
+		t.Fatalf("AddOrder failed: %s", err)
+	}
+	if o := manager.GetOrder(3); o == nil || o.ExecutedQuantity != 0 {
+		t.Fatalf("Expected order 3 to rest unfilled, got %+v", o)
+	}
+
+	// An IOC order whose minimum cannot be met is cancelled
+	ioc := minQuantityOrder(4, OrderSideBuy, 10100, 200, 100)
+	ioc.TimeInForce = OrderTimeInForceIOC
+	manager.AddOrder(ioc)
+	if manager.GetOrder(4) != nil || handler.deleted[len(handler.deleted)-1] != 4 {
+		t.Error("Expected the IOC order to be cancelled")
+	}
+
+	// Once the minimum is available, the order sweeps the levels with fills
+	// smaller than its minimum
+	manager.DeleteOrder(3)
+	manager.AddOrder(*NewLimitOrder(5, 1, OrderSideSell, 10100, 50))
+	manager.AddOrder(minQuantityOrder(6, OrderSideBuy, 10100, 100, 100))
+	if manager.GetOrder(6) != nil {
+		t.Error("Expected order 6 to be filled")
+	}
+	if manager.GetOrder(1) != nil || manager.GetOrder(2) != nil || manager.GetOrder(5).LeavesQuantity != 20 {
+		t.Error("Expected orders 1 and 2 filled and 30 of order 5")
+	}
+}
+
+func TestMarketManager_MinQuantityResting(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.EnableMatching()
+
+	// A resting order only trades with contra orders of at least its minimum
+	manager.AddOrder(minQuantityOrder(1, OrderSideBuy, 10000, 100, 50))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideBuy, 10000, 10))
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideSell, 10000, 20))
+	if o := manager.GetOrder(1); o.ExecutedQuantity != 0 {
+		t.Errorf("Expected order 1 not to trade 20 shares, got %d", o.ExecutedQuantity)
+	}
+	if manager.GetOrder(2) != nil {
+		t.Error("Expected order 2 to trade ahead of order 1")
+	}
+	// The 10 shares left of order 3 keep the book crossed against order 1
+	if o := manager.GetOrder(3); o == nil || o.LeavesQuantity != 10 {
+		t.Fatalf("Expected 10 shares of order 3 left, got %+v", o)
+	}
+
+	manager.AddOrder(*NewLimitOrder(4, 1, OrderSideSell, 10000, 60))
+	if o := manager.GetOrder(1); o == nil || o.ExecutedQuantity != 60 {
+		t.Errorf("Expected order 1 to trade 60 shares, got %+v", o)
+	}
+
+	// The minimum is capped by the leaves quantity
+	manager.AddOrder(*NewLimitOrder(5, 1, OrderSideSell, 10000, 40))
+	if manager.GetOrder(1) != nil {
+		t.Error("Expected the last 40 shares of order 1 to trade")
+	}
+
+	if err := manager.AddOrder(minQuantityOrder(6, OrderSideBuy, 10000, 10, 20)); err != ErrorOrderQuantityInvalid {
+		t.Errorf("Expected ErrorOrderQuantityInvalid, got %s", err)
+	}
+}
+
+func TestMarketManager_MinQuantityReplace(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.EnableMatching()
+
+	// A replacement keeps the minimum, capped by its quantity
+	manager.AddOrder(minQuantityOrder(1, OrderSideBuy, 10000, 100, 50))
+	if err := manager.ReplaceOrder(1, 2, 10000, 80); err != ErrorOK {
+		t.Fatalf("ReplaceOrder failed: %s", err)
+	}
+	if o := manager.GetOrder(2); o == nil || o.MinQuantity != 50 {
+		t.Fatalf("Expected order 2 to keep the minimum of 50, got %+v", o)
+	}
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideSell, 10000, 20))
+	if o := manager.GetOrder(2); o.ExecutedQuantity != 0 {
+		t.Errorf("Expected order 2 not to trade 20 shares, got %d", o.ExecutedQuantity)
+	}
+	manager.DeleteOrder(3)
+
+	if err := manager.ReplaceOrder(2, 4, 10000, 30); err != ErrorOK {
+		t.Fatalf("ReplaceOrder failed: %s", err)
+	}
+	if o := manager.GetOrder(4); o == nil || o.MinQuantity != 30 {
+		t.Errorf("Expected the minimum of order 4 capped at 30, got %+v", o)
+	}
+}
+
+func TestOrderBook_CanMatch(t *testing.T) {
+	manager := newConfigManager(&corporateHandler{})
+	ob := manager.GetOrderBook(1)
+
+	// A minimum quantity keeps the book crossed with matching enabled
+	manager.EnableMatching()
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideSell, 10000, 30))
+	manager.AddOrder(minQuantityOrder(2, OrderSideBuy, 10100, 200, 100))
+	if !ob.IsCrossed() || ob.CanMatch() {
+		t.Errorf("Expected a crossed book that cannot match, crossed %v", ob.IsCrossed())
+	}
+
+	// A crossing order entered while matching is disabled can match
+	manager.DisableMatching()
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideBuy, 10100, 10))
+	if !ob.CanMatch() {
+		t.Error("Expected the book to be able to match")
+	}
+	manager.EnableMatching()
+	manager.Uncross(1)
+	if ob.CanMatch() {
+		t.Error("Expected the uncrossed book not to be able to match")
+	}
+}
+
+func TestMarketManager_MinQuantityBlockedLevel(t *testing.T) {
+	handler := &corporateHandler{}
+	manager := newConfigManager(handler)
+	manager.EnableMatching()
+
+	// The best bid rests crossed, blocked by its minimum
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideSell, 9900, 10))
+	manager.AddOrder(minQuantityOrder(2, OrderSideBuy, 10100, 100, 100))
+	if o := manager.GetOrder(2); o == nil || o.ExecutedQuantity != 0 {
+		t.Fatalf("Expected order 2 to rest unfilled, got %+v", o)
+	}
+
+	// A marketable bid at a worse price still trades with the ask
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideBuy, 10000, 10))
+	if manager.GetOrder(1) != nil || manager.GetOrder(3) != nil {
+		t.Errorf("Expected orders 1 and 3 to trade, got %+v and %+v", manager.GetOrder(1), manager.GetOrder(3))
+	}
+	if o := manager.GetOrder(2); o == nil || o.ExecutedQuantity != 0 {
+		t.Errorf("Expected order 2 to keep resting, got %+v", o)
+	}
+
+	// Blocked asks are skipped across levels as well
+	manager.AddOrder(*NewLimitOrder(4, 1, OrderSideBuy, 9900, 30))
+	manager.AddOrder(minQuantityOrder(5, OrderSideSell, 9800, 50, 50))
+	if o := manager.GetOrder(5); o == nil || o.ExecutedQuantity != 0 {
+		t.Fatalf("Expected order 5 to rest unfilled, got %+v", o)
+	}
+	manager.AddOrder(*NewLimitOrder(6, 1, OrderSideSell, 9900, 30))
+	if manager.GetOrder(4) != nil || manager.GetOrder(6) != nil {
+		t.Errorf("Expected orders 4 and 6 to trade, got %+v and %+v", manager.GetOrder(4), manager.GetOrder(6))
+	}
+	if manager.GetOrder(2) == nil || manager.GetOrder(5) == nil {
+		t.Error("Expected the blocked orders 2 and 5 to keep resting")
+	}
+}
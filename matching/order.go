@@ -77,6 +77,8 @@ const (
 	OrderTimeInForceFOK
 	// OrderTimeInForceAON is All-Or-None
 	OrderTimeInForceAON
+	// OrderTimeInForceDay is valid until the end of the trading session
+	OrderTimeInForceDay
 )
 
 // String returns the string representation of an OrderTimeInForce
@@ -90,6 +92,8 @@ func (tif OrderTimeInForce) String() string {
 		return "FOK"
 	case OrderTimeInForceAON:
 		return "AON"
+	case OrderTimeInForceDay:
+		return "DAY"
 	default:
 		return "UNKNOWN"
 	}
@@ -126,22 +130,48 @@ type Order struct {
 	// TimeInForce specifies how long the order remains active
 	TimeInForce OrderTimeInForce
 
+	// MinQuantity is the minimum execution quantity (MEQ), 0 for none. On
+	// entry the order only trades if it can execute at least MinQuantity at
+	// once; once resting, none of its fills may be smaller, unless its leaves
+	// quantity is.
+	MinQuantity uint64
+
 	// MaxVisibleQuantity allows for iceberg/hidden orders
 	// >= LeavesQuantity: Regular order
 	// == 0: Hidden order
 	// < LeavesQuantity: Iceberg order
 	MaxVisibleQuantity uint64
 
+	// DisplayLowQuantity and DisplayHighQuantity randomize the display of an
+	// iceberg: each time its display is executed in full, MaxVisibleQuantity
+	// is redrawn uniformly between them, both included. 0 for a fixed display.
+	DisplayLowQuantity  uint64
+	DisplayHighQuantity uint64
+
 	// Slippage protects market orders from executing at unfavorable prices
 	Slippage uint64
 
 	// TrailingDistance is the distance from market for trailing stop orders
 	// Positive value: absolute distance
-	// Negative value: percentage distance (0.01% precision, -10000 = 100%)
+	// Negative value: percentage distance (0.01% precision, -10000 = 100%),
+	// see TrailingPercent and TrailingAmount
 	TrailingDistance int64
 
-	// TrailingStep is the step value for trailing stop updates
+	// TrailingStep is the minimum move of the stop price of trailing stop
+	// orders, 0 or in the units of TrailingDistance and smaller than it
 	TrailingStep int64
+
+	// ParticipantID identifies the market participant that entered the order,
+	// 0 for anonymous orders
+	ParticipantID uint32
+
+	// AccountID is the clearing account the executions of the order settle
+	// to, 0 for the default account
+	AccountID uint32
+
+	// Quote marks a market maker quote, which expires after the QuoteTTL of
+	// its order book
+	Quote bool
 }
 
 // NewOrder creates a new order with default values
@@ -244,6 +274,11 @@ func (o *Order) IsAON() bool {
 	return o.TimeInForce == OrderTimeInForceAON
 }
 
+// IsDay returns true if this is a Day order
+func (o *Order) IsDay() bool {
+	return o.TimeInForce == OrderTimeInForceDay
+}
+
 // HiddenQuantity returns the hidden quantity for iceberg orders
 func (o *Order) HiddenQuantity() uint64 {
 	if o.LeavesQuantity > o.MaxVisibleQuantity {
@@ -289,6 +324,37 @@ type OrderNode struct {
 	Prev *OrderNode
 	// Level points to the price level containing this order
 	Level *LevelNode
+
+	// priority is the arrival sequence number assigned by the market manager.
+	// Lower values arrived earlier and rest in the book ahead of higher values.
+	priority uint64
+	// timestamp is the Unix time in nanoseconds at which the order took its
+	// current queue position
+	timestamp int64
+	// amendments is the audit history, nil unless amendment history is enabled
+	amendments *amendmentRing
+	// minMet is set while an order entering the book matches after its
+	// minimum quantity was found available
+	minMet bool
+	// top is set for an order that opened a new best level, giving it top
+	// order priority in pro rata books while it is first in the queue
+	top bool
+	// exposed is the notional counted in the participant's open exposure
+	exposed uint64
+	// session is the cancel-on-disconnect session of the order, 0 for none
+	session uint64
+}
+
+// Priority returns the arrival sequence number of the order in its book.
+// Orders with a lower priority arrived earlier.
+func (on *OrderNode) Priority() uint64 {
+	return on.priority
+}
+
+// Timestamp returns the Unix time in nanoseconds at which the order took its
+// current queue position
+func (on *OrderNode) Timestamp() int64 {
+	return on.timestamp
 }
 
 // NewOrderNode creates a new OrderNode from an Order
@@ -1,6 +1,9 @@
 package matching
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // OrderBook represents an order book for a single symbol
 type OrderBook struct {
@@ -34,6 +37,28 @@ type OrderBook struct {
 	lastBidPrice   uint64
 	lastAskPrice   uint64
 	matchingPrice  uint64
+
+	// session is the trading statistics of the current session
+	session SessionStats
+
+	// config is the trading rules of the order book
+	config SymbolConfig
+
+	// crossed is set once OnBookCrossed has been reported and cleared when
+	// the book is no longer crossed
+	crossed bool
+
+	// halted rejects new orders while trading is halted, see HaltTrading
+	halted bool
+
+	// nextSweep is the time from which the next order purges expired orders,
+	// see SymbolConfig.ExpirySweep
+	nextSweep time.Time
+
+	// checksum caches the top of book checksum
+	checksum checksumState
+	// view caches the levels of the last read-only view
+	view viewState
 }
 
 // NewOrderBook creates a new order book for a symbol
@@ -106,6 +131,21 @@ func (ob *OrderBook) GetAsk(price uint64) *LevelNode {
 	return ob.asks.Find(price)
 }
 
+// LevelsInRange returns the levels of a side priced between from and to
+// inclusive, best first
+func (ob *OrderBook) LevelsInRange(side OrderSide, from, to uint64) []*LevelNode {
+	levels := ob.bids
+	if side == OrderSideSell {
+		levels = ob.asks
+	}
+	var result []*LevelNode
+	levels.Range(from, to, func(level *LevelNode) bool {
+		result = append(result, level)
+		return true
+	})
+	return result
+}
+
 // BestBuyStop returns the best buy stop level
 func (ob *OrderBook) BestBuyStop() *LevelNode {
 	return ob.bestBuyStop
@@ -161,6 +201,16 @@ func (ob *OrderBook) MatchingPrice() uint64 {
 	return ob.matchingPrice
 }
 
+// Config returns the trading rules of the order book
+func (ob *OrderBook) Config() SymbolConfig {
+	return ob.config
+}
+
+// SessionStats returns the trading statistics of the current session
+func (ob *OrderBook) SessionStats() SessionStats {
+	return ob.session
+}
+
 // AddLevel adds a new price level to the order book
 func (ob *OrderBook) AddLevel(order *OrderNode) *LevelNode {
 	var level *LevelNode
@@ -295,6 +345,9 @@ func (ob *OrderBook) AddOrder(order *OrderNode) {
 		level = ob.AddLevel(order)
 	}
 
+	ob.invalidateChecksum(order)
+	ob.invalidateView(order)
+
 	// Add order to the level
 	level.OrderList.PushBack(order)
 	order.Level = level
@@ -308,6 +361,8 @@ func (ob *OrderBook) AddOrder(order *OrderNode) {
 
 // ReduceOrder reduces the quantity of an order
 func (ob *OrderBook) ReduceOrder(order *OrderNode, quantity uint64, hidden, visible uint64) {
+	ob.invalidateChecksum(order)
+	ob.invalidateView(order)
 	level := order.Level
 	level.TotalVolume -= quantity
 	level.HiddenVolume -= hidden
@@ -316,6 +371,8 @@ func (ob *OrderBook) ReduceOrder(order *OrderNode, quantity uint64, hidden, visi
 
 // DeleteOrder removes an order from the order book
 func (ob *OrderBook) DeleteOrder(order *OrderNode) {
+	ob.invalidateChecksum(order)
+	ob.invalidateView(order)
 	level := order.Level
 
 	// Remove order from level
@@ -339,8 +396,15 @@ func (ob *OrderBook) String() string {
 		ob.symbol.Name, ob.bids.Size(), ob.asks.Size())
 }
 
+// IsCrossed returns true if the best bid is at or above the best ask.
+// A book can only stay crossed while automatic matching is disabled.
+func (ob *OrderBook) IsCrossed() bool {
+	return ob.bestBid != nil && ob.bestAsk != nil && ob.bestBid.Price >= ob.bestAsk.Price
+}
+
 // GetSpread returns the bid-ask spread (ask - bid), or 0 if there's no spread.
-// A spread of 0 may indicate no market or a crossed/locked market.
+// A spread of 0 may indicate no market or a crossed/locked market; use
+// IsCrossed to tell them apart.
 func (ob *OrderBook) GetSpread() uint64 {
 	if ob.bestBid == nil || ob.bestAsk == nil {
 		return 0
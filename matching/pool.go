@@ -33,6 +33,7 @@ func ReleaseOrderNode(node *OrderNode) {
 	node.Next = nil
 	node.Prev = nil
 	node.Level = nil
+	node.amendments = nil
 	orderNodePool.Put(node)
 }
 
@@ -61,6 +62,9 @@ func NewOrderNodePooled(order Order) *OrderNode {
 	node.Next = nil
 	node.Prev = nil
 	node.Level = nil
+	node.priority = 0
+	node.timestamp = 0
+	node.amendments = nil
 	return node
 }
 
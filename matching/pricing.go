@@ -0,0 +1,59 @@
+package matching
+
+// PriceRule determines the execution price of a match between a bid and an ask
+type PriceRule uint8
+
+const (
+	// PriceRuleResting executes at the price of the resting (earlier) order.
+	// This is the standard price-time priority rule used by lit exchanges.
+	PriceRuleResting PriceRule = iota
+	// PriceRuleMidpoint executes at the midpoint of the bid and ask prices,
+	// as used by dark books. The midpoint is rounded down.
+	PriceRuleMidpoint
+	// PriceRuleAggressor executes at the price of the incoming (later) order
+	PriceRuleAggressor
+)
+
+// String returns the string representation of a PriceRule
+func (r PriceRule) String() string {
+	switch r {
+	case PriceRuleResting:
+		return "RESTING"
+	case PriceRuleMidpoint:
+		return "MIDPOINT"
+	case PriceRuleAggressor:
+		return "AGGRESSOR"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Aggressor returns the side of the order that arrived later and so took
+// liquidity when bid and ask matched. The earlier order is the resting one.
+func Aggressor(bid, ask *OrderNode) OrderSide {
+	if bid.priority < ask.priority {
+		return OrderSideSell
+	}
+	return OrderSideBuy
+}
+
+// tradePrice returns the execution price of a match between bid and ask
+// according to the manager's price rule
+func (m *MarketManager) tradePrice(bid, ask *OrderNode) uint64 {
+	aggressor := Aggressor(bid, ask)
+
+	switch m.priceRule {
+	case PriceRuleMidpoint:
+		return bid.Price/2 + ask.Price/2 + (bid.Price%2+ask.Price%2)/2
+	case PriceRuleAggressor:
+		if aggressor == OrderSideSell {
+			return ask.Price
+		}
+		return bid.Price
+	default:
+		if aggressor == OrderSideSell {
+			return bid.Price
+		}
+		return ask.Price
+	}
+}
@@ -0,0 +1,164 @@
+package matching
+
+import "testing"
+
+// crossBook adds a resting order followed by a crossing aggressor and returns
+// the execution prices reported to the handler
+func crossBook(rule PriceRule, resting, aggressor *Order) []uint64 {
+	handler := &testMarketHandler{}
+	manager := NewMarketManagerWithHandler(handler)
+	manager.SetPriceRule(rule)
+	manager.EnableMatching()
+
+	symbol := NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+
+	manager.AddOrder(*resting)
+	manager.AddOrder(*aggressor)
+
+	prices := make([]uint64, 0, len(handler.executions))
+	for _, e := range handler.executions {
+		prices = append(prices, e.price)
+	}
+	return prices
+}
+
+func TestPriceRuleString(t *testing.T) {
+	tests := []struct {
+		rule     PriceRule
+		expected string
+	}{
+		{PriceRuleResting, "RESTING"},
+		{PriceRuleMidpoint, "MIDPOINT"},
+		{PriceRuleAggressor, "AGGRESSOR"},
+		{PriceRule(99), "UNKNOWN"},
+	}
+
+	for _, tt := range tests {
+		if tt.rule.String() != tt.expected {
+			t.Errorf("Expected %s, got %s", tt.expected, tt.rule.String())
+		}
+	}
+}
+
+func TestMarketManager_PriceRule(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      PriceRule
+		resting   *Order
+		aggressor *Order
+		expected  uint64
+	}{
+		{"resting ask", PriceRuleResting, NewLimitOrder(1, 1, OrderSideSell, 10000, 10), NewLimitOrder(2, 1, OrderSideBuy, 10100, 10), 10000},
+		{"resting bid", PriceRuleResting, NewLimitOrder(1, 1, OrderSideBuy, 10100, 10), NewLimitOrder(2, 1, OrderSideSell, 10000, 10), 10100},
+		{"aggressor buy", PriceRuleAggressor, NewLimitOrder(1, 1, OrderSideSell, 10000, 10), NewLimitOrder(2, 1, OrderSideBuy, 10100, 10), 10100},
+		{"aggressor sell", PriceRuleAggressor, NewLimitOrder(1, 1, OrderSideBuy, 10100, 10), NewLimitOrder(2, 1, OrderSideSell, 10000, 10), 10000},
+		{"midpoint", PriceRuleMidpoint, NewLimitOrder(1, 1, OrderSideSell, 10000, 10), NewLimitOrder(2, 1, OrderSideBuy, 10100, 10), 10050},
+		{"midpoint rounds down", PriceRuleMidpoint, NewLimitOrder(1, 1, OrderSideBuy, 10001, 10), NewLimitOrder(2, 1, OrderSideSell, 10000, 10), 10000},
+	}
+
+	for _, tt := range tests {
+		prices := crossBook(tt.rule, tt.resting, tt.aggressor)
+		if len(prices) != 2 {
+			t.Errorf("%s: expected 2 executions, got %d", tt.name, len(prices))
+			continue
+		}
+		for _, price := range prices {
+			if price != tt.expected {
+				t.Errorf("%s: expected price %d, got %d", tt.name, tt.expected, price)
+			}
+		}
+	}
+}
+
+func TestMarketManager_PriceRule_ModifyLosesPriority(t *testing.T) {
+	handler := &testMarketHandler{}
+	manager := NewMarketManagerWithHandler(handler)
+
+	symbol := NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10100, 10))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideSell, 10200, 10))
+
+	// Re-pricing the ask makes it the later order, so the bid is resting
+	manager.ModifyOrder(2, 10000, 10)
+	manager.Match(1)
+
+	if len(handler.executions) != 2 {
+		t.Fatalf("Expected 2 executions, got %d", len(handler.executions))
+	}
+	if handler.executions[0].price != 10100 {
+		t.Errorf("Expected price 10100, got %d", handler.executions[0].price)
+	}
+}
+
+func TestAggressor(t *testing.T) {
+	manager := NewMarketManager()
+	symbol := NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 10))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideSell, 10000, 10))
+
+	bid, ask := manager.GetOrder(1), manager.GetOrder(2)
+	if bid.Priority() >= ask.Priority() {
+		t.Errorf("Expected bid priority before ask, got %d and %d", bid.Priority(), ask.Priority())
+	}
+	if side := Aggressor(bid, ask); side != OrderSideSell {
+		t.Errorf("Expected SELL aggressor, got %s", side)
+	}
+}
+
+func TestMarketManager_AggressiveSellSweepsBids(t *testing.T) {
+	handler := &testMarketHandler{}
+	manager := NewMarketManagerWithHandler(handler)
+	manager.EnableMatching()
+
+	symbol := NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10200, 10))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideBuy, 10100, 10))
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideSell, 10000, 20))
+
+	// Each fill trades at the resting bid, never at the aggressive ask
+	expected := []uint64{10200, 10200, 10100, 10100}
+	if len(handler.executions) != len(expected) {
+		t.Fatalf("Expected %d executions, got %d", len(expected), len(handler.executions))
+	}
+	for i, e := range handler.executions {
+		if e.price != expected[i] {
+			t.Errorf("Execution %d: expected price %d, got %d", i, expected[i], e.price)
+		}
+	}
+}
+
+func TestMarketManager_AggressiveBuySweepsAsks(t *testing.T) {
+	handler := &testMarketHandler{}
+	manager := NewMarketManagerWithHandler(handler)
+	manager.EnableMatching()
+
+	symbol := NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideSell, 10000, 10))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideSell, 10100, 10))
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideBuy, 10200, 20))
+
+	// Each fill trades at the resting ask, never at the aggressive bid
+	expected := []uint64{10000, 10000, 10100, 10100}
+	if len(handler.executions) != len(expected) {
+		t.Fatalf("Expected %d executions, got %d", len(expected), len(handler.executions))
+	}
+	for i, e := range handler.executions {
+		if e.price != expected[i] {
+			t.Errorf("Execution %d: expected price %d, got %d", i, expected[i], e.price)
+		}
+	}
+}
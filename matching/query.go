@@ -0,0 +1,140 @@
+package matching
+
+import "sort"
+
+// OrderStatus is the state of an open order, used as a bit mask in OrderFilter
+type OrderStatus uint8
+
+const (
+	// OrderStatusNew is an active order without executions
+	OrderStatusNew OrderStatus = 1 << iota
+	// OrderStatusPartiallyFilled is an active order with executions
+	OrderStatusPartiallyFilled
+	// OrderStatusPending is a stop order waiting for activation
+	OrderStatusPending
+)
+
+// String returns the string representation of an OrderStatus
+func (s OrderStatus) String() string {
+	switch s {
+	case OrderStatusNew:
+		return "NEW"
+	case OrderStatusPartiallyFilled:
+		return "PARTIALLY_FILLED"
+	case OrderStatusPending:
+		return "PENDING"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Status returns the state of an open order
+func (o *Order) Status() OrderStatus {
+	switch {
+	case o.IsStop() || o.IsStopLimit() || o.IsTrailingStop() || o.IsTrailingStopLimit():
+		return OrderStatusPending
+	case o.ExecutedQuantity > 0:
+		return OrderStatusPartiallyFilled
+	default:
+		return OrderStatusNew
+	}
+}
+
+// OrderFilter selects open orders. The zero value matches every order.
+type OrderFilter struct {
+	// SymbolID restricts the query to one order book, 0 for all
+	SymbolID uint32
+	// Side restricts the query to one side, nil for both
+	Side *OrderSide
+	// ParticipantID restricts the query to one participant, 0 for all
+	ParticipantID uint32
+	// MinPrice is the lowest order price, 0 for no limit. Stop orders without
+	// a limit price are compared by their stop price.
+	MinPrice uint64
+	// MaxPrice is the highest order price, 0 for no limit
+	MaxPrice uint64
+	// Status is a mask of the accepted order states, 0 for all
+	Status OrderStatus
+
+	// Offset is the number of matching orders to skip
+	Offset int
+	// Limit is the maximum number of orders returned, 0 for no limit
+	Limit int
+}
+
+// Match returns true if an order passes the filter
+func (f OrderFilter) Match(order *Order) bool {
+	if f.SymbolID != 0 && order.SymbolID != f.SymbolID {
+		return false
+	}
+	if f.Side != nil && order.Side != *f.Side {
+		return false
+	}
+	if f.ParticipantID != 0 && order.ParticipantID != f.ParticipantID {
+		return false
+	}
+	price := order.Price
+	if price == 0 {
+		price = order.StopPrice
+	}
+	if f.MinPrice != 0 && price < f.MinPrice {
+		return false
+	}
+	if f.MaxPrice != 0 && price > f.MaxPrice {
+		return false
+	}
+	if f.Status != 0 && f.Status&order.Status() == 0 {
+		return false
+	}
+	return true
+}
+
+// page returns the window of n results selected by Offset and Limit
+func (f OrderFilter) page(n int) (int, int) {
+	start := f.Offset
+	if start < 0 {
+		start = 0
+	}
+	if start > n {
+		start = n
+	}
+	end := n
+	if f.Limit > 0 && start+f.Limit < end {
+		end = start + f.Limit
+	}
+	return start, end
+}
+
+// OrderPage is a page of query results
+type OrderPage struct {
+	// Orders are copies of the matching orders in the page
+	Orders []Order
+	// Total is the number of matching orders over all pages
+	Total int
+}
+
+// matchOrders returns the orders passing a filter in arrival order
+func (m *MarketManager) matchOrders(filter OrderFilter) []*OrderNode {
+	var matches []*OrderNode
+	for _, node := range m.orders {
+		if filter.Match(&node.Order) {
+			matches = append(matches, node)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].priority < matches[j].priority
+	})
+	return matches
+}
+
+// QueryOrders returns a page of the open orders passing a filter, in arrival
+// order
+func (m *MarketManager) QueryOrders(filter OrderFilter) OrderPage {
+	matches := m.matchOrders(filter)
+	start, end := filter.page(len(matches))
+	page := OrderPage{Orders: make([]Order, 0, end-start), Total: len(matches)}
+	for _, node := range matches[start:end] {
+		page.Orders = append(page.Orders, node.Order)
+	}
+	return page
+}
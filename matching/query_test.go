@@ -0,0 +1,109 @@
+package matching
+
+import "testing"
+
+// queryIDs returns the IDs of the orders of a page
+func queryIDs(page OrderPage) []uint64 {
+	ids := make([]uint64, 0, len(page.Orders))
+	for _, order := range page.Orders {
+		ids = append(ids, order.ID)
+	}
+	return ids
+}
+
+// equalIDs returns true if two ID lists are equal
+func equalIDs(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// participantOrder creates a limit order entered by a participant
+func participantOrder(id uint64, symbolID uint32, side OrderSide, price, quantity uint64, participant uint32) Order {
+	order := NewLimitOrder(id, symbolID, side, price, quantity)
+	order.ParticipantID = participant
+	return *order
+}
+
+func TestMarketManager_QueryOrders(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	msft := NewSymbol(2, "MSFT")
+	manager.AddSymbol(msft)
+	manager.AddOrderBook(msft)
+
+	manager.AddOrder(participantOrder(5, 1, OrderSideBuy, 10000, 100, 7))
+	manager.AddOrder(participantOrder(3, 1, OrderSideSell, 10200, 100, 8))
+	manager.AddOrder(participantOrder(4, 2, OrderSideBuy, 5000, 100, 7))
+	manager.AddOrder(participantOrder(1, 1, OrderSideBuy, 9900, 100, 8))
+	manager.AddOrder(*NewStopOrder(2, 1, OrderSideBuy, 10500, 100))
+	manager.ExecuteOrder(1, 40)
+
+	sell := OrderSideSell
+	buy := OrderSideBuy
+	tests := []struct {
+		name   string
+		filter OrderFilter
+		want   []uint64
+		total  int
+	}{
+		{"all in arrival order", OrderFilter{}, []uint64{5, 3, 4, 1, 2}, 5},
+		{"symbol", OrderFilter{SymbolID: 2}, []uint64{4}, 1},
+		{"side", OrderFilter{Side: &sell}, []uint64{3}, 1},
+		{"participant", OrderFilter{ParticipantID: 7}, []uint64{5, 4}, 2},
+		{"price range", OrderFilter{MinPrice: 9950, MaxPrice: 10200}, []uint64{5, 3}, 2},
+		{"stop price", OrderFilter{MinPrice: 10400}, []uint64{2}, 1},
+		{"partially filled", OrderFilter{Status: OrderStatusPartiallyFilled}, []uint64{1}, 1},
+		{"pending or new buys", OrderFilter{Side: &buy, Status: OrderStatusNew | OrderStatusPending}, []uint64{5, 4, 2}, 3},
+		{"first page", OrderFilter{Limit: 2}, []uint64{5, 3}, 5},
+		{"last page", OrderFilter{Offset: 4, Limit: 2}, []uint64{2}, 5},
+		{"past the end", OrderFilter{Offset: 10, Limit: 2}, []uint64{}, 5},
+	}
+	for _, tt := range tests {
+		page := manager.QueryOrders(tt.filter)
+		if got := queryIDs(page); !equalIDs(got, tt.want) {
+			t.Errorf("%s: expected orders %v, got %v", tt.name, tt.want, got)
+		}
+		if page.Total != tt.total {
+			t.Errorf("%s: expected total %d, got %d", tt.name, tt.total, page.Total)
+		}
+	}
+
+	page := manager.QueryOrders(OrderFilter{Status: OrderStatusPartiallyFilled})
+	if len(page.Orders) == 1 && page.Orders[0].LeavesQuantity != 60 {
+		t.Errorf("Expected 60 leaves, got %d", page.Orders[0].LeavesQuantity)
+	}
+}
+
+func TestEngine_QueryOrders(t *testing.T) {
+	engine := NewEngine(4)
+	defer engine.Close()
+
+	for id := uint32(1); id <= 3; id++ {
+		symbol := NewSymbol(id, "SYM")
+		engine.AddSymbol(symbol)
+		engine.AddOrderBook(symbol)
+	}
+	engine.AddOrder(participantOrder(1, 3, OrderSideBuy, 10000, 10, 7))
+	engine.AddOrder(participantOrder(2, 1, OrderSideBuy, 10000, 10, 8))
+	engine.AddOrder(participantOrder(3, 2, OrderSideSell, 10000, 10, 7))
+	engine.AddOrder(participantOrder(4, 3, OrderSideSell, 10100, 10, 7))
+
+	page := engine.QueryOrders(OrderFilter{ParticipantID: 7})
+	if page.Total != 3 {
+		t.Errorf("Expected 3 orders, got %d", page.Total)
+	}
+	page = engine.QueryOrders(OrderFilter{SymbolID: 3, Offset: 1})
+	if got := queryIDs(page); !equalIDs(got, []uint64{4}) || page.Total != 2 {
+		t.Errorf("Expected order 4 of 2, got %v of %d", got, page.Total)
+	}
+	page = engine.QueryOrders(OrderFilter{Limit: 3})
+	if len(page.Orders) != 3 || page.Total != 4 {
+		t.Errorf("Expected 3 orders of 4, got %d of %d", len(page.Orders), page.Total)
+	}
+}
@@ -0,0 +1,26 @@
+package matching
+
+// reject reports a rejected operation on a new order to the handler. The
+// order operations defer it with their result, after starting the operation
+// so that the reject is part of its batch:
+//
+//	defer m.operation()()
+//	defer m.reject(&order, &code)
+func (m *MarketManager) reject(order *Order, code *ErrorCode) {
+	if *code != ErrorOK {
+		m.handler.OnRejectOrder(*order, *code)
+	}
+}
+
+// rejectID reports a rejected operation on an existing order to the handler,
+// with the state of the order, or only its ID if it does not exist
+func (m *MarketManager) rejectID(id uint64, code *ErrorCode) {
+	if *code == ErrorOK {
+		return
+	}
+	order := Order{ID: id}
+	if node, exists := m.orders[id]; exists {
+		order = node.Order
+	}
+	m.handler.OnRejectOrder(order, *code)
+}
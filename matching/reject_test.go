@@ -0,0 +1,50 @@
+package matching
+
+import "testing"
+
+// rejectHandler records rejected order operations
+type rejectHandler struct {
+	DefaultMarketHandler
+	orders []Order
+	codes  []ErrorCode
+}
+
+func (h *rejectHandler) OnRejectOrder(order Order, code ErrorCode) {
+	h.orders = append(h.orders, order)
+	h.codes = append(h.codes, code)
+}
+
+func TestOnRejectOrder(t *testing.T) {
+	handler := &rejectHandler{}
+	manager := newConfigManager(handler)
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 100))
+	manager.ReduceOrder(1, 10)
+	if len(handler.codes) != 0 {
+		t.Fatalf("Expected no reject for accepted operations, got %v", handler.codes)
+	}
+
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideSell, 10100, 50))
+	manager.AddOrder(*NewLimitOrder(2, 7, OrderSideBuy, 10000, 10))
+	manager.ReplaceOrder(1, 1, 10000, 20)
+	manager.DeleteOrder(42)
+	want := []ErrorCode{ErrorOrderDuplicate, ErrorOrderBookNotFound, ErrorOrderDuplicate, ErrorOrderNotFound}
+	if len(handler.codes) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, handler.codes)
+	}
+	for i, code := range want {
+		if handler.codes[i] != code {
+			t.Errorf("Reject %d: expected %s, got %s", i, code, handler.codes[i])
+		}
+	}
+
+	// New orders are reported as requested, existing ones in their state
+	if o := handler.orders[0]; o.Side != OrderSideSell || o.Price != 10100 {
+		t.Errorf("Expected the requested sell order, got %+v", o)
+	}
+	if o := handler.orders[2]; o.Side != OrderSideBuy || o.LeavesQuantity != 90 {
+		t.Errorf("Expected the resting order with 90 left, got %+v", o)
+	}
+	if o := handler.orders[3]; o.ID != 42 {
+		t.Errorf("Expected the ID of the unknown order, got %+v", o)
+	}
+}
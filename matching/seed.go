@@ -0,0 +1,124 @@
+package matching
+
+import "math"
+
+// SeedParticipantID is the participant of the synthetic orders seeded from a
+// depth snapshot, so they can be queried and canceled apart from real flow
+const SeedParticipantID uint32 = math.MaxUint32
+
+// Depth is an L2 snapshot of an order book: the price levels of each side,
+// best first. Only the price, total volume and order count of the levels are
+// used.
+type Depth struct {
+	Bids []Level
+	Asks []Level
+}
+
+// Depth returns the top levels of each side, every level for levels <= 0
+func (ob *OrderBook) Depth(levels int) Depth {
+	return Depth{Bids: topLevels(ob.bids, levels), Asks: topLevels(ob.asks, levels)}
+}
+
+// LoadDepth seeds the order book with the liquidity of a depth snapshot, see
+// MarketManager.SeedBook
+func (ob *OrderBook) LoadDepth(depth Depth) ErrorCode {
+	return ob.manager.SeedBook(ob.symbol.ID, depth)
+}
+
+// SeedBook populates the order book of a symbol with synthetic resting
+// liquidity from a depth snapshot, so a simulation can start from a book
+// captured elsewhere. Each level becomes its order count of GTC limit
+// orders of SeedParticipantID, sharing the level volume in lots, with IDs
+// from the order ID allocator. The orders are restored as by RestoreOrder:
+// they are reported by OnAddOrder and the level events, but are not checked
+// against the trading schedule, authorizer or exposure limits, and do not
+// match.
+//
+// The snapshot is checked before any order is added: levels must be in
+// priority order, priced by the symbol rules, hold a volume of whole lots,
+// and must not cross each other or the orders already in the book.
+func (m *MarketManager) SeedBook(symbolID uint32, depth Depth) ErrorCode {
+	defer m.operation()()
+	ob, exists := m.orderBooks[symbolID]
+	if !exists {
+		return ErrorOrderBookNotFound
+	}
+	if code := checkDepth(ob, depth); code != ErrorOK {
+		return code
+	}
+
+	for _, level := range depth.Bids {
+		m.seedLevel(ob, OrderSideBuy, level)
+	}
+	for _, level := range depth.Asks {
+		m.seedLevel(ob, OrderSideSell, level)
+	}
+	return ErrorOK
+}
+
+// checkDepth checks that a depth snapshot can seed an order book
+func checkDepth(ob *OrderBook, depth Depth) ErrorCode {
+	for i, level := range depth.Bids {
+		if i > 0 && level.Price >= depth.Bids[i-1].Price {
+			return ErrorOrderParameterInvalid
+		}
+		if code := checkSeedLevel(ob.config, level); code != ErrorOK {
+			return code
+		}
+	}
+	for i, level := range depth.Asks {
+		if i > 0 && level.Price <= depth.Asks[i-1].Price {
+			return ErrorOrderParameterInvalid
+		}
+		if code := checkSeedLevel(ob.config, level); code != ErrorOK {
+			return code
+		}
+	}
+
+	bestBid, bestAsk := uint64(0), uint64(math.MaxUint64)
+	if ob.bestBid != nil {
+		bestBid = ob.bestBid.Price
+	}
+	if ob.bestAsk != nil {
+		bestAsk = ob.bestAsk.Price
+	}
+	if len(depth.Bids) > 0 {
+		bestBid = max(bestBid, depth.Bids[0].Price)
+	}
+	if len(depth.Asks) > 0 {
+		bestAsk = min(bestAsk, depth.Asks[0].Price)
+	}
+	if bestBid >= bestAsk {
+		return ErrorOrderParameterInvalid
+	}
+	return ErrorOK
+}
+
+// checkSeedLevel checks the price and volume of a level against the rules of
+// the symbol
+func checkSeedLevel(config SymbolConfig, level Level) ErrorCode {
+	if level.Price == 0 || !config.checkPrice(level.Price) {
+		return ErrorOrderParameterInvalid
+	}
+	if level.TotalVolume == 0 || !config.checkQuantity(level.TotalVolume) {
+		return ErrorOrderQuantityInvalid
+	}
+	return ErrorOK
+}
+
+// seedLevel restores the orders of a depth level, spreading its lots over at
+// most its order count, earlier orders taking the remainder
+func (m *MarketManager) seedLevel(ob *OrderBook, side OrderSide, level Level) {
+	lot := max(ob.config.LotSize, 1)
+	lots := level.TotalVolume / lot
+	n := min(max(level.Orders, 1), lots)
+	for i := uint64(0); i < n; i++ {
+		quantity := lots / n * lot
+		if i < lots%n {
+			quantity += lot
+		}
+		order := NewLimitOrder(m.NextOrderID(), ob.symbol.ID, side, level.Price, quantity)
+		order.ParticipantID = SeedParticipantID
+		m.RestoreOrder(*order)
+	}
+}
@@ -0,0 +1,75 @@
+package matching
+
+import "testing"
+
+func TestSeedBook(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.UpdateSymbolConfig(1, SymbolConfig{TickSize: 100, LotSize: 10})
+	depth := Depth{
+		Bids: []Level{{Price: 10000, TotalVolume: 100, Orders: 3}, {Price: 9900, TotalVolume: 50}},
+		Asks: []Level{{Price: 10100, TotalVolume: 20, Orders: 40}},
+	}
+	if err := manager.SeedBook(1, depth); err != ErrorOK {
+		t.Fatalf("Expected OK, got %s", err)
+	}
+
+	ob := manager.GetOrderBook(1)
+	got := ob.Depth(0)
+	if len(got.Bids) != 2 || len(got.Asks) != 1 {
+		t.Fatalf("Expected 2 bid and 1 ask levels, got %+v", got)
+	}
+	if got.Bids[0].TotalVolume != 100 || got.Bids[0].Orders != 3 || got.Bids[1].Orders != 1 {
+		t.Errorf("Expected 100 in 3 orders and 50 in 1, got %+v", got.Bids)
+	}
+	// Orders are whole lots of 10
+	if got.Asks[0].TotalVolume != 20 || got.Asks[0].Orders != 2 {
+		t.Errorf("Expected 20 in 2 orders, got %+v", got.Asks[0])
+	}
+	page := manager.QueryOrders(OrderFilter{ParticipantID: SeedParticipantID})
+	if page.Total != 6 {
+		t.Errorf("Expected 6 seeded orders, got %d", page.Total)
+	}
+	if o := manager.GetOrder(1); o == nil || o.Quantity != 40 || o.Side != OrderSideBuy {
+		t.Errorf("Expected the first order to take the remainder, got %+v", o)
+	}
+
+	// Seeded liquidity trades with new orders
+	manager.EnableMatching()
+	manager.AddOrder(*NewLimitOrder(100, 1, OrderSideSell, 10000, 50))
+	if ob.BestBid().TotalVolume != 50 {
+		t.Errorf("Expected 50 left at the best bid, got %d", ob.BestBid().TotalVolume)
+	}
+}
+
+func TestSeedBook_Invalid(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.UpdateSymbolConfig(1, SymbolConfig{TickSize: 100, LotSize: 10})
+	tests := []struct {
+		name  string
+		depth Depth
+		want  ErrorCode
+	}{
+		{"unordered", Depth{Bids: []Level{{Price: 9900, TotalVolume: 10}, {Price: 10000, TotalVolume: 10}}}, ErrorOrderParameterInvalid},
+		{"crossed", Depth{Bids: []Level{{Price: 10100, TotalVolume: 10}}, Asks: []Level{{Price: 10100, TotalVolume: 10}}}, ErrorOrderParameterInvalid},
+		{"tick", Depth{Bids: []Level{{Price: 10050, TotalVolume: 10}}}, ErrorOrderParameterInvalid},
+		{"lot", Depth{Bids: []Level{{Price: 10000, TotalVolume: 15}}}, ErrorOrderQuantityInvalid},
+		{"empty level", Depth{Asks: []Level{{Price: 10000}}}, ErrorOrderQuantityInvalid},
+	}
+	for _, tt := range tests {
+		if err := manager.SeedBook(1, tt.depth); err != tt.want {
+			t.Errorf("%s: Expected %s, got %s", tt.name, tt.want, err)
+		}
+	}
+	if len(manager.Orders()) != 0 {
+		t.Errorf("Expected no orders from invalid snapshots, got %d", len(manager.Orders()))
+	}
+	if err := manager.SeedBook(2, Depth{}); err != ErrorOrderBookNotFound {
+		t.Errorf("Expected ORDER_BOOK_NOT_FOUND, got %s", err)
+	}
+
+	// Seeded levels must not cross the book
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideSell, 10000, 10))
+	if err := manager.SeedBook(1, Depth{Bids: []Level{{Price: 10000, TotalVolume: 10}}}); err != ErrorOrderParameterInvalid {
+		t.Errorf("Expected a bid crossing the book rejected, got %s", err)
+	}
+}
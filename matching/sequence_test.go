@@ -0,0 +1,184 @@
+package matching
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden event sequence")
+
+// sequenceHandler records every callback as a line of text
+type sequenceHandler struct {
+	lines []string
+}
+
+func (h *sequenceHandler) add(format string, args ...interface{}) {
+	h.lines = append(h.lines, fmt.Sprintf(format, args...))
+}
+
+func formatOrder(o Order) string {
+	return fmt.Sprintf("id=%d %s %s price=%d qty=%d exec=%d leaves=%d", o.ID, o.Type, o.Side, o.Price, o.Quantity, o.ExecutedQuantity, o.LeavesQuantity)
+}
+
+func formatLevel(l Level, top bool) string {
+	return fmt.Sprintf("%s price=%d volume=%d visible=%d orders=%d top=%t", l.Type, l.Price, l.TotalVolume, l.VisibleVolume, l.Orders, top)
+}
+
+func (h *sequenceHandler) OnAddSymbol(s Symbol)    { h.add("AddSymbol %d %s", s.ID, s.Name) }
+func (h *sequenceHandler) OnDeleteSymbol(s Symbol) { h.add("DeleteSymbol %d %s", s.ID, s.Name) }
+func (h *sequenceHandler) OnAddOrderBook(ob *OrderBook) {
+	h.add("AddOrderBook %d", ob.Symbol().ID)
+}
+func (h *sequenceHandler) OnUpdateOrderBook(ob *OrderBook, top bool) {
+	h.add("UpdateOrderBook %d top=%t", ob.Symbol().ID, top)
+}
+func (h *sequenceHandler) OnDeleteOrderBook(ob *OrderBook) {
+	h.add("DeleteOrderBook %d", ob.Symbol().ID)
+}
+func (h *sequenceHandler) OnBookCrossed(ob *OrderBook) { h.add("BookCrossed %d", ob.Symbol().ID) }
+func (h *sequenceHandler) OnUpdateSymbolConfig(ob *OrderBook, c SymbolConfig) {
+	h.add("UpdateSymbolConfig %d tick=%d lot=%d", ob.Symbol().ID, c.TickSize, c.LotSize)
+}
+func (h *sequenceHandler) OnAddLevel(ob *OrderBook, l Level, top bool) {
+	h.add("AddLevel %s", formatLevel(l, top))
+}
+func (h *sequenceHandler) OnUpdateLevel(ob *OrderBook, l Level, top bool) {
+	h.add("UpdateLevel %s", formatLevel(l, top))
+}
+func (h *sequenceHandler) OnDeleteLevel(ob *OrderBook, l Level, top bool) {
+	h.add("DeleteLevel %s", formatLevel(l, top))
+}
+func (h *sequenceHandler) OnAddOrder(o Order)     { h.add("AddOrder %s", formatOrder(o)) }
+func (h *sequenceHandler) OnUpdateOrder(o Order)  { h.add("UpdateOrder %s", formatOrder(o)) }
+func (h *sequenceHandler) OnDeleteOrder(o Order)  { h.add("DeleteOrder %s", formatOrder(o)) }
+func (h *sequenceHandler) OnInvalidOrder(o Order) { h.add("InvalidOrder %s", formatOrder(o)) }
+func (h *sequenceHandler) OnRejectOrder(o Order, code ErrorCode) {
+	h.add("RejectOrder %s %s", formatOrder(o), code)
+}
+func (h *sequenceHandler) OnExpireOrder(o Order) { h.add("ExpireOrder %s", formatOrder(o)) }
+func (h *sequenceHandler) OnExecuteOrder(o Order, price, quantity uint64) {
+	h.add("ExecuteOrder %s at %d x %d", formatOrder(o), price, quantity)
+}
+func (h *sequenceHandler) OnTrade(t Trade) {
+	h.add("Trade buy=%d sell=%d price=%d qty=%d aggressor=%s", t.BuyOrderID, t.SellOrderID, t.Price, t.Quantity, t.Aggressor)
+}
+
+// runSequenceScenario runs a fixed sequence of operations covering every
+// callback and returns the recorded events, one per line, each operation
+// introduced by a "#" line
+func runSequenceScenario() string {
+	return runSequenceScenarioWith(nil)
+}
+
+// runSequenceScenarioWith runs the scenario with the handler set by setup,
+// the recorder if setup is nil
+func runSequenceScenarioWith(setup func(m *MarketManager, h *sequenceHandler)) string {
+	h := &sequenceHandler{}
+	m := NewMarketManagerWithHandler(h)
+	if setup != nil {
+		setup(m, h)
+	}
+	m.SetClock(func() time.Time { return time.Date(2024, 3, 1, 15, 0, 0, 0, time.UTC) })
+	step := func(name string, op func() ErrorCode) {
+		h.add("# %s", name)
+		if err := op(); err != ErrorOK {
+			h.add("= %s", err)
+		}
+	}
+	limit := func(id uint64, side OrderSide, price, quantity uint64) func() ErrorCode {
+		return func() ErrorCode { return m.AddOrder(*NewLimitOrder(id, 1, side, price, quantity)) }
+	}
+
+	step("add symbols", func() ErrorCode {
+		m.AddSymbol(NewSymbol(1, "AAPL"))
+		return m.AddSymbol(NewSymbol(2, "MSFT"))
+	})
+	step("add order books", func() ErrorCode {
+		m.AddOrderBook(NewSymbol(1, "AAPL"))
+		return m.AddOrderBook(NewSymbol(2, "MSFT"))
+	})
+
+	// Build both sides of the book without matching
+	step("bid 1", limit(1, OrderSideBuy, 10000, 100))
+	step("bid 2 same level", limit(2, OrderSideBuy, 10000, 50))
+	step("bid 3 lower", limit(3, OrderSideBuy, 9900, 200))
+	step("ask 4", limit(4, OrderSideSell, 10100, 100))
+	step("ask 5 higher", limit(5, OrderSideSell, 10200, 300))
+	step("iceberg ask 6", func() ErrorCode {
+		o := NewLimitOrder(6, 1, OrderSideSell, 10100, 120)
+		o.MaxVisibleQuantity = 20
+		return m.AddOrder(*o)
+	})
+	step("day bid 7", func() ErrorCode {
+		o := NewLimitOrder(7, 1, OrderSideBuy, 9800, 70)
+		o.TimeInForce = OrderTimeInForceDay
+		return m.AddOrder(*o)
+	})
+	step("crossing bid 8 with matching disabled", limit(8, OrderSideBuy, 10100, 10))
+	step("delete bid 8", func() ErrorCode { return m.DeleteOrder(8) })
+
+	m.EnableMatching()
+	step("buy 9 sweeps two ask levels", limit(9, OrderSideBuy, 10200, 250))
+	step("market sell 10", func() ErrorCode { return m.AddOrder(*NewMarketOrder(10, 1, OrderSideSell, 120)) })
+	step("reduce bid 3", func() ErrorCode { return m.ReduceOrder(3, 50) })
+	step("modify partially filled bid 2", func() ErrorCode { return m.ModifyOrder(2, 9950, 40) })
+	step("mitigate ask 5", func() ErrorCode { return m.MitigateOrder(5, 10150, 150) })
+	step("replace bid 3 with 11", func() ErrorCode { return m.ReplaceOrder(3, 11, 10150, 60) })
+	step("execute ask 5", func() ErrorCode { return m.ExecuteOrderWithPrice(5, 10140, 30) })
+	step("bid 12", limit(12, OrderSideBuy, 9700, 10))
+	step("expire bid 12", func() ErrorCode { return m.ExpireOrder(12) })
+	step("unknown order", func() ErrorCode { return m.DeleteOrder(99) })
+
+	// Session and configuration changes
+	step("MSFT orders", func() ErrorCode {
+		m.AddOrder(*NewLimitOrder(20, 2, OrderSideBuy, 5003, 10))
+		m.AddOrder(*NewLimitOrder(21, 2, OrderSideSell, 5100, 10))
+		return m.AddOrder(*NewLimitOrder(22, 2, OrderSideBuy, 5001, 15))
+	})
+	step("tick size invalidates MSFT orders", func() ErrorCode {
+		return m.UpdateSymbolConfig(2, SymbolConfig{TickSize: 5, CancelInvalid: true})
+	})
+	step("reset session", func() ErrorCode { m.ResetSession(); return ErrorOK })
+	step("delete AAPL", func() ErrorCode { return m.DeleteSymbol(1) })
+	return strings.Join(h.lines, "\n") + "\n"
+}
+
+// TestEventSequence_Golden enforces the ordering contract of MarketHandler:
+// the scenario must produce exactly the recorded callbacks, in order. Run
+// with -update only for an intended change of the event order, which breaks
+// replication and replay across versions.
+func TestEventSequence_Golden(t *testing.T) {
+	path := filepath.Join("testdata", "events.golden")
+	got := runSequenceScenario()
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if got != string(want) {
+		gotLines, wantLines := strings.Split(got, "\n"), strings.Split(string(want), "\n")
+		for i := 0; i < len(gotLines) && i < len(wantLines); i++ {
+			if gotLines[i] != wantLines[i] {
+				t.Fatalf("Event %d: expected %q, got %q", i+1, wantLines[i], gotLines[i])
+			}
+		}
+		t.Fatalf("Expected %d events, got %d", len(wantLines), len(gotLines))
+	}
+
+	// Map iteration order must not leak into the callbacks
+	for i := 0; i < 20; i++ {
+		if again := runSequenceScenario(); !bytes.Equal([]byte(again), []byte(got)) {
+			t.Fatalf("Expected the same events on run %d", i+2)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package matching
+
+import "sort"
+
+// SessionStats contains the trading statistics of an order book for the
+// current session
+type SessionStats struct {
+	// Trades is the number of trades
+	Trades uint64
+	// Volume is the traded quantity
+	Volume uint64
+	// Open is the price of the first trade
+	Open uint64
+	// High is the highest trade price
+	High uint64
+	// Low is the lowest trade price
+	Low uint64
+	// Last is the price of the last trade
+	Last uint64
+}
+
+// record updates the statistics with a trade
+func (s *SessionStats) record(price, quantity uint64) {
+	if s.Trades == 0 {
+		s.Open = price
+		s.High = price
+		s.Low = price
+	}
+	if price > s.High {
+		s.High = price
+	}
+	if price < s.Low {
+		s.Low = price
+	}
+	s.Last = price
+	s.Trades++
+	s.Volume += quantity
+}
+
+// ResetSession performs the end-of-day rollover of all order books.
+// Day orders are cancelled, GTC and other orders are carried over unchanged
+// with their queue priority, and session statistics, including the maker and
+// taker volume of participants and the settlement positions of accounts, are
+// reset.
+// Returns the number of cancelled orders.
+func (m *MarketManager) ResetSession() int {
+	defer m.operation()()
+	ids := make([]uint64, 0)
+	for id, order := range m.orders {
+		if order.IsDay() {
+			ids = append(ids, id)
+		}
+	}
+	// Cancel in a deterministic order so that replays produce identical events
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		m.DeleteOrder(id)
+	}
+
+	for _, ob := range m.orderBooks {
+		ob.session = SessionStats{}
+		ob.lastBidPrice = 0
+		ob.lastAskPrice = 0
+		ob.matchingPrice = 0
+	}
+	m.liquidity = nil
+	m.settlement = nil
+
+	return len(ids)
+}
@@ -0,0 +1,45 @@
+package matching
+
+import "testing"
+
+func TestMarketManager_ResetSession(t *testing.T) {
+	manager := NewMarketManager()
+	manager.EnableMatching()
+
+	symbol := NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+
+	day := NewLimitOrder(1, 1, OrderSideBuy, 9900, 10)
+	day.TimeInForce = OrderTimeInForceDay
+	manager.AddOrder(*day)
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideBuy, 9900, 10))
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideSell, 10000, 10))
+	manager.AddOrder(*NewLimitOrder(4, 1, OrderSideBuy, 10100, 4))
+	manager.AddOrder(*NewLimitOrder(5, 1, OrderSideBuy, 10000, 2))
+
+	ob := manager.GetOrderBook(1)
+	stats := ob.SessionStats()
+	if stats.Trades != 2 || stats.Volume != 6 {
+		t.Errorf("Expected 2 trades / 6 volume, got %d / %d", stats.Trades, stats.Volume)
+	}
+	if stats.Open != 10000 || stats.High != 10000 || stats.Low != 10000 || stats.Last != 10000 {
+		t.Errorf("Unexpected session prices %+v", stats)
+	}
+
+	if cancelled := manager.ResetSession(); cancelled != 1 {
+		t.Errorf("Expected 1 cancelled order, got %d", cancelled)
+	}
+	if manager.GetOrder(1) != nil {
+		t.Error("Expected day order to be cancelled")
+	}
+	if o := manager.GetOrder(3); o == nil || o.LeavesQuantity != 4 || o.ExecutedQuantity != 6 {
+		t.Error("Expected GTC order to be carried with its execution state")
+	}
+	if manager.GetOrder(2) == nil {
+		t.Error("Expected GTC order to be carried")
+	}
+	if ob.SessionStats() != (SessionStats{}) {
+		t.Errorf("Expected session stats to be reset, got %+v", ob.SessionStats())
+	}
+}
@@ -0,0 +1,260 @@
+package matching
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// FeeScale is the denominator of the rates of a FeeSchedule: a rate of 100
+// charges 100 millionths, or one basis point, of the notional
+const FeeScale = 1_000_000
+
+// FeeSchedule holds the fees charged on the executions of every account, in
+// FeeScale units of the notional. Negative rates pay rebates.
+type FeeSchedule struct {
+	// MakerRate is charged on the executions of resting orders
+	MakerRate int64
+	// TakerRate is charged on the executions of aggressing orders
+	TakerRate int64
+}
+
+// fee returns the fee of an execution of notional, truncated toward zero and
+// saturating on overflow
+func (f FeeSchedule) fee(maker bool, notional uint64) int64 {
+	rate := f.TakerRate
+	if maker {
+		rate = f.MakerRate
+	}
+	if rate == 0 {
+		return 0
+	}
+	abs := uint64(rate)
+	if rate < 0 {
+		abs = -abs
+	}
+	hi, lo := bits.Mul64(notional, abs)
+	fee := uint64(math.MaxInt64)
+	if hi < FeeScale {
+		if q, _ := bits.Div64(hi, lo, FeeScale); q < math.MaxInt64 {
+			fee = q
+		}
+	}
+	if rate < 0 {
+		return -int64(fee)
+	}
+	return int64(fee)
+}
+
+// SettlementPosition is what an account clears in a symbol for the current
+// session. Notionals are price × quantity, saturating on overflow.
+type SettlementPosition struct {
+	AccountID uint32 `json:"account_id"`
+	SymbolID  uint32 `json:"symbol_id"`
+	Trades    uint64 `json:"trades"`
+
+	BoughtQuantity uint64 `json:"bought_quantity"`
+	SoldQuantity   uint64 `json:"sold_quantity"`
+	BoughtNotional uint64 `json:"bought_notional"`
+	SoldNotional   uint64 `json:"sold_notional"`
+
+	// Fees is the sum of the fees charged, negative for a net rebate
+	Fees int64 `json:"fees"`
+	// NetPosition is the bought minus the sold quantity
+	NetPosition int64 `json:"net_position"`
+	// NetCash is the cash the account receives, the sold minus the bought
+	// notional minus the fees, negative when it pays
+	NetCash int64 `json:"net_cash"`
+}
+
+// settlementKey identifies the position of an account in a symbol
+type settlementKey struct {
+	accountID uint32
+	symbolID  uint32
+}
+
+// SetFeeSchedule sets the fees charged on the executions that follow
+func (m *MarketManager) SetFeeSchedule(fees FeeSchedule) {
+	m.fees = fees
+}
+
+// FeeSchedule returns the fees charged on executions
+func (m *MarketManager) FeeSchedule() FeeSchedule {
+	return m.fees
+}
+
+// recordSettlement books a trade between a bid and an ask to the accounts of
+// both orders
+func (m *MarketManager) recordSettlement(symbolID uint32, bid, ask *OrderNode, price, quantity uint64) {
+	hi, notional := bits.Mul64(price, quantity)
+	if hi != 0 {
+		notional = math.MaxUint64
+	}
+	aggressor := Aggressor(bid, ask)
+
+	buy := m.settlementPosition(bid.AccountID, symbolID)
+	buy.Trades++
+	buy.BoughtQuantity += quantity
+	buy.BoughtNotional = saturatingAdd(buy.BoughtNotional, notional)
+	buy.Fees = saturatingAddInt64(buy.Fees, m.fees.fee(aggressor == OrderSideSell, notional))
+
+	sell := m.settlementPosition(ask.AccountID, symbolID)
+	sell.Trades++
+	sell.SoldQuantity += quantity
+	sell.SoldNotional = saturatingAdd(sell.SoldNotional, notional)
+	sell.Fees = saturatingAddInt64(sell.Fees, m.fees.fee(aggressor == OrderSideBuy, notional))
+}
+
+// settlementPosition returns the position of an account in a symbol,
+// creating it on first use
+func (m *MarketManager) settlementPosition(accountID, symbolID uint32) *SettlementPosition {
+	if m.settlement == nil {
+		m.settlement = make(map[settlementKey]*SettlementPosition)
+	}
+	key := settlementKey{accountID: accountID, symbolID: symbolID}
+	position := m.settlement[key]
+	if position == nil {
+		position = &SettlementPosition{AccountID: accountID, SymbolID: symbolID}
+		m.settlement[key] = position
+	}
+	return position
+}
+
+// Settlement returns the position of every account in every symbol it
+// traded in the current session, sorted by account and symbol. Orders
+// without an account settle to account 0. ResetSession clears the
+// positions, so export them before the session is reset.
+func (m *MarketManager) Settlement() []SettlementPosition {
+	positions := make([]SettlementPosition, 0, len(m.settlement))
+	for _, position := range m.settlement {
+		positions = append(positions, position.settled())
+	}
+	sortSettlement(positions)
+	return positions
+}
+
+// settled returns the position with its net position and cash
+func (p SettlementPosition) settled() SettlementPosition {
+	p.NetPosition = subtractUint64(p.BoughtQuantity, p.SoldQuantity)
+	p.NetCash = saturatingAddInt64(subtractUint64(p.SoldNotional, p.BoughtNotional), saturatingNegate(p.Fees))
+	return p
+}
+
+// Settlement returns the positions of every account, over all shards
+func (e *Engine) Settlement() []SettlementPosition {
+	var mu sync.Mutex
+	var positions []SettlementPosition
+	e.broadcast(func(m *MarketManager) ErrorCode {
+		shard := m.Settlement()
+		mu.Lock()
+		positions = append(positions, shard...)
+		mu.Unlock()
+		return ErrorOK
+	})
+	sortSettlement(positions)
+	return positions
+}
+
+// SetFeeSchedule sets the fees charged on executions on all shards
+func (e *Engine) SetFeeSchedule(fees FeeSchedule) ErrorCode {
+	return e.broadcast(func(m *MarketManager) ErrorCode {
+		m.SetFeeSchedule(fees)
+		return ErrorOK
+	})
+}
+
+// sortSettlement sorts positions by account and symbol
+func sortSettlement(positions []SettlementPosition) {
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].AccountID != positions[j].AccountID {
+			return positions[i].AccountID < positions[j].AccountID
+		}
+		return positions[i].SymbolID < positions[j].SymbolID
+	})
+}
+
+// settlementColumns is the header written by WriteSettlementCSV
+var settlementColumns = []string{
+	"Account", "Symbol", "Trades", "BoughtQty", "SoldQty", "BoughtNotional", "SoldNotional",
+	"NetPosition", "NetCash", "Fees",
+}
+
+// WriteSettlementCSV writes positions as CSV, one row per account and symbol
+// after a header row, for clearing systems that import end-of-session files
+func WriteSettlementCSV(w io.Writer, positions []SettlementPosition) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(settlementColumns); err != nil {
+		return err
+	}
+
+	for _, p := range positions {
+		record := []string{
+			strconv.FormatUint(uint64(p.AccountID), 10),
+			strconv.FormatUint(uint64(p.SymbolID), 10),
+			strconv.FormatUint(p.Trades, 10),
+			strconv.FormatUint(p.BoughtQuantity, 10),
+			strconv.FormatUint(p.SoldQuantity, 10),
+			strconv.FormatUint(p.BoughtNotional, 10),
+			strconv.FormatUint(p.SoldNotional, 10),
+			strconv.FormatInt(p.NetPosition, 10),
+			strconv.FormatInt(p.NetCash, 10),
+			strconv.FormatInt(p.Fees, 10),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteSettlementJSON writes positions as a JSON array of objects with the
+// snake_case names of the SettlementPosition fields
+func WriteSettlementJSON(w io.Writer, positions []SettlementPosition) error {
+	if positions == nil {
+		positions = []SettlementPosition{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(positions)
+}
+
+// subtractUint64 returns a - b, saturating to the int64 range
+func subtractUint64(a, b uint64) int64 {
+	if a >= b {
+		if a-b > math.MaxInt64 {
+			return math.MaxInt64
+		}
+		return int64(a - b)
+	}
+	if b-a > 1<<63 {
+		return math.MinInt64
+	}
+	return int64(-(b - a))
+}
+
+// saturatingAddInt64 returns a + b, saturating on overflow
+func saturatingAddInt64(a, b int64) int64 {
+	sum := a + b
+	switch {
+	case a > 0 && b > 0 && sum < 0:
+		return math.MaxInt64
+	case a < 0 && b < 0 && sum >= 0:
+		return math.MinInt64
+	}
+	return sum
+}
+
+// saturatingNegate returns -a, math.MaxInt64 for math.MinInt64
+func saturatingNegate(a int64) int64 {
+	if a == math.MinInt64 {
+		return math.MaxInt64
+	}
+	return -a
+}
@@ -0,0 +1,157 @@
+package matching
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+// accountOrder returns a limit order settling to an account
+func accountOrder(id uint64, symbolID uint32, side OrderSide, price, quantity uint64, accountID uint32) Order {
+	order := NewLimitOrder(id, symbolID, side, price, quantity)
+	order.AccountID = accountID
+	return *order
+}
+
+func TestMarketManager_Settlement(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.EnableMatching()
+	// 2 basis points for takers, a 1 basis point rebate for makers
+	manager.SetFeeSchedule(FeeSchedule{MakerRate: -100, TakerRate: 200})
+
+	manager.AddOrder(accountOrder(1, 1, OrderSideSell, 10000, 10, 1))
+	manager.AddOrder(accountOrder(2, 1, OrderSideSell, 10100, 10, 2))
+	manager.AddOrder(accountOrder(3, 1, OrderSideBuy, 10100, 15, 3))
+	// No account, settles to the default account
+	manager.AddOrder(accountOrder(4, 1, OrderSideBuy, 10100, 5, 0))
+
+	positions := manager.Settlement()
+	want := []SettlementPosition{
+		{AccountID: 0, SymbolID: 1, Trades: 1, BoughtQuantity: 5, BoughtNotional: 50500,
+			Fees: 10, NetPosition: 5, NetCash: -50510},
+		{AccountID: 1, SymbolID: 1, Trades: 1, SoldQuantity: 10, SoldNotional: 100000,
+			Fees: -10, NetPosition: -10, NetCash: 100010},
+		{AccountID: 2, SymbolID: 1, Trades: 2, SoldQuantity: 10, SoldNotional: 101000,
+			Fees: -10, NetPosition: -10, NetCash: 101010},
+		{AccountID: 3, SymbolID: 1, Trades: 2, BoughtQuantity: 15, BoughtNotional: 150500,
+			Fees: 30, NetPosition: 15, NetCash: -150530},
+	}
+	if len(positions) != len(want) {
+		t.Fatalf("Expected %d positions, got %+v", len(want), positions)
+	}
+	var net int64
+	for i := range want {
+		if positions[i] != want[i] {
+			t.Errorf("Expected position %+v, got %+v", want[i], positions[i])
+		}
+		net += positions[i].NetPosition
+	}
+	if net != 0 {
+		t.Errorf("Expected the net positions to balance, got %d", net)
+	}
+
+	manager.ResetSession()
+	if positions := manager.Settlement(); len(positions) != 0 {
+		t.Errorf("Expected no positions after the reset, got %+v", positions)
+	}
+	if manager.FeeSchedule().TakerRate != 200 {
+		t.Errorf("Expected the fee schedule to be kept, got %+v", manager.FeeSchedule())
+	}
+}
+
+func TestMarketManager_ReplaceOrderKeepsAccount(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.EnableMatching()
+	manager.AddOrder(accountOrder(1, 1, OrderSideBuy, 10000, 10, 5))
+	if result := manager.ReplaceOrder(1, 2, 10100, 10); result != ErrorOK {
+		t.Fatalf("Expected ErrorOK, got %v", result)
+	}
+	manager.AddOrder(accountOrder(3, 1, OrderSideSell, 10100, 10, 6))
+
+	positions := manager.Settlement()
+	if len(positions) != 2 || positions[0].AccountID != 5 || positions[0].BoughtQuantity != 10 {
+		t.Errorf("Expected the replacement to settle to account 5, got %+v", positions)
+	}
+}
+
+func TestFeeSchedule_Fee(t *testing.T) {
+	fees := FeeSchedule{MakerRate: -25, TakerRate: 30}
+	if fee := fees.fee(false, 1_000_000); fee != 30 {
+		t.Errorf("Expected a taker fee of 30, got %d", fee)
+	}
+	if fee := fees.fee(true, 1_000_000); fee != -25 {
+		t.Errorf("Expected a maker rebate of -25, got %d", fee)
+	}
+	// Truncated toward zero
+	if fee := fees.fee(true, 39_999); fee != 0 {
+		t.Errorf("Expected a truncated rebate of 0, got %d", fee)
+	}
+	fees = FeeSchedule{MakerRate: math.MinInt64, TakerRate: FeeScale * 2}
+	if fee := fees.fee(false, math.MaxUint64); fee != math.MaxInt64 {
+		t.Errorf("Expected a saturated fee, got %d", fee)
+	}
+	if fee := fees.fee(true, math.MaxUint64); fee != -math.MaxInt64 {
+		t.Errorf("Expected a saturated rebate, got %d", fee)
+	}
+}
+
+func TestWriteSettlementCSV(t *testing.T) {
+	positions := []SettlementPosition{
+		{AccountID: 1, SymbolID: 2, Trades: 1, BoughtQuantity: 5, BoughtNotional: 500, Fees: 1, NetPosition: 5, NetCash: -501},
+	}
+	var buf bytes.Buffer
+	if err := WriteSettlementCSV(&buf, positions); err != nil {
+		t.Fatalf("WriteSettlementCSV: %v", err)
+	}
+	want := "Account,Symbol,Trades,BoughtQty,SoldQty,BoughtNotional,SoldNotional,NetPosition,NetCash,Fees\n" +
+		"1,2,1,5,0,500,0,5,-501,1\n"
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestWriteSettlementJSON(t *testing.T) {
+	positions := []SettlementPosition{{AccountID: 1, SymbolID: 2, SoldQuantity: 3, NetPosition: -3}}
+	var buf bytes.Buffer
+	if err := WriteSettlementJSON(&buf, positions); err != nil {
+		t.Fatalf("WriteSettlementJSON: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"net_position": -3`) {
+		t.Errorf("Expected snake_case fields, got %s", buf.String())
+	}
+	var got []SettlementPosition
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil || len(got) != 1 || got[0] != positions[0] {
+		t.Errorf("Expected %+v, got %+v, %v", positions, got, err)
+	}
+
+	buf.Reset()
+	if err := WriteSettlementJSON(&buf, nil); err != nil || strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("Expected an empty array, got %q, %v", buf.String(), err)
+	}
+}
+
+func TestEngine_Settlement(t *testing.T) {
+	engine := NewEngine(2)
+	defer engine.Close()
+	engine.EnableMatching()
+	engine.SetFeeSchedule(FeeSchedule{TakerRate: 100})
+	for id := uint32(1); id <= 2; id++ {
+		symbol := NewSymbol(id, "SYM")
+		engine.AddSymbol(symbol)
+		engine.AddOrderBook(symbol)
+	}
+	for id := uint32(1); id <= 2; id++ {
+		engine.AddOrder(accountOrder(uint64(id)*10, id, OrderSideSell, 10000, 10, 7))
+		engine.AddOrder(accountOrder(uint64(id)*10+1, id, OrderSideBuy, 10000, 4, 8))
+	}
+
+	positions := engine.Settlement()
+	if len(positions) != 4 {
+		t.Fatalf("Expected 4 positions, got %+v", positions)
+	}
+	if p := positions[2]; p.AccountID != 8 || p.SymbolID != 1 || p.Fees != 4 || p.NetCash != -40004 {
+		t.Errorf("Expected account 8 buying symbol 1, got %+v", p)
+	}
+}
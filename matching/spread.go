@@ -0,0 +1,271 @@
+package matching
+
+// SpreadLeg is a leg of a spread: buying one spread trades Ratio of the leg
+// symbol on Side, selling one spread trades it on the other side
+type SpreadLeg struct {
+	// SymbolID is the leg symbol, an outright with an order book
+	SymbolID uint32
+	// Side is the side of the leg for the spread buyer
+	Side OrderSide
+	// Ratio is the leg quantity per spread
+	Ratio uint64
+}
+
+// Spread is the definition of a synthetic instrument combining the legs of
+// existing symbols, such as a futures calendar spread buying the front month
+// and selling the next:
+//
+//	Spread{Legs: []SpreadLeg{
+//		{SymbolID: front, Side: OrderSideBuy, Ratio: 1},
+//		{SymbolID: next, Side: OrderSideSell, Ratio: 1},
+//	}}
+//
+// The spread price is the sum of the leg prices times their ratios, counted
+// negative for sell legs. Prices are unsigned, so a spread expected to trade
+// below zero is defined with its legs reversed.
+type Spread struct {
+	// Legs are the legs of the spread, at least two of different symbols
+	Legs []SpreadLeg
+	// Implied prices the legs of spread trades from the current quotes of the
+	// leg books rather than from their last trades
+	Implied bool
+}
+
+// SpreadQuote is the spread market implied by the best levels of the leg
+// books. A side without quantity has no implied price.
+type SpreadQuote struct {
+	BidPrice    uint64
+	BidQuantity uint64
+	AskPrice    uint64
+	AskQuantity uint64
+}
+
+// AddSpread adds a spread symbol with its order book. Spread orders are added
+// and matched in the spread book like outright orders; every spread trade is
+// followed by the leg trades it generates, one OnTrade per leg with Leg set,
+// at leg prices adding up to the spread price.
+//
+// All legs but the first are priced at their reference price: the last trade
+// of the leg book, or the midpoint of its quote (the best bid or ask of a one
+// sided book) if it has not traded or the spread prices implied. The first
+// leg gets the price making up the spread price. A spread match whose legs
+// cannot be priced that way, for want of a reference price or because the
+// first leg price would not be a positive whole price, does not trade and the
+// spread book is left crossed.
+//
+// Leg trades do not execute leg book orders nor update leg session statistics.
+func (m *MarketManager) AddSpread(symbol Symbol, spread Spread) ErrorCode {
+	defer m.operation()()
+	if err := m.validateSpread(symbol.ID, spread); err != ErrorOK {
+		return err
+	}
+	if err := m.AddSymbol(symbol); err != ErrorOK {
+		return err
+	}
+	if err := m.AddOrderBook(symbol); err != ErrorOK {
+		return err
+	}
+
+	if m.spreads == nil {
+		m.spreads = make(map[uint32]*Spread)
+	}
+	spread.Legs = append([]SpreadLeg(nil), spread.Legs...)
+	m.spreads[symbol.ID] = &spread
+	return ErrorOK
+}
+
+// validateSpread checks the legs of a spread
+func (m *MarketManager) validateSpread(id uint32, spread Spread) ErrorCode {
+	if len(spread.Legs) < 2 {
+		return ErrorSpreadInvalid
+	}
+	seen := make(map[uint32]bool, len(spread.Legs))
+	for _, leg := range spread.Legs {
+		if leg.Ratio == 0 || leg.SymbolID == id || seen[leg.SymbolID] || m.spreads[leg.SymbolID] != nil {
+			return ErrorSpreadInvalid
+		}
+		if _, exists := m.orderBooks[leg.SymbolID]; !exists {
+			return ErrorOrderBookNotFound
+		}
+		seen[leg.SymbolID] = true
+	}
+	return ErrorOK
+}
+
+// Spread returns the definition of a spread symbol
+func (m *MarketManager) Spread(id uint32) (Spread, bool) {
+	spread, exists := m.spreads[id]
+	if !exists {
+		return Spread{}, false
+	}
+	return *spread, true
+}
+
+// isLeg checks if a symbol is the leg of a spread
+func (m *MarketManager) isLeg(id uint32) bool {
+	for _, spread := range m.spreads {
+		for _, leg := range spread.Legs {
+			if leg.SymbolID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ImpliedQuote returns the spread market implied by the best levels of the
+// leg books: the implied bid sells the legs the spread buyer buys at their
+// best bids and buys the others at their best asks, and the implied ask the
+// reverse. The quantity is the number of spreads the visible volume of the
+// best levels fills.
+func (m *MarketManager) ImpliedQuote(id uint32) (SpreadQuote, ErrorCode) {
+	spread, exists := m.spreads[id]
+	if !exists {
+		return SpreadQuote{}, ErrorSymbolNotFound
+	}
+
+	var quote SpreadQuote
+	if price, quantity, ok := m.impliedSide(spread, OrderSideBuy); ok {
+		quote.BidPrice, quote.BidQuantity = price, quantity
+	}
+	if price, quantity, ok := m.impliedSide(spread, OrderSideSell); ok {
+		quote.AskPrice, quote.AskQuantity = price, quantity
+	}
+	return quote, ErrorOK
+}
+
+// impliedSide returns the implied price and quantity of buying (side buy,
+// the implied bid) or selling a spread into the leg books
+func (m *MarketManager) impliedSide(spread *Spread, side OrderSide) (uint64, uint64, bool) {
+	var price int64
+	var quantity uint64
+	for i, leg := range spread.Legs {
+		ob := m.orderBooks[leg.SymbolID]
+		if ob == nil {
+			return 0, 0, false
+		}
+		// The implied bid is hit by a spread seller, who sells the buy legs
+		// into their bids and buys the sell legs from their asks
+		level := ob.bestAsk
+		if leg.Side == side {
+			level = ob.bestBid
+		}
+		sign := int64(1)
+		if leg.Side == OrderSideSell {
+			sign = -1
+		}
+		if level == nil {
+			return 0, 0, false
+		}
+		price += sign * int64(leg.Ratio*level.Price)
+		if fills := level.VisibleVolume / leg.Ratio; i == 0 || fills < quantity {
+			quantity = fills
+		}
+	}
+	if price <= 0 || quantity == 0 {
+		return 0, 0, false
+	}
+	return uint64(price), quantity, true
+}
+
+// referencePrice returns the price a leg of spread trades is priced at, or 0
+// if the leg book has no price
+func referencePrice(ob *OrderBook, implied bool) uint64 {
+	if ob == nil {
+		return 0
+	}
+	if ob.session.Trades > 0 && !implied {
+		return ob.session.Last
+	}
+	switch {
+	case ob.bestBid != nil && ob.bestAsk != nil:
+		return ob.GetMidPrice()
+	case ob.bestBid != nil:
+		return ob.bestBid.Price
+	case ob.bestAsk != nil:
+		return ob.bestAsk.Price
+	default:
+		return ob.session.Last
+	}
+}
+
+// legPrices prices the legs of a spread trade at price, the first leg making
+// up the difference between price and the reference prices of the others
+func (m *MarketManager) legPrices(spread *Spread, price uint64) ([]uint64, bool) {
+	// The first leg is worth price minus the others for the spread buyer, or
+	// the reverse if it is a sell leg
+	first := spread.Legs[0]
+	prices := make([]uint64, len(spread.Legs))
+	residual := int64(price)
+	if first.Side == OrderSideSell {
+		residual = -residual
+	}
+	for i := 1; i < len(spread.Legs); i++ {
+		leg := spread.Legs[i]
+		reference := referencePrice(m.orderBooks[leg.SymbolID], spread.Implied)
+		if reference == 0 {
+			return nil, false
+		}
+		prices[i] = reference
+		value := int64(leg.Ratio * reference)
+		if leg.Side == first.Side {
+			residual -= value
+		} else {
+			residual += value
+		}
+	}
+	if residual <= 0 || residual%int64(first.Ratio) != 0 {
+		return nil, false
+	}
+	prices[0] = uint64(residual) / first.Ratio
+	return prices, true
+}
+
+// canTrade checks if the legs of a match of a spread book can be priced; it
+// is true for outright books
+func (m *MarketManager) canTrade(ob *OrderBook, bidOrder, askOrder *OrderNode) bool {
+	spread := m.spreads[ob.symbol.ID]
+	if spread == nil {
+		return true
+	}
+	_, ok := m.legPrices(spread, m.tradePrice(bidOrder, askOrder))
+	return ok
+}
+
+// tradeLegs reports the leg trades of a spread trade
+func (m *MarketManager) tradeLegs(trade Trade) {
+	spread := m.spreads[trade.SymbolID]
+	if spread == nil {
+		return
+	}
+	prices, ok := m.legPrices(spread, trade.Price)
+	if !ok {
+		return
+	}
+	for i, leg := range spread.Legs {
+		legTrade := Trade{
+			SymbolID:    leg.SymbolID,
+			BuyOrderID:  trade.BuyOrderID,
+			SellOrderID: trade.SellOrderID,
+			Price:       prices[i],
+			Quantity:    leg.Ratio * trade.Quantity,
+			Aggressor:   trade.Aggressor,
+			SpreadID:    trade.SymbolID,
+			Leg:         true,
+		}
+		// The spread seller buys the sell legs
+		if leg.Side == OrderSideSell {
+			legTrade.BuyOrderID, legTrade.SellOrderID = trade.SellOrderID, trade.BuyOrderID
+			legTrade.Aggressor = opposite(trade.Aggressor)
+		}
+		m.handler.OnTrade(legTrade)
+	}
+}
+
+// opposite returns the other side
+func opposite(side OrderSide) OrderSide {
+	if side == OrderSideBuy {
+		return OrderSideSell
+	}
+	return OrderSideBuy
+}
@@ -0,0 +1,201 @@
+package matching
+
+import "testing"
+
+// spreadHandler records the trades of spread tests
+type spreadHandler struct {
+	DefaultMarketHandler
+	trades []Trade
+}
+
+func (h *spreadHandler) OnTrade(trade Trade) {
+	h.trades = append(h.trades, trade)
+}
+
+// newSpreadManager creates a manager with matching enabled, the outrights
+// FRONT (1) and NEXT (2) and the calendar spread CAL (3) buying FRONT and
+// selling NEXT
+func newSpreadManager(t *testing.T, handler MarketHandler, implied bool) *MarketManager {
+	t.Helper()
+	m := NewMarketManagerWithHandler(handler)
+	m.EnableMatching()
+	for _, symbol := range []Symbol{NewSymbol(1, "FRONT"), NewSymbol(2, "NEXT")} {
+		m.AddSymbol(symbol)
+		m.AddOrderBook(symbol)
+	}
+	spread := Spread{
+		Legs: []SpreadLeg{
+			{SymbolID: 1, Side: OrderSideBuy, Ratio: 1},
+			{SymbolID: 2, Side: OrderSideSell, Ratio: 1},
+		},
+		Implied: implied,
+	}
+	if err := m.AddSpread(NewSymbol(3, "CAL"), spread); err != ErrorOK {
+		t.Fatalf("AddSpread failed: %s", err)
+	}
+	return m
+}
+
+func TestSpread_LegTrades(t *testing.T) {
+	handler := &spreadHandler{}
+	m := newSpreadManager(t, handler, false)
+
+	// NEXT is quoted 4990/5010, so its legs are priced at 5000
+	m.AddOrder(*NewLimitOrder(1, 2, OrderSideBuy, 4990, 5))
+	m.AddOrder(*NewLimitOrder(2, 2, OrderSideSell, 5010, 5))
+
+	m.AddOrder(*NewLimitOrder(10, 3, OrderSideBuy, 20, 3))
+	m.AddOrder(*NewLimitOrder(11, 3, OrderSideSell, 20, 3))
+
+	if len(handler.trades) != 3 {
+		t.Fatalf("Expected the spread trade and 2 leg trades, got %+v", handler.trades)
+	}
+	spread, front, next := handler.trades[0], handler.trades[1], handler.trades[2]
+	if spread.SymbolID != 3 || spread.Leg || spread.Price != 20 || spread.Quantity != 3 {
+		t.Errorf("Expected the spread trade of 3 @ 20, got %+v", spread)
+	}
+	if !front.Leg || front.SpreadID != 3 || front.SymbolID != 1 || front.Price != 5020 || front.Quantity != 3 ||
+		front.BuyOrderID != 10 || front.SellOrderID != 11 || front.Aggressor != OrderSideSell {
+		t.Errorf("Expected the spread buyer to buy FRONT at 5020, got %+v", front)
+	}
+	if !next.Leg || next.SymbolID != 2 || next.Price != 5000 || next.Quantity != 3 ||
+		next.BuyOrderID != 11 || next.SellOrderID != 10 || next.Aggressor != OrderSideBuy {
+		t.Errorf("Expected the spread buyer to sell NEXT at 5000, got %+v", next)
+	}
+	if front.Price-next.Price != spread.Price {
+		t.Errorf("Expected leg prices adding up to the spread price, got %d and %d", front.Price, next.Price)
+	}
+	if stats := m.GetOrderBook(1).SessionStats(); stats.Trades != 0 {
+		t.Errorf("Expected no trade in the FRONT book, got %+v", stats)
+	}
+}
+
+func TestSpread_LastTradeReference(t *testing.T) {
+	for _, implied := range []bool{false, true} {
+		handler := &spreadHandler{}
+		m := newSpreadManager(t, handler, implied)
+
+		// NEXT last traded at 4980 and is quoted 4990/5010
+		m.AddOrder(*NewLimitOrder(1, 2, OrderSideBuy, 4980, 1))
+		m.AddOrder(*NewLimitOrder(2, 2, OrderSideSell, 4980, 1))
+		m.AddOrder(*NewLimitOrder(3, 2, OrderSideBuy, 4990, 5))
+		m.AddOrder(*NewLimitOrder(4, 2, OrderSideSell, 5010, 5))
+
+		m.AddOrder(*NewLimitOrder(10, 3, OrderSideSell, 15, 2))
+		m.AddOrder(*NewLimitOrder(11, 3, OrderSideBuy, 15, 2))
+
+		want := uint64(4980)
+		if implied {
+			want = 5000
+		}
+		if n := len(handler.trades); n != 4 || handler.trades[3].Price != want || handler.trades[2].Price != want+15 {
+			t.Errorf("Expected NEXT priced at %d with implied %v, got %+v", want, implied, handler.trades)
+		}
+	}
+}
+
+func TestSpread_Unpriceable(t *testing.T) {
+	handler := &spreadHandler{}
+	m := newSpreadManager(t, handler, false)
+
+	// NEXT has no price, so the spread cannot trade
+	m.AddOrder(*NewLimitOrder(10, 3, OrderSideBuy, 20, 3))
+	m.AddOrder(*NewLimitOrder(11, 3, OrderSideSell, 20, 3))
+	if len(handler.trades) != 0 || !m.GetOrderBook(3).IsCrossed() {
+		t.Fatalf("Expected the spread book left crossed, got %+v", handler.trades)
+	}
+
+	// Once NEXT is quoted, matching the spread book trades
+	m.AddOrder(*NewLimitOrder(1, 2, OrderSideBuy, 4990, 5))
+	m.Match(3)
+	if len(handler.trades) != 3 || handler.trades[1].Price != 5010 {
+		t.Errorf("Expected the spread to trade with FRONT at 5010, got %+v", handler.trades)
+	}
+}
+
+func TestSpread_RatioLegs(t *testing.T) {
+	handler := &spreadHandler{}
+	m := NewMarketManagerWithHandler(handler)
+	m.EnableMatching()
+	for _, symbol := range []Symbol{NewSymbol(1, "A"), NewSymbol(2, "B")} {
+		m.AddSymbol(symbol)
+		m.AddOrderBook(symbol)
+	}
+	// A ratio spread selling two B for every A
+	spread := Spread{Legs: []SpreadLeg{
+		{SymbolID: 1, Side: OrderSideBuy, Ratio: 1},
+		{SymbolID: 2, Side: OrderSideSell, Ratio: 2},
+	}}
+	if err := m.AddSpread(NewSymbol(3, "A-2B"), spread); err != ErrorOK {
+		t.Fatalf("AddSpread failed: %s", err)
+	}
+	m.AddOrder(*NewLimitOrder(1, 2, OrderSideBuy, 100, 1))
+
+	m.AddOrder(*NewLimitOrder(10, 3, OrderSideBuy, 50, 4))
+	m.AddOrder(*NewLimitOrder(11, 3, OrderSideSell, 50, 4))
+	if len(handler.trades) != 3 {
+		t.Fatalf("Expected the spread trade and 2 leg trades, got %+v", handler.trades)
+	}
+	if a, b := handler.trades[1], handler.trades[2]; a.Price != 250 || a.Quantity != 4 || b.Price != 100 || b.Quantity != 8 {
+		t.Errorf("Expected 4 A at 250 and 8 B at 100, got %+v and %+v", a, b)
+	}
+}
+
+func TestSpread_Validation(t *testing.T) {
+	m := newSpreadManager(t, &DefaultMarketHandler{}, false)
+
+	tests := []struct {
+		name string
+		legs []SpreadLeg
+		want ErrorCode
+	}{
+		{"single leg", []SpreadLeg{{SymbolID: 1, Ratio: 1}}, ErrorSpreadInvalid},
+		{"zero ratio", []SpreadLeg{{SymbolID: 1, Ratio: 1}, {SymbolID: 2}}, ErrorSpreadInvalid},
+		{"duplicate leg", []SpreadLeg{{SymbolID: 1, Ratio: 1}, {SymbolID: 1, Ratio: 1}}, ErrorSpreadInvalid},
+		{"spread leg", []SpreadLeg{{SymbolID: 1, Ratio: 1}, {SymbolID: 3, Ratio: 1}}, ErrorSpreadInvalid},
+		{"unknown leg", []SpreadLeg{{SymbolID: 1, Ratio: 1}, {SymbolID: 9, Ratio: 1}}, ErrorOrderBookNotFound},
+	}
+	for _, tt := range tests {
+		if err := m.AddSpread(NewSymbol(4, "BAD"), Spread{Legs: tt.legs}); err != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, err)
+		}
+	}
+	if m.GetSymbol(4) != nil {
+		t.Error("Expected no symbol for the invalid spreads")
+	}
+
+	if err := m.DeleteSymbol(1); err != ErrorSymbolInUse {
+		t.Errorf("Expected a leg to be in use, got %s", err)
+	}
+	if err := m.DeleteSymbol(3); err != ErrorOK {
+		t.Errorf("Expected the spread deleted, got %s", err)
+	}
+	if _, ok := m.Spread(3); ok {
+		t.Error("Expected the spread definition deleted")
+	}
+	if err := m.DeleteSymbol(1); err != ErrorOK {
+		t.Errorf("Expected the former leg deleted, got %s", err)
+	}
+}
+
+func TestSpread_ImpliedQuote(t *testing.T) {
+	m := newSpreadManager(t, &DefaultMarketHandler{}, false)
+	m.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 5020, 4))
+	m.AddOrder(*NewLimitOrder(2, 1, OrderSideSell, 5030, 10))
+	m.AddOrder(*NewLimitOrder(3, 2, OrderSideBuy, 4990, 6))
+	m.AddOrder(*NewLimitOrder(4, 2, OrderSideSell, 5000, 2))
+
+	quote, err := m.ImpliedQuote(3)
+	if err != ErrorOK {
+		t.Fatalf("ImpliedQuote failed: %s", err)
+	}
+	// Bid: sell FRONT at 5020, buy NEXT at 5000; ask: buy FRONT at 5030,
+	// sell NEXT at 4990
+	want := SpreadQuote{BidPrice: 20, BidQuantity: 2, AskPrice: 40, AskQuantity: 6}
+	if quote != want {
+		t.Errorf("Expected %+v, got %+v", want, quote)
+	}
+	if _, err := m.ImpliedQuote(1); err != ErrorSymbolNotFound {
+		t.Errorf("Expected no implied quote of an outright, got %s", err)
+	}
+}
@@ -0,0 +1,48 @@
+package matching
+
+import "github.com/tienpsm/go-trader/metrics"
+
+// Statistics contains the latency measurements of the market manager and
+// the liquidity counters of the current session
+type Statistics struct {
+	// AddOrderLatency is the time from entering AddOrder until it returns,
+	// including matching and all handler callbacks
+	AddOrderLatency metrics.Snapshot
+	// Liquidity is the maker and taker volume of each participant that
+	// traded in the current session, reset by ResetSession
+	Liquidity map[uint32]LiquidityStats
+}
+
+// EnableLatencyTracking starts recording operation latencies.
+// Tracking is off by default because reading the clock adds overhead.
+func (m *MarketManager) EnableLatencyTracking() {
+	if m.addOrderLatency == nil {
+		m.addOrderLatency = metrics.NewHistogram()
+	}
+}
+
+// DisableLatencyTracking stops recording operation latencies and discards
+// the recorded values
+func (m *MarketManager) DisableLatencyTracking() {
+	m.addOrderLatency = nil
+}
+
+// IsLatencyTrackingEnabled returns true if operation latencies are recorded
+func (m *MarketManager) IsLatencyTrackingEnabled() bool {
+	return m.addOrderLatency != nil
+}
+
+// Statistics returns the recorded latency statistics and a copy of the
+// liquidity counters.
+// The snapshots are empty if latency tracking is disabled.
+func (m *MarketManager) Statistics() Statistics {
+	var stats Statistics
+	if m.addOrderLatency != nil {
+		stats.AddOrderLatency = m.addOrderLatency.Snapshot()
+	}
+	stats.Liquidity = make(map[uint32]LiquidityStats, len(m.liquidity))
+	for id, liquidity := range m.liquidity {
+		stats.Liquidity[id] = *liquidity
+	}
+	return stats
+}
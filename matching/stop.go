@@ -0,0 +1,28 @@
+package matching
+
+import "math"
+
+// TriggerStop returns the order a triggered stop order enters the book as,
+// by the price protection of the book. Stop and trailing stop orders become
+// limit orders at their stop price plus (buy) or minus (sell) StopProtection,
+// clamped at 0 and the maximum price, so that they cannot sweep a thin book;
+// without protection they stay market orders. Other orders, including stop
+// limit orders which keep their limit price, are returned unchanged.
+func (c SymbolConfig) TriggerStop(order Order) Order {
+	if c.StopProtection == 0 || !order.IsStop() && !order.IsTrailingStop() {
+		return order
+	}
+	order.Type = OrderTypeLimit
+	if order.IsBuy() {
+		order.Price = math.MaxUint64
+		if order.StopPrice < math.MaxUint64-c.StopProtection {
+			order.Price = order.StopPrice + c.StopProtection
+		}
+	} else {
+		order.Price = 0
+		if order.StopPrice > c.StopProtection {
+			order.Price = order.StopPrice - c.StopProtection
+		}
+	}
+	return order
+}
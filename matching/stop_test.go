@@ -0,0 +1,44 @@
+package matching
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSymbolConfig_TriggerStop(t *testing.T) {
+	config := SymbolConfig{TickSize: 10, StopProtection: 50}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if (SymbolConfig{TickSize: 10, StopProtection: 55}).Validate() == nil {
+		t.Error("Expected a stop protection off the tick size to be invalid")
+	}
+
+	tests := []struct {
+		name  string
+		order *Order
+		price uint64
+	}{
+		{"buy stop", NewStopOrder(1, 1, OrderSideBuy, 10000, 10), 10050},
+		{"sell stop", NewStopOrder(2, 1, OrderSideSell, 10000, 10), 9950},
+		{"sell stop clamped at 0", NewStopOrder(3, 1, OrderSideSell, 30, 10), 0},
+		{"buy stop clamped at the maximum", NewStopOrder(4, 1, OrderSideBuy, math.MaxUint64-20, 10), math.MaxUint64},
+		{"trailing stop", NewOrder(5, 1, OrderTypeTrailingStop, OrderSideSell, 0, 9800, 10), 9750},
+	}
+	for _, tt := range tests {
+		order := config.TriggerStop(*tt.order)
+		if order.Type != OrderTypeLimit || order.Price != tt.price || order.Quantity != 10 {
+			t.Errorf("%s: expected a limit order at %d, got %+v", tt.name, tt.price, order)
+		}
+	}
+
+	// Stop limit orders keep their price and unprotected stops stay market
+	stopLimit := NewStopLimitOrder(6, 1, OrderSideBuy, 10100, 10000, 10)
+	if order := config.TriggerStop(*stopLimit); order != *stopLimit {
+		t.Errorf("Expected the stop limit order unchanged, got %+v", order)
+	}
+	stop := NewStopOrder(7, 1, OrderSideBuy, 10000, 10)
+	if order := (SymbolConfig{}).TriggerStop(*stop); order != *stop {
+		t.Errorf("Expected the unprotected stop unchanged, got %+v", order)
+	}
+}
@@ -0,0 +1,22 @@
+package matching
+
+// Trade represents a single match between a buy and a sell order
+type Trade struct {
+	// SymbolID is the symbol the trade was executed for
+	SymbolID uint32
+	// BuyOrderID is the ID of the buy order
+	BuyOrderID uint64
+	// SellOrderID is the ID of the sell order
+	SellOrderID uint64
+	// Price is the execution price
+	Price uint64
+	// Quantity is the executed quantity
+	Quantity uint64
+	// Aggressor is the side of the order that took liquidity
+	Aggressor OrderSide
+	// Leg is set for the leg trades of a spread trade, whose order IDs are
+	// those of the spread orders
+	Leg bool
+	// SpreadID is the spread symbol of a leg trade
+	SpreadID uint32
+}
@@ -0,0 +1,94 @@
+package matching
+
+import "math"
+
+// TrailingPercentScale is the percentage-mode trailing distance or step of
+// 100%: a negative TrailingDistance or TrailingStep of -n trails by n/100
+// percent of the market price, so -1 is 0.01% and -10000 is 100%
+const TrailingPercentScale = 10000
+
+// TrailingPercent converts a percentage, such as 1.25 for 1.25%, into a
+// percentage-mode trailing distance or step, rounded to the nearest 0.01%.
+// It returns 0, an invalid distance, for percentages rounding outside
+// 0.01% to 100%.
+func TrailingPercent(percent float64) int64 {
+	units := math.Round(percent * TrailingPercentScale / 100)
+	if !(units >= 1 && units <= TrailingPercentScale) {
+		return 0
+	}
+	return -int64(units)
+}
+
+// TrailingPercentOf returns the percentage of a percentage-mode trailing
+// distance or step, 0 for an absolute one
+func TrailingPercentOf(value int64) float64 {
+	if value >= 0 {
+		return 0
+	}
+	return float64(-value) * 100 / TrailingPercentScale
+}
+
+// TrailingAmount returns the price distance of a trailing distance or step
+// at a market price: the value itself in absolute mode, its percentage of
+// price rounded down in percentage mode
+func TrailingAmount(value int64, price uint64) uint64 {
+	if value >= 0 {
+		return uint64(value)
+	}
+	return mulDiv(price, uint64(-value), TrailingPercentScale, false)
+}
+
+// IsTrailingPercent returns true if the trailing distance of the order is a
+// percentage of the market price
+func (o *Order) IsTrailingPercent() bool {
+	return o.TrailingDistance < 0
+}
+
+// TrailingStopPrice returns the stop price of a trailing stop order after the
+// market moved to price. The stop trails the market by the trailing
+// distance, above it for buy orders and below it for sell orders. It only
+// moves toward the market, by at least the trailing step, and is otherwise
+// the current stop price. A buy order without a stop price takes the
+// trailing price at once.
+func (o *Order) TrailingStopPrice(price uint64) uint64 {
+	distance := TrailingAmount(o.TrailingDistance, price)
+	step := TrailingAmount(o.TrailingStep, price)
+	if o.IsBuy() {
+		stop := uint64(math.MaxUint64)
+		if price < math.MaxUint64-distance {
+			stop = price + distance
+		}
+		if o.StopPrice == 0 || (stop < o.StopPrice && o.StopPrice-stop >= step) {
+			return stop
+		}
+		return o.StopPrice
+	}
+	var stop uint64
+	if price > distance {
+		stop = price - distance
+	}
+	if stop > o.StopPrice && stop-o.StopPrice >= step {
+		return stop
+	}
+	return o.StopPrice
+}
+
+// checkTrailing checks the trailing distance and step of a trailing stop
+// order: the distance is set, at most 100% in percentage mode, and the step
+// is 0 or in the units of the distance and smaller than it
+func checkTrailing(order *Order) ErrorCode {
+	distance, step := order.TrailingDistance, order.TrailingStep
+	switch {
+	case distance == 0:
+		return ErrorOrderParameterInvalid
+	case distance < 0:
+		if distance < -TrailingPercentScale || step > 0 || step <= distance {
+			return ErrorOrderParameterInvalid
+		}
+	default:
+		if step < 0 || step >= distance {
+			return ErrorOrderParameterInvalid
+		}
+	}
+	return ErrorOK
+}
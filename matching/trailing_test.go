@@ -0,0 +1,160 @@
+package matching
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"testing/quick"
+)
+
+func TestTrailingPercent(t *testing.T) {
+	tests := []struct {
+		percent float64
+		value   int64
+	}{
+		{1.25, -125},
+		{0.01, -1},
+		{100, -10000},
+		{0.004, 0},
+		{0.005, -1},
+		{100.004, -10000},
+		{100.01, 0},
+		{-1, 0},
+		{math.NaN(), 0},
+	}
+	for _, tt := range tests {
+		if got := TrailingPercent(tt.percent); got != tt.value {
+			t.Errorf("TrailingPercent(%v): expected %d, got %d", tt.percent, tt.value, got)
+		}
+	}
+	if TrailingPercentOf(-125) != 1.25 || TrailingPercentOf(50) != 0 {
+		t.Errorf("Expected 1.25%% and 0, got %v and %v", TrailingPercentOf(-125), TrailingPercentOf(50))
+	}
+}
+
+func TestTrailingPercent_Properties(t *testing.T) {
+	// Every 0.01% step converts back to itself
+	roundTrip := func(n uint16) bool {
+		value := -int64(n%TrailingPercentScale) - 1
+		return TrailingPercent(TrailingPercentOf(value)) == value
+	}
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Error(err)
+	}
+
+	// Percentages round to the nearest 0.01%
+	nearest := func(n uint32) bool {
+		percent := float64(n%10_000_000)/100_000 + 0.01
+		value := TrailingPercent(percent)
+		return value < 0 && math.Abs(TrailingPercentOf(value)-percent) <= 0.005+1e-9
+	}
+	if err := quick.Check(nearest, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTrailingAmount_Properties(t *testing.T) {
+	// A percentage amount is the exact amount rounded down
+	floor := func(n uint16, price uint64) bool {
+		units := uint64(n%TrailingPercentScale) + 1
+		amount := TrailingAmount(-int64(units), price)
+		exact := new(big.Int).Mul(new(big.Int).SetUint64(price), new(big.Int).SetUint64(units))
+		low := new(big.Int).Mul(new(big.Int).SetUint64(amount), big.NewInt(TrailingPercentScale))
+		high := new(big.Int).Add(low, big.NewInt(TrailingPercentScale))
+		return low.Cmp(exact) <= 0 && exact.Cmp(high) < 0 && amount <= price
+	}
+	if err := quick.Check(floor, nil); err != nil {
+		t.Error(err)
+	}
+
+	// Amounts grow with the price and the percentage
+	monotonic := func(n, m uint16, a, b uint64) bool {
+		u, v := int64(n%TrailingPercentScale)+1, int64(m%TrailingPercentScale)+1
+		if u > v {
+			u, v = v, u
+		}
+		if a > b {
+			a, b = b, a
+		}
+		return TrailingAmount(-u, a) <= TrailingAmount(-u, b) && TrailingAmount(-u, a) <= TrailingAmount(-v, a)
+	}
+	if err := quick.Check(monotonic, nil); err != nil {
+		t.Error(err)
+	}
+
+	if TrailingAmount(25, 10000) != 25 {
+		t.Errorf("Expected an absolute amount of 25, got %d", TrailingAmount(25, 10000))
+	}
+}
+
+func TestOrder_TrailingStopPrice(t *testing.T) {
+	sell := NewOrder(1, 1, OrderTypeTrailingStop, OrderSideSell, 0, 9000, 10)
+	sell.TrailingDistance = TrailingPercent(1)
+	sell.TrailingStep = TrailingPercent(0.1)
+
+	// 1% below 10000, then not moved by less than the 0.1% step
+	if stop := sell.TrailingStopPrice(10000); stop != 9900 {
+		t.Fatalf("Expected a stop at 9900, got %d", stop)
+	}
+	sell.StopPrice = 9900
+	if stop := sell.TrailingStopPrice(10005); stop != 9900 {
+		t.Errorf("Expected the stop to stay at 9900, got %d", stop)
+	}
+	if stop := sell.TrailingStopPrice(9000); stop != 9900 {
+		t.Errorf("Expected the stop not to follow the market down, got %d", stop)
+	}
+
+	buy := NewOrder(2, 1, OrderTypeTrailingStop, OrderSideBuy, 0, 0, 10)
+	buy.TrailingDistance = 50
+	if stop := buy.TrailingStopPrice(10000); stop != 10050 {
+		t.Fatalf("Expected a stop at 10050, got %d", stop)
+	}
+	buy.StopPrice = 10050
+	if stop := buy.TrailingStopPrice(9900); stop != 9950 {
+		t.Errorf("Expected the stop to follow the market down to 9950, got %d", stop)
+	}
+
+	// Stops only move toward the market and stay on their side of it
+	sellSide := func(n uint16, stop, price uint64) bool {
+		order := Order{Side: OrderSideSell, StopPrice: stop, TrailingDistance: -int64(n%TrailingPercentScale) - 1}
+		next := order.TrailingStopPrice(price)
+		return next >= stop && (next == stop || next <= price)
+	}
+	if err := quick.Check(sellSide, nil); err != nil {
+		t.Error(err)
+	}
+	buySide := func(n uint16, stop, price uint64) bool {
+		order := Order{Side: OrderSideBuy, StopPrice: stop | 1, TrailingDistance: -int64(n%TrailingPercentScale) - 1}
+		next := order.TrailingStopPrice(price)
+		return next <= stop|1 && (next == stop|1 || next >= price)
+	}
+	if err := quick.Check(buySide, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMarketManager_TrailingValidation(t *testing.T) {
+	tests := []struct {
+		distance, step int64
+		code           ErrorCode
+	}{
+		{100, 0, ErrorOK},
+		{100, 10, ErrorOK},
+		{-100, -10, ErrorOK},
+		{-TrailingPercentScale, 0, ErrorOK},
+		{0, 0, ErrorOrderParameterInvalid},
+		{-TrailingPercentScale - 1, 0, ErrorOrderParameterInvalid},
+		{100, 100, ErrorOrderParameterInvalid},
+		{-100, -100, ErrorOrderParameterInvalid},
+		{100, -10, ErrorOrderParameterInvalid},
+		{-100, 10, ErrorOrderParameterInvalid},
+	}
+	manager := newConfigManager(&DefaultMarketHandler{})
+	for i, tt := range tests {
+		order := NewOrder(uint64(i+1), 1, OrderTypeTrailingStop, OrderSideSell, 0, 9000, 10)
+		order.TrailingDistance, order.TrailingStep = tt.distance, tt.step
+		if code := manager.AddOrder(*order); code != tt.code {
+			t.Errorf("Distance %d and step %d: expected %s, got %s", tt.distance, tt.step, tt.code, code)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package matching
+
+// BookView is a read-only copy of an order book at one point, returned by
+// OrderBook.Snapshot. It does not refer to the book, so it can be handed to
+// other goroutines, for instance for analytics, while the engine keeps
+// changing the book, without any locking.
+//
+// The level slices are shared by the views taken while the levels of the
+// book are unchanged, and must not be modified.
+type BookView struct {
+	Symbol Symbol
+	Config SymbolConfig
+	// Bids and Asks are the top levels of each side, best first
+	Bids          []Level
+	Asks          []Level
+	LastBidPrice  uint64
+	LastAskPrice  uint64
+	MatchingPrice uint64
+	Session       SessionStats
+	Checksum      uint32
+}
+
+// BestBid returns the best bid level, or false if there are no bids
+func (v BookView) BestBid() (Level, bool) {
+	if len(v.Bids) == 0 {
+		return Level{}, false
+	}
+	return v.Bids[0], true
+}
+
+// BestAsk returns the best ask level, or false if there are no asks
+func (v BookView) BestAsk() (Level, bool) {
+	if len(v.Asks) == 0 {
+		return Level{}, false
+	}
+	return v.Asks[0], true
+}
+
+// viewState caches the levels of the last view of an order book, which are
+// copied again only after a change to a limit order level
+type viewState struct {
+	valid  bool
+	levels int
+	bids   []Level
+	asks   []Level
+}
+
+// Snapshot returns a read-only view of the top levels of each side, every
+// level for levels <= 0, and the state of the book. Like any other use of
+// the book it must be called on the goroutine running the engine, such as in
+// a handler or with Engine.Do; the view returned can then be used anywhere.
+//
+// The levels are copied on write: views taken while the levels are unchanged
+// share them, so taking a view of a quiet book allocates nothing.
+func (ob *OrderBook) Snapshot(levels int) BookView {
+	vs := &ob.view
+	if !vs.valid || vs.levels != levels {
+		vs.bids = topLevels(ob.bids, levels)
+		vs.asks = topLevels(ob.asks, levels)
+		vs.levels = levels
+		vs.valid = true
+	}
+	return BookView{
+		Symbol:        ob.symbol,
+		Config:        ob.config,
+		Bids:          vs.bids,
+		Asks:          vs.asks,
+		LastBidPrice:  ob.lastBidPrice,
+		LastAskPrice:  ob.lastAskPrice,
+		MatchingPrice: ob.matchingPrice,
+		Session:       ob.session,
+		Checksum:      ob.Checksum(),
+	}
+}
+
+// invalidateView discards the cached view levels if order is on a limit
+// order level. The slices are replaced, not modified, so earlier views keep
+// their levels.
+func (ob *OrderBook) invalidateView(order *OrderNode) {
+	if order.IsStop() || order.IsStopLimit() || order.IsTrailingStop() || order.IsTrailingStopLimit() {
+		return
+	}
+	ob.view = viewState{}
+}
@@ -0,0 +1,84 @@
+package matching
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestOrderBook_Snapshot(t *testing.T) {
+	manager := newConfigManager(&DefaultMarketHandler{})
+	manager.EnableMatching()
+	manager.AddOrder(*NewLimitOrder(1, 1, OrderSideBuy, 10000, 100))
+	manager.AddOrder(*NewLimitOrder(2, 1, OrderSideBuy, 9900, 50))
+	manager.AddOrder(*NewLimitOrder(3, 1, OrderSideSell, 10100, 70))
+	ob := manager.GetOrderBook(1)
+
+	view := ob.Snapshot(1)
+	if len(view.Bids) != 1 || view.Bids[0].Price != 10000 || len(view.Asks) != 1 {
+		t.Fatalf("Expected the top level of each side, got %+v", view)
+	}
+	if bid, ok := view.BestBid(); !ok || bid.TotalVolume != 100 {
+		t.Errorf("Expected a best bid of 100, got %+v", bid)
+	}
+	if view.Symbol.Name != "AAPL" || view.Checksum != ob.Checksum() {
+		t.Errorf("Expected the book metadata, got %+v", view)
+	}
+
+	// An unchanged book shares the levels, a stop order leaves them valid
+	manager.AddOrder(*NewStopOrder(4, 1, OrderSideBuy, 10500, 10))
+	if again := ob.Snapshot(1); &again.Bids[0] != &view.Bids[0] {
+		t.Error("Expected views of an unchanged book to share their levels")
+	}
+
+	// A trade copies the levels again and leaves the earlier view alone
+	manager.AddOrder(*NewLimitOrder(5, 1, OrderSideSell, 10000, 40))
+	after := ob.Snapshot(0)
+	if view.Bids[0].TotalVolume != 100 || view.Session.Trades != 0 {
+		t.Errorf("Expected the earlier view to be unchanged, got %+v", view)
+	}
+	if len(after.Bids) != 2 || after.Bids[0].TotalVolume != 60 || after.Session.Trades != 1 || after.Session.Last != 10000 {
+		t.Errorf("Expected 60 left at the best bid after a trade, got %+v", after)
+	}
+	if _, ok := (BookView{}).BestAsk(); ok {
+		t.Error("Expected no best ask in an empty view")
+	}
+}
+
+func TestEngine_BookSnapshot(t *testing.T) {
+	engine := NewEngine(2)
+	defer engine.Close()
+	symbol := NewSymbol(1, "AAPL")
+	engine.AddSymbol(symbol)
+	engine.AddOrderBook(symbol)
+	engine.EnableMatching()
+	if _, err := engine.BookSnapshot(2, 5); err != ErrorOrderBookNotFound {
+		t.Errorf("Expected ErrorOrderBookNotFound, got %s", err)
+	}
+
+	// Readers use the views while the engine keeps changing the book
+	views := make(chan BookView)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for view := range views {
+			var volume uint64
+			for _, level := range view.Bids {
+				volume += level.TotalVolume
+			}
+			if volume != uint64(len(view.Bids))*10 {
+				t.Errorf("Expected 10 per level, got %d in %d levels", volume, len(view.Bids))
+			}
+		}
+	}()
+	for i := uint64(1); i <= 100; i++ {
+		engine.AddOrder(*NewLimitOrder(i, 1, OrderSideBuy, 10000-i, 10))
+		view, err := engine.BookSnapshot(1, 0)
+		if err != ErrorOK {
+			t.Fatalf("Expected OK, got %s", err)
+		}
+		views <- view
+	}
+	close(views)
+	wg.Wait()
+}
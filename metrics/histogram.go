@@ -0,0 +1,227 @@
+// Package metrics provides lock-free latency histograms for instrumenting the
+// matching engine and persistence layer.
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// subBucketBits is the number of bits of precision kept within each power of
+// two. 16 linear sub-buckets bound the relative error of a recorded value to
+// 1/16 (6.25%), in the style of HDR histograms.
+const subBucketBits = 4
+
+const (
+	subBuckets  = 1 << subBucketBits
+	bucketCount = (64 - subBucketBits + 1) * subBuckets
+)
+
+// bucketIndex returns the bucket holding value v
+func bucketIndex(v uint64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	exp := bits.Len64(v) - 1
+	sub := (v >> (exp - subBucketBits)) & (subBuckets - 1)
+	return (exp-subBucketBits+1)*subBuckets + int(sub)
+}
+
+// bucketRange returns the lowest and highest value held by a bucket
+func bucketRange(index int) (uint64, uint64) {
+	if index < subBuckets {
+		return uint64(index), uint64(index)
+	}
+	exp := index/subBuckets + subBucketBits - 1
+	sub := uint64(index % subBuckets)
+	shift := exp - subBucketBits
+	low := (subBuckets + sub) << shift
+	return low, low + (1 << shift) - 1
+}
+
+// Histogram records durations into logarithmic buckets with linear
+// sub-buckets. Recording is lock-free and safe for concurrent use; the zero
+// value is ready to use.
+type Histogram struct {
+	counts [bucketCount]atomic.Uint64
+	count  atomic.Uint64
+	sum    atomic.Uint64
+	max    atomic.Uint64
+	// minInv holds the bitwise complement of the minimum, so that the zero
+	// value means "no minimum" and it can be updated like max
+	minInv atomic.Uint64
+}
+
+// NewHistogram creates an empty histogram
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// Record adds a duration to the histogram. Negative durations count as 0.
+func (h *Histogram) Record(d time.Duration) {
+	v := uint64(0)
+	if d > 0 {
+		v = uint64(d)
+	}
+
+	h.counts[bucketIndex(v)].Add(1)
+	h.count.Add(1)
+	h.sum.Add(v)
+
+	storeMax(&h.max, v)
+	storeMax(&h.minInv, ^v)
+}
+
+// storeMax atomically raises a to v if v is larger
+func storeMax(a *atomic.Uint64, v uint64) {
+	for {
+		current := a.Load()
+		if v <= current || a.CompareAndSwap(current, v) {
+			return
+		}
+	}
+}
+
+// Since records the time elapsed since start
+func (h *Histogram) Since(start time.Time) {
+	h.Record(time.Since(start))
+}
+
+// Reset clears all recorded values. Values recorded concurrently with Reset
+// may be partially lost.
+func (h *Histogram) Reset() {
+	for i := range h.counts {
+		h.counts[i].Store(0)
+	}
+	h.count.Store(0)
+	h.sum.Store(0)
+	h.max.Store(0)
+	h.minInv.Store(0)
+}
+
+// Snapshot returns a point-in-time copy of the histogram. Values recorded
+// while the snapshot is taken may or may not be included.
+func (h *Histogram) Snapshot() Snapshot {
+	s := Snapshot{
+		Count: h.count.Load(),
+		Min:   time.Duration(^h.minInv.Load()),
+		Max:   time.Duration(h.max.Load()),
+		sum:   h.sum.Load(),
+	}
+	if s.Count == 0 {
+		s.Min = 0
+	}
+	for i := range h.counts {
+		if c := h.counts[i].Load(); c > 0 {
+			s.buckets = append(s.buckets, bucket{index: i, count: c})
+		}
+	}
+	return s
+}
+
+// bucket is a non-empty histogram bucket in a snapshot
+type bucket struct {
+	index int
+	count uint64
+}
+
+// Snapshot is an immutable copy of a Histogram
+type Snapshot struct {
+	// Count is the number of recorded values
+	Count uint64
+	// Min is the smallest recorded value
+	Min time.Duration
+	// Max is the largest recorded value
+	Max time.Duration
+
+	sum     uint64
+	buckets []bucket
+}
+
+// Mean returns the average recorded value
+func (s Snapshot) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return time.Duration(s.sum / s.Count)
+}
+
+// Percentile returns the value at or below which the fraction q (0..1) of
+// recorded values fall. The result is the upper bound of the bucket holding
+// the value, so it overstates the true value by at most 6.25%.
+func (s Snapshot) Percentile(q float64) time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return s.Min
+	}
+	if q >= 1 {
+		return s.Max
+	}
+
+	rank := uint64(math.Ceil(q * float64(s.Count)))
+	var seen uint64
+	for _, b := range s.buckets {
+		seen += b.count
+		if seen >= rank {
+			_, high := bucketRange(b.index)
+			if v := time.Duration(high); v < s.Max {
+				return v
+			}
+			return s.Max
+		}
+	}
+	return s.Max
+}
+
+// Merge returns a snapshot combining the values of s and other, such as the
+// histograms of several files or workers
+func (s Snapshot) Merge(other Snapshot) Snapshot {
+	if other.Count == 0 {
+		return s
+	}
+	if s.Count == 0 {
+		return other
+	}
+
+	merged := Snapshot{
+		Count:   s.Count + other.Count,
+		Min:     s.Min,
+		Max:     s.Max,
+		sum:     s.sum + other.sum,
+		buckets: make([]bucket, 0, len(s.buckets)+len(other.buckets)),
+	}
+	if other.Min < merged.Min {
+		merged.Min = other.Min
+	}
+	if other.Max > merged.Max {
+		merged.Max = other.Max
+	}
+
+	i, j := 0, 0
+	for i < len(s.buckets) || j < len(other.buckets) {
+		switch {
+		case j == len(other.buckets) || (i < len(s.buckets) && s.buckets[i].index < other.buckets[j].index):
+			merged.buckets = append(merged.buckets, s.buckets[i])
+			i++
+		case i == len(s.buckets) || other.buckets[j].index < s.buckets[i].index:
+			merged.buckets = append(merged.buckets, other.buckets[j])
+			j++
+		default:
+			merged.buckets = append(merged.buckets, bucket{index: s.buckets[i].index, count: s.buckets[i].count + other.buckets[j].count})
+			i++
+			j++
+		}
+	}
+	return merged
+}
+
+// String returns a one-line summary of the snapshot
+func (s Snapshot) String() string {
+	return fmt.Sprintf("count=%d min=%v mean=%v p50=%v p99=%v p99.9=%v max=%v",
+		s.Count, s.Min, s.Mean(), s.Percentile(0.5), s.Percentile(0.99), s.Percentile(0.999), s.Max)
+}
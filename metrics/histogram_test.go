@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBucketIndexRange(t *testing.T) {
+	for _, v := range []uint64{0, 1, 15, 16, 17, 31, 32, 33, 1000, 123456789, 1 << 40, 1<<63 + 12345} {
+		low, high := bucketRange(bucketIndex(v))
+		if v < low || v > high {
+			t.Errorf("Value %d outside bucket range %d-%d", v, low, high)
+		}
+		if v >= 16 && float64(high-low+1)/float64(low) > 1.0/16 {
+			t.Errorf("Bucket %d-%d too wide for %d", low, high, v)
+		}
+	}
+	if bucketIndex(^uint64(0)) != bucketCount-1 {
+		t.Errorf("Expected max value in last bucket, got %d", bucketIndex(^uint64(0)))
+	}
+}
+
+func TestHistogram_Percentiles(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+
+	s := h.Snapshot()
+	if s.Count != 1000 {
+		t.Errorf("Expected count 1000, got %d", s.Count)
+	}
+	if s.Min != time.Microsecond || s.Max != time.Millisecond {
+		t.Errorf("Expected range 1µs-1ms, got %v-%v", s.Min, s.Max)
+	}
+	if s.Mean() != 500500*time.Nanosecond {
+		t.Errorf("Expected mean 500.5µs, got %v", s.Mean())
+	}
+
+	tests := []struct {
+		q        float64
+		expected time.Duration
+	}{
+		{0.5, 500 * time.Microsecond},
+		{0.99, 990 * time.Microsecond},
+		{1, time.Millisecond},
+	}
+	for _, tt := range tests {
+		got := s.Percentile(tt.q)
+		if got < tt.expected || float64(got) > float64(tt.expected)*1.0625 {
+			t.Errorf("p%v: expected %v within 6.25%%, got %v", tt.q*100, tt.expected, got)
+		}
+	}
+}
+
+func TestHistogram_Empty(t *testing.T) {
+	s := NewHistogram().Snapshot()
+	if s.Count != 0 || s.Min != 0 || s.Max != 0 || s.Percentile(0.99) != 0 || s.Mean() != 0 {
+		t.Errorf("Expected empty snapshot, got %v", s)
+	}
+}
+
+func TestSnapshot_Merge(t *testing.T) {
+	a, b, all := NewHistogram(), NewHistogram(), NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		d := time.Duration(i) * time.Microsecond
+		if i%3 == 0 {
+			a.Record(d)
+		} else {
+			b.Record(d)
+		}
+		all.Record(d)
+	}
+
+	merged := a.Snapshot().Merge(b.Snapshot())
+	expected := all.Snapshot()
+	if merged.Count != expected.Count || merged.Min != expected.Min || merged.Max != expected.Max || merged.Mean() != expected.Mean() {
+		t.Errorf("Expected %v, got %v", expected, merged)
+	}
+	for _, q := range []float64{0.1, 0.5, 0.9, 0.99} {
+		if merged.Percentile(q) != expected.Percentile(q) {
+			t.Errorf("p%v: expected %v, got %v", q*100, expected.Percentile(q), merged.Percentile(q))
+		}
+	}
+
+	empty := NewHistogram().Snapshot()
+	if got := empty.Merge(merged); got.Count != merged.Count || got.Min != merged.Min {
+		t.Errorf("Expected merge into empty snapshot to keep %v, got %v", merged, got)
+	}
+}
+
+func TestHistogram_Concurrent(t *testing.T) {
+	h := NewHistogram()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				h.Record(time.Duration(g*1000 + i + 1))
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	s := h.Snapshot()
+	if s.Count != 8000 || s.Min != 1 || s.Max != 8000 {
+		t.Errorf("Expected 8000 values in 1-8000, got %d in %v-%v", s.Count, s.Min, s.Max)
+	}
+
+	h.Reset()
+	if s := h.Snapshot(); s.Count != 0 || s.Max != 0 {
+		t.Errorf("Expected empty histogram after reset, got %v", s)
+	}
+}
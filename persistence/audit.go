@@ -0,0 +1,229 @@
+package persistence
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// The invariants checked by Audit, reported as the Check of a violation.
+const (
+	// AuditCrossed is a book left crossed with matching enabled while its
+	// best bid and ask could trade.
+	AuditCrossed = "crossed"
+	// AuditQuantity is an order whose leaves and executed quantities exceed
+	// its quantity, or a resting order with no leaves quantity.  Reductions
+	// cancel quantity, so leaves plus executed may be less than the quantity.
+	AuditQuantity = "quantity"
+	// AuditLevel is a price level whose volumes or order count differ from
+	// the orders queued on it, or an order missing from its level.
+	AuditLevel = "level"
+	// AuditBest is a best bid or ask that is not the first level of its side.
+	AuditBest = "best"
+	// AuditBook is an order of a symbol without an order book.
+	AuditBook = "book"
+)
+
+// AuditViolation is a broken invariant of the engine state.
+type AuditViolation struct {
+	// Check is the broken invariant, one of the Audit constants.
+	Check    string
+	SymbolID uint32
+	// OrderID is the order at fault, 0 for a book or level violation.
+	OrderID uint64
+	// Price is the price of the level at fault, 0 for an order or book
+	// violation.
+	Price  uint64
+	Detail string
+}
+
+// String returns the string representation of an AuditViolation.
+func (v AuditViolation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: symbol %d", v.Check, v.SymbolID)
+	if v.OrderID != 0 {
+		fmt.Fprintf(&b, " order %d", v.OrderID)
+	}
+	if v.Price != 0 {
+		fmt.Fprintf(&b, " price %d", v.Price)
+	}
+	fmt.Fprintf(&b, ": %s", v.Detail)
+	return b.String()
+}
+
+// AuditReport is the result of Audit.
+type AuditReport struct {
+	// Books, Levels and Orders count what was checked.
+	Books  int
+	Levels int
+	Orders int
+	// Violations are sorted by symbol, order and price.
+	Violations []AuditViolation
+}
+
+// OK reports whether no invariant is broken.
+func (r *AuditReport) OK() bool {
+	return len(r.Violations) == 0
+}
+
+// AuditError is returned by NewManagerWithOptions when the audit of the
+// recovered state finds violations.
+type AuditError struct {
+	Report *AuditReport
+}
+
+// Error implements error.
+func (e *AuditError) Error() string {
+	msg := fmt.Sprintf("persistence: recovered state failed the audit with %d violations", len(e.Report.Violations))
+	if len(e.Report.Violations) > 0 {
+		msg += ", first " + e.Report.Violations[0].String()
+	}
+	return msg
+}
+
+// Audit checks the invariants of the engine state, typically right after a
+// recovery so that a corrupt state is caught before accepting traffic:
+//
+//   - no book is crossed with matching enabled, unless minimum quantities or
+//     spread leg prices keep its best orders apart (see OrderBook.CanMatch);
+//   - the leaves plus executed quantity of every order is at most its
+//     quantity, and every resting order has leaves quantity;
+//   - every order is queued on the level of its side and price, or stop
+//     price, and the volumes and order count of every level match its orders;
+//   - the best bid and ask are the first levels of their sides.
+//
+// mm must not be modified during the audit.
+func Audit(mm *matching.MarketManager) *AuditReport {
+	r := &AuditReport{Orders: len(mm.Orders())}
+	add := func(v AuditViolation) {
+		r.Violations = append(r.Violations, v)
+	}
+
+	// Orders and the levels they are queued on.
+	levels := make(map[*matching.LevelNode]uint32)
+	for _, order := range mm.Orders() {
+		fail := func(check, format string, args ...any) {
+			add(AuditViolation{Check: check, SymbolID: order.SymbolID, OrderID: order.ID, Detail: fmt.Sprintf(format, args...)})
+		}
+		if order.LeavesQuantity == 0 || order.LeavesQuantity+order.ExecutedQuantity > order.Quantity {
+			fail(AuditQuantity, "leaves %d and executed %d of quantity %d", order.LeavesQuantity, order.ExecutedQuantity, order.Quantity)
+		}
+		ob := mm.GetOrderBook(order.SymbolID)
+		if ob == nil {
+			fail(AuditBook, "no order book")
+			continue
+		}
+		level := orderLevel(ob, &order.Order)
+		if level == nil || order.Level != level {
+			fail(AuditLevel, "not queued on its level")
+			continue
+		}
+		levels[level] = order.SymbolID
+	}
+
+	// Limit levels, which must all hold orders.
+	for id, ob := range mm.OrderBooks() {
+		r.Books++
+		for _, side := range []*matching.AVLTree{ob.Bids(), ob.Asks()} {
+			side.ForEach(func(level *matching.LevelNode) bool {
+				levels[level] = id
+				return true
+			})
+		}
+		if ob.BestBid() != ob.Bids().First() || ob.BestAsk() != ob.Asks().First() {
+			add(AuditViolation{Check: AuditBest, SymbolID: id, Detail: "best bid or ask is not the first level"})
+		}
+		if mm.IsMatchingEnabled() && ob.CanMatch() {
+			add(AuditViolation{
+				Check:    AuditCrossed,
+				SymbolID: id,
+				Detail:   fmt.Sprintf("bid %d crosses ask %d", ob.BestBid().Price, ob.BestAsk().Price),
+			})
+		}
+	}
+
+	r.Levels = len(levels)
+	for level, id := range levels {
+		if detail := auditLevel(mm, level); detail != "" {
+			add(AuditViolation{Check: AuditLevel, SymbolID: id, Price: level.Price, Detail: detail})
+		}
+	}
+
+	sort.Slice(r.Violations, func(i, j int) bool {
+		a, b := r.Violations[i], r.Violations[j]
+		if a.SymbolID != b.SymbolID {
+			return a.SymbolID < b.SymbolID
+		}
+		if a.OrderID != b.OrderID {
+			return a.OrderID < b.OrderID
+		}
+		if a.Price != b.Price {
+			return a.Price < b.Price
+		}
+		return a.Check < b.Check
+	})
+	return r
+}
+
+// orderLevel returns the level of the book an order belongs on, nil if there
+// is none.
+func orderLevel(ob *matching.OrderBook, order *matching.Order) *matching.LevelNode {
+	switch {
+	case order.IsTrailingStop() || order.IsTrailingStopLimit():
+		if order.IsBuy() {
+			return ob.GetTrailingBuyStopLevel(order.StopPrice)
+		}
+		return ob.GetTrailingSellStopLevel(order.StopPrice)
+	case order.IsStop() || order.IsStopLimit():
+		if order.IsBuy() {
+			return ob.GetBuyStopLevel(order.StopPrice)
+		}
+		return ob.GetSellStopLevel(order.StopPrice)
+	case order.IsBuy():
+		return ob.GetBid(order.Price)
+	default:
+		return ob.GetAsk(order.Price)
+	}
+}
+
+// auditLevel compares a level with the orders queued on it and returns what
+// differs, empty if nothing does.
+func auditLevel(mm *matching.MarketManager, level *matching.LevelNode) string {
+	var orders, total, hidden, visible uint64
+	for node := level.OrderList.Head; node != nil; node = node.Next {
+		if mm.Orders()[node.ID] != node || node.Level != level {
+			return fmt.Sprintf("queues unknown order %d", node.ID)
+		}
+		if orders == uint64(len(mm.Orders())) {
+			return "order list loops"
+		}
+		orders++
+		total += node.LeavesQuantity
+		hidden += node.HiddenQuantity()
+		visible += node.VisibleQuantity()
+	}
+	var diffs []string
+	check := func(name string, got, want uint64) {
+		if got != want {
+			diffs = append(diffs, fmt.Sprintf("%s %d, orders hold %d", name, got, want))
+		}
+	}
+	check("orders", level.Orders, orders)
+	check("list size", level.OrderList.Size, orders)
+	check("volume", level.TotalVolume, total)
+	check("hidden volume", level.HiddenVolume, hidden)
+	check("visible volume", level.VisibleVolume, visible)
+	if orders == 0 {
+		diffs = append(diffs, "no orders")
+	}
+	return strings.Join(diffs, ", ")
+}
+
+// Audit audits the engine under the manager lock, see Audit.
+func (m *Manager) Audit() *AuditReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return Audit(m.mm)
+}
@@ -0,0 +1,110 @@
+package persistence
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+func TestAudit(t *testing.T) {
+	mm := newManager(t)
+	mm.AddOrder(newLimitOrder(1, matching.OrderSideBuy, 9900, 10))
+	mm.AddOrder(newLimitOrder(2, matching.OrderSideBuy, 9900, 20))
+	mm.AddOrder(newLimitOrder(3, matching.OrderSideSell, 10100, 30))
+	mm.AddOrder(newLimitOrder(4, matching.OrderSideSell, 9900, 5))
+	mm.ReduceOrder(2, 5)
+
+	r := Audit(mm)
+	if !r.OK() {
+		t.Fatalf("got violations %v, want none", r.Violations)
+	}
+	if r.Books != 1 || r.Levels != 2 || r.Orders != 3 {
+		t.Errorf("got %d books, %d levels and %d orders, want 1, 2 and 3", r.Books, r.Levels, r.Orders)
+	}
+
+	// Corrupt a level aggregate and an order.
+	mm.GetOrderBook(1).GetBid(9900).TotalVolume++
+	mm.GetOrder(3).ExecutedQuantity = 1
+	r = Audit(mm)
+	if len(r.Violations) != 2 {
+		t.Fatalf("got violations %v, want 2", r.Violations)
+	}
+	if v := r.Violations[0]; v.Check != AuditLevel || v.Price != 9900 || v.OrderID != 0 {
+		t.Errorf("got %v, want the volume of the bid level", v)
+	}
+	if v := r.Violations[1]; v.Check != AuditQuantity || v.OrderID != 3 {
+		t.Errorf("got %v, want the quantity of order 3", v)
+	}
+}
+
+func TestAudit_Crossed(t *testing.T) {
+	mm := newManager(t)
+	mm.DisableMatching()
+	mm.AddOrder(newLimitOrder(1, matching.OrderSideBuy, 10100, 10))
+	mm.AddOrder(newLimitOrder(2, matching.OrderSideSell, 10000, 10))
+
+	// A crossed book is expected while matching is disabled.
+	if r := Audit(mm); !r.OK() {
+		t.Fatalf("got violations %v, want none", r.Violations)
+	}
+	mm.EnableMatching()
+	r := Audit(mm)
+	if len(r.Violations) != 1 || r.Violations[0].Check != AuditCrossed {
+		t.Errorf("got violations %v, want a crossed book", r.Violations)
+	}
+}
+
+func TestManager_Audit(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "test.journal")
+	snapshotDir := filepath.Join(dir, "snapshots")
+
+	mgr, err := NewManagerWithOptions(newManager(t), journalPath, snapshotDir, ManagerOptions{})
+	if err != nil {
+		t.Fatalf("NewManagerWithOptions: %v", err)
+	}
+	if err := mgr.AddOrder(newLimitOrder(1, matching.OrderSideBuy, 9900, 10)); err != nil {
+		t.Fatalf("AddOrder: %v", err)
+	}
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	opts := ManagerOptions{Recover: true, Audit: true}
+	mgr, err = NewManagerWithOptions(newManager(t), journalPath, snapshotDir, opts)
+	if err != nil {
+		t.Fatalf("NewManagerWithOptions: %v", err)
+	}
+	if a := mgr.Stats().Recovery.Audit; a == nil || !a.OK() || a.Orders != 1 {
+		t.Errorf("got audit %+v, want 1 order without violations", a)
+	}
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// A snapshot restoring a crossed book fails the audit with matching
+	// enabled.
+	sp, err := NewSnapshotter(snapshotDir)
+	if err != nil {
+		t.Fatalf("NewSnapshotter: %v", err)
+	}
+	snap := Snapshot{
+		Timestamp: time.Now().Add(time.Hour).UnixNano(),
+		Symbols:   []matching.Symbol{matching.NewSymbol(1, "AAPL")},
+		Orders: []matching.Order{
+			newLimitOrder(1, matching.OrderSideBuy, 10100, 10),
+			newLimitOrder(2, matching.OrderSideSell, 10000, 10),
+		},
+	}
+	if err := sp.Save(snap); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	_, err = NewManagerWithOptions(newManager(t), journalPath, snapshotDir, opts)
+	var auditErr *AuditError
+	if !errors.As(err, &auditErr) || auditErr.Report.Violations[0].Check != AuditCrossed {
+		t.Fatalf("got %v, want a crossed book audit error", err)
+	}
+}
@@ -0,0 +1,231 @@
+package persistence
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/persistence/persistencetest"
+)
+
+// Outcomes of the crash-safety matrix documented by persistencetest.
+const (
+	crashRecovered = iota
+	crashDetected
+	crashUndetected
+)
+
+// crashFiles are the files of a store written for a crash test.
+type crashFiles struct {
+	journal, snapshots string
+	// records are the journal record offsets, see RecordOffsets.
+	records []int64
+	// snapshot is the snapshot taken after the second order, if any.
+	snapshot string
+}
+
+// writeCrashStore journals four resting buy orders, 1 to 4 at prices 9900 to
+// 9600, optionally snapshotting after the second one.
+func writeCrashStore(t *testing.T, snapshot bool) crashFiles {
+	t.Helper()
+	dir := t.TempDir()
+	files := crashFiles{
+		journal:   filepath.Join(dir, "test.journal"),
+		snapshots: filepath.Join(dir, "snapshots"),
+	}
+	mgr, err := NewManager(newManager(t), files.journal, files.snapshots)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	for id := uint64(1); id <= 4; id++ {
+		if err := mgr.AddOrder(newLimitOrder(id, matching.OrderSideBuy, 10000-100*id, 10)); err != nil {
+			t.Fatalf("AddOrder: %v", err)
+		}
+		if id == 2 && snapshot {
+			if err := mgr.SaveSnapshot(); err != nil {
+				t.Fatalf("SaveSnapshot: %v", err)
+			}
+		}
+	}
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if files.records, err = persistencetest.RecordOffsets(files.journal); err != nil || len(files.records) != 5 {
+		t.Fatalf("RecordOffsets: got %v, %v, want 5 offsets", files.records, err)
+	}
+	if snapshot {
+		paths, err := persistencetest.Snapshots(files.snapshots)
+		if err != nil || len(paths) != 1 {
+			t.Fatalf("Snapshots: got %v, %v, want 1", paths, err)
+		}
+		files.snapshot = paths[0]
+	}
+	return files
+}
+
+func TestCrashSafetyMatrix(t *testing.T) {
+	// Offsets within a record: the length prefix, then the type, the
+	// timestamp and the order, whose price starts at byte 14.
+	const typeOffset, priceOffset = 4, 4 + 1 + 8 + 14
+
+	tests := []struct {
+		name     string
+		snapshot bool
+		inject   func(f crashFiles) error
+		outcome  int
+		// want are the orders recovered, for crashRecovered.
+		want []uint64
+	}{
+		{
+			name: "torn record",
+			inject: func(f crashFiles) error {
+				return persistencetest.Truncate(f.journal, f.records[3]+typeOffset+5)
+			},
+			want: []uint64{1, 2, 3},
+		},
+		{
+			name: "torn at record offset",
+			inject: func(f crashFiles) error {
+				return persistencetest.Truncate(f.journal, f.records[2])
+			},
+			want: []uint64{1, 2},
+		},
+		{
+			name: "torn length",
+			inject: func(f crashFiles) error {
+				return persistencetest.Truncate(f.journal, f.records[3]+2)
+			},
+			want: []uint64{1, 2, 3},
+		},
+		{
+			name:     "snapshot temp file",
+			snapshot: true,
+			inject: func(f crashFiles) error {
+				newer := filepath.Join(f.snapshots, snapshotName(time.Now().Add(time.Hour).UnixNano()))
+				return persistencetest.WriteTempFile(newer, []byte("partial"))
+			},
+			want: []uint64{1, 2, 3, 4},
+		},
+		{
+			// The journal is not rotated, so recovery skips the events the
+			// snapshot covers.
+			name:     "snapshot before rotation",
+			snapshot: true,
+			inject:   func(f crashFiles) error { return nil },
+			want:     []uint64{1, 2, 3, 4},
+		},
+		{
+			name: "corrupt event type",
+			inject: func(f crashFiles) error {
+				return persistencetest.FlipByte(f.journal, f.records[1]+typeOffset)
+			},
+			outcome: crashDetected,
+		},
+		{
+			name:     "corrupt snapshot",
+			snapshot: true,
+			inject: func(f crashFiles) error {
+				size, err := persistencetest.Size(f.snapshot)
+				if err != nil {
+					return err
+				}
+				return persistencetest.FlipByte(f.snapshot, size/2)
+			},
+			outcome: crashDetected,
+		},
+		{
+			name:     "truncated snapshot",
+			snapshot: true,
+			inject: func(f crashFiles) error {
+				size, err := persistencetest.Size(f.snapshot)
+				if err != nil {
+					return err
+				}
+				return persistencetest.Truncate(f.snapshot, size/2)
+			},
+			outcome: crashDetected,
+		},
+		{
+			// The high byte, so that the length runs past the end of the
+			// journal.
+			name: "corrupt record length",
+			inject: func(f crashFiles) error {
+				return persistencetest.FlipByte(f.journal, f.records[1])
+			},
+			outcome: crashUndetected,
+		},
+		{
+			name: "corrupt event payload",
+			inject: func(f crashFiles) error {
+				return persistencetest.FlipByte(f.journal, f.records[0]+priceOffset+7)
+			},
+			outcome: crashUndetected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := writeCrashStore(t, tt.snapshot)
+			if err := tt.inject(files); err != nil {
+				t.Fatalf("inject: %v", err)
+			}
+			mm := newManager(t)
+			err := Recover(mm, files.journal, files.snapshots)
+
+			switch tt.outcome {
+			case crashDetected:
+				if err == nil {
+					t.Fatalf("got recovered %d orders, want an error", len(mm.Orders()))
+				}
+				return
+			case crashUndetected:
+				if err != nil {
+					t.Fatalf("got %v, want an undetected corruption", err)
+				}
+				if recoveredIntact(mm, 4) {
+					t.Fatal("got the written state, want it corrupted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Recover: %v", err)
+			}
+			if !recoveredIntact(mm, len(tt.want)) || len(mm.Orders()) != len(tt.want) {
+				t.Errorf("got %d orders, want orders %v", len(mm.Orders()), tt.want)
+			}
+		})
+	}
+}
+
+// recoveredIntact reports whether mm holds orders 1 to n as written by
+// writeCrashStore.
+func recoveredIntact(mm *matching.MarketManager, n int) bool {
+	if len(mm.Orders()) != n {
+		return false
+	}
+	for id := uint64(1); id <= uint64(n); id++ {
+		order := mm.GetOrder(id)
+		if order == nil || order.Price != 10000-100*id || order.LeavesQuantity != 10 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRemoveTempFiles(t *testing.T) {
+	files := writeCrashStore(t, true)
+	if err := persistencetest.WriteTempFile(files.snapshot, []byte("partial")); err != nil {
+		t.Fatalf("WriteTempFile: %v", err)
+	}
+	if paths, _ := persistencetest.TempFiles(files.snapshots); len(paths) != 1 {
+		t.Fatalf("got temp files %v, want 1", paths)
+	}
+	if n, err := persistencetest.RemoveTempFiles(files.snapshots); n != 1 || err != nil {
+		t.Errorf("RemoveTempFiles: got %d, %v, want 1", n, err)
+	}
+	if paths, _ := persistencetest.TempFiles(files.snapshots); len(paths) != 0 {
+		t.Errorf("got temp files %v, want none", paths)
+	}
+}
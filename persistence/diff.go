@@ -0,0 +1,134 @@
+package persistence
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// SnapshotDiff lists the differences between two snapshots.  Symbols and
+// orders are matched by ID and every list is sorted by ID.
+type SnapshotDiff struct {
+	SymbolsAdded   []matching.Symbol
+	SymbolsRemoved []matching.Symbol
+	SymbolsChanged []SymbolChange
+	OrdersAdded    []matching.Order
+	OrdersRemoved  []matching.Order
+	OrdersChanged  []OrderChange
+}
+
+// SymbolChange is a symbol present in both snapshots with different fields.
+type SymbolChange struct {
+	Old, New matching.Symbol
+	Fields   []FieldChange
+}
+
+// OrderChange is an order present in both snapshots with different fields.
+type OrderChange struct {
+	Old, New matching.Order
+	Fields   []FieldChange
+}
+
+// FieldChange is a single field that differs between two versions of a
+// symbol or order.  Values are formatted with fmt.
+type FieldChange struct {
+	Field    string
+	Old, New string
+}
+
+// Empty reports whether the two snapshots hold the same symbols and orders.
+func (d *SnapshotDiff) Empty() bool {
+	return len(d.SymbolsAdded) == 0 && len(d.SymbolsRemoved) == 0 && len(d.SymbolsChanged) == 0 &&
+		len(d.OrdersAdded) == 0 && len(d.OrdersRemoved) == 0 && len(d.OrdersChanged) == 0
+}
+
+// DiffSnapshots compares snapshot a with snapshot b: added entries exist only
+// in b, removed entries only in a.  A nil snapshot is treated as empty.  The
+// snapshot timestamps are not compared.
+func DiffSnapshots(a, b *Snapshot) *SnapshotDiff {
+	if a == nil {
+		a = &Snapshot{}
+	}
+	if b == nil {
+		b = &Snapshot{}
+	}
+	d := &SnapshotDiff{}
+
+	oldSymbols := make(map[uint32]matching.Symbol, len(a.Symbols))
+	for _, s := range a.Symbols {
+		oldSymbols[s.ID] = s
+	}
+	newSymbols := make(map[uint32]matching.Symbol, len(b.Symbols))
+	for _, s := range b.Symbols {
+		newSymbols[s.ID] = s
+		old, ok := oldSymbols[s.ID]
+		if !ok {
+			d.SymbolsAdded = append(d.SymbolsAdded, s)
+		} else if fields := diffFields(old, s); len(fields) > 0 {
+			d.SymbolsChanged = append(d.SymbolsChanged, SymbolChange{Old: old, New: s, Fields: fields})
+		}
+	}
+	for _, s := range a.Symbols {
+		if _, ok := newSymbols[s.ID]; !ok {
+			d.SymbolsRemoved = append(d.SymbolsRemoved, s)
+		}
+	}
+
+	oldOrders := make(map[uint64]matching.Order, len(a.Orders))
+	for _, o := range a.Orders {
+		oldOrders[o.ID] = o
+	}
+	newOrders := make(map[uint64]matching.Order, len(b.Orders))
+	for _, o := range b.Orders {
+		newOrders[o.ID] = o
+		old, ok := oldOrders[o.ID]
+		if !ok {
+			d.OrdersAdded = append(d.OrdersAdded, o)
+		} else if fields := diffFields(old, o); len(fields) > 0 {
+			d.OrdersChanged = append(d.OrdersChanged, OrderChange{Old: old, New: o, Fields: fields})
+		}
+	}
+	for _, o := range a.Orders {
+		if _, ok := newOrders[o.ID]; !ok {
+			d.OrdersRemoved = append(d.OrdersRemoved, o)
+		}
+	}
+
+	sortSymbols(d.SymbolsAdded)
+	sortSymbols(d.SymbolsRemoved)
+	sort.Slice(d.SymbolsChanged, func(i, j int) bool { return d.SymbolsChanged[i].New.ID < d.SymbolsChanged[j].New.ID })
+	sortOrders(d.OrdersAdded)
+	sortOrders(d.OrdersRemoved)
+	sort.Slice(d.OrdersChanged, func(i, j int) bool { return d.OrdersChanged[i].New.ID < d.OrdersChanged[j].New.ID })
+	return d
+}
+
+// diffFields compares the exported fields of two structs of the same type,
+// in declaration order.
+func diffFields(a, b any) []FieldChange {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	var fields []FieldChange
+	for i := 0; i < va.NumField(); i++ {
+		f := va.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fa, fb := va.Field(i).Interface(), vb.Field(i).Interface()
+		if !reflect.DeepEqual(fa, fb) {
+			fields = append(fields, FieldChange{Field: f.Name, Old: fmt.Sprint(fa), New: fmt.Sprint(fb)})
+		}
+	}
+	return fields
+}
+
+// sortSymbols sorts symbols by ID.
+func sortSymbols(symbols []matching.Symbol) {
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i].ID < symbols[j].ID })
+}
+
+// sortOrders sorts orders by ID.
+func sortOrders(orders []matching.Order) {
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+}
@@ -0,0 +1,93 @@
+package persistence
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	a := &Snapshot{
+		Timestamp: 1,
+		Symbols:   []matching.Symbol{{ID: 1, Name: "AAPL"}, {ID: 2, Name: "GOOGL"}},
+		Orders: []matching.Order{
+			newLimitOrder(1, matching.OrderSideBuy, 10000, 100),
+			newLimitOrder(2, matching.OrderSideSell, 10100, 50),
+			newLimitOrder(3, matching.OrderSideSell, 10200, 10),
+		},
+	}
+	b := &Snapshot{
+		Timestamp: 2,
+		Symbols:   []matching.Symbol{{ID: 1, Name: "AAPL.O"}, {ID: 3, Name: "MSFT"}},
+		Orders: []matching.Order{
+			newLimitOrder(4, matching.OrderSideBuy, 9900, 20),
+			newLimitOrder(1, matching.OrderSideBuy, 10000, 100),
+			newLimitOrder(2, matching.OrderSideSell, 10100, 50),
+		},
+	}
+	b.Orders[1].ExecutedQuantity = 30
+	b.Orders[1].LeavesQuantity = 70
+
+	d := DiffSnapshots(a, b)
+	if d.Empty() {
+		t.Fatal("Empty: got true, want false")
+	}
+	if len(d.SymbolsAdded) != 1 || d.SymbolsAdded[0].ID != 3 {
+		t.Errorf("SymbolsAdded: got %+v, want MSFT", d.SymbolsAdded)
+	}
+	if len(d.SymbolsRemoved) != 1 || d.SymbolsRemoved[0].ID != 2 {
+		t.Errorf("SymbolsRemoved: got %+v, want GOOGL", d.SymbolsRemoved)
+	}
+	wantSymbol := []FieldChange{{Field: "Name", Old: "AAPL", New: "AAPL.O"}}
+	if len(d.SymbolsChanged) != 1 || !reflect.DeepEqual(d.SymbolsChanged[0].Fields, wantSymbol) {
+		t.Errorf("SymbolsChanged: got %+v, want %+v", d.SymbolsChanged, wantSymbol)
+	}
+	if len(d.OrdersAdded) != 1 || d.OrdersAdded[0].ID != 4 {
+		t.Errorf("OrdersAdded: got %+v, want order 4", d.OrdersAdded)
+	}
+	if len(d.OrdersRemoved) != 1 || d.OrdersRemoved[0].ID != 3 {
+		t.Errorf("OrdersRemoved: got %+v, want order 3", d.OrdersRemoved)
+	}
+	wantOrder := []FieldChange{
+		{Field: "ExecutedQuantity", Old: "0", New: "30"},
+		{Field: "LeavesQuantity", Old: "100", New: "70"},
+	}
+	if len(d.OrdersChanged) != 1 || d.OrdersChanged[0].New.ID != 1 || !reflect.DeepEqual(d.OrdersChanged[0].Fields, wantOrder) {
+		t.Errorf("OrdersChanged: got %+v, want order 1 with %+v", d.OrdersChanged, wantOrder)
+	}
+
+	// Only the timestamps differ.
+	a.Timestamp = 3
+	if d := DiffSnapshots(a, a); !d.Empty() {
+		t.Errorf("DiffSnapshots(a, a): got %+v, want empty", d)
+	}
+	if d := DiffSnapshots(nil, a); len(d.OrdersAdded) != 3 || len(d.SymbolsAdded) != 2 {
+		t.Errorf("DiffSnapshots(nil, a): got %+v, want everything added", d)
+	}
+}
+
+func TestLoadSnapshotFile(t *testing.T) {
+	dir := t.TempDir()
+	sp, err := NewSnapshotter(dir)
+	if err != nil {
+		t.Fatalf("NewSnapshotter: %v", err)
+	}
+	snap := Snapshot{
+		Timestamp: 42,
+		Symbols:   []matching.Symbol{{ID: 1, Name: "AAPL"}},
+		Orders:    []matching.Order{newLimitOrder(1, matching.OrderSideBuy, 10000, 100)},
+	}
+	if err := sp.Save(snap); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	got, err := LoadSnapshotFile(filepath.Join(dir, snapshotName(42)))
+	if err != nil {
+		t.Fatalf("LoadSnapshotFile: %v", err)
+	}
+	if d := DiffSnapshots(&snap, got); !d.Empty() {
+		t.Errorf("DiffSnapshots: got %+v, want empty", d)
+	}
+}
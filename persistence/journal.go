@@ -2,10 +2,14 @@ package persistence
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"sync"
 	"time"
+
+	"github.com/tienpsm/go-trader/metrics"
 )
 
 const (
@@ -22,27 +26,120 @@ const (
 // that the number of fsync system calls is minimised.
 type Journal struct {
 	mu     sync.Mutex
+	path   string
 	file   *os.File
 	writer *bufio.Writer
 
 	ticker *time.Ticker
 	done   chan struct{}
 	wg     sync.WaitGroup
+
+	// syncLatency records the Append→fsync latency of every event when
+	// tracking is enabled; appended holds the append times of unsynced events.
+	syncLatency *metrics.Histogram
+	appended    []time.Time
+
+	// err is the result of the last flush.
+	err error
+
+	// size is the size of the current segment including buffered events,
+	// unsynced counts the bytes appended since the last successful fsync and
+	// lastSync is its time, zero if there was none since the journal opened.
+	size     int64
+	unsynced int64
+	lastSync time.Time
+
+	durability Durability
+
+	// wrap, if set, interposes a writer between the buffer and the file.
+	wrap func(io.Writer) io.Writer
+
+	// lock is the lock on the journal, nil with JournalOptions.NoLock.
+	lock *FileLock
+}
+
+// Durability selects when appended events reach the disk.
+type Durability uint8
+
+const (
+	// DurabilityGroupCommit fsyncs buffered events every defaultFlushInterval
+	// or when the buffer fills up.  A crash loses the events of the last
+	// interval.
+	DurabilityGroupCommit Durability = iota
+	// DurabilitySync fsyncs every event before Append returns.
+	DurabilitySync
+)
+
+// String returns the name of the durability mode.
+func (d Durability) String() string {
+	switch d {
+	case DurabilityGroupCommit:
+		return "group"
+	case DurabilitySync:
+		return "sync"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseDurability parses the name of a durability mode, "group" or "sync".
+func ParseDurability(s string) (Durability, error) {
+	switch s {
+	case "group", "":
+		return DurabilityGroupCommit, nil
+	case "sync":
+		return DurabilitySync, nil
+	default:
+		return 0, fmt.Errorf("persistence: unknown durability %q", s)
+	}
 }
 
 // OpenJournal opens (or creates) the journal file at path and starts the
 // background flush goroutine.
 func OpenJournal(path string) (*Journal, error) {
+	return OpenJournalWithOptions(path, JournalOptions{})
+}
+
+// JournalOptions configures OpenJournalWithOptions.
+type JournalOptions struct {
+	// NoLock opens the journal without locking it against other writers, for
+	// recovery tooling that must write to a journal whose lock is held, for
+	// example by a hung process.  Two writers corrupt the journal.
+	NoLock bool
+}
+
+// OpenJournalWithOptions is OpenJournal with options.  Unless NoLock is set,
+// the journal is locked by the lock file "<path>.lock" until Close, and an
+// error wrapping ErrAlreadyInUse is returned when another process has it
+// open.
+func OpenJournalWithOptions(path string, opts JournalOptions) (*Journal, error) {
+	var lock *FileLock
+	if !opts.NoLock {
+		var err error
+		if lock, err = LockFile(journalLockPath(path)); err != nil {
+			return nil, err
+		}
+	}
 	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o644)
 	if err != nil {
+		_ = lock.Unlock()
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		_ = lock.Unlock()
 		return nil, err
 	}
 
 	j := &Journal{
+		path:   path,
 		file:   f,
+		size:   info.Size(),
 		writer: bufio.NewWriterSize(f, defaultBufSize),
 		ticker: time.NewTicker(defaultFlushInterval),
 		done:   make(chan struct{}),
+		lock:   lock,
 	}
 
 	j.wg.Add(1)
@@ -61,8 +158,93 @@ func (j *Journal) Append(event MatchingEvent) error {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
-	_, err = j.writer.Write(record)
-	return err
+	n, err := j.writer.Write(record)
+	j.size += int64(n)
+	j.unsynced += int64(n)
+	if err != nil {
+		return err
+	}
+	if j.syncLatency != nil {
+		j.appended = append(j.appended, time.Now())
+	}
+	if j.durability == DurabilitySync {
+		return j.flush()
+	}
+	return nil
+}
+
+// SetDurability changes when appended events are fsynced.  The default is
+// DurabilityGroupCommit.
+func (j *Journal) SetDurability(d Durability) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.durability = d
+}
+
+// WrapWriter interposes the writer returned by wrap between the write buffer
+// and the journal file, such as a fault injecting writer in tests.  Buffered
+// events are flushed first.  The wrapper also applies to the files opened by
+// Rotate; fsyncs still go to the file directly.
+func (j *Journal) WrapWriter(wrap func(io.Writer) io.Writer) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.flush(); err != nil {
+		return err
+	}
+	j.wrap = wrap
+	j.writer.Reset(j.output())
+	return nil
+}
+
+// output returns the writer under the write buffer.  It must be called with
+// j.mu held.
+func (j *Journal) output() io.Writer {
+	if j.wrap == nil {
+		return j.file
+	}
+	return j.wrap(j.file)
+}
+
+// EnableLatencyTracking starts recording the time from Append until the event
+// has been fsynced to disk.
+func (j *Journal) EnableLatencyTracking() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.syncLatency == nil {
+		j.syncLatency = metrics.NewHistogram()
+	}
+}
+
+// SyncLatency returns the recorded Append→fsync latencies.  The snapshot is
+// empty if latency tracking is not enabled.
+func (j *Journal) SyncLatency() metrics.Snapshot {
+	j.mu.Lock()
+	h := j.syncLatency
+	j.mu.Unlock()
+	if h == nil {
+		return metrics.Snapshot{}
+	}
+	return h.Snapshot()
+}
+
+// JournalStats describes how far the journal lags behind its appends.
+type JournalStats struct {
+	// Size is the size of the current segment in bytes, including events
+	// that are still buffered.
+	Size int64
+	// UnsyncedBytes is the number of bytes appended since the last
+	// successful fsync.
+	UnsyncedBytes int64
+	// LastSync is the time of the last successful fsync, zero if there was
+	// none since the journal was opened.
+	LastSync time.Time
+}
+
+// Stats returns the size of the journal and the bytes not yet fsynced.
+func (j *Journal) Stats() JournalStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return JournalStats{Size: j.size, UnsyncedBytes: j.unsynced, LastSync: j.lastSync}
 }
 
 // Flush forces all buffered data to be written to disk (fsync).
@@ -72,16 +254,77 @@ func (j *Journal) Flush() error {
 	return j.flush()
 }
 
+// Err returns the error of the last flush, automatic or not, or nil if it
+// succeeded.  A journal that keeps failing to flush is no longer durable.
+func (j *Journal) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
 // flush must be called with j.mu held.
 func (j *Journal) flush() error {
+	j.err = j.sync()
+	return j.err
+}
+
+// sync writes the buffer to disk.  It must be called with j.mu held.
+func (j *Journal) sync() error {
 	if err := j.writer.Flush(); err != nil {
 		return err
 	}
-	return j.file.Sync()
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+	now := time.Now()
+	j.unsynced = 0
+	j.lastSync = now
+	if len(j.appended) > 0 {
+		for _, t := range j.appended {
+			j.syncLatency.Record(now.Sub(t))
+		}
+		j.appended = j.appended[:0]
+	}
+	return nil
 }
 
-// Close flushes remaining data, stops the background goroutine, and closes the
-// underlying file.
+// Rotate closes the current journal segment, renames it to
+// "<path>.<unix-nanos>" and continues appending to a new, empty file at the
+// original path.  It returns the path of the archived segment.
+//
+// The archived segment is no longer read by Recover, so callers must first
+// persist a snapshot that covers every event it contains.
+func (j *Journal) Rotate() (string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.flush(); err != nil {
+		return "", err
+	}
+	if err := j.file.Close(); err != nil {
+		return "", err
+	}
+
+	archive := fmt.Sprintf("%s.%d", j.path, time.Now().UnixNano())
+	renameErr := os.Rename(j.path, archive)
+
+	// Reopen the journal even if the rename failed so that it stays usable.
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return "", err
+	}
+	j.file = f
+	j.writer.Reset(j.output())
+
+	if renameErr != nil {
+		return "", renameErr
+	}
+	j.size = 0
+	return archive, nil
+}
+
+// Close flushes remaining data, stops the background goroutine, closes the
+// underlying file and releases the lock.
 func (j *Journal) Close() error {
 	j.ticker.Stop()
 	close(j.done)
@@ -89,6 +332,7 @@ func (j *Journal) Close() error {
 
 	j.mu.Lock()
 	defer j.mu.Unlock()
+	defer j.lock.Unlock()
 	if err := j.flush(); err != nil {
 		_ = j.file.Close()
 		return err
@@ -111,30 +355,75 @@ func (j *Journal) flushLoop() {
 	}
 }
 
+// JournalReader streams the events of a journal file one at a time, so that
+// journals of any size can be read in constant memory.
+//
+// A truncated record at the end of the file, left by a crash during a write,
+// is treated as the end of the journal.
+type JournalReader struct {
+	file *os.File
+	r    *bufio.Reader
+}
+
+// OpenJournalReader opens the journal at path for reading.  A missing journal
+// is treated as empty: the returned reader reports io.EOF immediately.
+func OpenJournalReader(path string) (*JournalReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &JournalReader{}, nil
+		}
+		return nil, err
+	}
+	return &JournalReader{file: f, r: bufio.NewReaderSize(f, defaultBufSize)}, nil
+}
+
+// Next decodes the next event.  It returns io.EOF once every complete record
+// has been read.
+func (jr *JournalReader) Next() (MatchingEvent, error) {
+	if jr.r == nil {
+		return MatchingEvent{}, io.EOF
+	}
+	e, err := decodeEvent(jr.r)
+	if err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return MatchingEvent{}, io.EOF // truncated tail is tolerated (crash during write)
+		}
+		return MatchingEvent{}, err
+	}
+	return e, nil
+}
+
+// Close closes the journal file.
+func (jr *JournalReader) Close() error {
+	if jr.file == nil {
+		return nil
+	}
+	return jr.file.Close()
+}
+
 // ReadAll opens the journal at path in read-only mode and decodes every
 // record it contains.  It returns all successfully decoded events and the
 // first unrecoverable error (io.EOF is never returned to the caller).
+//
+// ReadAll holds the whole journal in memory; use JournalReader to stream
+// large journals.
 func ReadAll(path string) ([]MatchingEvent, error) {
-	f, err := os.Open(path)
+	jr, err := OpenJournalReader(path)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
 		return nil, err
 	}
-	defer f.Close()
+	defer jr.Close()
 
 	var events []MatchingEvent
-	r := bufio.NewReader(f)
 	for {
-		e, err := decodeEvent(r)
+		e, err := jr.Next()
+		if err == io.EOF {
+			return events, nil
+		}
 		if err != nil {
-			if err == io.EOF || err == io.ErrUnexpectedEOF {
-				break // truncated tail is tolerated (crash during write)
-			}
 			return events, err
 		}
 		events = append(events, e)
 	}
-	return events, nil
 }
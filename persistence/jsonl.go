@@ -0,0 +1,304 @@
+package persistence
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// The JSONL form of a journal holds one event per line.  Unlike the binary
+// record format, its field names and enum values are fixed, so journals can be
+// inspected, edited for test scenarios and carried across incompatible binary
+// format versions:
+//
+//	{"type":"new_order","timestamp":1700000000000000000,"order":{"id":1,"symbol_id":1,"order_type":"LIMIT","side":"BUY","price":10000,...}}
+//	{"type":"cancel_order","timestamp":1700000000000000001,"order_id":1}
+//	{"type":"reset_session","timestamp":1700000000000000002}
+//	{"type":"reject_order","timestamp":1700000000000000003,"order":{"id":2,...},"reject":"ORDER_DUPLICATE"}
+//
+// Optional order fields are omitted when zero.
+
+// jsonEvent is the JSONL form of a MatchingEvent.
+type jsonEvent struct {
+	Type      string     `json:"type"`
+	Timestamp int64      `json:"timestamp"`
+	Order     *jsonOrder `json:"order,omitempty"`
+	OrderID   uint64     `json:"order_id,omitempty"`
+	Reject    string     `json:"reject,omitempty"`
+}
+
+// jsonOrder is the JSONL form of a matching.Order.
+type jsonOrder struct {
+	ID                  uint64 `json:"id"`
+	SymbolID            uint32 `json:"symbol_id"`
+	Type                string `json:"order_type"`
+	Side                string `json:"side"`
+	Price               uint64 `json:"price"`
+	StopPrice           uint64 `json:"stop_price,omitempty"`
+	Quantity            uint64 `json:"quantity"`
+	ExecutedQuantity    uint64 `json:"executed_quantity"`
+	LeavesQuantity      uint64 `json:"leaves_quantity"`
+	TimeInForce         string `json:"time_in_force"`
+	MinQuantity         uint64 `json:"min_quantity,omitempty"`
+	MaxVisibleQuantity  uint64 `json:"max_visible_quantity"`
+	DisplayLowQuantity  uint64 `json:"display_low_quantity,omitempty"`
+	DisplayHighQuantity uint64 `json:"display_high_quantity,omitempty"`
+	Slippage            uint64 `json:"slippage"`
+	TrailingDistance    int64  `json:"trailing_distance,omitempty"`
+	TrailingStep        int64  `json:"trailing_step,omitempty"`
+	ParticipantID       uint32 `json:"participant_id,omitempty"`
+	AccountID           uint32 `json:"account_id,omitempty"`
+	Quote               bool   `json:"quote,omitempty"`
+}
+
+// jsonEventTypes are the JSONL names of the event types.
+var jsonEventTypes = map[EventType]string{
+	EventNewOrder:     "new_order",
+	EventCancelOrder:  "cancel_order",
+	EventResetSession: "reset_session",
+	EventRejectOrder:  "reject_order",
+}
+
+// ExportJSONL writes every event of the journal at journalPath to w, one JSON
+// object per line, and returns the number of events written.  Like recovery,
+// it stops at a truncated record at the end of the journal.
+func ExportJSONL(journalPath string, w io.Writer) (int, error) {
+	jr, err := OpenJournalReader(journalPath)
+	if err != nil {
+		return 0, err
+	}
+	defer jr.Close()
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	n := 0
+	for {
+		e, err := jr.Next()
+		if err == io.EOF {
+			return n, bw.Flush()
+		}
+		if err != nil {
+			return n, err
+		}
+		je, err := toJSONEvent(e)
+		if err != nil {
+			return n, err
+		}
+		if err := enc.Encode(je); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// ImportJSONL reads JSONL events from r and writes them to a new binary journal
+// at journalPath, replacing any existing file only once every event has been
+// read and written.  Blank lines are skipped.  It returns the number of events
+// imported; errors name the offending line.
+//
+// The journal is locked as by OpenJournal while it is replaced.
+func ImportJSONL(r io.Reader, journalPath string) (int, error) {
+	return ImportJSONLWithOptions(r, journalPath, JournalOptions{})
+}
+
+// ImportJSONLWithOptions is ImportJSONL with options.  NoLock replaces the
+// journal even if another process has it open.
+func ImportJSONLWithOptions(r io.Reader, journalPath string, opts JournalOptions) (int, error) {
+	if !opts.NoLock {
+		lock, err := LockFile(journalLockPath(journalPath))
+		if err != nil {
+			return 0, err
+		}
+		defer lock.Unlock()
+	}
+
+	tmp := journalPath + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	n, err := importJSONL(r, f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, journalPath)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return 0, err
+	}
+	return n, nil
+}
+
+// importJSONL converts JSONL events from r into binary records written to w.
+func importJSONL(r io.Reader, w io.Writer) (int, error) {
+	bw := bufio.NewWriterSize(w, defaultBufSize)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	n, line := 0, 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		var je jsonEvent
+		if err := dec.Decode(&je); err != nil {
+			return n, fmt.Errorf("persistence: JSONL line %d: %w", line, err)
+		}
+		e, err := je.event()
+		if err != nil {
+			return n, fmt.Errorf("persistence: JSONL line %d: %w", line, err)
+		}
+		record, err := encodeEvent(e)
+		if err != nil {
+			return n, fmt.Errorf("persistence: JSONL line %d: %w", line, err)
+		}
+		if _, err := bw.Write(record); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// toJSONEvent converts an event to its JSONL form.
+func toJSONEvent(e MatchingEvent) (jsonEvent, error) {
+	name, ok := jsonEventTypes[e.Type]
+	if !ok {
+		return jsonEvent{}, fmt.Errorf("persistence: unknown EventType %d", e.Type)
+	}
+	je := jsonEvent{Type: name, Timestamp: e.Timestamp}
+	switch e.Type {
+	case EventNewOrder:
+		je.Order = toJSONOrder(e.Order)
+	case EventCancelOrder:
+		je.OrderID = e.OrderID
+	case EventRejectOrder:
+		je.Order = toJSONOrder(e.Order)
+		je.Reject = e.Reject.String()
+	}
+	return je, nil
+}
+
+// toJSONOrder converts an order to its JSONL form.
+func toJSONOrder(o matching.Order) *jsonOrder {
+	return &jsonOrder{
+		ID:                  o.ID,
+		SymbolID:            o.SymbolID,
+		Type:                o.Type.String(),
+		Side:                o.Side.String(),
+		Price:               o.Price,
+		StopPrice:           o.StopPrice,
+		Quantity:            o.Quantity,
+		ExecutedQuantity:    o.ExecutedQuantity,
+		LeavesQuantity:      o.LeavesQuantity,
+		TimeInForce:         o.TimeInForce.String(),
+		MinQuantity:         o.MinQuantity,
+		MaxVisibleQuantity:  o.MaxVisibleQuantity,
+		DisplayLowQuantity:  o.DisplayLowQuantity,
+		DisplayHighQuantity: o.DisplayHighQuantity,
+		Slippage:            o.Slippage,
+		TrailingDistance:    o.TrailingDistance,
+		TrailingStep:        o.TrailingStep,
+		ParticipantID:       o.ParticipantID,
+		AccountID:           o.AccountID,
+		Quote:               o.Quote,
+	}
+}
+
+// event converts a JSONL event back into a MatchingEvent.
+func (je jsonEvent) event() (MatchingEvent, error) {
+	e := MatchingEvent{Timestamp: je.Timestamp}
+	for t, name := range jsonEventTypes {
+		if name == je.Type {
+			e.Type = t
+		}
+	}
+	switch e.Type {
+	case EventNewOrder, EventRejectOrder:
+		if je.Order == nil {
+			return e, fmt.Errorf("%s event without an order", je.Type)
+		}
+		o, err := je.Order.order()
+		if err != nil {
+			return e, err
+		}
+		e.Order = o
+		if e.Type == EventRejectOrder {
+			// Every code is tried, stopping short of overflowing.
+			var ok bool
+			if e.Reject, ok = parseEnum(je.Reject, matching.ErrorOK+1, matching.ErrorCode(math.MaxUint8-1)); !ok {
+				return e, fmt.Errorf("unknown reject code %q", je.Reject)
+			}
+		}
+	case EventCancelOrder:
+		e.OrderID = je.OrderID
+	case EventResetSession:
+	default:
+		return e, fmt.Errorf("unknown event type %q", je.Type)
+	}
+	return e, nil
+}
+
+// order converts a JSONL order back into a matching.Order.
+func (jo *jsonOrder) order() (matching.Order, error) {
+	o := matching.Order{
+		ID:                  jo.ID,
+		SymbolID:            jo.SymbolID,
+		Price:               jo.Price,
+		StopPrice:           jo.StopPrice,
+		Quantity:            jo.Quantity,
+		ExecutedQuantity:    jo.ExecutedQuantity,
+		LeavesQuantity:      jo.LeavesQuantity,
+		MinQuantity:         jo.MinQuantity,
+		MaxVisibleQuantity:  jo.MaxVisibleQuantity,
+		DisplayLowQuantity:  jo.DisplayLowQuantity,
+		DisplayHighQuantity: jo.DisplayHighQuantity,
+		Slippage:            jo.Slippage,
+		TrailingDistance:    jo.TrailingDistance,
+		TrailingStep:        jo.TrailingStep,
+		ParticipantID:       jo.ParticipantID,
+		AccountID:           jo.AccountID,
+		Quote:               jo.Quote,
+	}
+	var ok bool
+	if o.Type, ok = parseEnum(jo.Type, matching.OrderTypeMarket, matching.OrderTypeTrailingStopLimit); !ok {
+		return o, fmt.Errorf("unknown order type %q", jo.Type)
+	}
+	if o.Side, ok = parseEnum(jo.Side, matching.OrderSideBuy, matching.OrderSideSell); !ok {
+		return o, fmt.Errorf("unknown side %q", jo.Side)
+	}
+	if o.TimeInForce, ok = parseEnum(jo.TimeInForce, matching.OrderTimeInForceGTC, matching.OrderTimeInForceDay); !ok {
+		return o, fmt.Errorf("unknown time in force %q", jo.TimeInForce)
+	}
+	return o, nil
+}
+
+// parseEnum returns the value from first to last whose String is s.
+func parseEnum[T interface {
+	~uint8
+	String() string
+}](s string, first, last T) (T, bool) {
+	for v := first; v <= last; v++ {
+		if v.String() == s {
+			return v, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,93 @@
+package persistence
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+func TestJSONL_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "engine.journal")
+
+	iceberg := newLimitOrder(2, matching.OrderSideSell, 10100, 500)
+	iceberg.MaxVisibleQuantity = 100
+	iceberg.DisplayLowQuantity, iceberg.DisplayHighQuantity = 50, 150
+	iceberg.MinQuantity = 200
+	iceberg.TimeInForce = matching.OrderTimeInForceDay
+	iceberg.ParticipantID = 42
+	iceberg.AccountID = 7
+	iceberg.Quote = true
+	stop := newLimitOrder(3, matching.OrderSideBuy, 0, 10)
+	stop.Type = matching.OrderTypeTrailingStop
+	stop.TrailingDistance, stop.TrailingStep = -250, 5
+	events := []MatchingEvent{
+		{Type: EventNewOrder, Timestamp: 1, Order: newLimitOrder(1, matching.OrderSideBuy, 10000, 100)},
+		{Type: EventNewOrder, Timestamp: 2, Order: iceberg},
+		{Type: EventNewOrder, Timestamp: 3, Order: stop},
+		{Type: EventCancelOrder, Timestamp: 4, OrderID: 1},
+		{Type: EventResetSession, Timestamp: 5},
+		{Type: EventRejectOrder, Timestamp: 6, Order: matching.Order{ID: 9}, Reject: matching.ErrorOrderNotFound},
+	}
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	for _, e := range events {
+		if err := j.Append(e); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	var buf bytes.Buffer
+	n, err := ExportJSONL(path, &buf)
+	if err != nil || n != len(events) {
+		t.Fatalf("ExportJSONL: got %d, %v, want %d events", n, err, len(events))
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(events) || !strings.Contains(lines[1], `"time_in_force":"DAY"`) || !strings.Contains(lines[3], `"type":"cancel_order"`) {
+		t.Fatalf("unexpected JSONL:\n%s", buf.String())
+	}
+
+	imported := filepath.Join(dir, "imported.journal")
+	n, err = ImportJSONL(strings.NewReader(buf.String()+"\n\n"), imported)
+	if err != nil || n != len(events) {
+		t.Fatalf("ImportJSONL: got %d, %v, want %d events", n, err, len(events))
+	}
+	got, err := ReadAll(imported)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if len(got) != len(events) {
+		t.Fatalf("got %d events, want %d", len(got), len(events))
+	}
+	for i := range events {
+		if got[i] != events[i] {
+			t.Errorf("event %d: got %+v, want %+v", i, got[i], events[i])
+		}
+	}
+}
+
+func TestImportJSONL_Errors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "engine.journal")
+	for _, bad := range []string{
+		`{"type":"new_order","timestamp":1}`,
+		`{"type":"amend_order","timestamp":1}`,
+		`{"type":"cancel_order","timestamp":1,"order_id":1,"reason":"x"}`,
+		`{"type":"new_order","timestamp":1,"order":{"id":1,"order_type":"LIMIT","side":"SHORT","time_in_force":"GTC"}}`,
+		"{\"type\":\"reset_session\",\"timestamp\":1}\nnot json",
+	} {
+		if _, err := ImportJSONL(strings.NewReader(bad), path); err == nil || !strings.Contains(err.Error(), "line") {
+			t.Errorf("ImportJSONL(%q): got %v, want an error naming the line", bad, err)
+		}
+	}
+	if events, _ := ReadAll(path); len(events) != 0 {
+		t.Errorf("got %d events after failed imports, want none", len(events))
+	}
+}
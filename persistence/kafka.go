@@ -0,0 +1,143 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// KafkaHeader is a header of a KafkaMessage.
+type KafkaHeader struct {
+	Key   string
+	Value []byte
+}
+
+// KafkaMessage is a record produced to a Kafka topic.
+type KafkaMessage struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers []KafkaHeader
+}
+
+// KafkaProducer produces messages to Kafka.  Implementations adapt a Kafka
+// client, such as a kafka-go Writer or a franz-go client, so that the module
+// does not depend on one.  Produce must only return nil once every message
+// was acknowledged by all in-sync replicas (acks=all), and must keep the
+// messages of a partition in order, with an idempotent producer or a single
+// request in flight.
+type KafkaProducer interface {
+	Produce(ctx context.Context, messages []KafkaMessage) error
+}
+
+// KafkaSinkOptions configures NewKafkaSink.
+type KafkaSinkOptions struct {
+	// Topic receives the journal events.
+	Topic string
+	// TradeTopic receives the trades, "" for Topic.
+	TradeTopic string
+	// Timeout bounds each delivery, 0 for defaultKafkaTimeout.
+	Timeout time.Duration
+}
+
+// defaultKafkaTimeout is the default time limit of a KafkaSink delivery.
+const defaultKafkaTimeout = 10 * time.Second
+
+// KafkaSink is the reference SinkHandler, producing every change as a JSON
+// message.  Journal events have the JSONL form of ExportJSONL and trades the
+// type "trade", both with the "sequence" of the change, which is also the
+// "sequence" header so that consumers drop redelivered changes without
+// parsing them.  Journal events are keyed by order ID, so that the events of
+// an order stay in order in one partition, and trades by symbol ID.
+//
+//	{"sequence":7,"type":"new_order","timestamp":1700000000000000000,"order":{"id":1,...}}
+//	{"sequence":8,"type":"trade","timestamp":1700000000000000001,"trade":{"symbol_id":1,"buy_order_id":1,...}}
+type KafkaSink struct {
+	producer KafkaProducer
+	opts     KafkaSinkOptions
+}
+
+// NewKafkaSink creates a sink producing changes with producer.
+func NewKafkaSink(producer KafkaProducer, opts KafkaSinkOptions) *KafkaSink {
+	if opts.TradeTopic == "" {
+		opts.TradeTopic = opts.Topic
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultKafkaTimeout
+	}
+	return &KafkaSink{producer: producer, opts: opts}
+}
+
+// kafkaValue is the JSON value of a KafkaSink message.
+type kafkaValue struct {
+	Sequence uint64 `json:"sequence"`
+	jsonEvent
+	Trade *kafkaTrade `json:"trade,omitempty"`
+}
+
+// kafkaTrade is the JSON form of a trade.
+type kafkaTrade struct {
+	SymbolID    uint32 `json:"symbol_id"`
+	BuyOrderID  uint64 `json:"buy_order_id"`
+	SellOrderID uint64 `json:"sell_order_id"`
+	Price       uint64 `json:"price"`
+	Quantity    uint64 `json:"quantity"`
+	Aggressor   string `json:"aggressor"`
+}
+
+// Deliver produces the changes and waits for their acknowledgment.
+func (s *KafkaSink) Deliver(changes []Change) error {
+	messages := make([]KafkaMessage, len(changes))
+	for i, change := range changes {
+		var err error
+		if messages[i], err = s.message(change); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	return s.producer.Produce(ctx, messages)
+}
+
+// message converts a change to its Kafka message.
+func (s *KafkaSink) message(change Change) (KafkaMessage, error) {
+	value := kafkaValue{Sequence: change.Sequence}
+	msg := KafkaMessage{Topic: s.opts.Topic}
+	switch change.Type {
+	case ChangeJournal:
+		var err error
+		if value.jsonEvent, err = toJSONEvent(change.Event); err != nil {
+			return KafkaMessage{}, err
+		}
+		switch change.Event.Type {
+		case EventNewOrder, EventRejectOrder:
+			msg.Key = strconv.AppendUint(nil, change.Event.Order.ID, 10)
+		case EventCancelOrder:
+			msg.Key = strconv.AppendUint(nil, change.Event.OrderID, 10)
+		}
+	case ChangeTrade:
+		t := change.Trade
+		value.jsonEvent = jsonEvent{Type: "trade", Timestamp: t.Timestamp}
+		value.Trade = &kafkaTrade{
+			SymbolID:    t.SymbolID,
+			BuyOrderID:  t.BuyOrderID,
+			SellOrderID: t.SellOrderID,
+			Price:       t.Price,
+			Quantity:    t.Quantity,
+			Aggressor:   t.Aggressor.String(),
+		}
+		msg.Topic = s.opts.TradeTopic
+		msg.Key = strconv.AppendUint(nil, uint64(t.SymbolID), 10)
+	default:
+		return KafkaMessage{}, fmt.Errorf("persistence: unknown ChangeType %d", change.Type)
+	}
+
+	var err error
+	if msg.Value, err = json.Marshal(value); err != nil {
+		return KafkaMessage{}, err
+	}
+	msg.Headers = []KafkaHeader{{Key: "sequence", Value: strconv.AppendUint(nil, change.Sequence, 10)}}
+	return msg, nil
+}
@@ -0,0 +1,80 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrAlreadyInUse is returned when opening a journal or snapshot directory
+// for writing that another process already has open.  Two writers would
+// interleave their records and silently corrupt the journal.
+var ErrAlreadyInUse = errors.New("persistence: journal or snapshot directory in use by another process")
+
+// FileLock is an exclusive advisory lock on a lock file, held by a writer of
+// a journal or snapshot directory.
+type FileLock struct {
+	file *os.File
+}
+
+// LockFile takes an exclusive advisory lock on the file at path, creating it
+// if needed, without waiting.  It returns an error wrapping ErrAlreadyInUse
+// when another process, or another FileLock of this process, holds the lock.
+//
+// The lock is released by Unlock or when the process exits, even if it
+// crashes, so a stale lock file never blocks a restart.  The file itself is
+// left in place.  Platforms without flock take no lock.
+func LockFile(path string) (*FileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(f); err != nil {
+		_ = f.Close()
+		if errors.Is(err, ErrAlreadyInUse) {
+			return nil, fmt.Errorf("%w (%s)", ErrAlreadyInUse, path)
+		}
+		return nil, fmt.Errorf("persistence: locking %s: %w", path, err)
+	}
+	return &FileLock{file: f}, nil
+}
+
+// Unlock releases the lock.  It is safe to call on a nil FileLock.
+func (l *FileLock) Unlock() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// journalLockPath returns the lock file of the journal at path.  The journal
+// itself is not locked because Rotate replaces it.
+func journalLockPath(path string) string {
+	return path + ".lock"
+}
+
+// snapshotLockPath returns the lock file of a snapshot directory.  Its name
+// does not start with "snapshot-", so it is never taken for a snapshot.
+func snapshotLockPath(dir string) string {
+	return filepath.Join(dir, ".lock")
+}
+
+// lockStore locks the journal at journalPath and the snapshot directory,
+// creating the directory if needed.
+func lockStore(journalPath, snapshotDir string) ([]*FileLock, error) {
+	journal, err := LockFile(journalLockPath(journalPath))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(snapshotDir, 0o755); err != nil {
+		_ = journal.Unlock()
+		return nil, err
+	}
+	snapshots, err := LockFile(snapshotLockPath(snapshotDir))
+	if err != nil {
+		_ = journal.Unlock()
+		return nil, err
+	}
+	return []*FileLock{journal, snapshots}, nil
+}
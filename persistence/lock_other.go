@@ -0,0 +1,10 @@
+//go:build !unix
+
+package persistence
+
+import "os"
+
+// flock takes no lock on platforms without flock.
+func flock(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,95 @@
+//go:build unix
+
+package persistence
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+func TestOpenJournal_Lock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "engine.journal")
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+
+	if _, err := OpenJournal(path); !errors.Is(err, ErrAlreadyInUse) {
+		t.Fatalf("second OpenJournal: got %v, want ErrAlreadyInUse", err)
+	}
+	if _, err := ImportJSONL(strings.NewReader(""), path); !errors.Is(err, ErrAlreadyInUse) {
+		t.Fatalf("ImportJSONL: got %v, want ErrAlreadyInUse", err)
+	}
+	forced, err := OpenJournalWithOptions(path, JournalOptions{NoLock: true})
+	if err != nil {
+		t.Fatalf("OpenJournalWithOptions(NoLock): %v", err)
+	}
+	if err := forced.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if err := j.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	j, err = OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal after Close: %v", err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestManager_Lock(t *testing.T) {
+	dir := t.TempDir()
+	journal := filepath.Join(dir, "engine.journal")
+	snapshots := filepath.Join(dir, "snapshots")
+	mgr, err := NewManager(newManager(t), journal, snapshots)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if err := mgr.AddOrder(newLimitOrder(1, matching.OrderSideBuy, 10000, 100)); err != nil {
+		t.Fatalf("AddOrder: %v", err)
+	}
+
+	if _, err := NewManagerWithRecovery(newManager(t), journal, snapshots); !errors.Is(err, ErrAlreadyInUse) {
+		t.Fatalf("second manager: got %v, want ErrAlreadyInUse", err)
+	}
+	// The snapshot directory is locked on its own
+	other := filepath.Join(dir, "other.journal")
+	if _, err := NewManager(newManager(t), other, snapshots); !errors.Is(err, ErrAlreadyInUse) {
+		t.Fatalf("manager sharing the snapshots: got %v, want ErrAlreadyInUse", err)
+	}
+	j, err := OpenJournal(other)
+	if err != nil {
+		t.Fatalf("OpenJournal: the failed manager kept the journal locked: %v", err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	forced, err := NewManagerWithOptions(newManager(t), journal, snapshots, ManagerOptions{NoLock: true})
+	if err != nil {
+		t.Fatalf("NewManagerWithOptions(NoLock): %v", err)
+	}
+	if err := forced.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	mm := newManager(t)
+	mgr, err = NewManagerWithRecovery(mm, journal, snapshots)
+	if err != nil {
+		t.Fatalf("NewManagerWithRecovery after Close: %v", err)
+	}
+	defer mgr.Close()
+	if got := len(mm.Orders()); got != 1 {
+		t.Errorf("recovered orders: got %d, want 1", got)
+	}
+}
@@ -1,11 +1,13 @@
 package persistence
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/metrics"
 )
 
 // Manager is the top-level persistence facade.
@@ -21,33 +23,157 @@ type Manager struct {
 	mm          *matching.MarketManager
 	journal     *Journal
 	snapshotter *Snapshotter
+
+	// trades and recorder are set by AttachTradeStore; both are optional.
+	trades   *TradeStore
+	recorder *TradeRecorder
+
+	// marketData and marketDataRecorder are set by AttachMarketDataLog; both
+	// are optional.
+	marketData         *MarketDataLog
+	marketDataRecorder *MarketDataRecorder
+
+	// feed is set by AttachChangeFeed and optional.
+	feed *ChangeFeed
+
+	// recovery describes the recovery at startup, zero without one, and
+	// opened is the time the Manager was created.
+	recovery RecoveryStats
+	opened   time.Time
+
+	// locks are the locks on the journal and snapshot directory, nil with
+	// ManagerOptions.NoLock.
+	locks []*FileLock
+
+	// journalRejects is ManagerOptions.JournalRejects.
+	journalRejects bool
+}
+
+// ErrEngineNotEmpty is returned when recovering into a MarketManager that
+// already holds orders.
+var ErrEngineNotEmpty = errors.New("persistence: recovering into an engine with orders")
+
+// ManagerOptions configures NewManagerWithOptions.
+type ManagerOptions struct {
+	// Recover restores the engine from the latest snapshot in snapshotDir and
+	// the journal before the Manager is returned, so that no write can be
+	// accepted before the state of the previous run is back.  The engine may
+	// already hold its symbols and order books but no orders.
+	Recover bool
+	// Durability selects when journalled events are fsynced.  The zero value
+	// is DurabilityGroupCommit.
+	Durability Durability
+	// Snapshots configures the compression of the snapshots written by
+	// TakeSnapshot and ResetSession.
+	Snapshots SnapshotterOptions
+	// NoLock skips locking the journal and snapshot directory against other
+	// writers, for recovery tooling that must open them while their locks
+	// are held, for example by a hung process.  Two writers corrupt the
+	// journal.
+	NoLock bool
+	// JournalRejects appends an EventRejectOrder after every order or
+	// cancellation rejected by the engine, so that reject rates and reasons
+	// can be audited from the journal.
+	JournalRejects bool
+	// Audit checks the invariants of the recovered state with Audit before
+	// the Manager is returned, failing with an *AuditError if any is broken,
+	// so that a corrupt state never accepts traffic.  The report is kept in
+	// the Recovery of Stats.
+	Audit bool
 }
 
 // NewManager opens (or creates) the journal at journalPath, initialises the
 // snapshotter in snapshotDir, and returns a ready-to-use Manager.
 //
-// Call Recover separately before NewManager if you need to restore state from
-// a previous run.
+// NewManager starts from the engine as it is.  Use NewManagerWithRecovery to
+// restore the state of a previous run first; starting without recovery over
+// an existing journal silently loses its orders.
 func NewManager(
 	mm *matching.MarketManager,
 	journalPath string,
 	snapshotDir string,
 ) (*Manager, error) {
-	j, err := OpenJournal(journalPath)
+	return NewManagerWithOptions(mm, journalPath, snapshotDir, ManagerOptions{})
+}
+
+// NewManagerWithRecovery restores mm from the latest snapshot and the journal
+// of a previous run, then returns a Manager appending to the same journal.
+func NewManagerWithRecovery(
+	mm *matching.MarketManager,
+	journalPath string,
+	snapshotDir string,
+) (*Manager, error) {
+	return NewManagerWithOptions(mm, journalPath, snapshotDir, ManagerOptions{Recover: true})
+}
+
+// NewManagerWithOptions is NewManager with options.  With Recover set, the
+// journal is only opened for writing once the recovery has succeeded.
+//
+// Unless NoLock is set, the journal and snapshot directory are locked before
+// anything is read, until Close, and an error wrapping ErrAlreadyInUse is
+// returned when another process has them open.
+func NewManagerWithOptions(
+	mm *matching.MarketManager,
+	journalPath string,
+	snapshotDir string,
+	opts ManagerOptions,
+) (*Manager, error) {
+	var locks []*FileLock
+	if !opts.NoLock {
+		var err error
+		if locks, err = lockStore(journalPath, snapshotDir); err != nil {
+			return nil, err
+		}
+	}
+	unlock := func() {
+		for _, l := range locks {
+			_ = l.Unlock()
+		}
+	}
+
+	var recovery RecoveryStats
+	if opts.Recover {
+		if len(mm.Orders()) > 0 {
+			unlock()
+			return nil, ErrEngineNotEmpty
+		}
+		var err error
+		if recovery, err = RecoverWithStats(mm, journalPath, snapshotDir); err != nil {
+			unlock()
+			return nil, err
+		}
+		if opts.Audit {
+			recovery.Audit = Audit(mm)
+			if !recovery.Audit.OK() {
+				unlock()
+				return nil, &AuditError{Report: recovery.Audit}
+			}
+		}
+	}
+
+	// The journal is already locked with the snapshot directory.
+	j, err := OpenJournalWithOptions(journalPath, JournalOptions{NoLock: true})
 	if err != nil {
+		unlock()
 		return nil, fmt.Errorf("persistence: opening journal: %w", err)
 	}
+	j.SetDurability(opts.Durability)
 
-	sp, err := NewSnapshotter(snapshotDir)
+	sp, err := NewSnapshotterWithOptions(snapshotDir, opts.Snapshots)
 	if err != nil {
 		_ = j.Close()
+		unlock()
 		return nil, fmt.Errorf("persistence: opening snapshotter: %w", err)
 	}
 
 	return &Manager{
-		mm:          mm,
-		journal:     j,
-		snapshotter: sp,
+		mm:             mm,
+		journal:        j,
+		snapshotter:    sp,
+		recovery:       recovery,
+		opened:         time.Now(),
+		locks:          locks,
+		journalRejects: opts.JournalRejects,
 	}, nil
 }
 
@@ -64,15 +190,26 @@ func (m *Manager) AddOrder(order matching.Order) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if err := m.journal.Append(event); err != nil {
+	if err := m.append(event); err != nil {
 		return fmt.Errorf("persistence: journalling NewOrder: %w", err)
 	}
 	if code := m.mm.AddOrder(order); code != matching.ErrorOK {
-		return fmt.Errorf("persistence: AddOrder: %w", code.Error())
+		return m.rejected(order, code, fmt.Errorf("persistence: AddOrder: %w", code.Error()))
 	}
 	return nil
 }
 
+// NextOrderID allocates the ID of a new order with the order ID allocator of
+// the engine.  The allocator state is saved with every snapshot, and the IDs
+// of journalled orders are observed when they are replayed, so IDs are not
+// allocated twice across restarts.  Set the allocator with
+// MarketManager.SetOrderIDAllocator before recovering.
+func (m *Manager) NextOrderID() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mm.NextOrderID()
+}
+
 // CancelOrder journals the cancellation and then removes the order from the
 // matching engine.
 func (m *Manager) CancelOrder(orderID uint64) error {
@@ -85,15 +222,57 @@ func (m *Manager) CancelOrder(orderID uint64) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if err := m.journal.Append(event); err != nil {
+	if err := m.append(event); err != nil {
 		return fmt.Errorf("persistence: journalling CancelOrder: %w", err)
 	}
 	if code := m.mm.DeleteOrder(orderID); code != matching.ErrorOK {
-		return fmt.Errorf("persistence: CancelOrder: %w", code.Error())
+		return m.rejected(matching.Order{ID: orderID}, code, fmt.Errorf("persistence: CancelOrder: %w", code.Error()))
 	}
 	return nil
 }
 
+// ExpireOrders journals a cancellation for every open order that outlived the
+// time to live of its order book and then expires it in the matching engine,
+// reporting it with OnExpireOrder.  It returns the IDs of the expired orders
+// in ID order.  Deployments with order time to lives call it periodically
+// instead of relying on the unjournaled SymbolConfig.ExpirySweep.
+func (m *Manager) ExpireOrders() ([]uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := m.mm.ExpiredOrders()
+	for i, id := range ids {
+		event := MatchingEvent{
+			Type:      EventCancelOrder,
+			Timestamp: time.Now().UnixNano(),
+			OrderID:   id,
+		}
+		if err := m.append(event); err != nil {
+			return ids[:i], fmt.Errorf("persistence: journalling CancelOrder: %w", err)
+		}
+		m.mm.ExpireOrder(id)
+	}
+	return ids, nil
+}
+
+// rejected journals the reject of an operation with JournalRejects and
+// returns err, the error of the operation.  It is called with m.mu held.
+func (m *Manager) rejected(order matching.Order, code matching.ErrorCode, err error) error {
+	if !m.journalRejects {
+		return err
+	}
+	event := MatchingEvent{
+		Type:      EventRejectOrder,
+		Timestamp: time.Now().UnixNano(),
+		Order:     order,
+		Reject:    code,
+	}
+	if jerr := m.append(event); jerr != nil {
+		return fmt.Errorf("%w (journalling RejectOrder: %v)", err, jerr)
+	}
+	return err
+}
+
 // TakeSnapshot captures the current engine state in a background goroutine.
 //
 // Copy-on-Write approach:
@@ -101,6 +280,10 @@ func (m *Manager) CancelOrder(orderID uint64) error {
 //  2. Release the lock so the engine can continue matching.
 //  3. Write the snapshot file in a background goroutine.
 //
+// The background writes are bounded by the Workers of the snapshotter
+// options: a snapshot taken while the previous ones are still compressed
+// waits for them, so checkpoints never take more cores than configured.
+//
 // errCh receives exactly one value when the background goroutine finishes.
 // Callers that do not care about the result may pass nil for errCh.
 func (m *Manager) TakeSnapshot(errCh chan<- error) {
@@ -118,6 +301,268 @@ func (m *Manager) TakeSnapshot(errCh chan<- error) {
 	}()
 }
 
+// SaveSnapshot takes a snapshot like TakeSnapshot but waits for it to be
+// written and returns the result.
+func (m *Manager) SaveSnapshot() error {
+	errCh := make(chan error, 1)
+	m.TakeSnapshot(errCh)
+	return <-errCh
+}
+
+// ResetSession performs the end-of-day rollover:
+//  1. Journals an EventResetSession and calls MarketManager.ResetSession, which
+//     cancels day orders, carries GTC orders and resets session statistics.
+//  2. Writes a start-of-day snapshot synchronously.
+//  3. Rotates the journal so the new session starts with an empty segment.
+//
+// The manager lock is held throughout, so no order can slip in between the
+// snapshot and the rotation.  If the snapshot cannot be written the journal is
+// not rotated and the reset event is replayed by Recover instead.
+// It returns the path of the archived journal segment.
+func (m *Manager) ResetSession() (string, error) {
+	event := MatchingEvent{
+		Type:      EventResetSession,
+		Timestamp: time.Now().UnixNano(),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.append(event); err != nil {
+		return "", fmt.Errorf("persistence: journalling ResetSession: %w", err)
+	}
+	m.mm.ResetSession()
+
+	if err := m.snapshotter.Save(captureSnapshot(m.mm)); err != nil {
+		return "", fmt.Errorf("persistence: start-of-day snapshot: %w", err)
+	}
+	archive, err := m.journal.Rotate()
+	if err != nil {
+		return "", fmt.Errorf("persistence: rotating journal: %w", err)
+	}
+	return archive, nil
+}
+
+// RotateJournal archives the journal at runtime, for example to bound its
+// size between session resets.  A snapshot is written synchronously first so
+// that recovery does not need the archived segment, then the journal is
+// rotated, both under the manager lock.  If the snapshot cannot be written
+// the journal is not rotated.  It returns the path of the archived segment.
+func (m *Manager) RotateJournal() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.snapshotter.Save(captureSnapshot(m.mm)); err != nil {
+		return "", fmt.Errorf("persistence: rotation snapshot: %w", err)
+	}
+	archive, err := m.journal.Rotate()
+	if err != nil {
+		return "", fmt.Errorf("persistence: rotating journal: %w", err)
+	}
+	return archive, nil
+}
+
+// AttachTradeStore starts recording every trade executed by the engine into
+// store.  The engine's current handler keeps receiving all events.
+//
+// Attach the store after Recover: trades re-executed while replaying the
+// journal are already in the store and would otherwise be recorded twice.
+// The Manager takes ownership of store and closes it in Close.
+func (m *Manager) AttachTradeStore(store *TradeStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.trades = store
+	m.recorder = NewTradeRecorder(store, m.mm.Handler())
+	m.mm.SetHandler(m.recorder)
+}
+
+// TradesBetween returns the trades of a symbol recorded in the half-open
+// interval [from, to), ordered by timestamp.
+func (m *Manager) TradesBetween(symbolID uint32, from, to time.Time) ([]TradeRecord, error) {
+	m.mu.Lock()
+	store := m.trades
+	m.mu.Unlock()
+
+	if store == nil {
+		return nil, ErrNoTradeStore
+	}
+	return store.TradesBetween(symbolID, from, to), nil
+}
+
+// AttachMarketDataLog starts logging the trades and price level changes
+// published by the engine into log, so that downstream consumers can replay
+// them by sequence number.  The engine's current handler keeps receiving all
+// events.
+//
+// As with AttachTradeStore, attach the log after Recover so that events
+// re-published while replaying the journal are not logged twice.  The
+// Manager takes ownership of log and closes it in Close.
+func (m *Manager) AttachMarketDataLog(log *MarketDataLog) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.marketData = log
+	m.marketDataRecorder = NewMarketDataRecorder(log, m.mm.Handler())
+	m.mm.SetHandler(m.marketDataRecorder)
+}
+
+// AttachChangeFeed streams every event journalled from now on, and the
+// trades of the engine if the feed was opened with Trades, to the sink of
+// feed for change-data-capture.  The engine's current handler keeps
+// receiving all events.  Close closes the feed.
+func (m *Manager) AttachChangeFeed(feed *ChangeFeed) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.feed = feed
+	if feed.opts.Trades {
+		m.mm.SetHandler(&changeRecorder{MarketHandler: m.mm.Handler(), feed: feed})
+	}
+}
+
+// append journals an event and adds it to the change feed.  It is called
+// with m.mu held.  A failed addition to the change feed does not fail the
+// operation: the event is journalled, and the feed reports the error.
+func (m *Manager) append(event MatchingEvent) error {
+	if err := m.journal.Append(event); err != nil {
+		return err
+	}
+	if m.feed != nil {
+		_ = m.feed.AppendEvent(event)
+	}
+	return nil
+}
+
+// MarketDataLog returns the attached market data log, nil if none.
+func (m *Manager) MarketDataLog() *MarketDataLog {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.marketData
+}
+
+// MarketDataFrom returns up to max logged market data events starting at
+// sequence number seq.  See MarketDataLog.ReadFrom.
+func (m *Manager) MarketDataFrom(seq uint64, max int) ([]MarketDataEvent, error) {
+	m.mu.Lock()
+	log := m.marketData
+	m.mu.Unlock()
+
+	if log == nil {
+		return nil, ErrNoMarketDataLog
+	}
+	return log.ReadFrom(seq, max)
+}
+
+// Statistics contains the latency measurements of the persistence manager and
+// the engine it wraps.
+type Statistics struct {
+	// Engine holds the matching engine latencies and liquidity counters.
+	Engine matching.Statistics
+	// JournalSync is the time from journal Append until the event was fsynced.
+	JournalSync metrics.Snapshot
+}
+
+// EnableLatencyTracking starts recording engine and journal latencies.
+func (m *Manager) EnableLatencyTracking() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mm.EnableLatencyTracking()
+	m.journal.EnableLatencyTracking()
+}
+
+// Statistics returns the recorded latency statistics.
+func (m *Manager) Statistics() Statistics {
+	m.mu.Lock()
+	engine := m.mm.Statistics()
+	m.mu.Unlock()
+	return Statistics{
+		Engine:      engine,
+		JournalSync: m.journal.SyncLatency(),
+	}
+}
+
+// Health is the state of the durable storage of a Manager.
+type Health struct {
+	// JournalErr is the error of the last journal flush, nil if it succeeded.
+	JournalErr error
+	// LastSnapshot is the capture time of the newest snapshot, zero if there
+	// is none.
+	LastSnapshot time.Time
+	// SnapshotErr is the error listing the snapshots, if any.
+	SnapshotErr error
+}
+
+// Health reports whether the journal is still writable and the age of the
+// newest snapshot.
+func (m *Manager) Health() Health {
+	last, err := m.snapshotter.LatestTime()
+	return Health{
+		JournalErr:   m.journal.Err(),
+		LastSnapshot: last,
+		SnapshotErr:  err,
+	}
+}
+
+// DurabilityStats tells how far the durable state of a Manager lags behind
+// the engine, so that operators can alert before a crash would lose more than
+// they expect.
+type DurabilityStats struct {
+	// Journal is the size of the journal and the bytes not yet fsynced.
+	Journal JournalStats
+	// SinceSync is the time since the last successful journal fsync, or
+	// since the Manager was opened if there was none.  With group commit it
+	// stays around the flush interval while the disk keeps up.
+	SinceSync time.Duration
+	// LastSnapshot is the capture time of the newest snapshot, zero if there
+	// is none, and SinceSnapshot the time since then.
+	LastSnapshot  time.Time
+	SinceSnapshot time.Duration
+	// SnapshotErr is the error listing the snapshots, if any.
+	SnapshotErr error
+	// Recovery describes the recovery at startup.
+	Recovery RecoveryStats
+}
+
+// Stats reports the journal lag, the snapshot staleness and the startup
+// recovery.
+func (m *Manager) Stats() DurabilityStats {
+	now := time.Now()
+	stats := DurabilityStats{
+		Journal:  m.journal.Stats(),
+		Recovery: m.recovery,
+	}
+	lastSync := stats.Journal.LastSync
+	if lastSync.IsZero() {
+		lastSync = m.opened
+	}
+	stats.SinceSync = now.Sub(lastSync)
+	stats.LastSnapshot, stats.SnapshotErr = m.snapshotter.LatestTime()
+	if !stats.LastSnapshot.IsZero() {
+		stats.SinceSnapshot = now.Sub(stats.LastSnapshot)
+	}
+	return stats
+}
+
+// View calls fn with the underlying MarketManager under the manager lock, so
+// that order books and orders can be read consistently while other goroutines
+// submit orders.  fn must not retain mm or modify it.
+func (m *Manager) View(fn func(mm *matching.MarketManager)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(m.mm)
+}
+
+// Update calls fn with the underlying MarketManager under the manager lock to
+// make changes the journal does not record, such as adding symbols or halting
+// trading.  Such changes are lost on restart unless a snapshot is taken after
+// them, see SaveSnapshot.
+func (m *Manager) Update(fn func(mm *matching.MarketManager) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fn(m.mm)
+}
+
 // MarketManager returns the underlying MarketManager.
 // Callers that need direct (non-persisted) access to the engine can use this,
 // but note that operations performed directly on the MarketManager are not
@@ -126,7 +571,39 @@ func (m *Manager) MarketManager() *matching.MarketManager {
 	return m.mm
 }
 
-// Close flushes the journal and releases all resources.
+// Snapshotter returns the snapshotter used by TakeSnapshot, for example to
+// attach warm storage and tier old snapshots.
+func (m *Manager) Snapshotter() *Snapshotter {
+	return m.snapshotter
+}
+
+// Close flushes the journal (and the trade store and market data log, if
+// attached) and releases all resources.  The first error encountered is returned.
 func (m *Manager) Close() error {
-	return m.journal.Close()
+	err := m.journal.Close()
+	if m.trades != nil {
+		if terr := m.trades.Close(); err == nil {
+			err = terr
+		}
+		if rerr := m.recorder.Err(); err == nil && rerr != nil {
+			err = fmt.Errorf("persistence: recording trades: %w", rerr)
+		}
+	}
+	if m.marketData != nil {
+		if lerr := m.marketData.Close(); err == nil {
+			err = lerr
+		}
+		if rerr := m.marketDataRecorder.Err(); err == nil && rerr != nil {
+			err = fmt.Errorf("persistence: recording market data: %w", rerr)
+		}
+	}
+	if m.feed != nil {
+		if ferr := m.feed.Close(); err == nil && ferr != nil {
+			err = fmt.Errorf("persistence: change feed: %w", ferr)
+		}
+	}
+	for _, l := range m.locks {
+		_ = l.Unlock()
+	}
+	return err
 }
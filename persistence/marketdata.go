@@ -0,0 +1,431 @@
+package persistence
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tienpsm/go-trader/events"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// ErrNoMarketDataLog is returned by Manager.MarketDataFrom when no market data
+// log has been attached.
+var ErrNoMarketDataLog = errors.New("persistence: no market data log attached")
+
+// ErrSequenceUnavailable is returned by MarketDataLog.ReadFrom when the
+// requested sequence number is older than the first event in the log.
+var ErrSequenceUnavailable = errors.New("persistence: market data sequence not available")
+
+// MarketDataEventType identifies the kind of a MarketDataEvent.
+type MarketDataEventType uint8
+
+const (
+	// MarketDataTrade is a trade between two orders.
+	MarketDataTrade MarketDataEventType = iota + 1
+	// MarketDataLevelAdd is a new price level.
+	MarketDataLevelAdd
+	// MarketDataLevelUpdate is a change of an existing price level.
+	MarketDataLevelUpdate
+	// MarketDataLevelDelete is a price level that no longer has any orders.
+	MarketDataLevelDelete
+)
+
+// String returns the string representation of a MarketDataEventType.
+func (t MarketDataEventType) String() string {
+	switch t {
+	case MarketDataTrade:
+		return "TRADE"
+	case MarketDataLevelAdd:
+		return "LEVEL_ADD"
+	case MarketDataLevelUpdate:
+		return "LEVEL_UPDATE"
+	case MarketDataLevelDelete:
+		return "LEVEL_DELETE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// MarketDataEvent is an outbound market data event as observed by the
+// engine's handler.  Trade is set for trades; Level and Top are set for level
+// events.
+type MarketDataEvent struct {
+	// Sequence is the position of the event in the log, starting at 1.
+	Sequence uint64
+	// Timestamp is Unix nanoseconds at the time the event was logged.
+	Timestamp int64
+	// Type is the kind of event.
+	Type MarketDataEventType
+	// SymbolID is the symbol of the trade or order book.
+	SymbolID uint32
+
+	// Trade is the trade of a MarketDataTrade event.
+	Trade matching.Trade
+	// Level is the state of the level after a level event.
+	Level matching.Level
+	// Top is true if the level is the best bid or ask.
+	Top bool
+}
+
+// marketDataWireSize is the fixed byte size of a serialised MarketDataEvent.
+// Layout (all big-endian):
+//
+//	 8 – Sequence
+//	 8 – Timestamp
+//	 1 – Type
+//	 4 – SymbolID
+//	42 – payload
+//
+// A trade payload is BuyOrderID, SellOrderID, Price, Quantity (8 bytes each)
+// and Aggressor (1 byte).  A level payload is Level.Type (1 byte), Price,
+// TotalVolume, HiddenVolume, VisibleVolume, Orders (8 bytes each) and Top
+// (1 byte).
+//
+// Total: 63 bytes
+const marketDataWireSize = 63
+
+// marshalMarketData writes e into buf (must be at least marketDataWireSize bytes).
+func marshalMarketData(buf []byte, e MarketDataEvent) {
+	for i := range buf[:marketDataWireSize] {
+		buf[i] = 0
+	}
+	binary.BigEndian.PutUint64(buf[0:8], e.Sequence)
+	binary.BigEndian.PutUint64(buf[8:16], uint64(e.Timestamp))
+	buf[16] = uint8(e.Type)
+	binary.BigEndian.PutUint32(buf[17:21], e.SymbolID)
+
+	p := buf[21:marketDataWireSize]
+	if e.Type == MarketDataTrade {
+		binary.BigEndian.PutUint64(p[0:8], e.Trade.BuyOrderID)
+		binary.BigEndian.PutUint64(p[8:16], e.Trade.SellOrderID)
+		binary.BigEndian.PutUint64(p[16:24], e.Trade.Price)
+		binary.BigEndian.PutUint64(p[24:32], e.Trade.Quantity)
+		p[32] = uint8(e.Trade.Aggressor)
+		return
+	}
+	p[0] = uint8(e.Level.Type)
+	binary.BigEndian.PutUint64(p[1:9], e.Level.Price)
+	binary.BigEndian.PutUint64(p[9:17], e.Level.TotalVolume)
+	binary.BigEndian.PutUint64(p[17:25], e.Level.HiddenVolume)
+	binary.BigEndian.PutUint64(p[25:33], e.Level.VisibleVolume)
+	binary.BigEndian.PutUint64(p[33:41], e.Level.Orders)
+	if e.Top {
+		p[41] = 1
+	}
+}
+
+// unmarshalMarketData reads an event from buf (must be at least
+// marketDataWireSize bytes).
+func unmarshalMarketData(buf []byte) MarketDataEvent {
+	e := MarketDataEvent{
+		Sequence:  binary.BigEndian.Uint64(buf[0:8]),
+		Timestamp: int64(binary.BigEndian.Uint64(buf[8:16])),
+		Type:      MarketDataEventType(buf[16]),
+		SymbolID:  binary.BigEndian.Uint32(buf[17:21]),
+	}
+
+	p := buf[21:marketDataWireSize]
+	if e.Type == MarketDataTrade {
+		e.Trade = matching.Trade{
+			SymbolID:    e.SymbolID,
+			BuyOrderID:  binary.BigEndian.Uint64(p[0:8]),
+			SellOrderID: binary.BigEndian.Uint64(p[8:16]),
+			Price:       binary.BigEndian.Uint64(p[16:24]),
+			Quantity:    binary.BigEndian.Uint64(p[24:32]),
+			Aggressor:   matching.OrderSide(p[32]),
+		}
+		return e
+	}
+	e.Level = matching.Level{
+		Type:          matching.LevelType(p[0]),
+		Price:         binary.BigEndian.Uint64(p[1:9]),
+		TotalVolume:   binary.BigEndian.Uint64(p[9:17]),
+		HiddenVolume:  binary.BigEndian.Uint64(p[17:25]),
+		VisibleVolume: binary.BigEndian.Uint64(p[25:33]),
+		Orders:        binary.BigEndian.Uint64(p[33:41]),
+	}
+	e.Top = p[41] == 1
+	return e
+}
+
+// MarketDataLog is an append-only, file-backed log of outbound market data
+// events with gap-free sequence numbers, so that downstream consumers that
+// disconnect can ask for a replay from the last sequence they processed.
+//
+// It is separate from the Journal: the journal records the orders needed to
+// rebuild the engine, the market data log records what the engine published.
+// Records have a fixed size, so the record of any sequence number is found
+// without an index and a torn record left by a crash is truncated away on
+// open.  Like the Journal, writes are buffered and flushed every
+// defaultFlushInterval.
+type MarketDataLog struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	// first is the sequence number of the first record in the file.
+	first uint64
+	// last is the sequence number of the last appended event, 0 if empty.
+	last uint64
+
+	ticker *time.Ticker
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// OpenMarketDataLog opens (or creates) the market data log at path and starts
+// the background flush goroutine.  Sequence numbers continue from the last
+// complete record in the file.
+func OpenMarketDataLog(path string) (*MarketDataLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	l := &MarketDataLog{
+		file:   f,
+		first:  1,
+		ticker: time.NewTicker(defaultFlushInterval),
+		done:   make(chan struct{}),
+	}
+
+	// Drop a torn tail record so that new records stay aligned.
+	valid := info.Size() - info.Size()%marketDataWireSize
+	if valid > 0 {
+		var buf [marketDataWireSize]byte
+		if _, err := f.ReadAt(buf[:], 0); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		l.first = unmarshalMarketData(buf[:]).Sequence
+		l.last = l.first + uint64(valid/marketDataWireSize) - 1
+	}
+	if err := f.Truncate(valid); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	l.writer = bufio.NewWriterSize(f, defaultBufSize)
+
+	l.wg.Add(1)
+	go l.flushLoop()
+	return l, nil
+}
+
+// Append assigns the next sequence number to e, stamps it with the current
+// time if it has no timestamp, and writes it to the log.  It returns the
+// sequence number.  It is safe to call from multiple goroutines concurrently.
+func (l *MarketDataLog) Append(e MarketDataEvent) (uint64, error) {
+	if e.Timestamp == 0 {
+		e.Timestamp = time.Now().UnixNano()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.Sequence = l.last + 1
+	var buf [marketDataWireSize]byte
+	marshalMarketData(buf[:], e)
+	if _, err := l.writer.Write(buf[:]); err != nil {
+		return 0, err
+	}
+	l.last = e.Sequence
+	return e.Sequence, nil
+}
+
+// LastSequence returns the sequence number of the last appended event, or 0
+// if the log is empty.
+func (l *MarketDataLog) LastSequence() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.last
+}
+
+// ReadFrom returns up to max events starting at sequence number seq, in
+// sequence order.  A max of 0 returns every remaining event.  It returns no
+// events if seq is past the end of the log, and ErrSequenceUnavailable if seq
+// is older than the first event in the log.
+func (l *MarketDataLog) ReadFrom(seq uint64, max int) ([]MarketDataEvent, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if seq < l.first {
+		return nil, ErrSequenceUnavailable
+	}
+	if seq > l.last {
+		return nil, nil
+	}
+	// Buffered events must reach the file before it is read.
+	if err := l.writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	n := l.last - seq + 1
+	if max > 0 && uint64(max) < n {
+		n = uint64(max)
+	}
+	buf := make([]byte, n*marketDataWireSize)
+	if _, err := l.file.ReadAt(buf, int64(seq-l.first)*marketDataWireSize); err != nil {
+		return nil, err
+	}
+
+	events := make([]MarketDataEvent, n)
+	for i := range events {
+		events[i] = unmarshalMarketData(buf[i*marketDataWireSize:])
+		if events[i].Sequence != seq+uint64(i) {
+			return events[:i], fmt.Errorf("persistence: market data log out of sequence at %d", seq+uint64(i))
+		}
+	}
+	return events, nil
+}
+
+// Flush forces all buffered events to be written to disk (fsync).
+func (l *MarketDataLog) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.flush()
+}
+
+// flush must be called with l.mu held.
+func (l *MarketDataLog) flush() error {
+	if err := l.writer.Flush(); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// Close flushes remaining events, stops the background goroutine, and closes
+// the underlying file.
+func (l *MarketDataLog) Close() error {
+	l.ticker.Stop()
+	close(l.done)
+	l.wg.Wait()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.flush(); err != nil {
+		_ = l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+// flushLoop periodically flushes the write buffer.
+func (l *MarketDataLog) flushLoop() {
+	defer l.wg.Done()
+	for {
+		select {
+		case <-l.ticker.C:
+			l.mu.Lock()
+			_ = l.flush()
+			l.mu.Unlock()
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// MarketDataRecorder is a matching.MarketHandler that appends trades and
+// price level changes to a MarketDataLog and forwards all events to the
+// wrapped handler.
+//
+// Handler callbacks cannot return errors, so the first failed append is kept
+// and reported by Err.
+type MarketDataRecorder struct {
+	matching.MarketHandler
+	log *MarketDataLog
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewMarketDataRecorder creates a recorder that logs market data to log and
+// forwards events to next.  A nil next is replaced by a no-op handler.
+func NewMarketDataRecorder(log *MarketDataLog, next matching.MarketHandler) *MarketDataRecorder {
+	if next == nil {
+		next = &matching.DefaultMarketHandler{}
+	}
+	return &MarketDataRecorder{MarketHandler: next, log: log}
+}
+
+// OnTrade records the trade and forwards it to the wrapped handler.
+func (r *MarketDataRecorder) OnTrade(trade matching.Trade) {
+	r.record(MarketDataEvent{Type: MarketDataTrade, SymbolID: trade.SymbolID, Trade: trade})
+	r.MarketHandler.OnTrade(trade)
+}
+
+// OnAddLevel records the new level and forwards it to the wrapped handler.
+func (r *MarketDataRecorder) OnAddLevel(orderBook *matching.OrderBook, level matching.Level, top bool) {
+	r.recordLevel(MarketDataLevelAdd, orderBook.Symbol().ID, level, top)
+	r.MarketHandler.OnAddLevel(orderBook, level, top)
+}
+
+// OnUpdateLevel records the level change and forwards it to the wrapped
+// handler.
+func (r *MarketDataRecorder) OnUpdateLevel(orderBook *matching.OrderBook, level matching.Level, top bool) {
+	r.recordLevel(MarketDataLevelUpdate, orderBook.Symbol().ID, level, top)
+	r.MarketHandler.OnUpdateLevel(orderBook, level, top)
+}
+
+// OnDeleteLevel records the deleted level and forwards it to the wrapped
+// handler.
+func (r *MarketDataRecorder) OnDeleteLevel(orderBook *matching.OrderBook, level matching.Level, top bool) {
+	r.recordLevel(MarketDataLevelDelete, orderBook.Symbol().ID, level, top)
+	r.MarketHandler.OnDeleteLevel(orderBook, level, top)
+}
+
+// Subscribe records the trades and level changes published on an events bus,
+// as an alternative to installing the recorder as the engine's handler.
+// Events are not forwarded to the wrapped handler.  Both topics must be
+// delivered by the same subscriber to keep them in engine order.
+func (r *MarketDataRecorder) Subscribe(s *events.Subscriber) {
+	events.Subscribe(s, events.TopicTrade, func(trade matching.Trade) {
+		r.record(MarketDataEvent{Type: MarketDataTrade, SymbolID: trade.SymbolID, Trade: trade})
+	})
+	events.Subscribe(s, events.TopicLevel, func(c events.LevelChange) {
+		t := MarketDataLevelUpdate
+		switch c.Action {
+		case events.LevelAdd:
+			t = MarketDataLevelAdd
+		case events.LevelDelete:
+			t = MarketDataLevelDelete
+		}
+		r.recordLevel(t, c.SymbolID, c.Level, c.Top)
+	})
+}
+
+// recordLevel appends a level event to the log.
+func (r *MarketDataRecorder) recordLevel(t MarketDataEventType, symbolID uint32, level matching.Level, top bool) {
+	r.record(MarketDataEvent{Type: t, SymbolID: symbolID, Level: level, Top: top})
+}
+
+// record appends an event to the log, keeping the first error.
+func (r *MarketDataRecorder) record(e MarketDataEvent) {
+	if _, err := r.log.Append(e); err != nil {
+		r.mu.Lock()
+		if r.err == nil {
+			r.err = err
+		}
+		r.mu.Unlock()
+	}
+}
+
+// Err returns the first error encountered while recording market data.
+func (r *MarketDataRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
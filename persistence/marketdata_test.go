@@ -0,0 +1,174 @@
+package persistence
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tienpsm/go-trader/events"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+func TestMarketDataLog_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "marketdata.dat")
+	log, err := OpenMarketDataLog(path)
+	if err != nil {
+		t.Fatalf("OpenMarketDataLog: %v", err)
+	}
+
+	trade := matching.Trade{SymbolID: 1, BuyOrderID: 2, SellOrderID: 3, Price: 10000, Quantity: 40, Aggressor: matching.OrderSideSell}
+	level := matching.Level{Type: matching.LevelTypeAsk, Price: 10100, TotalVolume: 300, HiddenVolume: 100, VisibleVolume: 200, Orders: 3}
+	for i, e := range []MarketDataEvent{
+		{Type: MarketDataTrade, SymbolID: 1, Trade: trade},
+		{Type: MarketDataLevelAdd, SymbolID: 1, Level: level, Top: true},
+		{Type: MarketDataLevelDelete, SymbolID: 2, Level: level},
+	} {
+		seq, err := log.Append(e)
+		if err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		if seq != uint64(i+1) {
+			t.Errorf("sequence: got %d, want %d", seq, i+1)
+		}
+	}
+
+	// Reads see buffered events.
+	got, err := log.ReadFrom(2, 0)
+	if err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("events: got %d, want 2", len(got))
+	}
+	if got[0].Sequence != 2 || got[0].Type != MarketDataLevelAdd || got[0].Level != level || !got[0].Top {
+		t.Errorf("level event: got %+v", got[0])
+	}
+	if got[1].SymbolID != 2 || got[1].Type != MarketDataLevelDelete || got[1].Top {
+		t.Errorf("delete event: got %+v", got[1])
+	}
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// A torn record is dropped and sequence numbers continue after reopening.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	f.Write([]byte{0, 0, 0})
+	f.Close()
+
+	log, err = OpenMarketDataLog(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer log.Close()
+	if log.LastSequence() != 3 {
+		t.Errorf("last sequence: got %d, want 3", log.LastSequence())
+	}
+	if seq, _ := log.Append(MarketDataEvent{Type: MarketDataTrade, SymbolID: 1, Trade: trade}); seq != 4 {
+		t.Errorf("sequence after reopen: got %d, want 4", seq)
+	}
+
+	got, err = log.ReadFrom(1, 1)
+	if err != nil || len(got) != 1 {
+		t.Fatalf("ReadFrom: got %d events, %v", len(got), err)
+	}
+	if got[0].Trade != trade || got[0].Timestamp == 0 {
+		t.Errorf("trade event: got %+v", got[0])
+	}
+	if got, _ := log.ReadFrom(5, 0); len(got) != 0 {
+		t.Errorf("past the end: got %d events, want 0", len(got))
+	}
+	if _, err := log.ReadFrom(0, 0); !errors.Is(err, ErrSequenceUnavailable) {
+		t.Errorf("sequence 0: got %v, want ErrSequenceUnavailable", err)
+	}
+}
+
+func TestMarketDataRecorder(t *testing.T) {
+	dir := t.TempDir()
+	handlerLog, err := OpenMarketDataLog(filepath.Join(dir, "handler.dat"))
+	if err != nil {
+		t.Fatalf("OpenMarketDataLog: %v", err)
+	}
+	defer handlerLog.Close()
+	busLog, err := OpenMarketDataLog(filepath.Join(dir, "bus.dat"))
+	if err != nil {
+		t.Fatalf("OpenMarketDataLog: %v", err)
+	}
+	defer busLog.Close()
+
+	bus := events.NewBus()
+	NewMarketDataRecorder(busLog, nil).Subscribe(bus.NewSubscriber(64, events.Block))
+	recorder := NewMarketDataRecorder(handlerLog, events.NewMarketHandler(bus))
+
+	mm := matching.NewMarketManagerWithHandler(recorder)
+	mm.EnableMatching()
+	symbol := matching.NewSymbol(1, "AAPL")
+	mm.AddSymbol(symbol)
+	mm.AddOrderBook(symbol)
+	mm.AddOrder(newLimitOrder(1, matching.OrderSideSell, 10000, 100))
+	mm.AddOrder(newLimitOrder(2, matching.OrderSideBuy, 10000, 100))
+	bus.Close()
+
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	want := []MarketDataEventType{MarketDataLevelAdd, MarketDataLevelAdd, MarketDataLevelDelete, MarketDataLevelDelete, MarketDataTrade}
+	for name, log := range map[string]*MarketDataLog{"handler": handlerLog, "bus": busLog} {
+		got, err := log.ReadFrom(1, 0)
+		if err != nil {
+			t.Fatalf("%s: ReadFrom: %v", name, err)
+		}
+		types := make([]MarketDataEventType, len(got))
+		for i, e := range got {
+			types[i] = e.Type
+		}
+		if len(types) != len(want) {
+			t.Fatalf("%s: got %v, want %v", name, types, want)
+		}
+		for i := range want {
+			if types[i] != want[i] {
+				t.Errorf("%s: got %v, want %v", name, types, want)
+				break
+			}
+		}
+	}
+}
+
+func TestManager_AttachMarketDataLog(t *testing.T) {
+	dir := t.TempDir()
+	mm := matching.NewMarketManager()
+	m, err := NewManager(mm, filepath.Join(dir, "journal.wal"), filepath.Join(dir, "snapshots"))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if _, err := m.MarketDataFrom(1, 0); !errors.Is(err, ErrNoMarketDataLog) {
+		t.Errorf("MarketDataFrom without log: got %v, want ErrNoMarketDataLog", err)
+	}
+
+	log, err := OpenMarketDataLog(filepath.Join(dir, "marketdata.dat"))
+	if err != nil {
+		t.Fatalf("OpenMarketDataLog: %v", err)
+	}
+	m.AttachMarketDataLog(log)
+
+	symbol := matching.NewSymbol(1, "AAPL")
+	mm.AddSymbol(symbol)
+	mm.AddOrderBook(symbol)
+	if err := m.AddOrder(newLimitOrder(1, matching.OrderSideBuy, 10000, 100)); err != nil {
+		t.Fatalf("AddOrder: %v", err)
+	}
+
+	got, err := m.MarketDataFrom(1, 0)
+	if err != nil {
+		t.Fatalf("MarketDataFrom: %v", err)
+	}
+	if len(got) != 1 || got[0].Type != MarketDataLevelAdd || got[0].Level.Price != 10000 {
+		t.Errorf("events: got %+v", got)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
@@ -1,6 +1,8 @@
 package persistence
 
 import (
+	"encoding/binary"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -48,6 +50,12 @@ func TestEncodeDecodeNewOrder(t *testing.T) {
 		Timestamp: 1234567890,
 		Order:     newLimitOrder(42, matching.OrderSideBuy, 10000, 100),
 	}
+	orig.Order.ParticipantID = 0x4753434F
+	orig.Order.DisplayLowQuantity = 5
+	orig.Order.DisplayHighQuantity = 15
+	orig.Order.MinQuantity = 20
+	orig.Order.AccountID = 3
+	orig.Order.Quote = true
 
 	data, err := encodeEvent(orig)
 	if err != nil {
@@ -72,6 +80,122 @@ func TestEncodeDecodeNewOrder(t *testing.T) {
 	}
 }
 
+func TestDecodeNewOrder_LegacyRecord(t *testing.T) {
+	orig := MatchingEvent{
+		Type:      EventNewOrder,
+		Timestamp: 1234567890,
+		Order:     newLimitOrder(42, matching.OrderSideBuy, 10000, 100),
+	}
+	orig.Order.ParticipantID = 7
+	orig.Order.DisplayLowQuantity = 5
+	orig.Order.DisplayHighQuantity = 15
+	orig.Order.MinQuantity = 20
+	orig.Order.AccountID = 3
+	orig.Order.Quote = true
+
+	data, err := encodeEvent(orig)
+	if err != nil {
+		t.Fatalf("encodeEvent: %v", err)
+	}
+
+	// Rewrite the record as it was before ParticipantID was added.
+	legacy := data[:4+9+orderWireSizeV1]
+	binary.BigEndian.PutUint32(legacy[0:4], uint32(9+orderWireSizeV1))
+
+	got, err := decodeEvent(newByteReader(legacy))
+	if err != nil {
+		t.Fatalf("decodeEvent: %v", err)
+	}
+	want := orig.Order
+	want.ParticipantID = 0
+	want.DisplayLowQuantity = 0
+	want.DisplayHighQuantity = 0
+	want.MinQuantity = 0
+	want.AccountID = 0
+	want.Quote = false
+	if got.Order != want {
+		t.Errorf("Order: got %+v, want %+v", got.Order, want)
+	}
+
+	// Records written before the display range was added keep the participant.
+	legacy = data[:4+9+orderWireSizeV2]
+	binary.BigEndian.PutUint32(legacy[0:4], uint32(9+orderWireSizeV2))
+	got, err = decodeEvent(newByteReader(legacy))
+	if err != nil {
+		t.Fatalf("decodeEvent: %v", err)
+	}
+	want.ParticipantID = 7
+	if got.Order != want {
+		t.Errorf("Order: got %+v, want %+v", got.Order, want)
+	}
+
+	// Records written before MinQuantity was added keep the display range.
+	legacy = data[:4+9+orderWireSizeV3]
+	binary.BigEndian.PutUint32(legacy[0:4], uint32(9+orderWireSizeV3))
+	got, err = decodeEvent(newByteReader(legacy))
+	if err != nil {
+		t.Fatalf("decodeEvent: %v", err)
+	}
+	want.DisplayLowQuantity = 5
+	want.DisplayHighQuantity = 15
+	if got.Order != want {
+		t.Errorf("Order: got %+v, want %+v", got.Order, want)
+	}
+
+	// Records written before AccountID was added keep the minimum quantity.
+	legacy = data[:4+9+orderWireSizeV4]
+	binary.BigEndian.PutUint32(legacy[0:4], uint32(9+orderWireSizeV4))
+	got, err = decodeEvent(newByteReader(legacy))
+	if err != nil {
+		t.Fatalf("decodeEvent: %v", err)
+	}
+	want.MinQuantity = 20
+	if got.Order != want {
+		t.Errorf("Order: got %+v, want %+v", got.Order, want)
+	}
+
+	// Records written before the flags were added keep the account.
+	legacy = data[:4+9+orderWireSizeV5]
+	binary.BigEndian.PutUint32(legacy[0:4], uint32(9+orderWireSizeV5))
+	got, err = decodeEvent(newByteReader(legacy))
+	if err != nil {
+		t.Fatalf("decodeEvent: %v", err)
+	}
+	want.AccountID = orig.Order.AccountID
+	if got.Order != want {
+		t.Errorf("Order: got %+v, want %+v", got.Order, want)
+	}
+}
+
+func TestDecodeRejectOrder_LegacyRecord(t *testing.T) {
+	orig := MatchingEvent{
+		Type:      EventRejectOrder,
+		Timestamp: 1234567890,
+		Order:     newLimitOrder(42, matching.OrderSideBuy, 10000, 100),
+		Reject:    matching.ErrorOrderDuplicate,
+	}
+	orig.Order.AccountID = 3
+
+	data, err := encodeEvent(orig)
+	if err != nil {
+		t.Fatalf("encodeEvent: %v", err)
+	}
+
+	// Rewrite the record as it was before AccountID was added.
+	legacy := append(data[:4+9+orderWireSizeV4:4+9+orderWireSizeV4], byte(orig.Reject))
+	binary.BigEndian.PutUint32(legacy[0:4], uint32(9+orderWireSizeV4+1))
+
+	got, err := decodeEvent(newByteReader(legacy))
+	if err != nil {
+		t.Fatalf("decodeEvent: %v", err)
+	}
+	want := orig.Order
+	want.AccountID = 0
+	if got.Order != want || got.Reject != orig.Reject {
+		t.Errorf("got %+v, reject %d, want %+v, reject %d", got.Order, got.Reject, want, orig.Reject)
+	}
+}
+
 func TestEncodeDecodeCancelOrder(t *testing.T) {
 	orig := MatchingEvent{
 		Type:      EventCancelOrder,
@@ -139,6 +263,90 @@ func TestJournal_AppendAndReadAll(t *testing.T) {
 	}
 }
 
+func TestJournal_DurabilitySync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.journal")
+
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	defer j.Close()
+	j.SetDurability(DurabilitySync)
+
+	// The event is on disk before Append returns, without waiting for the
+	// flush interval.
+	if err := j.Append(MatchingEvent{Type: EventCancelOrder, Timestamp: 1, OrderID: 1}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	got, err := ReadAll(path)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if len(got) != 1 {
+		t.Errorf("ReadAll: got %d events, want 1", len(got))
+	}
+
+	for _, name := range []string{"group", "sync"} {
+		d, err := ParseDurability(name)
+		if err != nil || d.String() != name {
+			t.Errorf("ParseDurability(%q): got %v, %v", name, d, err)
+		}
+	}
+	if _, err := ParseDurability("always"); err == nil {
+		t.Error("ParseDurability(\"always\"): got nil error")
+	}
+}
+
+func TestJournalReader_TruncatedTail(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.journal")
+
+	var data []byte
+	for i := int64(1); i <= 3; i++ {
+		record, err := encodeEvent(MatchingEvent{Type: EventCancelOrder, Timestamp: i, OrderID: uint64(i)})
+		if err != nil {
+			t.Fatalf("encodeEvent: %v", err)
+		}
+		data = append(data, record...)
+	}
+	// Cut the last record in the middle of its payload.
+	if err := os.WriteFile(path, data[:len(data)-5], 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	jr, err := OpenJournalReader(path)
+	if err != nil {
+		t.Fatalf("OpenJournalReader: %v", err)
+	}
+	defer jr.Close()
+
+	for want := uint64(1); want <= 2; want++ {
+		e, err := jr.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if e.OrderID != want {
+			t.Errorf("OrderID: got %d, want %d", e.OrderID, want)
+		}
+	}
+	if _, err := jr.Next(); err != io.EOF {
+		t.Errorf("Next at truncated tail: got %v, want io.EOF", err)
+	}
+}
+
+func TestJournalReader_Missing(t *testing.T) {
+	jr, err := OpenJournalReader(filepath.Join(t.TempDir(), "missing.journal"))
+	if err != nil {
+		t.Fatalf("OpenJournalReader: %v", err)
+	}
+	if _, err := jr.Next(); err != io.EOF {
+		t.Errorf("Next: got %v, want io.EOF", err)
+	}
+	if err := jr.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
 func TestJournal_ReadAllMissing(t *testing.T) {
 	// ReadAll on a non-existent file should return nil, nil.
 	events, err := ReadAll("/tmp/this-file-should-not-exist-go-trader-test.journal")
@@ -198,6 +406,7 @@ func TestSnapshot_SaveAndLoadLatest(t *testing.T) {
 	}
 	snap.Orders[0].ExecutedQuantity = 30
 	snap.Orders[0].LeavesQuantity = 70
+	snap.Orders[1].ParticipantID = 42
 
 	if err := sp.Save(snap); err != nil {
 		t.Fatalf("Save: %v", err)
@@ -224,6 +433,9 @@ func TestSnapshot_SaveAndLoadLatest(t *testing.T) {
 	if got.Orders[0].LeavesQuantity != 70 {
 		t.Errorf("LeavesQuantity: got %d, want 70", got.Orders[0].LeavesQuantity)
 	}
+	if got.Orders[1].ParticipantID != 42 {
+		t.Errorf("ParticipantID: got %d, want 42", got.Orders[1].ParticipantID)
+	}
 }
 
 func TestSnapshotter_LoadLatest_NoSnapshots(t *testing.T) {
@@ -396,6 +608,89 @@ func TestRecover_SnapshotAndJournal(t *testing.T) {
 	}
 }
 
+func TestRecoverWithOptions_Targets(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "test.journal")
+	snapshotDir := filepath.Join(dir, "snapshots")
+
+	// Snapshots at ts=1000 (order 1) and ts=3500 (orders 1 to 3).
+	sp, err := NewSnapshotter(snapshotDir)
+	if err != nil {
+		t.Fatalf("NewSnapshotter: %v", err)
+	}
+	symbols := []matching.Symbol{{ID: 1, Name: "AAPL"}}
+	o1 := newLimitOrder(1, matching.OrderSideBuy, 10000, 100)
+	o2 := newLimitOrder(2, matching.OrderSideSell, 11000, 20)
+	o3 := newLimitOrder(3, matching.OrderSideSell, 12000, 30)
+	for _, snap := range []Snapshot{
+		{Timestamp: 1000, Symbols: symbols, Orders: []matching.Order{o1}},
+		{Timestamp: 3500, Symbols: symbols, Orders: []matching.Order{o1, o2, o3}},
+	} {
+		if err := sp.Save(snap); err != nil {
+			t.Fatalf("Save snapshot: %v", err)
+		}
+	}
+
+	j, err := OpenJournal(journalPath)
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	for _, e := range []MatchingEvent{
+		{Type: EventNewOrder, Timestamp: 500, Order: o1},
+		{Type: EventNewOrder, Timestamp: 2000, Order: o2},
+		{Type: EventNewOrder, Timestamp: 3000, Order: o3},
+		{Type: EventCancelOrder, Timestamp: 4000, OrderID: 2},
+	} {
+		_ = j.Append(e)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     RecoverOptions
+		orders   []uint64
+		snapshot int64
+		seq      int
+		stopped  bool
+	}{
+		{"time", RecoverOptions{TargetTime: time.Unix(0, 2500)}, []uint64{1, 2}, 1000, 2, true},
+		{"time of an event", RecoverOptions{TargetTime: time.Unix(0, 3000)}, []uint64{1, 2, 3}, 1000, 3, true},
+		{"sequence", RecoverOptions{TargetSeq: 3}, []uint64{1, 2, 3}, 1000, 3, true},
+		{"first target", RecoverOptions{TargetTime: time.Unix(0, 2500), TargetSeq: 4}, []uint64{1, 2}, 1000, 2, true},
+		{"past the journal", RecoverOptions{TargetSeq: 10}, []uint64{1, 3}, 3500, 4, false},
+		{"before the snapshots", RecoverOptions{TargetTime: time.Unix(0, 600)}, []uint64{1}, 0, 1, true},
+	}
+	for _, tt := range tests {
+		mm := newManager(t)
+		stats, err := RecoverWithOptions(mm, journalPath, snapshotDir, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: RecoverWithOptions: %v", tt.name, err)
+		}
+		var snapshot int64
+		if !stats.Snapshot.IsZero() {
+			snapshot = stats.Snapshot.UnixNano()
+		}
+		if snapshot != tt.snapshot || stats.Sequence != tt.seq || stats.Stopped != tt.stopped {
+			t.Errorf("%s: got snapshot %d, sequence %d, stopped %v, want %d, %d, %v",
+				tt.name, snapshot, stats.Sequence, stats.Stopped, tt.snapshot, tt.seq, tt.stopped)
+		}
+		if len(mm.Orders()) != len(tt.orders) {
+			t.Errorf("%s: got %d orders, want %v", tt.name, len(mm.Orders()), tt.orders)
+		}
+		for _, id := range tt.orders {
+			if mm.GetOrder(id) == nil {
+				t.Errorf("%s: order %d should exist", tt.name, id)
+			}
+		}
+	}
+
+	if _, err := RecoverWithOptions(newManager(t), journalPath, snapshotDir, RecoverOptions{TargetSeq: -1}); err == nil {
+		t.Error("expected an error for a negative target sequence")
+	}
+}
+
 // ─── manager ─────────────────────────────────────────────────────────────────
 
 func TestManager_AddAndCancel(t *testing.T) {
@@ -424,6 +719,154 @@ func TestManager_AddAndCancel(t *testing.T) {
 	}
 }
 
+func TestManager_ExpireOrders(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "test.journal")
+	snapshotDir := filepath.Join(dir, "snapshots")
+	now := time.Unix(1700000000, 0)
+	mm := newManager(t)
+	mm.SetClock(func() time.Time { return now })
+	if code := mm.UpdateSymbolConfig(1, matching.SymbolConfig{QuoteTTL: time.Second}); code != matching.ErrorOK {
+		t.Fatalf("UpdateSymbolConfig: %s", code)
+	}
+
+	mgr, err := NewManager(mm, journalPath, snapshotDir)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	quote := newLimitOrder(1, matching.OrderSideBuy, 9000, 10)
+	quote.Quote = true
+	for _, o := range []matching.Order{quote, newLimitOrder(2, matching.OrderSideBuy, 9000, 10)} {
+		if err := mgr.AddOrder(o); err != nil {
+			t.Fatalf("AddOrder: %v", err)
+		}
+	}
+	now = now.Add(time.Second)
+	ids, err := mgr.ExpireOrders()
+	if err != nil || len(ids) != 1 || ids[0] != 1 {
+		t.Fatalf("ExpireOrders: got %v, %v, want [1]", ids, err)
+	}
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The expiry is journalled, so the quote stays deleted after a restart.
+	mm = newManager(t)
+	if err := Recover(mm, journalPath, snapshotDir); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if mm.GetOrder(1) != nil || mm.GetOrder(2) == nil {
+		t.Error("order 2 should be recovered, the quote should stay expired")
+	}
+}
+
+func TestNewManagerWithRecovery(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "test.journal")
+	snapshotDir := filepath.Join(dir, "snapshots")
+
+	mgr, err := NewManagerWithRecovery(newManager(t), journalPath, snapshotDir)
+	if err != nil {
+		t.Fatalf("NewManagerWithRecovery on empty dir: %v", err)
+	}
+	for i := uint64(1); i <= 3; i++ {
+		if err := mgr.AddOrder(newLimitOrder(i, matching.OrderSideBuy, 9000+i, 10)); err != nil {
+			t.Fatalf("AddOrder: %v", err)
+		}
+	}
+	if err := mgr.CancelOrder(2); err != nil {
+		t.Fatalf("CancelOrder: %v", err)
+	}
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The restarted manager starts from the previous state and keeps
+	// appending to the same journal
+	mm := newManager(t)
+	mgr, err = NewManagerWithRecovery(mm, journalPath, snapshotDir)
+	if err != nil {
+		t.Fatalf("NewManagerWithRecovery: %v", err)
+	}
+	if mm.GetOrder(1) == nil || mm.GetOrder(2) != nil || mm.GetOrder(3) == nil {
+		t.Error("orders 1 and 3 should be recovered, 2 should stay cancelled")
+	}
+	if err := mgr.AddOrder(newLimitOrder(4, matching.OrderSideBuy, 9004, 10)); err != nil {
+		t.Fatalf("AddOrder: %v", err)
+	}
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	mm = newManager(t)
+	if err := Recover(mm, journalPath, snapshotDir); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if got := len(mm.Orders()); got != 3 {
+		t.Errorf("orders after second restart: got %d, want 3", got)
+	}
+
+	// Recovering into an engine that already has orders would mix states
+	if _, err := NewManagerWithRecovery(mm, journalPath, snapshotDir); !errors.Is(err, ErrEngineNotEmpty) {
+		t.Errorf("got %v, want ErrEngineNotEmpty", err)
+	}
+}
+
+func TestManager_NextOrderID(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "test.journal")
+	snapshotDir := filepath.Join(dir, "snapshots")
+	restart := func() *Manager {
+		mm := newManager(t)
+		mm.SetOrderIDAllocator(matching.NewPartitionedIDAllocator(3, 8))
+		mgr, err := NewManagerWithRecovery(mm, journalPath, snapshotDir)
+		if err != nil {
+			t.Fatalf("NewManagerWithRecovery: %v", err)
+		}
+		return mgr
+	}
+
+	mgr := restart()
+	first := mgr.NextOrderID()
+	if err := mgr.AddOrder(newLimitOrder(first, matching.OrderSideBuy, 9000, 10)); err != nil {
+		t.Fatalf("AddOrder: %v", err)
+	}
+	// The ID of an order that was never entered is only known from the
+	// snapshot
+	unused := mgr.NextOrderID()
+	if first>>56 != 3 || unused != first+1 {
+		t.Errorf("allocated %#x and %#x, want consecutive IDs of partition 3", first, unused)
+	}
+	errCh := make(chan error, 1)
+	mgr.TakeSnapshot(errCh)
+	if err := <-errCh; err != nil {
+		t.Fatalf("TakeSnapshot: %v", err)
+	}
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	mgr = restart()
+	id := mgr.NextOrderID()
+	if id != unused+1 {
+		t.Errorf("NextOrderID after restart: got %#x, want %#x", id, unused+1)
+	}
+	// The ID of an order entered after the snapshot is only known from the
+	// journal
+	if err := mgr.AddOrder(newLimitOrder(id+5, matching.OrderSideBuy, 9001, 10)); err != nil {
+		t.Fatalf("AddOrder: %v", err)
+	}
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	mgr = restart()
+	defer mgr.Close()
+	if got := mgr.NextOrderID(); got != id+6 {
+		t.Errorf("NextOrderID after second restart: got %#x, want %#x", got, id+6)
+	}
+}
+
 func TestManager_TakeSnapshot(t *testing.T) {
 	dir := t.TempDir()
 	mm := newManager(t)
@@ -470,3 +913,307 @@ func (b *byteReader) Read(p []byte) (int, error) {
 	b.pos += n
 	return n, nil
 }
+
+func TestManager_ResetSession(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "test.journal")
+	snapshotDir := filepath.Join(dir, "snapshots")
+
+	mgr, err := NewManager(newManager(t), journalPath, snapshotDir)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	day := newLimitOrder(1, matching.OrderSideBuy, 9900, 10)
+	day.TimeInForce = matching.OrderTimeInForceDay
+	for _, o := range []matching.Order{
+		day,
+		newLimitOrder(2, matching.OrderSideBuy, 9900, 10),
+		newLimitOrder(3, matching.OrderSideBuy, 9800, 10),
+	} {
+		if err := mgr.AddOrder(o); err != nil {
+			t.Fatalf("AddOrder: %v", err)
+		}
+	}
+
+	archive, err := mgr.ResetSession()
+	if err != nil {
+		t.Fatalf("ResetSession: %v", err)
+	}
+	if events, _ := ReadAll(archive); len(events) != 4 || events[3].Type != EventResetSession {
+		t.Errorf("archived segment: got %d events, want 3 orders and a reset", len(events))
+	}
+	if events, _ := ReadAll(journalPath); len(events) != 0 {
+		t.Errorf("new segment: got %d events, want 0", len(events))
+	}
+
+	if err := mgr.AddOrder(newLimitOrder(4, matching.OrderSideSell, 10000, 5)); err != nil {
+		t.Fatalf("AddOrder: %v", err)
+	}
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The start-of-day snapshot plus the new segment restore the book.
+	mm := newManager(t)
+	if err := Recover(mm, journalPath, snapshotDir); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if mm.GetOrder(1) != nil {
+		t.Error("day order should not be recovered")
+	}
+	for _, id := range []uint64{2, 3, 4} {
+		if mm.GetOrder(id) == nil {
+			t.Errorf("order %d should exist after recovery", id)
+		}
+	}
+}
+
+func TestManager_RotateJournal(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "test.journal")
+	snapshotDir := filepath.Join(dir, "snapshots")
+
+	mgr, err := NewManager(newManager(t), journalPath, snapshotDir)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	for _, o := range []matching.Order{
+		newLimitOrder(1, matching.OrderSideBuy, 9900, 10),
+		newLimitOrder(2, matching.OrderSideSell, 10100, 10),
+	} {
+		if err := mgr.AddOrder(o); err != nil {
+			t.Fatalf("AddOrder: %v", err)
+		}
+	}
+
+	archive, err := mgr.RotateJournal()
+	if err != nil {
+		t.Fatalf("RotateJournal: %v", err)
+	}
+	if events, _ := ReadAll(archive); len(events) != 2 {
+		t.Errorf("archived segment: got %d events, want 2", len(events))
+	}
+	if events, _ := ReadAll(journalPath); len(events) != 0 {
+		t.Errorf("new segment: got %d events, want 0", len(events))
+	}
+
+	if err := mgr.CancelOrder(2); err != nil {
+		t.Fatalf("CancelOrder: %v", err)
+	}
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The rotation snapshot plus the new segment restore the book.
+	mm := newManager(t)
+	if err := Recover(mm, journalPath, snapshotDir); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if mm.GetOrder(1) == nil || mm.GetOrder(2) != nil {
+		t.Errorf("got orders 1 %v and 2 %v, want only order 1", mm.GetOrder(1) != nil, mm.GetOrder(2) != nil)
+	}
+}
+
+func TestManager_UpdateSaveSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "test.journal")
+	snapshotDir := filepath.Join(dir, "snapshots")
+
+	mgr, err := NewManager(newManager(t), journalPath, snapshotDir)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	err = mgr.Update(func(mm *matching.MarketManager) error {
+		symbol := matching.NewSymbol(2, "MSFT")
+		mm.AddSymbol(symbol)
+		return mm.AddOrderBook(symbol).Error()
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := mgr.SaveSnapshot(); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+	if err := mgr.AddOrder(newLimitOrder(1, matching.OrderSideBuy, 9900, 10)); err != nil {
+		t.Fatalf("AddOrder: %v", err)
+	}
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The snapshot restores the symbol added outside the journal.
+	mm := matching.NewMarketManager()
+	if err := Recover(mm, journalPath, snapshotDir); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if mm.GetOrderBook(2) == nil || mm.GetOrder(1) == nil {
+		t.Errorf("got book 2 %v and order 1 %v, want both", mm.GetOrderBook(2) != nil, mm.GetOrder(1) != nil)
+	}
+}
+
+func TestManager_JournalRejects(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "test.journal")
+	snapshotDir := filepath.Join(dir, "snapshots")
+
+	mgr, err := NewManagerWithOptions(newManager(t), journalPath, snapshotDir, ManagerOptions{JournalRejects: true})
+	if err != nil {
+		t.Fatalf("NewManagerWithOptions: %v", err)
+	}
+	if err := mgr.AddOrder(newLimitOrder(1, matching.OrderSideBuy, 9900, 10)); err != nil {
+		t.Fatalf("AddOrder: %v", err)
+	}
+	if err := mgr.AddOrder(newLimitOrder(1, matching.OrderSideSell, 10100, 5)); err == nil {
+		t.Fatal("AddOrder of a duplicate: got nil error")
+	}
+	if err := mgr.CancelOrder(42); err == nil {
+		t.Fatal("CancelOrder of an unknown order: got nil error")
+	}
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	events, err := ReadAll(journalPath)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if len(events) != 5 {
+		t.Fatalf("got %d events, want 2 orders, a cancel and 2 rejects", len(events))
+	}
+	if e := events[2]; e.Type != EventRejectOrder || e.Reject != matching.ErrorOrderDuplicate || e.Order.Side != matching.OrderSideSell {
+		t.Errorf("got %+v, want the duplicate sell order rejected", e)
+	}
+	if e := events[4]; e.Type != EventRejectOrder || e.Reject != matching.ErrorOrderNotFound || e.Order.ID != 42 {
+		t.Errorf("got %+v, want the cancellation of order 42 rejected", e)
+	}
+
+	// Rejects change nothing on recovery.
+	mm := newManager(t)
+	if err := Recover(mm, journalPath, snapshotDir); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if o := mm.GetOrder(1); o == nil || o.Side != matching.OrderSideBuy || len(mm.Orders()) != 1 {
+		t.Errorf("got %d orders, want the buy order 1 only", len(mm.Orders()))
+	}
+}
+
+func TestRecover_ReplaysResetSession(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "test.journal")
+
+	j, err := OpenJournal(journalPath)
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	day := newLimitOrder(1, matching.OrderSideBuy, 9900, 10)
+	day.TimeInForce = matching.OrderTimeInForceDay
+	_ = j.Append(MatchingEvent{Type: EventNewOrder, Timestamp: 1, Order: day})
+	_ = j.Append(MatchingEvent{Type: EventResetSession, Timestamp: 2})
+	if err := j.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	mm := newManager(t)
+	if err := Recover(mm, journalPath, filepath.Join(dir, "snapshots")); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if mm.GetOrder(1) != nil {
+		t.Error("day order should be cancelled by the replayed reset")
+	}
+}
+
+func TestManager_Statistics(t *testing.T) {
+	dir := t.TempDir()
+	mgr, err := NewManager(newManager(t), filepath.Join(dir, "test.journal"), filepath.Join(dir, "snapshots"))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+
+	mgr.EnableLatencyTracking()
+	for i := uint64(1); i <= 3; i++ {
+		if err := mgr.AddOrder(newLimitOrder(i, matching.OrderSideBuy, 10000-i, 10)); err != nil {
+			t.Fatalf("AddOrder: %v", err)
+		}
+	}
+	if err := mgr.journal.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	stats := mgr.Statistics()
+	if stats.Engine.AddOrderLatency.Count != 3 {
+		t.Errorf("engine samples: got %d, want 3", stats.Engine.AddOrderLatency.Count)
+	}
+	if stats.JournalSync.Count != 3 {
+		t.Errorf("journal sync samples: got %d, want 3", stats.JournalSync.Count)
+	}
+	if stats.JournalSync.Max <= 0 {
+		t.Errorf("journal sync max: got %v, want > 0", stats.JournalSync.Max)
+	}
+}
+
+func TestManager_Stats(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "test.journal")
+	snapshotDir := filepath.Join(dir, "snapshots")
+	mgr, err := NewManagerWithRecovery(newManager(t), journalPath, snapshotDir)
+	if err != nil {
+		t.Fatalf("NewManagerWithRecovery: %v", err)
+	}
+
+	stats := mgr.Stats()
+	if stats.Journal.Size != 0 || !stats.LastSnapshot.IsZero() || stats.SinceSnapshot != 0 {
+		t.Errorf("empty manager: got %+v, want no journal and no snapshot", stats)
+	}
+	if stats.Recovery.EventsReplayed != 0 || !stats.Recovery.Snapshot.IsZero() {
+		t.Errorf("empty recovery: got %+v", stats.Recovery)
+	}
+
+	for i := uint64(1); i <= 2; i++ {
+		if err := mgr.AddOrder(newLimitOrder(i, matching.OrderSideBuy, 9000+i, 10)); err != nil {
+			t.Fatalf("AddOrder: %v", err)
+		}
+	}
+	if size := mgr.Stats().Journal.Size; size <= 0 {
+		t.Errorf("journal size: got %d, want > 0", size)
+	}
+	if err := mgr.journal.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	stats = mgr.Stats()
+	if stats.Journal.UnsyncedBytes != 0 || stats.Journal.LastSync.IsZero() {
+		t.Errorf("after flush: got %+v, want everything synced", stats.Journal)
+	}
+
+	errCh := make(chan error, 1)
+	mgr.TakeSnapshot(errCh)
+	if err := <-errCh; err != nil {
+		t.Fatalf("TakeSnapshot: %v", err)
+	}
+	if stats := mgr.Stats(); stats.LastSnapshot.IsZero() || stats.SinceSnapshot < 0 {
+		t.Errorf("after snapshot: got %v (%v ago), want a snapshot time", stats.LastSnapshot, stats.SinceSnapshot)
+	}
+	time.Sleep(time.Millisecond)
+	if err := mgr.AddOrder(newLimitOrder(3, matching.OrderSideBuy, 9003, 10)); err != nil {
+		t.Fatalf("AddOrder: %v", err)
+	}
+	size := mgr.Stats().Journal.Size
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	mgr, err = NewManagerWithRecovery(newManager(t), journalPath, snapshotDir)
+	if err != nil {
+		t.Fatalf("NewManagerWithRecovery: %v", err)
+	}
+	defer mgr.Close()
+	stats = mgr.Stats()
+	if stats.Journal.Size != size {
+		t.Errorf("reopened journal size: got %d, want %d", stats.Journal.Size, size)
+	}
+	r := stats.Recovery
+	if r.Snapshot.IsZero() || r.SnapshotOrders != 2 || r.EventsSkipped != 2 || r.EventsReplayed != 1 {
+		t.Errorf("recovery: got %+v, want 2 snapshot orders, 2 skipped and 1 replayed event", r)
+	}
+}
@@ -0,0 +1,177 @@
+// Package persistencetest injects the storage failures of a crash or of a
+// faulty disk into the files of a persistence journal and snapshot directory,
+// so that recovery can be tested against them.  Journals can be torn at any
+// offset or at record boundaries, bytes of any file flipped, and the temporary
+// files of interrupted snapshot writes left behind or cleaned up.
+//
+// # Crash-safety matrix
+//
+// The outcome of recovering from each failure point, enforced by the crash
+// tests of the persistence package:
+//
+//	Failure point                              Fault                         Outcome
+//	-----------------------------------------  ----------------------------  -----------
+//	Crash while appending an event             Truncate inside a record      Recovered
+//	Crash between two appends                  Truncate at a record offset   Recovered
+//	Crash while writing a record length        Truncate inside the length    Recovered
+//	Crash while writing a snapshot             WriteTempFile                 Recovered
+//	Crash between a snapshot and a rotation    snapshot, journal kept        Recovered
+//	Corrupt event type                         FlipByte in the type          Detected
+//	Corrupt snapshot                           FlipByte in a snapshot        Detected
+//	Truncated snapshot                         Truncate a snapshot           Detected
+//	Corrupt record length                      FlipByte in the length        Undetected
+//	Corrupt event payload                      FlipByte in the payload       Undetected
+//
+// Recovered means that recovery succeeds with every event appended before
+// the failure point, minus the torn event.  Detected means that recovery
+// fails with an error, so the operator restores an older snapshot or archived
+// journal segment.  Undetected means that recovery succeeds with a different
+// state: journal records carry no checksum, so a corrupt payload replays a
+// different event and a corrupt length running past the end of the journal is
+// read as a torn tail, dropping the events after it.  Snapshots are protected by the zstd frame checksum.
+package persistencetest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TempSuffix is the suffix of the temporary file a snapshot is written to
+// before it is renamed into place.
+const TempSuffix = ".tmp"
+
+// Size returns the size of the file at path.
+func Size(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// Truncate cuts the file at path to size bytes, as a crash leaves a write
+// torn at that offset.
+func Truncate(path string, size int64) error {
+	n, err := Size(path)
+	if err != nil {
+		return err
+	}
+	if size < 0 || size > n {
+		return fmt.Errorf("persistencetest: offset %d outside %s of %d bytes", size, path, n)
+	}
+	return os.Truncate(path, size)
+}
+
+// FlipByte inverts every bit of the byte at offset of the file at path, as a
+// faulty disk corrupts data.
+func FlipByte(path string, offset int64) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var b [1]byte
+	if _, err := f.ReadAt(b[:], offset); err != nil {
+		return fmt.Errorf("persistencetest: reading offset %d of %s: %w", offset, path, err)
+	}
+	b[0] ^= 0xFF
+	if _, err := f.WriteAt(b[:], offset); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// RecordOffsets returns the offset of every record of the journal at path,
+// followed by the offset past the last complete record, so that record i
+// spans offsets[i] to offsets[i+1].  Records are framed by a 4-byte
+// big-endian payload length.  A torn tail is not included.
+func RecordOffsets(path string) ([]int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	size, err := Size(path)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := []int64{0}
+	var offset int64
+	var length [4]byte
+	for {
+		if _, err := f.ReadAt(length[:], offset); err != nil {
+			if errors.Is(err, io.EOF) {
+				return offsets, nil
+			}
+			return nil, err
+		}
+		next := offset + 4 + int64(binary.BigEndian.Uint32(length[:]))
+		if next > size {
+			return offsets, nil
+		}
+		offset = next
+		offsets = append(offsets, offset)
+	}
+}
+
+// WriteTempFile writes data to the temporary file of the snapshot at path,
+// as a crash in the middle of a snapshot write leaves it behind.
+func WriteTempFile(path string, data []byte) error {
+	return os.WriteFile(path+TempSuffix, data, 0o644)
+}
+
+// TempFiles returns the paths of the temporary files in dir, sorted.
+func TempFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), TempSuffix) {
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	return paths, nil
+}
+
+// RemoveTempFiles removes the temporary files in dir, as an operator cleans
+// up after a crash, and returns their number.
+func RemoveTempFiles(dir string) (int, error) {
+	paths, err := TempFiles(dir)
+	if err != nil {
+		return 0, err
+	}
+	for i, path := range paths {
+		if err := os.Remove(path); err != nil {
+			return i, err
+		}
+	}
+	return len(paths), nil
+}
+
+// Snapshots returns the paths of the local snapshot files in dir, oldest
+// first.
+func Snapshots(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "snapshot-*.snap"))
+	if err != nil {
+		return nil, err
+	}
+	// Snapshot names hold Unix nanosecond timestamps, which sort by length
+	// first.
+	sort.Slice(paths, func(i, j int) bool {
+		if len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) < len(paths[j])
+		}
+		return paths[i] < paths[j]
+	})
+	return paths, nil
+}
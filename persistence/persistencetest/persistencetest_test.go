@@ -0,0 +1,58 @@
+package persistencetest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordOffsets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.journal")
+	// Records of 2 and 0 payload bytes, then a torn record.
+	data := []byte{0, 0, 0, 2, 'a', 'b', 0, 0, 0, 0, 0, 0, 0, 9, 'c'}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	offsets, err := RecordOffsets(path)
+	if err != nil {
+		t.Fatalf("RecordOffsets: %v", err)
+	}
+	if len(offsets) != 3 || offsets[1] != 6 || offsets[2] != 10 {
+		t.Errorf("got offsets %v, want [0 6 10]", offsets)
+	}
+
+	if err := FlipByte(path, 4); err != nil {
+		t.Fatalf("FlipByte: %v", err)
+	}
+	if got, _ := os.ReadFile(path); got[4] != 'a'^0xFF {
+		t.Errorf("got byte %#x, want %#x", got[4], 'a'^0xFF)
+	}
+	if err := FlipByte(path, int64(len(data))); err == nil {
+		t.Error("FlipByte past the end: got nil error")
+	}
+	if err := Truncate(path, int64(len(data))+1); err == nil {
+		t.Error("Truncate past the end: got nil error")
+	}
+	if err := Truncate(path, 6); err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+	if size, _ := Size(path); size != 6 {
+		t.Errorf("got size %d, want 6", size)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"snapshot-20.snap", "snapshot-100.snap", "snapshot-3.snap", "snapshot-4.snap.tmp"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	paths, err := Snapshots(dir)
+	if err != nil {
+		t.Fatalf("Snapshots: %v", err)
+	}
+	if len(paths) != 3 || filepath.Base(paths[0]) != "snapshot-3.snap" || filepath.Base(paths[2]) != "snapshot-100.snap" {
+		t.Errorf("got %v, want snapshots 3, 20 and 100", paths)
+	}
+}
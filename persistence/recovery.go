@@ -2,10 +2,48 @@ package persistence
 
 import (
 	"fmt"
+	"io"
+	"math"
+	"time"
 
 	"github.com/tienpsm/go-trader/matching"
 )
 
+// RecoveryStats describes the recovery of a MarketManager.
+type RecoveryStats struct {
+	// Snapshot is the capture time of the snapshot restored, zero if there
+	// was none.
+	Snapshot time.Time
+	// SnapshotOrders is the number of orders restored from the snapshot.
+	SnapshotOrders int
+	// EventsReplayed is the number of journal events applied after the
+	// snapshot, and EventsSkipped the number already covered by it.
+	EventsReplayed int
+	EventsSkipped  int
+	// Sequence is the 1-based journal position of the last event recovered,
+	// replayed or skipped, and Stopped is set when a recovery target stopped
+	// the replay before the end of the journal.
+	Sequence int
+	Stopped  bool
+	// Duration is the time the recovery took.
+	Duration time.Duration
+	// Audit is the audit of the recovered state with ManagerOptions.Audit,
+	// nil without.
+	Audit *AuditReport
+}
+
+// RecoverOptions selects the point in time a recovery stops at.  The zero
+// value recovers the whole journal.
+type RecoverOptions struct {
+	// TargetTime stops the replay after the last event accepted at or before
+	// it, rebuilding the books as of that time.  Zero for no time target.
+	TargetTime time.Time
+	// TargetSeq stops the replay after the event at that 1-based journal
+	// position, the sequence numbers of Replayer.  Zero for no sequence
+	// target.
+	TargetSeq int
+}
+
 // Recover restores a MarketManager to its last known state by:
 //  1. Loading the most recent snapshot from dir (if any).
 //  2. Replaying every journal event whose timestamp is strictly greater than
@@ -17,48 +55,144 @@ import (
 //
 // If neither a snapshot nor a journal exists the function is a no-op.
 func Recover(mm *matching.MarketManager, journalPath, snapshotDir string) error {
+	_, err := RecoverWithStats(mm, journalPath, snapshotDir)
+	return err
+}
+
+// RecoverWithStats is Recover, also reporting what was restored.
+func RecoverWithStats(mm *matching.MarketManager, journalPath, snapshotDir string) (RecoveryStats, error) {
+	return RecoverWithOptions(mm, journalPath, snapshotDir, RecoverOptions{})
+}
+
+// RecoverWithOptions is RecoverWithStats stopping at the target of opts, to
+// rebuild the books as of a point of the session or to bisect the journal for
+// the first event leading to a bad state.  The snapshot restored is the newest
+// one the target does not precede, so later snapshots are ignored.  With both
+// targets set, the replay stops at whichever comes first.
+//
+// The journal must still hold the events since that snapshot.  The state
+// recovered to a target is behind the journal: it is meant for inspection,
+// not for a Manager appending to the same journal.
+func RecoverWithOptions(mm *matching.MarketManager, journalPath, snapshotDir string, opts RecoverOptions) (RecoveryStats, error) {
+	start := time.Now()
+	var stats RecoveryStats
+	if opts.TargetSeq < 0 {
+		return stats, fmt.Errorf("persistence: negative target sequence %d", opts.TargetSeq)
+	}
 	sp, err := NewSnapshotter(snapshotDir)
 	if err != nil {
-		return fmt.Errorf("persistence: opening snapshot dir: %w", err)
+		return stats, fmt.Errorf("persistence: opening snapshot dir: %w", err)
 	}
 
 	// ── 1. Load snapshot ──────────────────────────────────────────────────────
-	snap, err := sp.LoadLatest()
+	// A snapshot must not cover events past the target: one taken at the
+	// target time is fine, one taken at the time of the target event may
+	// already hold events that followed it at the same timestamp.
+	cutoff := int64(math.MaxInt64)
+	if !opts.TargetTime.IsZero() {
+		cutoff = opts.TargetTime.UnixNano() + 1
+	}
+	if opts.TargetSeq > 0 {
+		ts, ok, err := journalTimestamp(journalPath, opts.TargetSeq)
+		if err != nil {
+			return stats, fmt.Errorf("persistence: reading journal: %w", err)
+		}
+		if ok && ts < cutoff {
+			cutoff = ts
+		}
+	}
+	snap, err := sp.LoadBefore(cutoff)
 	if err != nil {
-		return fmt.Errorf("persistence: loading snapshot: %w", err)
+		return stats, fmt.Errorf("persistence: loading snapshot: %w", err)
 	}
 
 	var snapshotTS int64
 	if snap != nil {
 		if err := applySnapshot(mm, snap); err != nil {
-			return fmt.Errorf("persistence: applying snapshot: %w", err)
+			return stats, fmt.Errorf("persistence: applying snapshot: %w", err)
 		}
 		snapshotTS = snap.Timestamp
+		stats.Snapshot = time.Unix(0, snap.Timestamp)
+		stats.SnapshotOrders = len(snap.Orders)
 	}
 
 	// ── 2. Replay journal ─────────────────────────────────────────────────────
-	events, err := ReadAll(journalPath)
+	// Events are streamed so that memory use does not grow with the journal.
+	jr, err := OpenJournalReader(journalPath)
 	if err != nil {
-		return fmt.Errorf("persistence: reading journal: %w", err)
+		return stats, fmt.Errorf("persistence: reading journal: %w", err)
 	}
+	defer jr.Close()
 
-	for _, e := range events {
+	for {
+		e, err := jr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, fmt.Errorf("persistence: reading journal: %w", err)
+		}
+		if opts.reached(stats.Sequence, e) {
+			stats.Stopped = true
+			break
+		}
+		stats.Sequence++
 		// Skip events already covered by the snapshot.
 		if e.Timestamp <= snapshotTS {
+			stats.EventsSkipped++
 			continue
 		}
 		if err := applyEvent(mm, e); err != nil {
-			return fmt.Errorf("persistence: replaying event at ts=%d: %w", e.Timestamp, err)
+			return stats, fmt.Errorf("persistence: replaying event at ts=%d: %w", e.Timestamp, err)
 		}
+		stats.EventsReplayed++
 	}
 
-	return nil
+	stats.Duration = time.Since(start)
+	return stats, nil
+}
+
+// reached reports whether the replay is past the target once seq events have
+// been recovered and e is next.
+func (o RecoverOptions) reached(seq int, e MatchingEvent) bool {
+	if o.TargetSeq > 0 && seq >= o.TargetSeq {
+		return true
+	}
+	return !o.TargetTime.IsZero() && e.Timestamp > o.TargetTime.UnixNano()
+}
+
+// journalTimestamp returns the timestamp of the event at the 1-based position
+// seq of a journal, and false if the journal is shorter.
+func journalTimestamp(path string, seq int) (int64, bool, error) {
+	jr, err := OpenJournalReader(path)
+	if err != nil {
+		return 0, false, err
+	}
+	defer jr.Close()
+
+	for i := 1; ; i++ {
+		e, err := jr.Next()
+		if err == io.EOF {
+			return 0, false, nil
+		}
+		if err != nil {
+			return 0, false, err
+		}
+		if i == seq {
+			return e.Timestamp, true, nil
+		}
+	}
 }
 
 // applySnapshot restores symbols and orders from snap into mm.
 // Symbols are added first (which implicitly creates their order books), then
 // all orders are restored via RestoreOrder so that partial fills are preserved.
+// The order ID allocator of mm, which must be of the kind that took the
+// snapshot, continues from the saved state.
 func applySnapshot(mm *matching.MarketManager, snap *Snapshot) error {
+	if snap.OrderIDState != 0 {
+		mm.OrderIDAllocator().Restore(snap.OrderIDState)
+	}
 	for _, sym := range snap.Symbols {
 		if code := mm.AddSymbol(sym); code != matching.ErrorOK && code != matching.ErrorSymbolDuplicate {
 			return fmt.Errorf("AddSymbol(%d): %s", sym.ID, code)
@@ -89,6 +223,10 @@ func applyEvent(mm *matching.MarketManager, e MatchingEvent) error {
 		if code != matching.ErrorOK && code != matching.ErrorOrderNotFound {
 			return fmt.Errorf("DeleteOrder(%d): %s", e.OrderID, code)
 		}
+	case EventResetSession:
+		mm.ResetSession()
+	case EventRejectOrder:
+		// The rejected operation is replayed by the event before it.
 	default:
 		return fmt.Errorf("unknown event type %d", e.Type)
 	}
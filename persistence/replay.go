@@ -0,0 +1,192 @@
+package persistence
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// Replayer steps through journal events one at a time on top of a base
+// snapshot, so the state of the matching engine can be inspected at any point
+// of a trading session.
+//
+// Events are numbered by their 1-based position in the journal.  Sequence 0 is
+// the base snapshot with no events applied.  Executions are not journalled;
+// they are reproduced deterministically by re-running the order flow.
+//
+// Symbols are not journalled either, so without a base snapshot an order book
+// is created for every symbol the journal submits orders to.
+//
+// Replayer is not safe for concurrent use.
+type Replayer struct {
+	events []MatchingEvent
+	base   *Snapshot
+	create func() *matching.MarketManager
+
+	// symbols are created on rewind when there is no base snapshot.
+	symbols []uint32
+
+	mm  *matching.MarketManager
+	pos int
+}
+
+// NewReplayer creates a Replayer over events starting from base, which may be
+// nil for an empty engine.
+//
+// create is called to build a fresh MarketManager every time the replay is
+// rewound; it lets the caller attach a handler or change the price rule.  If
+// create is nil, a manager with automatic matching enabled is used.
+func NewReplayer(events []MatchingEvent, base *Snapshot, create func() *matching.MarketManager) (*Replayer, error) {
+	if create == nil {
+		create = func() *matching.MarketManager {
+			mm := matching.NewMarketManager()
+			mm.EnableMatching()
+			return mm
+		}
+	}
+
+	r := &Replayer{events: events, base: base, create: create}
+	if base == nil {
+		seen := make(map[uint32]bool)
+		for _, e := range events {
+			if e.Type == EventNewOrder && !seen[e.Order.SymbolID] {
+				seen[e.Order.SymbolID] = true
+				r.symbols = append(r.symbols, e.Order.SymbolID)
+			}
+		}
+	}
+	if err := r.rewind(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// OpenReplayer reads the journal at journalPath and pairs it with the newest
+// snapshot in snapshotDir taken before the first journal event.  snapshotDir
+// may be empty to replay the journal on an empty engine.
+func OpenReplayer(journalPath, snapshotDir string, create func() *matching.MarketManager) (*Replayer, error) {
+	events, err := ReadAll(journalPath)
+	if err != nil {
+		return nil, fmt.Errorf("persistence: reading journal: %w", err)
+	}
+
+	var base *Snapshot
+	if snapshotDir != "" && len(events) > 0 {
+		sp, err := NewSnapshotter(snapshotDir)
+		if err != nil {
+			return nil, fmt.Errorf("persistence: opening snapshot dir: %w", err)
+		}
+		base, err = sp.LoadBefore(events[0].Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("persistence: loading snapshot: %w", err)
+		}
+	}
+
+	return NewReplayer(events, base, create)
+}
+
+// Len returns the number of journal events.
+func (r *Replayer) Len() int {
+	return len(r.events)
+}
+
+// Position returns the sequence number of the last applied event, or 0 if no
+// event has been applied yet.
+func (r *Replayer) Position() int {
+	return r.pos
+}
+
+// Event returns the event with the given sequence number (1..Len).
+func (r *Replayer) Event(seq int) (MatchingEvent, bool) {
+	if seq < 1 || seq > len(r.events) {
+		return MatchingEvent{}, false
+	}
+	return r.events[seq-1], true
+}
+
+// Base returns the snapshot the replay starts from, or nil.
+func (r *Replayer) Base() *Snapshot {
+	return r.base
+}
+
+// MarketManager returns the engine state at the current position.
+// The returned manager is replaced when the replay is rewound.
+func (r *Replayer) MarketManager() *matching.MarketManager {
+	return r.mm
+}
+
+// Done returns true if every event has been applied.
+func (r *Replayer) Done() bool {
+	return r.pos >= len(r.events)
+}
+
+// Step applies the next event and returns it.
+// It returns false when the end of the journal has been reached.  An event
+// that fails to apply still advances the position so stepping can continue.
+func (r *Replayer) Step() (MatchingEvent, bool, error) {
+	if r.Done() {
+		return MatchingEvent{}, false, nil
+	}
+
+	e := r.events[r.pos]
+	r.pos++
+	// Events already reflected in the base snapshot are skipped, as in Recover.
+	if r.base == nil || e.Timestamp > r.base.Timestamp {
+		if err := applyEvent(r.mm, e); err != nil {
+			return e, true, fmt.Errorf("persistence: replaying event %d: %w", r.pos, err)
+		}
+	}
+	return e, true, nil
+}
+
+// Seek moves the replay to the given sequence number, so that events 1..seq
+// are applied.  Seeking backwards rebuilds the engine from the base snapshot.
+func (r *Replayer) Seek(seq int) error {
+	if seq < 0 || seq > len(r.events) {
+		return fmt.Errorf("persistence: sequence %d out of range 0..%d", seq, len(r.events))
+	}
+	if seq < r.pos {
+		if err := r.rewind(); err != nil {
+			return err
+		}
+	}
+	for r.pos < seq {
+		if _, _, err := r.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SeekTime moves the replay to the last event with a timestamp at or before
+// ts (Unix nanoseconds).
+func (r *Replayer) SeekTime(ts int64) error {
+	seq := 0
+	for seq < len(r.events) && r.events[seq].Timestamp <= ts {
+		seq++
+	}
+	return r.Seek(seq)
+}
+
+// rewind rebuilds the engine from the base snapshot.
+func (r *Replayer) rewind() error {
+	mm := r.create()
+	for _, id := range r.symbols {
+		symbol := matching.NewSymbol(id, strconv.FormatUint(uint64(id), 10))
+		if code := mm.AddSymbol(symbol); code != matching.ErrorOK && code != matching.ErrorSymbolDuplicate {
+			return fmt.Errorf("persistence: AddSymbol(%d): %s", id, code)
+		}
+		if code := mm.AddOrderBook(symbol); code != matching.ErrorOK && code != matching.ErrorOrderBookDuplicate {
+			return fmt.Errorf("persistence: AddOrderBook(%d): %s", id, code)
+		}
+	}
+	if r.base != nil {
+		if err := applySnapshot(mm, r.base); err != nil {
+			return fmt.Errorf("persistence: applying snapshot: %w", err)
+		}
+	}
+	r.mm = mm
+	r.pos = 0
+	return nil
+}
@@ -0,0 +1,134 @@
+package persistence
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// writeJournal writes events to a new journal file and returns its path.
+func writeJournal(t *testing.T, dir string, events []MatchingEvent) string {
+	t.Helper()
+	path := filepath.Join(dir, "test.journal")
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	for _, e := range events {
+		if err := j.Append(e); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return path
+}
+
+func TestReplayer_StepAndSeek(t *testing.T) {
+	dir := t.TempDir()
+	path := writeJournal(t, dir, []MatchingEvent{
+		{Type: EventNewOrder, Timestamp: 10, Order: newLimitOrder(1, matching.OrderSideSell, 10000, 100)},
+		{Type: EventNewOrder, Timestamp: 20, Order: newLimitOrder(2, matching.OrderSideBuy, 10000, 40)},
+		{Type: EventNewOrder, Timestamp: 30, Order: newLimitOrder(3, matching.OrderSideBuy, 10000, 60)},
+		{Type: EventCancelOrder, Timestamp: 40, OrderID: 3},
+	})
+
+	// No snapshot: the order book is created from the journal.
+	r, err := OpenReplayer(path, "", nil)
+	if err != nil {
+		t.Fatalf("OpenReplayer: %v", err)
+	}
+	if r.Len() != 4 || r.Position() != 0 {
+		t.Fatalf("len/position: got %d/%d, want 4/0", r.Len(), r.Position())
+	}
+	if r.MarketManager().GetOrderBook(1) == nil {
+		t.Fatal("order book 1 should have been created")
+	}
+
+	e, ok, err := r.Step()
+	if err != nil || !ok || e.Order.ID != 1 {
+		t.Fatalf("Step: got %v %v %v, want order 1", e.Order.ID, ok, err)
+	}
+
+	if err := r.Seek(2); err != nil {
+		t.Fatalf("Seek(2): %v", err)
+	}
+	if got := r.MarketManager().GetOrder(1).LeavesQuantity; got != 60 {
+		t.Errorf("order 1 leaves at #2: got %d, want 60", got)
+	}
+
+	if err := r.Seek(4); err != nil {
+		t.Fatalf("Seek(4): %v", err)
+	}
+	if len(r.MarketManager().Orders()) != 0 {
+		t.Errorf("orders at #4: got %d, want 0", len(r.MarketManager().Orders()))
+	}
+	if _, ok, _ := r.Step(); ok || !r.Done() {
+		t.Error("Step past the end should report false")
+	}
+
+	// Seeking backwards rebuilds the book.
+	if err := r.Seek(1); err != nil {
+		t.Fatalf("Seek(1): %v", err)
+	}
+	if got := r.MarketManager().GetOrder(1).LeavesQuantity; got != 100 {
+		t.Errorf("order 1 leaves at #1: got %d, want 100", got)
+	}
+
+	if err := r.SeekTime(25); err != nil {
+		t.Fatalf("SeekTime: %v", err)
+	}
+	if r.Position() != 2 {
+		t.Errorf("position after SeekTime(25): got %d, want 2", r.Position())
+	}
+
+	if err := r.Seek(5); err == nil {
+		t.Error("Seek past the end should fail")
+	}
+}
+
+func TestReplayer_BaseSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	snapshotDir := filepath.Join(dir, "snapshots")
+	sp, err := NewSnapshotter(snapshotDir)
+	if err != nil {
+		t.Fatalf("NewSnapshotter: %v", err)
+	}
+
+	// The snapshot at 5 precedes the journal; the one at 25 is taken mid-journal.
+	for _, ts := range []int64{5, 25} {
+		snap := Snapshot{
+			Timestamp: ts,
+			Symbols:   []matching.Symbol{matching.NewSymbol(1, "AAPL")},
+			Orders:    []matching.Order{newLimitOrder(uint64(ts)+1000, matching.OrderSideBuy, 9000, 10)},
+		}
+		if err := sp.Save(snap); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	path := writeJournal(t, dir, []MatchingEvent{
+		{Type: EventNewOrder, Timestamp: 10, Order: newLimitOrder(1, matching.OrderSideSell, 10000, 100)},
+		{Type: EventNewOrder, Timestamp: 30, Order: newLimitOrder(2, matching.OrderSideBuy, 10000, 40)},
+	})
+
+	r, err := OpenReplayer(path, snapshotDir, nil)
+	if err != nil {
+		t.Fatalf("OpenReplayer: %v", err)
+	}
+	if r.Base() == nil || r.Base().Timestamp != 5 {
+		t.Fatalf("base snapshot: got %+v, want ts=5", r.Base())
+	}
+	if r.MarketManager().GetOrder(1005) == nil {
+		t.Error("order from the base snapshot should be restored")
+	}
+
+	if err := r.Seek(r.Len()); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	if got := r.MarketManager().GetOrder(1).LeavesQuantity; got != 60 {
+		t.Errorf("order 1 leaves: got %d, want 60", got)
+	}
+}
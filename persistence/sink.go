@@ -0,0 +1,490 @@
+package persistence
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// ChangeType identifies the kind of a Change.
+type ChangeType uint8
+
+const (
+	// ChangeJournal is an event appended to the journal.
+	ChangeJournal ChangeType = iota + 1
+	// ChangeTrade is a trade executed by the engine.
+	ChangeTrade
+)
+
+// String returns the string representation of a ChangeType.
+func (t ChangeType) String() string {
+	switch t {
+	case ChangeJournal:
+		return "JOURNAL"
+	case ChangeTrade:
+		return "TRADE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Change is an entry of the change stream of a ChangeFeed.  Event is set for
+// journal events and Trade for trades.
+type Change struct {
+	// Sequence is the position of the change in the stream, starting at 1.
+	// Sequences continue across restarts and are never reused.
+	Sequence uint64
+	Type     ChangeType
+	Event    MatchingEvent
+	Trade    TradeRecord
+}
+
+// SinkHandler receives the change stream of a ChangeFeed, for external
+// change-data-capture such as a Kafka topic.
+//
+// Delivery is at least once: Deliver is called from a single goroutine with
+// consecutive changes in sequence order and must only return nil once the
+// sink durably holds all of them.  An error makes the feed retry the same
+// changes, and changes not committed before a crash are delivered again after
+// a restart, so the sink sees some sequences more than once and should drop
+// those it already holds.
+type SinkHandler interface {
+	Deliver(changes []Change) error
+}
+
+// ChangeFeedOptions configures OpenChangeFeed.
+type ChangeFeedOptions struct {
+	// Trades streams the trades of the engine after the journal event that
+	// caused them, in addition to the journal events.
+	Trades bool
+	// BatchSize is the maximum number of changes per Deliver call, 0 for
+	// defaultSinkBatchSize.
+	BatchSize int
+	// RetryInterval is the time between delivery attempts after a failed
+	// Deliver, and between flushes of the outbox, 0 for
+	// defaultSinkRetryInterval.
+	RetryInterval time.Duration
+}
+
+const (
+	// defaultSinkBatchSize is the default maximum number of changes per
+	// Deliver call.
+	defaultSinkBatchSize = 512
+	// defaultSinkRetryInterval is the default time between delivery attempts
+	// after a failure.
+	defaultSinkRetryInterval = 100 * time.Millisecond
+)
+
+// ChangeFeed streams the journal events of a Manager, and optionally its
+// trades, to a SinkHandler.
+//
+// Changes are numbered and appended to an outbox file at the time they are
+// journalled; a background goroutine fsyncs the outbox and delivers the
+// changes in batches.  The sequence of the last change the sink accepted is
+// the committed sequence, saved in the checkpoint file "<path>.committed"
+// after every delivery, and the outbox is emptied once every change is
+// committed.  On open, the changes after the committed sequence are delivered
+// again, so no change is lost while the sink is down or across restarts.
+// Like the journal under group commit, changes appended in the last flush
+// interval before a crash may be lost, together with their journal events.
+//
+// Undelivered changes are also kept in memory until the sink accepts them.
+type ChangeFeed struct {
+	mu        sync.Mutex
+	path      string
+	file      *os.File
+	writer    *bufio.Writer
+	sink      SinkHandler
+	opts      ChangeFeedOptions
+	pending   []Change
+	last      uint64
+	committed uint64
+	// err is the first failed append, deliveryErr the last failed delivery.
+	err         error
+	deliveryErr error
+
+	ticker *time.Ticker
+	wake   chan struct{}
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// OpenChangeFeed opens (or creates) the outbox at path, queues the changes
+// after the committed sequence for delivery to sink and starts delivering.
+func OpenChangeFeed(path string, sink SinkHandler, opts ChangeFeedOptions) (*ChangeFeed, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultSinkBatchSize
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = defaultSinkRetryInterval
+	}
+	committed, err := readCheckpoint(path + ".committed")
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &ChangeFeed{
+		path:      path,
+		file:      f,
+		sink:      sink,
+		opts:      opts,
+		last:      committed,
+		committed: committed,
+		ticker:    time.NewTicker(opts.RetryInterval),
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	valid, err := c.load()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	// Drop a torn tail record so that new records follow complete ones.
+	if err := f.Truncate(valid); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	c.writer = bufio.NewWriterSize(f, defaultBufSize)
+
+	c.wg.Add(1)
+	go c.deliverLoop()
+	c.notify()
+	return c, nil
+}
+
+// Outbox record layout (all big-endian):
+//
+//	4 – length of the rest of the record
+//	8 – Sequence
+//	1 – Type
+//	N – the encoded journal event (encodeEvent) or trade (tradeWireSize)
+
+// maxChangeRecordSize bounds the length of outbox records, so that a corrupt
+// length fails instead of allocating it.
+const maxChangeRecordSize = 1 << 16
+
+// load queues the uncommitted changes of the outbox and returns the size of
+// its valid prefix.
+func (c *ChangeFeed) load() (int64, error) {
+	r := bufio.NewReader(c.file)
+	var valid int64
+	var header [4]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return valid, nil
+			}
+			return valid, err
+		}
+		size := binary.BigEndian.Uint32(header[:])
+		if size > maxChangeRecordSize {
+			return valid, fmt.Errorf("persistence: outbox record at offset %d: length %d", valid, size)
+		}
+		record := make([]byte, size)
+		if _, err := io.ReadFull(r, record); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return valid, nil
+			}
+			return valid, err
+		}
+		change, err := decodeChange(record)
+		if err != nil {
+			return valid, fmt.Errorf("persistence: outbox record at offset %d: %w", valid, err)
+		}
+		valid += int64(len(header) + len(record))
+		if change.Sequence > c.committed {
+			c.pending = append(c.pending, change)
+		}
+		c.last = max(c.last, change.Sequence)
+	}
+}
+
+// encodeChange encodes a change into an outbox record.
+func encodeChange(change Change) ([]byte, error) {
+	var payload []byte
+	switch change.Type {
+	case ChangeJournal:
+		var err error
+		if payload, err = encodeEvent(change.Event); err != nil {
+			return nil, err
+		}
+	case ChangeTrade:
+		payload = make([]byte, tradeWireSize)
+		marshalTrade(payload, change.Trade)
+	default:
+		return nil, fmt.Errorf("persistence: unknown ChangeType %d", change.Type)
+	}
+	record := make([]byte, 4+8+1, 4+8+1+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(8+1+len(payload)))
+	binary.BigEndian.PutUint64(record[4:12], change.Sequence)
+	record[12] = uint8(change.Type)
+	return append(record, payload...), nil
+}
+
+// decodeChange decodes an outbox record without its length.
+func decodeChange(record []byte) (Change, error) {
+	if len(record) < 9 {
+		return Change{}, fmt.Errorf("short record (%d bytes)", len(record))
+	}
+	change := Change{
+		Sequence: binary.BigEndian.Uint64(record[0:8]),
+		Type:     ChangeType(record[8]),
+	}
+	payload := record[9:]
+	switch change.Type {
+	case ChangeJournal:
+		var err error
+		if change.Event, err = decodeEvent(bytes.NewReader(payload)); err != nil {
+			return Change{}, err
+		}
+	case ChangeTrade:
+		if len(payload) < tradeWireSize {
+			return Change{}, fmt.Errorf("short trade (%d bytes)", len(payload))
+		}
+		change.Trade = unmarshalTrade(payload)
+	default:
+		return Change{}, fmt.Errorf("unknown ChangeType %d", change.Type)
+	}
+	return change, nil
+}
+
+// readCheckpoint reads a committed sequence, 0 if the checkpoint is missing.
+func readCheckpoint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 8 {
+		return 0, fmt.Errorf("persistence: corrupt checkpoint %s (%d bytes)", path, len(data))
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// writeCheckpoint replaces the checkpoint at path with a committed sequence
+// atomically.
+func writeCheckpoint(path string, seq uint64) error {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], seq)
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data[:]); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// AppendEvent adds a journal event to the stream.  It is safe to call from
+// multiple goroutines concurrently.
+func (c *ChangeFeed) AppendEvent(event MatchingEvent) error {
+	return c.append(Change{Type: ChangeJournal, Event: event})
+}
+
+// AppendTrade adds a trade to the stream.  It is safe to call from multiple
+// goroutines concurrently.
+func (c *ChangeFeed) AppendTrade(rec TradeRecord) error {
+	return c.append(Change{Type: ChangeTrade, Trade: rec})
+}
+
+// append numbers a change, writes it to the outbox and queues it, keeping
+// the first error.
+func (c *ChangeFeed) append(change Change) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	change.Sequence = c.last + 1
+	record, err := encodeChange(change)
+	if err == nil {
+		_, err = c.writer.Write(record)
+	}
+	if err != nil {
+		if c.err == nil {
+			c.err = err
+		}
+		return err
+	}
+	c.last = change.Sequence
+	c.pending = append(c.pending, change)
+	c.notify()
+	return nil
+}
+
+// notify wakes the delivery goroutine.
+func (c *ChangeFeed) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverLoop delivers queued changes until the feed is closed.
+func (c *ChangeFeed) deliverLoop() {
+	defer c.wg.Done()
+	for {
+		select {
+		case <-c.wake:
+		case <-c.ticker.C:
+		case <-c.done:
+			return
+		}
+		for c.deliver() {
+		}
+	}
+}
+
+// deliver fsyncs the outbox and delivers the next batch of changes.  It
+// returns true if more changes are waiting.
+func (c *ChangeFeed) deliver() bool {
+	c.mu.Lock()
+	// Changes must be durable before the sink sees them, so that their
+	// sequences are never reused after a crash.
+	if err := c.flush(); err != nil || len(c.pending) == 0 {
+		c.mu.Unlock()
+		return false
+	}
+	batch := c.pending[:min(len(c.pending), c.opts.BatchSize)]
+	c.mu.Unlock()
+
+	if err := c.sink.Deliver(batch); err != nil {
+		c.mu.Lock()
+		c.deliveryErr = err
+		c.mu.Unlock()
+		return false
+	}
+	return c.commit(batch[len(batch)-1].Sequence)
+}
+
+// commit records the delivery of the changes up to seq and returns true if
+// more changes are waiting.
+func (c *ChangeFeed) commit(seq uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deliveryErr = nil
+	if err := writeCheckpoint(c.path+".committed", seq); err != nil {
+		c.deliveryErr = err
+		return false
+	}
+	c.committed = seq
+	n := 0
+	for n < len(c.pending) && c.pending[n].Sequence <= seq {
+		n++
+	}
+	c.pending = c.pending[n:]
+	if len(c.pending) == 0 {
+		// Every change is committed: start the outbox over.
+		c.pending = nil
+		if err := c.reset(); err != nil && c.err == nil {
+			c.err = err
+		}
+	}
+	return len(c.pending) != 0
+}
+
+// reset empties the outbox.  It must be called with c.mu held.
+func (c *ChangeFeed) reset() error {
+	if err := c.writer.Flush(); err != nil {
+		return err
+	}
+	if err := c.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := c.file.Seek(0, io.SeekStart)
+	return err
+}
+
+// flush must be called with c.mu held.
+func (c *ChangeFeed) flush() error {
+	if err := c.writer.Flush(); err != nil {
+		return err
+	}
+	return c.file.Sync()
+}
+
+// LastSequence returns the sequence of the last appended change, 0 if none.
+func (c *ChangeFeed) LastSequence() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// Committed returns the sequence of the last change accepted by the sink.
+func (c *ChangeFeed) Committed() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.committed
+}
+
+// Err returns the first error encountered while appending changes, or else
+// the error of the last delivery if it failed.
+func (c *ChangeFeed) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return c.deliveryErr
+}
+
+// Close stops delivering, flushes the outbox and closes it, returning the
+// first error encountered while appending changes if there was one.  Changes
+// not yet committed are delivered after the outbox is opened again.
+func (c *ChangeFeed) Close() error {
+	c.ticker.Stop()
+	close(c.done)
+	c.wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.flush(); err != nil {
+		_ = c.file.Close()
+		return err
+	}
+	if err := c.file.Close(); err != nil {
+		return err
+	}
+	return c.err
+}
+
+// changeRecorder is a matching.MarketHandler that adds every trade to a
+// ChangeFeed and forwards all events to the wrapped handler.
+type changeRecorder struct {
+	matching.MarketHandler
+	feed *ChangeFeed
+}
+
+// OnTrade streams the trade and forwards it to the wrapped handler.
+func (r *changeRecorder) OnTrade(trade matching.Trade) {
+	// Append errors are kept by the feed and reported by Err.
+	_ = r.feed.AppendTrade(TradeRecord{Timestamp: time.Now().UnixNano(), Trade: trade})
+	r.MarketHandler.OnTrade(trade)
+}
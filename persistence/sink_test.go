@@ -0,0 +1,196 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// recordingSink keeps the delivered changes and fails while down is set.
+type recordingSink struct {
+	mu      sync.Mutex
+	down    bool
+	changes []Change
+}
+
+func (s *recordingSink) Deliver(changes []Change) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("sink down")
+	}
+	s.changes = append(s.changes, changes...)
+	return nil
+}
+
+func (s *recordingSink) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+func (s *recordingSink) delivered() []Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Change(nil), s.changes...)
+}
+
+// waitCommitted waits until the feed committed seq.
+func waitCommitted(t *testing.T, feed *ChangeFeed, seq uint64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for feed.Committed() < seq {
+		if time.Now().After(deadline) {
+			t.Fatalf("committed: got %d, want %d (%v)", feed.Committed(), seq, feed.Err())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestChangeFeed_Manager(t *testing.T) {
+	dir := t.TempDir()
+	outbox := filepath.Join(dir, "changes.outbox")
+	sink := &recordingSink{}
+	feed, err := OpenChangeFeed(outbox, sink, ChangeFeedOptions{Trades: true, RetryInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("OpenChangeFeed: %v", err)
+	}
+	mgr, err := NewManager(newManager(t), filepath.Join(dir, "test.journal"), filepath.Join(dir, "snapshots"))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	mgr.AttachChangeFeed(feed)
+
+	if err := mgr.AddOrder(newLimitOrder(1, matching.OrderSideSell, 10000, 10)); err != nil {
+		t.Fatalf("AddOrder: %v", err)
+	}
+	if err := mgr.AddOrder(newLimitOrder(2, matching.OrderSideBuy, 10000, 10)); err != nil {
+		t.Fatalf("AddOrder: %v", err)
+	}
+	waitCommitted(t, feed, 3)
+
+	got := sink.delivered()
+	if len(got) != 3 {
+		t.Fatalf("changes: got %d, want 3", len(got))
+	}
+	for i, c := range got {
+		if c.Sequence != uint64(i+1) {
+			t.Errorf("changes[%d].Sequence: got %d, want %d", i, c.Sequence, i+1)
+		}
+	}
+	if got[1].Type != ChangeJournal || got[1].Event.Type != EventNewOrder || got[1].Event.Order.ID != 2 {
+		t.Errorf("changes[1]: got %+v, want the new order 2", got[1])
+	}
+	if got[2].Type != ChangeTrade || got[2].Trade.BuyOrderID != 2 || got[2].Trade.Quantity != 10 {
+		t.Errorf("changes[2]: got %+v, want the trade after order 2", got[2])
+	}
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestChangeFeed_Redelivery(t *testing.T) {
+	outbox := filepath.Join(t.TempDir(), "changes.outbox")
+	sink := &recordingSink{}
+	feed, err := OpenChangeFeed(outbox, sink, ChangeFeedOptions{RetryInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("OpenChangeFeed: %v", err)
+	}
+	if err := feed.AppendEvent(MatchingEvent{Type: EventCancelOrder, Timestamp: 1, OrderID: 1}); err != nil {
+		t.Fatalf("AppendEvent: %v", err)
+	}
+	waitCommitted(t, feed, 1)
+
+	// Changes appended while the sink is down wait in the outbox, across a
+	// restart.
+	sink.setDown(true)
+	for id := uint64(2); id <= 3; id++ {
+		if err := feed.AppendEvent(MatchingEvent{Type: EventCancelOrder, Timestamp: int64(id), OrderID: id}); err != nil {
+			t.Fatalf("AppendEvent: %v", err)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	if feed.Committed() != 1 || feed.Err() == nil {
+		t.Errorf("committed: got %d, %v, want 1 and the sink error", feed.Committed(), feed.Err())
+	}
+	if err := feed.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	sink.setDown(false)
+	feed, err = OpenChangeFeed(outbox, sink, ChangeFeedOptions{RetryInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("OpenChangeFeed: %v", err)
+	}
+	defer feed.Close()
+	waitCommitted(t, feed, 3)
+	got := sink.delivered()
+	if len(got) != 3 || got[1].Sequence != 2 || got[2].Event.OrderID != 3 {
+		t.Errorf("changes: got %+v, want sequences 1 to 3", got)
+	}
+
+	// Sequences continue after the emptied outbox.
+	if err := feed.AppendEvent(MatchingEvent{Type: EventResetSession, Timestamp: 4}); err != nil {
+		t.Fatalf("AppendEvent: %v", err)
+	}
+	if feed.LastSequence() != 4 {
+		t.Errorf("LastSequence: got %d, want 4", feed.LastSequence())
+	}
+}
+
+// recordingProducer keeps the produced messages.
+type recordingProducer struct {
+	messages []KafkaMessage
+}
+
+func (p *recordingProducer) Produce(ctx context.Context, messages []KafkaMessage) error {
+	p.messages = append(p.messages, messages...)
+	return nil
+}
+
+func TestKafkaSink(t *testing.T) {
+	producer := &recordingProducer{}
+	sink := NewKafkaSink(producer, KafkaSinkOptions{Topic: "journal", TradeTopic: "trades"})
+	trade := TradeRecord{Timestamp: 2, Trade: matching.Trade{SymbolID: 1, BuyOrderID: 2, SellOrderID: 1, Price: 10000, Quantity: 10, Aggressor: matching.OrderSideBuy}}
+	err := sink.Deliver([]Change{
+		{Sequence: 7, Type: ChangeJournal, Event: MatchingEvent{Type: EventNewOrder, Timestamp: 1, Order: newLimitOrder(2, matching.OrderSideBuy, 10000, 10)}},
+		{Sequence: 8, Type: ChangeTrade, Trade: trade},
+	})
+	if err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if len(producer.messages) != 2 {
+		t.Fatalf("messages: got %d, want 2", len(producer.messages))
+	}
+
+	order, tr := producer.messages[0], producer.messages[1]
+	if order.Topic != "journal" || string(order.Key) != "2" || tr.Topic != "trades" || string(tr.Key) != "1" {
+		t.Errorf("routing: got %s/%s and %s/%s, want journal/2 and trades/1", order.Topic, order.Key, tr.Topic, tr.Key)
+	}
+	if len(tr.Headers) != 1 || tr.Headers[0].Key != "sequence" || string(tr.Headers[0].Value) != "8" {
+		t.Errorf("headers: got %+v, want sequence 8", tr.Headers)
+	}
+	var value struct {
+		Sequence uint64 `json:"sequence"`
+		Type     string `json:"type"`
+		Order    struct {
+			ID uint64 `json:"id"`
+		} `json:"order"`
+	}
+	if err := json.Unmarshal(order.Value, &value); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if value.Sequence != 7 || value.Type != "new_order" || value.Order.ID != 2 {
+		t.Errorf("value: got %s", order.Value)
+	}
+	want := `{"sequence":8,"type":"trade","timestamp":2,"trade":{"symbol_id":1,"buy_order_id":2,"sell_order_id":1,"price":10000,"quantity":10,"aggressor":"BUY"}}`
+	if string(tr.Value) != want {
+		t.Errorf("value: got %s, want %s", tr.Value, want)
+	}
+}
@@ -1,23 +1,25 @@
 package persistence
 
 import (
+	"bufio"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/klauspost/compress/zstd"
 	"github.com/tienpsm/go-trader/matching"
 )
 
-// snapshotMagic is written at the start of every snapshot file so that corrupt
-// or foreign files are rejected quickly.
-var snapshotMagic = [8]byte{'G', 'T', 'S', 'N', 'A', 'P', 0, 1}
+// snapshotMagicPrefix is written at the start of every snapshot file so that
+// corrupt or foreign files are rejected quickly.  It is followed by the format
+// version as a big-endian uint16.
+var snapshotMagicPrefix = [6]byte{'G', 'T', 'S', 'N', 'A', 'P'}
 
 // Snapshot is the full, self-contained state of the matching engine at a single
 // point in time.  Symbols carry their order-book association implicitly: an
@@ -30,31 +32,100 @@ type Snapshot struct {
 	// Orders is the list of all active orders (with their current execution
 	// state) across all order books.
 	Orders []matching.Order
+	// OrderIDState is the state of the order ID allocator, so that IDs
+	// allocated before the snapshot are not allocated again after a restart.
+	// It is 0 for snapshots taken before the allocator was persisted.
+	OrderIDState uint64
 }
 
 // Snapshotter manages snapshot files inside a directory.
 type Snapshotter struct {
-	dir string
+	dir  string
+	opts SnapshotterOptions
+	// workers holds a token per snapshot being written, bounding the
+	// compressions running at the same time to opts.Workers.
+	workers chan struct{}
+	// warm is the optional secondary tier set by AttachWarmStorage.
+	warm Storage
+}
+
+// SnapshotterOptions configures how a Snapshotter compresses snapshots, so
+// that checkpoints of a large engine do not compete with matching for CPU.
+// The zero value compresses one snapshot at a time, on a single goroutine, at
+// the default zstd level and without a rate limit.
+type SnapshotterOptions struct {
+	// Level is the zstd compression level.  The zero value is
+	// zstd.SpeedDefault.
+	Level zstd.EncoderLevel
+	// Concurrency is the number of goroutines compressing a snapshot.  The
+	// zero value is 1, so that a checkpoint takes at most one core.
+	Concurrency int
+	// Workers is the number of snapshots written at the same time.  Further
+	// saves wait for a worker to be free.  The zero value is 1.
+	Workers int
+	// Limiter, if set, throttles the snapshot data fed to the compressor, in
+	// bytes, spreading a checkpoint over time instead of running it in a
+	// burst.  See NewRateLimiter.
+	Limiter RateLimiter
 }
 
 // NewSnapshotter creates a Snapshotter that stores files in dir.
 // dir is created if it does not exist.
 func NewSnapshotter(dir string) (*Snapshotter, error) {
+	return NewSnapshotterWithOptions(dir, SnapshotterOptions{})
+}
+
+// NewSnapshotterWithOptions is NewSnapshotter with compression options.
+func NewSnapshotterWithOptions(dir string, opts SnapshotterOptions) (*Snapshotter, error) {
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return nil, err
 	}
-	return &Snapshotter{dir: dir}, nil
+	if opts.Level == 0 {
+		opts.Level = zstd.SpeedDefault
+	}
+	opts.Concurrency = max(opts.Concurrency, 1)
+	opts.Workers = max(opts.Workers, 1)
+	return &Snapshotter{
+		dir:     dir,
+		opts:    opts,
+		workers: make(chan struct{}, opts.Workers),
+	}, nil
+}
+
+// ParseSnapshotLevel parses the name of a zstd compression level, "fastest",
+// "default", "better" or "best".  The empty string is the default level.
+func ParseSnapshotLevel(s string) (zstd.EncoderLevel, error) {
+	if s == "" {
+		return zstd.SpeedDefault, nil
+	}
+	ok, level := zstd.EncoderLevelFromString(s)
+	if !ok {
+		return 0, fmt.Errorf("persistence: unknown snapshot level %q", s)
+	}
+	return level, nil
+}
+
+// Options returns the compression options of the snapshotter, with the
+// defaults filled in.
+func (s *Snapshotter) Options() SnapshotterOptions {
+	return s.opts
 }
 
 // snapshotPath returns the full path for a snapshot with the given timestamp.
 func (s *Snapshotter) snapshotPath(ts int64) string {
-	return filepath.Join(s.dir, fmt.Sprintf("snapshot-%d.snap", ts))
+	return filepath.Join(s.dir, snapshotName(ts))
 }
 
 // Save serialises snap and writes it to a zstd-compressed file.
 // The file is written atomically: data is first flushed to a temp file and then
 // renamed so that a crash mid-write never leaves a corrupt snapshot.
+//
+// Save waits for a free worker when opts.Workers snapshots are already being
+// written.
 func (s *Snapshotter) Save(snap Snapshot) error {
+	s.workers <- struct{}{}
+	defer func() { <-s.workers }()
+
 	dst := s.snapshotPath(snap.Timestamp)
 	tmp := dst + ".tmp"
 
@@ -63,14 +134,16 @@ func (s *Snapshotter) Save(snap Snapshot) error {
 		return err
 	}
 
-	enc, err := zstd.NewWriter(f)
+	enc, err := zstd.NewWriter(f,
+		zstd.WithEncoderLevel(s.opts.Level),
+		zstd.WithEncoderConcurrency(s.opts.Concurrency))
 	if err != nil {
 		_ = f.Close()
 		_ = os.Remove(tmp)
 		return err
 	}
 
-	if err := writeSnapshot(enc, snap); err != nil {
+	if err := s.write(enc, snap); err != nil {
 		_ = enc.Close()
 		_ = f.Close()
 		_ = os.Remove(tmp)
@@ -93,39 +166,58 @@ func (s *Snapshotter) Save(snap Snapshot) error {
 	return os.Rename(tmp, dst)
 }
 
+// write serialises snap into the compressor, through the rate limiter if
+// one is set.
+func (s *Snapshotter) write(enc io.Writer, snap Snapshot) error {
+	if s.opts.Limiter == nil {
+		return writeSnapshot(enc, snap)
+	}
+	w := bufio.NewWriterSize(&throttledWriter{
+		ctx:     context.Background(),
+		w:       enc,
+		limiter: s.opts.Limiter,
+	}, RateLimitChunk)
+	if err := writeSnapshot(w, snap); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
 // LoadLatest finds the most-recent snapshot in the directory and deserialises
 // it.  It returns nil (with no error) when no snapshot exists yet.
 func (s *Snapshotter) LoadLatest() (*Snapshot, error) {
-	entries, err := os.ReadDir(s.dir)
+	return s.LoadBefore(math.MaxInt64)
+}
+
+// LoadBefore finds the most-recent snapshot taken strictly before ts (Unix
+// nanoseconds) and deserialises it.  It returns nil (with no error) when no
+// such snapshot exists.  Snapshots moved by Tier are fetched from the warm
+// storage.
+func (s *Snapshotter) LoadBefore(ts int64) (*Snapshot, error) {
+	timestamps, warm, err := s.listSnapshots()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
 		return nil, err
 	}
-
-	// Collect snapshot timestamps so we can find the maximum.
-	var timestamps []int64
-	for _, e := range entries {
-		name := e.Name()
-		if !strings.HasPrefix(name, "snapshot-") || !strings.HasSuffix(name, ".snap") {
+	for _, snapTS := range timestamps {
+		if snapTS >= ts {
 			continue
 		}
-		tsStr := strings.TrimPrefix(name, "snapshot-")
-		tsStr = strings.TrimSuffix(tsStr, ".snap")
-		ts, err := strconv.ParseInt(tsStr, 10, 64)
-		if err != nil {
-			continue
+		if warm[snapTS] {
+			return s.loadWarm(snapTS)
 		}
-		timestamps = append(timestamps, ts)
-	}
-	if len(timestamps) == 0 {
-		return nil, nil
+		return readSnapshotFile(s.snapshotPath(snapTS))
 	}
+	return nil, nil
+}
 
-	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] > timestamps[j] })
-	path := s.snapshotPath(timestamps[0])
+// LoadSnapshotFile reads a single snapshot file written by Save, for tools
+// that inspect snapshots outside of a Snapshotter directory.
+func LoadSnapshotFile(path string) (*Snapshot, error) {
+	return readSnapshotFile(path)
+}
 
+// readSnapshotFile decompresses and deserialises the snapshot file at path.
+func readSnapshotFile(path string) (*Snapshot, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -161,23 +253,34 @@ func captureSnapshot(mm *matching.MarketManager) Snapshot {
 		symbols = append(symbols, *sym)
 	}
 
-	orders := make([]matching.Order, 0, len(mm.Orders()))
+	// Orders are captured in arrival order so that RestoreOrder rebuilds the
+	// same queue priority within each price level.
+	nodes := make([]*matching.OrderNode, 0, len(mm.Orders()))
 	for _, node := range mm.Orders() {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Priority() < nodes[j].Priority() })
+
+	orders := make([]matching.Order, 0, len(nodes))
+	for _, node := range nodes {
 		orders = append(orders, node.Order)
 	}
 
 	return Snapshot{
-		Timestamp: ts,
-		Symbols:   symbols,
-		Orders:    orders,
+		Timestamp:    ts,
+		Symbols:      symbols,
+		Orders:       orders,
+		OrderIDState: mm.OrderIDAllocator().State(),
 	}
 }
 
 // ─── Binary snapshot wire format ────────────────────────────────────────────
 //
-// All integers are big-endian.
+// All integers are big-endian.  This is the layout of the current version;
+// older versions are described in snapshot_version.go.
 //
-//	 8 bytes – magic
+//	 6 bytes – magic prefix
+//	 2 bytes – format version (uint16)
 //	 8 bytes – Timestamp (int64)
 //	 4 bytes – number of symbols (uint32)
 //	   per symbol:
@@ -185,11 +288,15 @@ func captureSnapshot(mm *matching.MarketManager) Snapshot {
 //	     1 byte  – name length (uint8)
 //	     N bytes – name (UTF-8)
 //	 4 bytes – number of orders (uint32)
-//	   per order: 87 bytes (orderWireSize)
+//	   per order: 120 bytes (orderWireSize)
+//	 8 bytes – OrderIDState (uint64)
 
 func writeSnapshot(w io.Writer, snap Snapshot) error {
-	// Magic
-	if _, err := w.Write(snapshotMagic[:]); err != nil {
+	// Magic and version
+	var magic [8]byte
+	copy(magic[:], snapshotMagicPrefix[:])
+	binary.BigEndian.PutUint16(magic[6:8], snapshotVersion)
+	if _, err := w.Write(magic[:]); err != nil {
 		return err
 	}
 
@@ -237,19 +344,17 @@ func writeSnapshot(w io.Writer, snap Snapshot) error {
 			return err
 		}
 	}
-	return nil
-}
 
-func readSnapshot(r io.Reader) (*Snapshot, error) {
-	// Magic
-	var magic [8]byte
-	if _, err := io.ReadFull(r, magic[:]); err != nil {
-		return nil, fmt.Errorf("persistence: reading snapshot magic: %w", err)
-	}
-	if magic != snapshotMagic {
-		return nil, fmt.Errorf("persistence: invalid snapshot magic")
-	}
+	// Order ID allocator
+	binary.BigEndian.PutUint64(buf8[:], snap.OrderIDState)
+	_, err := w.Write(buf8[:])
+	return err
+}
 
+// readSnapshotBody reads everything after the magic of a snapshot whose
+// orders are orderSize bytes long.  It is shared by the formats that only
+// differ in the order layout.
+func readSnapshotBody(r io.Reader, orderSize int) (*Snapshot, error) {
 	// Timestamp
 	var buf8 [8]byte
 	if _, err := io.ReadFull(r, buf8[:]); err != nil {
@@ -292,7 +397,7 @@ func readSnapshot(r io.Reader) (*Snapshot, error) {
 	}
 	orderCount := binary.BigEndian.Uint32(buf4[:])
 	snap.Orders = make([]matching.Order, 0, orderCount)
-	orderBuf := make([]byte, orderWireSize)
+	orderBuf := make([]byte, orderSize)
 	for i := uint32(0); i < orderCount; i++ {
 		if _, err := io.ReadFull(r, orderBuf); err != nil {
 			return nil, fmt.Errorf("persistence: reading order: %w", err)
@@ -302,3 +407,19 @@ func readSnapshot(r io.Reader) (*Snapshot, error) {
 
 	return snap, nil
 }
+
+// readSnapshotV5 reads the body of a version 5 or later snapshot, which
+// appends the order ID allocator state to the version 4 layout, with orders
+// of orderSize bytes.
+func readSnapshotV5(r io.Reader, orderSize int) (*Snapshot, error) {
+	snap, err := readSnapshotBody(r, orderSize)
+	if err != nil {
+		return nil, err
+	}
+	var buf8 [8]byte
+	if _, err := io.ReadFull(r, buf8[:]); err != nil {
+		return nil, fmt.Errorf("persistence: reading order ID state: %w", err)
+	}
+	snap.OrderIDState = binary.BigEndian.Uint64(buf8[:])
+	return snap, nil
+}
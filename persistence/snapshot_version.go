@@ -0,0 +1,97 @@
+package persistence
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// snapshotVersion is the format version written by writeSnapshot.
+//
+// To change the format, bump snapshotVersion, register a reader for the new
+// version in snapshotReaders and a migration from the previous version in
+// snapshotMigrations.  Readers of old versions must be kept so that their
+// snapshots stay loadable.
+const snapshotVersion uint16 = 7
+
+// snapshotReaders decode the body of a snapshot, after the magic, for every
+// supported format version.
+//
+// Version history:
+//
+//	1 – initial format, 87-byte orders
+//	2 – orders gain ParticipantID (91 bytes)
+//	3 – orders gain the iceberg display range (107 bytes)
+//	4 – orders gain MinQuantity (115 bytes)
+//	5 – the order ID allocator state follows the orders
+//	6 – orders gain AccountID (119 bytes)
+//	7 – orders gain the flags, such as Quote (120 bytes)
+var snapshotReaders = map[uint16]func(r io.Reader) (*Snapshot, error){
+	1: func(r io.Reader) (*Snapshot, error) { return readSnapshotBody(r, orderWireSizeV1) },
+	2: func(r io.Reader) (*Snapshot, error) { return readSnapshotBody(r, orderWireSizeV2) },
+	3: func(r io.Reader) (*Snapshot, error) { return readSnapshotBody(r, orderWireSizeV3) },
+	4: func(r io.Reader) (*Snapshot, error) { return readSnapshotBody(r, orderWireSizeV4) },
+	5: func(r io.Reader) (*Snapshot, error) { return readSnapshotV5(r, orderWireSizeV4) },
+	6: func(r io.Reader) (*Snapshot, error) { return readSnapshotV5(r, orderWireSizeV5) },
+	7: func(r io.Reader) (*Snapshot, error) { return readSnapshotV5(r, orderWireSize) },
+}
+
+// snapshotMigrations upgrade a snapshot decoded from the version given by the
+// key to the next version.  Migrations are applied in sequence until the
+// snapshot reaches snapshotVersion.
+var snapshotMigrations = map[uint16]func(snap *Snapshot) error{
+	// Version 1 orders have no participant, which decodes as anonymous (0).
+	1: func(snap *Snapshot) error { return nil },
+	// Version 2 orders have no display range, which decodes as a fixed
+	// display.
+	2: func(snap *Snapshot) error { return nil },
+	// Version 3 orders have no minimum quantity.
+	3: func(snap *Snapshot) error { return nil },
+	// Version 4 snapshots have no order ID state.  The allocator observes
+	// the restored and replayed orders instead.
+	4: func(snap *Snapshot) error { return nil },
+	// Version 5 orders settle to the default account.
+	5: func(snap *Snapshot) error { return nil },
+	// Version 6 orders are not quotes.
+	6: func(snap *Snapshot) error { return nil },
+}
+
+// readSnapshot checks the magic of a snapshot, decodes it with the reader of
+// its format version and migrates it to the current version.
+func readSnapshot(r io.Reader) (*Snapshot, error) {
+	var magic [8]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, fmt.Errorf("persistence: reading snapshot magic: %w", err)
+	}
+	if [6]byte(magic[:6]) != snapshotMagicPrefix {
+		return nil, fmt.Errorf("persistence: invalid snapshot magic")
+	}
+
+	version := binary.BigEndian.Uint16(magic[6:8])
+	read, ok := snapshotReaders[version]
+	if !ok || version > snapshotVersion {
+		return nil, fmt.Errorf("persistence: unsupported snapshot version %d", version)
+	}
+	snap, err := read(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrateSnapshot(snap, version); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// migrateSnapshot upgrades snap from version to snapshotVersion.
+func migrateSnapshot(snap *Snapshot, version uint16) error {
+	for v := version; v < snapshotVersion; v++ {
+		migrate, ok := snapshotMigrations[v]
+		if !ok {
+			return fmt.Errorf("persistence: no snapshot migration from version %d", v)
+		}
+		if err := migrate(snap); err != nil {
+			return fmt.Errorf("persistence: migrating snapshot from version %d: %w", v, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package persistence
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// TestSnapshotFixtures loads a snapshot written by every supported format
+// version.  testdata/snapshot-vN.snap holds the same engine state in format N:
+// AAPL and MSFT, a partially filled buy on AAPL and a sell on MSFT, from
+// version 5 the order ID state 3, from version 6 the account of the sell and
+// from version 7 the sell is a quote.
+func TestSnapshotFixtures(t *testing.T) {
+	for version := uint16(1); version <= snapshotVersion; version++ {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			path := filepath.Join("testdata", fmt.Sprintf("snapshot-v%d.snap", version))
+			snap, err := readSnapshotFile(path)
+			if err != nil {
+				t.Fatalf("readSnapshotFile: %v", err)
+			}
+
+			if snap.Timestamp != 1700000000000000000 {
+				t.Errorf("Timestamp: got %d, want 1700000000000000000", snap.Timestamp)
+			}
+			want := []matching.Symbol{{ID: 1, Name: "AAPL"}, {ID: 2, Name: "MSFT"}}
+			if len(snap.Symbols) != len(want) || snap.Symbols[0] != want[0] || snap.Symbols[1] != want[1] {
+				t.Errorf("Symbols: got %v, want %v", snap.Symbols, want)
+			}
+			if len(snap.Orders) != 2 {
+				t.Fatalf("Orders len: got %d, want 2", len(snap.Orders))
+			}
+
+			buy := newLimitOrder(1, matching.OrderSideBuy, 10000, 100)
+			buy.ExecutedQuantity = 30
+			buy.LeavesQuantity = 70
+			if snap.Orders[0] != buy {
+				t.Errorf("Orders[0]: got %+v, want %+v", snap.Orders[0], buy)
+			}
+
+			sell := newLimitOrder(2, matching.OrderSideSell, 10100, 50)
+			sell.SymbolID = 2
+			if version >= 2 {
+				sell.ParticipantID = 42
+			}
+			if version >= 6 {
+				sell.AccountID = 9
+			}
+			if version >= 7 {
+				sell.Quote = true
+			}
+			if snap.Orders[1] != sell {
+				t.Errorf("Orders[1]: got %+v, want %+v", snap.Orders[1], sell)
+			}
+
+			wantState := uint64(0)
+			if version >= 5 {
+				wantState = 3
+			}
+			if snap.OrderIDState != wantState {
+				t.Errorf("OrderIDState: got %d, want %d", snap.OrderIDState, wantState)
+			}
+		})
+	}
+}
+
+func TestSnapshotVersionRegistry(t *testing.T) {
+	for version := uint16(1); version <= snapshotVersion; version++ {
+		if _, ok := snapshotReaders[version]; !ok {
+			t.Errorf("no reader for snapshot version %d", version)
+		}
+		if _, ok := snapshotMigrations[version]; !ok && version < snapshotVersion {
+			t.Errorf("no migration from snapshot version %d", version)
+		}
+	}
+}
+
+func TestReadSnapshot_RejectsUnknownVersion(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeSnapshot(&buf, Snapshot{Timestamp: 1}); err != nil {
+		t.Fatalf("writeSnapshot: %v", err)
+	}
+	data := buf.Bytes()
+
+	data[7] = byte(snapshotVersion + 1)
+	_, err := readSnapshot(bytes.NewReader(data))
+	if err == nil || !strings.Contains(err.Error(), "unsupported snapshot version") {
+		t.Errorf("future version: got %v, want unsupported version error", err)
+	}
+
+	data[0] = 'X'
+	_, err = readSnapshot(bytes.NewReader(data))
+	if err == nil || !strings.Contains(err.Error(), "invalid snapshot magic") {
+		t.Errorf("bad magic: got %v, want invalid magic error", err)
+	}
+}
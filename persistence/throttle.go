@@ -0,0 +1,89 @@
+package persistence
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimitChunk is the largest number of bytes a Snapshotter asks its
+// RateLimiter for at once.  Limiters with a burst, such as a *rate.Limiter of
+// golang.org/x/time/rate, need a burst of at least RateLimitChunk.
+const RateLimitChunk = 64 << 10
+
+// RateLimiter throttles the data written by a Snapshotter.  WaitN blocks
+// until n more bytes may be written.  *rate.Limiter of golang.org/x/time/rate
+// implements it.
+type RateLimiter interface {
+	WaitN(ctx context.Context, n int) error
+}
+
+// byteRateLimiter is the RateLimiter of NewRateLimiter.  next is the time at
+// which the bytes granted so far have been paid for.
+type byteRateLimiter struct {
+	mu             sync.Mutex
+	bytesPerSecond float64
+	next           time.Time
+}
+
+// NewRateLimiter returns a RateLimiter letting through bytesPerSecond bytes a
+// second on average, shared by every Snapshotter it is given to.  A request
+// is granted at once when the limiter is idle, later requests wait for the
+// earlier ones to be paid for.  It returns nil, no limit, when bytesPerSecond
+// is not positive.
+func NewRateLimiter(bytesPerSecond int64) RateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &byteRateLimiter{bytesPerSecond: float64(bytesPerSecond)}
+}
+
+// WaitN waits until n bytes may be written.
+func (l *byteRateLimiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / l.bytesPerSecond * float64(time.Second)))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledWriter writes through a RateLimiter, at most RateLimitChunk bytes
+// at a time.
+type throttledWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter RateLimiter
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), RateLimitChunk)]
+		if err := t.limiter.WaitN(t.ctx, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := t.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
@@ -0,0 +1,144 @@
+package persistence
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// largeSnapshot returns a snapshot of n orders.
+func largeSnapshot(ts int64, n int) Snapshot {
+	snap := Snapshot{Timestamp: ts, Symbols: []matching.Symbol{{ID: 1, Name: "AAPL"}}}
+	for i := 0; i < n; i++ {
+		snap.Orders = append(snap.Orders, newLimitOrder(uint64(i+1), matching.OrderSideBuy, 10000-uint64(i%100), 100))
+	}
+	return snap
+}
+
+func TestSnapshotter_Options(t *testing.T) {
+	sp, err := NewSnapshotterWithOptions(t.TempDir(), SnapshotterOptions{
+		Level:       zstd.SpeedBestCompression,
+		Concurrency: 2,
+		Limiter:     NewRateLimiter(1 << 20),
+	})
+	if err != nil {
+		t.Fatalf("NewSnapshotterWithOptions: %v", err)
+	}
+	if opts := sp.Options(); opts.Workers != 1 || opts.Concurrency != 2 || opts.Level != zstd.SpeedBestCompression {
+		t.Errorf("got options %+v, want 1 worker compressing on 2 goroutines", opts)
+	}
+
+	// 2000 orders are 230 KB, of which all but the first chunk are throttled
+	// at 1 MB/s.
+	start := time.Now()
+	if err := sp.Save(largeSnapshot(1, 2000)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("got a save in %v, want at least 100ms at 1 MB/s", elapsed)
+	}
+
+	got, err := sp.LoadLatest()
+	if err != nil {
+		t.Fatalf("LoadLatest: %v", err)
+	}
+	if len(got.Orders) != 2000 || got.Orders[1999].ID != 2000 {
+		t.Errorf("got %d orders, want 2000", len(got.Orders))
+	}
+}
+
+// blockingLimiter blocks its first WaitN until release is closed.
+type blockingLimiter struct {
+	mu      sync.Mutex
+	calls   int
+	release chan struct{}
+}
+
+func (l *blockingLimiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	l.calls++
+	first := l.calls == 1
+	l.mu.Unlock()
+	if first {
+		<-l.release
+	}
+	return nil
+}
+
+func (l *blockingLimiter) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.calls
+}
+
+func TestSnapshotter_Workers(t *testing.T) {
+	limiter := &blockingLimiter{release: make(chan struct{})}
+	sp, err := NewSnapshotterWithOptions(t.TempDir(), SnapshotterOptions{Limiter: limiter})
+	if err != nil {
+		t.Fatalf("NewSnapshotterWithOptions: %v", err)
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- sp.Save(largeSnapshot(1, 10)) }()
+	for limiter.count() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	go func() { errs <- sp.Save(largeSnapshot(2, 10)) }()
+
+	// The second save waits for the only worker
+	time.Sleep(50 * time.Millisecond)
+	if n := limiter.count(); n != 1 {
+		t.Errorf("got %d limiter calls while the first save is blocked, want 1", n)
+	}
+	close(limiter.release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	if n := limiter.count(); n != 2 {
+		t.Errorf("got %d limiter calls, want 2", n)
+	}
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	if l := NewRateLimiter(0); l != nil {
+		t.Errorf("got limiter %v for no limit, want nil", l)
+	}
+
+	l := NewRateLimiter(1000)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.WaitN(context.Background(), 50); err != nil {
+			t.Fatalf("WaitN: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("got 150 bytes in %v, want at least 100ms at 1000 bytes/s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.WaitN(ctx, 1000); err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestParseSnapshotLevel(t *testing.T) {
+	for s, want := range map[string]zstd.EncoderLevel{
+		"":        zstd.SpeedDefault,
+		"fastest": zstd.SpeedFastest,
+		"best":    zstd.SpeedBestCompression,
+	} {
+		if got, err := ParseSnapshotLevel(s); err != nil || got != want {
+			t.Errorf("ParseSnapshotLevel(%q): got %v, %v, want %v", s, got, err, want)
+		}
+	}
+	if _, err := ParseSnapshotLevel("max"); err == nil {
+		t.Error("got no error for an unknown level")
+	}
+}
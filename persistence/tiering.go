@@ -0,0 +1,265 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrNoWarmStorage is returned when a snapshot has been moved to the warm tier
+// but no warm Storage is attached to the Snapshotter.
+var ErrNoWarmStorage = errors.New("persistence: no warm snapshot storage attached")
+
+// Storage is a secondary (warm) tier for snapshot files, such as a network
+// mount or an object store.  Objects are addressed by snapshot file name.
+//
+// Implementations must be safe for concurrent use.
+type Storage interface {
+	// Put stores the contents of r under name, replacing any existing object.
+	Put(name string, r io.Reader) error
+	// Get opens the object stored under name.
+	Get(name string) (io.ReadCloser, error)
+}
+
+// DirStorage is a Storage backed by a local directory, typically on a larger
+// or slower volume than the snapshot directory.
+type DirStorage struct {
+	dir string
+}
+
+// NewDirStorage creates a DirStorage in dir, which is created if needed.
+func NewDirStorage(dir string) (*DirStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DirStorage{dir: dir}, nil
+}
+
+// Put writes r to the file name atomically.
+func (d *DirStorage) Put(name string, r io.Reader) error {
+	dst := filepath.Join(d.dir, name)
+	tmp := dst + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// Get opens the file name.
+func (d *DirStorage) Get(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(d.dir, name))
+}
+
+// TieringPolicy decides which snapshots Tier moves to warm storage.
+type TieringPolicy struct {
+	// KeepLocal is the number of most recent snapshots that always stay in
+	// the snapshot directory.  At least one is always kept.
+	KeepLocal int
+	// MinAge is the age a snapshot must reach before it is moved.
+	MinAge time.Duration
+	// Recompress re-encodes moved snapshots at the best zstd compression
+	// level, trading CPU time for warm storage space.
+	Recompress bool
+}
+
+// warmSuffix marks the local metadata file left behind for a snapshot that
+// was moved to warm storage.
+const warmSuffix = ".warm"
+
+// AttachWarmStorage sets the warm tier used by Tier and by LoadLatest and
+// LoadBefore for snapshots no longer kept locally.
+func (s *Snapshotter) AttachWarmStorage(st Storage) {
+	s.warm = st
+}
+
+// snapshotName returns the file name of the snapshot with the given timestamp.
+func snapshotName(ts int64) string {
+	return fmt.Sprintf("snapshot-%d.snap", ts)
+}
+
+// listSnapshots returns the timestamps of every snapshot in the directory,
+// newest first, and whether each one only exists in warm storage.
+func (s *Snapshotter) listSnapshots() ([]int64, map[int64]bool, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	warm := make(map[int64]bool)
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, "snapshot-") {
+			continue
+		}
+		var suffix string
+		switch {
+		case strings.HasSuffix(name, ".snap"):
+			suffix = ".snap"
+		case strings.HasSuffix(name, warmSuffix):
+			suffix = warmSuffix
+		default:
+			continue
+		}
+		ts, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, "snapshot-"), suffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		// A local copy wins over a warm one left by an interrupted Tier.
+		if isWarm, seen := warm[ts]; seen && !isWarm {
+			continue
+		}
+		warm[ts] = suffix == warmSuffix
+	}
+
+	timestamps := make([]int64, 0, len(warm))
+	for ts := range warm {
+		timestamps = append(timestamps, ts)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] > timestamps[j] })
+	return timestamps, warm, nil
+}
+
+// LatestTime returns the capture time of the newest snapshot, local or warm,
+// or the zero time if there is none.
+func (s *Snapshotter) LatestTime() (time.Time, error) {
+	timestamps, _, err := s.listSnapshots()
+	if err != nil || len(timestamps) == 0 {
+		return time.Time{}, err
+	}
+	return time.Unix(0, timestamps[0]), nil
+}
+
+// loadWarm reads a snapshot from warm storage.
+func (s *Snapshotter) loadWarm(ts int64) (*Snapshot, error) {
+	if s.warm == nil {
+		return nil, ErrNoWarmStorage
+	}
+	rc, err := s.warm.Get(snapshotName(ts))
+	if err != nil {
+		return nil, fmt.Errorf("persistence: fetching warm snapshot %d: %w", ts, err)
+	}
+	defer rc.Close()
+
+	dec, err := zstd.NewReader(rc)
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+
+	return readSnapshot(dec)
+}
+
+// Tier moves the snapshots selected by policy to the attached warm storage.
+// Each moved snapshot leaves a small metadata file in the snapshot directory
+// so that it can still be found and loaded.  It returns the number of
+// snapshots moved.
+func (s *Snapshotter) Tier(policy TieringPolicy) (int, error) {
+	if s.warm == nil {
+		return 0, ErrNoWarmStorage
+	}
+	timestamps, warm, err := s.listSnapshots()
+	if err != nil {
+		return 0, err
+	}
+
+	keep := policy.KeepLocal
+	if keep < 1 {
+		keep = 1
+	}
+	cutoff := time.Now().Add(-policy.MinAge).UnixNano()
+
+	moved := 0
+	local := 0
+	for _, ts := range timestamps {
+		if warm[ts] {
+			continue
+		}
+		local++
+		if local <= keep || ts > cutoff {
+			continue
+		}
+		if err := s.moveToWarm(ts, policy.Recompress); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// moveToWarm uploads a local snapshot, writes its metadata file and removes
+// the local copy, in that order so that a crash never loses the snapshot.
+func (s *Snapshotter) moveToWarm(ts int64, recompress bool) error {
+	path := s.snapshotPath(ts)
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var src io.Reader = f
+	if recompress {
+		pr, pw := io.Pipe()
+		go func() { pw.CloseWithError(recompressSnapshot(pw, f)) }()
+		defer pr.Close()
+		src = pr
+	}
+
+	name := snapshotName(ts)
+	if err := s.warm.Put(name, src); err != nil {
+		return fmt.Errorf("persistence: storing warm snapshot %d: %w", ts, err)
+	}
+
+	stub := strings.TrimSuffix(path, ".snap") + warmSuffix
+	if err := os.WriteFile(stub+".tmp", []byte(name+"\n"), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(stub+".tmp", stub); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// recompressSnapshot re-encodes a zstd stream at the best compression level.
+func recompressSnapshot(w io.Writer, r io.Reader) error {
+	dec, err := zstd.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer dec.Close()
+
+	enc, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(enc, dec); err != nil {
+		_ = enc.Close()
+		return err
+	}
+	return enc.Close()
+}
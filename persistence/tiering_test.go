@@ -0,0 +1,103 @@
+package persistence
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+func saveSnapshots(t *testing.T, sp *Snapshotter, timestamps ...int64) {
+	t.Helper()
+	for _, ts := range timestamps {
+		snap := Snapshot{
+			Timestamp: ts,
+			Symbols:   []matching.Symbol{{ID: 1, Name: "AAPL"}},
+			Orders:    []matching.Order{newLimitOrder(uint64(ts), matching.OrderSideBuy, 10000, 100)},
+		}
+		if err := sp.Save(snap); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+}
+
+func TestSnapshotter_TierAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	sp, err := NewSnapshotter(filepath.Join(dir, "hot"))
+	if err != nil {
+		t.Fatalf("NewSnapshotter: %v", err)
+	}
+	warm, err := NewDirStorage(filepath.Join(dir, "warm"))
+	if err != nil {
+		t.Fatalf("NewDirStorage: %v", err)
+	}
+	saveSnapshots(t, sp, 1000, 2000, 3000)
+
+	if _, err := sp.Tier(TieringPolicy{}); !errors.Is(err, ErrNoWarmStorage) {
+		t.Fatalf("Tier without storage: got %v, want ErrNoWarmStorage", err)
+	}
+
+	sp.AttachWarmStorage(warm)
+	moved, err := sp.Tier(TieringPolicy{KeepLocal: 1, Recompress: true})
+	if err != nil {
+		t.Fatalf("Tier: %v", err)
+	}
+	if moved != 2 {
+		t.Errorf("moved: got %d, want 2", moved)
+	}
+
+	// Only the newest snapshot stays local; the others leave metadata files.
+	for _, name := range []string{"snapshot-3000.snap", "snapshot-2000.warm", "snapshot-1000.warm"} {
+		if _, err := os.Stat(filepath.Join(dir, "hot", name)); err != nil {
+			t.Errorf("expected %s in snapshot dir: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hot", "snapshot-2000.snap")); !os.IsNotExist(err) {
+		t.Errorf("expected snapshot-2000.snap to be removed, got %v", err)
+	}
+
+	latest, err := sp.LoadLatest()
+	if err != nil || latest == nil || latest.Timestamp != 3000 {
+		t.Fatalf("LoadLatest: got %v, %v; want ts 3000", latest, err)
+	}
+	older, err := sp.LoadBefore(3000)
+	if err != nil || older == nil || older.Timestamp != 2000 {
+		t.Fatalf("LoadBefore(3000): got %v, %v; want ts 2000", older, err)
+	}
+	if len(older.Orders) != 1 || older.Orders[0].ID != 2000 {
+		t.Errorf("warm snapshot orders: got %+v", older.Orders)
+	}
+
+	// A second pass has nothing left to move.
+	if moved, err := sp.Tier(TieringPolicy{KeepLocal: 1}); err != nil || moved != 0 {
+		t.Errorf("second Tier: got %d, %v; want 0, nil", moved, err)
+	}
+
+	// Without the warm tier attached, moved snapshots cannot be loaded.
+	cold, _ := NewSnapshotter(filepath.Join(dir, "hot"))
+	if _, err := cold.LoadBefore(3000); !errors.Is(err, ErrNoWarmStorage) {
+		t.Errorf("LoadBefore without storage: got %v, want ErrNoWarmStorage", err)
+	}
+}
+
+func TestSnapshotter_TierMinAge(t *testing.T) {
+	dir := t.TempDir()
+	sp, _ := NewSnapshotter(filepath.Join(dir, "hot"))
+	warm, _ := NewDirStorage(filepath.Join(dir, "warm"))
+	sp.AttachWarmStorage(warm)
+
+	// Timestamps in the far future are younger than any MinAge.
+	saveSnapshots(t, sp, 1, 1<<62, 1<<62+1)
+	moved, err := sp.Tier(TieringPolicy{KeepLocal: 1, MinAge: 1})
+	if err != nil {
+		t.Fatalf("Tier: %v", err)
+	}
+	if moved != 1 {
+		t.Errorf("moved: got %d, want 1", moved)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "warm", "snapshot-1.snap")); err != nil {
+		t.Errorf("expected snapshot-1.snap in warm storage: %v", err)
+	}
+}
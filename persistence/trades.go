@@ -0,0 +1,299 @@
+package persistence
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tienpsm/go-trader/events"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// ErrNoTradeStore is returned by Manager trade queries when no trade store
+// has been attached.
+var ErrNoTradeStore = errors.New("persistence: no trade store attached")
+
+// tradeWireSize is the fixed byte size of a serialised TradeRecord.
+// Layout (all big-endian):
+//
+//	8 – Timestamp
+//	4 – SymbolID
+//	8 – BuyOrderID
+//	8 – SellOrderID
+//	8 – Price
+//	8 – Quantity
+//	1 – Aggressor
+//
+// Total: 45 bytes
+const tradeWireSize = 45
+
+// TradeRecord is a trade together with the time it was recorded.
+type TradeRecord struct {
+	// Timestamp is Unix nanoseconds at the time the trade was recorded.
+	Timestamp int64
+	matching.Trade
+}
+
+// marshalTrade writes rec into buf (must be at least tradeWireSize bytes).
+func marshalTrade(buf []byte, rec TradeRecord) {
+	binary.BigEndian.PutUint64(buf[0:8], uint64(rec.Timestamp))
+	binary.BigEndian.PutUint32(buf[8:12], rec.SymbolID)
+	binary.BigEndian.PutUint64(buf[12:20], rec.BuyOrderID)
+	binary.BigEndian.PutUint64(buf[20:28], rec.SellOrderID)
+	binary.BigEndian.PutUint64(buf[28:36], rec.Price)
+	binary.BigEndian.PutUint64(buf[36:44], rec.Quantity)
+	buf[44] = uint8(rec.Aggressor)
+}
+
+// unmarshalTrade reads a trade record from buf (must be at least tradeWireSize bytes).
+func unmarshalTrade(buf []byte) TradeRecord {
+	return TradeRecord{
+		Timestamp: int64(binary.BigEndian.Uint64(buf[0:8])),
+		Trade: matching.Trade{
+			SymbolID:    binary.BigEndian.Uint32(buf[8:12]),
+			BuyOrderID:  binary.BigEndian.Uint64(buf[12:20]),
+			SellOrderID: binary.BigEndian.Uint64(buf[20:28]),
+			Price:       binary.BigEndian.Uint64(buf[28:36]),
+			Quantity:    binary.BigEndian.Uint64(buf[36:44]),
+			Aggressor:   matching.OrderSide(buf[44]),
+		},
+	}
+}
+
+// TradeStore is an append-only, file-backed history of executed trades with
+// an in-memory index by symbol.
+//
+// Records have a fixed size, so a torn record left by a crash is detected on
+// open and truncated away.  Like the Journal, writes are buffered and flushed
+// every defaultFlushInterval.
+type TradeStore struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	trades map[uint32][]TradeRecord
+
+	ticker *time.Ticker
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// OpenTradeStore opens (or creates) the trade store at path, loads the trades
+// it already contains and starts the background flush goroutine.
+func OpenTradeStore(path string) (*TradeStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &TradeStore{
+		file:   f,
+		trades: make(map[uint32][]TradeRecord),
+		ticker: time.NewTicker(defaultFlushInterval),
+		done:   make(chan struct{}),
+	}
+
+	valid, err := s.load()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	// Drop a torn tail record so that new records stay aligned.
+	if err := f.Truncate(valid); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	s.writer = bufio.NewWriterSize(f, defaultBufSize)
+
+	s.wg.Add(1)
+	go s.flushLoop()
+	return s, nil
+}
+
+// load reads every complete record from the file into the index and returns
+// the size of the valid prefix.
+func (s *TradeStore) load() (int64, error) {
+	r := bufio.NewReader(s.file)
+	buf := make([]byte, tradeWireSize)
+	var valid int64
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return valid, nil
+			}
+			return valid, err
+		}
+		s.index(unmarshalTrade(buf))
+		valid += tradeWireSize
+	}
+}
+
+// index adds rec to the per-symbol history, keeping it ordered by timestamp.
+// Must be called with s.mu held (or before the store is shared).
+func (s *TradeStore) index(rec TradeRecord) {
+	trades := s.trades[rec.SymbolID]
+	n := len(trades)
+	if n == 0 || trades[n-1].Timestamp <= rec.Timestamp {
+		s.trades[rec.SymbolID] = append(trades, rec)
+		return
+	}
+	// Out-of-order timestamp (wall clock stepped back): insert in place.
+	i := sort.Search(n, func(i int) bool { return trades[i].Timestamp > rec.Timestamp })
+	trades = append(trades, TradeRecord{})
+	copy(trades[i+1:], trades[i:])
+	trades[i] = rec
+	s.trades[rec.SymbolID] = trades
+}
+
+// Append writes a trade record to the store.  It is safe to call from
+// multiple goroutines concurrently.
+func (s *TradeStore) Append(rec TradeRecord) error {
+	var buf [tradeWireSize]byte
+	marshalTrade(buf[:], rec)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.writer.Write(buf[:]); err != nil {
+		return err
+	}
+	s.index(rec)
+	return nil
+}
+
+// TradesBetween returns the trades of a symbol recorded in the half-open
+// interval [from, to), ordered by timestamp.
+func (s *TradeStore) TradesBetween(symbolID uint32, from, to time.Time) []TradeRecord {
+	lo, hi := from.UnixNano(), to.UnixNano()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trades := s.trades[symbolID]
+	i := sort.Search(len(trades), func(i int) bool { return trades[i].Timestamp >= lo })
+	j := sort.Search(len(trades), func(i int) bool { return trades[i].Timestamp >= hi })
+	if i >= j {
+		return nil
+	}
+	return append([]TradeRecord(nil), trades[i:j]...)
+}
+
+// Len returns the total number of trades in the store.
+func (s *TradeStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, trades := range s.trades {
+		n += len(trades)
+	}
+	return n
+}
+
+// Flush forces all buffered trades to be written to disk (fsync).
+func (s *TradeStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+// flush must be called with s.mu held.
+func (s *TradeStore) flush() error {
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close flushes remaining trades, stops the background goroutine, and closes
+// the underlying file.
+func (s *TradeStore) Close() error {
+	s.ticker.Stop()
+	close(s.done)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.flush(); err != nil {
+		_ = s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+// flushLoop periodically flushes the write buffer.
+func (s *TradeStore) flushLoop() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.ticker.C:
+			s.mu.Lock()
+			_ = s.flush()
+			s.mu.Unlock()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// TradeRecorder is a matching.MarketHandler that appends every trade to a
+// TradeStore and forwards all events to the wrapped handler.
+//
+// Handler callbacks cannot return errors, so the first failed append is kept
+// and reported by Err.
+type TradeRecorder struct {
+	matching.MarketHandler
+	store *TradeStore
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewTradeRecorder creates a recorder that stores trades in store and forwards
+// events to next.  A nil next is replaced by a no-op handler.
+func NewTradeRecorder(store *TradeStore, next matching.MarketHandler) *TradeRecorder {
+	if next == nil {
+		next = &matching.DefaultMarketHandler{}
+	}
+	return &TradeRecorder{MarketHandler: next, store: store}
+}
+
+// OnTrade records the trade and forwards it to the wrapped handler.
+func (r *TradeRecorder) OnTrade(trade matching.Trade) {
+	r.record(trade)
+	r.MarketHandler.OnTrade(trade)
+}
+
+// Subscribe records the trades published on an events bus, as an alternative
+// to installing the recorder as the engine's handler.  Events are not
+// forwarded to the wrapped handler.
+func (r *TradeRecorder) Subscribe(s *events.Subscriber) {
+	events.Subscribe(s, events.TopicTrade, r.record)
+}
+
+// record appends a trade to the store, keeping the first error.
+func (r *TradeRecorder) record(trade matching.Trade) {
+	rec := TradeRecord{Timestamp: time.Now().UnixNano(), Trade: trade}
+	if err := r.store.Append(rec); err != nil {
+		r.mu.Lock()
+		if r.err == nil {
+			r.err = err
+		}
+		r.mu.Unlock()
+	}
+}
+
+// Err returns the first error encountered while recording trades.
+func (r *TradeRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
@@ -0,0 +1,186 @@
+package persistence
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tienpsm/go-trader/events"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+func TestEncodeDecodeTrade(t *testing.T) {
+	orig := TradeRecord{
+		Timestamp: 1234567890,
+		Trade: matching.Trade{
+			SymbolID:    7,
+			BuyOrderID:  1,
+			SellOrderID: 2,
+			Price:       10050,
+			Quantity:    25,
+			Aggressor:   matching.OrderSideSell,
+		},
+	}
+
+	buf := make([]byte, tradeWireSize)
+	marshalTrade(buf, orig)
+	if got := unmarshalTrade(buf); got != orig {
+		t.Errorf("Trade: got %+v, want %+v", got, orig)
+	}
+}
+
+func TestTradeStore_AppendReopenAndQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trades.dat")
+
+	store, err := OpenTradeStore(path)
+	if err != nil {
+		t.Fatalf("OpenTradeStore: %v", err)
+	}
+	base := time.Unix(1700000000, 0)
+	for i := 0; i < 5; i++ {
+		rec := TradeRecord{
+			Timestamp: base.Add(time.Duration(i) * time.Second).UnixNano(),
+			Trade:     matching.Trade{SymbolID: uint32(1 + i%2), Price: uint64(100 + i), Quantity: 1},
+		}
+		if err := store.Append(rec); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Simulate a crash in the middle of writing a record.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write([]byte{1, 2, 3})
+	_ = f.Close()
+
+	store, err = OpenTradeStore(path)
+	if err != nil {
+		t.Fatalf("OpenTradeStore (reopen): %v", err)
+	}
+	defer store.Close()
+
+	if store.Len() != 5 {
+		t.Fatalf("Len: got %d, want 5", store.Len())
+	}
+
+	// Symbol 1 traded at seconds 0, 2 and 4.
+	got := store.TradesBetween(1, base.Add(time.Second), base.Add(4*time.Second))
+	if len(got) != 1 || got[0].Price != 102 {
+		t.Errorf("TradesBetween: got %+v, want the trade at 102", got)
+	}
+	if got := store.TradesBetween(1, base, base.Add(time.Hour)); len(got) != 3 {
+		t.Errorf("TradesBetween: got %d trades, want 3", len(got))
+	}
+	if got := store.TradesBetween(3, base, base.Add(time.Hour)); got != nil {
+		t.Errorf("TradesBetween unknown symbol: got %+v, want nil", got)
+	}
+
+	// Records appended after the torn tail must stay readable.
+	if err := store.Append(TradeRecord{Timestamp: base.Add(10 * time.Second).UnixNano(), Trade: matching.Trade{SymbolID: 1, Price: 110}}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 6*tradeWireSize {
+		t.Errorf("file size: got %d, want %d", info.Size(), 6*tradeWireSize)
+	}
+}
+
+func TestManager_TradeStore(t *testing.T) {
+	dir := t.TempDir()
+	mm := newManager(t)
+
+	mgr, err := NewManager(mm, filepath.Join(dir, "journal.wal"), filepath.Join(dir, "snapshots"))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	from := time.Now()
+	if _, err := mgr.TradesBetween(1, from, from.Add(time.Hour)); err != ErrNoTradeStore {
+		t.Errorf("TradesBetween without store: got %v, want ErrNoTradeStore", err)
+	}
+
+	store, err := OpenTradeStore(filepath.Join(dir, "trades.dat"))
+	if err != nil {
+		t.Fatalf("OpenTradeStore: %v", err)
+	}
+	mgr.AttachTradeStore(store)
+
+	if err := mgr.AddOrder(newLimitOrder(1, matching.OrderSideSell, 10000, 100)); err != nil {
+		t.Fatalf("AddOrder: %v", err)
+	}
+	if err := mgr.AddOrder(newLimitOrder(2, matching.OrderSideBuy, 10000, 40)); err != nil {
+		t.Fatalf("AddOrder: %v", err)
+	}
+
+	trades, err := mgr.TradesBetween(1, from, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("TradesBetween: %v", err)
+	}
+	if len(trades) != 1 {
+		t.Fatalf("trades: got %d, want 1", len(trades))
+	}
+	tr := trades[0]
+	if tr.BuyOrderID != 2 || tr.SellOrderID != 1 || tr.Price != 10000 || tr.Quantity != 40 || tr.Aggressor != matching.OrderSideBuy {
+		t.Errorf("trade: got %+v", tr)
+	}
+
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Trades survive a restart.
+	store, err = OpenTradeStore(filepath.Join(dir, "trades.dat"))
+	if err != nil {
+		t.Fatalf("OpenTradeStore (reopen): %v", err)
+	}
+	defer store.Close()
+	if store.Len() != 1 {
+		t.Errorf("Len after reopen: got %d, want 1", store.Len())
+	}
+}
+
+func TestTradeRecorder_Subscribe(t *testing.T) {
+	store, err := OpenTradeStore(filepath.Join(t.TempDir(), "trades.dat"))
+	if err != nil {
+		t.Fatalf("OpenTradeStore: %v", err)
+	}
+	defer store.Close()
+
+	bus := events.NewBus()
+	recorder := NewTradeRecorder(store, nil)
+	recorder.Subscribe(bus.NewSubscriber(64, events.Block))
+
+	mm := matching.NewMarketManagerWithHandler(events.NewMarketHandler(bus))
+	mm.EnableMatching()
+	symbol := matching.NewSymbol(1, "AAPL")
+	mm.AddSymbol(symbol)
+	mm.AddOrderBook(symbol)
+	from := time.Now()
+	mm.AddOrder(newLimitOrder(1, matching.OrderSideSell, 10000, 100))
+	mm.AddOrder(newLimitOrder(2, matching.OrderSideBuy, 10000, 40))
+	mm.AddOrder(newLimitOrder(3, matching.OrderSideBuy, 10000, 10))
+	bus.Close()
+
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	trades := store.TradesBetween(1, from, time.Now().Add(time.Second))
+	if len(trades) != 2 {
+		t.Fatalf("trades: got %d, want 2", len(trades))
+	}
+	if trades[0].BuyOrderID != 2 || trades[1].BuyOrderID != 3 || trades[1].Quantity != 10 {
+		t.Errorf("trades: got %+v", trades)
+	}
+}
@@ -6,7 +6,9 @@
 //	Manager                 – top-level facade; wraps MarketManager
 //	  ├── Journal           – append-only binary WAL with batch-flush
 //	  ├── Snapshotter       – zstd-compressed periodic snapshots
+//	  ├── TradeStore        – optional append-only trade history
 //	  └── Recover()         – load latest snapshot + replay journal on startup
+//	                          (run by NewManagerWithRecovery)
 package persistence
 
 import (
@@ -25,6 +27,12 @@ const (
 	EventNewOrder EventType = iota + 1
 	// EventCancelOrder is written when an order is cancelled.
 	EventCancelOrder
+	// EventResetSession is written at the end-of-day session rollover.
+	EventResetSession
+	// EventRejectOrder is written after a new order or cancellation rejected
+	// by the engine, with ManagerOptions.JournalRejects.  It records the
+	// reason and does not change the engine state on replay.
+	EventRejectOrder
 )
 
 // MatchingEvent is the unit persisted to the journal.
@@ -39,6 +47,9 @@ type MatchingEvent struct {
 	Order matching.Order
 	// OrderID is used for EventCancelOrder.
 	OrderID uint64
+	// Reject is the reason of EventRejectOrder, whose Order is the order as
+	// submitted, or only holds the ID of a rejected cancellation.
+	Reject matching.ErrorCode
 }
 
 // orderWireSize is the fixed byte size of a serialised matching.Order.
@@ -58,13 +69,40 @@ type MatchingEvent struct {
 //	 8 – Slippage
 //	 8 – TrailingDistance
 //	 8 – TrailingStep
+//	 4 – ParticipantID
+//	 8 – DisplayLowQuantity
+//	 8 – DisplayHighQuantity
+//	 8 – MinQuantity
+//	 4 – AccountID
+//	 1 – Flags (bit 0: Quote)
 //
-// Total: 87 bytes
-const orderWireSize = 87
+// Total: 120 bytes
+const orderWireSize = 120
+
+// orderWireSizeV1 is the size of orders written before ParticipantID was
+// added.  Such records are still accepted and decode with ParticipantID 0.
+const orderWireSizeV1 = 87
+
+// orderWireSizeV2 is the size of orders written before the display range was
+// added.  Such records decode with a fixed display.
+const orderWireSizeV2 = 91
+
+// orderWireSizeV3 is the size of orders written before MinQuantity was added.
+// Such records decode without a minimum quantity.
+const orderWireSizeV3 = 107
+
+// orderWireSizeV4 is the size of orders written before AccountID was added.
+// Such records decode with the default account (0).
+const orderWireSizeV4 = 115
+
+// orderWireSizeV5 is the size of orders written before the flags were added.
+// Such records decode as orders that are not quotes.
+const orderWireSizeV5 = 119
 
 // eventHeaderSize = 1 (EventType) + 8 (Timestamp) = 9 bytes.
-// A full NewOrder record is eventHeaderSize + orderWireSize = 96 bytes.
+// A full NewOrder record is eventHeaderSize + orderWireSize = 129 bytes.
 // A CancelOrder record is eventHeaderSize + 8 (OrderID) = 17 bytes.
+// A ResetSession record is just the eventHeaderSize = 9 bytes.
 
 // marshalOrder writes o into buf (must be at least orderWireSize bytes).
 func marshalOrder(buf []byte, o matching.Order) {
@@ -82,11 +120,24 @@ func marshalOrder(buf []byte, o matching.Order) {
 	binary.BigEndian.PutUint64(buf[63:71], o.Slippage)
 	binary.BigEndian.PutUint64(buf[71:79], uint64(o.TrailingDistance))
 	binary.BigEndian.PutUint64(buf[79:87], uint64(o.TrailingStep))
+	binary.BigEndian.PutUint32(buf[87:91], o.ParticipantID)
+	binary.BigEndian.PutUint64(buf[91:99], o.DisplayLowQuantity)
+	binary.BigEndian.PutUint64(buf[99:107], o.DisplayHighQuantity)
+	binary.BigEndian.PutUint64(buf[107:115], o.MinQuantity)
+	binary.BigEndian.PutUint32(buf[115:119], o.AccountID)
+	buf[119] = 0
+	if o.Quote {
+		buf[119] |= orderFlagQuote
+	}
 }
 
-// unmarshalOrder reads an order from buf (must be at least orderWireSize bytes).
+// orderFlagQuote is the flag bit of matching.Order.Quote.
+const orderFlagQuote = 1 << 0
+
+// unmarshalOrder reads an order from buf (must be at least orderWireSizeV1
+// bytes; fields added later are decoded only if buf is long enough).
 func unmarshalOrder(buf []byte) matching.Order {
-	return matching.Order{
+	o := matching.Order{
 		ID:                 binary.BigEndian.Uint64(buf[0:8]),
 		SymbolID:           binary.BigEndian.Uint32(buf[8:12]),
 		Type:               matching.OrderType(buf[12]),
@@ -102,6 +153,23 @@ func unmarshalOrder(buf []byte) matching.Order {
 		TrailingDistance:   int64(binary.BigEndian.Uint64(buf[71:79])),
 		TrailingStep:       int64(binary.BigEndian.Uint64(buf[79:87])),
 	}
+	if len(buf) >= orderWireSizeV2 {
+		o.ParticipantID = binary.BigEndian.Uint32(buf[87:91])
+	}
+	if len(buf) >= orderWireSizeV3 {
+		o.DisplayLowQuantity = binary.BigEndian.Uint64(buf[91:99])
+		o.DisplayHighQuantity = binary.BigEndian.Uint64(buf[99:107])
+	}
+	if len(buf) >= orderWireSizeV4 {
+		o.MinQuantity = binary.BigEndian.Uint64(buf[107:115])
+	}
+	if len(buf) >= orderWireSizeV5 {
+		o.AccountID = binary.BigEndian.Uint32(buf[115:119])
+	}
+	if len(buf) >= orderWireSize {
+		o.Quote = buf[119]&orderFlagQuote != 0
+	}
+	return o
 }
 
 // encodeEvent encodes a MatchingEvent into a length-prefixed binary record.
@@ -112,8 +180,14 @@ func unmarshalOrder(buf []byte) matching.Order {
 //	1 byte  – EventType
 //	8 bytes – Timestamp (int64 big-endian)
 //	N bytes – event-specific payload
-//	             EventNewOrder:    87 bytes (order)
+//	             EventNewOrder:   120 bytes (order, 119 before the flags,
+//	                              115 before AccountID, 107 before
+//	                              MinQuantity, 91 before the display range,
+//	                              87 before ParticipantID)
 //	             EventCancelOrder:  8 bytes (order ID)
+//	             EventResetSession: 0 bytes
+//	             EventRejectOrder: 121 bytes (order, reject code; 120
+//	                               before the flags, 116 before AccountID)
 func encodeEvent(e MatchingEvent) ([]byte, error) {
 	var payloadSize int
 	switch e.Type {
@@ -121,6 +195,10 @@ func encodeEvent(e MatchingEvent) ([]byte, error) {
 		payloadSize = 1 + 8 + orderWireSize
 	case EventCancelOrder:
 		payloadSize = 1 + 8 + 8
+	case EventResetSession:
+		payloadSize = 1 + 8
+	case EventRejectOrder:
+		payloadSize = 1 + 8 + orderWireSize + 1
 	default:
 		return nil, fmt.Errorf("persistence: unknown EventType %d", e.Type)
 	}
@@ -135,6 +213,9 @@ func encodeEvent(e MatchingEvent) ([]byte, error) {
 		marshalOrder(record[13:], e.Order)
 	case EventCancelOrder:
 		binary.BigEndian.PutUint64(record[13:21], e.OrderID)
+	case EventRejectOrder:
+		marshalOrder(record[13:], e.Order)
+		record[13+orderWireSize] = uint8(e.Reject)
 	}
 	return record, nil
 }
@@ -161,7 +242,7 @@ func decodeEvent(r io.Reader) (MatchingEvent, error) {
 	}
 	switch e.Type {
 	case EventNewOrder:
-		if len(payload) < 9+orderWireSize {
+		if len(payload) < 9+orderWireSizeV1 {
 			return MatchingEvent{}, fmt.Errorf("persistence: short NewOrder payload (%d bytes)", len(payload))
 		}
 		e.Order = unmarshalOrder(payload[9:])
@@ -170,6 +251,15 @@ func decodeEvent(r io.Reader) (MatchingEvent, error) {
 			return MatchingEvent{}, fmt.Errorf("persistence: short CancelOrder payload (%d bytes)", len(payload))
 		}
 		e.OrderID = binary.BigEndian.Uint64(payload[9:17])
+	case EventResetSession:
+	case EventRejectOrder:
+		if len(payload) < 9+orderWireSizeV4+1 {
+			return MatchingEvent{}, fmt.Errorf("persistence: short RejectOrder payload (%d bytes)", len(payload))
+		}
+		// The reject code follows the order, whose size depends on the
+		// version that wrote the record
+		e.Order = unmarshalOrder(payload[9 : len(payload)-1])
+		e.Reject = matching.ErrorCode(payload[len(payload)-1])
 	default:
 		return MatchingEvent{}, fmt.Errorf("persistence: unknown EventType %d", e.Type)
 	}
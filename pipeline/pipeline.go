@@ -0,0 +1,376 @@
+// Package pipeline assembles a live ITCH consumer: a MoldUDP64 receiver, the
+// ITCH parser, a depth of book builder and a strategy, each running on its
+// own goroutine and connected by bounded queues.
+//
+// The receiver detects sequence gaps and duplicate packets and waits for the
+// parser when the packet queue is full. Book updates waiting for a slow
+// strategy are handled by the DepthPolicy: block the parser, drop them, or
+// conflate them by price level. Trades are never dropped. Stats reports the
+// counters, the occupancy of both queues and the latency from the receipt of
+// a packet to the delivery of its events.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tienpsm/go-trader/itch"
+	"github.com/tienpsm/go-trader/marketdata"
+	"github.com/tienpsm/go-trader/metrics"
+	"github.com/tienpsm/go-trader/strategy"
+)
+
+// DepthPolicy selects what happens to a book update when the strategy falls
+// behind
+type DepthPolicy uint8
+
+const (
+	// DepthBlock makes the parser wait for space in the event queue
+	DepthBlock DepthPolicy = iota
+	// DepthDrop drops book updates while the event queue is full. The books
+	// of the strategy miss the dropped changes.
+	DepthDrop
+	// DepthConflate replaces the update of a level still waiting in the
+	// event queue with the new state of the level, so the strategy skips
+	// intermediate states. New levels wait for space when the queue is full.
+	DepthConflate
+)
+
+// String returns the string representation of a DepthPolicy
+func (p DepthPolicy) String() string {
+	switch p {
+	case DepthBlock:
+		return "BLOCK"
+	case DepthDrop:
+		return "DROP"
+	case DepthConflate:
+		return "CONFLATE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Default queue sizes
+const (
+	DefaultPacketQueue = 1024
+	DefaultEventQueue  = 4096
+)
+
+// maxDatagram is the largest UDP payload
+const maxDatagram = 65535
+
+// Config configures a pipeline
+type Config struct {
+	// Date is the trading session date; message timestamps are converted to
+	// times of date, taken at midnight in its location
+	Date time.Time
+	// Broker places the orders of the strategy (optional)
+	Broker strategy.Broker
+	// Next receives every message before the book builder, such as the
+	// simulator of a paper trading session (optional)
+	Next itch.Handler
+	// PacketQueue is the number of packets waiting for the parser
+	// (DefaultPacketQueue when 0)
+	PacketQueue int
+	// EventQueue is the number of events waiting for the strategy
+	// (DefaultEventQueue when 0)
+	EventQueue int
+	// DepthPolicy handles book updates when the event queue is full
+	DepthPolicy DepthPolicy
+	// OnGap is called by the receiver with the sequence numbers of missed
+	// messages, first and last included (optional)
+	OnGap func(first, last uint64)
+	// OnError is called by the parser for messages it cannot parse, which
+	// are skipped (optional)
+	OnError func(err error)
+}
+
+// QueueStats is the occupancy of a queue
+type QueueStats struct {
+	Len int
+	Cap int
+	// HighWater is the largest length reached
+	HighWater int
+}
+
+// Stats are the counters of a pipeline
+type Stats struct {
+	// Packets is the number of packets received, heartbeats included
+	Packets uint64
+	// Messages is the number of messages parsed
+	Messages uint64
+	// Gaps is the number of messages missed in sequence gaps
+	Gaps uint64
+	// Duplicates is the number of messages received more than once and
+	// skipped
+	Duplicates uint64
+	// ParseErrors is the number of messages that could not be parsed
+	ParseErrors uint64
+	// Events is the number of events delivered to the strategy
+	Events uint64
+	// Dropped and Conflated are the book updates handled by the DepthPolicy
+	Dropped   uint64
+	Conflated uint64
+	// LastPacket and LastGap are the times of the last packet and of the
+	// last sequence gap, zero if there was none
+	LastPacket time.Time
+	LastGap    time.Time
+
+	PacketQueue QueueStats
+	EventQueue  QueueStats
+	// Latency is the time from the receipt of a packet to the delivery of
+	// its events
+	Latency metrics.Snapshot
+}
+
+// packet holds the new messages of a received packet
+type packet struct {
+	messages [][]byte
+	received time.Time
+}
+
+// Pipeline runs a strategy on a MoldUDP64 ITCH feed
+type Pipeline struct {
+	// Runner delivers the events to the strategy. Fills of the broker are
+	// passed to Runner.OnFill.
+	Runner *strategy.Runner
+
+	cfg     Config
+	packets chan packet
+	events  *eventQueue
+	latency metrics.Histogram
+
+	// next is the sequence number of the next expected message, 0 before
+	// the first packet
+	next            uint64
+	packetHighWater atomic.Int64
+
+	packetCount atomic.Uint64
+	messages    atomic.Uint64
+	gaps        atomic.Uint64
+	duplicates  atomic.Uint64
+	parseErrors atomic.Uint64
+	delivered   atomic.Uint64
+	dropped     atomic.Uint64
+	conflated   atomic.Uint64
+	// lastPacket and lastGap are Unix nanoseconds, 0 for none
+	lastPacket atomic.Int64
+	lastGap    atomic.Int64
+
+	running atomic.Bool
+}
+
+// New creates a pipeline for a strategy and starts the strategy
+func New(s strategy.Strategy, cfg Config) *Pipeline {
+	if cfg.PacketQueue <= 0 {
+		cfg.PacketQueue = DefaultPacketQueue
+	}
+	if cfg.EventQueue <= 0 {
+		cfg.EventQueue = DefaultEventQueue
+	}
+	return &Pipeline{
+		Runner:  strategy.NewRunner(s, cfg.Broker),
+		cfg:     cfg,
+		packets: make(chan packet, cfg.PacketQueue),
+		events:  newEventQueue(cfg.EventQueue, cfg.DepthPolicy),
+	}
+}
+
+// ListenMulticast joins a multicast group such as "239.1.1.1:30001" on the
+// named interface, or the default one when name is empty
+func ListenMulticast(address, name string) (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	var ifi *net.Interface
+	if name != "" {
+		if ifi, err = net.InterfaceByName(name); err != nil {
+			return nil, err
+		}
+	}
+	return net.ListenMulticastUDP("udp", ifi, addr)
+}
+
+// Run reads packets from r, one per Read call as returned by a UDP
+// connection, until the end-of-session packet, the end of r or ctx ends.
+// Readers with a SetReadDeadline method, such as net.UDPConn, are unblocked
+// when ctx ends. Queued events are delivered to the strategy before Run
+// returns. A pipeline can only be run once.
+func (p *Pipeline) Run(ctx context.Context, r io.Reader) error {
+	if !p.running.CompareAndSwap(false, true) {
+		return errors.New("pipeline: already run")
+	}
+
+	if d, ok := r.(interface{ SetReadDeadline(time.Time) error }); ok {
+		stop := context.AfterFunc(ctx, func() { _ = d.SetReadDeadline(time.Now()) })
+		defer stop()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		p.parse()
+	}()
+	go func() {
+		defer wg.Done()
+		p.deliver()
+	}()
+
+	err := p.receive(ctx, r)
+	close(p.packets)
+	wg.Wait()
+	return err
+}
+
+// receive reads and queues packets until the end of the session
+func (p *Pipeline) receive(ctx context.Context, r io.Reader) error {
+	buf := make([]byte, maxDatagram)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		received := time.Now()
+		p.packetCount.Add(1)
+		p.lastPacket.Store(received.UnixNano())
+
+		mold, err := marketdata.ParseMoldPacket(buf[:n])
+		if err != nil {
+			return fmt.Errorf("pipeline: %w", err)
+		}
+		if mold.IsEndOfSession() {
+			return nil
+		}
+		messages := p.sequence(mold)
+		if len(messages) == 0 {
+			continue
+		}
+
+		// Messages alias buf, which is reused for the next packet
+		data := make([]byte, 0, n)
+		pkt := packet{messages: make([][]byte, len(messages)), received: received}
+		for i, msg := range messages {
+			start := len(data)
+			data = append(data, msg...)
+			pkt.messages[i] = data[start:len(data):len(data)]
+		}
+		select {
+		case p.packets <- pkt:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if n := int64(len(p.packets)); n > p.packetHighWater.Load() {
+			p.packetHighWater.Store(n)
+		}
+	}
+}
+
+// sequence checks the sequence number of a packet and returns its messages
+// not received before
+func (p *Pipeline) sequence(mold marketdata.MoldPacket) [][]byte {
+	if p.next == 0 {
+		p.next = mold.Sequence
+	}
+	if mold.Sequence > p.next {
+		p.gaps.Add(mold.Sequence - p.next)
+		p.lastGap.Store(time.Now().UnixNano())
+		if p.cfg.OnGap != nil {
+			p.cfg.OnGap(p.next, mold.Sequence-1)
+		}
+		p.next = mold.Sequence
+	}
+	messages := mold.Messages
+	if skip := p.next - mold.Sequence; skip > 0 {
+		skip = min(skip, uint64(len(messages)))
+		p.duplicates.Add(skip)
+		messages = messages[skip:]
+	}
+	p.next += uint64(len(messages))
+	return messages
+}
+
+// parse parses the queued packets into events for the strategy
+func (p *Pipeline) parse() {
+	defer p.events.close()
+
+	var received time.Time
+	source := strategy.NewITCHSource(p.cfg.Date, func(e strategy.MarketEvent) {
+		switch p.events.push(e, received) {
+		case dropped:
+			p.dropped.Add(1)
+		case conflated:
+			p.conflated.Add(1)
+		}
+	})
+	source.Next = p.cfg.Next
+	parser := itch.NewParser(source)
+
+	for pkt := range p.packets {
+		received = pkt.received
+		for _, msg := range pkt.messages {
+			if _, err := parser.Parse(msg); err != nil {
+				p.parseErrors.Add(1)
+				if p.cfg.OnError != nil {
+					p.cfg.OnError(err)
+				}
+				continue
+			}
+			p.messages.Add(1)
+		}
+	}
+}
+
+// deliver passes the queued events to the strategy
+func (p *Pipeline) deliver() {
+	for {
+		item, ok := p.events.pop()
+		if !ok {
+			return
+		}
+		p.Runner.OnMarketEvent(item.event)
+		p.latency.Since(item.received)
+		p.delivered.Add(1)
+	}
+}
+
+// Stats returns the counters of the pipeline. It is safe to call while the
+// pipeline runs.
+func (p *Pipeline) Stats() Stats {
+	return Stats{
+		Packets:     p.packetCount.Load(),
+		Messages:    p.messages.Load(),
+		Gaps:        p.gaps.Load(),
+		Duplicates:  p.duplicates.Load(),
+		ParseErrors: p.parseErrors.Load(),
+		Events:      p.delivered.Load(),
+		Dropped:     p.dropped.Load(),
+		Conflated:   p.conflated.Load(),
+		LastPacket:  unixTime(p.lastPacket.Load()),
+		LastGap:     unixTime(p.lastGap.Load()),
+		PacketQueue: QueueStats{Len: len(p.packets), Cap: cap(p.packets), HighWater: int(p.packetHighWater.Load())},
+		EventQueue:  p.events.stats(),
+		Latency:     p.latency.Snapshot(),
+	}
+}
+
+// unixTime converts Unix nanoseconds, 0 being the zero time
+func unixTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
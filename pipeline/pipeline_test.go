@@ -0,0 +1,207 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tienpsm/go-trader/marketdata"
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/strategy"
+)
+
+// packetWriter records every written packet
+type packetWriter struct {
+	packets [][]byte
+}
+
+func (w *packetWriter) Write(p []byte) (int, error) {
+	w.packets = append(w.packets, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+// packetReader returns one packet per Read, like a UDP connection
+type packetReader struct {
+	packets [][]byte
+}
+
+func (r *packetReader) Read(p []byte) (int, error) {
+	if len(r.packets) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.packets[0])
+	r.packets = r.packets[1:]
+	return n, nil
+}
+
+// recorder keeps the book and trades seen by the strategy
+type recorder struct {
+	strategy.DefaultStrategy
+	mu     sync.Mutex
+	book   *strategy.Book
+	trades []strategy.MarketEvent
+}
+
+func (s *recorder) OnBookUpdate(event strategy.MarketEvent, book *strategy.Book) {
+	s.mu.Lock()
+	s.book = book
+	s.mu.Unlock()
+}
+
+func (s *recorder) OnTrade(event strategy.MarketEvent) {
+	s.mu.Lock()
+	s.trades = append(s.trades, event)
+	s.mu.Unlock()
+}
+
+// feed publishes a short session of the engine as MoldUDP64 packets
+func feed(t *testing.T) [][]byte {
+	t.Helper()
+	w := &packetWriter{}
+	pub := marketdata.NewPublisher(w, "TEST")
+	pub.SetMaxPacketSize(100)
+
+	manager := matching.NewMarketManagerWithHandler(pub)
+	manager.EnableMatching()
+	symbol := matching.NewSymbol(1, "AAPL")
+	manager.AddSymbol(symbol)
+	manager.AddOrderBook(symbol)
+
+	for i := uint64(0); i < 5; i++ {
+		manager.AddOrder(*matching.NewLimitOrder(i+1, 1, matching.OrderSideBuy, 10000-i*100, 100))
+		manager.AddOrder(*matching.NewLimitOrder(i+101, 1, matching.OrderSideSell, 10100+i*100, 100))
+	}
+	manager.AddOrder(*matching.NewLimitOrder(201, 1, matching.OrderSideBuy, 10100, 40))
+	manager.DeleteOrder(5)
+	if err := pub.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return w.packets
+}
+
+func TestPipeline(t *testing.T) {
+	for _, policy := range []DepthPolicy{DepthBlock, DepthConflate} {
+		t.Run(policy.String(), func(t *testing.T) {
+			s := &recorder{}
+			p := New(s, Config{Date: time.Now(), EventQueue: 2, DepthPolicy: policy})
+			if err := p.Run(context.Background(), &packetReader{packets: feed(t)}); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			bid, _ := s.book.BestBid()
+			ask, _ := s.book.BestAsk()
+			if bid.Price != 10000 || ask.Price != 10100 || ask.Quantity != 60 {
+				t.Errorf("Expected 10000 / 60 @ 10100, got %+v / %+v", bid, ask)
+			}
+			if len(s.book.Bids(-1)) != 4 || len(s.book.Asks(-1)) != 5 {
+				t.Errorf("Expected 4 bids and 5 asks, got %d and %d", len(s.book.Bids(-1)), len(s.book.Asks(-1)))
+			}
+			// Both executions of the match are printed
+			if len(s.trades) != 2 || s.trades[0].Quantity != 40 {
+				t.Errorf("Expected 2 trades of 40, got %+v", s.trades)
+			}
+
+			stats := p.Stats()
+			if stats.Gaps != 0 || stats.Duplicates != 0 || stats.ParseErrors != 0 || stats.Dropped != 0 {
+				t.Errorf("Expected no gaps, duplicates, errors or drops, got %+v", stats)
+			}
+			// Stock directory, 11 adds, 2 executions, a delete and end of messages
+			if stats.Messages != 16 {
+				t.Errorf("Expected 16 messages, got %d", stats.Messages)
+			}
+			// 11 level additions, 3 level changes by the match and the delete,
+			// and 2 trades
+			if stats.Events+stats.Conflated != 16 {
+				t.Errorf("Expected 16 events, got %d delivered and %d conflated", stats.Events, stats.Conflated)
+			}
+			if stats.EventQueue.Cap != 2 || stats.EventQueue.HighWater > 2 || stats.EventQueue.Len != 0 {
+				t.Errorf("Expected an empty event queue of 2, got %+v", stats.EventQueue)
+			}
+			if stats.Latency.Count != stats.Events {
+				t.Errorf("Expected %d latencies, got %d", stats.Events, stats.Latency.Count)
+			}
+		})
+	}
+}
+
+func TestPipeline_Sequence(t *testing.T) {
+	packets := feed(t)
+	lost, err := marketdata.ParseMoldPacket(packets[1])
+	if err != nil {
+		t.Fatalf("ParseMoldPacket: %v", err)
+	}
+	repeated, err := marketdata.ParseMoldPacket(packets[2])
+	if err != nil {
+		t.Fatalf("ParseMoldPacket: %v", err)
+	}
+	// Lose the second packet and receive the third one twice
+	received := append([][]byte{packets[0], packets[2]}, packets[2:]...)
+
+	var first, last uint64
+	p := New(&recorder{}, Config{
+		OnGap: func(f, l uint64) { first, last = f, l },
+	})
+	if err := p.Run(context.Background(), &packetReader{packets: received}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	stats := p.Stats()
+	if stats.Gaps != uint64(len(lost.Messages)) {
+		t.Errorf("Expected %d missed messages, got %d", len(lost.Messages), stats.Gaps)
+	}
+	if stats.LastGap.IsZero() || stats.LastPacket.Before(stats.LastGap) {
+		t.Errorf("Expected the gap time before the last packet, got %v and %v", stats.LastGap, stats.LastPacket)
+	}
+	if first != lost.Sequence || last != repeated.Sequence-1 {
+		t.Errorf("Expected gap %d-%d, got %d-%d", lost.Sequence, repeated.Sequence-1, first, last)
+	}
+	if stats.Duplicates != uint64(len(repeated.Messages)) {
+		t.Errorf("Expected %d duplicates, got %d", len(repeated.Messages), stats.Duplicates)
+	}
+	if stats.Packets != uint64(len(received)) {
+		t.Errorf("Expected %d packets, got %d", len(received), stats.Packets)
+	}
+}
+
+func TestEventQueue_Policies(t *testing.T) {
+	update := func(price, quantity uint64, action strategy.BookAction) strategy.MarketEvent {
+		return strategy.MarketEvent{Type: strategy.EventBookUpdate, Symbol: "AAPL", Side: matching.OrderSideBuy, Action: action, Price: price, Quantity: quantity}
+	}
+	now := time.Now()
+
+	q := newEventQueue(2, DepthDrop)
+	q.push(update(100, 10, strategy.BookAdd), now)
+	q.push(update(101, 10, strategy.BookAdd), now)
+	if r := q.push(update(102, 10, strategy.BookAdd), now); r != dropped {
+		t.Errorf("Expected the update to be dropped, got %d", r)
+	}
+
+	q = newEventQueue(2, DepthConflate)
+	q.push(update(100, 10, strategy.BookAdd), now)
+	q.push(update(101, 10, strategy.BookAdd), now)
+	if r := q.push(update(100, 30, strategy.BookUpdate), now); r != conflated {
+		t.Errorf("Expected the update to be conflated, got %d", r)
+	}
+	item, _ := q.pop()
+	if item.event.Price != 100 || item.event.Quantity != 30 || item.event.Action != strategy.BookAdd {
+		t.Errorf("Expected the level added with 30, got %+v", item.event)
+	}
+	// The level was delivered, so its next update is queued again
+	if r := q.push(update(100, 20, strategy.BookUpdate), now); r != pushed {
+		t.Errorf("Expected the update to be queued, got %d", r)
+	}
+	q.close()
+	var prices []uint64
+	for {
+		item, ok := q.pop()
+		if !ok {
+			break
+		}
+		prices = append(prices, item.event.Price)
+	}
+	if len(prices) != 2 || prices[0] != 101 || prices[1] != 100 {
+		t.Errorf("Expected 101 then 100, got %v", prices)
+	}
+}
@@ -0,0 +1,144 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/strategy"
+)
+
+// levelKey identifies the price level of a book update
+type levelKey struct {
+	symbol string
+	side   matching.OrderSide
+	price  uint64
+}
+
+// queued is an event waiting for the strategy with the receive time of its
+// packet
+type queued struct {
+	event    strategy.MarketEvent
+	received time.Time
+}
+
+// pushResult is the outcome of eventQueue.push
+type pushResult uint8
+
+const (
+	pushed pushResult = iota
+	dropped
+	conflated
+)
+
+// eventQueue is a bounded FIFO of market events applying a DepthPolicy to
+// book updates. Trades always wait for space.
+type eventQueue struct {
+	policy DepthPolicy
+
+	mu       sync.Mutex
+	notEmpty sync.Cond
+	notFull  sync.Cond
+	items    []queued
+	// head and tail are absolute positions, items[pos%len(items)]
+	head, tail uint64
+	// pending is the position of the waiting update of every level, kept
+	// with DepthConflate only
+	pending   map[levelKey]uint64
+	closed    bool
+	highWater int
+}
+
+// newEventQueue creates a queue holding up to size events
+func newEventQueue(size int, policy DepthPolicy) *eventQueue {
+	q := &eventQueue{policy: policy, items: make([]queued, size)}
+	q.notEmpty.L = &q.mu
+	q.notFull.L = &q.mu
+	if policy == DepthConflate {
+		q.pending = make(map[levelKey]uint64)
+	}
+	return q
+}
+
+// key returns the level of a book update
+func key(e strategy.MarketEvent) levelKey {
+	return levelKey{symbol: e.Symbol, side: e.Side, price: e.Price}
+}
+
+// push queues an event, waiting for space unless the event is a book update
+// that is dropped or conflated
+func (q *eventQueue) push(e strategy.MarketEvent, received time.Time) pushResult {
+	update := e.Type == strategy.EventBookUpdate
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if update && q.pending != nil {
+		if pos, ok := q.pending[key(e)]; ok {
+			item := &q.items[pos%uint64(len(q.items))]
+			// The strategy has not seen the level yet, so it is still added
+			if item.event.Action == strategy.BookAdd && e.Action == strategy.BookUpdate {
+				e.Action = strategy.BookAdd
+			}
+			item.event = e
+			return conflated
+		}
+	}
+	for q.len() == len(q.items) {
+		if update && q.policy == DepthDrop {
+			return dropped
+		}
+		q.notFull.Wait()
+	}
+
+	q.items[q.tail%uint64(len(q.items))] = queued{event: e, received: received}
+	if update && q.pending != nil {
+		q.pending[key(e)] = q.tail
+	}
+	q.tail++
+	q.highWater = max(q.highWater, q.len())
+	q.notEmpty.Signal()
+	return pushed
+}
+
+// pop removes the oldest event, waiting for one. It returns false once the
+// queue is closed and empty.
+func (q *eventQueue) pop() (queued, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.len() == 0 {
+		if q.closed {
+			return queued{}, false
+		}
+		q.notEmpty.Wait()
+	}
+
+	item := q.items[q.head%uint64(len(q.items))]
+	if q.pending != nil && item.event.Type == strategy.EventBookUpdate {
+		if pos, ok := q.pending[key(item.event)]; ok && pos == q.head {
+			delete(q.pending, key(item.event))
+		}
+	}
+	q.head++
+	q.notFull.Signal()
+	return item, true
+}
+
+// close wakes up pop once the remaining events are consumed
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.mu.Unlock()
+}
+
+// stats returns the occupancy of the queue
+func (q *eventQueue) stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return QueueStats{Len: q.len(), Cap: len(q.items), HighWater: q.highWater}
+}
+
+// len returns the number of queued events. Must be called with q.mu held.
+func (q *eventQueue) len() int {
+	return int(q.tail - q.head)
+}
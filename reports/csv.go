@@ -0,0 +1,113 @@
+package reports
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// csvHeader is the header row of the CSV blotter
+var csvHeader = []string{
+	"exec_id", "trade_date", "trade_time", "symbol_id", "symbol", "price", "quantity", "aggressor",
+	"buy_order_id", "buy_participant", "sell_order_id", "sell_participant",
+}
+
+// csvRecord converts a report into a blotter row. Trade times are UTC with
+// nanoseconds.
+func csvRecord(r TradeReport) []string {
+	t := r.TradeTime.UTC()
+	return []string{
+		r.ExecID,
+		t.Format("20060102"),
+		t.Format(time.RFC3339Nano),
+		strconv.FormatUint(uint64(r.SymbolID), 10),
+		r.Symbol,
+		strconv.FormatUint(r.Price, 10),
+		strconv.FormatUint(r.Quantity, 10),
+		r.Aggressor.String(),
+		strconv.FormatUint(r.BuyOrderID, 10),
+		strconv.FormatUint(uint64(r.BuyParticipant), 10),
+		strconv.FormatUint(r.SellOrderID, 10),
+		strconv.FormatUint(uint64(r.SellParticipant), 10),
+	}
+}
+
+// WriteCSV writes reports as a CSV blotter with a header row
+func WriteCSV(w io.Writer, reports []TradeReport) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range reports {
+		if err := cw.Write(csvRecord(r)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// CSVSink is a sink appending reports to a CSV blotter. Rows are flushed to
+// the underlying writer after every report.
+type CSVSink struct {
+	mu     sync.Mutex
+	writer *csv.Writer
+	closer io.Closer
+}
+
+// NewCSVSink creates a sink writing a new blotter, header first, to w
+func NewCSVSink(w io.Writer) (*CSVSink, error) {
+	s := &CSVSink{writer: csv.NewWriter(w)}
+	if err := s.writer.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	s.writer.Flush()
+	return s, s.writer.Error()
+}
+
+// OpenCSVFile opens (or creates) a blotter file and appends to it. The header
+// is written when the file is empty.
+func OpenCSVFile(path string) (*CSVSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	s := &CSVSink{writer: csv.NewWriter(f), closer: f}
+	if info.Size() == 0 {
+		if err := s.writer.Write(csvHeader); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Write implements Sink
+func (s *CSVSink) Write(report TradeReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.writer.Write(csvRecord(report)); err != nil {
+		return err
+	}
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+// Close closes the file of a sink opened with OpenCSVFile
+func (s *CSVSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writer.Flush()
+	if s.closer == nil {
+		return s.writer.Error()
+	}
+	return s.closer.Close()
+}
@@ -0,0 +1,175 @@
+package reports
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// fixTimeFormat is the FIX UTCTimestamp format with milliseconds
+const fixTimeFormat = "20060102-15:04:05.000"
+
+// FIXConfig is the session and format configuration of FIX reports
+type FIXConfig struct {
+	SenderCompID string
+	TargetCompID string
+	// PriceDecimals is the number of implied decimals of engine prices,
+	// 2 for prices in cents
+	PriceDecimals int
+}
+
+// FormatTradeCaptureReport encodes a report as a FIX 4.4
+// TradeCaptureReport (35=AE) message with sequence number seq, sent at
+// sendingTime. Both sides are reported, with the participant as executing
+// firm party when it is known.
+func FormatTradeCaptureReport(cfg FIXConfig, seq uint64, sendingTime time.Time, r TradeReport) []byte {
+	var body bytes.Buffer
+	field := func(tag int, value string) {
+		body.WriteString(strconv.Itoa(tag))
+		body.WriteByte('=')
+		body.WriteString(value)
+		body.WriteByte(0x01)
+	}
+	field(35, "AE")
+	field(49, cfg.SenderCompID)
+	field(56, cfg.TargetCompID)
+	field(34, strconv.FormatUint(seq, 10))
+	field(52, sendingTime.UTC().Format(fixTimeFormat))
+	field(571, r.ExecID)
+	field(487, "0") // TradeReportTransType: new
+	field(856, "0") // TradeReportType: submit
+	field(570, "N") // PreviouslyReported
+	field(17, r.ExecID)
+	field(55, r.Symbol)
+	field(32, strconv.FormatUint(r.Quantity, 10))
+	field(31, formatPrice(r.Price, cfg.PriceDecimals))
+	field(75, r.TradeTime.UTC().Format("20060102"))
+	field(60, r.TradeTime.UTC().Format(fixTimeFormat))
+	field(552, "2")
+	sides := []struct {
+		side        matching.OrderSide
+		orderID     uint64
+		participant uint32
+	}{
+		{matching.OrderSideBuy, r.BuyOrderID, r.BuyParticipant},
+		{matching.OrderSideSell, r.SellOrderID, r.SellParticipant},
+	}
+	for _, s := range sides {
+		if s.side == matching.OrderSideBuy {
+			field(54, "1")
+		} else {
+			field(54, "2")
+		}
+		field(37, strconv.FormatUint(s.orderID, 10))
+		if s.participant != 0 {
+			field(453, "1")
+			field(448, strconv.FormatUint(uint64(s.participant), 10))
+			field(447, "D") // Proprietary/custom code
+			field(452, "1") // Executing firm
+		}
+		if s.side == r.Aggressor {
+			field(1057, "Y")
+		} else {
+			field(1057, "N")
+		}
+	}
+
+	var msg bytes.Buffer
+	msg.WriteString("8=FIX.4.4\x01")
+	msg.WriteString("9=" + strconv.Itoa(body.Len()) + "\x01")
+	msg.Write(body.Bytes())
+	var sum byte
+	for _, b := range msg.Bytes() {
+		sum += b
+	}
+	fmt.Fprintf(&msg, "10=%03d\x01", sum)
+	return msg.Bytes()
+}
+
+// formatPrice formats a price with implied decimals
+func formatPrice(price uint64, decimals int) string {
+	s := strconv.FormatUint(price, 10)
+	if decimals <= 0 {
+		return s
+	}
+	for len(s) <= decimals {
+		s = "0" + s
+	}
+	return s[:len(s)-decimals] + "." + s[len(s)-decimals:]
+}
+
+// FIXSink is a sink writing TradeCaptureReport messages, one per line, with
+// consecutive sequence numbers
+type FIXSink struct {
+	// Now returns the sending time of messages (time.Now when nil)
+	Now func() time.Time
+
+	cfg FIXConfig
+
+	mu     sync.Mutex
+	seq    uint64
+	writer *bufio.Writer
+	closer io.Closer
+}
+
+// NewFIXSink creates a sink writing to w, numbering messages from 1
+func NewFIXSink(w io.Writer, cfg FIXConfig) *FIXSink {
+	return &FIXSink{cfg: cfg, writer: bufio.NewWriter(w)}
+}
+
+// OpenFIXFile opens (or creates) a file of FIX messages and appends to it.
+// Sequence numbers continue after seq, the last number already written.
+func OpenFIXFile(path string, cfg FIXConfig, seq uint64) (*FIXSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s := NewFIXSink(f, cfg)
+	s.seq = seq
+	s.closer = f
+	return s, nil
+}
+
+// Write implements Sink
+func (s *FIXSink) Write(report TradeReport) error {
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	msg := FormatTradeCaptureReport(s.cfg, s.seq, now, report)
+	if _, err := s.writer.Write(append(msg, '\n')); err != nil {
+		return err
+	}
+	return s.writer.Flush()
+}
+
+// Sequence returns the sequence number of the last message written
+func (s *FIXSink) Sequence() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seq
+}
+
+// Close flushes the sink and closes the file of a sink opened with
+// OpenFIXFile
+func (s *FIXSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
@@ -0,0 +1,190 @@
+// Package reports converts the trades of the matching engine into trade
+// reports: FIX 4.4 TradeCaptureReport messages and a regulatory-style CSV
+// blotter. A Reporter installed as the engine's handler builds a TradeReport
+// for every trade and writes it to a Sink: a FIX or CSV file, or a Memory
+// sink whose reports are returned through the API.
+package reports
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// TradeReport is a trade with the details needed to report it
+type TradeReport struct {
+	// ExecID identifies the trade, unique per Reporter prefix
+	ExecID   string
+	SymbolID uint32
+	Symbol   string
+	Price    uint64
+	Quantity uint64
+	// Aggressor is the side of the order that took liquidity
+	Aggressor       matching.OrderSide
+	BuyOrderID      uint64
+	BuyParticipant  uint32
+	SellOrderID     uint64
+	SellParticipant uint32
+	// TradeTime is the time the trade was matched
+	TradeTime time.Time
+}
+
+// Sink receives trade reports
+type Sink interface {
+	Write(report TradeReport) error
+}
+
+// Reporter is a matching.MarketHandler that writes a report of every trade to
+// a sink and forwards all events to the wrapped handler.
+//
+// Handler callbacks cannot return errors, so the first failed write is kept
+// and reported by Err.
+type Reporter struct {
+	matching.MarketHandler
+
+	// Now returns the time of trades (time.Now when nil)
+	Now func() time.Time
+
+	sink    Sink
+	prefix  string
+	nextID  uint64
+	symbols map[uint32]string
+	// buy and sell are the last executions of each side, which precede
+	// the trade they belong to
+	buy, sell matching.Order
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewReporter creates a reporter writing to sink and forwarding events to
+// next. Exec IDs are prefix followed by a counter, so the prefix must differ
+// between sessions. A nil next is replaced by a no-op handler.
+func NewReporter(sink Sink, prefix string, next matching.MarketHandler) *Reporter {
+	if next == nil {
+		next = &matching.DefaultMarketHandler{}
+	}
+	return &Reporter{
+		MarketHandler: next,
+		sink:          sink,
+		prefix:        prefix,
+		symbols:       make(map[uint32]string),
+	}
+}
+
+// AddSymbol registers the name of a symbol added before the reporter was
+// installed
+func (r *Reporter) AddSymbol(symbol matching.Symbol) {
+	r.symbols[symbol.ID] = symbol.Name
+}
+
+// OnAddSymbol registers the symbol name and forwards the event
+func (r *Reporter) OnAddSymbol(symbol matching.Symbol) {
+	r.AddSymbol(symbol)
+	r.MarketHandler.OnAddSymbol(symbol)
+}
+
+// OnExecuteOrder remembers the execution for its trade and forwards the event
+func (r *Reporter) OnExecuteOrder(order matching.Order, price, quantity uint64) {
+	if order.IsBuy() {
+		r.buy = order
+	} else {
+		r.sell = order
+	}
+	r.MarketHandler.OnExecuteOrder(order, price, quantity)
+}
+
+// OnTrade writes the trade report and forwards the event
+func (r *Reporter) OnTrade(trade matching.Trade) {
+	r.nextID++
+	report := TradeReport{
+		ExecID:      r.prefix + strconv.FormatUint(r.nextID, 10),
+		SymbolID:    trade.SymbolID,
+		Symbol:      r.symbols[trade.SymbolID],
+		Price:       trade.Price,
+		Quantity:    trade.Quantity,
+		Aggressor:   trade.Aggressor,
+		BuyOrderID:  trade.BuyOrderID,
+		SellOrderID: trade.SellOrderID,
+		TradeTime:   r.now(),
+	}
+	if r.buy.ID == trade.BuyOrderID {
+		report.BuyParticipant = r.buy.ParticipantID
+	}
+	if r.sell.ID == trade.SellOrderID {
+		report.SellParticipant = r.sell.ParticipantID
+	}
+	if err := r.sink.Write(report); err != nil {
+		r.mu.Lock()
+		if r.err == nil {
+			r.err = err
+		}
+		r.mu.Unlock()
+	}
+	r.MarketHandler.OnTrade(trade)
+}
+
+// Err returns the first error encountered while writing reports
+func (r *Reporter) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// now returns the current time
+func (r *Reporter) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// Memory is a sink keeping the reports in memory
+type Memory struct {
+	mu      sync.Mutex
+	reports []TradeReport
+}
+
+// Write implements Sink
+func (m *Memory) Write(report TradeReport) error {
+	m.mu.Lock()
+	m.reports = append(m.reports, report)
+	m.mu.Unlock()
+	return nil
+}
+
+// Reports returns the reports of trades in [from, to), in trade order
+func (m *Memory) Reports(from, to time.Time) []TradeReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []TradeReport
+	for _, report := range m.reports {
+		if !report.TradeTime.Before(from) && report.TradeTime.Before(to) {
+			result = append(result, report)
+		}
+	}
+	return result
+}
+
+// Len returns the number of reports
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.reports)
+}
+
+// MultiSink writes every report to all sinks, returning the first error
+type MultiSink []Sink
+
+// Write implements Sink
+func (s MultiSink) Write(report TradeReport) error {
+	var first error
+	for _, sink := range s {
+		if err := sink.Write(report); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,131 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+var tradeTime = time.Date(2024, 3, 1, 14, 30, 0, 123456789, time.UTC)
+
+// trade matches a resting sell of participant 7 with a buy of participant 8
+func trade(t *testing.T, sink Sink) *Reporter {
+	t.Helper()
+	r := NewReporter(sink, "T-", nil)
+	r.Now = func() time.Time { return tradeTime }
+	mm := matching.NewMarketManagerWithHandler(r)
+	mm.EnableMatching()
+	symbol := matching.NewSymbol(1, "AAPL")
+	mm.AddSymbol(symbol)
+	mm.AddOrderBook(symbol)
+
+	sell := matching.NewLimitOrder(1, 1, matching.OrderSideSell, 15025, 100)
+	sell.ParticipantID = 7
+	buy := matching.NewLimitOrder(2, 1, matching.OrderSideBuy, 15030, 60)
+	buy.ParticipantID = 8
+	mm.AddOrder(*sell)
+	mm.AddOrder(*buy)
+	return r
+}
+
+func TestReporter(t *testing.T) {
+	memory := &Memory{}
+	r := trade(t, memory)
+	if r.Err() != nil {
+		t.Fatalf("Err: %v", r.Err())
+	}
+	reports := memory.Reports(tradeTime, tradeTime.Add(time.Second))
+	if len(reports) != 1 {
+		t.Fatalf("Expected 1 report, got %d", len(reports))
+	}
+	expected := TradeReport{
+		ExecID: "T-1", SymbolID: 1, Symbol: "AAPL", Price: 15025, Quantity: 60, Aggressor: matching.OrderSideBuy,
+		BuyOrderID: 2, BuyParticipant: 8, SellOrderID: 1, SellParticipant: 7, TradeTime: tradeTime,
+	}
+	if reports[0] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, reports[0])
+	}
+	if got := memory.Reports(tradeTime.Add(time.Second), tradeTime.Add(time.Hour)); len(got) != 0 {
+		t.Errorf("Expected no reports after the trade, got %+v", got)
+	}
+}
+
+func TestFormatTradeCaptureReport(t *testing.T) {
+	memory := &Memory{}
+	trade(t, memory)
+	report := memory.Reports(tradeTime, tradeTime.Add(time.Second))[0]
+
+	msg := FormatTradeCaptureReport(FIXConfig{SenderCompID: "EXCH", TargetCompID: "REG", PriceDecimals: 2}, 5, tradeTime, report)
+	fields := strings.Split(strings.TrimSuffix(string(msg), "\x01"), "\x01")
+	want := []string{
+		"8=FIX.4.4", "", "35=AE", "49=EXCH", "56=REG", "34=5", "52=20240301-14:30:00.123",
+		"571=T-1", "487=0", "856=0", "570=N", "17=T-1", "55=AAPL", "32=60", "31=150.25",
+		"75=20240301", "60=20240301-14:30:00.123", "552=2",
+		"54=1", "37=2", "453=1", "448=8", "447=D", "452=1", "1057=Y",
+		"54=2", "37=1", "453=1", "448=7", "447=D", "452=1", "1057=N",
+	}
+	if len(fields) != len(want)+1 {
+		t.Fatalf("Expected %d fields, got %q", len(want)+1, fields)
+	}
+	for i, w := range want {
+		if w != "" && fields[i] != w {
+			t.Errorf("Field %d: expected %s, got %s", i, w, fields[i])
+		}
+	}
+
+	// Body length counts from after 9= to the checksum, and the checksum is
+	// the byte sum up to it modulo 256
+	header := len("8=FIX.4.4\x01") + len(fields[1]) + 1
+	trailer := strings.LastIndex(string(msg), "10=")
+	if fields[1] != "9="+strconv.Itoa(trailer-header) {
+		t.Errorf("Expected body length %d, got %s", trailer-header, fields[1])
+	}
+	var sum byte
+	for _, b := range msg[:trailer] {
+		sum += b
+	}
+	if checksum := fields[len(fields)-1]; checksum != fmt.Sprintf("10=%03d", sum) {
+		t.Errorf("Expected checksum %03d, got %s", sum, checksum)
+	}
+}
+
+func TestCSVFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blotter.csv")
+	for i := 0; i < 2; i++ {
+		sink, err := OpenCSVFile(path)
+		if err != nil {
+			t.Fatalf("OpenCSVFile: %v", err)
+		}
+		if r := trade(t, sink); r.Err() != nil {
+			t.Fatalf("Err: %v", r.Err())
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	// The header is only written to the new file
+	if len(rows) != 3 || rows[0][0] != "exec_id" {
+		t.Fatalf("Expected a header and 2 rows, got %q", rows)
+	}
+	want := []string{"T-1", "20240301", "2024-03-01T14:30:00.123456789Z", "1", "AAPL", "15025", "60", "BUY", "2", "8", "1", "7"}
+	if strings.Join(rows[1], ",") != strings.Join(want, ",") {
+		t.Errorf("Expected row %q, got %q", want, rows[1])
+	}
+}
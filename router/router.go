@@ -0,0 +1,274 @@
+// Package router splits parent orders across several order books (venues)
+// and sends the resulting child orders to them. How a parent is split is
+// chosen by a pluggable Strategy: Sweep takes the best prices first,
+// Proportional follows the displayed liquidity, and Iceberg releases a parent
+// in slices over time. Venues are matching.MarketManager books (BookVenue) or
+// gateway servers (GatewayVenue).
+package router
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+var (
+	// ErrNoVenues is returned when routing without venues
+	ErrNoVenues = errors.New("router: no venues")
+	// ErrInvalidOrder is returned for parent orders without quantity
+	ErrInvalidOrder = errors.New("router: invalid order")
+	// ErrDuplicateOrder is returned for parent IDs of routes in progress
+	ErrDuplicateOrder = errors.New("router: duplicate order")
+)
+
+// Level is a price level of a venue
+type Level struct {
+	Price    uint64
+	Quantity uint64
+}
+
+// Parent is an order to split across venues
+type Parent struct {
+	ID     uint64
+	Symbol string
+	Side   matching.OrderSide
+	// Market sends market child orders instead of limit orders at Price
+	Market   bool
+	Price    uint64
+	Quantity uint64
+}
+
+// marketable reports whether the parent can take a level at price
+func (p Parent) marketable(price uint64) bool {
+	if p.Market {
+		return true
+	}
+	if p.Side == matching.OrderSideBuy {
+		return price <= p.Price
+	}
+	return price >= p.Price
+}
+
+// Child is an order sent to a venue for a part of a parent
+type Child struct {
+	ID       uint64
+	ParentID uint64
+	Venue    string
+	Symbol   string
+	Side     matching.OrderSide
+	Market   bool
+	Price    uint64
+	Quantity uint64
+}
+
+// Allocation is the quantity of a wave given to a venue
+type Allocation struct {
+	// Venue is the index of the venue in the router
+	Venue    int
+	Quantity uint64
+}
+
+// Venue is an order book child orders are sent to
+type Venue interface {
+	// Name identifies the venue in child orders
+	Name() string
+	// Depth returns the levels of symbol an order of side would take, best
+	// first: the asks for a buy and the bids for a sell
+	Depth(symbol string, side matching.OrderSide) ([]Level, error)
+	// Send enters a child order
+	Send(child Child) error
+}
+
+// Strategy splits the quantity of a wave of a parent across venues
+type Strategy interface {
+	// Allocate splits quantity given the depth of every venue, indexed like
+	// the venues of the router. The allocations should add up to quantity.
+	Allocate(parent Parent, quantity uint64, depth [][]Level) []Allocation
+}
+
+// Slicer is implemented by strategies releasing a parent over time
+type Slicer interface {
+	// Slice returns the quantity of the next wave of a route and the time of
+	// the wave after it, or the zero time if it is the last one
+	Slice(route *Route, now time.Time) (uint64, time.Time)
+}
+
+// Route is the state of a parent order being routed
+type Route struct {
+	Parent Parent
+	// Routed is the quantity sent to venues so far
+	Routed   uint64
+	Children []Child
+	// Next is the time of the next wave, or zero when the route is done
+	Next time.Time
+}
+
+// Remaining returns the quantity not routed yet
+func (r *Route) Remaining() uint64 {
+	return r.Parent.Quantity - r.Routed
+}
+
+// Router sends the child orders of parent orders to its venues. A Router is
+// not safe for concurrent use.
+type Router struct {
+	// Strategy splits the parent orders
+	Strategy Strategy
+	// NextID returns the ID of a new child order (1, 2, ... when nil)
+	NextID func() uint64
+	// Now returns the current time (time.Now when nil)
+	Now func() time.Time
+	// OnChild is called for every child order sent (optional)
+	OnChild func(Child)
+
+	venues []Venue
+	lastID uint64
+	routes map[uint64]*Route
+}
+
+// New creates a router splitting orders with strategy across venues
+func New(strategy Strategy, venues ...Venue) *Router {
+	return &Router{
+		Strategy: strategy,
+		venues:   venues,
+		routes:   make(map[uint64]*Route),
+	}
+}
+
+// Venues returns the venues of the router
+func (r *Router) Venues() []Venue {
+	return r.venues
+}
+
+// Route starts routing a parent order and sends its first wave. Routes of
+// slicing strategies stay active until their last wave is sent by Tick.
+func (r *Router) Route(parent Parent) (*Route, error) {
+	if len(r.venues) == 0 {
+		return nil, ErrNoVenues
+	}
+	if parent.Quantity == 0 {
+		return nil, ErrInvalidOrder
+	}
+	if _, ok := r.routes[parent.ID]; ok {
+		return nil, ErrDuplicateOrder
+	}
+	route := &Route{Parent: parent}
+	err := r.step(route, r.now())
+	if !route.Next.IsZero() {
+		r.routes[parent.ID] = route
+	}
+	return route, err
+}
+
+// Tick sends the waves of the active routes that are due
+func (r *Router) Tick() error {
+	now := r.now()
+	var errs []error
+	for id, route := range r.routes {
+		if now.Before(route.Next) {
+			continue
+		}
+		if err := r.step(route, now); err != nil {
+			errs = append(errs, err)
+		}
+		if route.Next.IsZero() {
+			delete(r.routes, id)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Active returns the route of a parent order with waves left
+func (r *Router) Active(id uint64) (*Route, bool) {
+	route, ok := r.routes[id]
+	return route, ok
+}
+
+// Cancel stops sending waves of a parent order. Child orders already sent
+// are left to their venues.
+func (r *Router) Cancel(id uint64) bool {
+	route, ok := r.routes[id]
+	if !ok {
+		return false
+	}
+	route.Next = time.Time{}
+	delete(r.routes, id)
+	return true
+}
+
+// now returns the current time
+func (r *Router) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// nextID returns the ID of a new child order
+func (r *Router) nextID() uint64 {
+	if r.NextID != nil {
+		return r.NextID()
+	}
+	r.lastID++
+	return r.lastID
+}
+
+// step sends the next wave of a route and schedules the one after it. A
+// failed wave ends the route.
+func (r *Router) step(route *Route, now time.Time) error {
+	quantity := route.Remaining()
+	route.Next = time.Time{}
+	if slicer, ok := r.Strategy.(Slicer); ok {
+		quantity, route.Next = slicer.Slice(route, now)
+	}
+	if err := r.wave(route, quantity); err != nil {
+		route.Next = time.Time{}
+		return err
+	}
+	if route.Remaining() == 0 {
+		route.Next = time.Time{}
+	}
+	return nil
+}
+
+// wave splits quantity of a route and sends the child orders
+func (r *Router) wave(route *Route, quantity uint64) error {
+	if quantity == 0 {
+		return nil
+	}
+	parent := route.Parent
+	depth := make([][]Level, len(r.venues))
+	for i, venue := range r.venues {
+		levels, err := venue.Depth(parent.Symbol, parent.Side)
+		if err != nil {
+			return fmt.Errorf("router: depth of %s: %w", venue.Name(), err)
+		}
+		depth[i] = levels
+	}
+	for _, a := range r.Strategy.Allocate(parent, quantity, depth) {
+		if a.Quantity == 0 {
+			continue
+		}
+		venue := r.venues[a.Venue]
+		child := Child{
+			ID:       r.nextID(),
+			ParentID: parent.ID,
+			Venue:    venue.Name(),
+			Symbol:   parent.Symbol,
+			Side:     parent.Side,
+			Market:   parent.Market,
+			Price:    parent.Price,
+			Quantity: a.Quantity,
+		}
+		if err := venue.Send(child); err != nil {
+			return fmt.Errorf("router: child %d to %s: %w", child.ID, venue.Name(), err)
+		}
+		route.Routed += a.Quantity
+		route.Children = append(route.Children, child)
+		if r.OnChild != nil {
+			r.OnChild(child)
+		}
+	}
+	return nil
+}
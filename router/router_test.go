@@ -0,0 +1,142 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// newVenue creates a book venue with asks resting at the given prices and
+// quantities
+func newVenue(t *testing.T, name string, asks ...uint64) (*BookVenue, *matching.MarketManager) {
+	t.Helper()
+	mm := matching.NewMarketManager()
+	mm.EnableMatching()
+	symbol := matching.NewSymbol(1, "AAPL")
+	mm.AddSymbol(symbol)
+	mm.AddOrderBook(symbol)
+	for i := 0; i+1 < len(asks); i += 2 {
+		order := matching.NewLimitOrder(uint64(1000+i), 1, matching.OrderSideSell, asks[i], asks[i+1])
+		if err := mm.AddOrder(*order); err != matching.ErrorOK {
+			t.Fatalf("AddOrder: %v", err)
+		}
+	}
+	return NewBookVenue(name, mm), mm
+}
+
+func byVenue(children []Child) map[string]uint64 {
+	result := make(map[string]uint64)
+	for _, child := range children {
+		result[child.Venue] += child.Quantity
+	}
+	return result
+}
+
+func TestRouter_Sweep(t *testing.T) {
+	a, mmA := newVenue(t, "A", 10000, 100, 10200, 100)
+	b, mmB := newVenue(t, "B", 10100, 50)
+	r := New(Sweep{}, a, b)
+
+	// Best prices first: 100 @ 10000 on A, 50 @ 10100 on B, 30 @ 10200 on A
+	route, err := r.Route(Parent{ID: 1, Symbol: "AAPL", Side: matching.OrderSideBuy, Price: 10200, Quantity: 180})
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+	if got := byVenue(route.Children); got["A"] != 130 || got["B"] != 50 {
+		t.Errorf("Expected 130 to A and 50 to B, got %v", got)
+	}
+	if route.Remaining() != 0 || !route.Next.IsZero() {
+		t.Errorf("Expected route done, got %+v", route)
+	}
+	if ask := mmA.GetOrderBook(1).BestAsk(); ask == nil || ask.Price != 10200 || ask.VisibleVolume != 70 {
+		t.Errorf("Expected 70 left @ 10200 on A, got %+v", ask)
+	}
+	if ask := mmB.GetOrderBook(1).BestAsk(); ask != nil {
+		t.Errorf("Expected B swept, got %+v", ask)
+	}
+
+	// Unmarketable quantity rests on the venue with the best price
+	route, err = r.Route(Parent{ID: 2, Symbol: "AAPL", Side: matching.OrderSideBuy, Price: 10100, Quantity: 40})
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+	if len(route.Children) != 1 || route.Children[0].Venue != "A" {
+		t.Errorf("Expected the order resting on A, got %+v", route.Children)
+	}
+	if bid := mmA.GetOrderBook(1).BestBid(); bid == nil || bid.Price != 10100 || bid.VisibleVolume != 40 {
+		t.Errorf("Expected bid 40 @ 10100 on A, got %+v", bid)
+	}
+}
+
+func TestRouter_Proportional(t *testing.T) {
+	a, _ := newVenue(t, "A", 10000, 300)
+	b, _ := newVenue(t, "B", 10000, 100, 10500, 600)
+	r := New(Proportional{}, a, b)
+
+	// The ask at 10500 is above the limit, so A has 3/4 of the liquidity
+	route, err := r.Route(Parent{ID: 1, Symbol: "AAPL", Side: matching.OrderSideBuy, Price: 10000, Quantity: 201})
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+	if got := byVenue(route.Children); got["A"] != 151 || got["B"] != 50 {
+		t.Errorf("Expected 151 to A and 50 to B, got %v", got)
+	}
+
+	if _, err := r.Route(Parent{ID: 2, Symbol: "AAPL", Quantity: 0}); err != ErrInvalidOrder {
+		t.Errorf("Expected ErrInvalidOrder, got %v", err)
+	}
+	if _, err := New(Sweep{}).Route(Parent{ID: 3, Quantity: 10}); err != ErrNoVenues {
+		t.Errorf("Expected ErrNoVenues, got %v", err)
+	}
+	if _, err := r.Route(Parent{ID: 4, Symbol: "MSFT", Side: matching.OrderSideBuy, Price: 10000, Quantity: 10}); err == nil {
+		t.Errorf("Expected an error for an unknown symbol")
+	}
+}
+
+func TestRouter_Iceberg(t *testing.T) {
+	a, _ := newVenue(t, "A", 10000, 1000)
+	now := time.Date(2024, 3, 1, 14, 30, 0, 0, time.UTC)
+	r := New(Iceberg{Strategy: Sweep{}, Display: 100, Interval: time.Minute}, a)
+	r.Now = func() time.Time { return now }
+	var children []Child
+	r.OnChild = func(c Child) { children = append(children, c) }
+
+	route, err := r.Route(Parent{ID: 1, Symbol: "AAPL", Side: matching.OrderSideBuy, Price: 10000, Quantity: 250})
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+	if route.Routed != 100 || !route.Next.Equal(now.Add(time.Minute)) {
+		t.Fatalf("Expected 100 routed and the next slice in a minute, got %+v", route)
+	}
+	if _, err := r.Route(Parent{ID: 1, Symbol: "AAPL", Quantity: 10}); err != ErrDuplicateOrder {
+		t.Errorf("Expected ErrDuplicateOrder, got %v", err)
+	}
+
+	// Nothing is due before the interval
+	now = now.Add(30 * time.Second)
+	if err := r.Tick(); err != nil || route.Routed != 100 {
+		t.Errorf("Expected no slice before the interval, got %d, %v", route.Routed, err)
+	}
+	now = now.Add(30 * time.Second)
+	r.Tick()
+	now = now.Add(time.Minute)
+	r.Tick()
+	if route.Routed != 250 || len(children) != 3 || children[2].Quantity != 50 {
+		t.Errorf("Expected slices of 100, 100 and 50, got %+v", children)
+	}
+	if _, ok := r.Active(1); ok {
+		t.Errorf("Expected the route done")
+	}
+
+	// Canceled routes send no more slices
+	route, _ = r.Route(Parent{ID: 2, Symbol: "AAPL", Side: matching.OrderSideBuy, Price: 10000, Quantity: 250})
+	if !r.Cancel(2) {
+		t.Fatalf("Expected the route canceled")
+	}
+	now = now.Add(time.Hour)
+	r.Tick()
+	if route.Routed != 100 {
+		t.Errorf("Expected 100 routed, got %d", route.Routed)
+	}
+}
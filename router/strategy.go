@@ -0,0 +1,127 @@
+package router
+
+import (
+	"math/bits"
+	"sort"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// Sweep takes the best prices across venues first, up to the parent's limit.
+// Venues are preferred in order at equal prices. Quantity beyond the
+// marketable liquidity goes to the venue with the best price, or to the first
+// venue of an empty market.
+type Sweep struct{}
+
+// Allocate implements Strategy
+func (Sweep) Allocate(parent Parent, quantity uint64, depth [][]Level) []Allocation {
+	type quote struct {
+		venue int
+		level Level
+	}
+	var quotes []quote
+	for venue, levels := range depth {
+		for _, level := range levels {
+			if parent.marketable(level.Price) {
+				quotes = append(quotes, quote{venue, level})
+			}
+		}
+	}
+	buy := parent.Side == matching.OrderSideBuy
+	sort.SliceStable(quotes, func(i, j int) bool {
+		if buy {
+			return quotes[i].level.Price < quotes[j].level.Price
+		}
+		return quotes[i].level.Price > quotes[j].level.Price
+	})
+
+	amounts := make([]uint64, len(depth))
+	left := quantity
+	for _, q := range quotes {
+		if left == 0 {
+			break
+		}
+		take := min(left, q.level.Quantity)
+		amounts[q.venue] += take
+		left -= take
+	}
+	if left > 0 {
+		rest := 0
+		if len(quotes) > 0 {
+			rest = quotes[0].venue
+		}
+		amounts[rest] += left
+	}
+	return allocations(amounts)
+}
+
+// Proportional splits quantity in proportion to the marketable liquidity of
+// every venue. Rounding leftovers go to the deepest venue, and an empty
+// market leaves everything to the first venue.
+type Proportional struct{}
+
+// Allocate implements Strategy
+func (Proportional) Allocate(parent Parent, quantity uint64, depth [][]Level) []Allocation {
+	available := make([]uint64, len(depth))
+	var total uint64
+	deepest := 0
+	for venue, levels := range depth {
+		for _, level := range levels {
+			if parent.marketable(level.Price) {
+				available[venue] += level.Quantity
+			}
+		}
+		total += available[venue]
+		if available[venue] > available[deepest] {
+			deepest = venue
+		}
+	}
+
+	amounts := make([]uint64, len(depth))
+	if total == 0 {
+		amounts[0] = quantity
+		return allocations(amounts)
+	}
+	left := quantity
+	for venue := range amounts {
+		hi, lo := bits.Mul64(quantity, available[venue])
+		amounts[venue], _ = bits.Div64(hi, lo, total)
+		left -= amounts[venue]
+	}
+	amounts[deepest] += left
+	return allocations(amounts)
+}
+
+// Iceberg releases a parent in slices of at most Display, one every Interval,
+// each split by Strategy
+type Iceberg struct {
+	Strategy Strategy
+	Display  uint64
+	Interval time.Duration
+}
+
+// Allocate implements Strategy
+func (s Iceberg) Allocate(parent Parent, quantity uint64, depth [][]Level) []Allocation {
+	return s.Strategy.Allocate(parent, quantity, depth)
+}
+
+// Slice implements Slicer
+func (s Iceberg) Slice(route *Route, now time.Time) (uint64, time.Time) {
+	remaining := route.Remaining()
+	if s.Display == 0 || remaining <= s.Display {
+		return remaining, time.Time{}
+	}
+	return s.Display, now.Add(s.Interval)
+}
+
+// allocations converts the amounts of every venue, skipping empty ones
+func allocations(amounts []uint64) []Allocation {
+	var result []Allocation
+	for venue, quantity := range amounts {
+		if quantity > 0 {
+			result = append(result, Allocation{Venue: venue, Quantity: quantity})
+		}
+	}
+	return result
+}
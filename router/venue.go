@@ -0,0 +1,115 @@
+package router
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/tienpsm/go-trader/client"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// BookVenue is a venue backed by the order books of a MarketManager. Child
+// orders are added with their ID as order ID, so the router's NextID should
+// not collide with other order flow of the manager.
+type BookVenue struct {
+	name string
+	mm   *matching.MarketManager
+}
+
+// NewBookVenue creates a venue named name for the books of mm
+func NewBookVenue(name string, mm *matching.MarketManager) *BookVenue {
+	return &BookVenue{name: name, mm: mm}
+}
+
+// Name implements Venue
+func (v *BookVenue) Name() string {
+	return v.name
+}
+
+// Depth implements Venue with the visible volume of the levels
+func (v *BookVenue) Depth(symbol string, side matching.OrderSide) ([]Level, error) {
+	ob := v.mm.GetOrderBookByName(symbol)
+	if ob == nil {
+		return nil, matching.ErrorOrderBookNotFound.Error()
+	}
+	levels := ob.Asks()
+	if side == matching.OrderSideSell {
+		levels = ob.Bids()
+	}
+	var result []Level
+	levels.ForEach(func(level *matching.LevelNode) bool {
+		result = append(result, Level{Price: level.Price, Quantity: level.VisibleVolume})
+		return true
+	})
+	return result, nil
+}
+
+// Send implements Venue
+func (v *BookVenue) Send(child Child) error {
+	symbol := v.mm.GetSymbolByName(child.Symbol)
+	if symbol == nil {
+		return matching.ErrorSymbolNotFound.Error()
+	}
+	order := matching.NewLimitOrder(child.ID, symbol.ID, child.Side, child.Price, child.Quantity)
+	if child.Market {
+		order = matching.NewMarketOrder(child.ID, symbol.ID, child.Side, child.Quantity)
+	}
+	return v.mm.AddOrder(*order).Error()
+}
+
+// GatewayVenue is a venue reached through a gateway client. Child orders are
+// submitted with their ID after prefix as client order ID, and requests wait
+// for their answer until ctx ends.
+type GatewayVenue struct {
+	name   string
+	client *client.Client
+	ctx    context.Context
+	prefix string
+}
+
+// NewGatewayVenue creates a venue named name for the server of c. The prefix
+// must differ between sessions of a participant, since the server rejects
+// client order IDs it has seen.
+func NewGatewayVenue(ctx context.Context, name string, c *client.Client, prefix string) *GatewayVenue {
+	return &GatewayVenue{name: name, client: c, ctx: ctx, prefix: prefix}
+}
+
+// Name implements Venue
+func (v *GatewayVenue) Name() string {
+	return v.name
+}
+
+// Depth implements Venue with a depth snapshot of the server
+func (v *GatewayVenue) Depth(symbol string, side matching.OrderSide) ([]Level, error) {
+	depth, err := v.client.Depth(v.ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	levels := depth.Asks
+	if side == matching.OrderSideSell {
+		levels = depth.Bids
+	}
+	result := make([]Level, 0, len(levels))
+	for _, level := range levels {
+		result = append(result, Level{Price: level.Price, Quantity: level.Volume})
+	}
+	return result, nil
+}
+
+// Send implements Venue and waits until the child order is accepted
+func (v *GatewayVenue) Send(child Child) error {
+	_, err := v.client.SubmitOrder(v.ctx, client.Order{
+		ClientOrderID: v.ClientOrderID(child.ID),
+		Symbol:        child.Symbol,
+		Side:          child.Side,
+		Market:        child.Market,
+		Price:         child.Price,
+		Quantity:      child.Quantity,
+	})
+	return err
+}
+
+// ClientOrderID returns the client order ID of a child order
+func (v *GatewayVenue) ClientOrderID(id uint64) string {
+	return v.prefix + strconv.FormatUint(id, 10)
+}
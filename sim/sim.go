@@ -0,0 +1,688 @@
+// Package sim is a paper-trading broker simulator. Orders of a strategy are
+// filled against the book reconstructed from an ITCH feed, live or replayed,
+// without ever reaching the market.
+//
+// Marketable orders take the displayed liquidity of the book. Resting orders
+// join the queue of their price level behind the displayed shares already
+// there and are filled once the feed shows the queue ahead of them traded
+// away: executions at the order's price consume the shares ahead first,
+// executions at a worse price trade through it, and a new order crossing it
+// trades with it. Cancels ahead of the order move it up the queue.
+//
+// The simulated orders have no market impact: liquidity they take stays in
+// the book, and they never change what the feed does.
+package sim
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/tienpsm/go-trader/itch"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// Errors returned by the simulator
+var (
+	// ErrUnknownStock is returned for orders of a stock not seen in the feed
+	ErrUnknownStock = errors.New("sim: unknown stock")
+	// ErrUnknownOrder is returned for orders that are not open
+	ErrUnknownOrder = errors.New("sim: unknown order")
+	// ErrInvalidOrder is returned for orders without shares, side or price
+	ErrInvalidOrder = errors.New("sim: invalid order")
+)
+
+// CancelModel selects how cancels at the price level of a resting order move
+// it up the queue
+type CancelModel uint8
+
+const (
+	// CancelExact uses the order reference numbers of the feed: only cancels
+	// of orders added before the simulated order are ahead of it
+	CancelExact CancelModel = iota
+	// CancelProRata assumes canceled shares are spread evenly over the queue:
+	// each canceled share is ahead with probability shares ahead / level
+	// shares. It suits feeds whose cancels cannot be attributed to orders.
+	CancelProRata
+)
+
+// Config configures a Simulator
+type Config struct {
+	// CancelModel is the queue model of cancels
+	CancelModel CancelModel
+	// Seed seeds the random source of probabilistic models
+	Seed int64
+}
+
+// OrderState is the state of a simulated order
+type OrderState uint8
+
+const (
+	// OrderStateOpen is an order resting in the book
+	OrderStateOpen OrderState = iota
+	// OrderStateFilled is a fully executed order
+	OrderStateFilled
+	// OrderStateCanceled is an order canceled by the strategy, or the
+	// unfilled remainder of a market or IOC order
+	OrderStateCanceled
+)
+
+// String returns the string representation of an OrderState
+func (s OrderState) String() string {
+	switch s {
+	case OrderStateOpen:
+		return "OPEN"
+	case OrderStateFilled:
+		return "FILLED"
+	case OrderStateCanceled:
+		return "CANCELED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Order is a simulated order
+type Order struct {
+	// ID is assigned by Submit
+	ID uint64
+	// Stock is the trimmed stock symbol
+	Stock string
+	Side  matching.OrderSide
+	// Market selects a market order, which never rests
+	Market bool
+	// IOC cancels the remainder of a limit order instead of resting it
+	IOC bool
+	// Price is the limit price (4 implied decimals)
+	Price uint32
+	// Shares is the order quantity
+	Shares uint32
+
+	// ExecutedShares and LeavesShares are the filled and remaining shares
+	ExecutedShares uint32
+	LeavesShares   uint32
+	State          OrderState
+	// SharesAhead is the estimated displayed shares ahead of a resting order
+	// at its price level
+	SharesAhead uint64
+	// Timestamp is the feed time of submission in nanoseconds since midnight
+	Timestamp uint64
+
+	locate uint16
+	// mark is the feed sequence at submission; market orders added up to it
+	// are ahead of the order
+	mark uint64
+}
+
+// IsBuy returns true for buy orders
+func (o *Order) IsBuy() bool {
+	return o.Side == matching.OrderSideBuy
+}
+
+// Fill is an execution of a simulated order
+type Fill struct {
+	OrderID uint64
+	Stock   string
+	Side    matching.OrderSide
+	// Price is the execution price (4 implied decimals)
+	Price  uint32
+	Shares uint32
+	// LeavesShares is the remaining quantity of the order after the fill
+	LeavesShares uint32
+	// Passive is true for fills of resting orders, false for liquidity taken
+	// on submission
+	Passive bool
+	// Timestamp is the feed time in nanoseconds since midnight
+	Timestamp uint64
+}
+
+// Position is the net position of the simulated fills in a stock
+type Position struct {
+	Stock string
+	// Shares is the net position, negative when short
+	Shares int64
+	// BoughtShares and SoldShares are the total shares bought and sold
+	BoughtShares uint64
+	SoldShares   uint64
+	// BuyNotional and SellNotional are the sums of price * shares of buys
+	// and sells (4 implied decimals)
+	BuyNotional  uint64
+	SellNotional uint64
+}
+
+// marketOrder is the state of an order of the feed
+type marketOrder struct {
+	locate uint16
+	side   byte
+	price  uint32
+	shares uint32
+	// seq orders market orders by arrival
+	seq uint64
+}
+
+// Simulator is an ITCH handler that reconstructs the book of the feed and
+// fills simulated orders against it. It is safe for concurrent use, so a
+// strategy may submit orders while a live feed is being handled. OnFill is
+// called after the simulator lock is released and may submit or cancel
+// orders.
+type Simulator struct {
+	itch.DefaultHandler
+
+	// OnFill is called for every fill (optional)
+	OnFill func(f Fill)
+
+	mu     sync.Mutex
+	cfg    Config
+	rand   *rand.Rand
+	book   *itch.BookBuilder
+	stocks map[string]uint16
+	market map[uint64]marketOrder
+	// seq is the number of market orders added so far
+	seq uint64
+	// now is the timestamp of the last message
+	now    uint64
+	nextID uint64
+	// open are the resting orders in ID order
+	open      []*Order
+	orders    map[uint64]*Order
+	positions map[string]*Position
+	// fills are the fills waiting for OnFill
+	fills []Fill
+}
+
+// New creates a simulator
+func New(cfg Config) *Simulator {
+	return &Simulator{
+		cfg:       cfg,
+		rand:      rand.New(rand.NewSource(cfg.Seed)),
+		book:      itch.NewBookBuilder(),
+		stocks:    make(map[string]uint16),
+		market:    make(map[uint64]marketOrder),
+		nextID:    1,
+		orders:    make(map[uint64]*Order),
+		positions: make(map[string]*Position),
+	}
+}
+
+// Submit enters a simulated order at the current feed time and returns its
+// state after taking liquidity
+func (s *Simulator) Submit(order Order) (Order, error) {
+	if order.Shares == 0 || (order.Side != matching.OrderSideBuy && order.Side != matching.OrderSideSell) || (!order.Market && order.Price == 0) {
+		return Order{}, ErrInvalidOrder
+	}
+	s.mu.Lock()
+	locate, ok := s.stocks[order.Stock]
+	if !ok {
+		s.mu.Unlock()
+		return Order{}, ErrUnknownStock
+	}
+
+	o := &order
+	o.ID = s.nextID
+	s.nextID++
+	o.ExecutedShares = 0
+	o.LeavesShares = o.Shares
+	o.State = OrderStateOpen
+	o.Timestamp = s.now
+	o.locate = locate
+	o.mark = s.seq
+	s.take(o)
+
+	if o.LeavesShares > 0 {
+		if o.Market || o.IOC {
+			o.State = OrderStateCanceled
+		} else {
+			o.SharesAhead = s.levelShares(locate, side(o.IsBuy()), o.Price)
+			s.open = append(s.open, o)
+			s.orders[o.ID] = o
+		}
+	}
+	result := *o
+	s.unlock()
+	return result, nil
+}
+
+// take fills an order against the displayed liquidity crossing it
+func (s *Simulator) take(o *Order) {
+	book := s.book.BookByLocate(o.locate)
+	if book == nil {
+		return
+	}
+	levels := book.Asks(-1)
+	if !o.IsBuy() {
+		levels = book.Bids(-1)
+	}
+	for _, level := range levels {
+		if o.LeavesShares == 0 || (!o.Market && !crosses(o, level.Price)) {
+			return
+		}
+		s.fill(o, level.Price, uint32(min(level.Shares, uint64(o.LeavesShares))), false)
+	}
+}
+
+// Cancel cancels an open order and returns its final state
+func (s *Simulator) Cancel(id uint64) (Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.orders[id]
+	if o == nil {
+		return Order{}, ErrUnknownOrder
+	}
+	o.State = OrderStateCanceled
+	s.remove(o)
+	return *o, nil
+}
+
+// Order returns an open order
+func (s *Simulator) Order(id uint64) (Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if o := s.orders[id]; o != nil {
+		return *o, true
+	}
+	return Order{}, false
+}
+
+// OpenOrders returns the open orders in ID order
+func (s *Simulator) OpenOrders() []Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	orders := make([]Order, len(s.open))
+	for i, o := range s.open {
+		orders[i] = *o
+	}
+	return orders
+}
+
+// Position returns the position of a stock
+func (s *Simulator) Position(stock string) Position {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p := s.positions[stock]; p != nil {
+		return *p
+	}
+	return Position{Stock: stock}
+}
+
+// Quote returns the best bid and ask of a stock
+func (s *Simulator) Quote(stock string) (bid, ask itch.BookLevel, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	book := s.book.Book(stock)
+	if book == nil {
+		return itch.BookLevel{}, itch.BookLevel{}, false
+	}
+	bid, _ = book.BestBid()
+	ask, _ = book.BestAsk()
+	return bid, ask, true
+}
+
+// Depth returns up to depth levels of each side of a stock, best first (-1
+// for all)
+func (s *Simulator) Depth(stock string, depth int) (bids, asks []itch.BookLevel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	book := s.book.Book(stock)
+	if book == nil {
+		return nil, nil
+	}
+	return book.Bids(depth), book.Asks(depth)
+}
+
+// Now returns the timestamp of the last message in nanoseconds since midnight
+func (s *Simulator) Now() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+// unlock releases the simulator lock and reports the pending fills
+func (s *Simulator) unlock() {
+	fills := s.fills
+	s.fills = nil
+	s.mu.Unlock()
+	if s.OnFill != nil {
+		for _, f := range fills {
+			s.OnFill(f)
+		}
+	}
+}
+
+// fill executes shares of an order at a price
+func (s *Simulator) fill(o *Order, price, shares uint32, passive bool) {
+	if shares == 0 {
+		return
+	}
+	o.ExecutedShares += shares
+	o.LeavesShares -= shares
+	if o.LeavesShares == 0 {
+		o.State = OrderStateFilled
+		s.remove(o)
+	}
+
+	p := s.positions[o.Stock]
+	if p == nil {
+		p = &Position{Stock: o.Stock}
+		s.positions[o.Stock] = p
+	}
+	notional := uint64(price) * uint64(shares)
+	if o.IsBuy() {
+		p.Shares += int64(shares)
+		p.BoughtShares += uint64(shares)
+		p.BuyNotional += notional
+	} else {
+		p.Shares -= int64(shares)
+		p.SoldShares += uint64(shares)
+		p.SellNotional += notional
+	}
+
+	s.fills = append(s.fills, Fill{
+		OrderID:      o.ID,
+		Stock:        o.Stock,
+		Side:         o.Side,
+		Price:        price,
+		Shares:       shares,
+		LeavesShares: o.LeavesShares,
+		Passive:      passive,
+		Timestamp:    s.now,
+	})
+}
+
+// remove forgets an order that is no longer open
+func (s *Simulator) remove(o *Order) {
+	delete(s.orders, o.ID)
+	for i, open := range s.open {
+		if open == o {
+			s.open = append(s.open[:i], s.open[i+1:]...)
+			return
+		}
+	}
+}
+
+// levelShares returns the displayed shares of a price level
+func (s *Simulator) levelShares(locate uint16, side byte, price uint32) uint64 {
+	book := s.book.BookByLocate(locate)
+	if book == nil {
+		return 0
+	}
+	levels := book.Bids(-1)
+	if side == 'S' {
+		levels = book.Asks(-1)
+	}
+	for _, level := range levels {
+		if level.Price == price {
+			return level.Shares
+		}
+	}
+	return 0
+}
+
+// resting returns the open orders of a stock side in priority order
+func (s *Simulator) resting(locate uint16, side byte) []*Order {
+	var orders []*Order
+	for _, o := range s.open {
+		if o.locate == locate && sideOf(o) == side {
+			orders = append(orders, o)
+		}
+	}
+	sort.SliceStable(orders, func(i, j int) bool {
+		if orders[i].IsBuy() {
+			return orders[i].Price > orders[j].Price
+		}
+		return orders[i].Price < orders[j].Price
+	})
+	return orders
+}
+
+// added handles a market order joining the book, which trades with the
+// resting simulated orders it crosses
+func (s *Simulator) added(ref uint64, locate uint16, stock itch.StockSymbol, side byte, shares, price uint32) {
+	s.seq++
+	s.market[ref] = marketOrder{locate: locate, side: side, price: price, shares: shares, seq: s.seq}
+	if name := s.book.Directory().Name(stock); name != "" {
+		if _, ok := s.stocks[name]; !ok {
+			s.stocks[name] = locate
+		}
+	}
+
+	budget := shares
+	for _, o := range s.resting(locate, opposite(side)) {
+		if budget == 0 || !crosses(o, price) {
+			break
+		}
+		f := min(budget, o.LeavesShares)
+		budget -= f
+		s.fill(o, o.Price, f, true)
+	}
+}
+
+// executed handles an execution of a market order: shares ahead of the
+// resting simulated orders at its price are consumed first, and orders at a
+// better price are traded through
+func (s *Simulator) executed(ref uint64, shares uint32) {
+	mo, ok := s.market[ref]
+	if !ok {
+		return
+	}
+	budget := shares
+	for _, o := range s.resting(mo.locate, mo.side) {
+		var f uint32
+		switch {
+		case o.Price == mo.price:
+			excess := uint64(shares)
+			if s.cfg.CancelModel != CancelExact || mo.seq <= o.mark {
+				consumed := min(o.SharesAhead, uint64(shares))
+				o.SharesAhead -= consumed
+				excess -= consumed
+			} else {
+				// The feed executed an order behind this one
+				o.SharesAhead = 0
+			}
+			f = uint32(min(excess, uint64(budget), uint64(o.LeavesShares)))
+		case better(o, mo.price):
+			f = min(budget, o.LeavesShares)
+		}
+		budget -= f
+		s.fill(o, o.Price, f, true)
+	}
+	s.reduce(ref, mo, shares)
+}
+
+// canceled handles canceled shares of a market order, which move the resting
+// simulated orders behind it up the queue
+func (s *Simulator) canceled(ref uint64, shares uint32) {
+	mo, ok := s.market[ref]
+	if !ok {
+		return
+	}
+	shares = min(shares, mo.shares)
+	for _, o := range s.resting(mo.locate, mo.side) {
+		if o.Price != mo.price || o.SharesAhead == 0 {
+			continue
+		}
+		switch s.cfg.CancelModel {
+		case CancelExact:
+			if mo.seq <= o.mark {
+				o.SharesAhead -= min(o.SharesAhead, uint64(shares))
+			}
+		case CancelProRata:
+			total := s.levelShares(mo.locate, mo.side, mo.price)
+			if total == 0 {
+				continue
+			}
+			p := float64(min(o.SharesAhead, total)) / float64(total)
+			expected := float64(shares) * p
+			ahead := uint64(expected)
+			if s.rand.Float64() < expected-float64(ahead) {
+				ahead++
+			}
+			o.SharesAhead -= min(o.SharesAhead, ahead)
+		}
+	}
+	s.reduce(ref, mo, shares)
+}
+
+// reduce removes shares from a market order
+func (s *Simulator) reduce(ref uint64, mo marketOrder, shares uint32) {
+	if shares >= mo.shares {
+		delete(s.market, ref)
+		return
+	}
+	mo.shares -= shares
+	s.market[ref] = mo
+}
+
+// clamp caps the shares ahead of the resting orders of a level at the
+// displayed shares of the level
+func (s *Simulator) clamp(locate uint16, side byte, price uint32) {
+	total := s.levelShares(locate, side, price)
+	for _, o := range s.open {
+		if o.locate == locate && sideOf(o) == side && o.Price == price {
+			o.SharesAhead = min(o.SharesAhead, total)
+		}
+	}
+}
+
+// OnStockDirectory registers the stock of a locate code
+func (s *Simulator) OnStockDirectory(msg itch.StockDirectoryMessage) error {
+	s.mu.Lock()
+	defer s.unlock()
+	s.now = msg.Timestamp
+	if name := s.book.Directory().Name(msg.Stock); name != "" {
+		if _, ok := s.stocks[name]; !ok {
+			s.stocks[name] = msg.StockLocate
+		}
+	}
+	return s.book.OnStockDirectory(msg)
+}
+
+// OnAddOrder adds the order to the book and trades it with crossed orders
+func (s *Simulator) OnAddOrder(msg itch.AddOrderMessage) error {
+	s.mu.Lock()
+	defer s.unlock()
+	s.now = msg.Timestamp
+	s.added(msg.OrderReferenceNumber, msg.StockLocate, msg.Stock, msg.BuySellIndicator, msg.Shares, msg.Price)
+	return s.book.OnAddOrder(msg)
+}
+
+// OnAddOrderMPID adds the order to the book and trades it with crossed orders
+func (s *Simulator) OnAddOrderMPID(msg itch.AddOrderMPIDMessage) error {
+	s.mu.Lock()
+	defer s.unlock()
+	s.now = msg.Timestamp
+	s.added(msg.OrderReferenceNumber, msg.StockLocate, msg.Stock, msg.BuySellIndicator, msg.Shares, msg.Price)
+	return s.book.OnAddOrderMPID(msg)
+}
+
+// OnOrderExecuted advances the queues of the executed order's level
+func (s *Simulator) OnOrderExecuted(msg itch.OrderExecutedMessage) error {
+	s.mu.Lock()
+	defer s.unlock()
+	s.now = msg.Timestamp
+	mo, ok := s.market[msg.OrderReferenceNumber]
+	s.executed(msg.OrderReferenceNumber, msg.ExecutedShares)
+	err := s.book.OnOrderExecuted(msg)
+	if ok {
+		s.clamp(mo.locate, mo.side, mo.price)
+	}
+	return err
+}
+
+// OnOrderExecutedWithPrice advances the queues of the executed order's level
+func (s *Simulator) OnOrderExecutedWithPrice(msg itch.OrderExecutedWithPriceMessage) error {
+	s.mu.Lock()
+	defer s.unlock()
+	s.now = msg.Timestamp
+	mo, ok := s.market[msg.OrderReferenceNumber]
+	s.executed(msg.OrderReferenceNumber, msg.ExecutedShares)
+	err := s.book.OnOrderExecutedWithPrice(msg)
+	if ok {
+		s.clamp(mo.locate, mo.side, mo.price)
+	}
+	return err
+}
+
+// OnOrderCancel advances the queues behind the canceled shares
+func (s *Simulator) OnOrderCancel(msg itch.OrderCancelMessage) error {
+	s.mu.Lock()
+	defer s.unlock()
+	s.now = msg.Timestamp
+	mo, ok := s.market[msg.OrderReferenceNumber]
+	s.canceled(msg.OrderReferenceNumber, msg.CanceledShares)
+	err := s.book.OnOrderCancel(msg)
+	if ok {
+		s.clamp(mo.locate, mo.side, mo.price)
+	}
+	return err
+}
+
+// OnOrderDelete advances the queues behind the deleted order
+func (s *Simulator) OnOrderDelete(msg itch.OrderDeleteMessage) error {
+	s.mu.Lock()
+	defer s.unlock()
+	s.now = msg.Timestamp
+	mo, ok := s.market[msg.OrderReferenceNumber]
+	if ok {
+		s.canceled(msg.OrderReferenceNumber, mo.shares)
+	}
+	err := s.book.OnOrderDelete(msg)
+	if ok {
+		s.clamp(mo.locate, mo.side, mo.price)
+	}
+	return err
+}
+
+// OnOrderReplace handles a replace as a delete followed by an add, so the
+// replacement joins the back of its queue
+func (s *Simulator) OnOrderReplace(msg itch.OrderReplaceMessage) error {
+	s.mu.Lock()
+	defer s.unlock()
+	s.now = msg.Timestamp
+	mo, ok := s.market[msg.OriginalOrderReferenceNumber]
+	if !ok {
+		return s.book.OnOrderReplace(msg)
+	}
+	s.canceled(msg.OriginalOrderReferenceNumber, mo.shares)
+	s.added(msg.NewOrderReferenceNumber, mo.locate, itch.StockSymbol{}, mo.side, msg.Shares, msg.Price)
+	err := s.book.OnOrderReplace(msg)
+	s.clamp(mo.locate, mo.side, mo.price)
+	return err
+}
+
+// side converts a buy flag into an ITCH side
+func side(buy bool) byte {
+	if buy {
+		return 'B'
+	}
+	return 'S'
+}
+
+// sideOf returns the ITCH side of a simulated order
+func sideOf(o *Order) byte {
+	return side(o.IsBuy())
+}
+
+// opposite returns the other ITCH side
+func opposite(side byte) byte {
+	if side == 'B' {
+		return 'S'
+	}
+	return 'B'
+}
+
+// crosses returns true if a limit order trades at a price of the other side
+func crosses(o *Order, price uint32) bool {
+	if o.IsBuy() {
+		return price <= o.Price
+	}
+	return price >= o.Price
+}
+
+// better returns true if a limit order is priced better than a price of its
+// own side, so the market trading there trades through it
+func better(o *Order, price uint32) bool {
+	if o.IsBuy() {
+		return o.Price > price
+	}
+	return o.Price < price
+}
@@ -0,0 +1,188 @@
+package sim
+
+import (
+	"testing"
+
+	"github.com/tienpsm/go-trader/itch"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+var aapl = itch.StockField("AAPL")
+
+func add(s *Simulator, ref uint64, side byte, shares, price uint32) {
+	s.OnAddOrder(itch.AddOrderMessage{StockLocate: 1, OrderReferenceNumber: ref, BuySellIndicator: side, Shares: shares, Stock: aapl, Price: price})
+}
+
+func execute(s *Simulator, ref uint64, shares uint32) {
+	s.OnOrderExecuted(itch.OrderExecutedMessage{StockLocate: 1, OrderReferenceNumber: ref, ExecutedShares: shares})
+}
+
+func cancel(s *Simulator, ref uint64, shares uint32) {
+	s.OnOrderCancel(itch.OrderCancelMessage{StockLocate: 1, OrderReferenceNumber: ref, CanceledShares: shares})
+}
+
+func submit(t *testing.T, s *Simulator, order Order) Order {
+	t.Helper()
+	order.Stock = "AAPL"
+	o, err := s.Submit(order)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	return o
+}
+
+func ahead(t *testing.T, s *Simulator, id uint64) uint64 {
+	t.Helper()
+	o, ok := s.Order(id)
+	if !ok {
+		t.Fatalf("Expected order %d open", id)
+	}
+	return o.SharesAhead
+}
+
+func TestSimulator_TakesLiquidity(t *testing.T) {
+	s := New(Config{})
+	var fills []Fill
+	s.OnFill = func(f Fill) { fills = append(fills, f) }
+
+	if _, err := s.Submit(Order{Stock: "AAPL", Side: matching.OrderSideBuy, Price: 10000, Shares: 10}); err != ErrUnknownStock {
+		t.Errorf("Expected ErrUnknownStock, got %v", err)
+	}
+	add(s, 1, 'S', 100, 10000)
+	add(s, 2, 'S', 200, 10100)
+	add(s, 3, 'S', 200, 10200)
+
+	o := submit(t, s, Order{Side: matching.OrderSideBuy, Price: 10100, Shares: 250})
+	if o.State != OrderStateFilled || o.ExecutedShares != 250 {
+		t.Errorf("Expected order filled, got %+v", o)
+	}
+	if len(fills) != 2 || fills[0].Price != 10000 || fills[0].Shares != 100 || fills[1].Price != 10100 || fills[1].Shares != 150 || fills[1].Passive {
+		t.Errorf("Expected 100 @ 10000 and 150 @ 10100 taken, got %+v", fills)
+	}
+
+	// The remainder of IOC and market orders is canceled
+	o = submit(t, s, Order{Side: matching.OrderSideBuy, Price: 10000, Shares: 150, IOC: true})
+	if o.State != OrderStateCanceled || o.ExecutedShares != 100 {
+		t.Errorf("Expected IOC order canceled after 100, got %+v", o)
+	}
+	o = submit(t, s, Order{Side: matching.OrderSideSell, Market: true, Shares: 10})
+	if o.State != OrderStateCanceled || o.ExecutedShares != 0 {
+		t.Errorf("Expected market sell without bids canceled, got %+v", o)
+	}
+	if len(s.OpenOrders()) != 0 {
+		t.Errorf("Expected no open orders, got %+v", s.OpenOrders())
+	}
+
+	// Taken liquidity stays in the book
+	if p := s.Position("AAPL"); p.Shares != 350 || p.BuyNotional != 100*10000+150*10100+100*10000 {
+		t.Errorf("Expected long 350, got %+v", p)
+	}
+	if _, ask, _ := s.Quote("AAPL"); ask.Price != 10000 || ask.Shares != 100 {
+		t.Errorf("Expected best ask unchanged, got %+v", ask)
+	}
+}
+
+func TestSimulator_QueuePosition(t *testing.T) {
+	s := New(Config{})
+	var fills []Fill
+	s.OnFill = func(f Fill) { fills = append(fills, f) }
+
+	add(s, 1, 'B', 100, 9900)
+	add(s, 2, 'B', 200, 9900)
+	add(s, 3, 'B', 500, 9800)
+	o := submit(t, s, Order{Side: matching.OrderSideBuy, Price: 9900, Shares: 80})
+	if o.State != OrderStateOpen || o.SharesAhead != 300 {
+		t.Fatalf("Expected order resting behind 300 shares, got %+v", o)
+	}
+
+	// Orders added later are behind and do not move the queue when canceled
+	add(s, 4, 'B', 100, 9900)
+	cancel(s, 4, 100)
+	if got := ahead(t, s, o.ID); got != 300 {
+		t.Errorf("Expected 300 ahead, got %d", got)
+	}
+	add(s, 5, 'B', 100, 9900)
+	cancel(s, 1, 40)
+	if got := ahead(t, s, o.ID); got != 260 {
+		t.Errorf("Expected 260 ahead after cancel, got %d", got)
+	}
+	execute(s, 1, 60)
+	execute(s, 2, 150)
+	if got := ahead(t, s, o.ID); got != 50 || len(fills) != 0 {
+		t.Errorf("Expected 50 ahead without fills, got %d and %+v", got, fills)
+	}
+
+	// Executions past the queue ahead fill the order
+	execute(s, 2, 50)
+	execute(s, 5, 30)
+	if len(fills) != 1 || fills[0].Shares != 30 || fills[0].Price != 9900 || !fills[0].Passive || fills[0].LeavesShares != 50 {
+		t.Fatalf("Expected passive fill of 30, got %+v", fills)
+	}
+
+	// Trading through the price fills the rest
+	execute(s, 3, 200)
+	if len(fills) != 2 || fills[1].Shares != 50 || fills[1].Price != 9900 || fills[1].LeavesShares != 0 {
+		t.Errorf("Expected trade-through fill of 50, got %+v", fills)
+	}
+	if _, ok := s.Order(o.ID); ok {
+		t.Errorf("Expected filled order to be closed")
+	}
+}
+
+func TestSimulator_CrossingAdd(t *testing.T) {
+	s := New(Config{})
+	var fills []Fill
+	s.OnFill = func(f Fill) { fills = append(fills, f) }
+
+	add(s, 1, 'S', 100, 10100)
+	first := submit(t, s, Order{Side: matching.OrderSideSell, Price: 10050, Shares: 40})
+	second := submit(t, s, Order{Side: matching.OrderSideSell, Price: 10060, Shares: 40})
+
+	// A buy at 10060 reaching the feed trades with both simulated asks, best first
+	add(s, 2, 'B', 60, 10060)
+	if len(fills) != 2 || fills[0].OrderID != first.ID || fills[0].Shares != 40 || fills[1].OrderID != second.ID || fills[1].Shares != 20 {
+		t.Errorf("Expected 40 and 20 filled, got %+v", fills)
+	}
+	if o, err := s.Cancel(second.ID); err != nil || o.State != OrderStateCanceled || o.LeavesShares != 20 {
+		t.Errorf("Expected second order canceled with 20 leaves, got %+v, %v", o, err)
+	}
+	if _, err := s.Cancel(second.ID); err != ErrUnknownOrder {
+		t.Errorf("Expected ErrUnknownOrder, got %v", err)
+	}
+	if p := s.Position("AAPL"); p.Shares != -60 || p.SoldShares != 60 {
+		t.Errorf("Expected short 60, got %+v", p)
+	}
+}
+
+func TestSimulator_ProRataCancels(t *testing.T) {
+	s := New(Config{CancelModel: CancelProRata, Seed: 1})
+	add(s, 1, 'S', 1000, 10000)
+	o := submit(t, s, Order{Side: matching.OrderSideSell, Price: 10000, Shares: 100})
+
+	// With every share ahead, canceled shares are all ahead
+	cancel(s, 1, 500)
+	if got := ahead(t, s, o.ID); got != 500 {
+		t.Errorf("Expected 500 ahead, got %d", got)
+	}
+	// A third of the level is ahead, so a third of the canceled shares are
+	add(s, 2, 'S', 1000, 10000)
+	cancel(s, 2, 300)
+	if got := ahead(t, s, o.ID); got != 400 {
+		t.Errorf("Expected 400 ahead, got %d", got)
+	}
+}
+
+func TestSimulator_OnFillReenters(t *testing.T) {
+	s := New(Config{})
+	add(s, 1, 'B', 100, 9900)
+	var replaced Order
+	s.OnFill = func(f Fill) {
+		if f.LeavesShares == 0 && replaced.ID == 0 {
+			replaced, _ = s.Submit(Order{Stock: "AAPL", Side: matching.OrderSideSell, Price: 10100, Shares: 10})
+		}
+	}
+	submit(t, s, Order{Side: matching.OrderSideSell, Price: 9900, Shares: 10})
+	if replaced.State != OrderStateOpen {
+		t.Errorf("Expected an order submitted from OnFill to rest, got %+v", replaced)
+	}
+}
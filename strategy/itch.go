@@ -0,0 +1,342 @@
+package strategy
+
+import (
+	"time"
+
+	"github.com/tienpsm/go-trader/itch"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// restingOrder is the state of an order of the feed needed to price its
+// executions
+type restingOrder struct {
+	side   byte
+	price  uint32
+	shares uint32
+}
+
+// ITCHSource is an ITCH handler publishing the book updates and trades of the
+// feed as market events. Executions, non-displayed trades and crosses are
+// trades; the aggressor of an execution is the side opposite the resting
+// order, and crosses are published with the buy side.
+type ITCHSource struct {
+	// Next receives every message before the source publishes its events,
+	// such as the simulator of a paper trading session (optional)
+	Next itch.Handler
+
+	date    time.Time
+	publish func(MarketEvent)
+	book    *itch.BookBuilder
+	orders  map[uint64]restingOrder
+}
+
+// NewITCHSource creates a source of a trading session publishing to publish.
+// Message timestamps are converted to times of date, taken at midnight in its
+// location, so date should be in the exchange's time zone.
+func NewITCHSource(date time.Time, publish func(MarketEvent)) *ITCHSource {
+	h := &ITCHSource{
+		date:    time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location()),
+		publish: publish,
+		book:    itch.NewBookBuilder(),
+		orders:  make(map[uint64]restingOrder),
+	}
+	h.book.OnDepthChange = h.onDepthChange
+	return h
+}
+
+// time converts a message timestamp
+func (h *ITCHSource) time(timestamp uint64) time.Time {
+	return h.date.Add(time.Duration(timestamp))
+}
+
+// onDepthChange publishes a book update
+func (h *ITCHSource) onDepthChange(c itch.DepthChange) {
+	e := MarketEvent{
+		Type:     EventBookUpdate,
+		Time:     h.time(c.Timestamp),
+		Symbol:   c.Stock,
+		Side:     orderSide(c.Side),
+		Price:    uint64(c.Level.Price),
+		Quantity: c.Level.Shares,
+		Orders:   uint64(c.Level.Orders),
+	}
+	switch c.Action {
+	case itch.DepthAdd:
+		e.Action = BookAdd
+	case itch.DepthUpdate:
+		e.Action = BookUpdate
+	case itch.DepthDelete:
+		e.Action = BookDelete
+	}
+	h.publish(e)
+}
+
+// trade publishes a trade
+func (h *ITCHSource) trade(timestamp uint64, locate uint16, aggressor matching.OrderSide, price uint32, shares uint64) {
+	h.publish(MarketEvent{
+		Type:     EventTrade,
+		Time:     h.time(timestamp),
+		Symbol:   h.book.Directory().Stock(locate),
+		Side:     aggressor,
+		Price:    uint64(price),
+		Quantity: shares,
+	})
+}
+
+// executed publishes the execution of a resting order of the feed at the
+// order's price, or at price if it is not 0. Non-printable executions are
+// not published.
+func (h *ITCHSource) executed(timestamp uint64, locate uint16, ref uint64, price uint32, shares uint32, printable bool) {
+	order, ok := h.orders[ref]
+	if !ok {
+		return
+	}
+	h.reduce(ref, order, shares)
+	if !printable {
+		return
+	}
+	if price == 0 {
+		price = order.price
+	}
+	aggressor := matching.OrderSideBuy
+	if order.side == 'B' {
+		aggressor = matching.OrderSideSell
+	}
+	h.trade(timestamp, locate, aggressor, price, uint64(shares))
+}
+
+// reduce removes shares from an order of the feed
+func (h *ITCHSource) reduce(ref uint64, order restingOrder, shares uint32) {
+	if shares >= order.shares {
+		delete(h.orders, ref)
+		return
+	}
+	order.shares -= shares
+	h.orders[ref] = order
+}
+
+// register remembers the stock of a locate code
+func (h *ITCHSource) register(locate uint16, stock itch.StockSymbol) {
+	h.book.Directory().Register(locate, stock)
+}
+
+// OnSystemEvent forwards the message
+func (h *ITCHSource) OnSystemEvent(msg itch.SystemEventMessage) error {
+	if h.Next != nil {
+		return h.Next.OnSystemEvent(msg)
+	}
+	return nil
+}
+
+// OnStockDirectory registers the stock
+func (h *ITCHSource) OnStockDirectory(msg itch.StockDirectoryMessage) error {
+	if h.Next != nil {
+		if err := h.Next.OnStockDirectory(msg); err != nil {
+			return err
+		}
+	}
+	return h.book.OnStockDirectory(msg)
+}
+
+// OnStockTradingAction forwards the message
+func (h *ITCHSource) OnStockTradingAction(msg itch.StockTradingActionMessage) error {
+	if h.Next != nil {
+		return h.Next.OnStockTradingAction(msg)
+	}
+	return nil
+}
+
+// OnRegSHO forwards the message
+func (h *ITCHSource) OnRegSHO(msg itch.RegSHOMessage) error {
+	if h.Next != nil {
+		return h.Next.OnRegSHO(msg)
+	}
+	return nil
+}
+
+// OnMarketParticipantPosition forwards the message
+func (h *ITCHSource) OnMarketParticipantPosition(msg itch.MarketParticipantPositionMessage) error {
+	if h.Next != nil {
+		return h.Next.OnMarketParticipantPosition(msg)
+	}
+	return nil
+}
+
+// OnMWCBDecline forwards the message
+func (h *ITCHSource) OnMWCBDecline(msg itch.MWCBDeclineMessage) error {
+	if h.Next != nil {
+		return h.Next.OnMWCBDecline(msg)
+	}
+	return nil
+}
+
+// OnMWCBStatus forwards the message
+func (h *ITCHSource) OnMWCBStatus(msg itch.MWCBStatusMessage) error {
+	if h.Next != nil {
+		return h.Next.OnMWCBStatus(msg)
+	}
+	return nil
+}
+
+// OnIPOQuoting forwards the message
+func (h *ITCHSource) OnIPOQuoting(msg itch.IPOQuotingMessage) error {
+	if h.Next != nil {
+		return h.Next.OnIPOQuoting(msg)
+	}
+	return nil
+}
+
+// OnAddOrder publishes the level change
+func (h *ITCHSource) OnAddOrder(msg itch.AddOrderMessage) error {
+	if h.Next != nil {
+		if err := h.Next.OnAddOrder(msg); err != nil {
+			return err
+		}
+	}
+	h.register(msg.StockLocate, msg.Stock)
+	h.orders[msg.OrderReferenceNumber] = restingOrder{side: msg.BuySellIndicator, price: msg.Price, shares: msg.Shares}
+	return h.book.OnAddOrder(msg)
+}
+
+// OnAddOrderMPID publishes the level change
+func (h *ITCHSource) OnAddOrderMPID(msg itch.AddOrderMPIDMessage) error {
+	if h.Next != nil {
+		if err := h.Next.OnAddOrderMPID(msg); err != nil {
+			return err
+		}
+	}
+	h.register(msg.StockLocate, msg.Stock)
+	h.orders[msg.OrderReferenceNumber] = restingOrder{side: msg.BuySellIndicator, price: msg.Price, shares: msg.Shares}
+	return h.book.OnAddOrderMPID(msg)
+}
+
+// OnOrderExecuted publishes the trade and the level change
+func (h *ITCHSource) OnOrderExecuted(msg itch.OrderExecutedMessage) error {
+	if h.Next != nil {
+		if err := h.Next.OnOrderExecuted(msg); err != nil {
+			return err
+		}
+	}
+	h.executed(msg.Timestamp, msg.StockLocate, msg.OrderReferenceNumber, 0, msg.ExecutedShares, true)
+	return h.book.OnOrderExecuted(msg)
+}
+
+// OnOrderExecutedWithPrice publishes printable trades and the level change
+func (h *ITCHSource) OnOrderExecutedWithPrice(msg itch.OrderExecutedWithPriceMessage) error {
+	if h.Next != nil {
+		if err := h.Next.OnOrderExecutedWithPrice(msg); err != nil {
+			return err
+		}
+	}
+	h.executed(msg.Timestamp, msg.StockLocate, msg.OrderReferenceNumber, msg.ExecutionPrice, msg.ExecutedShares, msg.Printable == 'Y')
+	return h.book.OnOrderExecutedWithPrice(msg)
+}
+
+// OnOrderCancel publishes the level change
+func (h *ITCHSource) OnOrderCancel(msg itch.OrderCancelMessage) error {
+	if h.Next != nil {
+		if err := h.Next.OnOrderCancel(msg); err != nil {
+			return err
+		}
+	}
+	if order, ok := h.orders[msg.OrderReferenceNumber]; ok {
+		h.reduce(msg.OrderReferenceNumber, order, msg.CanceledShares)
+	}
+	return h.book.OnOrderCancel(msg)
+}
+
+// OnOrderDelete publishes the level change
+func (h *ITCHSource) OnOrderDelete(msg itch.OrderDeleteMessage) error {
+	if h.Next != nil {
+		if err := h.Next.OnOrderDelete(msg); err != nil {
+			return err
+		}
+	}
+	delete(h.orders, msg.OrderReferenceNumber)
+	return h.book.OnOrderDelete(msg)
+}
+
+// OnOrderReplace publishes the level changes
+func (h *ITCHSource) OnOrderReplace(msg itch.OrderReplaceMessage) error {
+	if h.Next != nil {
+		if err := h.Next.OnOrderReplace(msg); err != nil {
+			return err
+		}
+	}
+	if order, ok := h.orders[msg.OriginalOrderReferenceNumber]; ok {
+		delete(h.orders, msg.OriginalOrderReferenceNumber)
+		h.orders[msg.NewOrderReferenceNumber] = restingOrder{side: order.side, price: msg.Price, shares: msg.Shares}
+	}
+	return h.book.OnOrderReplace(msg)
+}
+
+// OnTrade publishes a non-displayed trade
+func (h *ITCHSource) OnTrade(msg itch.TradeMessage) error {
+	if h.Next != nil {
+		if err := h.Next.OnTrade(msg); err != nil {
+			return err
+		}
+	}
+	h.register(msg.StockLocate, msg.Stock)
+	// The indicator is the side of the non-displayed resting order
+	aggressor := matching.OrderSideBuy
+	if msg.BuySellIndicator == 'B' {
+		aggressor = matching.OrderSideSell
+	}
+	h.trade(msg.Timestamp, msg.StockLocate, aggressor, msg.Price, uint64(msg.Shares))
+	return nil
+}
+
+// OnCrossTrade publishes the cross volume, if any shares were matched
+func (h *ITCHSource) OnCrossTrade(msg itch.CrossTradeMessage) error {
+	if h.Next != nil {
+		if err := h.Next.OnCrossTrade(msg); err != nil {
+			return err
+		}
+	}
+	h.register(msg.StockLocate, msg.Stock)
+	if msg.Shares > 0 {
+		h.trade(msg.Timestamp, msg.StockLocate, matching.OrderSideBuy, msg.CrossPrice, msg.Shares)
+	}
+	return nil
+}
+
+// OnBrokenTrade forwards the message
+func (h *ITCHSource) OnBrokenTrade(msg itch.BrokenTradeMessage) error {
+	if h.Next != nil {
+		return h.Next.OnBrokenTrade(msg)
+	}
+	return nil
+}
+
+// OnNOII forwards the message
+func (h *ITCHSource) OnNOII(msg itch.NOIIMessage) error {
+	if h.Next != nil {
+		return h.Next.OnNOII(msg)
+	}
+	return nil
+}
+
+// OnRPII forwards the message
+func (h *ITCHSource) OnRPII(msg itch.RPIIMessage) error {
+	if h.Next != nil {
+		return h.Next.OnRPII(msg)
+	}
+	return nil
+}
+
+// OnUnknownMessage forwards the message
+func (h *ITCHSource) OnUnknownMessage(msgType byte, data []byte) error {
+	if h.Next != nil {
+		return h.Next.OnUnknownMessage(msgType, data)
+	}
+	return nil
+}
+
+// orderSide converts an ITCH side
+func orderSide(side byte) matching.OrderSide {
+	if side == 'B' {
+		return matching.OrderSideBuy
+	}
+	return matching.OrderSideSell
+}
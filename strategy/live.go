@@ -0,0 +1,212 @@
+package strategy
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tienpsm/go-trader/client"
+	"github.com/tienpsm/go-trader/gateway"
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// ClientBroker places the orders of a strategy through a gateway client.
+// Orders get client order IDs made of a session prefix and their ID.
+type ClientBroker struct {
+	client *client.Client
+	ctx    context.Context
+	prefix string
+
+	mu      sync.Mutex
+	nextID  uint64
+	ids     map[string]uint64
+	clients map[uint64]string
+}
+
+// NewClientBroker creates a broker placing orders through c. Requests wait
+// for their answer until ctx ends. The prefix must differ between sessions
+// of a participant, since the server rejects client order IDs it has seen.
+func NewClientBroker(ctx context.Context, c *client.Client, prefix string) *ClientBroker {
+	return &ClientBroker{
+		client:  c,
+		ctx:     ctx,
+		prefix:  prefix,
+		nextID:  1,
+		ids:     make(map[string]uint64),
+		clients: make(map[uint64]string),
+	}
+}
+
+// Submit enters an order and waits until it is accepted
+func (b *ClientBroker) Submit(order Order) (uint64, error) {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	clientOrderID := b.prefix + strconv.FormatUint(id, 10)
+	b.ids[clientOrderID] = id
+	b.clients[id] = clientOrderID
+	b.mu.Unlock()
+
+	tif := matching.OrderTimeInForceGTC
+	if order.IOC {
+		tif = matching.OrderTimeInForceIOC
+	}
+	_, err := b.client.SubmitOrder(b.ctx, client.Order{
+		ClientOrderID: clientOrderID,
+		Symbol:        order.Symbol,
+		Side:          order.Side,
+		Market:        order.Market,
+		TimeInForce:   tif,
+		Price:         order.Price,
+		Quantity:      order.Quantity,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Cancel cancels an open order and waits until it is canceled
+func (b *ClientBroker) Cancel(id uint64) error {
+	b.mu.Lock()
+	clientOrderID, ok := b.clients[id]
+	b.mu.Unlock()
+	if !ok {
+		return ErrUnknownOrder
+	}
+	_, err := b.client.CancelOrder(b.ctx, clientOrderID)
+	return err
+}
+
+// fill converts a fill report of an order of the broker
+func (b *ClientBroker) fill(r gateway.Report) (Fill, bool) {
+	b.mu.Lock()
+	id, ok := b.ids[r.ClientOrderID]
+	b.mu.Unlock()
+	if !ok || r.Type != gateway.ReportFill {
+		return Fill{}, false
+	}
+	side := matching.OrderSideBuy
+	if r.Side == "sell" {
+		side = matching.OrderSideSell
+	}
+	return Fill{
+		OrderID:        id,
+		Symbol:         r.Symbol,
+		Side:           side,
+		Price:          r.LastPrice,
+		Quantity:       r.LastQuantity,
+		LeavesQuantity: r.LeavesQuantity,
+		Time:           time.Unix(0, r.Timestamp),
+	}, true
+}
+
+// Live runs a strategy on the market data of a gateway server, placing its
+// orders through the server
+type Live struct {
+	// Client is the order entry session
+	Client *client.Client
+	// Broker places the orders of the strategy
+	Broker *ClientBroker
+	// Runner delivers the events to the strategy
+	Runner *Runner
+}
+
+// NewLive connects a strategy to a gateway server and streams the market
+// data of symbols to it until ctx ends. Each symbol starts with a depth
+// snapshot published as book additions. Close the session, or cancel ctx, to
+// stop.
+func NewLive(ctx context.Context, cfg client.Config, strategy Strategy, symbols []string) (*Live, error) {
+	l := &Live{}
+	ready := make(chan struct{})
+	onReport := cfg.OnReport
+	cfg.OnReport = func(r gateway.Report) {
+		if onReport != nil {
+			onReport(r)
+		}
+		<-ready
+		if f, ok := l.Broker.fill(r); ok {
+			l.Runner.OnFill(f)
+		}
+	}
+	c, err := client.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	l.Client = c
+	l.Broker = NewClientBroker(ctx, c, strconv.FormatInt(time.Now().UnixNano(), 36)+"-")
+	l.Runner = NewRunner(strategy, l.Broker)
+	close(ready)
+
+	for _, symbol := range symbols {
+		if err := l.stream(ctx, symbol); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// stream publishes the depth snapshot of a symbol and then its market data
+func (l *Live) stream(ctx context.Context, symbol string) error {
+	depth, err := l.Client.Depth(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	events, err := l.Client.SubscribeMarketData(ctx, symbol, depth.Sequence+1)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, level := range depth.Bids {
+		l.Runner.OnMarketEvent(MarketEvent{Type: EventBookUpdate, Time: now, Symbol: symbol, Side: matching.OrderSideBuy, Action: BookAdd, Price: level.Price, Quantity: level.Volume, Orders: level.Orders})
+	}
+	for _, level := range depth.Asks {
+		l.Runner.OnMarketEvent(MarketEvent{Type: EventBookUpdate, Time: now, Symbol: symbol, Side: matching.OrderSideSell, Action: BookAdd, Price: level.Price, Quantity: level.Volume, Orders: level.Orders})
+	}
+	go func() {
+		for md := range events {
+			l.Runner.OnMarketEvent(marketEvent(md))
+		}
+	}()
+	return nil
+}
+
+// Close ends the session
+func (l *Live) Close() error {
+	return l.Client.Close()
+}
+
+// marketEvent converts a gateway market data event
+func marketEvent(md gateway.MarketData) MarketEvent {
+	e := MarketEvent{
+		Time:     time.Unix(0, md.Timestamp),
+		Symbol:   md.Symbol,
+		Price:    md.Price,
+		Quantity: md.Volume,
+		Orders:   md.Orders,
+	}
+	switch md.Type {
+	case gateway.MarketDataTrade:
+		e.Type = EventTrade
+		e.Quantity = md.Quantity
+		e.Side = matching.OrderSideBuy
+		if md.Aggressor == "sell" {
+			e.Side = matching.OrderSideSell
+		}
+		return e
+	case gateway.MarketDataLevelAdd:
+		e.Action = BookAdd
+	case gateway.MarketDataLevelUpdate:
+		e.Action = BookUpdate
+	case gateway.MarketDataLevelDelete:
+		e.Action = BookDelete
+	}
+	e.Type = EventBookUpdate
+	e.Side = matching.OrderSideSell
+	if md.Side == "bid" {
+		e.Side = matching.OrderSideBuy
+	}
+	return e
+}
@@ -0,0 +1,98 @@
+package strategy
+
+import (
+	"io"
+	"math"
+	"time"
+
+	"github.com/tienpsm/go-trader/itch"
+	"github.com/tienpsm/go-trader/sim"
+)
+
+// SimBroker places the orders of a strategy with a sim.Simulator
+type SimBroker struct {
+	sim *sim.Simulator
+}
+
+// NewSimBroker creates a broker placing orders with s
+func NewSimBroker(s *sim.Simulator) *SimBroker {
+	return &SimBroker{sim: s}
+}
+
+// Submit places an order with the simulator. ITCH prices and share counts
+// are 32 bits, so larger values are rejected.
+func (b *SimBroker) Submit(order Order) (uint64, error) {
+	if order.Price > math.MaxUint32 || order.Quantity > math.MaxUint32 {
+		return 0, sim.ErrInvalidOrder
+	}
+	o, err := b.sim.Submit(sim.Order{
+		Stock:  order.Symbol,
+		Side:   order.Side,
+		Market: order.Market,
+		IOC:    order.IOC,
+		Price:  uint32(order.Price),
+		Shares: uint32(order.Quantity),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return o.ID, nil
+}
+
+// Cancel cancels an open order of the simulator
+func (b *SimBroker) Cancel(id uint64) error {
+	if _, err := b.sim.Cancel(id); err != nil {
+		return ErrUnknownOrder
+	}
+	return nil
+}
+
+// Paper runs a strategy on an ITCH feed, live or replayed, with a simulator
+// as its broker. Parse the feed with the Paper as the itch.Handler.
+type Paper struct {
+	*ITCHSource
+
+	// Simulator fills the orders of the strategy
+	Simulator *sim.Simulator
+	// Runner delivers the events to the strategy
+	Runner *Runner
+	// Performance tracks the profit of the strategy, sampled every minute
+	Performance *Performance
+}
+
+// NewPaper creates a paper trading session of date for a strategy. The
+// simulator sees every message before the strategy, so fills caused by a
+// message are delivered before its market events.
+func NewPaper(strategy Strategy, date time.Time, cfg sim.Config) *Paper {
+	simulator := sim.New(cfg)
+	runner := NewRunner(strategy, NewSimBroker(simulator))
+	performance := NewPerformance(time.Minute)
+	runner.Track(performance)
+	source := NewITCHSource(date, runner.OnMarketEvent)
+	source.Next = simulator
+
+	day := source.date
+	simulator.OnFill = func(f sim.Fill) {
+		runner.OnFill(Fill{
+			OrderID:        f.OrderID,
+			Symbol:         f.Stock,
+			Side:           f.Side,
+			Price:          uint64(f.Price),
+			Quantity:       uint64(f.Shares),
+			LeavesQuantity: uint64(f.LeavesShares),
+			Time:           day.Add(time.Duration(f.Timestamp)),
+		})
+	}
+	return &Paper{ITCHSource: source, Simulator: simulator, Runner: runner, Performance: performance}
+}
+
+// Backtest runs a strategy on a recorded ITCH session of date, read from r as
+// length-prefixed messages, and returns the session once the file is parsed.
+// Its Performance reports the results of the strategy.
+func Backtest(r io.Reader, strategy Strategy, date time.Time, cfg sim.Config) (*Paper, error) {
+	p := NewPaper(strategy, date, cfg)
+	if _, err := itch.NewParser(p).ParseStream(r); err != nil {
+		return p, err
+	}
+	return p, nil
+}
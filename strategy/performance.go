@@ -0,0 +1,383 @@
+package strategy
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// sessionsPerYear and sessionLength annualize the Sharpe and Sortino ratios
+const (
+	sessionsPerYear = 252
+	sessionLength   = 6*time.Hour + 30*time.Minute
+)
+
+// Performance accumulates the fills of a strategy and the prices of its
+// symbols into an equity curve, from which Report computes the performance
+// statistics. Positions are marked at the mid of the book, or the last trade
+// or fill without one. Amounts are in price units times quantity, with the
+// implied decimals of the source.
+type Performance struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	symbols map[string]*symbolPerformance
+	orders  map[uint64]struct{}
+	fills   FillStats
+	// curve is the equity at the end of every interval with events
+	curve []EquityPoint
+	// end is the end of the current interval, zero before the first event
+	end   time.Time
+	start time.Time
+	last  time.Time
+}
+
+// symbolPerformance is the position and profit of one symbol
+type symbolPerformance struct {
+	position int64
+	// average is the average price of the open position
+	average  float64
+	realized float64
+	mark     uint64
+
+	fills    int
+	bought   uint64
+	sold     uint64
+	notional float64
+}
+
+// unrealized returns the profit of the open position at the mark
+func (s *symbolPerformance) unrealized() float64 {
+	return float64(s.position) * (float64(s.mark) - s.average)
+}
+
+// FillStats are the fill statistics of a strategy
+type FillStats struct {
+	Fills     int `json:"fills"`
+	BuyFills  int `json:"buy_fills"`
+	SellFills int `json:"sell_fills"`
+	// Orders is the number of orders with fills
+	Orders   int    `json:"orders"`
+	Quantity uint64 `json:"quantity"`
+	// AverageQuantity is the average quantity of a fill
+	AverageQuantity float64 `json:"average_quantity"`
+	// ClosingFills reduce an open position, WinningFills are the closing
+	// fills with a profit
+	ClosingFills int `json:"closing_fills"`
+	WinningFills int `json:"winning_fills"`
+}
+
+// SymbolPerformance is the profit attribution of one symbol
+type SymbolPerformance struct {
+	Symbol string `json:"symbol"`
+	// Position is the net position, negative when short, and AveragePrice
+	// its average price
+	Position     int64   `json:"position"`
+	AveragePrice float64 `json:"average_price"`
+	// Mark is the price the position is valued at
+	Mark          uint64  `json:"mark"`
+	RealizedPnL   float64 `json:"realized_pnl"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+	PnL           float64 `json:"pnl"`
+
+	Fills          int     `json:"fills"`
+	BoughtQuantity uint64  `json:"bought_quantity"`
+	SoldQuantity   uint64  `json:"sold_quantity"`
+	TradedNotional float64 `json:"traded_notional"`
+}
+
+// EquityPoint is a sample of the equity curve
+type EquityPoint struct {
+	Time   time.Time `json:"time"`
+	Equity float64   `json:"equity"`
+	// Drawdown is the decline from the highest equity before
+	Drawdown float64 `json:"drawdown"`
+}
+
+// PerformanceReport is the performance of a strategy over a session
+type PerformanceReport struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Capital is the capital the relative statistics are computed against,
+	// 0 if none was given
+	Capital float64 `json:"capital"`
+
+	PnL           float64 `json:"pnl"`
+	RealizedPnL   float64 `json:"realized_pnl"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+	// MaxDrawdown is the largest decline of the equity from a peak, and
+	// MaxDrawdownPercent that decline relative to the capital at the peak,
+	// 0 without capital
+	MaxDrawdown        float64 `json:"max_drawdown"`
+	MaxDrawdownPercent float64 `json:"max_drawdown_percent"`
+	// Sharpe and Sortino are the annualized ratios of the equity changes per
+	// interval, assuming 252 sessions of 6.5 hours a year and no risk-free
+	// rate
+	Sharpe  float64 `json:"sharpe"`
+	Sortino float64 `json:"sortino"`
+	// HitRate is the share of closing fills with a profit
+	HitRate float64 `json:"hit_rate"`
+	// TradedNotional is the sum of price times quantity of all fills, and
+	// Turnover that sum relative to the capital, 0 without capital
+	TradedNotional float64 `json:"traded_notional"`
+	Turnover       float64 `json:"turnover"`
+
+	Fills   FillStats           `json:"fill_stats"`
+	Symbols []SymbolPerformance `json:"symbols"`
+	Equity  []EquityPoint       `json:"equity"`
+}
+
+// NewPerformance creates a performance tracker sampling the equity curve
+// every interval, one minute if interval is not positive
+func NewPerformance(interval time.Duration) *Performance {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &Performance{
+		interval: interval,
+		symbols:  make(map[string]*symbolPerformance),
+		orders:   make(map[uint64]struct{}),
+	}
+}
+
+// Interval returns the sampling interval of the equity curve
+func (p *Performance) Interval() time.Duration {
+	return p.interval
+}
+
+// OnMarketEvent marks the symbol of an event: at the mid of book after a
+// book update, at the trade price after a trade
+func (p *Performance) OnMarketEvent(event MarketEvent, book *Book) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.advance(event.Time)
+	s := p.symbol(event.Symbol)
+	switch event.Type {
+	case EventBookUpdate:
+		if book == nil {
+			return
+		}
+		bid, hasBid := book.BestBid()
+		ask, hasAsk := book.BestAsk()
+		if hasBid && hasAsk {
+			s.mark = (bid.Price + ask.Price) / 2
+		}
+	case EventTrade:
+		s.mark = event.Price
+	}
+}
+
+// OnFill records a fill, realizing the profit of the part closing the open
+// position at its average price
+func (p *Performance) OnFill(fill Fill) {
+	if fill.Quantity == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.advance(fill.Time)
+	s := p.symbol(fill.Symbol)
+	price := float64(fill.Price)
+	quantity := int64(fill.Quantity)
+	if fill.Side == matching.OrderSideSell {
+		quantity = -quantity
+		s.sold += fill.Quantity
+		p.fills.SellFills++
+	} else {
+		s.bought += fill.Quantity
+		p.fills.BuyFills++
+	}
+	s.fills++
+	s.notional += price * float64(fill.Quantity)
+	if s.mark == 0 {
+		s.mark = fill.Price
+	}
+	p.fills.Fills++
+	p.fills.Quantity += fill.Quantity
+	p.orders[fill.OrderID] = struct{}{}
+
+	if s.position == 0 || (s.position > 0) == (quantity > 0) {
+		// Opening or adding to the position
+		held := math.Abs(float64(s.position))
+		s.average = (s.average*held + price*float64(fill.Quantity)) / (held + float64(fill.Quantity))
+		s.position += quantity
+		return
+	}
+
+	closed := min(abs(quantity), abs(s.position))
+	profit := float64(closed) * (price - s.average)
+	if s.position < 0 {
+		profit = -profit
+	}
+	s.realized += profit
+	p.fills.ClosingFills++
+	if profit > 0 {
+		p.fills.WinningFills++
+	}
+	s.position += quantity
+	switch {
+	case s.position == 0:
+		s.average = 0
+	case abs(quantity) > closed:
+		// The fill reversed the position
+		s.average = price
+	}
+}
+
+// Report computes the performance so far. capital scales the drawdown
+// percentage and the turnover, 0 to leave them out.
+func (p *Performance) Report(capital float64) PerformanceReport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	r := PerformanceReport{Start: p.start, End: p.last, Capital: capital, Fills: p.fills}
+	r.Fills.Orders = len(p.orders)
+	if r.Fills.Fills > 0 {
+		r.Fills.AverageQuantity = float64(r.Fills.Quantity) / float64(r.Fills.Fills)
+	}
+	if r.Fills.ClosingFills > 0 {
+		r.HitRate = float64(r.Fills.WinningFills) / float64(r.Fills.ClosingFills)
+	}
+
+	names := make([]string, 0, len(p.symbols))
+	for name := range p.symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := p.symbols[name]
+		if s.fills == 0 {
+			continue
+		}
+		unrealized := s.unrealized()
+		r.Symbols = append(r.Symbols, SymbolPerformance{
+			Symbol:         name,
+			Position:       s.position,
+			AveragePrice:   s.average,
+			Mark:           s.mark,
+			RealizedPnL:    s.realized,
+			UnrealizedPnL:  unrealized,
+			PnL:            s.realized + unrealized,
+			Fills:          s.fills,
+			BoughtQuantity: s.bought,
+			SoldQuantity:   s.sold,
+			TradedNotional: s.notional,
+		})
+		r.RealizedPnL += s.realized
+		r.UnrealizedPnL += unrealized
+		r.TradedNotional += s.notional
+	}
+	r.PnL = r.RealizedPnL + r.UnrealizedPnL
+	if capital > 0 {
+		r.Turnover = r.TradedNotional / capital
+	}
+
+	// The curve ends with the equity after the last event
+	curve := append([]EquityPoint(nil), p.curve...)
+	if n := len(curve); !p.last.IsZero() && (n == 0 || !curve[n-1].Time.Equal(p.last) || curve[n-1].Equity != r.PnL) {
+		curve = append(curve, EquityPoint{Time: p.last, Equity: r.PnL})
+	}
+
+	peak := 0.0
+	returns := make([]float64, 0, len(curve))
+	previous := 0.0
+	for i := range curve {
+		point := &curve[i]
+		peak = max(peak, point.Equity)
+		point.Drawdown = peak - point.Equity
+		if point.Drawdown > r.MaxDrawdown {
+			r.MaxDrawdown = point.Drawdown
+			if capital > 0 {
+				r.MaxDrawdownPercent = 100 * point.Drawdown / (capital + peak)
+			}
+		}
+		returns = append(returns, point.Equity-previous)
+		previous = point.Equity
+	}
+	r.Equity = curve
+
+	periods := float64(sessionsPerYear) * float64(sessionLength) / float64(p.interval)
+	r.Sharpe, r.Sortino = ratios(returns, periods)
+	return r
+}
+
+// ratios returns the annualized Sharpe and Sortino ratios of returns, 0 when
+// they are undefined
+func ratios(returns []float64, periodsPerYear float64) (sharpe, sortino float64) {
+	n := float64(len(returns))
+	if n < 2 {
+		return 0, 0
+	}
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= n
+
+	variance, downside := 0.0, 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+		if r < 0 {
+			downside += r * r
+		}
+	}
+	annualize := math.Sqrt(periodsPerYear)
+	if std := math.Sqrt(variance / (n - 1)); std > 0 {
+		sharpe = mean / std * annualize
+	}
+	if deviation := math.Sqrt(downside / n); deviation > 0 {
+		sortino = mean / deviation * annualize
+	}
+	return sharpe, sortino
+}
+
+// advance samples the equity when t starts a new interval. Intervals without
+// events are not sampled.
+func (p *Performance) advance(t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	if p.start.IsZero() {
+		p.start = t
+	}
+	if !p.end.IsZero() && !t.Before(p.end) {
+		p.curve = append(p.curve, EquityPoint{Time: p.end, Equity: p.equity()})
+	}
+	if p.end.IsZero() || !t.Before(p.end) {
+		p.end = t.Truncate(p.interval).Add(p.interval)
+	}
+	if t.After(p.last) {
+		p.last = t
+	}
+}
+
+// equity returns the realized and unrealized profit of all symbols
+func (p *Performance) equity() float64 {
+	equity := 0.0
+	for _, s := range p.symbols {
+		equity += s.realized + s.unrealized()
+	}
+	return equity
+}
+
+// symbol returns the performance of a symbol, creating it on first use
+func (p *Performance) symbol(name string) *symbolPerformance {
+	s := p.symbols[name]
+	if s == nil {
+		s = &symbolPerformance{}
+		p.symbols[name] = s
+	}
+	return s
+}
+
+func abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
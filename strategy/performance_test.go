@@ -0,0 +1,142 @@
+package strategy
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// performanceSession feeds a session of three fills: a buy, a profitable
+// partial sale and a losing sale reversing the position
+func performanceSession() *Performance {
+	at := func(minute, second int) time.Time {
+		return time.Date(2024, 3, 1, 10, minute, second, 0, time.UTC)
+	}
+	p := NewPerformance(time.Minute)
+	book := NewBook("AAPL")
+	for _, e := range []MarketEvent{
+		{Type: EventBookUpdate, Time: at(0, 0), Symbol: "AAPL", Side: matching.OrderSideBuy, Action: BookAdd, Price: 100, Quantity: 10, Orders: 1},
+		{Type: EventBookUpdate, Time: at(0, 0), Symbol: "AAPL", Side: matching.OrderSideSell, Action: BookAdd, Price: 102, Quantity: 10, Orders: 1},
+	} {
+		book.Apply(e)
+		p.OnMarketEvent(e, book)
+	}
+	p.OnFill(Fill{OrderID: 1, Symbol: "AAPL", Side: matching.OrderSideBuy, Price: 101, Quantity: 10, Time: at(0, 10)})
+	p.OnMarketEvent(MarketEvent{Type: EventTrade, Time: at(1, 5), Symbol: "AAPL", Price: 105, Quantity: 1}, nil)
+	p.OnFill(Fill{OrderID: 2, Symbol: "AAPL", Side: matching.OrderSideSell, Price: 104, Quantity: 5, Time: at(2, 30)})
+	p.OnMarketEvent(MarketEvent{Type: EventTrade, Time: at(3, 10), Symbol: "AAPL", Price: 99, Quantity: 1}, nil)
+	p.OnFill(Fill{OrderID: 3, Symbol: "AAPL", Side: matching.OrderSideSell, Price: 98, Quantity: 10, Time: at(4, 0)})
+	return p
+}
+
+func TestPerformance_Report(t *testing.T) {
+	r := performanceSession().Report(1000)
+
+	if r.RealizedPnL != 0 || r.UnrealizedPnL != -5 || r.PnL != -5 {
+		t.Errorf("Expected realized 0 and unrealized -5, got %v and %v", r.RealizedPnL, r.UnrealizedPnL)
+	}
+	if len(r.Symbols) != 1 {
+		t.Fatalf("Expected 1 symbol, got %+v", r.Symbols)
+	}
+	if s := r.Symbols[0]; s.Position != -5 || s.AveragePrice != 98 || s.Mark != 99 || s.BoughtQuantity != 10 || s.SoldQuantity != 15 {
+		t.Errorf("Expected short 5 at 98 marked at 99, got %+v", s)
+	}
+
+	var equity []float64
+	for _, p := range r.Equity {
+		equity = append(equity, p.Equity)
+	}
+	if want := []float64{0, 40, 35, 5, -5}; !equalFloats(equity, want) {
+		t.Errorf("Expected equity curve %v, got %v", want, equity)
+	}
+	if r.MaxDrawdown != 45 || math.Abs(r.MaxDrawdownPercent-100*45.0/1040) > 1e-9 {
+		t.Errorf("Expected max drawdown 45 (4.33%%), got %v (%v%%)", r.MaxDrawdown, r.MaxDrawdownPercent)
+	}
+	if r.Sharpe >= 0 || r.Sortino >= 0 {
+		t.Errorf("Expected negative ratios for a losing session, got %v and %v", r.Sharpe, r.Sortino)
+	}
+
+	if r.Fills.Fills != 3 || r.Fills.Orders != 3 || r.Fills.BuyFills != 1 || r.Fills.SellFills != 2 || r.Fills.Quantity != 25 {
+		t.Errorf("Expected 3 fills of 25, got %+v", r.Fills)
+	}
+	if r.Fills.ClosingFills != 2 || r.Fills.WinningFills != 1 || r.HitRate != 0.5 {
+		t.Errorf("Expected 1 of 2 closing fills winning, got %+v", r.Fills)
+	}
+	if r.TradedNotional != 2510 || r.Turnover != 2.51 {
+		t.Errorf("Expected traded notional 2510 and turnover 2.51, got %v and %v", r.TradedNotional, r.Turnover)
+	}
+}
+
+func TestRatios(t *testing.T) {
+	sharpe, sortino := ratios([]float64{1, 3}, 4)
+	if math.Abs(sharpe-2*math.Sqrt2) > 1e-9 || sortino != 0 {
+		t.Errorf("Expected Sharpe 2.83 and no Sortino, got %v and %v", sharpe, sortino)
+	}
+	sharpe, sortino = ratios([]float64{2, -1, 2}, 1)
+	if math.Abs(sharpe-1/math.Sqrt(3)) > 1e-9 || math.Abs(sortino-math.Sqrt(3)) > 1e-9 {
+		t.Errorf("Expected Sharpe 0.58 and Sortino 1.73, got %v and %v", sharpe, sortino)
+	}
+	if sharpe, sortino = ratios([]float64{5}, 1); sharpe != 0 || sortino != 0 {
+		t.Errorf("Expected no ratios of a single return, got %v and %v", sharpe, sortino)
+	}
+}
+
+func TestPerformanceReport_Export(t *testing.T) {
+	r := performanceSession().Report(0)
+
+	var buf bytes.Buffer
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	var decoded PerformanceReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if decoded.PnL != r.PnL || len(decoded.Equity) != len(r.Equity) || decoded.Symbols[0].Symbol != "AAPL" {
+		t.Errorf("Expected the report back from JSON, got %+v", decoded)
+	}
+
+	buf.Reset()
+	if err := r.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[1] != "AAPL,-5,98,99,0,-5,-5,3,10,15,2510" {
+		t.Errorf("Expected the AAPL row, got %q", lines)
+	}
+
+	buf.Reset()
+	if err := r.WriteEquityCSV(&buf); err != nil {
+		t.Fatalf("WriteEquityCSV: %v", err)
+	}
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 6 || lines[2] != "2024-03-01T10:02:00Z,40,0" || lines[5] != "2024-03-01T10:04:00Z,-5,45" {
+		t.Errorf("Expected 5 equity points, got %q", lines)
+	}
+
+	buf.Reset()
+	if err := r.WriteHTML(&buf); err != nil {
+		t.Fatalf("WriteHTML: %v", err)
+	}
+	page := buf.String()
+	if !strings.Contains(page, "<svg") || !strings.Contains(page, "<td>AAPL</td>") || strings.Contains(page, "Turnover") {
+		t.Errorf("Expected the chart and the AAPL row without turnover, got %s", page)
+	}
+}
+
+func equalFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
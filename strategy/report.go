@@ -0,0 +1,165 @@
+package strategy
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// symbolsHeader is the header row of the per-symbol CSV
+var symbolsHeader = []string{
+	"symbol", "position", "average_price", "mark", "realized_pnl", "unrealized_pnl", "pnl",
+	"fills", "bought_quantity", "sold_quantity", "traded_notional",
+}
+
+// equityHeader is the header row of the equity curve CSV
+var equityHeader = []string{"time", "equity", "drawdown"}
+
+// WriteJSON writes the report as indented JSON
+func (r PerformanceReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes the per-symbol attribution as CSV with a header row
+func (r PerformanceReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(symbolsHeader); err != nil {
+		return err
+	}
+	for _, s := range r.Symbols {
+		record := []string{
+			s.Symbol,
+			strconv.FormatInt(s.Position, 10),
+			formatAmount(s.AveragePrice),
+			strconv.FormatUint(s.Mark, 10),
+			formatAmount(s.RealizedPnL),
+			formatAmount(s.UnrealizedPnL),
+			formatAmount(s.PnL),
+			strconv.Itoa(s.Fills),
+			strconv.FormatUint(s.BoughtQuantity, 10),
+			strconv.FormatUint(s.SoldQuantity, 10),
+			formatAmount(s.TradedNotional),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteEquityCSV writes the equity curve as CSV with a header row. Times are
+// UTC with nanoseconds.
+func (r PerformanceReport) WriteEquityCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(equityHeader); err != nil {
+		return err
+	}
+	for _, p := range r.Equity {
+		record := []string{p.Time.UTC().Format(time.RFC3339Nano), formatAmount(p.Equity), formatAmount(p.Drawdown)}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteHTML writes the report as a standalone HTML page with the equity curve
+// drawn as an SVG chart
+func (r PerformanceReport) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, struct {
+		PerformanceReport
+		Chart template.HTML
+	}{r, equityChart(r.Equity, 800, 240)})
+}
+
+// formatAmount formats an amount with the fewest digits representing it
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// equityChart draws the equity curve as an SVG polyline of width by height
+// pixels
+func equityChart(points []EquityPoint, width, height float64) template.HTML {
+	if len(points) == 0 {
+		return ""
+	}
+	low, high := 0.0, 0.0
+	for _, p := range points {
+		low, high = min(low, p.Equity), max(high, p.Equity)
+	}
+	if high == low {
+		high = low + 1
+	}
+	start, end := points[0].Time, points[len(points)-1].Time
+	span := float64(end.Sub(start))
+
+	var path strings.Builder
+	for i, p := range points {
+		x := 0.0
+		if span > 0 {
+			x = width * float64(p.Time.Sub(start)) / span
+		}
+		y := height * (high - p.Equity) / (high - low)
+		if i > 0 {
+			path.WriteByte(' ')
+		}
+		fmt.Fprintf(&path, "%.1f,%.1f", x, y)
+	}
+	zero := height * high / (high - low)
+	return template.HTML(fmt.Sprintf(
+		`<svg width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f">`+
+			`<line x1="0" y1="%.1f" x2="%.0f" y2="%.1f" stroke="#ccc"/>`+
+			`<polyline fill="none" stroke="#1f77b4" points="%s"/></svg>`,
+		width, height, width, height, zero, width, zero, path.String()))
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"amount":  func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) },
+	"percent": func(v float64) string { return strconv.FormatFloat(100*v, 'f', 1, 64) + "%" },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Strategy performance</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+</style>
+</head>
+<body>
+<h1>Strategy performance</h1>
+<p>{{.Start.UTC.Format "2006-01-02 15:04:05"}} – {{.End.UTC.Format "2006-01-02 15:04:05"}}</p>
+<table>
+<tr><th>PnL</th><td>{{amount .PnL}}</td></tr>
+<tr><th>Realized PnL</th><td>{{amount .RealizedPnL}}</td></tr>
+<tr><th>Unrealized PnL</th><td>{{amount .UnrealizedPnL}}</td></tr>
+<tr><th>Max drawdown</th><td>{{amount .MaxDrawdown}}{{if .Capital}} ({{amount .MaxDrawdownPercent}}%){{end}}</td></tr>
+<tr><th>Sharpe</th><td>{{amount .Sharpe}}</td></tr>
+<tr><th>Sortino</th><td>{{amount .Sortino}}</td></tr>
+<tr><th>Hit rate</th><td>{{percent .HitRate}}</td></tr>
+<tr><th>Traded notional</th><td>{{amount .TradedNotional}}</td></tr>
+{{if .Capital}}<tr><th>Turnover</th><td>{{amount .Turnover}}</td></tr>
+{{end}}<tr><th>Fills</th><td>{{.Fills.Fills}} ({{.Fills.BuyFills}} buy, {{.Fills.SellFills}} sell) of {{.Fills.Orders}} orders</td></tr>
+<tr><th>Average fill</th><td>{{amount .Fills.AverageQuantity}}</td></tr>
+</table>
+<h2>Equity</h2>
+{{.Chart}}
+<h2>Symbols</h2>
+<table>
+<tr><th>Symbol</th><th>Position</th><th>Average price</th><th>Mark</th><th>Realized</th><th>Unrealized</th><th>PnL</th><th>Fills</th><th>Bought</th><th>Sold</th><th>Notional</th></tr>
+{{range .Symbols}}<tr><td>{{.Symbol}}</td><td>{{.Position}}</td><td>{{amount .AveragePrice}}</td><td>{{.Mark}}</td><td>{{amount .RealizedPnL}}</td><td>{{amount .UnrealizedPnL}}</td><td>{{amount .PnL}}</td><td>{{.Fills}}</td><td>{{.BoughtQuantity}}</td><td>{{.SoldQuantity}}</td><td>{{amount .TradedNotional}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,327 @@
+// Package strategy runs trading strategies on a unified market event model,
+// so the same strategy code runs unchanged in every mode:
+//
+//   - backtest: a recorded ITCH file with the sim package as broker (Backtest)
+//   - paper: a live ITCH feed with the sim package as broker (NewPaper)
+//   - live: the gateway market data and order entry through the client
+//     package (NewLive)
+//
+// A Strategy receives book updates, trades and fills from a Runner, one
+// callback at a time, and places orders through a Broker.
+package strategy
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tienpsm/go-trader/matching"
+)
+
+// ErrUnknownOrder is returned by brokers for orders they did not place
+var ErrUnknownOrder = errors.New("strategy: unknown order")
+
+// EventType is the type of a market event
+type EventType uint8
+
+const (
+	// EventBookUpdate is a change of a price level
+	EventBookUpdate EventType = iota + 1
+	// EventTrade is a trade
+	EventTrade
+)
+
+// String returns the string representation of an EventType
+func (t EventType) String() string {
+	switch t {
+	case EventBookUpdate:
+		return "BOOK_UPDATE"
+	case EventTrade:
+		return "TRADE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// BookAction is the kind of a price level change
+type BookAction uint8
+
+const (
+	// BookAdd is a new price level
+	BookAdd BookAction = iota + 1
+	// BookUpdate is a change of the quantity of a price level
+	BookUpdate
+	// BookDelete is a price level without orders left
+	BookDelete
+)
+
+// String returns the string representation of a BookAction
+func (a BookAction) String() string {
+	switch a {
+	case BookAdd:
+		return "ADD"
+	case BookUpdate:
+		return "UPDATE"
+	case BookDelete:
+		return "DELETE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// MarketEvent is a market data event of any source. Prices are in the units
+// of the source, 4 implied decimals for ITCH feeds.
+type MarketEvent struct {
+	Type   EventType
+	Time   time.Time
+	Symbol string
+	// Side is the book side of an update (buy for bids), or the aggressor
+	// side of a trade
+	Side matching.OrderSide
+	// Action is the kind of a book update
+	Action BookAction
+	// Price is the level or trade price
+	Price uint64
+	// Quantity is the displayed quantity of the level after an update, or
+	// the traded quantity
+	Quantity uint64
+	// Orders is the number of orders of the level after an update
+	Orders uint64
+}
+
+// Order is an order placed by a strategy
+type Order struct {
+	Symbol string
+	Side   matching.OrderSide
+	// Market selects a market order instead of a limit order
+	Market bool
+	// IOC cancels the unfilled remainder instead of resting it
+	IOC      bool
+	Price    uint64
+	Quantity uint64
+}
+
+// Fill is an execution of an order placed by a strategy
+type Fill struct {
+	// OrderID is the ID returned by Broker.Submit
+	OrderID uint64
+	Symbol  string
+	Side    matching.OrderSide
+	Price   uint64
+	// Quantity is the executed quantity
+	Quantity uint64
+	// LeavesQuantity is the remaining quantity of the order after the fill
+	LeavesQuantity uint64
+	Time           time.Time
+}
+
+// Broker places the orders of a strategy
+type Broker interface {
+	// Submit places an order and returns its ID. Fills may be delivered
+	// before Submit returns.
+	Submit(order Order) (uint64, error)
+	// Cancel cancels an open order
+	Cancel(id uint64) error
+}
+
+// Strategy is the interface of trading strategies
+type Strategy interface {
+	// Start is called once with the broker before any other callback
+	Start(broker Broker)
+	// OnBookUpdate is called for every price level change, with the book of
+	// the symbol after the change
+	OnBookUpdate(event MarketEvent, book *Book)
+	// OnTrade is called for every trade
+	OnTrade(event MarketEvent)
+	// OnFill is called for every fill of the strategy's orders
+	OnFill(fill Fill)
+}
+
+// DefaultStrategy is a no-op implementation of Strategy
+type DefaultStrategy struct{}
+
+func (s *DefaultStrategy) Start(broker Broker)                        {}
+func (s *DefaultStrategy) OnBookUpdate(event MarketEvent, book *Book) {}
+func (s *DefaultStrategy) OnTrade(event MarketEvent)                  {}
+func (s *DefaultStrategy) OnFill(fill Fill)                           {}
+
+// Level is a price level of a Book
+type Level struct {
+	Price    uint64
+	Quantity uint64
+	Orders   uint64
+}
+
+// Book is the displayed depth of a symbol built from book updates
+type Book struct {
+	Symbol string
+	bids   map[uint64]Level
+	asks   map[uint64]Level
+}
+
+// NewBook creates an empty book
+func NewBook(symbol string) *Book {
+	return &Book{
+		Symbol: symbol,
+		bids:   make(map[uint64]Level),
+		asks:   make(map[uint64]Level),
+	}
+}
+
+// Apply applies a book update
+func (b *Book) Apply(event MarketEvent) {
+	levels := b.asks
+	if event.Side == matching.OrderSideBuy {
+		levels = b.bids
+	}
+	if event.Action == BookDelete {
+		delete(levels, event.Price)
+		return
+	}
+	levels[event.Price] = Level{Price: event.Price, Quantity: event.Quantity, Orders: event.Orders}
+}
+
+// Bids returns up to depth bid levels, best first (-1 for all)
+func (b *Book) Bids(depth int) []Level {
+	return sortedLevels(b.bids, depth, func(x, y uint64) bool { return x > y })
+}
+
+// Asks returns up to depth ask levels, best first (-1 for all)
+func (b *Book) Asks(depth int) []Level {
+	return sortedLevels(b.asks, depth, func(x, y uint64) bool { return x < y })
+}
+
+// BestBid returns the best bid level
+func (b *Book) BestBid() (Level, bool) {
+	levels := b.Bids(1)
+	if len(levels) == 0 {
+		return Level{}, false
+	}
+	return levels[0], true
+}
+
+// BestAsk returns the best ask level
+func (b *Book) BestAsk() (Level, bool) {
+	levels := b.Asks(1)
+	if len(levels) == 0 {
+		return Level{}, false
+	}
+	return levels[0], true
+}
+
+// sortedLevels copies the levels of one side in priority order
+func sortedLevels(levels map[uint64]Level, depth int, better func(x, y uint64) bool) []Level {
+	result := make([]Level, 0, len(levels))
+	for _, level := range levels {
+		result = append(result, level)
+	}
+	sort.Slice(result, func(i, j int) bool { return better(result[i].Price, result[j].Price) })
+	if depth >= 0 && len(result) > depth {
+		result = result[:depth]
+	}
+	return result
+}
+
+// event is a queued callback of a Runner
+type event struct {
+	market MarketEvent
+	fill   *Fill
+}
+
+// Runner delivers the events of a source and the fills of a broker to a
+// strategy, maintaining the book of every symbol. Callbacks are serialized:
+// events published from other goroutines, or by the strategy's own orders
+// while a callback runs, are queued and delivered after it returns.
+type Runner struct {
+	strategy Strategy
+
+	mu          sync.Mutex
+	queue       []event
+	dispatching bool
+	books       map[string]*Book
+	performance *Performance
+}
+
+// NewRunner creates a runner and starts the strategy with broker
+func NewRunner(strategy Strategy, broker Broker) *Runner {
+	r := &Runner{
+		strategy: strategy,
+		books:    make(map[string]*Book),
+	}
+	strategy.Start(broker)
+	return r
+}
+
+// Track records the events and fills delivered from now on in p. Call it
+// before publishing events.
+func (r *Runner) Track(p *Performance) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.performance = p
+}
+
+// OnMarketEvent delivers a market event
+func (r *Runner) OnMarketEvent(e MarketEvent) {
+	r.enqueue(event{market: e})
+}
+
+// OnFill delivers a fill
+func (r *Runner) OnFill(f Fill) {
+	r.enqueue(event{fill: &f})
+}
+
+// enqueue queues an event and delivers the queue unless another call is
+// already delivering it
+func (r *Runner) enqueue(e event) {
+	r.mu.Lock()
+	r.queue = append(r.queue, e)
+	if r.dispatching {
+		r.mu.Unlock()
+		return
+	}
+	r.dispatching = true
+	for len(r.queue) > 0 {
+		e := r.queue[0]
+		r.queue = r.queue[1:]
+		r.mu.Unlock()
+		r.deliver(e)
+		r.mu.Lock()
+	}
+	r.dispatching = false
+	r.mu.Unlock()
+}
+
+// deliver calls the strategy for an event. Books are only touched here, so
+// they need no lock of their own.
+func (r *Runner) deliver(e event) {
+	r.mu.Lock()
+	performance := r.performance
+	r.mu.Unlock()
+
+	if e.fill != nil {
+		if performance != nil {
+			performance.OnFill(*e.fill)
+		}
+		r.strategy.OnFill(*e.fill)
+		return
+	}
+	switch e.market.Type {
+	case EventBookUpdate:
+		book := r.books[e.market.Symbol]
+		if book == nil {
+			book = NewBook(e.market.Symbol)
+			r.books[e.market.Symbol] = book
+		}
+		book.Apply(e.market)
+		if performance != nil {
+			performance.OnMarketEvent(e.market, book)
+		}
+		r.strategy.OnBookUpdate(e.market, book)
+	case EventTrade:
+		if performance != nil {
+			performance.OnMarketEvent(e.market, nil)
+		}
+		r.strategy.OnTrade(e.market)
+	}
+}
@@ -0,0 +1,168 @@
+package strategy
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/tienpsm/go-trader/itch"
+	"github.com/tienpsm/go-trader/matching"
+	"github.com/tienpsm/go-trader/sim"
+)
+
+var aapl = itch.StockField("AAPL")
+
+// recorder records the callbacks of a strategy
+type recorder struct {
+	DefaultStrategy
+	broker  Broker
+	updates []MarketEvent
+	trades  []MarketEvent
+	fills   []Fill
+}
+
+func (r *recorder) Start(broker Broker)                        { r.broker = broker }
+func (r *recorder) OnBookUpdate(event MarketEvent, book *Book) { r.updates = append(r.updates, event) }
+func (r *recorder) OnTrade(event MarketEvent)                  { r.trades = append(r.trades, event) }
+func (r *recorder) OnFill(fill Fill)                           { r.fills = append(r.fills, fill) }
+
+// joiner joins the best bid once the book has one and records its fills
+type joiner struct {
+	recorder
+	order uint64
+}
+
+func (j *joiner) OnBookUpdate(event MarketEvent, book *Book) {
+	j.recorder.OnBookUpdate(event, book)
+	bid, ok := book.BestBid()
+	if !ok || j.order != 0 {
+		return
+	}
+	id, err := j.broker.Submit(Order{Symbol: event.Symbol, Side: matching.OrderSideBuy, Price: bid.Price, Quantity: 50})
+	if err != nil {
+		panic(err)
+	}
+	j.order = id
+}
+
+func TestBook(t *testing.T) {
+	b := NewBook("AAPL")
+	b.Apply(MarketEvent{Side: matching.OrderSideBuy, Action: BookAdd, Price: 9900, Quantity: 100, Orders: 1})
+	b.Apply(MarketEvent{Side: matching.OrderSideBuy, Action: BookAdd, Price: 9800, Quantity: 200, Orders: 2})
+	b.Apply(MarketEvent{Side: matching.OrderSideSell, Action: BookAdd, Price: 10100, Quantity: 300, Orders: 1})
+	b.Apply(MarketEvent{Side: matching.OrderSideSell, Action: BookAdd, Price: 10000, Quantity: 50, Orders: 1})
+	b.Apply(MarketEvent{Side: matching.OrderSideBuy, Action: BookUpdate, Price: 9900, Quantity: 60, Orders: 1})
+
+	if bids := b.Bids(-1); len(bids) != 2 || bids[0].Price != 9900 || bids[0].Quantity != 60 || bids[1].Price != 9800 {
+		t.Errorf("Expected bids 60 @ 9900 and 200 @ 9800, got %+v", bids)
+	}
+	if ask, ok := b.BestAsk(); !ok || ask.Price != 10000 {
+		t.Errorf("Expected best ask 10000, got %+v", ask)
+	}
+	b.Apply(MarketEvent{Side: matching.OrderSideSell, Action: BookDelete, Price: 10000})
+	if asks := b.Asks(1); len(asks) != 1 || asks[0].Price != 10100 {
+		t.Errorf("Expected best ask 10100 after delete, got %+v", asks)
+	}
+}
+
+// reentrant publishes a fill from its first book update, as a broker filling
+// an order before Submit returns does
+type reentrant struct {
+	recorder
+	runner *Runner
+	inside bool
+	nested bool
+}
+
+func (s *reentrant) OnBookUpdate(event MarketEvent, book *Book) {
+	s.inside = true
+	defer func() { s.inside = false }()
+	s.recorder.OnBookUpdate(event, book)
+	if len(s.updates) == 1 {
+		s.runner.OnFill(Fill{OrderID: 1, Symbol: event.Symbol, Quantity: 10})
+	}
+}
+
+func (s *reentrant) OnFill(fill Fill) {
+	if s.inside {
+		s.nested = true
+	}
+	s.recorder.OnFill(fill)
+}
+
+func TestRunner_Reentrancy(t *testing.T) {
+	s := &reentrant{}
+	s.runner = NewRunner(s, nil)
+	s.runner.OnMarketEvent(MarketEvent{Type: EventBookUpdate, Symbol: "AAPL", Side: matching.OrderSideBuy, Action: BookAdd, Price: 9900, Quantity: 100, Orders: 1})
+
+	if s.nested {
+		t.Errorf("Expected the fill to be delivered after the book update returned")
+	}
+	if len(s.fills) != 1 || len(s.updates) != 1 {
+		t.Errorf("Expected 1 update and 1 fill, got %d and %d", len(s.updates), len(s.fills))
+	}
+}
+
+func TestITCHSource_Events(t *testing.T) {
+	date := time.Date(2024, 3, 1, 15, 0, 0, 0, time.UTC)
+	var events []MarketEvent
+	h := NewITCHSource(date, func(e MarketEvent) { events = append(events, e) })
+
+	h.OnAddOrder(itch.AddOrderMessage{StockLocate: 1, Timestamp: uint64(time.Hour), OrderReferenceNumber: 1, BuySellIndicator: 'S', Shares: 100, Stock: aapl, Price: 10000})
+	h.OnOrderExecuted(itch.OrderExecutedMessage{StockLocate: 1, OrderReferenceNumber: 1, ExecutedShares: 30})
+	h.OnOrderExecutedWithPrice(itch.OrderExecutedWithPriceMessage{StockLocate: 1, OrderReferenceNumber: 1, ExecutedShares: 20, Printable: 'N', ExecutionPrice: 10010})
+	h.OnTrade(itch.TradeMessage{StockLocate: 1, BuySellIndicator: 'B', Shares: 10, Stock: aapl, Price: 9950})
+
+	if len(events) != 5 {
+		t.Fatalf("Expected 5 events, got %+v", events)
+	}
+	add := events[0]
+	if add.Type != EventBookUpdate || add.Action != BookAdd || add.Symbol != "AAPL" || add.Side != matching.OrderSideSell || add.Quantity != 100 || !add.Time.Equal(time.Date(2024, 3, 1, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected ask level added at 01:00, got %+v", add)
+	}
+	trade := events[1]
+	if trade.Type != EventTrade || trade.Side != matching.OrderSideBuy || trade.Price != 10000 || trade.Quantity != 30 {
+		t.Errorf("Expected buy trade of 30 @ 10000, got %+v", trade)
+	}
+	if events[2].Type != EventBookUpdate || events[2].Quantity != 70 {
+		t.Errorf("Expected level update to 70, got %+v", events[2])
+	}
+	// The non-printable execution only changes the level
+	if events[3].Type != EventBookUpdate || events[3].Quantity != 50 {
+		t.Errorf("Expected level update to 50, got %+v", events[3])
+	}
+	if events[4].Type != EventTrade || events[4].Side != matching.OrderSideSell || events[4].Price != 9950 {
+		t.Errorf("Expected non-displayed sell trade @ 9950, got %+v", events[4])
+	}
+}
+
+func TestBacktest(t *testing.T) {
+	var feed []byte
+	feed = itch.AppendFrame(feed, itch.AppendAddOrder(nil, itch.AddOrderMessage{StockLocate: 1, Timestamp: 1, OrderReferenceNumber: 1, BuySellIndicator: 'B', Shares: 100, Stock: aapl, Price: 9900}))
+	feed = itch.AppendFrame(feed, itch.AppendAddOrder(nil, itch.AddOrderMessage{StockLocate: 1, Timestamp: 2, OrderReferenceNumber: 2, BuySellIndicator: 'B', Shares: 100, Stock: aapl, Price: 9900}))
+	feed = itch.AppendFrame(feed, itch.AppendOrderExecuted(nil, itch.OrderExecutedMessage{StockLocate: 1, Timestamp: 3, OrderReferenceNumber: 1, ExecutedShares: 100}))
+	feed = itch.AppendFrame(feed, itch.AppendOrderExecuted(nil, itch.OrderExecutedMessage{StockLocate: 1, Timestamp: 4, OrderReferenceNumber: 2, ExecutedShares: 100}))
+
+	s := &joiner{}
+	p, err := Backtest(bytes.NewReader(feed), s, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), sim.Config{})
+	if err != nil {
+		t.Fatalf("Backtest: %v", err)
+	}
+	if s.order == 0 {
+		t.Fatalf("Expected an order submitted")
+	}
+	// The order joined behind the first 100 shares, so the execution of the
+	// order behind it fills it
+	if len(s.fills) != 1 || s.fills[0].OrderID != s.order || s.fills[0].Quantity != 50 || s.fills[0].LeavesQuantity != 0 {
+		t.Errorf("Expected the order filled, got %+v", s.fills)
+	}
+	if len(s.trades) != 2 {
+		t.Errorf("Expected 2 trades, got %+v", s.trades)
+	}
+	if pos := p.Simulator.Position("AAPL"); pos.Shares != 50 {
+		t.Errorf("Expected long 50, got %+v", pos)
+	}
+	if r := p.Performance.Report(0); len(r.Symbols) != 1 || r.Symbols[0].Position != 50 || r.Fills.Fills != 1 {
+		t.Errorf("Expected the fill in the performance report, got %+v", r)
+	}
+}